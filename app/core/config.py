@@ -39,6 +39,8 @@ class Settings(BaseModel):
     REDIS_URL: str = os.getenv("REDIS_URL", "redis://redis:6379/0")
     REDIS_HOST: str = os.getenv("REDIS_HOST", "redis")
     REDIS_PORT: int = int(os.getenv("REDIS_PORT", "6379"))
+    # Namespace for the keys shared with the Go service (see app/utils/redis_keys.py)
+    REDIS_KEY_PREFIX: str = os.getenv("REDIS_KEY_PREFIX", "")
     
     # ==================== Streaming ====================
     STREAM_PROXY: Optional[str] = os.getenv("STREAM_PROXY")
@@ -110,6 +112,9 @@ class Settings(BaseModel):
     # ==================== Go Service ====================
     GO_SERVICE_URL: str = os.getenv("GO_SERVICE_URL", "http://go-service:9000")
     GO_SERVICE_TIMEOUT: int = int(os.getenv("GO_SERVICE_TIMEOUT", "30"))
+    # Comments the Go service publishes itself have IDs from here up and no
+    # Postgres row (goCommentIDBase in go-service/snowflake.go)
+    GO_COMMENT_ID_BASE: int = 1 << 40
     
     # ==================== Rate Limiting ====================
     RATE_LIMIT_REQUESTS: int = int(os.getenv("RATE_LIMIT_REQUESTS", "100"))
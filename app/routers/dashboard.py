@@ -11,6 +11,7 @@ from app.models import User, Channel, Video, StreamSchedule, Comment
 from typing import List
 from datetime import datetime
 from app.utils.datetime_utils import now_tehran, to_tehran, format_datetime_persian
+from app.utils.redis_keys import allow_comments_key, stream_pid_key
 import time
 
 router = APIRouter(prefix="/api/dashboard", tags=["dashboard"])
@@ -380,7 +381,7 @@ async def cancel_stream(
                 import signal
                 import os
                 redis_client = redis.Redis(host='redis', port=6379, db=0, decode_responses=True)
-                pid_key = f"stream:pid:{stream_id}"
+                pid_key = stream_pid_key(stream_id)
                 pid_str = redis_client.get(pid_key)
                 
                 if pid_str:
@@ -444,7 +445,7 @@ async def toggle_stream_comments(
     
     if redis_client:
         try:
-            cache_key = f"stream:allow_comments:{stream_id}"
+            cache_key = allow_comments_key(stream_id)
             # Use "1" for true, "0" for false
             value = "1" if enabled else "0"
             redis_client.setex(cache_key, 3600 * 24, value)  # Cache for 24 hours (longer expiry)
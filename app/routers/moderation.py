@@ -6,6 +6,7 @@ from fastapi import Request
 from sqlalchemy.orm import Session
 from app.core.database import get_db
 from app.models import Comment, StreamSchedule, User
+from app.utils.redis_keys import comment_index_key, comment_data_key
 from typing import List
 from datetime import datetime
 
@@ -139,27 +140,29 @@ async def delete_comment(
     user = require_stream_owner_or_moderator(stream_id, request, db)
     
     from app.utils.datetime_utils import now_tehran
+    from app.core.config import settings
     
-    comment = db.query(Comment).filter(
-        Comment.id == comment_id,
-        Comment.stream_id == stream_id
-    ).first()
-    
-    if not comment:
-        raise HTTPException(status_code=404, detail="Comment not found")
-    
-    # Soft delete
-    comment.deleted_at = now_tehran()
-    db.commit()
+    # Comments posted through the Go service only exist in Redis
+    if comment_id < settings.GO_COMMENT_ID_BASE:
+        comment = db.query(Comment).filter(
+            Comment.id == comment_id,
+            Comment.stream_id == stream_id
+        ).first()
+        
+        if not comment:
+            raise HTTPException(status_code=404, detail="Comment not found")
+        
+        # Soft delete
+        comment.deleted_at = now_tehran()
+        db.commit()
     
     # Remove from Redis if exists
     try:
         import redis
-        from app.core.config import settings
         redis_client = redis.Redis.from_url(settings.REDIS_URL, decode_responses=False)
         sid = str(stream_id)
-        idxKey = f"comments:index:{sid}"
-        dataKey = f"comments:data:{sid}"
+        idxKey = comment_index_key(sid)
+        dataKey = comment_data_key(sid)
         redis_client.zrem(idxKey, str(comment_id))
         redis_client.hdel(dataKey, str(comment_id))
     except Exception as e:
@@ -222,8 +225,8 @@ async def approve_comment(
     try:
         redis_client = redis.Redis.from_url(settings.REDIS_URL, decode_responses=False)
         sid = str(stream_id)
-        idxKey = f"comments:index:{sid}"
-        dataKey = f"comments:data:{sid}"
+        idxKey = comment_index_key(sid)
+        dataKey = comment_data_key(sid)
         
         # Store comment data
         comment_data = {
@@ -6,6 +6,7 @@ from fastapi.responses import RedirectResponse, JSONResponse
 from sqlalchemy.orm import Session
 from app.core.database import get_db
 from app.models import Channel, StreamSchedule, Comment, Viewer
+from app.utils.redis_keys import comment_index_key, comment_data_key
 from typing import Optional
 from datetime import datetime
 import uuid
@@ -357,8 +358,8 @@ async def submit_comment(
         
         # Store comment in Redis for auto-approval
         sid = str(stream.id)
-        idxKey = f"comments:index:{sid}"
-        dataKey = f"comments:data:{sid}"
+        idxKey = comment_index_key(sid)
+        dataKey = comment_data_key(sid)
         
         published_at_timestamp = int(comment.published_at.timestamp() * 1000)
         
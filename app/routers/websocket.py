@@ -5,6 +5,7 @@ from fastapi import APIRouter, WebSocket, WebSocketDisconnect, Depends, HTTPExce
 from sqlalchemy.orm import Session
 from app.core.database import get_db
 from app.models import Comment, StreamSchedule, User
+from app.utils.redis_keys import comment_index_key, comment_data_key
 from typing import Dict, Set
 import json
 import asyncio
@@ -150,8 +151,8 @@ async def websocket_comments(
                             
                             redis_client = redis.Redis.from_url(settings.REDIS_URL, decode_responses=False)
                             sid = str(stream_id)
-                            idxKey = f"comments:index:{sid}"
-                            dataKey = f"comments:data:{sid}"
+                            idxKey = comment_index_key(sid)
+                            dataKey = comment_data_key(sid)
                             
                             published_at_timestamp = int(comment.published_at.timestamp() * 1000)
                             comment_data = {
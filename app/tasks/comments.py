@@ -6,6 +6,7 @@ from sqlalchemy.orm import Session
 from app.core.database import SessionLocal
 from app.models import Comment
 from app.utils.datetime_utils import now_tehran
+from app.utils.redis_keys import comment_index_key, comment_data_key
 from datetime import timedelta
 import redis
 import json
@@ -42,8 +43,8 @@ def auto_approve_comments():
                 try:
                     redis_client = redis.Redis.from_url(settings.REDIS_URL, decode_responses=False)
                     sid = str(comment.stream_id)
-                    idxKey = f"comments:index:{sid}"
-                    dataKey = f"comments:data:{sid}"
+                    idxKey = comment_index_key(sid)
+                    dataKey = comment_data_key(sid)
                     
                     published_at_timestamp = int(comment.published_at.timestamp() * 1000)
                     
@@ -7,6 +7,7 @@ from app.core.database import SessionLocal
 from app.models import StreamSchedule, Channel, Video
 from app.utils.datetime_utils import now_tehran, to_tehran
 from app.utils.ffmpeg import get_video_duration
+from app.utils.redis_keys import online_set_key, stream_pid_key
 from datetime import timedelta
 import subprocess
 import os
@@ -227,7 +228,7 @@ def start_stream_task(self, stream_id: int):
         # Store PID in Redis for later cleanup/kill
         import redis
         redis_client = redis.Redis(host='redis', port=6379, db=0, decode_responses=True)
-        pid_key = f"stream:pid:{stream_id}"
+        pid_key = stream_pid_key(stream_id)
         
         while offset_seconds < video_duration:
             attempt += 1
@@ -643,7 +644,7 @@ def kill_stream_process(stream_id: int):
         import time
         
         redis_client = redis.Redis(host='redis', port=6379, db=0, decode_responses=True)
-        pid_key = f"stream:pid:{stream_id}"
+        pid_key = stream_pid_key(stream_id)
         pid_str = redis_client.get(pid_key)
         
         if pid_str:
@@ -743,7 +744,7 @@ def update_max_viewers():
         for stream in live_streams:
             try:
                 # Get online count from Redis (same key used by Go service)
-                online_key = f"online:{stream.id}"
+                online_key = online_set_key(stream.id)
                 online_count = redis_client.scard(online_key)
                 
                 # Update max_viewers if current count is higher
@@ -0,0 +1,51 @@
+"""
+Redis key builders for the keys shared with the Go service
+
+Every key is namespaced with REDIS_KEY_PREFIX so several deployments can
+share one Redis. The Go service builds the same keys with the same prefix
+(go-service/keys.go), so both must be configured alike; never format a key
+inline.
+"""
+from app.core.config import settings
+
+
+def redis_key(name: str) -> str:
+    """
+    Prefix a key name with this deployment's namespace
+    """
+    return f"{settings.REDIS_KEY_PREFIX}{name}"
+
+
+def comment_index_key(stream_id) -> str:
+    """
+    Sorted set of a stream's comment IDs by publish time (ms)
+    """
+    return redis_key(f"comments:index:{stream_id}")
+
+
+def comment_data_key(stream_id) -> str:
+    """
+    Hash of a stream's comments as JSON, by comment ID
+    """
+    return redis_key(f"comments:data:{stream_id}")
+
+
+def online_set_key(stream_id) -> str:
+    """
+    Set of viewers currently online in a stream
+    """
+    return redis_key(f"online:{stream_id}")
+
+
+def allow_comments_key(stream_id) -> str:
+    """
+    Whether a stream's chat is open ("1" or "0")
+    """
+    return redis_key(f"stream:allow_comments:{stream_id}")
+
+
+def stream_pid_key(stream_id) -> str:
+    """
+    PID of the ffmpeg process broadcasting a stream
+    """
+    return redis_key(f"stream:pid:{stream_id}")
@@ -32,9 +32,10 @@ func NewPersianSwear() *PersianSwear {
 	return ps
 }
 
+var clearPattern = regexp.MustCompile(`[\p{P}\p{Mn}\p{S}\p{N}]`)
+
 func (ps *PersianSwear) clearWord(word string) string {
-	pattern := regexp.MustCompile(`[\p{P}\p{Mn}\p{S}\p{N}]`)
-	clearedWord := pattern.ReplaceAllString(word, "")
+	clearedWord := clearPattern.ReplaceAllString(word, "")
 	clearedWord = strings.TrimSpace(clearedWord)
 	clearedWord = strings.ReplaceAll(clearedWord, "\u200c", " ")
 	clearedWord = strings.ReplaceAll(clearedWord, "ي", "ی")
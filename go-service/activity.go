@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// The active-streams set lists the streams people are watching right now,
+// scored by when each became active. Viewer counts on a quiet stream hover
+// around zero as viewers drop in and out, so membership has hysteresis: a
+// stream joins once it has ACTIVE_ENTER_VIEWERS online, and only leaves after
+// staying at or below ACTIVE_EXIT_VIEWERS for ACTIVE_EXIT_DWELL seconds. Any
+// count above the exit threshold during the dwell cancels the exit. Counts
+// are observed on every heartbeat, and the sweeper re-reads the counts of
+// active streams so ones nobody is left watching still age out.
+// ACTIVE_ENTER_VIEWERS=0 turns tracking off.
+var (
+	activeEnterViewers int64
+	activeExitViewers  int64
+	activeExitDwell    time.Duration
+)
+
+func loadActivityConfig() {
+	activeEnterViewers = int64(envInt("ACTIVE_ENTER_VIEWERS", 1))
+	activeExitViewers = int64(envInt("ACTIVE_EXIT_VIEWERS", 0))
+	if activeEnterViewers > 0 && activeExitViewers >= activeEnterViewers {
+		log.Printf("[GO] ACTIVE_EXIT_VIEWERS must be below ACTIVE_ENTER_VIEWERS, using %d", activeEnterViewers-1)
+		activeExitViewers = activeEnterViewers - 1
+	}
+	activeExitDwell = time.Duration(envInt("ACTIVE_EXIT_DWELL", 60)) * time.Second
+}
+
+// activityScript moves a stream in or out of the active set for one observed
+// count. KEYS: active set, leaving hash. ARGV: stream id, count, enter
+// threshold, exit threshold, now (ms), dwell (ms). Returns 1 when the stream
+// became active, -1 when it became inactive, 0 otherwise.
+var activityScript = redis.NewScript(`
+local count, now = tonumber(ARGV[2]), tonumber(ARGV[5])
+if not redis.call('ZSCORE', KEYS[1], ARGV[1]) then
+	if count >= tonumber(ARGV[3]) then
+		redis.call('ZADD', KEYS[1], now, ARGV[1])
+		redis.call('HDEL', KEYS[2], ARGV[1])
+		return 1
+	end
+	return 0
+end
+if count > tonumber(ARGV[4]) then
+	redis.call('HDEL', KEYS[2], ARGV[1])
+	return 0
+end
+local since = tonumber(redis.call('HGET', KEYS[2], ARGV[1]))
+if not since then
+	since = now
+	redis.call('HSET', KEYS[2], ARGV[1], now)
+end
+if now - since >= tonumber(ARGV[6]) then
+	redis.call('ZREM', KEYS[1], ARGV[1])
+	redis.call('HDEL', KEYS[2], ARGV[1])
+	return -1
+end
+return 0
+`)
+
+// observeActivity feeds a stream's current viewer count into the active set
+func observeActivity(ctx context.Context, streamID int64, online int64) {
+	if activeEnterViewers <= 0 {
+		return
+	}
+	changed, err := activityScript.Run(ctx, rdb, []string{activeStreamsKey(), leavingStreamsKey()},
+		streamID, online, activeEnterViewers, activeExitViewers,
+		time.Now().UnixMilli(), activeExitDwell.Milliseconds()).Int()
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error updating stream activity: %v", streamID, err)
+		return
+	}
+	switch changed {
+	case 1:
+		log.Printf("[GO] Stream %d: Active with %d viewers", streamID, online)
+		publishStreamEvent(ctx, streamID, map[string]interface{}{"type": "stream_active", "online": online})
+	case -1:
+		log.Printf("[GO] Stream %d: Inactive", streamID)
+		publishStreamEvent(ctx, streamID, map[string]interface{}{"type": "stream_inactive"})
+	}
+}
+
+// runActivitySweeper periodically re-checks the active streams, which stop
+// receiving heartbeats once their last viewer leaves
+func runActivitySweeper(ctx context.Context, interval time.Duration) {
+	if interval <= 0 || activeEnterViewers <= 0 {
+		log.Printf("[GO] Activity sweeper disabled")
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	jobs.setRunning("activity_sweeper", true)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := sweepActivity(ctx)
+			jobs.ran("activity_sweeper", err)
+			if err != nil {
+				log.Printf("[GO] Activity sweep failed: %v", err)
+			}
+		}
+	}
+}
+
+func sweepActivity(ctx context.Context) error {
+	members, err := rdb.ZRange(ctx, activeStreamsKey(), 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		streamID, err := strconv.ParseInt(member, 10, 64)
+		if err != nil {
+			continue
+		}
+		online, err := store.OnlineCount(ctx, streamID)
+		if err != nil {
+			return err
+		}
+		observeActivity(ctx, streamID, online)
+	}
+	return nil
+}
+
+// ActiveStream is one entry of GET /active-streams
+type ActiveStream struct {
+	StreamID    int64 `json:"stream_id"`
+	ActiveSince int64 `json:"active_since"` // ms
+}
+
+// getActiveStreams lists the active streams, longest active first
+func getActiveStreams(c *gin.Context) {
+	reqCtx := c.Request.Context()
+	entries, err := rdb.ZRangeWithScores(reqCtx, activeStreamsKey(), 0, -1).Result()
+	if err != nil {
+		log.Printf("[GO] Error loading active streams: %v", err)
+		c.JSON(500, gin.H{"error": "failed to load active streams"})
+		return
+	}
+	streams := make([]ActiveStream, 0, len(entries))
+	for _, z := range entries {
+		member, _ := z.Member.(string)
+		streamID, err := strconv.ParseInt(member, 10, 64)
+		if err != nil {
+			continue
+		}
+		streams = append(streams, ActiveStream{StreamID: streamID, ActiveSince: int64(z.Score)})
+	}
+	c.JSON(200, gin.H{"streams": streams, "count": len(streams)})
+}
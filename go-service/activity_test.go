@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// withActivityThresholds sets the hysteresis for the rest of the test
+func withActivityThresholds(t *testing.T, enter, exit int64, dwell time.Duration) {
+	t.Helper()
+	setVar(t, &activeEnterViewers, enter)
+	setVar(t, &activeExitViewers, exit)
+	setVar(t, &activeExitDwell, dwell)
+}
+
+// streamActive reports whether stream 1 is in the active set
+func streamActive(t *testing.T) bool {
+	t.Helper()
+	w := request(t, http.MethodGet, "/active-streams", nil, trusted...)
+	expectStatus(t, w, 200)
+	streams, _ := decode(t, w)["streams"].([]interface{})
+	for _, s := range streams {
+		if s.(map[string]interface{})["stream_id"] == float64(1) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestActivityHysteresis(t *testing.T) {
+	resetRedis(t)
+	withActivityThresholds(t, 3, 1, 50*time.Millisecond)
+
+	for _, step := range []struct {
+		online int64
+		active bool
+	}{
+		{2, false}, // below the enter threshold
+		{3, true},
+		{2, true}, // between the thresholds
+		{1, true}, // the dwell starts
+		{2, true}, // and is cancelled
+		{0, true}, // and starts again
+	} {
+		observeActivity(ctx, 1, step.online)
+		if got := streamActive(t); got != step.active {
+			t.Fatalf("after %d online: active = %v, want %v", step.online, got, step.active)
+		}
+	}
+	time.Sleep(60 * time.Millisecond)
+	observeActivity(ctx, 1, 1)
+	if streamActive(t) {
+		t.Fatal("stream still active after dwelling at the exit threshold")
+	}
+}
+
+func TestActivityOscillationDoesNotFlap(t *testing.T) {
+	resetRedis(t)
+	withActivityThresholds(t, 1, 0, 200*time.Millisecond)
+
+	// A count hovering around zero keeps cancelling the exit
+	observeActivity(ctx, 1, 1)
+	for i := 0; i < 10; i++ {
+		observeActivity(ctx, 1, int64(i%2))
+		if !streamActive(t) {
+			t.Fatalf("stream left the active set at step %d", i)
+		}
+		time.Sleep(30 * time.Millisecond)
+	}
+}
+
+func TestActivitySweeperAgesOutAbandonedStreams(t *testing.T) {
+	resetRedis(t)
+	withActivityThresholds(t, 1, 0, 50*time.Millisecond)
+	observeActivity(ctx, 1, 1)
+
+	// Nobody is online and no heartbeat arrives: only the sweeper sees it
+	if err := sweepActivity(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !streamActive(t) {
+		t.Fatal("stream left the active set before the dwell")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if err := sweepActivity(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if streamActive(t) {
+		t.Fatal("abandoned stream still active after the dwell")
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// Publication states reported by the admin comment query
+const (
+	statusPublished = "published"
+	statusScheduled = "scheduled" // timestamp still in the future
+	statusExpired   = "expired"
+	statusMissing   = "missing" // indexed, but its data is gone
+)
+
+const maxAdminQueryLimit = 1000
+
+type StoredComment struct {
+	ID      string   `json:"id"`
+	Score   int64    `json:"score"`
+	Status  string   `json:"status"`
+	Comment *Comment `json:"comment,omitempty"`
+}
+
+// getStoredComments returns what is stored for a stream in a time range,
+// ignoring the publish gate, so operators can compare stored vs visible
+func getStoredComments(c *gin.Context) {
+	streamID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid stream id"})
+		return
+	}
+	from, err := strconv.ParseInt(c.DefaultQuery("from", "0"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "from must be a timestamp in ms"})
+		return
+	}
+	to := c.DefaultQuery("to", "+inf")
+	if to != "+inf" {
+		if _, err := strconv.ParseInt(to, 10, 64); err != nil {
+			c.JSON(400, gin.H{"error": "to must be a timestamp in ms"})
+			return
+		}
+	}
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "100"), 10, 64)
+	if err != nil || limit <= 0 || limit > maxAdminQueryLimit {
+		c.JSON(400, gin.H{"error": "limit must be between 1 and 1000"})
+		return
+	}
+
+	reqCtx := c.Request.Context()
+	entries, err := rdb.ZRangeByScoreWithScores(reqCtx, commentIndexKey(streamID), &redis.ZRangeBy{
+		Min:   strconv.FormatInt(from, 10),
+		Max:   to,
+		Count: limit + 1, // one extra tells us the range was truncated
+	}).Result()
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error querying stored comments: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to query comments"})
+		return
+	}
+	truncated := int64(len(entries)) > limit
+	if truncated {
+		entries = entries[:limit]
+	}
+
+	stored := make([]StoredComment, 0, len(entries))
+	if len(entries) > 0 {
+		ids := make([]string, len(entries))
+		for i, z := range entries {
+			ids[i] = z.Member.(string)
+		}
+		data, dataErr := rdb.HMGet(reqCtx, commentDataKey(streamID), ids...).Result()
+		if dataErr != nil {
+			log.Printf("[GO] Stream %d: Error loading stored comments: %v", streamID, dataErr)
+			c.JSON(500, gin.H{"error": "failed to query comments"})
+			return
+		}
+
+		now := time.Now().UnixMilli()
+		for i, z := range entries {
+			entry := StoredComment{ID: ids[i], Score: int64(z.Score), Status: statusMissing}
+			if raw, ok := data[i].(string); ok {
+				var cmt Comment
+				if json.Unmarshal([]byte(raw), &cmt) == nil {
+					entry.Comment = &cmt
+					switch {
+					case isExpired(cmt, now):
+						entry.Status = statusExpired
+					case entry.Score > now:
+						entry.Status = statusScheduled
+					default:
+						entry.Status = statusPublished
+					}
+				}
+			}
+			stored = append(stored, entry)
+		}
+	}
+
+	c.JSON(200, gin.H{"comments": stored, "truncated": truncated})
+}
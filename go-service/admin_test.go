@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// storedComments runs the admin comment query on stream 1 and returns
+// "id:status" entries and whether the result was truncated
+func storedComments(t *testing.T, query string) ([]string, bool) {
+	t.Helper()
+	w := request(t, http.MethodGet, "/stream/1/comments"+query, nil, asRole(roleModerator)...)
+	expectStatus(t, w, 200)
+	resp := decode(t, w)
+	list, _ := resp["comments"].([]interface{})
+	out := make([]string, 0, len(list))
+	for _, e := range list {
+		m := e.(map[string]interface{})
+		out = append(out, fmt.Sprintf("%s:%s", m["id"], m["status"]))
+	}
+	return out, resp["truncated"] == true
+}
+
+func TestStoredCommentsAcrossTime(t *testing.T) {
+	resetRedis(t)
+	now := time.Now()
+	past := now.Add(-time.Hour).UnixMilli()
+	saveAt(t, past, 1)
+	saveAt(t, now.Add(-time.Second).UnixMilli(), 2)
+	saveAt(t, now.Add(time.Hour).UnixMilli(), 3)
+	testRedis.ZAdd(commentIndexKey(1), float64(now.Add(-time.Minute).UnixMilli()), "4")
+
+	got, truncated := storedComments(t, "")
+	if fmt.Sprint(got) != "[1:published 4:missing 2:published 3:scheduled]" || truncated {
+		t.Fatalf("all = %v truncated %v", got, truncated)
+	}
+	if got, _ := storedComments(t, fmt.Sprintf("?from=%d&to=%d", now.Add(-2*time.Second).UnixMilli(), now.UnixMilli())); fmt.Sprint(got) != "[2:published]" {
+		t.Fatalf("present = %v, want [2:published]", got)
+	}
+	if got, _ := storedComments(t, fmt.Sprintf("?to=%d", past)); fmt.Sprint(got) != "[1:published]" {
+		t.Fatalf("past = %v, want [1:published]", got)
+	}
+	if got, truncated := storedComments(t, "?limit=2"); fmt.Sprint(got) != "[1:published 4:missing]" || !truncated {
+		t.Fatalf("limit 2 = %v truncated %v, want the oldest two, truncated", got, truncated)
+	}
+
+	for _, query := range []string{"?limit=0", "?limit=1001", "?from=yesterday", "?to=soon"} {
+		expectStatus(t, request(t, http.MethodGet, "/stream/1/comments"+query, nil, asRole(roleModerator)...), 400)
+	}
+	expectStatus(t, request(t, http.MethodGet, "/stream/1/comments", nil), 401)
+	expectStatus(t, request(t, http.MethodGet, "/stream/1/comments", nil, trusted...), 403)
+}
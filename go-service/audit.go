@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// Every moderation action that changes a stream (bans, purges, clears,
+// redactions, the welcome message, featured questions, integrity repairs,
+// automatic bans and raid slow-mode) appends an entry to the stream's audit log, newest first,
+// which moderators read with GET /stream/:id/audit. Entries are never
+// changed once written. The log keeps the last AUDIT_MAX_ENTRIES entries and
+// expires AUDIT_RETENTION seconds after the last one. Dry runs aren't
+// logged.
+//
+// The actor is who the trusted caller says is acting (X-Viewer-Id and
+// X-Viewer-Role), never anything in the request body; automatic actions are
+// logged with the system as actor.
+var (
+	auditMaxEntries int64
+	auditRetention  time.Duration
+)
+
+const (
+	auditActorSystem = "system"
+	auditActorBot    = "bot" // actor ID is the bot token's ID
+	auditPageMax     = 500
+)
+
+func loadAuditConfig() {
+	auditMaxEntries = int64(envInt("AUDIT_MAX_ENTRIES", 10000))
+	auditRetention = time.Duration(envInt("AUDIT_RETENTION", 30*24*3600)) * time.Second
+}
+
+// AuditActor is who performed an action
+type AuditActor struct {
+	ID   string `json:"id,omitempty"`
+	Role string `json:"role"`
+}
+
+// AuditEntry records one moderation action
+type AuditEntry struct {
+	Action    string                 `json:"action"`
+	Actor     AuditActor             `json:"actor"`
+	Target    *ModerationTarget      `json:"target,omitempty"`
+	Reason    string                 `json:"reason,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	Timestamp int64                  `json:"timestamp"`
+}
+
+// requestActor identifies the moderator or bot behind a request
+func requestActor(c *gin.Context) AuditActor {
+	if bot := requestBot(c); bot != nil {
+		return AuditActor{ID: bot.ID, Role: auditActorBot}
+	}
+	actor := AuditActor{Role: requestRole(c)}
+	if isTrustedRequest(c) {
+		actor.ID = c.GetHeader("X-Viewer-Id")
+	}
+	return actor
+}
+
+// systemActor is the actor of automatic actions
+var systemActor = AuditActor{Role: auditActorSystem}
+
+// recordAudit appends an entry to a stream's audit log. Failing to log
+// doesn't undo the action, so errors are only reported.
+func recordAudit(ctx context.Context, streamID int64, entry AuditEntry) {
+	entry.Timestamp = time.Now().UnixMilli()
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error encoding audit entry: %v", streamID, err)
+		return
+	}
+	_, err = rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, auditKey(streamID), payload)
+		if auditMaxEntries > 0 {
+			pipe.LTrim(ctx, auditKey(streamID), 0, auditMaxEntries-1)
+		}
+		if auditRetention > 0 {
+			pipe.Expire(ctx, auditKey(streamID), auditRetention)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error recording audit entry %s: %v", streamID, entry.Action, err)
+	}
+}
+
+// auditRequest records an action performed through a request
+func auditRequest(c *gin.Context, streamID int64, action string, target *ModerationTarget, reason string, details map[string]interface{}) {
+	recordAudit(c.Request.Context(), streamID, AuditEntry{Action: action, Actor: requestActor(c), Target: target, Reason: reason, Details: details})
+	recordModeratorAction(c.Request.Context(), streamID)
+}
+
+// getAuditLog returns a stream's audit entries, newest first. ?limit= caps
+// the page (default 50), ?offset= skips entries and ?action= keeps one kind.
+func getAuditLog(c *gin.Context) {
+	streamID, ok := parseStreamID(c)
+	if !ok {
+		return
+	}
+	limit, offset := 50, 0
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > auditPageMax {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", auditPageMax)})
+			return
+		}
+		limit = n
+	}
+	if v := c.Query("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			c.JSON(400, gin.H{"error": "invalid offset"})
+			return
+		}
+		offset = n
+	}
+	action := c.Query("action")
+
+	reqCtx := c.Request.Context()
+	entries := []AuditEntry{}
+	skipped := 0
+	// Filtering by action reads the log in pages until it has enough
+	for start := int64(0); len(entries) < limit; start += auditPageMax {
+		raw, err := rdb.LRange(reqCtx, auditKey(streamID), start, start+auditPageMax-1).Result()
+		if err != nil {
+			log.Printf("[GO] Stream %d: Error loading audit log: %v", streamID, err)
+			c.JSON(500, gin.H{"error": "failed to load audit log"})
+			return
+		}
+		for _, s := range raw {
+			var entry AuditEntry
+			if json.Unmarshal([]byte(s), &entry) != nil || (action != "" && entry.Action != action) {
+				continue
+			}
+			if skipped < offset {
+				skipped++
+				continue
+			}
+			if len(entries) < limit {
+				entries = append(entries, entry)
+			}
+		}
+		if len(raw) < auditPageMax {
+			break
+		}
+	}
+	c.JSON(200, gin.H{"stream_id": streamID, "entries": entries})
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// asModerator are the headers of moderator mod-7 acting through the backend
+var asModerator = append(asRole(roleModerator), "X-Viewer-Id", "mod-7")
+
+// auditLog reads stream 1's audit log with the given query
+func auditLog(t *testing.T, query string) []map[string]interface{} {
+	t.Helper()
+	w := request(t, http.MethodGet, "/stream/1/audit"+query, nil, asRole(roleModerator)...)
+	expectStatus(t, w, 200)
+	list := decode(t, w)["entries"].([]interface{})
+	out := make([]map[string]interface{}, len(list))
+	for i, e := range list {
+		out[i] = e.(map[string]interface{})
+	}
+	return out
+}
+
+func TestModerationActionsAreAudited(t *testing.T) {
+	resetRedis(t)
+	postedID(t, 1, "v1", "alice", "spam")
+	edited := postedID(t, 1, "v3", "carol", "typo")
+	deleted := postedID(t, 1, "v3", "carol", "oops")
+
+	for _, step := range []struct {
+		path string
+		body map[string]interface{}
+	}{
+		// The actor in the body is ignored: it comes from the caller
+		{"ban", map[string]interface{}{"username": "bob", "viewer_id": "v2", "reason": "spam", "actor": map[string]interface{}{"id": "someone-else"}}},
+		{"ban", map[string]interface{}{"username": "dave", "dry_run": true}},
+		{"unban", map[string]interface{}{"username": "bob", "viewer_id": "v2"}},
+		{"purge", map[string]interface{}{"username": "alice"}},
+		{fmt.Sprintf("comments/%d/edit", edited), map[string]interface{}{"message": "fixed", "version": 0}},
+		{fmt.Sprintf("comments/%d/delete", deleted), map[string]interface{}{"version": 0, "reason": "off topic"}},
+		{"welcome", map[string]interface{}{"text": "Be kind"}},
+		{"clear", map[string]interface{}{}},
+	} {
+		expectStatus(t, request(t, http.MethodPost, "/stream/1/"+step.path, step.body, asModerator...), 200)
+	}
+
+	entries := auditLog(t, "")
+	want := []string{"clear", "welcome_updated", "delete", "edit", "purge", "unban", "ban"}
+	if len(entries) != len(want) {
+		t.Fatalf("audit log has %d entries, want %v (dry runs aren't logged)", len(entries), want)
+	}
+	for i, entry := range entries {
+		if entry["action"] != want[i] {
+			t.Fatalf("entry %d = %v, want %s", i, entry["action"], want[i])
+		}
+		actor := entry["actor"].(map[string]interface{})
+		if actor["id"] != "mod-7" || actor["role"] != roleModerator || entry["timestamp"] == nil {
+			t.Fatalf("%s entry actor = %v at %v, want mod-7 moderator", entry["action"], actor, entry["timestamp"])
+		}
+	}
+	ban := entries[len(entries)-1]
+	if target := ban["target"].(map[string]interface{}); target["username"] != "bob" || ban["reason"] != "spam" {
+		t.Fatalf("ban entry = %v, want bob for spam", ban)
+	}
+	if purge := entries[4]["details"].(map[string]interface{}); len(purge["comment_ids"].([]interface{})) != 1 {
+		t.Fatalf("purge details = %v, want alice's comment", purge)
+	}
+
+	if got := auditLog(t, "?action=ban"); len(got) != 1 || got[0]["action"] != "ban" {
+		t.Fatalf("filtered by ban = %v", got)
+	}
+	if got := auditLog(t, "?limit=2&offset=1"); len(got) != 2 || got[0]["action"] != "welcome_updated" {
+		t.Fatalf("limit 2 offset 1 = %v", got)
+	}
+}
+
+func TestAutomaticActionsAreAuditedAsSystem(t *testing.T) {
+	resetRedis(t)
+	withProfanityWords(t, tierExtreme, "slur")
+	expectStatus(t, post(t, 1, "v1", "alice", "a slur"), 403)
+
+	entries := auditLog(t, "")
+	if len(entries) != 1 || entries[0]["action"] != "ban" || entries[0]["reason"] != "profanity" {
+		t.Fatalf("audit log = %v, want the automatic ban", entries)
+	}
+	if actor := entries[0]["actor"].(map[string]interface{}); actor["role"] != auditActorSystem || actor["id"] != nil {
+		t.Fatalf("actor = %v, want the system", actor)
+	}
+}
+
+func TestAuditLogIsBounded(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &auditMaxEntries, 2)
+	for _, name := range []string{"a", "b", "c"} {
+		expectStatus(t, request(t, http.MethodPost, "/stream/1/ban", map[string]interface{}{"username": name}, asModerator...), 200)
+	}
+	entries := auditLog(t, "")
+	if len(entries) != 2 || entries[0]["target"].(map[string]interface{})["username"] != "c" {
+		t.Fatalf("audit log = %v, want the 2 newest", entries)
+	}
+	if ttl := testRedis.TTL(auditKey(1)); ttl != auditRetention {
+		t.Fatalf("audit log TTL = %v, want %v", ttl, auditRetention)
+	}
+}
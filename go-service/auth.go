@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/subtle"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// internalAPIKey authenticates trusted callers (the backend, chat bridges,
+// moderation bots). Trusted endpoints are disabled while it is unset.
+var internalAPIKey string
+
+func loadInternalAPIKey() {
+	internalAPIKey = os.Getenv("INTERNAL_API_KEY")
+}
+
+// isTrustedRequest reports whether the request carries the internal API key
+func isTrustedRequest(c *gin.Context) bool {
+	if internalAPIKey == "" {
+		return false
+	}
+	key := c.GetHeader("X-Api-Key")
+	return subtle.ConstantTimeCompare([]byte(key), []byte(internalAPIKey)) == 1
+}
+
+// Roles a trusted caller may assert for the viewer it is acting for
+const (
+	roleViewer    = "viewer"
+	roleModerator = "moderator"
+	roleStreamer  = "streamer"
+	roleAdmin     = "admin"
+)
+
+// requestRole returns the role of the viewer behind the request. Only
+// trusted callers (the backend proxying for a signed-in user) can assert a
+// role via X-Viewer-Role; everyone else is a plain viewer.
+func requestRole(c *gin.Context) string {
+	if !isTrustedRequest(c) {
+		return roleViewer
+	}
+	switch role := c.GetHeader("X-Viewer-Role"); role {
+	case roleModerator, roleStreamer, roleAdmin:
+		return role
+	}
+	return roleViewer
+}
+
+// isPrivileged reports whether a role moderates the stream
+func isPrivileged(role string) bool {
+	return role == roleModerator || role == roleStreamer || role == roleAdmin
+}
+
+// requireModerator restricts an endpoint to moderators of the stream, as
+// asserted by a trusted caller, and to bot tokens with the route's scope
+func requireModerator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if raw := botTokenFromHeader(c); raw != "" && !isTrustedRequest(c) {
+			if authorizeBot(c, raw) {
+				c.Next()
+			}
+			return
+		}
+		if !isTrustedRequest(c) {
+			c.AbortWithStatusJSON(401, gin.H{"error": "unauthorized"})
+			return
+		}
+		if !isPrivileged(requestRole(c)) {
+			c.AbortWithStatusJSON(403, gin.H{"error": "moderator access required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// requireAdmin restricts an endpoint to admins, as asserted by a trusted
+// caller. Bot tokens never qualify.
+func requireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isTrustedRequest(c) {
+			c.AbortWithStatusJSON(401, gin.H{"error": "unauthorized"})
+			return
+		}
+		if requestRole(c) != roleAdmin {
+			c.AbortWithStatusJSON(403, gin.H{"error": "admin access required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// requireInternalKey restricts an endpoint to trusted callers
+func requireInternalKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isTrustedRequest(c) {
+			c.AbortWithStatusJSON(401, gin.H{"error": "unauthorized"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// Bot tokens let third-party integrations (giveaway bots, moderation bots,
+// analytics) call moderator endpoints without the internal API key. A bot
+// sends "Authorization: Bot <token>"; each token carries scopes that decide
+// which endpoints it may call (botRouteScopes) and may be limited to some
+// streams. Endpoints without a scope stay closed to bots, and on public
+// endpoints a bot is just a viewer. The backend issues tokens with POST
+// /bot-tokens, which is the only time the token itself is returned: Redis
+// keeps its SHA-256. Tokens are checked against Redis on every request, so a
+// revoked token stops working at once.
+const (
+	scopeRead     = "read"     // stored comments, viewers, stats, audit log
+	scopeModerate = "moderate" // purges, bans, clears and the like
+)
+
+var botScopes = map[string]bool{scopeRead: true, scopeModerate: true}
+
+// botRouteScopes maps the moderator routes open to bots to the scope they need
+var botRouteScopes = map[string]string{
+	"GET /stream/:id/viewers":                      scopeRead,
+	"GET /stream/:id/comments":                     scopeRead,
+	"GET /stream/:id/integrity":                    scopeRead,
+	"GET /stream/:id/reputation":                   scopeRead,
+	"GET /stream/:id/stats":                        scopeRead,
+	"GET /stream/:id/audit":                        scopeRead,
+	"POST /stream/:id/integrity/repair":            scopeModerate,
+	"POST /stream/:id/purge":                       scopeModerate,
+	"POST /stream/:id/comments/:comment_id/edit":   scopeModerate,
+	"POST /stream/:id/comments/:comment_id/delete": scopeModerate,
+	"POST /stream/:id/profanity/rescan":            scopeModerate,
+	"POST /stream/:id/ban":                         scopeModerate,
+	"POST /stream/:id/unban":                       scopeModerate,
+	"POST /stream/:id/clear":                       scopeModerate,
+	"POST /stream/:id/welcome":                     scopeModerate,
+	"POST /stream/:id/timezone":                    scopeModerate,
+	"GET /stream/:id/emote-packs":                  scopeRead,
+	"POST /stream/:id/emote-packs/:pack":           scopeModerate,
+	"POST /stream/:id/emote-packs/:pack/delete":    scopeModerate,
+	"GET /stream/:id/geo-policy":                   scopeRead,
+	"POST /stream/:id/geo-policy":                  scopeModerate,
+}
+
+const (
+	botTokenPrefix = "mstb_"
+	botTokenBytes  = 24
+	botIDLength    = 16 // hex characters of the token hash
+)
+
+// BotToken is a token's stored metadata
+type BotToken struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	Streams   []int64  `json:"streams,omitempty"` // empty = every stream
+	CreatedAt int64    `json:"created_at"`        // ms
+
+	hash string
+}
+
+func hashBotToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (t *BotToken) hasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *BotToken) allowsStream(streamID int64) bool {
+	if len(t.Streams) == 0 {
+		return true
+	}
+	for _, id := range t.Streams {
+		if id == streamID {
+			return true
+		}
+	}
+	return false
+}
+
+// loadBotToken reads a token's metadata by ID; nil when it doesn't exist
+func loadBotToken(ctx context.Context, id string) (*BotToken, error) {
+	fields, err := rdb.HGetAll(ctx, botTokenKey(id)).Result()
+	if err != nil || len(fields) == 0 {
+		return nil, err
+	}
+	token := &BotToken{ID: id, Name: fields["name"], hash: fields["hash"]}
+	token.CreatedAt, _ = strconv.ParseInt(fields["created_at"], 10, 64)
+	if fields["scopes"] != "" {
+		token.Scopes = strings.Split(fields["scopes"], ",")
+	}
+	for _, s := range strings.Split(fields["streams"], ",") {
+		if id, err := strconv.ParseInt(s, 10, 64); err == nil {
+			token.Streams = append(token.Streams, id)
+		}
+	}
+	return token, nil
+}
+
+// botTokenFromHeader returns the raw token a request carries, "" if none
+func botTokenFromHeader(c *gin.Context) string {
+	scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bot") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// authenticateBot looks up the token a request carries. It returns nil when
+// the token is unknown or revoked.
+func authenticateBot(c *gin.Context, raw string) (*BotToken, error) {
+	if !strings.HasPrefix(raw, botTokenPrefix) {
+		return nil, nil
+	}
+	hash := hashBotToken(raw)
+	token, err := loadBotToken(c.Request.Context(), hash[:botIDLength])
+	if err != nil || token == nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(token.hash), []byte(hash)) != 1 {
+		return nil, nil
+	}
+	return token, nil
+}
+
+// requestBot returns the bot token requireModerator accepted for the
+// request, nil for other callers
+func requestBot(c *gin.Context) *BotToken {
+	if v, ok := c.Get("bot_token"); ok {
+		return v.(*BotToken)
+	}
+	return nil
+}
+
+// authorizeBot lets a bot token through to a moderator route if it has the
+// route's scope and covers the stream, aborting the request otherwise
+func authorizeBot(c *gin.Context, raw string) bool {
+	token, err := authenticateBot(c, raw)
+	if err != nil {
+		log.Printf("[GO] Error checking bot token: %v", err)
+		c.AbortWithStatusJSON(503, gin.H{"error": "authorization unavailable"})
+		return false
+	}
+	if token == nil {
+		c.AbortWithStatusJSON(401, gin.H{"error": "invalid or revoked bot token"})
+		return false
+	}
+	scope, ok := botRouteScopes[c.Request.Method+" "+c.FullPath()]
+	if !ok || !token.hasScope(scope) {
+		c.AbortWithStatusJSON(403, gin.H{"error": "bot token lacks the required scope", "reason": "insufficient_scope", "scope": scope})
+		return false
+	}
+	if streamID, err := strconv.ParseInt(c.Param("id"), 10, 64); err == nil && !token.allowsStream(streamID) {
+		c.AbortWithStatusJSON(403, gin.H{"error": "bot token is not valid for this stream", "reason": "stream_not_allowed"})
+		return false
+	}
+	c.Set("bot_token", token)
+	return true
+}
+
+type CreateBotTokenRequest struct {
+	Name    string   `json:"name" binding:"required"`
+	Scopes  []string `json:"scopes" binding:"required"`
+	Streams []int64  `json:"streams"`
+}
+
+// createBotToken issues a bot token. The response is the only place the
+// token appears.
+func createBotToken(c *gin.Context) {
+	var req CreateBotTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	scopes := map[string]bool{}
+	for _, s := range req.Scopes {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if !botScopes[s] {
+			c.JSON(400, gin.H{"error": "unknown scope: " + s})
+			return
+		}
+		scopes[s] = true
+	}
+	if len(scopes) == 0 {
+		c.JSON(400, gin.H{"error": "a token needs at least one scope"})
+		return
+	}
+
+	b := make([]byte, botTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("[GO] Error generating bot token: %v", err)
+		c.JSON(500, gin.H{"error": "failed to create token"})
+		return
+	}
+	raw := botTokenPrefix + hex.EncodeToString(b)
+	hash := hashBotToken(raw)
+	token := BotToken{ID: hash[:botIDLength], Name: strings.TrimSpace(req.Name), Streams: req.Streams, CreatedAt: time.Now().UnixMilli()}
+	for s := range scopes {
+		token.Scopes = append(token.Scopes, s)
+	}
+	sort.Strings(token.Scopes)
+	streams := make([]string, len(token.Streams))
+	for i, id := range token.Streams {
+		streams[i] = strconv.FormatInt(id, 10)
+	}
+
+	reqCtx := c.Request.Context()
+	_, err := rdb.TxPipelined(reqCtx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(reqCtx, botTokenKey(token.ID), map[string]interface{}{
+			"hash":       hash,
+			"name":       token.Name,
+			"scopes":     strings.Join(token.Scopes, ","),
+			"streams":    strings.Join(streams, ","),
+			"created_at": token.CreatedAt,
+		})
+		pipe.SAdd(reqCtx, botTokensKey(), token.ID)
+		return nil
+	})
+	if err != nil {
+		log.Printf("[GO] Error storing bot token: %v", err)
+		c.JSON(500, gin.H{"error": "failed to create token"})
+		return
+	}
+	log.Printf("[GO] Issued bot token %s (%s) with scopes %v", token.ID, token.Name, token.Scopes)
+	c.JSON(200, gin.H{"success": true, "token": raw, "bot": token})
+}
+
+// listBotTokens returns every issued token's metadata, without the tokens
+func listBotTokens(c *gin.Context) {
+	reqCtx := c.Request.Context()
+	ids, err := rdb.SMembers(reqCtx, botTokensKey()).Result()
+	if err != nil {
+		log.Printf("[GO] Error listing bot tokens: %v", err)
+		c.JSON(500, gin.H{"error": "failed to list tokens"})
+		return
+	}
+	sort.Strings(ids)
+	tokens := []BotToken{}
+	for _, id := range ids {
+		token, err := loadBotToken(reqCtx, id)
+		if err != nil {
+			log.Printf("[GO] Error loading bot token %s: %v", id, err)
+			continue
+		}
+		if token != nil {
+			tokens = append(tokens, *token)
+		}
+	}
+	c.JSON(200, gin.H{"tokens": tokens})
+}
+
+// revokeBotToken deletes a token, which takes effect on its next request
+func revokeBotToken(c *gin.Context) {
+	id := c.Param("token_id")
+	reqCtx := c.Request.Context()
+	var deleted *redis.IntCmd
+	_, err := rdb.TxPipelined(reqCtx, func(pipe redis.Pipeliner) error {
+		deleted = pipe.Del(reqCtx, botTokenKey(id))
+		pipe.SRem(reqCtx, botTokensKey(), id)
+		return nil
+	})
+	if err != nil {
+		log.Printf("[GO] Error revoking bot token %s: %v", id, err)
+		c.JSON(500, gin.H{"error": "failed to revoke token"})
+		return
+	}
+	if deleted.Val() == 0 {
+		c.JSON(404, gin.H{"error": "token not found"})
+		return
+	}
+	log.Printf("[GO] Revoked bot token %s", id)
+	c.JSON(200, gin.H{"success": true, "revoked": id})
+}
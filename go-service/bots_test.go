@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+// issueBotToken creates a bot token and returns it with its ID
+func issueBotToken(t *testing.T, scopes []string, streams []int64) (string, string) {
+	t.Helper()
+	w := request(t, http.MethodPost, "/bot-tokens", map[string]interface{}{"name": "giveaway", "scopes": scopes, "streams": streams}, trusted...)
+	expectStatus(t, w, 200)
+	resp := decode(t, w)
+	return resp["token"].(string), resp["bot"].(map[string]interface{})["id"].(string)
+}
+
+// asBot returns the headers of a request with a bot token
+func asBot(token string) []string {
+	return []string{"Authorization", "Bot " + token}
+}
+
+func TestBotTokenScopes(t *testing.T) {
+	resetRedis(t)
+	reader, _ := issueBotToken(t, []string{"read"}, []int64{1})
+	moderator, _ := issueBotToken(t, []string{"read", "moderate"}, nil)
+
+	expectStatus(t, request(t, http.MethodGet, "/stream/1/viewers", nil, asBot(reader)...), 200)
+	w := request(t, http.MethodPost, "/stream/1/clear", nil, asBot(reader)...)
+	if resp := decode(t, w); w.Code != 403 || resp["reason"] != "insufficient_scope" {
+		t.Fatalf("clear with a read token: %d %v, want 403 insufficient_scope", w.Code, resp)
+	}
+	w = request(t, http.MethodGet, "/stream/2/viewers", nil, asBot(reader)...)
+	if resp := decode(t, w); w.Code != 403 || resp["reason"] != "stream_not_allowed" {
+		t.Fatalf("another stream: %d %v, want 403 stream_not_allowed", w.Code, resp)
+	}
+
+	expectStatus(t, request(t, http.MethodPost, "/stream/2/clear", nil, asBot(moderator)...), 200)
+	// Control endpoints stay closed to every bot
+	expectStatus(t, request(t, http.MethodPost, "/bot-tokens", map[string]interface{}{"name": "x", "scopes": []string{"read"}}, asBot(moderator)...), 401)
+	expectStatus(t, request(t, http.MethodGet, "/stream/1/viewers", nil, asBot("mstb_made-up")...), 401)
+}
+
+func TestBotTokenRevocation(t *testing.T) {
+	resetRedis(t)
+	token, id := issueBotToken(t, []string{"read"}, nil)
+	expectStatus(t, request(t, http.MethodGet, "/stream/1/viewers", nil, asBot(token)...), 200)
+
+	expectStatus(t, request(t, http.MethodPost, "/bot-tokens/"+id+"/revoke", nil, trusted...), 200)
+	expectStatus(t, request(t, http.MethodGet, "/stream/1/viewers", nil, asBot(token)...), 401)
+	expectStatus(t, request(t, http.MethodPost, "/bot-tokens/"+id+"/revoke", nil, trusted...), 404)
+
+	w := request(t, http.MethodGet, "/bot-tokens", nil, trusted...)
+	expectStatus(t, w, 200)
+	if list, _ := decode(t, w)["tokens"].([]interface{}); len(list) != 0 {
+		t.Fatalf("tokens after revocation = %v, want none", list)
+	}
+}
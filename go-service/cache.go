@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Responses default to Cache-Control: no-store, so no proxy or CDN keeps
+// per-viewer data such as check-update and heartbeat answers. Endpoints that
+// serve the same thing to every viewer opt into brief public caching with
+// cacheFor: the stream cursor (CACHE_CURSOR_TTL), emotes (CACHE_EMOTES_TTL),
+// top comments (CACHE_TOP_COMMENTS_TTL) and replay timelines
+// (CACHE_REPLAY_TTL), in seconds, 0 keeping them uncached. Only successes
+// are cacheable, and never for trusted callers, whose role and tier headers
+// change the answer.
+var (
+	cursorCacheTTL      time.Duration
+	emotesCacheTTL      time.Duration
+	topCommentsCacheTTL time.Duration
+	replayHTTPCacheTTL  time.Duration
+)
+
+const noStore = "no-store"
+
+func loadCacheConfig() {
+	cursorCacheTTL = time.Duration(envInt("CACHE_CURSOR_TTL", 1)) * time.Second
+	emotesCacheTTL = time.Duration(envInt("CACHE_EMOTES_TTL", 30)) * time.Second
+	topCommentsCacheTTL = time.Duration(envInt("CACHE_TOP_COMMENTS_TTL", 5)) * time.Second
+	replayHTTPCacheTTL = time.Duration(envInt("CACHE_REPLAY_TTL", 60)) * time.Second
+}
+
+// cacheMiddleware marks every response uncacheable unless its route says
+// otherwise
+func cacheMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", noStore)
+		c.Next()
+	}
+}
+
+// cacheWriter falls back to no-store when the response turns out not to be
+// a success, deciding just before the headers go out
+type cacheWriter struct {
+	gin.ResponseWriter
+	decided bool
+}
+
+func (w *cacheWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	if w.Status() != 200 {
+		w.Header().Set("Cache-Control", noStore)
+	}
+}
+
+func (w *cacheWriter) WriteHeaderNow() {
+	w.decide()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *cacheWriter) Write(b []byte) (int, error) {
+	w.decide()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *cacheWriter) WriteString(s string) (int, error) {
+	w.decide()
+	return w.ResponseWriter.WriteString(s)
+}
+
+// cacheFor lets shared caches keep a route's successful responses for ttl
+func cacheFor(ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ttl <= 0 || isTrustedRequest(c) {
+			c.Next()
+			return
+		}
+		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(ttl/time.Second)))
+		c.Writer = &cacheWriter{ResponseWriter: c.Writer}
+		c.Next()
+	}
+}
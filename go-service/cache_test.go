@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCacheHeadersPerEndpoint(t *testing.T) {
+	resetRedis(t)
+	for _, tc := range []struct {
+		method, path string
+		body         interface{}
+		want         string
+	}{
+		{http.MethodPost, "/check-update", map[string]interface{}{"stream_id": 1, "viewer_id": "v1", "last_id": 0}, noStore},
+		{http.MethodPost, "/heartbeat", map[string]interface{}{"stream_id": 1, "viewer_id": "v1"}, noStore},
+		{http.MethodGet, "/health", nil, noStore},
+		{http.MethodGet, "/stream/1/cursor", nil, "public, max-age=1"},
+		{http.MethodGet, "/stream/1/emotes", nil, "public, max-age=30"},
+		{http.MethodGet, "/stream/1/reactions", nil, "public, max-age=30"},
+		{http.MethodGet, "/stream/1/top-comments", nil, "public, max-age=5"},
+	} {
+		w := request(t, tc.method, tc.path, tc.body)
+		expectStatus(t, w, 200)
+		if got := w.Header().Get("Cache-Control"); got != tc.want {
+			t.Errorf("%s %s: Cache-Control %q, want %q", tc.method, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestCacheHeadersOnlyForPublicSuccesses(t *testing.T) {
+	resetRedis(t)
+	// The replay timeline 404s before a broadcast
+	w := request(t, http.MethodGet, "/stream/1/replay/timeline", nil)
+	expectStatus(t, w, 404)
+	if got := w.Header().Get("Cache-Control"); got != noStore {
+		t.Fatalf("error response: Cache-Control %q, want no-store", got)
+	}
+	w = request(t, http.MethodGet, "/stream/x/cursor", nil)
+	if got := w.Header().Get("Cache-Control"); w.Code == 200 || got != noStore {
+		t.Fatalf("bad stream ID: %d Cache-Control %q, want no-store", w.Code, got)
+	}
+	// Trusted callers' answers depend on their role and tier headers
+	w = request(t, http.MethodGet, "/stream/1/cursor", nil, asRole(roleModerator)...)
+	if got := w.Header().Get("Cache-Control"); got != noStore {
+		t.Fatalf("trusted caller: Cache-Control %q, want no-store", got)
+	}
+}
+
+func TestCacheTTLsAreConfigurable(t *testing.T) {
+	setVar(t, &cursorCacheTTL, 10*time.Second)
+	setVar(t, &topCommentsCacheTTL, 0)
+	r := newRouter()
+
+	get := func(path string) string {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Header().Get("Cache-Control")
+	}
+	if got := get("/stream/1/cursor"); got != "public, max-age=10" {
+		t.Fatalf("cursor: Cache-Control %q, want max-age=10", got)
+	}
+	if got := get("/stream/1/top-comments"); got != noStore {
+		t.Fatalf("top comments with a TTL of 0: Cache-Control %q, want no-store", got)
+	}
+}
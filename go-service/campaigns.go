@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// Spam campaigns post one payload to many streams, staying under every
+// stream's own limits. Messages are fingerprinted by their letters and
+// digits (case, spacing, punctuation and emoji don't tell copies apart) into
+// a service-wide index, and once a fingerprint has been posted in
+// CAMPAIGN_STREAM_THRESHOLD different streams within CAMPAIGN_WINDOW it is
+// refused in every stream for CAMPAIGN_COOLDOWN. Messages shorter than
+// CAMPAIGN_MIN_LENGTH characters after normalization ("gg", "lol") and the
+// phrases in CAMPAIGN_ALLOWLIST are never tracked. Moderators and bridged
+// integrations are exempt. 0 turns the check off.
+//
+// GET /spam-campaigns lists the blocked fingerprints and POST
+// /spam-campaigns/:fingerprint/clear lifts a block early.
+var (
+	campaignStreamThreshold int
+	campaignWindow          time.Duration
+	campaignCooldown        time.Duration
+	campaignMinLength       int
+	campaignAllowlist       map[string]bool // fingerprints
+)
+
+// campaignMaxBlocks bounds how many blocked fingerprints GET /spam-campaigns reads
+const campaignMaxBlocks = 1000
+
+func loadCampaignConfig() {
+	campaignStreamThreshold = envInt("CAMPAIGN_STREAM_THRESHOLD", 0)
+	campaignWindow = time.Duration(envInt("CAMPAIGN_WINDOW", 300)) * time.Second
+	campaignCooldown = time.Duration(envInt("CAMPAIGN_COOLDOWN", 900)) * time.Second
+	campaignMinLength = envInt("CAMPAIGN_MIN_LENGTH", 12)
+	campaignAllowlist = map[string]bool{}
+	for _, phrase := range strings.Split(envString("CAMPAIGN_ALLOWLIST", ""), ",") {
+		if normalized := campaignContent(phrase); normalized != "" {
+			campaignAllowlist[fingerprintOf(normalized)] = true
+		}
+	}
+}
+
+// campaignContent reduces a message to the letters and digits that make it
+// the same payload
+func campaignContent(message string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(message) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func fingerprintOf(normalized string) string {
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:8])
+}
+
+// messageFingerprint returns a message's campaign fingerprint, "" when the
+// message isn't tracked
+func messageFingerprint(message string) string {
+	if campaignStreamThreshold <= 0 {
+		return ""
+	}
+	normalized := campaignContent(message)
+	if len([]rune(normalized)) < campaignMinLength {
+		return ""
+	}
+	fingerprint := fingerprintOf(normalized)
+	if campaignAllowlist[fingerprint] {
+		return ""
+	}
+	return fingerprint
+}
+
+// campaignRetryAfter returns the seconds left on a fingerprint's block, 0
+// when it isn't blocked
+func campaignRetryAfter(ctx context.Context, streamID int64, fingerprint string) int {
+	if fingerprint == "" {
+		return 0
+	}
+	ttl, err := rdb.PTTL(ctx, campaignBlockKey(fingerprint)).Result()
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error checking spam campaigns: %v", streamID, err)
+		return 0
+	}
+	if ttl <= 0 {
+		return 0
+	}
+	return int((ttl + time.Second - 1) / time.Second)
+}
+
+// recordCampaign counts a published comment's fingerprint towards its
+// stream and blocks it everywhere once it reached the threshold
+func recordCampaign(ctx context.Context, streamID int64, fingerprint, message string) {
+	if fingerprint == "" {
+		return
+	}
+	k := campaignStreamsKey(fingerprint)
+	var streams *redis.IntCmd
+	_, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SAdd(ctx, k, streamID)
+		pipe.Expire(ctx, k, campaignWindow)
+		streams = pipe.SCard(ctx, k)
+		return nil
+	})
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error tracking message fingerprint: %v", streamID, err)
+		return
+	}
+	if streams.Val() < int64(campaignStreamThreshold) {
+		return
+	}
+	blocked, err := rdb.SetNX(ctx, campaignBlockKey(fingerprint), truncateRunes(message, 200), campaignCooldown).Result()
+	if err != nil || !blocked {
+		return
+	}
+	rdb.Del(ctx, k)
+	log.Printf("[GO] Stream %d: Message %s posted in %d streams, blocked everywhere for %s", streamID, fingerprint, streams.Val(), campaignCooldown)
+	publishModEvent(ctx, streamID, map[string]interface{}{"type": "spam_campaign_blocked", "fingerprint": fingerprint, "streams": streams.Val(), "until": time.Now().Add(campaignCooldown).UnixMilli()})
+}
+
+// truncateRunes cuts s to at most n runes
+func truncateRunes(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
+
+// getSpamCampaigns lists the blocked fingerprints with a sample message and
+// the seconds left on each block
+func getSpamCampaigns(c *gin.Context) {
+	reqCtx := c.Request.Context()
+	keys, err := scanKeys(reqCtx, campaignBlockKey("*"), campaignMaxBlocks)
+	if err != nil {
+		log.Printf("[GO] Error listing spam campaigns: %v", err)
+		c.JSON(500, gin.H{"error": "failed to list spam campaigns"})
+		return
+	}
+	var samples []*redis.StringCmd
+	var ttls []*redis.DurationCmd
+	_, err = rdb.Pipelined(reqCtx, func(pipe redis.Pipeliner) error {
+		for _, k := range keys {
+			samples = append(samples, pipe.Get(reqCtx, k))
+			ttls = append(ttls, pipe.PTTL(reqCtx, k))
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		log.Printf("[GO] Error loading spam campaigns: %v", err)
+		c.JSON(500, gin.H{"error": "failed to list spam campaigns"})
+		return
+	}
+	campaigns := make([]gin.H, 0, len(keys))
+	for i, k := range keys {
+		if samples[i].Err() != nil || ttls[i].Val() <= 0 {
+			continue // expired since the scan
+		}
+		campaigns = append(campaigns, gin.H{
+			"fingerprint": k[strings.LastIndexByte(k, ':')+1:],
+			"sample":      samples[i].Val(),
+			"retry_after": int((ttls[i].Val() + time.Second - 1) / time.Second),
+		})
+	}
+	c.JSON(200, gin.H{"enabled": campaignStreamThreshold > 0, "threshold": campaignStreamThreshold, "campaigns": campaigns})
+}
+
+// clearSpamCampaign lifts a fingerprint's block and forgets the streams it
+// was seen in
+func clearSpamCampaign(c *gin.Context) {
+	fingerprint := c.Param("fingerprint")
+	if _, err := hex.DecodeString(fingerprint); err != nil || len(fingerprint) != 16 {
+		c.JSON(400, gin.H{"error": "invalid fingerprint"})
+		return
+	}
+	cleared, err := rdb.Del(c.Request.Context(), campaignBlockKey(fingerprint), campaignStreamsKey(fingerprint)).Result()
+	if err != nil {
+		log.Printf("[GO] Error clearing spam campaign %s: %v", fingerprint, err)
+		c.JSON(500, gin.H{"error": "failed to clear spam campaign"})
+		return
+	}
+	log.Printf("[GO] Cleared spam campaign %s", fingerprint)
+	c.JSON(200, gin.H{"success": true, "cleared": cleared > 0, "fingerprint": fingerprint})
+}
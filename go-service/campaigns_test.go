@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// withCampaigns blocks a fingerprint once it's posted in threshold streams
+func withCampaigns(t *testing.T, threshold int, allowlist ...string) {
+	t.Helper()
+	setVar(t, &campaignStreamThreshold, threshold)
+	setVar(t, &campaignWindow, time.Minute)
+	setVar(t, &campaignCooldown, 10*time.Minute)
+	setVar(t, &campaignMinLength, 12)
+	allowed := map[string]bool{}
+	for _, phrase := range allowlist {
+		allowed[fingerprintOf(campaignContent(phrase))] = true
+	}
+	setVar(t, &campaignAllowlist, allowed)
+}
+
+// postAcross posts message to streams first..last, each from its own viewer
+func postAcross(t *testing.T, first, last int64, message string) {
+	t.Helper()
+	for id := first; id <= last; id++ {
+		if w := post(t, id, fmt.Sprintf("spammer%d", id), "spammer", message); w.Code != 200 {
+			t.Fatalf("stream %d: %d %s, want the post through", id, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestMessageFingerprint(t *testing.T) {
+	withCampaigns(t, 3, "Thanks for the stream!")
+
+	base := messageFingerprint("Buy followers at spam dot example")
+	if base == "" {
+		t.Fatal("long message not fingerprinted")
+	}
+	if got := messageFingerprint("BUY followers!! at spam.dot.example 🔥"); got != base {
+		t.Fatalf("case, punctuation and emoji changed the fingerprint: %q != %q", got, base)
+	}
+	for _, msg := range []string{"gg", "lol lol lol", "thanks for the STREAM"} {
+		if got := messageFingerprint(msg); got != "" {
+			t.Fatalf("%q fingerprinted as %q, want it untracked", msg, got)
+		}
+	}
+	setVar(t, &campaignStreamThreshold, 0)
+	if got := messageFingerprint("Buy followers at spam dot example"); got != "" {
+		t.Fatalf("fingerprint %q with the check off", got)
+	}
+}
+
+func TestCampaignBlockedAcrossStreams(t *testing.T) {
+	resetRedis(t)
+	withCampaigns(t, 3)
+	const payload = "Buy followers at spam dot example"
+
+	postAcross(t, 1, 3, payload)
+
+	// The third stream tipped it over: a variant is now refused everywhere,
+	// including the streams it was first seen in
+	for _, id := range []int64{1, 4} {
+		w := post(t, id, "fresh", "newcomer", "buy FOLLOWERS at spam-dot-example!!")
+		if w.Code != 429 || decode(t, w)["reason"] != "spam_campaign" {
+			t.Fatalf("stream %d: %d %s, want 429 spam_campaign", id, w.Code, w.Body.String())
+		}
+		if w.Header().Get("Retry-After") == "" {
+			t.Fatalf("stream %d: no Retry-After", id)
+		}
+	}
+	// Moderators are exempt
+	expectStatus(t, post(t, 4, "mod-7", "mod", payload, asRole(roleModerator)...), 200)
+
+	w := request(t, http.MethodGet, "/spam-campaigns", nil, trusted...)
+	expectStatus(t, w, 200)
+	campaigns := decode(t, w)["campaigns"].([]interface{})
+	if len(campaigns) != 1 {
+		t.Fatalf("campaigns = %v, want one block", campaigns)
+	}
+	block := campaigns[0].(map[string]interface{})
+	if block["fingerprint"] != messageFingerprint(payload) || block["sample"] != payload {
+		t.Fatalf("block = %v", block)
+	}
+
+	expectStatus(t, request(t, http.MethodPost, "/spam-campaigns/"+messageFingerprint(payload)+"/clear", nil, trusted...), 200)
+	expectStatus(t, post(t, 5, "fresh", "newcomer", payload), 200)
+}
+
+func TestCampaignBelowThreshold(t *testing.T) {
+	resetRedis(t)
+	withCampaigns(t, 3, "Welcome to the stream everyone")
+
+	// Two streams aren't a campaign, and repeats within one stream count once
+	postAcross(t, 1, 2, "Buy followers at spam dot example")
+	postAcross(t, 1, 2, "Buy followers at spam dot example")
+	expectStatus(t, post(t, 1, "fresh", "newcomer", "Buy followers at spam dot example"), 200)
+
+	// Short and allowlisted messages are never tracked
+	postAcross(t, 1, 4, "gg")
+	postAcross(t, 1, 4, "Welcome to the stream, everyone!")
+	expectStatus(t, post(t, 5, "fresh", "newcomer", "welcome to the stream everyone"), 200)
+
+	// The window lapses before the third stream sees it
+	testRedis.FastForward(campaignWindow)
+	expectStatus(t, post(t, 3, "fresh", "newcomer", "Buy followers at spam dot example"), 200)
+	expectStatus(t, post(t, 4, "other", "newcomer", "Buy followers at spam dot example"), 200)
+}
+
+func TestClearSpamCampaignValidation(t *testing.T) {
+	resetRedis(t)
+	expectStatus(t, request(t, http.MethodPost, "/spam-campaigns/nothex/clear", nil, trusted...), 400)
+	expectStatus(t, request(t, http.MethodPost, "/spam-campaigns/0123456789abcdef/clear", nil), 401)
+	w := request(t, http.MethodPost, "/spam-campaigns/0123456789abcdef/clear", nil, trusted...)
+	expectStatus(t, w, 200)
+	if decode(t, w)["cleared"] != false {
+		t.Fatalf("clearing an unknown fingerprint: %s", w.Body.String())
+	}
+}
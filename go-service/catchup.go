@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// A viewer coming back from a network drop with an old last_id can have
+// hundreds of comments waiting. Polls that send catch_up get a summary
+// instead once CATCHUP_THRESHOLD or more have accumulated (0 = never): the
+// newest CATCHUP_RECENT comments, catch_up with how many were missed, and
+// truncated with a before to page the older ones on demand, exactly like a
+// truncated poll. Nothing is discarded; the full history stays available
+// through paging and the history endpoints.
+var (
+	catchUpThreshold int
+	catchUpRecent    int
+)
+
+func loadCatchUpConfig() {
+	catchUpThreshold = envInt("CATCHUP_THRESHOLD", 100)
+	catchUpRecent = envInt("CATCHUP_RECENT", 20)
+	if catchUpRecent < 1 {
+		catchUpRecent = 20
+	}
+	if catchUpThreshold > 0 && catchUpThreshold <= catchUpRecent {
+		catchUpThreshold = catchUpRecent + 1
+	}
+}
+
+// CatchUpSummary tells a reconnecting client what it missed
+type CatchUpSummary struct {
+	Missed int64 `json:"missed"` // comments since last_id, including the ones returned
+	Shown  int   `json:"shown"`
+	Since  int64 `json:"since"` // the last_id the client came back with
+}
+
+// catchUpPoll summarizes a poll over [min, max] that read comments once the
+// gap is big enough. The count includes comments a truncated read left out.
+// It returns the comments to send, the before and before ID to page the
+// rest and the summary, nil when the gap is small.
+func catchUpPoll(ctx context.Context, streamID int64, comments []Comment, truncated bool, since, min, max int64) ([]Comment, int64, int64, *CatchUpSummary) {
+	if catchUpThreshold <= 0 || len(comments) <= catchUpRecent {
+		return comments, 0, 0, nil
+	}
+	missed := int64(len(comments))
+	if truncated {
+		n, err := rdb.ZCount(ctx, commentIndexKey(streamID), strconv.FormatInt(min, 10), strconv.FormatInt(max, 10)).Result()
+		if err != nil && err != redis.Nil {
+			log.Printf("[GO] Stream %d: Error counting missed comments: %v", streamID, err)
+		} else if n > missed {
+			missed = n
+		}
+	}
+	if missed < int64(catchUpThreshold) {
+		return comments, 0, 0, nil
+	}
+	comments, before, beforeID := truncatePoll(comments, catchUpRecent)
+	return comments, before, beforeID, &CatchUpSummary{Missed: missed, Shown: len(comments), Since: since}
+}
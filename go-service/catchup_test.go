@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// missedComments saves n comments a millisecond apart from ts, m1 to m<n>
+func missedComments(t *testing.T, ts int64, n int) {
+	t.Helper()
+	for i := 1; i <= n; i++ {
+		saveAt(t, ts+int64(i), int64(i))
+	}
+}
+
+// pollCatchUp runs check-update with body and returns the decoded response
+func pollCatchUp(t *testing.T, body map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	w := request(t, http.MethodPost, "/check-update", body)
+	expectStatus(t, w, 200)
+	return decode(t, w)
+}
+
+func TestCatchUpSmallGap(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &catchUpThreshold, 10)
+	setVar(t, &catchUpRecent, 3)
+	ts := time.Now().Add(-time.Minute).UnixMilli()
+	missedComments(t, ts, 9)
+
+	resp := pollCatchUp(t, map[string]interface{}{"stream_id": 1, "viewer_id": "v1", "last_id": ts, "catch_up": true})
+	if got := messages(resp); len(got) != 9 || resp["catch_up"] != nil || resp["truncated"] != nil {
+		t.Fatalf("small gap = %v catch_up %v, want all 9 comments", got, resp["catch_up"])
+	}
+}
+
+func TestCatchUpLargeGap(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &catchUpThreshold, 10)
+	setVar(t, &catchUpRecent, 3)
+	ts := time.Now().Add(-time.Minute).UnixMilli()
+	missedComments(t, ts, 12)
+
+	body := map[string]interface{}{"stream_id": 1, "viewer_id": "v1", "last_id": ts, "catch_up": true}
+	resp := pollCatchUp(t, body)
+	if got := strings.Join(messages(resp), " "); got != "m10 m11 m12" {
+		t.Fatalf("large gap = %q, want the newest 3", got)
+	}
+	summary, _ := resp["catch_up"].(map[string]interface{})
+	if summary["missed"] != float64(12) || summary["shown"] != float64(3) || summary["since"] != float64(ts) {
+		t.Fatalf("catch_up = %v, want 12 missed, 3 shown since %d", summary, ts)
+	}
+	if resp["truncated"] != true {
+		t.Fatal("a summarized poll isn't truncated")
+	}
+
+	// The older comments are still there on demand
+	var all []string
+	for pages := 0; resp["truncated"] == true; pages++ {
+		if pages > 5 {
+			t.Fatalf("still truncated after %d pages", pages)
+		}
+		all = append(messages(resp), all...)
+		body["before"], body["before_id"] = resp["before"], resp["before_id"]
+		resp = pollCatchUp(t, body)
+	}
+	all = append(messages(resp), all...)
+	want := make([]string, 12)
+	for i := range want {
+		want[i] = fmt.Sprintf("m%d", i+1)
+	}
+	if got := strings.Join(all, " "); got != strings.Join(want, " ") {
+		t.Fatalf("paged = %q, want every missed comment", got)
+	}
+
+	// Clients that don't ask get the plain poll
+	if got := messages(pollCatchUp(t, map[string]interface{}{"stream_id": 1, "viewer_id": "v1", "last_id": ts})); len(got) != 12 {
+		t.Fatalf("without catch_up = %d comments, want 12", len(got))
+	}
+}
+
+func TestCatchUpCountsTruncatedComments(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &catchUpThreshold, 10)
+	setVar(t, &catchUpRecent, 3)
+	setVar(t, &pollMaxComments, 5)
+	ts := time.Now().Add(-time.Minute).UnixMilli()
+	missedComments(t, ts, 12)
+
+	resp := pollCatchUp(t, map[string]interface{}{"stream_id": 1, "viewer_id": "v1", "last_id": ts, "catch_up": true})
+	if summary, _ := resp["catch_up"].(map[string]interface{}); summary["missed"] != float64(12) {
+		t.Fatalf("catch_up = %v, want all 12 missed counted past the poll cap", resp["catch_up"])
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// While the backend has a stream's allow_comments flag off, check-update
+// serves no comments, so anything posted meanwhile would pop up with stale
+// timestamps once chat reopens. CHAT_DISABLED_POLICY decides what happens
+// to those comments instead:
+//
+//   - reject (default): posts are refused with 403 chat_disabled.
+//   - buffer: posts are accepted and held, up to CHAT_DISABLED_BUFFER_MAX
+//     per stream, then revealed in order when chat reopens, stamped with the
+//     time they were revealed. A background job checks streams holding
+//     comments every CHAT_BUFFER_REVEAL_INTERVAL seconds.
+//
+// System messages aren't affected.
+const (
+	chatDisabledReject = "reject"
+	chatDisabledBuffer = "buffer"
+)
+
+var (
+	chatDisabledPolicy    string
+	chatDisabledBufferMax int64
+)
+
+func loadClosedChatConfig() {
+	chatDisabledPolicy = envString("CHAT_DISABLED_POLICY", chatDisabledReject)
+	if chatDisabledPolicy != chatDisabledBuffer {
+		chatDisabledPolicy = chatDisabledReject
+	}
+	chatDisabledBufferMax = int64(envInt("CHAT_DISABLED_BUFFER_MAX", 1000))
+}
+
+// chatAllowed reads a stream's allow_comments flag, which defaults to on.
+// Errors count as allowed, as the feed read treats them.
+func chatAllowed(ctx context.Context, streamID int64) bool {
+	allowed, _ := store.AllowComments(ctx, streamID)
+	return allowed
+}
+
+// bufferedComment is a held comment with the author it is recorded against
+type bufferedComment struct {
+	ViewerID string  `json:"viewer_id,omitempty"`
+	Comment  Comment `json:"comment"`
+}
+
+// bufferComment stamps cmt and holds it until chat reopens. It returns false
+// when the stream's buffer is full.
+func bufferComment(ctx context.Context, streamID int64, viewerID string, cmt *Comment, lifetime time.Duration) (bool, error) {
+	if n, err := rdb.LLen(ctx, chatBufferKey(streamID)).Result(); err != nil {
+		return false, err
+	} else if n >= chatDisabledBufferMax {
+		return false, nil
+	}
+	if err := stampComment(ctx, streamID, cmt, lifetime); err != nil {
+		return false, err
+	}
+	payload, err := json.Marshal(bufferedComment{ViewerID: viewerID, Comment: *cmt})
+	if err != nil {
+		return false, fmt.Errorf("encode comment: %w", err)
+	}
+	_, err = rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.RPush(ctx, chatBufferKey(streamID), payload)
+		pipe.SAdd(ctx, bufferedStreamsKey(), streamID)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	cmt.buffered = true
+	log.Printf("[GO] Stream %d: Buffered comment %d while chat is disabled", streamID, cmt.ID)
+	return true, nil
+}
+
+// revealBuffered publishes a stream's held comments, one millisecond apart
+// from now on so they keep their order and land past every cursor
+func revealBuffered(ctx context.Context, streamID int64) (int, error) {
+	var held *redis.StringSliceCmd
+	_, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		held = pipe.LRange(ctx, chatBufferKey(streamID), 0, -1)
+		pipe.Del(ctx, chatBufferKey(streamID))
+		pipe.SRem(ctx, bufferedStreamsKey(), streamID)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now().UnixMilli()
+	revealed := 0
+	for i, raw := range held.Val() {
+		var entry bufferedComment
+		if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+			log.Printf("[GO] Stream %d: Dropping undecodable buffered comment: %v", streamID, err)
+			continue
+		}
+		cmt := entry.Comment
+		if cmt.ExpiresAt > 0 {
+			cmt.ExpiresAt += now + int64(i) - cmt.Timestamp
+		}
+		cmt.Timestamp = now + int64(i)
+		if err := storeComment(ctx, streamID, entry.ViewerID, &cmt); err != nil {
+			return revealed, err
+		}
+		revealed++
+	}
+	return revealed, nil
+}
+
+// runBufferReveal reveals held comments once their stream's chat reopens
+func runBufferReveal(ctx context.Context, interval time.Duration) {
+	if chatDisabledPolicy != chatDisabledBuffer || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	jobs.setRunning("buffer_reveal", true)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			jobs.ran("buffer_reveal", revealReopened(ctx))
+		}
+	}
+}
+
+// revealReopened reveals the held comments of every stream whose chat is on
+func revealReopened(ctx context.Context) error {
+	members, err := rdb.SMembers(ctx, bufferedStreamsKey()).Result()
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		streamID, convErr := strconv.ParseInt(member, 10, 64)
+		if convErr != nil || !chatAllowed(ctx, streamID) {
+			continue
+		}
+		n, err := revealBuffered(ctx, streamID)
+		if err != nil {
+			log.Printf("[GO] Stream %d: Error revealing buffered comments: %v", streamID, err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("[GO] Stream %d: Chat reopened, revealed %d buffered comments", streamID, n)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClosedChatRejectPolicy(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &chatDisabledPolicy, chatDisabledReject)
+	rdb.Set(ctx, allowCommentsKey(1), "0", 0)
+
+	w := post(t, 1, "v1", "alice", "anyone here?")
+	if w.Code != 403 || decode(t, w)["reason"] != "chat_disabled" {
+		t.Fatalf("post while disabled: %d %s, want 403 chat_disabled", w.Code, w.Body.String())
+	}
+	rdb.Set(ctx, allowCommentsKey(1), "1", 0)
+	expectStatus(t, post(t, 1, "v1", "alice", "back"), 200)
+	nextSecond()
+	if got := strings.Join(messages(poll(t, 1, "v2", 0)), " "); got != "back" {
+		t.Fatalf("feed after reopening = %q, want only the later post", got)
+	}
+}
+
+func TestClosedChatBufferPolicy(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &chatDisabledPolicy, chatDisabledBuffer)
+	rdb.Set(ctx, allowCommentsKey(1), "0", 0)
+
+	for _, msg := range []string{"first", "second"} {
+		w := post(t, 1, "v1", "alice", msg)
+		expectStatus(t, w, 200)
+		if resp := decode(t, w); resp["status"] != postPending || resp["buffered"] != true {
+			t.Fatalf("buffered post = %v, want pending", resp)
+		}
+	}
+	// Nothing is revealed while chat stays off
+	if err := revealReopened(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := messages(poll(t, 1, "v2", 0)); len(got) != 0 {
+		t.Fatalf("feed while disabled = %v, want nothing", got)
+	}
+
+	rdb.Set(ctx, allowCommentsKey(1), "1", 0)
+	reopened := time.Now().UnixMilli()
+	if err := revealReopened(ctx); err != nil {
+		t.Fatal(err)
+	}
+	nextSecond()
+	resp := poll(t, 1, "v2", 0)
+	if got := strings.Join(messages(resp), " "); got != "first second" {
+		t.Fatalf("feed after reopening = %q, want the held comments in order", got)
+	}
+	for _, c := range resp["comments"].([]interface{}) {
+		if ts := c.(map[string]interface{})["timestamp"].(float64); int64(ts) < reopened {
+			t.Fatalf("revealed comment stamped %v, before chat reopened at %d", ts, reopened)
+		}
+	}
+	if n, _ := rdb.LLen(ctx, chatBufferKey(1)).Result(); n != 0 {
+		t.Fatalf("%d comments still held", n)
+	}
+}
+
+func TestClosedChatBufferIsBounded(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &chatDisabledPolicy, chatDisabledBuffer)
+	setVar(t, &chatDisabledBufferMax, 1)
+	rdb.Set(ctx, allowCommentsKey(1), "0", 0)
+
+	expectStatus(t, post(t, 1, "v1", "alice", "held"), 200)
+	if w := post(t, 1, "v2", "bob", "no room"); w.Code != 403 || decode(t, w)["reason"] != "chat_disabled" {
+		t.Fatalf("post with a full buffer: %d %s, want 403 chat_disabled", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+var hexColorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// Name color policy: colors must keep at least nameColorMinContrast (WCAG
+// contrast ratio) against every chat background so names stay readable in
+// both light and dark themes.
+var (
+	nameColorsEnabled    bool
+	nameColorMinContrast float64
+	nameColorBackgrounds []string
+)
+
+func loadNameColorPolicy() {
+	nameColorsEnabled = envBool("NAME_COLORS_ENABLED", true)
+	nameColorMinContrast = envFloat("NAME_COLOR_MIN_CONTRAST", 1.5)
+	nameColorBackgrounds = nil
+	backgrounds := os.Getenv("NAME_COLOR_BACKGROUNDS")
+	if backgrounds == "" {
+		backgrounds = "#ffffff,#111827"
+	}
+	for _, bg := range strings.Split(backgrounds, ",") {
+		bg = strings.TrimSpace(bg)
+		if _, ok := parseHexColor(bg); ok {
+			nameColorBackgrounds = append(nameColorBackgrounds, bg)
+		} else if bg != "" {
+			log.Printf("[GO] Warning: ignoring invalid name color background %q", bg)
+		}
+	}
+}
+
+// normalizeHexColor validates a #rgb / #rrggbb color and returns it as
+// lowercase #rrggbb
+func normalizeHexColor(color string) (string, bool) {
+	if !hexColorPattern.MatchString(color) {
+		return "", false
+	}
+	hex := strings.ToLower(color[1:])
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	return "#" + hex, true
+}
+
+func parseHexColor(color string) ([3]float64, bool) {
+	var rgb [3]float64
+	normalized, ok := normalizeHexColor(color)
+	if !ok {
+		return rgb, false
+	}
+	for i := 0; i < 3; i++ {
+		v, _ := strconv.ParseUint(normalized[1+2*i:3+2*i], 16, 8)
+		rgb[i] = float64(v) / 255
+	}
+	return rgb, true
+}
+
+// relativeLuminance follows the WCAG 2.x definition
+func relativeLuminance(rgb [3]float64) float64 {
+	var lum [3]float64
+	for i, c := range rgb {
+		if c <= 0.03928 {
+			lum[i] = c / 12.92
+		} else {
+			lum[i] = math.Pow((c+0.055)/1.055, 2.4)
+		}
+	}
+	return 0.2126*lum[0] + 0.7152*lum[1] + 0.0722*lum[2]
+}
+
+func contrastRatio(a, b [3]float64) float64 {
+	la, lb := relativeLuminance(a), relativeLuminance(b)
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+// validateNameColor checks a color against the policy and returns its
+// normalized form, or a reason it was refused
+func validateNameColor(color string) (string, string) {
+	normalized, ok := normalizeHexColor(color)
+	if !ok {
+		return "", "color must be a hex value like #1e90ff"
+	}
+	rgb, _ := parseHexColor(normalized)
+	for _, bg := range nameColorBackgrounds {
+		bgRGB, _ := parseHexColor(bg)
+		if ratio := contrastRatio(rgb, bgRGB); ratio < nameColorMinContrast {
+			return "", fmt.Sprintf("color has too little contrast against background %s (contrast %.2f, minimum %.2f)", bg, ratio, nameColorMinContrast)
+		}
+	}
+	return normalized, ""
+}
+
+// loadNameColor returns the viewer's name color, or "" when unset
+func loadNameColor(ctx context.Context, viewerID string) string {
+	if !nameColorsEnabled || viewerID == "" {
+		return ""
+	}
+	color, err := rdb.HGet(ctx, nameColorsKey(), viewerID).Result()
+	if err != nil && err != redis.Nil {
+		log.Printf("[GO] Error loading name color for viewer %s: %v", viewerID, err)
+	}
+	return color
+}
+
+type NameColorRequest struct {
+	ViewerID string `json:"viewer_id" binding:"required"`
+	Color    string `json:"color"` // empty clears the color
+}
+
+// setNameColor sets or clears the viewer's name color, for the viewer or a
+// trusted caller
+func setNameColor(c *gin.Context) {
+	var req NameColorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if !nameColorsEnabled {
+		c.JSON(403, gin.H{"error": "name colors are disabled", "reason": "name_colors_disabled"})
+		return
+	}
+	if !authenticateViewer(c, req.ViewerID) {
+		return
+	}
+
+	reqCtx := c.Request.Context()
+	if req.Color == "" {
+		if err := rdb.HDel(reqCtx, nameColorsKey(), req.ViewerID).Err(); err != nil {
+			c.JSON(500, gin.H{"error": "failed to clear name color"})
+			return
+		}
+		c.JSON(200, gin.H{"success": true, "name_color": ""})
+		return
+	}
+
+	color, reason := validateNameColor(req.Color)
+	if reason != "" {
+		c.JSON(400, gin.H{"error": reason, "reason": "invalid_color"})
+		return
+	}
+	if err := rdb.HSet(reqCtx, nameColorsKey(), req.ViewerID, color).Err(); err != nil {
+		c.JSON(500, gin.H{"error": "failed to save name color"})
+		return
+	}
+	c.JSON(200, gin.H{"success": true, "name_color": color})
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+// setColor sets viewer v1's name color with the given headers
+func setColor(t *testing.T, color string, headers ...string) (int, map[string]interface{}) {
+	t.Helper()
+	w := request(t, http.MethodPost, "/name-color", map[string]interface{}{"viewer_id": "v1", "color": color}, headers...)
+	return w.Code, decode(t, w)
+}
+
+func TestNameColorValidation(t *testing.T) {
+	resetRedis(t)
+	session := []string{"X-Session-Token", viewerSession(t, "v1")}
+
+	if status, resp := setColor(t, "#E91", session...); status != 200 || resp["name_color"] != "#ee9911" {
+		t.Fatalf("valid color: %d %v", status, resp)
+	}
+	for _, color := range []string{"red", "#12345", "#ggg000"} {
+		if status, resp := setColor(t, color, session...); status != 400 || resp["reason"] != "invalid_color" {
+			t.Fatalf("color %q: %d %v, want 400 invalid_color", color, status, resp)
+		}
+	}
+	// White can't be read on the light background
+	if status, resp := setColor(t, "#fff", session...); status != 400 || resp["reason"] != "invalid_color" {
+		t.Fatalf("white: %d %v, want 400 invalid_color", status, resp)
+	}
+
+	w := post(t, 1, "v1", "alice", "colorful")
+	expectStatus(t, w, 200)
+	if cmt := decode(t, w)["comment"].(map[string]interface{}); cmt["name_color"] != "#ee9911" {
+		t.Fatalf("comment name_color = %v, want #ee9911", cmt["name_color"])
+	}
+
+	if status, resp := setColor(t, "", session...); status != 200 || resp["name_color"] != "" {
+		t.Fatalf("clearing: %d %v", status, resp)
+	}
+}
+
+func TestNameColorRequiresTheViewer(t *testing.T) {
+	resetRedis(t)
+	other := viewerSession(t, "v2")
+
+	if status, _ := setColor(t, "#e91"); status != 401 {
+		t.Fatalf("without a session: %d, want 401", status)
+	}
+	if status, _ := setColor(t, "#e91", "X-Session-Token", other); status != 401 {
+		t.Fatalf("with another viewer's session: %d, want 401", status)
+	}
+	if status, _ := setColor(t, "#e91", trusted...); status != 200 {
+		t.Fatalf("as a trusted caller: %d, want 200", status)
+	}
+}
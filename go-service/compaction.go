@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// Deleting comments over a long stream leaves churn behind: half-deleted
+// entries the integrity check reports, and rankings, report counts and
+// reaction keys still naming comments that are gone. Every
+// COMPACTION_INTERVAL seconds one replica, holding the compaction lock,
+// compacts up to COMPACTION_STREAMS streams that were active since the
+// streams it did last: it repairs the index and data hash like POST
+// /stream/:id/integrity/repair and removes those tombstones. Author and
+// viewer indexes keep deleted IDs on purpose, so history can report them.
+// Everything is walked with SCAN-family commands over the stream's own keys
+// in batches, never the keyspace, so live reads and writes are never blocked
+// for long. The lock is renewed while a run lasts, so a slow run doesn't
+// overlap the next replica's. With COMPACTION_REWRITE_MAX set,
+// a data hash of at most that many comments is also rewritten in one step
+// to give back memory left by deletions; larger ones are left alone, as
+// rewriting them would block Redis. GET /compaction reports the last run.
+// 0 turns compaction off.
+var (
+	compactionInterval   time.Duration
+	compactionStreams    int64
+	compactionRewriteMax int64
+)
+
+func loadCompactionConfig() {
+	compactionInterval = time.Duration(envInt("COMPACTION_INTERVAL", 0)) * time.Second
+	compactionStreams = int64(envInt("COMPACTION_STREAMS", 50))
+	if compactionStreams < 1 {
+		compactionStreams = 50
+	}
+	compactionRewriteMax = int64(envInt("COMPACTION_REWRITE_MAX", 0))
+}
+
+// CompactionStats describes a compaction run
+type CompactionStats struct {
+	StartedAt  int64  `json:"started_at"`
+	DurationMs int64  `json:"duration_ms"`
+	Streams    int    `json:"streams"`
+	Orphans    int    `json:"orphans"`    // index and data entries missing their other half
+	Tombstones int    `json:"tombstones"` // ranking, report and reaction entries of deleted comments
+	Rewritten  int    `json:"rewritten"`  // data hashes rewritten
+	Through    int64  `json:"through"`    // activity (ms) of the last stream compacted
+	Error      string `json:"error,omitempty"`
+}
+
+// rewriteScript rewrites a hash into a fresh allocation if it holds at most
+// ARGV[1] fields. KEYS: hash.
+var rewriteScript = redis.NewScript(`
+if redis.call('HLEN', KEYS[1]) > tonumber(ARGV[1]) then
+	return 0
+end
+local fields = redis.call('HGETALL', KEYS[1])
+if #fields == 0 then
+	return 0
+end
+redis.call('DEL', KEYS[1])
+for i = 1, #fields, 2 do
+	redis.call('HSET', KEYS[1], fields[i], fields[i + 1])
+end
+return 1
+`)
+
+// pruneSortedSet removes the members of a per-stream sorted set whose
+// comments are gone
+func pruneSortedSet(ctx context.Context, streamID int64, key string) (int, error) {
+	removed := 0
+	var cursor uint64
+	for {
+		pairs, next, err := rdb.ZScan(ctx, key, cursor, "*", integrityScanBatch).Result()
+		if err != nil {
+			return removed, err
+		}
+		ids := make([]string, 0, len(pairs)/2)
+		for i := 0; i < len(pairs); i += 2 {
+			ids = append(ids, pairs[i])
+		}
+		gone, err := missingData(ctx, streamID, ids)
+		if err != nil {
+			return removed, err
+		}
+		if len(gone) > 0 {
+			members := make([]interface{}, len(gone))
+			for i, id := range gone {
+				members[i] = id
+			}
+			if err := rdb.ZRem(ctx, key, members...).Err(); err != nil {
+				return removed, err
+			}
+			removed += len(gone)
+		}
+		if cursor = next; cursor == 0 {
+			return removed, nil
+		}
+	}
+}
+
+// pruneReportCounts removes the report counts of comments that are gone
+func pruneReportCounts(ctx context.Context, streamID int64) (int, error) {
+	removed := 0
+	var cursor uint64
+	for {
+		pairs, next, err := rdb.HScan(ctx, reportCountsKey(streamID), cursor, "*", integrityScanBatch).Result()
+		if err != nil {
+			return removed, err
+		}
+		ids := make([]string, 0, len(pairs)/2)
+		for i := 0; i < len(pairs); i += 2 {
+			ids = append(ids, pairs[i])
+		}
+		gone, err := missingData(ctx, streamID, ids)
+		if err != nil {
+			return removed, err
+		}
+		if len(gone) > 0 {
+			if err := rdb.HDel(ctx, reportCountsKey(streamID), gone...).Err(); err != nil {
+				return removed, err
+			}
+			removed += len(gone)
+		}
+		if cursor = next; cursor == 0 {
+			return removed, nil
+		}
+	}
+}
+
+// pruneCommentKeys deletes the per-comment reaction and report keys of
+// comments that are gone, walking the stream's keyed comments
+func pruneCommentKeys(ctx context.Context, streamID int64) (int, error) {
+	removed := 0
+	var cursor uint64
+	for {
+		ids, next, err := rdb.SScan(ctx, commentKeysKey(streamID), cursor, "*", integrityScanBatch).Result()
+		if err != nil {
+			return removed, err
+		}
+		gone, err := missingData(ctx, streamID, ids)
+		if err != nil {
+			return removed, err
+		}
+		if len(gone) > 0 {
+			stale := make([]string, 0, 3*len(gone))
+			members := make([]interface{}, len(gone))
+			for i, id := range gone {
+				commentID, _ := strconv.ParseInt(id, 10, 64)
+				stale = append(stale, reactionCountsKey(streamID, commentID), reactionVotersKey(streamID, commentID), reportersKey(streamID, commentID))
+				members[i] = id
+			}
+			_, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.Del(ctx, stale...)
+				pipe.SRem(ctx, commentKeysKey(streamID), members...)
+				return nil
+			})
+			if err != nil {
+				return removed, err
+			}
+			removed += len(gone)
+		}
+		if cursor = next; cursor == 0 {
+			return removed, nil
+		}
+	}
+}
+
+// compactStream compacts one stream into stats
+func compactStream(ctx context.Context, streamID int64, stats *CompactionStats) error {
+	report, err := checkIntegrity(ctx, streamID, true)
+	stats.Orphans += report.Repaired
+	if err != nil {
+		return err
+	}
+	for _, k := range []string{priorityKey(streamID), reactionLeaderboardKey(streamID), engagementKey(streamID)} {
+		n, err := pruneSortedSet(ctx, streamID, k)
+		stats.Tombstones += n
+		if err != nil {
+			return err
+		}
+	}
+	n, err := pruneReportCounts(ctx, streamID)
+	stats.Tombstones += n
+	if err != nil {
+		return err
+	}
+	n, err = pruneCommentKeys(ctx, streamID)
+	stats.Tombstones += n
+	if err != nil {
+		return err
+	}
+	if compactionRewriteMax > 0 {
+		rewritten, err := rewriteScript.Run(ctx, rdb, []string{commentDataKey(streamID)}, compactionRewriteMax).Int()
+		if err != nil {
+			return err
+		}
+		stats.Rewritten += rewritten
+	}
+	return nil
+}
+
+// compactComments runs one compaction, picking up after the streams the
+// previous run got through
+func compactComments(ctx context.Context) CompactionStats {
+	started := time.Now()
+	stats := CompactionStats{StartedAt: started.UnixMilli()}
+	previous := loadCompactionStats(ctx)
+	entries, err := rdb.ZRangeByScoreWithScores(ctx, streamActivityKey(), &redis.ZRangeBy{
+		Min:   "(" + strconv.FormatInt(previous.Through, 10),
+		Max:   "+inf",
+		Count: compactionStreams,
+	}).Result()
+	stats.Through = previous.Through
+	if err == nil {
+		for _, z := range entries {
+			member, _ := z.Member.(string)
+			streamID, convErr := strconv.ParseInt(member, 10, 64)
+			if convErr != nil {
+				continue
+			}
+			if err = compactStream(ctx, streamID, &stats); err != nil {
+				log.Printf("[GO] Stream %d: Compaction failed: %v", streamID, err)
+				break
+			}
+			stats.Streams++
+			stats.Through = int64(z.Score)
+		}
+	}
+	if err != nil {
+		stats.Error = err.Error()
+	}
+	stats.DurationMs = time.Since(started).Milliseconds()
+	if payload, encodeErr := json.Marshal(stats); encodeErr == nil {
+		rdb.Set(ctx, compactionStatsKey(), payload, 0)
+	}
+	return stats
+}
+
+// loadCompactionStats returns the last run's stats, zero before the first
+func loadCompactionStats(ctx context.Context) CompactionStats {
+	var stats CompactionStats
+	if raw, err := rdb.Get(ctx, compactionStatsKey()).Bytes(); err == nil {
+		json.Unmarshal(raw, &stats)
+	}
+	return stats
+}
+
+// compactionHolder tells this process's hold on the compaction lock apart
+// from other replicas'
+var compactionHolder = fmt.Sprintf("%d-%d", os.Getpid(), time.Now().UnixNano())
+
+// renewCompactionLock extends the compaction lock by an interval, reporting
+// whether this replica still holds it
+func renewCompactionLock(ctx context.Context) (bool, error) {
+	renewed, err := renewLockScript.Run(ctx, rdb, []string{compactionLockKey()}, compactionHolder, compactionInterval.Milliseconds()).Int()
+	return renewed == 1, err
+}
+
+// compactHoldingLock runs a compaction, renewing the lock as it goes and
+// stopping if it is lost. The lock is left to expire afterwards, which
+// keeps the next run an interval away.
+func compactHoldingLock(ctx context.Context) CompactionStats {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		ticker := time.NewTicker(compactionInterval / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-runCtx.Done():
+				return
+			case <-ticker.C:
+				held, err := renewCompactionLock(runCtx)
+				if runCtx.Err() != nil {
+					return
+				}
+				if err != nil {
+					log.Printf("[GO] Compaction: Error renewing the compaction lock: %v", err)
+				}
+				if !held {
+					log.Printf("[GO] Compaction: lost the compaction lock, stopping")
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+	return compactComments(runCtx)
+}
+
+// runCompaction compacts streams every interval. All replicas run it, so a
+// lock lets only one compact per interval.
+func runCompaction(ctx context.Context) {
+	if compactionInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(compactionInterval)
+	defer ticker.Stop()
+	jobs.setRunning("compaction", true)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			claimed, err := rdb.SetNX(ctx, compactionLockKey(), compactionHolder, compactionInterval).Result()
+			if err != nil || !claimed {
+				jobs.ran("compaction", err)
+				continue
+			}
+			stats := compactHoldingLock(ctx)
+			if stats.Error != "" {
+				jobs.ran("compaction", errors.New(stats.Error))
+				continue
+			}
+			jobs.ran("compaction", nil)
+			if stats.Orphans > 0 || stats.Tombstones > 0 {
+				log.Printf("[GO] Compaction of %d streams removed %d orphans and %d tombstones", stats.Streams, stats.Orphans, stats.Tombstones)
+			}
+		}
+	}
+}
+
+// getCompaction reports the last compaction run
+func getCompaction(c *gin.Context) {
+	stats := loadCompactionStats(c.Request.Context())
+	c.JSON(200, gin.H{"enabled": compactionInterval > 0, "last": stats})
+}
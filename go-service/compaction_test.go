@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// postedID posts a comment and returns its ID
+func postedID(t *testing.T, streamID int64, viewerID, username, message string) int64 {
+	t.Helper()
+	w := post(t, streamID, viewerID, username, message)
+	expectStatus(t, w, 200)
+	cmt, _ := decode(t, w)["comment"].(map[string]interface{})
+	id, _ := cmt["id"].(float64)
+	return int64(id)
+}
+
+// reactAndReport reacts to and reports a comment as viewer
+func reactAndReport(t *testing.T, streamID, commentID int64, viewer string) {
+	t.Helper()
+	expectStatus(t, request(t, http.MethodPost, "/react", map[string]interface{}{
+		"stream_id": streamID, "comment_id": commentID, "viewer_id": viewer, "reaction": "like",
+	}), 200)
+	expectStatus(t, request(t, http.MethodPost, "/report", map[string]interface{}{
+		"stream_id": streamID, "comment_id": commentID, "viewer_id": viewer,
+	}), 200)
+}
+
+func TestCompactionRemovesOrphansOnly(t *testing.T) {
+	resetRedis(t)
+	live := postedID(t, 1, "v1", "alice", "staying")
+	deleted := postedID(t, 1, "v2", "bob", "going")
+	reactAndReport(t, 1, live, "v3")
+	reactAndReport(t, 1, deleted, "v3")
+	if err := deleteComments(ctx, 1, []string{strconv.FormatInt(deleted, 10)}); err != nil {
+		t.Fatal(err)
+	}
+
+	var stats CompactionStats
+	if err := compactStream(ctx, 1, &stats); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{reactionCountsKey(1, deleted), reactionVotersKey(1, deleted), reportersKey(1, deleted)} {
+		if testRedis.Exists(k) {
+			t.Errorf("%s survived compaction", k)
+		}
+	}
+	for _, k := range []string{reactionCountsKey(1, live), reactionVotersKey(1, live), reportersKey(1, live)} {
+		if !testRedis.Exists(k) {
+			t.Errorf("%s of a live comment was removed", k)
+		}
+	}
+	if n, _ := rdb.HGet(ctx, reportCountsKey(1), strconv.FormatInt(live, 10)).Int(); n != 1 {
+		t.Errorf("live comment's report count = %d, want 1", n)
+	}
+	if rdb.HExists(ctx, reportCountsKey(1), strconv.FormatInt(deleted, 10)).Val() {
+		t.Error("deleted comment's report count survived compaction")
+	}
+	if keyed := rdb.SMembers(ctx, commentKeysKey(1)).Val(); len(keyed) != 1 || keyed[0] != strconv.FormatInt(live, 10) {
+		t.Errorf("keyed comments = %v, want only the live one", keyed)
+	}
+	if stats.Tombstones == 0 {
+		t.Error("compaction reported no tombstones")
+	}
+	nextSecond()
+	if got := messages(poll(t, 1, "v4", 0)); len(got) != 1 || got[0] != "staying" {
+		t.Fatalf("comments after compaction = %v, want [staying]", got)
+	}
+}
+
+func TestCompactionRepairsHalfDeletedComments(t *testing.T) {
+	resetRedis(t)
+	saveAt(t, time.Now().Add(-integrityRepairGrace-time.Minute).UnixMilli(), 1)
+	rdb.HDel(ctx, commentDataKey(1), "1")
+
+	var stats CompactionStats
+	if err := compactStream(ctx, 1, &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Orphans != 1 {
+		t.Fatalf("orphans = %d, want 1", stats.Orphans)
+	}
+	if rdb.ZCard(ctx, commentIndexKey(1)).Val() != 0 {
+		t.Fatal("index still names the deleted comment")
+	}
+}
+
+func TestCompactionLockIsHolderChecked(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &compactionInterval, time.Minute)
+
+	rdb.Set(ctx, compactionLockKey(), "another-replica", time.Second)
+	if held, err := renewCompactionLock(ctx); err != nil || held {
+		t.Fatalf("renewed another replica's lock: %v, %v", held, err)
+	}
+	if ttl := testRedis.TTL(compactionLockKey()); ttl != time.Second {
+		t.Fatalf("other replica's lock TTL = %v, want it untouched", ttl)
+	}
+
+	rdb.Set(ctx, compactionLockKey(), compactionHolder, time.Second)
+	if held, err := renewCompactionLock(ctx); err != nil || !held {
+		t.Fatalf("couldn't renew our own lock: %v, %v", held, err)
+	}
+	if ttl := testRedis.TTL(compactionLockKey()); ttl != time.Minute {
+		t.Fatalf("renewed lock TTL = %v, want 1m", ttl)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Concurrency limits bound how many requests an endpoint handles at once, so
+// a burst of viewers polling together queues up instead of hitting Redis all
+// at the same moment. CONCURRENCY_LIMITS lists them per route, e.g.
+// "/check-update=200,/post-comment=50"; routes not listed are unbounded.
+// Requests beyond the limit wait up to CONCURRENCY_QUEUE_TIMEOUT (ms) for a
+// slot, then get a 429; 0 fails them right away. Long-lived routes (SSE,
+// WebSocket) hold their slot while connected, so don't list them unless that
+// is the point.
+var (
+	concurrencyLimits       map[string]*endpointLimiter
+	concurrencyQueueTimeout time.Duration
+)
+
+var concurrencyRejected = newCounter("http_concurrency_rejected_total", "Requests refused because their endpoint was at its concurrency limit")
+
+// endpointLimiter is a semaphore over one endpoint's in-flight handlers
+type endpointLimiter struct {
+	slots    chan struct{}
+	inFlight *gauge
+}
+
+func newEndpointLimiter(endpoint string, limit int) *endpointLimiter {
+	return &endpointLimiter{
+		slots:    make(chan struct{}, limit),
+		inFlight: newGauge("http_inflight_requests", fmt.Sprintf("endpoint=%q", endpoint), "Requests currently being handled by concurrency-limited endpoints"),
+	}
+}
+
+func loadConcurrencyConfig() {
+	concurrencyQueueTimeout = time.Duration(envInt("CONCURRENCY_QUEUE_TIMEOUT", 0)) * time.Millisecond
+	concurrencyLimits = map[string]*endpointLimiter{}
+	for _, entry := range strings.Split(envString("CONCURRENCY_LIMITS", ""), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, value, _ := strings.Cut(entry, "=")
+		limit, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || limit <= 0 {
+			log.Printf("[GO] Warning: ignoring invalid concurrency limit %q", entry)
+			continue
+		}
+		route = strings.TrimSpace(route)
+		concurrencyLimits[route] = newEndpointLimiter(route, limit)
+	}
+}
+
+// acquire takes a slot, waiting up to timeout; done aborts the wait
+func (l *endpointLimiter) acquire(timeout time.Duration, done <-chan struct{}) bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if timeout <= 0 {
+		return false
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-done:
+		return false
+	}
+}
+
+func (l *endpointLimiter) release() {
+	<-l.slots
+}
+
+// concurrencyMiddleware applies the route's concurrency limit, if any
+func concurrencyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limiter := concurrencyLimits[c.FullPath()]
+		if limiter == nil {
+			c.Next()
+			return
+		}
+		if !limiter.acquire(concurrencyQueueTimeout, c.Request.Context().Done()) {
+			concurrencyRejected.Inc()
+			c.Header("Retry-After", "1")
+			c.AbortWithStatusJSON(429, gin.H{"error": "server is busy, please try again shortly", "reason": "overloaded", "retry_after": 1})
+			return
+		}
+		limiter.inFlight.Inc()
+		defer func() {
+			limiter.inFlight.Dec()
+			limiter.release()
+		}()
+		c.Next()
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// limitedRouter serves /slow under a concurrency limit, each request
+// holding its slot until release is closed
+func limitedRouter(t *testing.T, limit int, release <-chan struct{}) (*gin.Engine, *endpointLimiter, *int64) {
+	t.Helper()
+	limiter := newEndpointLimiter("/slow", limit)
+	setVar(t, &concurrencyLimits, map[string]*endpointLimiter{"/slow": limiter})
+	var peak, running int64
+	r := gin.New()
+	r.Use(concurrencyMiddleware())
+	r.GET("/slow", func(c *gin.Context) {
+		n := atomic.AddInt64(&running, 1)
+		for {
+			p := atomic.LoadInt64(&peak)
+			if n <= p || atomic.CompareAndSwapInt64(&peak, p, n) {
+				break
+			}
+		}
+		<-release
+		atomic.AddInt64(&running, -1)
+		c.Status(200)
+	})
+	return r, limiter, &peak
+}
+
+// concurrentGets sends n requests to /slow at once and returns their
+// statuses once all have finished
+func concurrentGets(r *gin.Engine, n int) <-chan int {
+	statuses := make(chan int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+			statuses <- w.Code
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(statuses)
+	}()
+	return statuses
+}
+
+func TestConcurrencyLimitFailsFastBeyondTheLimit(t *testing.T) {
+	setVar(t, &concurrencyQueueTimeout, 0)
+	release := make(chan struct{})
+	r, limiter, peak := limitedRouter(t, 2, release)
+	rejected := concurrencyRejected.Value()
+
+	statuses := concurrentGets(r, 5)
+	// The three requests without a slot come back right away
+	for i := 0; i < 3; i++ {
+		if code := <-statuses; code != 429 {
+			t.Fatalf("request beyond the limit = %d, want 429", code)
+		}
+	}
+	if got := limiter.inFlight.Value(); got != 2 {
+		t.Fatalf("in flight = %d, want 2", got)
+	}
+	close(release)
+	for code := range statuses {
+		if code != 200 {
+			t.Fatalf("request within the limit = %d, want 200", code)
+		}
+	}
+	if *peak != 2 || limiter.inFlight.Value() != 0 {
+		t.Fatalf("peak = %d in flight after = %d, want 2 and 0", *peak, limiter.inFlight.Value())
+	}
+	if got := concurrencyRejected.Value() - rejected; got != 3 {
+		t.Fatalf("rejected counter grew by %d, want 3", got)
+	}
+}
+
+func TestConcurrencyLimitQueuesWithinTheTimeout(t *testing.T) {
+	setVar(t, &concurrencyQueueTimeout, 5*time.Second)
+	release := make(chan struct{})
+	r, _, peak := limitedRouter(t, 2, release)
+
+	statuses := concurrentGets(r, 6)
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	for code := range statuses {
+		if code != 200 {
+			t.Fatalf("queued request = %d, want 200", code)
+		}
+	}
+	if *peak != 2 {
+		t.Fatalf("peak concurrency = %d, want 2", *peak)
+	}
+}
+
+func TestConcurrencyLimitsFromEnv(t *testing.T) {
+	t.Setenv("CONCURRENCY_LIMITS", "/check-update=200, /post-comment=50,/bad=0,/worse=x")
+	t.Setenv("CONCURRENCY_QUEUE_TIMEOUT", "250")
+	setVar(t, &concurrencyLimits, nil)
+	setVar(t, &concurrencyQueueTimeout, 0)
+	loadConcurrencyConfig()
+
+	if len(concurrencyLimits) != 2 || cap(concurrencyLimits["/check-update"].slots) != 200 || cap(concurrencyLimits["/post-comment"].slots) != 50 {
+		t.Fatalf("limits = %v, want /check-update=200 and /post-comment=50", concurrencyLimits)
+	}
+	if concurrencyQueueTimeout != 250*time.Millisecond {
+		t.Fatalf("queue timeout = %v, want 250ms", concurrencyQueueTimeout)
+	}
+}
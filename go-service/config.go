@@ -0,0 +1,61 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// envBool reads a boolean environment variable, falling back to def when
+// unset or unparsable
+func envBool(name string, def bool) bool {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return def
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("[GO] Warning: invalid %s=%q, using default %v", name, value, def)
+		return def
+	}
+	return parsed
+}
+
+// envInt reads an integer environment variable, falling back to def when
+// unset or unparsable
+func envInt(name string, def int) int {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return def
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("[GO] Warning: invalid %s=%q, using default %d", name, value, def)
+		return def
+	}
+	return parsed
+}
+
+// envFloat reads a float environment variable, falling back to def when
+// unset or unparsable
+func envFloat(name string, def float64) float64 {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return def
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("[GO] Warning: invalid %s=%q, using default %v", name, value, def)
+		return def
+	}
+	return parsed
+}
+
+// envString reads a string environment variable, falling back to def when unset
+func envString(name, def string) string {
+	if value, ok := os.LookupEnv(name); ok {
+		return value
+	}
+	return def
+}
@@ -0,0 +1,83 @@
+package main
+
+import "strings"
+
+// Clients can report their connection with a conn hint on check-update and
+// heartbeat, using the Network Information API's names ("wifi", "ethernet",
+// "cellular", "4g", "3g", "2g", "slow-2g") or "save-data". The hint only
+// shapes the response it came with and is never stored:
+//
+//   - rich (wifi, ethernet, 4g, 5g): polls return up to CONN_RICH_MAX_COMMENTS
+//     comments, defaulting to twice POLL_MAX_COMMENTS
+//   - full (3g, cellular, or no hint): the regular response
+//   - lean (2g, slow-2g, save-data): a digest (see digest.go) of at most
+//     CONN_LEAN_MAX_COMMENTS comments, without live reactions and seen counts
+//
+// ADAPTIVE_DELIVERY=false ignores hints and always sends the full response.
+const (
+	deliveryRich = "rich"
+	deliveryFull = "full"
+	deliveryLean = "lean"
+)
+
+var (
+	adaptiveDelivery    bool
+	connRichMaxComments int
+	connLeanMaxComments int
+)
+
+func loadConnConfig() {
+	adaptiveDelivery = envBool("ADAPTIVE_DELIVERY", true)
+	connRichMaxComments = envInt("CONN_RICH_MAX_COMMENTS", 2*pollMaxComments)
+	connLeanMaxComments = envInt("CONN_LEAN_MAX_COMMENTS", 50)
+	if connLeanMaxComments < 1 {
+		connLeanMaxComments = 50
+	}
+}
+
+// connDeliveries maps conn hints to the delivery they get
+var connDeliveries = map[string]string{
+	"wifi":      deliveryRich,
+	"ethernet":  deliveryRich,
+	"5g":        deliveryRich,
+	"4g":        deliveryRich,
+	"3g":        deliveryFull,
+	"cellular":  deliveryFull,
+	"2g":        deliveryLean,
+	"slow-2g":   deliveryLean,
+	"save-data": deliveryLean,
+}
+
+// deliveryFor returns the delivery for a conn hint; unknown and missing
+// hints get the full response
+func deliveryFor(conn string) string {
+	if !adaptiveDelivery {
+		return deliveryFull
+	}
+	if delivery, ok := connDeliveries[strings.ToLower(strings.TrimSpace(conn))]; ok {
+		return delivery
+	}
+	return deliveryFull
+}
+
+// deliveryMaxComments is the poll cap for a delivery, 0 = no cap
+func deliveryMaxComments(delivery string) int {
+	switch delivery {
+	case deliveryRich:
+		return connRichMaxComments
+	case deliveryLean:
+		if pollMaxComments > 0 && pollMaxComments < connLeanMaxComments {
+			return pollMaxComments
+		}
+		return connLeanMaxComments
+	}
+	return pollMaxComments
+}
+
+// deliveryInitialLimit is how many comments an initial load returns
+func deliveryInitialLimit(delivery string) int {
+	if delivery == deliveryLean && connLeanMaxComments < initialLoadLimit {
+		return connLeanMaxComments
+	}
+	return initialLoadLimit
+}
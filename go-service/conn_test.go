@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDeliveryForConnHints(t *testing.T) {
+	setVar(t, &adaptiveDelivery, true)
+	for conn, want := range map[string]string{
+		"wifi":           deliveryRich,
+		" WiFi ":         deliveryRich,
+		"4g":             deliveryRich,
+		"3g":             deliveryFull,
+		"cellular":       deliveryFull,
+		"2g":             deliveryLean,
+		"save-data":      deliveryLean,
+		"":               deliveryFull,
+		"carrier-pigeon": deliveryFull,
+	} {
+		if got := deliveryFor(conn); got != want {
+			t.Errorf("deliveryFor(%q) = %q, want %q", conn, got, want)
+		}
+	}
+
+	setVar(t, &adaptiveDelivery, false)
+	if got := deliveryFor("2g"); got != deliveryFull {
+		t.Fatalf("deliveryFor(2g) with adaptive delivery off = %q, want full", got)
+	}
+}
+
+// pollConn polls stream 1 from lastID with a conn hint
+func pollConn(t *testing.T, lastID int64, conn string) map[string]interface{} {
+	t.Helper()
+	w := request(t, http.MethodPost, "/check-update", map[string]interface{}{
+		"stream_id": 1, "viewer_id": "v1", "last_id": lastID, "conn": conn,
+	})
+	expectStatus(t, w, 200)
+	return decode(t, w)
+}
+
+func TestConnHintsShapeResponses(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &adaptiveDelivery, true)
+	setVar(t, &pollMaxComments, 5)
+	setVar(t, &connRichMaxComments, 20)
+	setVar(t, &connLeanMaxComments, 3)
+	ts := time.Now().Add(-time.Minute).UnixMilli()
+	for i := int64(1); i <= 8; i++ {
+		saveAt(t, ts+i, i)
+	}
+
+	for _, tc := range []struct {
+		conn, delivery string
+		comments       int
+		truncated      bool
+	}{
+		{"wifi", deliveryRich, 8, false},
+		{"3g", deliveryFull, 5, true},
+		{"", "", 5, true},
+	} {
+		resp := pollConn(t, ts, tc.conn)
+		if got := messages(resp); len(got) != tc.comments || (resp["truncated"] == true) != tc.truncated {
+			t.Fatalf("conn %q: %d comments truncated %v, want %d truncated %v", tc.conn, len(got), resp["truncated"], tc.comments, tc.truncated)
+		}
+		if delivery, _ := resp["delivery"].(string); delivery != tc.delivery || resp["digest"] != nil {
+			t.Fatalf("conn %q: delivery %q digest %v, want %q without a digest", tc.conn, delivery, resp["digest"], tc.delivery)
+		}
+	}
+
+	lean := pollConn(t, ts, "slow-2g")
+	if lean["delivery"] != deliveryLean || lean["digest"] == nil || lean["truncated"] != true {
+		t.Fatalf("lean poll = delivery %v digest %v truncated %v, want a truncated digest", lean["delivery"], lean["digest"], lean["truncated"])
+	}
+	if digest := lean["digest"].(map[string]interface{}); digest["count"] != float64(3) {
+		t.Fatalf("lean digest = %v, want it over the 3 lean comments", digest)
+	}
+}
+
+func TestHeartbeatReportsDelivery(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &adaptiveDelivery, true)
+	w := request(t, http.MethodPost, "/heartbeat", map[string]interface{}{"stream_id": 1, "viewer_id": "v1", "conn": "2g"})
+	expectStatus(t, w, 200)
+	if got := decode(t, w)["delivery"]; got != deliveryLean {
+		t.Fatalf("heartbeat delivery = %v, want lean", got)
+	}
+	if got := heartbeatSession(t, "v1", "")["delivery"]; got != nil {
+		t.Fatalf("heartbeat without a hint reports delivery %v", got)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Consumers that must not miss a comment (archival sinks, exports) poll
+// check-update with a consumer_id and acknowledge what they have processed:
+// each poll echoes the cursor of the last response it finished with as ack.
+// The server keeps the newest ack as the consumer's watermark (CONSUMER_TTL
+// after its last poll) and serves every poll from there, oldest first, so a
+// consumer that crashes before acknowledging gets the same comments again
+// and a restarted one resumes where it left off whatever last_id it sends.
+// last_id only seeds a consumer that has never acknowledged; 0 starts from
+// the beginning of the stream. Pages are capped at POLL_MAX_COMMENTS and end
+// on a millisecond boundary, with truncated set while more are waiting.
+// Consumers are independent of each other and of viewers, and are for
+// trusted callers only. Delivery is at-least-once: a consumer must tolerate
+// comments it already has.
+var consumerTTL time.Duration
+
+var consumerIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.:-]{1,64}$`)
+
+func loadConsumerConfig() {
+	consumerTTL = time.Duration(envInt("CONSUMER_TTL", 7*24*60*60)) * time.Second
+}
+
+// ackScript moves a watermark forward, never back. KEYS: watermark. ARGV:
+// ack, ttl (s). Returns the watermark, 0 while there is none.
+var ackScript = redis.NewScript(`
+local current = tonumber(redis.call('GET', KEYS[1]) or '0') or 0
+local ack = tonumber(ARGV[1])
+if ack > current then
+	current = ack
+	redis.call('SET', KEYS[1], current)
+end
+if current > 0 then
+	redis.call('EXPIRE', KEYS[1], ARGV[2])
+end
+return current
+`)
+
+// ackConsumer records a consumer's ack and returns its watermark
+func ackConsumer(ctx context.Context, streamID int64, consumerID string, ack int64) (int64, error) {
+	return ackScript.Run(ctx, rdb, []string{consumerKey(streamID, consumerID)}, ack, int(consumerTTL.Seconds())).Int64()
+}
+
+// consumerPage cuts a consumer's poll, read oldest first with one past the
+// cap, down to the cap. A page never ends inside a millisecond: a cut inside
+// one moves it to the next page, or when it fills the page alone the rest of
+// it is read so the cursor can move past it.
+func consumerPage(ctx context.Context, q feedQuery, comments []Comment, limit int, now int64) ([]Comment, bool) {
+	if limit <= 0 || len(comments) <= limit {
+		return comments, false
+	}
+	cut := comments[limit].Timestamp
+	page := comments[:limit]
+	if page[len(page)-1].Timestamp != cut {
+		return page, true
+	}
+	end := len(page)
+	for end > 0 && page[end-1].Timestamp == cut {
+		end--
+	}
+	if end > 0 {
+		return page[:end], true
+	}
+	rest := feedQuery{StreamID: q.StreamID, Min: cut, Max: cut}
+	snap := store.ReadFeed(ctx, rest)
+	return snap.decodeComments(ctx, q.StreamID, now), true
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// consume polls stream 1 as a trusted consumer acknowledging ack
+func consume(t *testing.T, consumerID string, lastID, ack int64) map[string]interface{} {
+	t.Helper()
+	w := request(t, http.MethodPost, "/check-update", map[string]interface{}{
+		"stream_id": 1, "consumer_id": consumerID, "last_id": lastID, "ack": ack,
+	}, trusted...)
+	expectStatus(t, w, 200)
+	return decode(t, w)
+}
+
+// cursorOf reads a poll response's cursor
+func cursorOf(resp map[string]interface{}) int64 {
+	cursor, _ := resp["cursor"].(float64)
+	return int64(cursor)
+}
+
+func TestConsumerResumesAfterRestart(t *testing.T) {
+	resetRedis(t)
+	ts := time.Now().Add(-time.Minute).UnixMilli()
+	saveAt(t, ts, 1)
+	saveAt(t, ts+1, 2)
+	saveAt(t, ts+2, 3)
+
+	first := consume(t, "sink", 0, 0)
+	if got := strings.Join(messages(first), " "); got != "m1 m2 m3" {
+		t.Fatalf("first poll = %q, want m1 m2 m3", got)
+	}
+	// Crashing before acknowledging redelivers the same comments
+	if got := strings.Join(messages(consume(t, "sink", 0, 0)), " "); got != "m1 m2 m3" {
+		t.Fatalf("unacknowledged poll = %q, want m1 m2 m3 again", got)
+	}
+	resp := consume(t, "sink", 0, cursorOf(first))
+	if got := messages(resp); len(got) != 0 || resp["watermark"] != float64(cursorOf(first)) {
+		t.Fatalf("acknowledged poll = %v watermark %v, want nothing past %d", got, resp["watermark"], cursorOf(first))
+	}
+
+	// A restarted consumer has lost its last_id but resumes from the watermark
+	saveAt(t, ts+3, 4)
+	if got := strings.Join(messages(consume(t, "sink", 0, 0)), " "); got != "m4" {
+		t.Fatalf("restarted poll = %q, want only m4", got)
+	}
+}
+
+func TestConsumersAreIndependent(t *testing.T) {
+	resetRedis(t)
+	ts := time.Now().Add(-time.Minute).UnixMilli()
+	saveAt(t, ts, 1)
+	saveAt(t, ts+1, 2)
+
+	a := consume(t, "archive", 0, 0)
+	consume(t, "archive", 0, cursorOf(a))
+	if got := strings.Join(messages(consume(t, "export", 0, 0)), " "); got != "m1 m2" {
+		t.Fatalf("second consumer = %q, want everything", got)
+	}
+	// An older ack never moves the watermark back
+	if resp := consume(t, "archive", 0, ts); resp["watermark"] != float64(cursorOf(a)) || len(messages(resp)) != 0 {
+		t.Fatalf("stale ack = watermark %v comments %v, want %d and nothing", resp["watermark"], messages(resp), cursorOf(a))
+	}
+}
+
+func TestConsumerPages(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &pollMaxComments, 2)
+	ts := time.Now().Add(-time.Minute).UnixMilli()
+	saveAt(t, ts, 1)
+	saveAt(t, ts+1, 2)
+	saveAt(t, ts+2, 3)
+
+	page := consume(t, "sink", 0, 0)
+	if got := strings.Join(messages(page), " "); got != "m1 m2" || page["truncated"] != true {
+		t.Fatalf("first page = %q truncated %v, want m1 m2 with more waiting", got, page["truncated"])
+	}
+	page = consume(t, "sink", 0, cursorOf(page))
+	if got := strings.Join(messages(page), " "); got != "m3" || page["truncated"] != nil {
+		t.Fatalf("second page = %q truncated %v, want m3 and the end", got, page["truncated"])
+	}
+}
+
+func TestConsumerRequiresTrustedCaller(t *testing.T) {
+	resetRedis(t)
+	body := map[string]interface{}{"stream_id": 1, "consumer_id": "sink"}
+	expectStatus(t, request(t, http.MethodPost, "/check-update", body), 403)
+	body["consumer_id"] = "no spaces"
+	expectStatus(t, request(t, http.MethodPost, "/check-update", body, trusted...), 400)
+}
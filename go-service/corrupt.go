@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+)
+
+// unavailableMessage replaces the text of a comment whose data can't be decoded
+const unavailableMessage = "[unavailable]"
+
+var corruptComments = newCounter("comments_corrupt_total", "Stored comments whose JSON failed to decode")
+
+var orphanedComments = newCounter("comments_orphaned_total", "Indexed comments found with no data while reading the feed")
+
+var (
+	// corruptPlaceholder returns a stand-in comment for undecodable entries
+	// (CORRUPT_COMMENT_PLACEHOLDER) instead of dropping them, so clients
+	// still see the slot and their cursor moves past it
+	corruptPlaceholder bool
+	// corruptQuarantineAfter moves an entry out of the feed once it has
+	// failed to decode this many times (CORRUPT_QUARANTINE_AFTER, 0 = never)
+	corruptQuarantineAfter int64
+	// orphanSelfHeal removes index entries found to have no data
+	// (ORPHAN_SELF_HEAL), so they aren't read again on every poll
+	orphanSelfHeal bool
+)
+
+func loadCorruptConfig() {
+	corruptPlaceholder = envBool("CORRUPT_COMMENT_PLACEHOLDER", false)
+	corruptQuarantineAfter = int64(envInt("CORRUPT_QUARANTINE_AFTER", 3))
+	orphanSelfHeal = envBool("ORPHAN_SELF_HEAL", true)
+}
+
+// handleCorruptComment records an entry that failed to decode and returns
+// its placeholder, or nil when the entry should be dropped
+func handleCorruptComment(ctx context.Context, streamID int64, id, raw string, decodeErr error) *Comment {
+	corruptComments.Inc()
+	log.Printf("[GO] Stream %d: Corrupt comment %s: %v", streamID, id, decodeErr)
+
+	// Build the placeholder first: quarantining drops the entry's score
+	var cmt *Comment
+	if corruptPlaceholder {
+		cmt = &Comment{Message: unavailableMessage}
+		cmt.ID, _ = strconv.ParseInt(id, 10, 64)
+		if score, err := rdb.ZScore(ctx, commentIndexKey(streamID), id).Result(); err == nil {
+			cmt.Timestamp = int64(score)
+		}
+	}
+	if corruptQuarantineAfter > 0 {
+		quarantineIfRepeated(ctx, streamID, id, raw)
+	}
+	return cmt
+}
+
+// quarantineIfRepeated counts decode failures per entry and, past the
+// threshold, moves the raw data aside and drops the entry from the feed
+func quarantineIfRepeated(ctx context.Context, streamID int64, id, raw string) {
+	failures, err := rdb.HIncrBy(ctx, corruptCountsKey(streamID), id, 1).Result()
+	if err != nil || failures < corruptQuarantineAfter {
+		return
+	}
+
+	pipe := rdb.TxPipeline()
+	pipe.HSet(ctx, quarantineKey(streamID), id, raw)
+	pipe.ZRem(ctx, commentIndexKey(streamID), id)
+	pipe.HDel(ctx, commentDataKey(streamID), id)
+	pipe.HDel(ctx, corruptCountsKey(streamID), id)
+	invalidateReplayTimelines(ctx, pipe, streamID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("[GO] Stream %d: Error quarantining comment %s: %v", streamID, id, err)
+		return
+	}
+	log.Printf("[GO] Stream %d: Quarantined comment %s after %d failed decodes", streamID, id, failures)
+}
+
+// handleOrphanedEntries records index entries whose data came back missing
+// and, if configured, removes them. A comment deleted between reading the
+// index and its data is missing too, so each entry is checked again first.
+func handleOrphanedEntries(ctx context.Context, streamID int64, ids []string) {
+	orphans, err := missingData(ctx, streamID, ids)
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error checking orphaned comments: %v", streamID, err)
+		return
+	}
+	var indexed []string
+	for _, id := range orphans {
+		if err := rdb.ZScore(ctx, commentIndexKey(streamID), id).Err(); err == nil {
+			indexed = append(indexed, id)
+		}
+	}
+	if len(indexed) == 0 {
+		return
+	}
+	orphanedComments.Add(int64(len(indexed)))
+	log.Printf("[GO] Stream %d: %d indexed comments have no data: %v", streamID, len(indexed), indexed)
+	if !orphanSelfHeal {
+		return
+	}
+	if err := deleteComments(ctx, streamID, indexed); err != nil {
+		log.Printf("[GO] Stream %d: Error removing orphaned comments: %v", streamID, err)
+		return
+	}
+	log.Printf("[GO] Stream %d: Removed %d orphaned index entries", streamID, len(indexed))
+}
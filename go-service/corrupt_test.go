@@ -0,0 +1,117 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// saveCorrupt indexes a comment whose stored JSON is malformed
+func saveCorrupt(t *testing.T, ts int64, id string) {
+	t.Helper()
+	testRedis.ZAdd(commentIndexKey(1), float64(ts), id)
+	testRedis.HSet(commentDataKey(1), id, `{"id": 2, "message": "trunc`)
+}
+
+func TestMalformedCommentIsSkipped(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &corruptQuarantineAfter, 0)
+	ts := time.Now().Add(-time.Minute).UnixMilli()
+	saveAt(t, ts, 1)
+	saveCorrupt(t, ts+1, "2")
+	saveAt(t, ts+2, 3)
+
+	before := corruptComments.Value()
+	resp := poll(t, 1, "v1", 0)
+	if got := strings.Join(messages(resp), " "); got != "m1 m3" {
+		t.Fatalf("comments = %s, want the corrupt one dropped", got)
+	}
+	if resp["cursor"] != float64(ts+2) {
+		t.Fatalf("cursor = %v, want %d", resp["cursor"], ts+2)
+	}
+	if got := corruptComments.Value() - before; got != 1 {
+		t.Fatalf("corrupt counter moved by %d, want 1", got)
+	}
+}
+
+func TestMalformedCommentPlaceholder(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &corruptPlaceholder, true)
+	setVar(t, &corruptQuarantineAfter, 0)
+	ts := time.Now().Add(-time.Minute).UnixMilli()
+	saveAt(t, ts, 1)
+	saveCorrupt(t, ts+1, "2")
+
+	resp := poll(t, 1, "v1", 0)
+	if got := strings.Join(messages(resp), " "); got != "m1 "+unavailableMessage {
+		t.Fatalf("comments = %s, want a placeholder for the corrupt one", got)
+	}
+	placeholder := resp["comments"].([]interface{})[1].(map[string]interface{})
+	if placeholder["id"] != float64(2) || placeholder["timestamp"] != float64(ts+1) || resp["cursor"] != float64(ts+1) {
+		t.Fatalf("placeholder = %v cursor %v, want ID 2 at %d", placeholder, resp["cursor"], ts+1)
+	}
+}
+
+func TestRepeatedlyCorruptCommentIsQuarantined(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &corruptQuarantineAfter, 2)
+	ts := time.Now().Add(-time.Minute).UnixMilli()
+	saveCorrupt(t, ts, "2")
+
+	poll(t, 1, "v1", 0)
+	if !testRedis.Exists(commentDataKey(1)) {
+		t.Fatal("quarantined after one failure")
+	}
+	poll(t, 1, "v1", 0)
+	if n := rdb.ZCard(ctx, commentIndexKey(1)).Val(); n != 0 {
+		t.Fatalf("index holds %d entries after quarantine, want 0", n)
+	}
+	if raw := testRedis.HGet(quarantineKey(1), "2"); !strings.HasPrefix(raw, `{"id": 2`) {
+		t.Fatalf("quarantined data = %q, want the raw entry", raw)
+	}
+}
+
+func TestOrphanedIndexEntryIsHealed(t *testing.T) {
+	for _, script := range []bool{true, false} {
+		resetRedis(t)
+		setVar(t, &useFeedScript, script)
+		saveAt(t, time.Now().Add(-time.Minute).UnixMilli(), 1, 2, 3)
+		rdb.HDel(ctx, commentDataKey(1), "2")
+		before := orphanedComments.Value()
+
+		if got := strings.Join(messages(poll(t, 1, "v2", 0)), " "); got != "m1 m3" {
+			t.Fatalf("feed (script %v) = %q, want the orphan skipped", script, got)
+		}
+		if got := orphanedComments.Value() - before; got != 1 {
+			t.Fatalf("orphan counter (script %v) grew by %d, want 1", script, got)
+		}
+		if rdb.ZScore(ctx, commentIndexKey(1), "2").Err() == nil {
+			t.Fatalf("orphaned index entry (script %v) still there", script)
+		}
+		// Healed, so the next poll doesn't find it again
+		poll(t, 1, "v2", 0)
+		if got := orphanedComments.Value() - before; got != 1 {
+			t.Fatalf("orphan counter (script %v) after healing grew by %d, want 1", script, got)
+		}
+	}
+}
+
+func TestOrphanedIndexEntryKeptWithoutSelfHeal(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &orphanSelfHeal, false)
+	saveAt(t, time.Now().Add(-time.Minute).UnixMilli(), 1, 2)
+	rdb.HDel(ctx, commentDataKey(1), "2")
+	before := orphanedComments.Value()
+
+	for i := 0; i < 2; i++ {
+		if got := strings.Join(messages(poll(t, 1, "v2", 0)), " "); got != "m1" {
+			t.Fatalf("feed = %q, want m1", got)
+		}
+	}
+	if got := orphanedComments.Value() - before; got != 2 {
+		t.Fatalf("orphan counter grew by %d, want 2 (once per poll)", got)
+	}
+	if err := rdb.ZScore(ctx, commentIndexKey(1), "2").Err(); err != nil {
+		t.Fatalf("orphaned index entry removed: %v", err)
+	}
+}
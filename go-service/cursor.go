@@ -0,0 +1,52 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// StreamCursor is a stream's newest comment. Its timestamp is the last_id to
+// poll with to receive only comments posted after it; an empty stream has
+// zero values, which polls from the start.
+type StreamCursor struct {
+	StreamID  int64 `json:"stream_id"`
+	ID        int64 `json:"id"`
+	Timestamp int64 `json:"timestamp"`
+}
+
+// getStreamCursor returns the head of a stream's feed, so clients joining
+// mid-stream can start polling without an initial load. Viewers subject to
+// the chat delay get the newest comment already revealed to them.
+func getStreamCursor(c *gin.Context) {
+	streamID, ok := parseStreamID(c)
+	if !ok {
+		return
+	}
+	reqCtx := c.Request.Context()
+	max := time.Now().UnixMilli()
+	if !isPrivileged(requestRole(c)) {
+		if delay, err := rdb.Get(reqCtx, delayKey(streamID)).Int(); err == nil && delay > 0 {
+			max -= int64(delay) * 1000
+		}
+	}
+	newest, err := rdb.ZRevRangeByScoreWithScores(reqCtx, commentIndexKey(streamID), &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(max, 10),
+		Count: 1,
+	}).Result()
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error reading cursor: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to load cursor"})
+		return
+	}
+	cursor := StreamCursor{StreamID: streamID}
+	if len(newest) > 0 {
+		cursor.ID, _ = strconv.ParseInt(newest[0].Member.(string), 10, 64)
+		cursor.Timestamp = int64(newest[0].Score)
+	}
+	c.JSON(200, cursor)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// streamCursor reads stream 1's cursor
+func streamCursor(t *testing.T, headers ...string) map[string]interface{} {
+	t.Helper()
+	w := request(t, http.MethodGet, "/stream/1/cursor", nil, headers...)
+	expectStatus(t, w, 200)
+	return decode(t, w)
+}
+
+func TestStreamCursorEmpty(t *testing.T) {
+	resetRedis(t)
+	if cursor := streamCursor(t); cursor["stream_id"] != float64(1) || cursor["id"] != float64(0) || cursor["timestamp"] != float64(0) {
+		t.Fatalf("empty stream cursor = %v, want zero values", cursor)
+	}
+}
+
+func TestStreamCursorPopulated(t *testing.T) {
+	resetRedis(t)
+	now := time.Now()
+	older := now.Add(-2 * time.Minute).UnixMilli()
+	newest := now.Add(-10 * time.Second).UnixMilli()
+	saveAt(t, older, 1)
+	saveAt(t, newest, 2)
+	saveAt(t, now.Add(time.Hour).UnixMilli(), 3) // scheduled, not yet published
+
+	cursor := streamCursor(t)
+	if cursor["id"] != float64(2) || cursor["timestamp"] != float64(newest) {
+		t.Fatalf("cursor = %v, want comment 2 at %d", cursor, newest)
+	}
+	if got := messages(poll(t, 1, "v9", int64(cursor["timestamp"].(float64)))); len(got) != 0 {
+		t.Fatalf("polling from the cursor = %v, want nothing old", got)
+	}
+
+	// Viewers behind the chat delay get the newest comment revealed to them
+	rdb.Set(ctx, delayKey(1), 60, 0)
+	if cursor := streamCursor(t); cursor["id"] != float64(1) || cursor["timestamp"] != float64(older) {
+		t.Fatalf("delayed cursor = %v, want comment 1", cursor)
+	}
+	if cursor := streamCursor(t, asRole(roleModerator)...); cursor["id"] != float64(2) {
+		t.Fatalf("moderator cursor = %v, want comment 2", cursor)
+	}
+}
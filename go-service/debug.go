@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// Debug endpoints let an admin see a stream's raw Redis state and wipe it
+// when it's beyond repair. They are only routed when DEBUG_ENDPOINTS is set
+// and only answer trusted callers asserting the admin role.
+var debugEndpoints bool
+
+func loadDebugConfig() {
+	debugEndpoints = envBool("DEBUG_ENDPOINTS", false)
+}
+
+// maxDebugScan bounds how many keys one pattern may match before the
+// inspection stops counting
+const maxDebugScan = 10000
+
+// streamStateKeys are the stream's keys with a fixed name
+func streamStateKeys(streamID int64) []string {
+	return append(ephemeralStreamKeys(streamID),
+		corruptCountsKey(streamID),
+		quarantineKey(streamID),
+		reactionLeaderboardKey(streamID),
+		commentKeysKey(streamID),
+		featuredActiveKey(streamID),
+		bansKey(streamID),
+		shadowBansKey(streamID),
+		allowCommentsKey(streamID),
+		modesKey(streamID),
+		geoPolicyKey(streamID),
+		highlightPolicyKey(streamID),
+		streamTTLKey(streamID),
+		delayKey(streamID),
+		floodKey(streamID),
+		auditKey(streamID),
+		welcomeKey(streamID),
+		emotesKey(streamID),
+		emotePacksKey(streamID),
+		reactionTypesKey(streamID),
+		raidKey(streamID),
+		raidCheckKey(streamID),
+		onlineSetKey(streamID),
+		onlineSmoothedKey(streamID),
+		timezoneKey(streamID),
+		dripKey(streamID),
+		viewerSeenKey(streamID),
+		heartbeatSeenKey(streamID),
+		readCursorsKey(streamID),
+		moderatorsOnlineKey(streamID),
+		moderatorActiveKey(streamID),
+		socketCountsKey(streamID),
+	)
+}
+
+// streamStatePatterns match the stream's keys named after a comment, viewer
+// or other value
+func streamStatePatterns(streamID int64) []string {
+	return []string{
+		authorIndexKey(streamID, "*"),
+		viewerHistoryKey(streamID, "*"),
+		key("reactions:counts:%d:*", streamID),
+		key("reactions:voters:%d:*", streamID),
+		key("reactions:live:%d:*", streamID),
+		key("reports:voters:%d:*", streamID),
+		key("comments:translations:%d:*", streamID),
+		emotePackKey(streamID, "*"),
+		linkPostersKey(streamID, "*"),
+		linkBlockKey(streamID, "*"),
+		key("velocity:%d:*", streamID),
+		consumerKey(streamID, "*"),
+		firstSeenKey(streamID, "*"),
+		dupesKey(streamID, "*"),
+		key("quota:%d:*", streamID),
+		recentMessagesKey(streamID, "*"),
+		key("ratelimit:%d:*", streamID),
+		slowModeKey(streamID, "*"),
+	}
+}
+
+// scanKeys returns up to max keys matching pattern
+func scanKeys(ctx context.Context, pattern string, max int) ([]string, error) {
+	var found []string
+	iter := rdb.Scan(ctx, 0, pattern, 500).Iterator()
+	for iter.Next(ctx) && len(found) < max {
+		found = append(found, iter.Val())
+	}
+	return found, iter.Err()
+}
+
+// DebugKey is one key of GET /stream/:id/debug. Size is the number of
+// members or fields, or the length of a string.
+type DebugKey struct {
+	Type string `json:"type"`
+	Size int64  `json:"size"`
+	TTL  int64  `json:"ttl_ms"` // -1 without an expiry
+}
+
+// getStreamDebug reports the stream's headline counters and every key it
+// holds with its type, size and TTL
+func getStreamDebug(c *gin.Context) {
+	streamID, ok := parseStreamID(c)
+	if !ok {
+		return
+	}
+	reqCtx := c.Request.Context()
+	names := streamStateKeys(streamID)
+	matched := map[string]int{}
+	for _, pattern := range streamStatePatterns(streamID) {
+		found, err := scanKeys(reqCtx, pattern, maxDebugScan)
+		if err != nil {
+			log.Printf("[GO] Stream %d: Error scanning keys: %v", streamID, err)
+			c.JSON(500, gin.H{"error": "failed to inspect stream"})
+			return
+		}
+		if len(found) > 0 {
+			matched[pattern] = len(found)
+			names = append(names, found...)
+		}
+	}
+
+	types := make([]*redis.StatusCmd, len(names))
+	ttls := make([]*redis.DurationCmd, len(names))
+	_, err := rdb.Pipelined(reqCtx, func(pipe redis.Pipeliner) error {
+		for i, name := range names {
+			types[i] = pipe.Type(reqCtx, name)
+			ttls[i] = pipe.PTTL(reqCtx, name)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error inspecting keys: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to inspect stream"})
+		return
+	}
+	keys := map[string]*DebugKey{}
+	sizes := map[string]*redis.IntCmd{}
+	_, err = rdb.Pipelined(reqCtx, func(pipe redis.Pipeliner) error {
+		for i, name := range names {
+			t := types[i].Val()
+			if t == "none" {
+				continue
+			}
+			ttl := int64(-1)
+			if d := ttls[i].Val(); d > 0 {
+				ttl = d.Milliseconds()
+			}
+			keys[name] = &DebugKey{Type: t, TTL: ttl}
+			switch t {
+			case "string":
+				sizes[name] = pipe.StrLen(reqCtx, name)
+			case "hash":
+				sizes[name] = pipe.HLen(reqCtx, name)
+			case "set":
+				sizes[name] = pipe.SCard(reqCtx, name)
+			case "zset":
+				sizes[name] = pipe.ZCard(reqCtx, name)
+			case "list":
+				sizes[name] = pipe.LLen(reqCtx, name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error inspecting keys: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to inspect stream"})
+		return
+	}
+	for name, cmd := range sizes {
+		keys[name].Size = cmd.Val()
+	}
+
+	size := func(name string) int64 {
+		if k := keys[name]; k != nil {
+			return k.Size
+		}
+		return 0
+	}
+	resp := gin.H{
+		"stream_id":   streamID,
+		"index_size":  size(commentIndexKey(streamID)),
+		"data_size":   size(commentDataKey(streamID)),
+		"online_size": size(onlineSetKey(streamID)),
+		"keys":        keys,
+		"patterns":    matched,
+	}
+	if v, err := rdb.Get(reqCtx, allowCommentsKey(streamID)).Result(); err == nil {
+		resp["allow_comments"] = v
+	}
+	if q, err := activeFeaturedQuestion(reqCtx, streamID); err == nil && q != nil {
+		resp["pinned"] = q
+	}
+	if modes, err := rdb.HGetAll(reqCtx, modesKey(streamID)).Result(); err == nil && len(modes) > 0 {
+		resp["modes"] = modes
+	}
+	c.JSON(200, resp)
+}
+
+// resetStream deletes every key the stream holds in one transaction, leaving
+// it as if it had never been used. Ephemeral comments' expiry entries are
+// dropped by the sweeper.
+func resetStream(c *gin.Context) {
+	streamID, ok := parseStreamID(c)
+	if !ok {
+		return
+	}
+	reqCtx := c.Request.Context()
+	names := streamStateKeys(streamID)
+	for _, pattern := range streamStatePatterns(streamID) {
+		found, err := scanKeys(reqCtx, pattern, maxDebugScan)
+		if err != nil {
+			log.Printf("[GO] Stream %d: Error scanning keys: %v", streamID, err)
+			c.JSON(500, gin.H{"error": "failed to reset stream"})
+			return
+		}
+		names = append(names, found...)
+	}
+
+	var deleted *redis.IntCmd
+	member := strconv.FormatInt(streamID, 10)
+	_, err := rdb.TxPipelined(reqCtx, func(pipe redis.Pipeliner) error {
+		deleted = pipe.Del(reqCtx, names...)
+		pipe.ZRem(reqCtx, activeStreamsKey(), member)
+		pipe.HDel(reqCtx, leavingStreamsKey(), member)
+		pipe.ZRem(reqCtx, streamActivityKey(), member)
+		pipe.SRem(reqCtx, bufferedStreamsKey(), streamID)
+		return nil
+	})
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error resetting stream: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to reset stream"})
+		return
+	}
+	log.Printf("[GO] Stream %d: Reset by an admin (%d keys)", streamID, deleted.Val())
+	auditRequest(c, streamID, "reset", nil, "", map[string]interface{}{"keys": deleted.Val()})
+	c.JSON(200, gin.H{"success": true, "deleted": deleted.Val()})
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// withDebugEndpoints routes the debug endpoints for the rest of the test
+func withDebugEndpoints(t *testing.T) {
+	t.Helper()
+	setVar(t, &debugEndpoints, true)
+	setVar(t, &testRouter, newRouter())
+}
+
+func TestDebugEndpointsGated(t *testing.T) {
+	resetRedis(t)
+	expectStatus(t, request(t, http.MethodGet, "/stream/1/debug", nil, asRole(roleAdmin)...), 404)
+
+	withDebugEndpoints(t)
+	expectStatus(t, request(t, http.MethodGet, "/stream/1/debug", nil, "X-Viewer-Role", roleAdmin), 401)
+	expectStatus(t, request(t, http.MethodGet, "/stream/1/debug", nil, asRole(roleModerator)...), 403)
+	expectStatus(t, request(t, http.MethodPost, "/stream/1/reset", nil, trusted...), 403)
+	expectStatus(t, request(t, http.MethodGet, "/stream/1/debug", nil, asRole(roleAdmin)...), 200)
+}
+
+func TestStreamDebugInspect(t *testing.T) {
+	resetRedis(t)
+	withDebugEndpoints(t)
+	saveAt(t, time.Now().Add(-time.Minute).UnixMilli(), 1, 2)
+	rdb.HSet(ctx, modesKey(1), "slow_mode", "5")
+	rdb.Set(ctx, delayKey(1), 5, time.Minute)
+	rdb.HSet(ctx, emotePackKey(1, "hype"), "pog", "https://cdn.example.com/pog.png")
+
+	w := request(t, http.MethodGet, "/stream/1/debug", nil, asRole(roleAdmin)...)
+	expectStatus(t, w, 200)
+	resp := decode(t, w)
+	if resp["index_size"] != float64(2) || resp["data_size"] != float64(2) {
+		t.Fatalf("index/data size = %v/%v, want 2/2", resp["index_size"], resp["data_size"])
+	}
+	if modes, _ := resp["modes"].(map[string]interface{}); modes["slow_mode"] != "5" {
+		t.Fatalf("modes = %v, want slow_mode 5", resp["modes"])
+	}
+	keys := resp["keys"].(map[string]interface{})
+	modes, _ := keys[modesKey(1)].(map[string]interface{})
+	if modes["type"] != "hash" || modes["size"] != float64(1) || modes["ttl_ms"] != float64(-1) {
+		t.Fatalf("modes key = %v, want a 1-field hash without expiry", modes)
+	}
+	delay, _ := keys[delayKey(1)].(map[string]interface{})
+	if ttl, _ := delay["ttl_ms"].(float64); delay["type"] != "string" || ttl <= 0 || ttl > 60000 {
+		t.Fatalf("delay key = %v, want a string expiring within a minute", delay)
+	}
+	if keys[emotePackKey(1, "hype")] == nil {
+		t.Fatalf("keys = %v, want the emote pack matched by pattern", keys)
+	}
+}
+
+func TestStreamReset(t *testing.T) {
+	resetRedis(t)
+	withDebugEndpoints(t)
+	saveAt(t, time.Now().Add(-time.Minute).UnixMilli(), 1, 2)
+	rdb.HSet(ctx, modesKey(1), "slow_mode", "5")
+	rdb.HSet(ctx, emotePackKey(1, "hype"), "pog", "https://cdn.example.com/pog.png")
+	rdb.ZAdd(ctx, activeStreamsKey(), &redis.Z{Score: 1, Member: "1"})
+	rdb.HSet(ctx, modesKey(2), "slow_mode", "5")
+
+	w := request(t, http.MethodPost, "/stream/1/reset", nil, asRole(roleAdmin)...)
+	expectStatus(t, w, 200)
+	if deleted, _ := decode(t, w)["deleted"].(float64); deleted < 4 {
+		t.Fatalf("deleted = %v, want the index, data, modes and pack at least", deleted)
+	}
+	for _, name := range []string{commentIndexKey(1), commentDataKey(1), modesKey(1), emotePackKey(1, "hype")} {
+		if testRedis.Exists(name) {
+			t.Fatalf("%s survived the reset", name)
+		}
+	}
+	if testRedis.Exists(activeStreamsKey()) {
+		t.Fatal("stream still in the active set")
+	}
+	if !testRedis.Exists(modesKey(2)) {
+		t.Fatal("another stream's keys were reset")
+	}
+	if got := messages(poll(t, 1, "v1", 0)); len(got) != 0 {
+		t.Fatalf("feed after reset = %v, want it empty", got)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Copypasta floods the feed with the same message from many viewers. Streams
+// that set dedup_window in their modes (or everyone, with DEDUP_WINDOW)
+// collapse an identical message posted again within that many seconds into
+// the comment that started it: instead of publishing a copy, the original's
+// multiplier becomes the number of different viewers who posted it and goes
+// out to live clients as an edit, so the feed shows one "x42" comment. Every
+// repeat keeps the window open. Messages compare case- and
+// whitespace-insensitively. Quotes, ephemeral comments and privileged
+// roles' comments are never collapsed.
+var dedupWindow time.Duration
+
+func loadDedupConfig() {
+	dedupWindow = time.Duration(envInt("DEDUP_WINDOW", 0)) * time.Second
+}
+
+// messageHash identifies a message for deduplication. Comments gated to
+// different tiers never collapse into each other.
+func messageHash(message, minTier string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(message)), " ")
+	sum := sha256.Sum256([]byte(minTier + "\x00" + normalized))
+	return hex.EncodeToString(sum[:8])
+}
+
+// collapseScript counts a poster against the comment a message hash points
+// to. KEYS: dupe hash. ARGV: poster, window (ms). Returns nil when the
+// message hasn't been posted within the window, otherwise the comment ID and
+// how many different viewers posted it.
+var collapseScript = redis.NewScript(`
+local id = redis.call('HGET', KEYS[1], 'id')
+if not id then
+	return false
+end
+redis.call('HSETNX', KEYS[1], 'u:' .. ARGV[1], 1)
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return {id, redis.call('HLEN', KEYS[1]) - 1}
+`)
+
+// collapseDuplicate folds a repeated message into the comment it repeats and
+// returns that comment, or nil when the message must be published
+func collapseDuplicate(ctx context.Context, streamID int64, poster, message, minTier string, window time.Duration) (*Comment, error) {
+	key := dupesKey(streamID, messageHash(message, minTier))
+	res, err := collapseScript.Run(ctx, rdb, []string{key}, poster, window.Milliseconds()).Slice()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil || len(res) != 2 {
+		return nil, err
+	}
+	id, _ := res[0].(string)
+	posters, _ := res[1].(int64)
+
+	raw, err := rdb.HGet(ctx, commentDataKey(streamID), id).Result()
+	if err == redis.Nil {
+		// The original was deleted: this one starts over
+		return nil, rdb.Del(ctx, key).Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cmt Comment
+	if err := json.Unmarshal([]byte(raw), &cmt); err != nil || isExpired(cmt, time.Now().UnixMilli()) {
+		return nil, rdb.Del(ctx, key).Err()
+	}
+	cmt.collapsed = true
+	if int(posters) > cmt.Multiplier && posters > 1 {
+		cmt.Multiplier = int(posters)
+		edited := []Comment{cmt}
+		if _, err := editComments(ctx, streamID, edited); err != nil {
+			return nil, err
+		}
+		cmt = edited[0]
+	}
+	return &cmt, nil
+}
+
+// registerDuplicate makes a published comment the one later copies of its
+// message collapse into
+func registerDuplicate(ctx context.Context, streamID int64, poster string, cmt *Comment, window time.Duration) error {
+	key := dupesKey(streamID, messageHash(cmt.Message, cmt.MinTier))
+	_, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSetNX(ctx, key, "id", strconv.FormatInt(cmt.ID, 10))
+		pipe.HSetNX(ctx, key, "u:"+poster, 1)
+		pipe.PExpire(ctx, key, window)
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCopypastaCollapsesWithACount(t *testing.T) {
+	resetRedis(t)
+	rdb.HSet(ctx, modesKey(1), "dedup_window", "30")
+
+	original := postedID(t, 1, "v1", "user1", "Copy  pasta")
+	for i, msg := range []string{"copy pasta", "COPY PASTA", " copy pasta "} {
+		w := post(t, 1, fmt.Sprintf("v%d", i+2), fmt.Sprintf("user%d", i+2), msg)
+		expectStatus(t, w, 200)
+		resp := decode(t, w)
+		cmt := resp["comment"].(map[string]interface{})
+		if resp["collapsed"] != true || cmt["id"] != float64(original) || cmt["multiplier"] != float64(i+2) {
+			t.Fatalf("copy %d = %v, want collapsed into %d x%d", i+1, resp, original, i+2)
+		}
+	}
+	// A viewer repeating themselves doesn't count twice
+	w := post(t, 1, "v2", "user2", "copy pasta")
+	expectStatus(t, w, 200)
+	if cmt := decode(t, w)["comment"].(map[string]interface{}); cmt["multiplier"] != float64(4) {
+		t.Fatalf("repeat by the same viewer: multiplier %v, want 4", cmt["multiplier"])
+	}
+	expectStatus(t, post(t, 1, "v5", "user5", "something else"), 200)
+	nextSecond()
+
+	list := poll(t, 1, "v9", 0)["comments"].([]interface{})
+	if len(list) != 2 {
+		t.Fatalf("feed = %d comments, want the collapsed one and the other", len(list))
+	}
+	if cmt := list[0].(map[string]interface{}); cmt["message"] != "Copy  pasta" || cmt["multiplier"] != float64(4) {
+		t.Fatalf("collapsed comment = %v, want the original x4", cmt)
+	}
+}
+
+func TestCopypastaWindowAndExemptions(t *testing.T) {
+	resetRedis(t)
+	rdb.HSet(ctx, modesKey(1), "dedup_window", "30")
+	first := postedID(t, 1, "v1", "user1", "pasta")
+
+	// Moderators' comments are never collapsed
+	w := post(t, 1, "mod", "mod", "pasta", asRole(roleModerator)...)
+	expectStatus(t, w, 200)
+	if resp := decode(t, w); resp["collapsed"] != nil || resp["comment"].(map[string]interface{})["id"] == float64(first) {
+		t.Fatalf("moderator comment = %v, want it published", resp)
+	}
+	testRedis.FastForward(31 * time.Second)
+	if id := postedID(t, 1, "v2", "user2", "pasta"); id == first {
+		t.Fatal("copy after the window collapsed")
+	}
+
+	// Streams that don't opt in publish every copy
+	resetRedis(t)
+	first = postedID(t, 1, "v1", "user1", "pasta")
+	if id := postedID(t, 1, "v2", "user2", "pasta"); id == first {
+		t.Fatal("copy collapsed without dedup_window")
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+// Digest mode is for constrained clients (smart TVs, low-end phones): instead
+// of every new comment, check-update returns how many there were and only the
+// newest DIGEST_LATEST of them. The cursor still moves past all of them, so
+// digest polls keep up with the feed; a client that wants the skipped
+// comments polls once without digest using Digest.Since as last_id.
+var digestLatest int
+
+func loadDigestConfig() {
+	digestLatest = envInt("DIGEST_LATEST", 2)
+	if digestLatest < 1 {
+		digestLatest = 1
+	}
+}
+
+// CommentDigest summarizes the comments a digest response left out
+type CommentDigest struct {
+	Count int   `json:"count"` // new comments, including the ones returned
+	Since int64 `json:"since"` // last_id to fetch them all from
+}
+
+// digestComments keeps the newest digestLatest of chronologically ordered
+// comments
+func digestComments(comments []Comment) []Comment {
+	if len(comments) <= digestLatest {
+		return comments
+	}
+	return comments[len(comments)-digestLatest:]
+}
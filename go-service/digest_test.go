@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDigestVersusFullOutput(t *testing.T) {
+	resetRedis(t)
+	for i := 1; i <= 5; i++ {
+		expectStatus(t, post(t, 1, "v1", "alice", fmt.Sprintf("m%d", i)), 200)
+	}
+	nextSecond()
+
+	full := poll(t, 1, "v2", 0)
+	w := request(t, http.MethodPost, "/check-update?digest=true", map[string]interface{}{
+		"stream_id": 1, "viewer_id": "v2", "last_id": 0,
+	})
+	expectStatus(t, w, 200)
+	digest := decode(t, w)
+
+	if got := strings.Join(messages(full), " "); got != "m1 m2 m3 m4 m5" {
+		t.Fatalf("full = %q", got)
+	}
+	if _, ok := full["digest"]; ok {
+		t.Fatal("full response has a digest")
+	}
+	if got := strings.Join(messages(digest), " "); got != "m4 m5" {
+		t.Fatalf("digest = %q, want the newest %d", got, digestLatest)
+	}
+	summary := digest["digest"].(map[string]interface{})
+	if summary["count"] != float64(5) || summary["since"] != float64(0) {
+		t.Fatalf("digest summary = %v, want count 5 since 0", summary)
+	}
+	if digest["cursor"] != full["cursor"] {
+		t.Fatalf("digest cursor = %v, want %v like the full response", digest["cursor"], full["cursor"])
+	}
+
+	// The skipped comments are still there from since, and nothing is left
+	// past the cursor
+	if got := strings.Join(messages(poll(t, 1, "v2", int64(summary["since"].(float64)))), " "); got != "m1 m2 m3 m4 m5" {
+		t.Fatalf("fetching the skipped comments = %q", got)
+	}
+	if got := messages(poll(t, 1, "v2", int64(digest["cursor"].(float64)))); len(got) != 0 {
+		t.Fatalf("after the digest cursor = %v, want nothing", got)
+	}
+}
+
+func TestDigestWithFewComments(t *testing.T) {
+	resetRedis(t)
+	expectStatus(t, post(t, 1, "v1", "alice", "only"), 200)
+	nextSecond()
+
+	w := request(t, http.MethodPost, "/check-update", map[string]interface{}{
+		"stream_id": 1, "viewer_id": "v2", "last_id": 0, "digest": true,
+	})
+	expectStatus(t, w, 200)
+	resp := decode(t, w)
+	if got := messages(resp); len(got) != 1 || got[0] != "only" {
+		t.Fatalf("digest = %v, want [only]", got)
+	}
+	if summary := resp["digest"].(map[string]interface{}); summary["count"] != float64(1) {
+		t.Fatalf("digest count = %v, want 1", summary["count"])
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Trusted domains are the hosts the service accepts links and media from,
+// such as custom emote images. Every feature that checks a host goes through
+// trustedDomains so they share one policy. TRUSTED_DOMAINS lists them
+// (comma-separated); the backend can add more through the
+// service:trusted_domains set and bumps service:trusted_domains:version so
+// every replica reloads. "*.example.com" matches any subdomain of
+// example.com but not example.com itself. With no domains configured every
+// host is trusted.
+type domainRegistry struct {
+	mu       sync.RWMutex
+	exact    map[string]bool
+	suffixes []string // ".example.com" for *.example.com
+	version  string
+}
+
+var trustedDomains = &domainRegistry{exact: map[string]bool{}}
+
+// configuredTrustedDomains is TRUSTED_DOMAINS, kept for reloads
+var configuredTrustedDomains []string
+
+func loadTrustedDomainsConfig() {
+	configuredTrustedDomains = strings.Split(envString("TRUSTED_DOMAINS", ""), ",")
+	trustedDomains.reload(ctx)
+}
+
+// normalizeHost lowercases a host and drops its port and trailing dot
+func normalizeHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(host, ".")
+}
+
+// reload rebuilds the registry from TRUSTED_DOMAINS and the Redis set
+func (d *domainRegistry) reload(ctx context.Context) {
+	version, err := rdb.Get(ctx, trustedDomainsVersionKey()).Result()
+	if err != nil && err != redis.Nil {
+		log.Printf("[GO] Error checking trusted domains: %v", err)
+		return
+	}
+	extra, err := rdb.SMembers(ctx, trustedDomainsKey()).Result()
+	if err != nil {
+		log.Printf("[GO] Error loading trusted domains: %v", err)
+		return
+	}
+
+	exact := map[string]bool{}
+	var suffixes []string
+	for _, entry := range append(append([]string{}, configuredTrustedDomains...), extra...) {
+		entry = normalizeHost(entry)
+		switch {
+		case entry == "":
+		case strings.HasPrefix(entry, "*."):
+			suffixes = append(suffixes, entry[1:])
+		default:
+			exact[entry] = true
+		}
+	}
+
+	d.mu.Lock()
+	d.exact, d.suffixes, d.version = exact, suffixes, version
+	d.mu.Unlock()
+	if n := len(exact) + len(suffixes); n > 0 {
+		log.Printf("[GO] Trusted domains loaded: %d entries", n)
+	}
+}
+
+// refreshIfChanged reloads the registry when its version has moved
+func (d *domainRegistry) refreshIfChanged(ctx context.Context) {
+	version, err := rdb.Get(ctx, trustedDomainsVersionKey()).Result()
+	if err != nil && err != redis.Nil {
+		return
+	}
+	d.mu.RLock()
+	changed := version != d.version
+	d.mu.RUnlock()
+	if changed {
+		d.reload(ctx)
+	}
+}
+
+// runTrustedDomainsRefresher polls for registry changes
+func runTrustedDomainsRefresher(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	jobs.setRunning("trusted_domains_refresher", true)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			trustedDomains.refreshIfChanged(ctx)
+		}
+	}
+}
+
+// trustsHost reports whether host is a trusted domain
+func (d *domainRegistry) trustsHost(host string) bool {
+	host = normalizeHost(host)
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.empty() {
+		return true
+	}
+	if host == "" {
+		return false
+	}
+	if d.exact[host] {
+		return true
+	}
+	for _, suffix := range d.suffixes {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// empty reports whether no domains are configured; callers hold d.mu
+func (d *domainRegistry) empty() bool {
+	return len(d.exact) == 0 && len(d.suffixes) == 0
+}
+
+// trustsURL reports whether raw is an absolute http(s) URL on a trusted
+// domain. Any URL passes while no domains are configured.
+func (d *domainRegistry) trustsURL(raw string) bool {
+	d.mu.RLock()
+	unrestricted := d.empty()
+	d.mu.RUnlock()
+	if unrestricted {
+		return true
+	}
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.User != nil {
+		return false
+	}
+	return d.trustsHost(u.Host)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+)
+
+// withTrustedDomains configures TRUSTED_DOMAINS for the rest of a test
+func withTrustedDomains(t *testing.T, domains ...string) {
+	t.Helper()
+	// Cleanups run last first, so this reload sees the restored list
+	t.Cleanup(func() { trustedDomains.reload(ctx) })
+	setVar(t, &configuredTrustedDomains, domains)
+	trustedDomains.reload(ctx)
+}
+
+func TestTrustedDomainMatching(t *testing.T) {
+	resetRedis(t)
+	withTrustedDomains(t, "cdn.example.com", "*.images.example.org")
+
+	for url, want := range map[string]bool{
+		"https://cdn.example.com/a.png":         true,
+		"https://CDN.Example.com:443/a.png":     true,
+		"http://cdn.example.com./a.png":         true,
+		"https://eu.images.example.org/a.png":   true,
+		"https://a.b.images.example.org/a.png":  true,
+		"https://images.example.org/a.png":      false, // the wildcard is for subdomains only
+		"https://evil-cdn.example.com/a.png":    false,
+		"https://cdn.example.com.evil.io/a.png": false,
+		"https://example.com/a.png":             false,
+		"ftp://cdn.example.com/a.png":           false,
+		"https://user@cdn.example.com/a.png":    false,
+		"/relative/a.png":                       false,
+	} {
+		if got := trustedDomains.trustsURL(url); got != want {
+			t.Errorf("trustsURL(%q) = %v, want %v", url, got, want)
+		}
+	}
+}
+
+func TestTrustedDomainsFromRedis(t *testing.T) {
+	resetRedis(t)
+	withTrustedDomains(t, "cdn.example.com")
+	rdb.SAdd(ctx, trustedDomainsKey(), "media.example.net")
+	trustedDomains.refreshIfChanged(ctx)
+	if trustedDomains.trustsHost("media.example.net") {
+		t.Fatal("picked up a domain without a version bump")
+	}
+	rdb.Incr(ctx, trustedDomainsVersionKey())
+	trustedDomains.refreshIfChanged(ctx)
+	if !trustedDomains.trustsHost("media.example.net") || !trustedDomains.trustsHost("cdn.example.com") {
+		t.Fatal("the registry doesn't hold both the configured and the added domain")
+	}
+}
+
+func TestUntrustedEmotesAreDropped(t *testing.T) {
+	resetRedis(t)
+	withTrustedDomains(t, "*.example.com")
+	rdb.HSet(ctx, emotesKey(1), "good", "https://cdn.example.com/good.png", "bad", "https://tracker.example.net/bad.png")
+
+	emotes, err := loadStreamEmotes(ctx, 1)
+	if err != nil || len(emotes) != 1 || emotes["good"] == "" {
+		t.Fatalf("emotes = %v, %v; want only good", emotes, err)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Drip mode paces a stream's feed for giveaways and the like: viewers are
+// shown at most drip_count new comments per drip_interval_ms (DRIP_INTERVAL_MS
+// by default), one batch after another, however fast they arrive. Comments
+// are only held back, never dropped. The pace is kept server-side as a
+// reveal horizon shared by every viewer and replica: comments at or before it
+// are visible, and each interval it moves past the next batch. A batch that
+// would end inside a millisecond stops before it, unless the millisecond
+// alone fills it. The horizon starts at the current feed, so turning drip on
+// doesn't hide what viewers already saw, and is forgotten once nobody has
+// polled for dripStateTTL, releasing anything still held. Moderators see the
+// undripped feed, like the chat delay.
+var dripInterval time.Duration
+
+// dripStateTTL is how long a stream's horizon outlives its last poll
+const dripStateTTL = time.Minute
+
+func loadDripConfig() {
+	dripInterval = time.Duration(envInt("DRIP_INTERVAL_MS", 1000)) * time.Millisecond
+	if dripInterval <= 0 {
+		dripInterval = time.Second
+	}
+}
+
+// dripPolicy is a stream's drip mode setting, off when Count is 0
+type dripPolicy struct {
+	Count    int
+	Interval time.Duration
+}
+
+// parseDripPolicy reads a stream's drip settings from its modes
+func parseDripPolicy(fields map[string]string) dripPolicy {
+	policy := dripPolicy{Interval: dripInterval}
+	if v, err := strconv.Atoi(fields["drip_count"]); err == nil && v > 0 {
+		policy.Count = v
+	}
+	if v, err := strconv.Atoi(fields["drip_interval_ms"]); err == nil && v > 0 {
+		policy.Interval = time.Duration(v) * time.Millisecond
+	}
+	return policy
+}
+
+// DripInfo tells the client the feed is paced
+type DripInfo struct {
+	Count      int   `json:"count"` // comments revealed per interval
+	IntervalMs int64 `json:"interval_ms"`
+	Held       int64 `json:"held"` // comments waiting to be revealed
+}
+
+// dripScript advances a stream's reveal horizon by at most one batch per
+// interval. KEYS: drip state hash, comment index. ARGV: max score, now (ms),
+// interval (ms), batch size, ttl (s). Returns the horizon and how many
+// comments are still held behind it.
+var dripScript = redis.NewScript(`
+local max, now = tonumber(ARGV[1]), tonumber(ARGV[2])
+local state = redis.call('HMGET', KEYS[1], 'horizon', 'at')
+local horizon, at = tonumber(state[1]), tonumber(state[2])
+if not horizon then
+	horizon, at = max, now
+	redis.call('HSET', KEYS[1], 'horizon', horizon, 'at', at)
+elseif now - at >= tonumber(ARGV[3]) then
+	local count = tonumber(ARGV[4])
+	local batch = redis.call('ZRANGEBYSCORE', KEYS[2], '(' .. horizon, max, 'WITHSCORES', 'LIMIT', 0, count + 1)
+	local n = #batch / 2
+	if n > 0 then
+		local last = tonumber(batch[math.min(n, count) * 2])
+		if n > count and tonumber(batch[n * 2]) == last and tonumber(batch[2]) < last then
+			last = last - 1
+		end
+		horizon = last
+		redis.call('HSET', KEYS[1], 'horizon', horizon, 'at', now)
+	end
+end
+redis.call('EXPIRE', KEYS[1], ARGV[5])
+return {horizon, redis.call('ZCOUNT', KEYS[2], '(' .. horizon, max)}
+`)
+
+// dripFeedQuery caps a feed query at the stream's reveal horizon. Like
+// pageFeedQuery it applies the chat delay itself, since the horizon is
+// measured against the delayed feed, and returns the delay it applied. The
+// returned info is nil when the horizon couldn't be read, which fails open.
+func dripFeedQuery(ctx context.Context, q *feedQuery, policy dripPolicy) (*DripInfo, int) {
+	delay := 0
+	if q.ApplyDelay {
+		if v, err := rdb.Get(ctx, delayKey(q.StreamID)).Int(); err == nil && v > 0 {
+			delay = v
+			q.Max -= int64(delay) * 1000
+		}
+		q.ApplyDelay = false
+	}
+	res, err := dripScript.Run(ctx, rdb, []string{dripKey(q.StreamID), commentIndexKey(q.StreamID)},
+		q.Max, time.Now().UnixMilli(), policy.Interval.Milliseconds(), policy.Count,
+		int(dripStateTTL.Seconds())).Int64Slice()
+	if err != nil || len(res) != 2 {
+		log.Printf("[GO] Stream %d: Error advancing drip horizon: %v", q.StreamID, err)
+		return nil, delay
+	}
+	if res[0] < q.Max {
+		q.Max = res[0]
+	}
+	return &DripInfo{Count: policy.Count, IntervalMs: policy.Interval.Milliseconds(), Held: res[1]}, delay
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// dripHeld reads how many comments a poll response says are held back
+func dripHeld(t *testing.T, resp map[string]interface{}) float64 {
+	t.Helper()
+	info, ok := resp["drip"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("drip = %v, want drip info", resp["drip"])
+	}
+	return info["held"].(float64)
+}
+
+// startDrip polls stream 1 once to place its reveal horizon and returns a
+// timestamp past it for comments that should be dripped
+func startDrip(t *testing.T) int64 {
+	t.Helper()
+	poll(t, 1, "v2", 0)
+	return time.Now().UnixMilli()
+}
+
+func TestDripPacesTheFeed(t *testing.T) {
+	resetRedis(t)
+	rdb.HSet(ctx, modesKey(1), "drip_count", "2", "drip_interval_ms", "50")
+	// The horizon starts at the current feed, so m1 is never held
+	saveAt(t, time.Now().Add(-time.Minute).UnixMilli(), 1)
+	ts := startDrip(t)
+	for i := int64(2); i <= 6; i++ {
+		saveAt(t, ts+i, i)
+	}
+	nextSecond()
+
+	for _, want := range []string{"m1 m2 m3", "m1 m2 m3 m4 m5", "m1 m2 m3 m4 m5 m6"} {
+		time.Sleep(60 * time.Millisecond)
+		if got := strings.Join(messages(poll(t, 1, "v2", 0)), " "); got != want {
+			t.Fatalf("paced poll = %q, want %q", got, want)
+		}
+		if want == "m1 m2 m3" {
+			// The next batch waits for the interval
+			resp := poll(t, 1, "v2", 0)
+			if got := strings.Join(messages(resp), " "); got != want || dripHeld(t, resp) != 3 {
+				t.Fatalf("within the interval = %q held %v, want %q with 3 held", got, dripHeld(t, resp), want)
+			}
+		}
+	}
+	if resp := poll(t, 1, "v2", 0); dripHeld(t, resp) != 0 {
+		t.Fatalf("held = %v after the last batch, want 0", dripHeld(t, resp))
+	}
+}
+
+func TestDripKeepsMillisecondsWhole(t *testing.T) {
+	resetRedis(t)
+	rdb.HSet(ctx, modesKey(1), "drip_count", "2", "drip_interval_ms", "50")
+	ts := startDrip(t)
+	saveAt(t, ts+1, 1)
+	saveAt(t, ts+2, 2, 3)
+	saveAt(t, ts+3, 4, 5, 6)
+	nextSecond()
+
+	// A batch ending inside a millisecond stops before it; a millisecond
+	// that alone overfills the batch is revealed whole
+	for _, want := range []int{1, 3, 6} {
+		time.Sleep(60 * time.Millisecond)
+		if got := messages(poll(t, 1, "v2", 0)); len(got) != want {
+			t.Fatalf("revealed %v, want %d comments", got, want)
+		}
+	}
+}
+
+func TestDripSkipsModeratorsAndExpires(t *testing.T) {
+	resetRedis(t)
+	rdb.HSet(ctx, modesKey(1), "drip_count", "1", "drip_interval_ms", "60000")
+	ts := startDrip(t)
+	saveAt(t, ts+1, 1)
+	saveAt(t, ts+2, 2)
+	nextSecond()
+
+	if got := messages(poll(t, 1, "v2", 0)); len(got) != 0 {
+		t.Fatalf("viewer feed = %v, want everything held", got)
+	}
+	resp := poll(t, 1, "mod", 0, asRole(roleModerator)...)
+	if got := messages(resp); len(got) != 2 || resp["drip"] != nil {
+		t.Fatalf("moderator feed = %v drip %v, want the undripped feed", got, resp["drip"])
+	}
+
+	// Once nobody polls, the horizon is forgotten and nothing stays held
+	testRedis.FastForward(dripStateTTL)
+	if got := messages(poll(t, 1, "v2", 0)); len(got) != 2 {
+		t.Fatalf("feed after the horizon expired = %v, want both released", got)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Edits rewrite comments that were already published. The new version
+// replaces the stored one and goes into the stream's edit log, from which
+// live feeds push it ("edits" messages on WebSocket and SSE) and check-update
+// returns it as edits when the poll sends edits_since (the server_time of its
+// previous response). Clients replace the comment with the same ID. The log
+// only covers the last editLogRetention; clients gone longer than that
+// reload the feed anyway.
+const editLogRetention = 10 * time.Minute
+
+// Results of applying one edit
+const (
+	editApplied  = 1
+	editMissing  = 0  // the comment was deleted meanwhile
+	editConflict = -1 // someone else edited it since it was read
+)
+
+// editScript replaces a comment's data if it is still there at the version
+// the edit was made from (see versions.go). It runs inside a transaction, so
+// it is sent whole (EVAL) rather than by SHA.
+// KEYS: data hash. ARGV: comment id, new payload, expected version.
+var editScript = redis.NewScript(`
+local current = redis.call('HGET', KEYS[1], ARGV[1])
+if not current then
+	return 0
+end
+local ok, stored = pcall(cjson.decode, current)
+local version = 0
+if ok and type(stored) == 'table' and type(stored.version) == 'number' then
+	version = stored.version
+end
+if version ~= tonumber(ARGV[3]) then
+	return -1
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+return 1
+`)
+
+// editComments stores new versions of comments, stamping them with the edit
+// time, and tells live feeds. It returns how many were still there, unedited
+// by anyone else since they were read, to edit.
+func editComments(ctx context.Context, streamID int64, edited []Comment) (int, error) {
+	results, err := applyEdits(ctx, streamID, edited)
+	applied := 0
+	for _, result := range results {
+		if result == editApplied {
+			applied++
+		}
+	}
+	return applied, err
+}
+
+// applyEdits is editComments reporting each edit's result. Every edit bumps
+// its comment's version.
+func applyEdits(ctx context.Context, streamID int64, edited []Comment) ([]int64, error) {
+	if len(edited) == 0 {
+		return nil, nil
+	}
+	now := time.Now().UnixMilli()
+	cmds := make([]*redis.Cmd, len(edited))
+	_, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i := range edited {
+			expected := edited[i].Version
+			edited[i].EditedAt = now
+			edited[i].Version++
+			payload, err := json.Marshal(edited[i])
+			if err != nil {
+				return fmt.Errorf("encode comment %d: %w", edited[i].ID, err)
+			}
+			id := strconv.FormatInt(edited[i].ID, 10)
+			cmds[i] = editScript.Eval(ctx, pipe, []string{commentDataKey(streamID)}, id, payload, expected)
+			pipe.ZAdd(ctx, editsKey(streamID), &redis.Z{Score: float64(now), Member: id})
+		}
+		pipe.ZRemRangeByScore(ctx, editsKey(streamID), "-inf", strconv.FormatInt(now-editLogRetention.Milliseconds(), 10))
+		pipe.Expire(ctx, editsKey(streamID), editLogRetention)
+		invalidateReplayTimelines(ctx, pipe, streamID)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	results := make([]int64, len(cmds))
+	for i, cmd := range cmds {
+		results[i], _ = cmd.Int64()
+	}
+	notifyLive(ctx, streamID)
+	return results, nil
+}
+
+// loadEdits returns the current version of comments edited after since (ms)
+// and the time of the newest edit, since when there is none
+func loadEdits(ctx context.Context, streamID, since int64) ([]Comment, int64, error) {
+	entries, err := rdb.ZRangeByScoreWithScores(ctx, editsKey(streamID), &redis.ZRangeBy{
+		Min: "(" + strconv.FormatInt(since, 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil || len(entries) == 0 {
+		return nil, since, err
+	}
+	ids := make([]string, len(entries))
+	for i, z := range entries {
+		ids[i] = z.Member.(string)
+	}
+	newest := int64(entries[len(entries)-1].Score)
+	data, err := rdb.HMGet(ctx, commentDataKey(streamID), ids...).Result()
+	if err != nil {
+		return nil, since, err
+	}
+	now := time.Now().UnixMilli()
+	var edits []Comment
+	for _, raw := range data {
+		s, ok := raw.(string)
+		if !ok {
+			continue // deleted since, which isn't an edit
+		}
+		var cmt Comment
+		if json.Unmarshal([]byte(s), &cmt) == nil && !isExpired(cmt, now) {
+			edits = append(edits, cmt)
+		}
+	}
+	return edits, newest, nil
+}
+
+// nextEdits returns the edits a live feed hasn't sent yet
+func (r *feedReader) nextEdits(ctx context.Context) []Comment {
+	edits, newest, err := loadEdits(ctx, r.streamID, r.editsSince)
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error loading edits: %v", r.streamID, err)
+		return nil
+	}
+	r.editsSince = newest
+	return withDisplayTime(r.filter.apply(edits), r.zone)
+}
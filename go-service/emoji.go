@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// shortcodePattern matches :name: style shortcodes
+var shortcodePattern = regexp.MustCompile(`:([a-zA-Z0-9_+\-]+):`)
+
+// builtinEmoji maps common shortcodes to their Unicode emoji
+var builtinEmoji = map[string]string{
+	"smile":         "😄",
+	"smiley":        "😃",
+	"grin":          "😁",
+	"joy":           "😂",
+	"rofl":          "🤣",
+	"wink":          "😉",
+	"blush":         "😊",
+	"heart_eyes":    "😍",
+	"kissing_heart": "😘",
+	"thinking":      "🤔",
+	"neutral_face":  "😐",
+	"unamused":      "😒",
+	"cry":           "😢",
+	"sob":           "😭",
+	"angry":         "😠",
+	"rage":          "😡",
+	"scream":        "😱",
+	"sunglasses":    "😎",
+	"sleeping":      "😴",
+	"clap":          "👏",
+	"wave":          "👋",
+	"pray":          "🙏",
+	"ok_hand":       "👌",
+	"muscle":        "💪",
+	"+1":            "👍",
+	"thumbsup":      "👍",
+	"-1":            "👎",
+	"thumbsdown":    "👎",
+	"heart":         "❤️",
+	"broken_heart":  "💔",
+	"fire":          "🔥",
+	"star":          "⭐",
+	"sparkles":      "✨",
+	"tada":          "🎉",
+	"rocket":        "🚀",
+	"100":           "💯",
+	"eyes":          "👀",
+	"rose":          "🌹",
+	"gift":          "🎁",
+	"trophy":        "🏆",
+}
+
+// loadStreamEmotes returns the custom emotes configured for a stream,
+// including its enabled packs (see emotepacks.go), leaving out those whose
+// image isn't on a trusted domain
+func loadStreamEmotes(ctx context.Context, streamID int64) (map[string]string, error) {
+	emotes, err := rdb.HGetAll(ctx, emotesKey(streamID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	packs, err := loadEmotePacks(ctx, streamID, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, pack := range packs {
+		for name, url := range pack.Emotes {
+			if _, ok := emotes[name]; !ok {
+				emotes[name] = url
+			}
+		}
+	}
+	for name, url := range emotes {
+		if !trustedDomains.trustsURL(url) {
+			delete(emotes, name)
+		}
+	}
+	return emotes, nil
+}
+
+// expandShortcodes replaces known Unicode shortcodes in message and collects
+// the custom emotes it references. Custom emotes stay literal in the text so
+// clients can swap them for images; unknown shortcodes are left untouched.
+func expandShortcodes(message string, custom map[string]string) (string, map[string]string) {
+	used := map[string]string{}
+	expanded := shortcodePattern.ReplaceAllStringFunc(message, func(match string) string {
+		name := match[1 : len(match)-1]
+		if url, ok := custom[name]; ok {
+			used[name] = url
+			return match
+		}
+		if emoji, ok := builtinEmoji[name]; ok {
+			return emoji
+		}
+		return match
+	})
+	if len(used) == 0 {
+		used = nil
+	}
+	return expanded, used
+}
+
+// isEmojiRune reports whether r is a pictographic emoji or one of the
+// modifiers (ZWJ, variation selectors, skin tones, keycaps, tags) that
+// combine with one.
+func isEmojiRune(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF: // pictographs, emoticons, flags, skin tones
+		return true
+	case r >= 0x2600 && r <= 0x27BF: // misc symbols, dingbats
+		return true
+	case r >= 0x2300 && r <= 0x23FF: // misc technical
+		return true
+	case r >= 0x2B00 && r <= 0x2BFF: // arrows, stars
+		return true
+	case r >= 0xE0020 && r <= 0xE007F: // tag sequences
+		return true
+	case r == 0x200D, r == 0xFE0E, r == 0xFE0F, r == 0x20E3:
+		return true
+	case r == 0x00A9, r == 0x00AE, r == 0x203C, r == 0x2049, r == 0x2122, r == 0x2139:
+		return true
+	case r == 0x3030, r == 0x303D, r == 0x3297, r == 0x3299:
+		return true
+	}
+	return false
+}
+
+// isEmojiText reports whether s consists only of emoji runes
+func isEmojiText(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !isEmojiRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// isEmoteOnly reports whether every whitespace-separated token of an
+// (already expanded) message is a custom stream emote or Unicode emoji.
+func isEmoteOnly(message string, custom map[string]string) bool {
+	tokens := strings.Fields(message)
+	if len(tokens) == 0 {
+		return false
+	}
+	for _, tok := range tokens {
+		rest := shortcodePattern.ReplaceAllStringFunc(tok, func(match string) string {
+			if _, ok := custom[match[1:len(match)-1]]; ok {
+				return ""
+			}
+			return match
+		})
+		if rest != "" && !isEmojiText(rest) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestExpandShortcodesMixed(t *testing.T) {
+	custom := map[string]string{"pog": "https://cdn.example.com/pog.png"}
+	got, used := expandShortcodes(":fire: :pog: :nope: :wave::pog:", custom)
+	if want := "🔥 :pog: :nope: 👋:pog:"; got != want {
+		t.Fatalf("expanded = %q, want %q", got, want)
+	}
+	if len(used) != 1 || used["pog"] != custom["pog"] {
+		t.Fatalf("emotes = %v, want only pog", used)
+	}
+
+	// A custom emote wins over a builtin of the same name
+	got, used = expandShortcodes(":fire:", map[string]string{"fire": "https://cdn.example.com/fire.png"})
+	if got != ":fire:" || used["fire"] == "" {
+		t.Fatalf("shadowed builtin = %q %v, want it left literal as a custom emote", got, used)
+	}
+	if got, used := expandShortcodes("no codes here: just colons:", nil); got != "no codes here: just colons:" || used != nil {
+		t.Fatalf("plain text = %q %v, want it unchanged", got, used)
+	}
+}
+
+func TestPostedCommentExpandsShortcodes(t *testing.T) {
+	resetRedis(t)
+	rdb.HSet(ctx, emotesKey(1), "pog", "https://cdn.example.com/pog.png")
+
+	w := post(t, 1, "v1", "alice", "gg :tada: :pog: :unknown:")
+	expectStatus(t, w, 200)
+	cmt := decode(t, w)["comment"].(map[string]interface{})
+	if cmt["message"] != "gg 🎉 :pog: :unknown:" {
+		t.Fatalf("message = %q", cmt["message"])
+	}
+	emotes, _ := cmt["emotes"].(map[string]interface{})
+	if len(emotes) != 1 || emotes["pog"] != "https://cdn.example.com/pog.png" {
+		t.Fatalf("emotes = %v, want only pog", cmt["emotes"])
+	}
+}
+
+func TestEmoteOnlyMode(t *testing.T) {
+	resetRedis(t)
+	rdb.HSet(ctx, emotesKey(1), "pog", "https://cdn.example.com/pog.png")
+	rdb.HSet(ctx, modesKey(1), "emote_only", "1")
+
+	for i, msg := range []string{":pog:", ":pog:   :fire: 👍🏽", "❤️ :wave:"} {
+		if w := post(t, 1, fmt.Sprintf("v%d", i), "alice", msg); w.Code != 200 {
+			t.Fatalf("emote-only %q: %d %s", msg, w.Code, w.Body.String())
+		}
+	}
+	for i, msg := range []string{":pog: hello", "hello", ":unknown:", "gg🔥"} {
+		w := post(t, 1, fmt.Sprintf("w%d", i), "bob", msg)
+		if w.Code != 403 || decode(t, w)["reason"] != "emote_only" {
+			t.Fatalf("%q: %d %s, want 403 emote_only", msg, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Walls of emoji are a spam pattern, so streams can cap how many emoji a
+// comment holds (emoji_max_count in their modes, EMOJI_MAX_COUNT by default)
+// and what share of its characters they make up (emoji_max_ratio,
+// EMOJI_MAX_RATIO, between 0 and 1). The ratio only applies to comments of at
+// least EMOJI_RATIO_MIN_LENGTH characters, so a lone emoji reaction passes.
+// 0 turns either limit off. Over the limit, a comment is refused with the
+// limits it broke, or with the trim action (emoji_action, EMOJI_ACTION) loses
+// the emoji past them. An emoji is one grapheme cluster: ZWJ sequences, skin
+// tones, keycaps, flags and tag sequences count once, as does each custom
+// emote. Emote-only mode lifts the limits.
+const (
+	emojiReject = "reject"
+	emojiTrim   = "trim"
+)
+
+// emojiPolicy is a stream's emoji density limits
+type emojiPolicy struct {
+	MaxCount int
+	MaxRatio float64
+	Action   string
+}
+
+var (
+	defaultEmojiPolicy  emojiPolicy
+	emojiRatioMinLength int
+)
+
+func loadEmojiDensityConfig() {
+	defaultEmojiPolicy = emojiPolicy{
+		MaxCount: envInt("EMOJI_MAX_COUNT", 0),
+		MaxRatio: envFloat("EMOJI_MAX_RATIO", 0),
+		Action:   parseEmojiAction(envString("EMOJI_ACTION", emojiReject)),
+	}
+	emojiRatioMinLength = envInt("EMOJI_RATIO_MIN_LENGTH", 5)
+}
+
+func parseEmojiAction(v string) string {
+	if strings.ToLower(strings.TrimSpace(v)) == emojiTrim {
+		return emojiTrim
+	}
+	return emojiReject
+}
+
+// parseEmojiPolicy reads a stream's overrides from its modes
+func parseEmojiPolicy(fields map[string]string) emojiPolicy {
+	policy := defaultEmojiPolicy
+	if v, err := strconv.Atoi(fields["emoji_max_count"]); err == nil && v >= 0 {
+		policy.MaxCount = v
+	}
+	if v, err := strconv.ParseFloat(fields["emoji_max_ratio"], 64); err == nil && v >= 0 && v <= 1 {
+		policy.MaxRatio = v
+	}
+	if v := fields["emoji_action"]; v != "" {
+		policy.Action = parseEmojiAction(v)
+	}
+	return policy
+}
+
+func (p emojiPolicy) enabled() bool {
+	return p.MaxCount > 0 || p.MaxRatio > 0
+}
+
+// limits describes the policy's limits for a rejection
+func (p emojiPolicy) limits() map[string]interface{} {
+	limits := map[string]interface{}{}
+	if p.MaxCount > 0 {
+		limits["max_emoji"] = p.MaxCount
+	}
+	if p.MaxRatio > 0 {
+		limits["max_emoji_ratio"] = p.MaxRatio
+	}
+	return limits
+}
+
+// grapheme is one user-perceived character of a message
+type grapheme struct {
+	text  string
+	emoji bool
+	space bool
+}
+
+// isEmojiModifier reports whether r extends the character before it rather
+// than starting a new one
+func isEmojiModifier(r rune) bool {
+	switch {
+	case r == 0xFE0E, r == 0xFE0F, r == 0x20E3: // variation selectors, keycap
+		return true
+	case r >= 0x1F3FB && r <= 0x1F3FF: // skin tones
+		return true
+	case r >= 0xE0020 && r <= 0xE007F: // tag sequences
+		return true
+	}
+	return unicode.In(r, unicode.Mn, unicode.Me)
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// splitGraphemes splits a message into characters, keeping emoji sequences
+// and custom emotes whole. It covers what emoji need, not every rule of
+// Unicode segmentation.
+func splitGraphemes(message string, custom map[string]string) []grapheme {
+	emotes := map[int]int{}
+	for _, span := range shortcodePattern.FindAllStringSubmatchIndex(message, -1) {
+		if _, ok := custom[message[span[2]:span[3]]]; ok {
+			emotes[span[0]] = span[1]
+		}
+	}
+
+	var out []grapheme
+	joinNext, pairOpen := false, false
+	for pos := 0; pos < len(message); {
+		if end, ok := emotes[pos]; ok {
+			out = append(out, grapheme{text: message[pos:end], emoji: true})
+			pos, joinNext, pairOpen = end, false, false
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(message[pos:])
+		text := message[pos : pos+size]
+		pos += size
+
+		n := len(out)
+		switch {
+		case n > 0 && !out[n-1].space && (joinNext || r == 0x200D || isEmojiModifier(r)):
+			out[n-1].text += text
+			if r == 0x20E3 {
+				out[n-1].emoji = true // keycap: 1️⃣
+			}
+			joinNext = r == 0x200D
+		case pairOpen && isRegionalIndicator(r):
+			out[n-1].text += text // the second half of a flag
+			pairOpen = false
+		default:
+			out = append(out, grapheme{text: text, emoji: isEmojiRune(r) && r != 0x200D, space: unicode.IsSpace(r)})
+			joinNext, pairOpen = false, isRegionalIndicator(r)
+		}
+	}
+	return out
+}
+
+// countGraphemes returns how many emoji and non-space characters there are
+func countGraphemes(graphemes []grapheme) (int, int) {
+	emoji, total := 0, 0
+	for _, g := range graphemes {
+		if g.space {
+			continue
+		}
+		total++
+		if g.emoji {
+			emoji++
+		}
+	}
+	return emoji, total
+}
+
+// allowedEmoji returns how many of a message's emoji the policy allows
+func (p emojiPolicy) allowedEmoji(emoji, total int) int {
+	allowed := emoji
+	if p.MaxCount > 0 && allowed > p.MaxCount {
+		allowed = p.MaxCount
+	}
+	if p.MaxRatio > 0 && total >= emojiRatioMinLength {
+		if p.MaxRatio >= 1 {
+			return allowed
+		}
+		// share = e/(e+others) <= ratio
+		others := float64(total - emoji)
+		if byRatio := int(p.MaxRatio * others / (1 - p.MaxRatio)); byRatio < allowed {
+			allowed = byRatio
+		}
+	}
+	return allowed
+}
+
+// apply checks a message against the policy. It returns the message, trimmed
+// if the policy trims, and false when it must be refused.
+func (p emojiPolicy) apply(message string, custom map[string]string) (string, bool) {
+	if !p.enabled() {
+		return message, true
+	}
+	graphemes := splitGraphemes(message, custom)
+	emoji, total := countGraphemes(graphemes)
+	allowed := p.allowedEmoji(emoji, total)
+	if allowed >= emoji {
+		return message, true
+	}
+	if p.Action != emojiTrim {
+		return message, false
+	}
+	var b strings.Builder
+	kept := 0
+	for _, g := range graphemes {
+		if g.emoji {
+			if kept == allowed {
+				continue
+			}
+			kept++
+		}
+		b.WriteString(g.text)
+	}
+	trimmed := strings.Join(strings.Fields(b.String()), " ")
+	return trimmed, trimmed != ""
+}
@@ -0,0 +1,81 @@
+package main
+
+import "testing"
+
+const (
+	emojiFamily   = "\U0001F468\u200d\U0001F469\u200d\U0001F467\u200d\U0001F466" // 👨‍👩‍👧‍👦
+	emojiThumbsUp = "\U0001F44D\U0001F3FD"                                       // 👍🏽
+	emojiFlag     = "\U0001F1FA\U0001F1F8"                                       // 🇺🇸
+	emojiKeycap   = "1\ufe0f\u20e3"                                              // 1️⃣
+	emojiFacepalm = "\U0001F926\U0001F3FB\u200d\u2642\ufe0f"                     // 🤦🏻‍♂️
+)
+
+func TestEmojiSequencesCountOnce(t *testing.T) {
+	for _, tc := range []struct {
+		name, message string
+		emoji, total  int
+	}{
+		{"ZWJ family", emojiFamily, 1, 1},
+		{"skin tone", emojiThumbsUp, 1, 1},
+		{"ZWJ with skin tone", emojiFacepalm, 1, 1},
+		{"flag", emojiFlag, 1, 1},
+		{"keycap", emojiKeycap, 1, 1},
+		{"two flags", emojiFlag + emojiFlag, 2, 2},
+		{"mixed", "hi " + emojiFamily + emojiThumbsUp + " ok", 2, 6},
+		{"custom emote", ":pog::pog: x", 2, 3},
+	} {
+		emoji, total := countGraphemes(splitGraphemes(tc.message, map[string]string{"pog": "https://cdn.example.com/pog.png"}))
+		if emoji != tc.emoji || total != tc.total {
+			t.Errorf("%s: %d emoji of %d, want %d of %d", tc.name, emoji, total, tc.emoji, tc.total)
+		}
+	}
+}
+
+func TestEmojiPolicyApply(t *testing.T) {
+	setVar(t, &emojiRatioMinLength, 5)
+	count := emojiPolicy{MaxCount: 2, Action: emojiReject}
+	if _, ok := count.apply(emojiFamily+" "+emojiThumbsUp, nil); !ok {
+		t.Fatal("2 sequences rejected with a limit of 2")
+	}
+	if _, ok := count.apply(emojiFamily+emojiThumbsUp+emojiFacepalm, nil); ok {
+		t.Fatal("3 sequences allowed with a limit of 2")
+	}
+
+	trim := emojiPolicy{MaxCount: 2, Action: emojiTrim}
+	if got, ok := trim.apply("gg "+emojiFamily+" "+emojiThumbsUp+" "+emojiFlag+" "+emojiKeycap, nil); !ok || got != "gg "+emojiFamily+" "+emojiThumbsUp {
+		t.Fatalf("trimmed = %q %v, want the first 2 sequences whole", got, ok)
+	}
+	if got, ok := trim.apply(emojiFlag+emojiFlag+emojiFlag, nil); !ok || got != emojiFlag+emojiFlag {
+		t.Fatalf("trimmed flags = %q %v, want 2 whole flags", got, ok)
+	}
+
+	// Half emoji at most, and only once a message is long enough
+	ratio := emojiPolicy{MaxRatio: 0.5, Action: emojiReject}
+	if _, ok := ratio.apply(emojiFamily+emojiThumbsUp, nil); !ok {
+		t.Fatal("a short reaction was rejected by the ratio")
+	}
+	if _, ok := ratio.apply("abc"+emojiFamily+emojiThumbsUp+emojiFlag, nil); !ok {
+		t.Fatal("3 of 6 rejected at a 0.5 ratio")
+	}
+	if _, ok := ratio.apply("ab"+emojiFamily+emojiThumbsUp+emojiFlag+emojiKeycap, nil); ok {
+		t.Fatal("4 of 6 allowed at a 0.5 ratio")
+	}
+}
+
+func TestTooManyEmojiRejection(t *testing.T) {
+	resetRedis(t)
+	rdb.HSet(ctx, modesKey(1), "emoji_max_count", "2", "emoji_max_ratio", "0.5")
+
+	w := post(t, 1, "v1", "alice", emojiFamily+emojiThumbsUp+emojiFacepalm+emojiFlag+emojiKeycap)
+	expectStatus(t, w, 403)
+	resp := decode(t, w)
+	limit, _ := resp["limit"].(map[string]interface{})
+	if resp["reason"] != "too_many_emoji" || limit["max_emoji"] != float64(2) || limit["max_emoji_ratio"] != 0.5 {
+		t.Fatalf("rejection = %v, want too_many_emoji with both limits", resp)
+	}
+	expectStatus(t, post(t, 1, "v1", "alice", "nice "+emojiFamily+emojiThumbsUp), 200)
+
+	// Streams can turn the limits off
+	rdb.HSet(ctx, modesKey(1), "emoji_max_count", "0", "emoji_max_ratio", "0")
+	expectStatus(t, post(t, 1, "v2", "bob", emojiFamily+emojiThumbsUp+emojiFacepalm+emojiFlag+emojiKeycap), 200)
+}
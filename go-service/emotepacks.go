@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// Besides single emotes, streamers group emotes into named packs (a
+// subscriber set, a seasonal set) they can switch on and off as a whole.
+// Emotes of enabled packs work exactly like the stream's own: they render,
+// count as emotes for emote-only mode and come back from
+// GET /stream/:id/emotes for autocomplete. A stream's own emote wins over a
+// pack's with the same shortcode. Images must be absolute http(s) URLs on a
+// trusted domain. Every published comment counts the custom emotes it uses,
+// and GET /stream/:id/stats reports the most used.
+const (
+	maxEmotePacks       = 20
+	maxEmotesPerPack    = 100
+	emoteUsageStatsSize = 10
+)
+
+var (
+	emotePackNamePattern = regexp.MustCompile(`^[a-z0-9_\-]{1,32}$`)
+	emoteShortcodeOnly   = regexp.MustCompile(`^[a-zA-Z0-9_+\-]{1,32}$`)
+)
+
+// EmotePack is a named set of emotes (shortcode -> image url)
+type EmotePack struct {
+	Name    string            `json:"name"`
+	Enabled bool              `json:"enabled"`
+	Emotes  map[string]string `json:"emotes"`
+}
+
+// loadEmotePacks returns a stream's packs by name, only the enabled ones
+// when enabledOnly is set
+func loadEmotePacks(ctx context.Context, streamID int64, enabledOnly bool) ([]EmotePack, error) {
+	flags, err := rdb.HGetAll(ctx, emotePacksKey(streamID)).Result()
+	if err != nil || len(flags) == 0 {
+		return nil, err
+	}
+	var packs []EmotePack
+	for name, flag := range flags {
+		if enabledOnly && flag != "1" {
+			continue
+		}
+		packs = append(packs, EmotePack{Name: name, Enabled: flag == "1"})
+	}
+	sort.Slice(packs, func(i, j int) bool { return packs[i].Name < packs[j].Name })
+
+	cmds := make([]*redis.StringStringMapCmd, len(packs))
+	_, err = rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, p := range packs {
+			cmds[i] = pipe.HGetAll(ctx, emotePackKey(streamID, p.Name))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range packs {
+		packs[i].Emotes = cmds[i].Val()
+	}
+	return packs, nil
+}
+
+// validEmoteURL reports whether an emote image may be stored
+func validEmoteURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || u.User != nil {
+		return false
+	}
+	return trustedDomains.trustsURL(raw)
+}
+
+// recordEmoteUsage counts the custom emotes a published comment used
+func recordEmoteUsage(ctx context.Context, streamID int64, emotes map[string]string) {
+	if len(emotes) == 0 {
+		return
+	}
+	_, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for name := range emotes {
+			pipe.ZIncrBy(ctx, emoteUsageKey(streamID), 1, name)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error recording emote usage: %v", streamID, err)
+	}
+}
+
+// EmoteUsage is one entry of the most used emotes
+type EmoteUsage struct {
+	Shortcode string `json:"shortcode"`
+	Count     int64  `json:"count"`
+}
+
+// topEmotes returns the current broadcast's most used custom emotes
+func topEmotes(ctx context.Context, streamID int64) ([]EmoteUsage, error) {
+	entries, err := rdb.ZRevRangeWithScores(ctx, emoteUsageKey(streamID), 0, emoteUsageStatsSize-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	top := make([]EmoteUsage, 0, len(entries))
+	for _, z := range entries {
+		name, _ := z.Member.(string)
+		top = append(top, EmoteUsage{Shortcode: name, Count: int64(z.Score)})
+	}
+	return top, nil
+}
+
+// getEmotePacks lists a stream's packs, disabled ones included
+func getEmotePacks(c *gin.Context) {
+	streamID, ok := parseStreamID(c)
+	if !ok {
+		return
+	}
+	packs, err := loadEmotePacks(c.Request.Context(), streamID, false)
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error loading emote packs: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to load emote packs"})
+		return
+	}
+	if packs == nil {
+		packs = []EmotePack{}
+	}
+	c.JSON(200, gin.H{"packs": packs})
+}
+
+type EmotePackRequest struct {
+	Add     map[string]string `json:"add"`     // shortcode -> image url
+	Remove  []string          `json:"remove"`  // shortcodes
+	Enabled *bool             `json:"enabled"` // unchanged when omitted; new packs start enabled
+}
+
+// updateEmotePack creates a pack or changes its emotes and state
+func updateEmotePack(c *gin.Context) {
+	streamID, ok := parseStreamID(c)
+	if !ok {
+		return
+	}
+	name := strings.ToLower(c.Param("pack"))
+	if !emotePackNamePattern.MatchString(name) {
+		c.JSON(400, gin.H{"error": "pack name must be 1-32 lowercase letters, digits, _ or -"})
+		return
+	}
+	var req EmotePackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	for code, link := range req.Add {
+		if !emoteShortcodeOnly.MatchString(code) {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("invalid shortcode %q", code), "reason": "invalid_shortcode"})
+			return
+		}
+		if !validEmoteURL(link) {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("image for %q isn't on a trusted domain", code), "reason": "untrusted_domain"})
+			return
+		}
+	}
+
+	reqCtx := c.Request.Context()
+	flag, err := rdb.HGet(reqCtx, emotePacksKey(streamID), name).Result()
+	exists := err == nil
+	if err != nil && err != redis.Nil {
+		log.Printf("[GO] Stream %d: Error loading emote pack %s: %v", streamID, name, err)
+		c.JSON(500, gin.H{"error": "failed to update emote pack"})
+		return
+	}
+	if !exists {
+		count, err := rdb.HLen(reqCtx, emotePacksKey(streamID)).Result()
+		if err != nil {
+			log.Printf("[GO] Stream %d: Error counting emote packs: %v", streamID, err)
+			c.JSON(500, gin.H{"error": "failed to update emote pack"})
+			return
+		}
+		if count >= maxEmotePacks {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("a stream can have at most %d emote packs", maxEmotePacks)})
+			return
+		}
+		flag = "1"
+	}
+	if req.Enabled != nil {
+		flag = "0"
+		if *req.Enabled {
+			flag = "1"
+		}
+	}
+	size, err := rdb.HLen(reqCtx, emotePackKey(streamID, name)).Result()
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error counting pack emotes: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to update emote pack"})
+		return
+	}
+	if int(size)+len(req.Add) > maxEmotesPerPack+len(req.Remove) {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("a pack can have at most %d emotes", maxEmotesPerPack)})
+		return
+	}
+
+	_, err = rdb.TxPipelined(reqCtx, func(pipe redis.Pipeliner) error {
+		if len(req.Remove) > 0 {
+			pipe.HDel(reqCtx, emotePackKey(streamID, name), req.Remove...)
+		}
+		if len(req.Add) > 0 {
+			pipe.HSet(reqCtx, emotePackKey(streamID, name), req.Add)
+		}
+		pipe.HSet(reqCtx, emotePacksKey(streamID), name, flag)
+		return nil
+	})
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error storing emote pack %s: %v", streamID, name, err)
+		c.JSON(500, gin.H{"error": "failed to update emote pack"})
+		return
+	}
+	emotes, err := rdb.HGetAll(reqCtx, emotePackKey(streamID, name)).Result()
+	if err != nil {
+		emotes = map[string]string{}
+	}
+	pack := EmotePack{Name: name, Enabled: flag == "1", Emotes: emotes}
+	log.Printf("[GO] Stream %d: Emote pack %s updated (%d emotes, enabled: %t)", streamID, name, len(emotes), pack.Enabled)
+	auditRequest(c, streamID, "emote_pack_updated", nil, "", map[string]interface{}{"pack": name, "added": len(req.Add), "removed": len(req.Remove), "enabled": pack.Enabled})
+	c.JSON(200, gin.H{"success": true, "pack": pack})
+}
+
+// deleteEmotePack removes a pack and its emotes
+func deleteEmotePack(c *gin.Context) {
+	streamID, ok := parseStreamID(c)
+	if !ok {
+		return
+	}
+	name := strings.ToLower(c.Param("pack"))
+	reqCtx := c.Request.Context()
+	var removed *redis.IntCmd
+	_, err := rdb.TxPipelined(reqCtx, func(pipe redis.Pipeliner) error {
+		removed = pipe.HDel(reqCtx, emotePacksKey(streamID), name)
+		pipe.Del(reqCtx, emotePackKey(streamID, name))
+		return nil
+	})
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error deleting emote pack %s: %v", streamID, name, err)
+		c.JSON(500, gin.H{"error": "failed to delete emote pack"})
+		return
+	}
+	if removed.Val() > 0 {
+		log.Printf("[GO] Stream %d: Emote pack %s deleted", streamID, name)
+		auditRequest(c, streamID, "emote_pack_deleted", nil, "", map[string]interface{}{"pack": name})
+	}
+	c.JSON(200, gin.H{"success": true, "deleted": removed.Val() > 0})
+}
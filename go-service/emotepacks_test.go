@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// updatePack changes one of stream 1's emote packs as a moderator
+func updatePack(t *testing.T, name string, body map[string]interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	w := request(t, http.MethodPost, "/stream/1/emote-packs/"+name, body, asRole(roleModerator)...)
+	return w.Code, decode(t, w)
+}
+
+// viewerEmotes lists the custom emotes a viewer can use on stream 1
+func viewerEmotes(t *testing.T) map[string]interface{} {
+	t.Helper()
+	w := request(t, http.MethodGet, "/stream/1/emotes", nil)
+	expectStatus(t, w, 200)
+	emotes, _ := decode(t, w)["emotes"].(map[string]interface{})
+	return emotes
+}
+
+func TestEmotePackManagement(t *testing.T) {
+	resetRedis(t)
+	withTrustedDomains(t, "cdn.example.com")
+	pog, kek := "https://cdn.example.com/pog.png", "https://cdn.example.com/kek.png"
+
+	code, resp := updatePack(t, "hype", map[string]interface{}{"add": map[string]string{"pog": pog, "kek": kek}})
+	if pack, _ := resp["pack"].(map[string]interface{}); code != 200 || pack["enabled"] != true || len(pack["emotes"].(map[string]interface{})) != 2 {
+		t.Fatalf("new pack: %d %v, want it enabled with 2 emotes", code, resp)
+	}
+	for _, tc := range []struct {
+		name   string
+		add    map[string]string
+		reason string
+	}{
+		{"hype", map[string]string{"evil": "https://evil.example.net/x.png"}, "untrusted_domain"},
+		{"hype", map[string]string{"evil": "javascript:alert(1)"}, "untrusted_domain"},
+		{"hype", map[string]string{"no spaces": pog}, "invalid_shortcode"},
+	} {
+		if code, resp := updatePack(t, tc.name, map[string]interface{}{"add": tc.add}); code != 400 || resp["reason"] != tc.reason {
+			t.Fatalf("adding %v: %d %v, want 400 %s", tc.add, code, resp, tc.reason)
+		}
+	}
+	if code, _ := updatePack(t, "Bad%20Name", map[string]interface{}{}); code != 400 {
+		t.Fatalf("bad pack name: %d, want 400", code)
+	}
+
+	updatePack(t, "hype", map[string]interface{}{"remove": []string{"kek"}})
+	if emotes := viewerEmotes(t); len(emotes) != 1 || emotes["pog"] != pog {
+		t.Fatalf("emotes = %v, want only pog", emotes)
+	}
+	updatePack(t, "hype", map[string]interface{}{"enabled": false})
+	if emotes := viewerEmotes(t); len(emotes) != 0 {
+		t.Fatalf("emotes of a disabled pack = %v, want none", emotes)
+	}
+	w := request(t, http.MethodGet, "/stream/1/emote-packs", nil, asRole(roleModerator)...)
+	expectStatus(t, w, 200)
+	packs := decode(t, w)["packs"].([]interface{})
+	if len(packs) != 1 || packs[0].(map[string]interface{})["enabled"] != false {
+		t.Fatalf("packs = %v, want the disabled pack still listed", packs)
+	}
+
+	// The stream's own emote wins over a pack's
+	updatePack(t, "hype", map[string]interface{}{"enabled": true})
+	rdb.HSet(ctx, emotesKey(1), "pog", "https://cdn.example.com/own.png")
+	if emotes := viewerEmotes(t); emotes["pog"] != "https://cdn.example.com/own.png" {
+		t.Fatalf("pog = %v, want the stream's own emote", emotes["pog"])
+	}
+	rdb.Del(ctx, emotesKey(1))
+
+	w = request(t, http.MethodPost, "/stream/1/emote-packs/hype/delete", nil, asRole(roleModerator)...)
+	expectStatus(t, w, 200)
+	if decode(t, w)["deleted"] != true || len(viewerEmotes(t)) != 0 {
+		t.Fatal("pack still there after deleting it")
+	}
+}
+
+func TestEmoteUsageAggregation(t *testing.T) {
+	resetRedis(t)
+	withTrustedDomains(t, "cdn.example.com")
+	updatePack(t, "hype", map[string]interface{}{"add": map[string]string{
+		"pog": "https://cdn.example.com/pog.png", "kek": "https://cdn.example.com/kek.png",
+	}})
+	rdb.HSet(ctx, modesKey(1), "emote_only", "1")
+
+	for i, msg := range []string{":pog:", ":pog: :kek:", ":pog: :pog:"} {
+		expectStatus(t, post(t, 1, fmt.Sprintf("v%d", i), "alice", msg), 200)
+	}
+	top, _ := filterStats(t)["top_emotes"].([]interface{})
+	if len(top) != 2 {
+		t.Fatalf("top emotes = %v, want pog and kek", top)
+	}
+	// A comment counts each emote it uses once
+	first, second := top[0].(map[string]interface{}), top[1].(map[string]interface{})
+	if first["shortcode"] != "pog" || first["count"] != float64(3) || second["shortcode"] != "kek" || second["count"] != float64(1) {
+		t.Fatalf("top emotes = %v, want pog 3 then kek 1", top)
+	}
+}
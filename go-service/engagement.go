@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// A comment's engagement score combines the signals it has collected with a
+// recency bonus:
+//
+//	score = reactions*ENGAGEMENT_REACTION_WEIGHT
+//	      - reports*ENGAGEMENT_REPORT_WEIGHT
+//	      + ENGAGEMENT_RECENCY_WEIGHT * 0.5^(age / ENGAGEMENT_HALF_LIFE)
+//
+// The signal part is kept per comment in the stream's engagement ZSET and
+// updated incrementally as reactions and reports come in; the recency part
+// depends on the time of the query and is added when ranking. Weight changes
+// only apply to signals recorded afterwards.
+var (
+	engagementReactionWeight float64
+	engagementReportWeight   float64
+	engagementRecencyWeight  float64
+	engagementHalfLife       time.Duration
+)
+
+func loadEngagementConfig() {
+	engagementReactionWeight = envFloat("ENGAGEMENT_REACTION_WEIGHT", 1)
+	engagementReportWeight = envFloat("ENGAGEMENT_REPORT_WEIGHT", 5)
+	engagementRecencyWeight = envFloat("ENGAGEMENT_RECENCY_WEIGHT", 10)
+	engagementHalfLife = time.Duration(envInt("ENGAGEMENT_HALF_LIFE", 300)) * time.Second
+	if engagementHalfLife <= 0 {
+		engagementHalfLife = 5 * time.Minute
+	}
+}
+
+// adjustEngagement adds delta to a comment's stored signal score
+func adjustEngagement(ctx context.Context, streamID int64, commentID string, delta float64) {
+	if delta == 0 {
+		return
+	}
+	if err := rdb.ZIncrBy(ctx, engagementKey(streamID), delta, commentID).Err(); err != nil {
+		log.Printf("[GO] Stream %d: Error updating engagement for comment %s: %v", streamID, commentID, err)
+	}
+}
+
+// engagementScore adds the recency bonus to a comment's signal score
+func engagementScore(signal float64, postedAt int64, now time.Time) float64 {
+	age := now.Sub(time.UnixMilli(postedAt))
+	if age < 0 {
+		age = 0
+	}
+	return signal + engagementRecencyWeight*math.Pow(0.5, float64(age)/float64(engagementHalfLife))
+}
+
+// rankEngagement scores the stream's recent comments (within window, or the
+// newest leaderboardScanLimit when window is 0), highest first
+func rankEngagement(ctx context.Context, streamID int64, window time.Duration) ([]redis.Z, error) {
+	now := time.Now()
+	min := "-inf"
+	if window > 0 {
+		min = strconv.FormatInt(now.Add(-window).UnixMilli(), 10)
+	}
+	recent, err := rdb.ZRevRangeByScoreWithScores(ctx, commentIndexKey(streamID), &redis.ZRangeBy{
+		Min:   min,
+		Max:   strconv.FormatInt(now.UnixMilli(), 10),
+		Count: leaderboardScanLimit,
+	}).Result()
+	if err != nil || len(recent) == 0 {
+		return nil, err
+	}
+
+	ids := make([]string, len(recent))
+	for i, z := range recent {
+		ids[i] = z.Member.(string)
+	}
+	signals, err := rdb.ZMScore(ctx, engagementKey(streamID), ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+	ranked := make([]redis.Z, len(recent))
+	for i, z := range recent {
+		ranked[i] = redis.Z{Member: ids[i], Score: engagementScore(signals[i], int64(z.Score), now)}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].Score > ranked[j].Score })
+	return ranked, nil
+}
+
+type ReportRequest struct {
+	StreamID  flexID `json:"stream_id" binding:"required"`
+	CommentID flexID `json:"comment_id" binding:"required"`
+	ViewerID  string `json:"viewer_id" binding:"required"`
+	Reason    string `json:"reason" binding:"max=200"`
+}
+
+// reportComment records a viewer's report against a comment, once per
+// viewer, and tells moderators about it
+func reportComment(c *gin.Context) {
+	var req ReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	reqCtx := c.Request.Context()
+	commentID := strconv.FormatInt(int64(req.CommentID), 10)
+	data, err := rdb.HGet(reqCtx, commentDataKey(int64(req.StreamID)), commentID).Result()
+	if err == redis.Nil {
+		c.JSON(404, gin.H{"error": "comment not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error checking comment %s: %v", int64(req.StreamID), commentID, err)
+		c.JSON(500, gin.H{"error": "failed to report comment"})
+		return
+	}
+
+	var reported *redis.IntCmd
+	_, err = rdb.TxPipelined(reqCtx, func(pipe redis.Pipeliner) error {
+		reported = pipe.SAdd(reqCtx, reportersKey(int64(req.StreamID), int64(req.CommentID)), req.ViewerID)
+		pipe.SAdd(reqCtx, commentKeysKey(int64(req.StreamID)), commentID)
+		return nil
+	})
+	added := reported.Val()
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error reporting comment %s: %v", int64(req.StreamID), commentID, err)
+		c.JSON(500, gin.H{"error": "failed to report comment"})
+		return
+	}
+	var reports int64
+	if added > 0 {
+		reports, err = rdb.HIncrBy(reqCtx, reportCountsKey(int64(req.StreamID)), commentID, 1).Result()
+		if err != nil {
+			log.Printf("[GO] Stream %d: Error counting reports for comment %s: %v", int64(req.StreamID), commentID, err)
+		}
+		adjustEngagement(reqCtx, int64(req.StreamID), commentID, -engagementReportWeight)
+		if isTrustedRequest(c) {
+			bumpReputation(reqCtx, int64(req.StreamID), commentAuthor(data), repReports, 1)
+		}
+		publishModEvent(reqCtx, int64(req.StreamID), map[string]interface{}{
+			"type":       "comment_reported",
+			"comment_id": req.CommentID,
+			"reason":     req.Reason,
+			"reports":    reports,
+		})
+	} else {
+		reports, _ = rdb.HGet(reqCtx, reportCountsKey(int64(req.StreamID)), commentID).Int64()
+	}
+	c.JSON(200, gin.H{"success": true, "reported": added > 0, "reports": reports})
+}
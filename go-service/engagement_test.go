@@ -0,0 +1,80 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// report reports a stream 1 comment as viewerID
+func report(t *testing.T, commentID int64, viewerID string, headers ...string) {
+	t.Helper()
+	expectStatus(t, request(t, http.MethodPost, "/report", map[string]interface{}{
+		"stream_id": 1, "comment_id": commentID, "viewer_id": viewerID, "reason": "spam",
+	}, headers...), 200)
+}
+
+func TestEngagementScoreRecency(t *testing.T) {
+	now := time.Now()
+	halfLife := engagementHalfLife
+	for _, tc := range []struct {
+		signal float64
+		posted time.Time
+		want   float64
+	}{
+		{0, now, engagementRecencyWeight},
+		{0, now.Add(-halfLife), engagementRecencyWeight / 2},
+		{0, now.Add(-2 * halfLife), engagementRecencyWeight / 4},
+		{3, now.Add(-halfLife), 3 + engagementRecencyWeight/2},
+		{-5, now, -5 + engagementRecencyWeight},
+		// Scheduled comments don't get more than the full bonus
+		{0, now.Add(time.Hour), engagementRecencyWeight},
+	} {
+		if got := engagementScore(tc.signal, tc.posted.UnixMilli(), now); math.Abs(got-tc.want) > 0.01 {
+			t.Errorf("score(%v, %v old) = %v, want %v", tc.signal, now.Sub(tc.posted), got, tc.want)
+		}
+	}
+}
+
+func TestEngagementRanking(t *testing.T) {
+	resetRedis(t)
+	liked := postedID(t, 1, "v1", "alice", "liked")
+	reported := postedID(t, 1, "v2", "bob", "reported")
+	quiet := postedID(t, 1, "v3", "carol", "quiet")
+	for _, viewer := range []string{"x1", "x2", "x3"} {
+		react(t, liked, viewer, "like")
+	}
+	report(t, reported, "x1")
+	report(t, reported, "x1") // once per viewer
+
+	signals := map[int64]float64{liked: 3 * engagementReactionWeight, reported: -engagementReportWeight, quiet: 0}
+	for id, want := range signals {
+		if got := rdb.ZScore(ctx, engagementKey(1), strconv.FormatInt(id, 10)).Val(); got != want {
+			t.Fatalf("comment %d signal = %v, want %v", id, got, want)
+		}
+	}
+
+	w := request(t, http.MethodGet, "/stream/1/top-comments?by=engagement", nil, asRole(roleModerator)...)
+	expectStatus(t, w, 200)
+	list := decode(t, w)["comments"].([]interface{})
+	order := []int64{liked, quiet, reported}
+	if len(list) != len(order) {
+		t.Fatalf("ranked %d comments, want %d", len(list), len(order))
+	}
+	for i, id := range order {
+		entry := list[i].(map[string]interface{})
+		score, _ := entry["engagement"].(float64)
+		if entry["id"] != float64(id) || math.Abs(score-(signals[id]+engagementRecencyWeight)) > 0.1 {
+			t.Fatalf("rank %d = %v (engagement %v), want comment %d", i, entry["id"], entry["engagement"], id)
+		}
+	}
+
+	// The score is for moderators only
+	w = request(t, http.MethodGet, "/stream/1/top-comments?by=engagement", nil)
+	expectStatus(t, w, 200)
+	if first := decode(t, w)["comments"].([]interface{})[0].(map[string]interface{}); first["engagement"] != nil {
+		t.Fatalf("viewers see the engagement score: %v", first)
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// COMMENT_GLOBAL_CAP bounds how many comments all streams hold together, so
+// a node hosting many streams at once can't fill Redis. The eviction job
+// counts every stream's comments each COMMENT_EVICT_INTERVAL seconds and,
+// over the cap, deletes oldest comments until the total is back to
+// COMMENT_EVICT_TARGET of it. COMMENT_EVICTION_POLICY picks the streams:
+//
+//   - lru:     least recently active first (a comment or viewer heartbeat
+//     counts as activity), so idle streams give up their history before
+//     live ones
+//   - largest: the streams holding the most comments first
+//
+// Every stream keeps at least its newest COMMENT_EVICT_KEEP comments, and
+// comments featured or queued as paid questions are never evicted. 0 turns
+// the cap off.
+const (
+	evictionLRU     = "lru"
+	evictionLargest = "largest"
+)
+
+var (
+	commentGlobalCap   int64
+	commentEvictTarget float64
+	commentEvictPolicy string
+	commentEvictKeep   int64
+)
+
+const commentsEvictedHelp = "Comments deleted to stay under COMMENT_GLOBAL_CAP"
+
+func loadEvictionConfig() {
+	commentGlobalCap = int64(envInt("COMMENT_GLOBAL_CAP", 0))
+	commentEvictTarget = envFloat("COMMENT_EVICT_TARGET", 0.9)
+	if commentEvictTarget <= 0 || commentEvictTarget > 1 {
+		commentEvictTarget = 0.9
+	}
+	commentEvictPolicy = strings.ToLower(strings.TrimSpace(envString("COMMENT_EVICTION_POLICY", evictionLRU)))
+	if commentEvictPolicy != evictionLRU && commentEvictPolicy != evictionLargest {
+		log.Printf("[GO] Warning: unknown COMMENT_EVICTION_POLICY %q, using %q", commentEvictPolicy, evictionLRU)
+		commentEvictPolicy = evictionLRU
+	}
+	commentEvictKeep = int64(envInt("COMMENT_EVICT_KEEP", 0))
+	if commentEvictKeep < 0 {
+		commentEvictKeep = 0
+	}
+}
+
+// touchStreamActivity records that a stream was just used, for LRU eviction
+// and compaction
+func touchStreamActivity(ctx context.Context, streamID int64) {
+	if commentGlobalCap <= 0 && compactionInterval <= 0 {
+		return
+	}
+	err := rdb.ZAdd(ctx, streamActivityKey(), &redis.Z{Score: float64(time.Now().UnixMilli()), Member: streamID}).Err()
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error recording stream activity: %v", streamID, err)
+	}
+}
+
+// runCommentEviction periodically enforces COMMENT_GLOBAL_CAP. All replicas
+// run it, so a lock lets only one evict per interval.
+func runCommentEviction(ctx context.Context, interval time.Duration) {
+	if commentGlobalCap <= 0 || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	jobs.setRunning("comment_eviction", true)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			claimed, err := rdb.SetNX(ctx, evictionLockKey(), 1, interval).Result()
+			if err != nil || !claimed {
+				jobs.ran("comment_eviction", err)
+				continue
+			}
+			evicted, err := evictComments(ctx)
+			jobs.ran("comment_eviction", err)
+			if err != nil {
+				log.Printf("[GO] Comment eviction failed: %v", err)
+			} else if evicted > 0 {
+				log.Printf("[GO] Comment eviction removed %d comments", evicted)
+			}
+		}
+	}
+}
+
+// streamUsage is one tracked stream's comment count and last activity
+type streamUsage struct {
+	streamID int64
+	comments int64
+	active   float64
+}
+
+// evictComments deletes comments until the global total is at the target,
+// returning how many it deleted
+func evictComments(ctx context.Context) (int, error) {
+	entries, err := rdb.ZRangeWithScores(ctx, streamActivityKey(), 0, -1).Result()
+	if err != nil || len(entries) == 0 {
+		return 0, err
+	}
+	counts := make([]*redis.IntCmd, len(entries))
+	_, err = rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, z := range entries {
+			sid, _ := z.Member.(string)
+			streamID, _ := strconv.ParseInt(sid, 10, 64)
+			counts[i] = pipe.ZCard(ctx, commentIndexKey(streamID))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	streams := make([]streamUsage, 0, len(entries))
+	for i, z := range entries {
+		sid, _ := z.Member.(string)
+		streamID, _ := strconv.ParseInt(sid, 10, 64)
+		n := counts[i].Val()
+		if n == 0 {
+			// Nothing left to evict; it's tracked again once it is used
+			rdb.ZRem(ctx, streamActivityKey(), sid)
+			continue
+		}
+		total += n
+		streams = append(streams, streamUsage{streamID: streamID, comments: n, active: z.Score})
+	}
+	if total <= commentGlobalCap {
+		return 0, nil
+	}
+	if commentEvictPolicy == evictionLargest {
+		sort.SliceStable(streams, func(i, j int) bool { return streams[i].comments > streams[j].comments })
+	}
+
+	excess := total - int64(float64(commentGlobalCap)*commentEvictTarget)
+	evicted := 0
+	for _, s := range streams {
+		if excess <= 0 {
+			break
+		}
+		n, err := evictOldest(ctx, s.streamID, s.comments, excess)
+		if err != nil {
+			return evicted, err
+		}
+		evicted += n
+		excess -= int64(n)
+	}
+	return evicted, nil
+}
+
+// evictOldest deletes up to want of a stream's oldest comments, leaving its
+// newest COMMENT_EVICT_KEEP and the ones pinned above the feed
+func evictOldest(ctx context.Context, streamID, count, want int64) (int, error) {
+	if want > count-commentEvictKeep {
+		want = count - commentEvictKeep
+	}
+	if want <= 0 {
+		return 0, nil
+	}
+	pinned, err := pinnedCommentIDs(ctx, streamID)
+	if err != nil {
+		return 0, err
+	}
+	ids, err := rdb.ZRange(ctx, commentIndexKey(streamID), 0, want+int64(len(pinned))-1).Result()
+	if err != nil {
+		return 0, err
+	}
+	victims := make([]string, 0, want)
+	for _, id := range ids {
+		if !pinned[id] && int64(len(victims)) < want {
+			victims = append(victims, id)
+		}
+	}
+	if err := deleteComments(ctx, streamID, victims); err != nil {
+		return 0, err
+	}
+	if len(victims) > 0 {
+		log.Printf("[GO] Stream %d: Evicted %d oldest comments", streamID, len(victims))
+		newCounter("comments_evicted_total", commentsEvictedHelp).Add(int64(len(victims)))
+	}
+	return len(victims), nil
+}
+
+// pinnedCommentIDs returns the IDs of every comment shown above the feed: the
+// featured and queued paid questions, and the newest priority comments, which
+// the priority lane and the highlights both read
+func pinnedCommentIDs(ctx context.Context, streamID int64) (map[string]bool, error) {
+	queued, err := rdb.HKeys(ctx, featuredEntriesKey(streamID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	shown := int64(priorityLaneMax)
+	if shown < highlightMaxSlots {
+		shown = highlightMaxSlots
+	}
+	priority, err := rdb.ZRevRange(ctx, priorityKey(streamID), 0, shown-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	pinned := make(map[string]bool, len(queued)+len(priority)+1)
+	for _, id := range queued {
+		pinned[id] = true
+	}
+	for _, id := range priority {
+		pinned[id] = true
+	}
+	active, err := activeFeaturedQuestion(ctx, streamID)
+	if err != nil {
+		return nil, err
+	}
+	if active != nil {
+		pinned[strconv.FormatInt(active.Comment.ID, 10)] = true
+	}
+	return pinned, nil
+}
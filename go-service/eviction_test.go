@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// fillStream stores n comments a millisecond apart on a stream, IDs
+// streamID*100+1 up, and marks the stream active at the given time
+func fillStream(t *testing.T, streamID int64, n int, active int64) {
+	t.Helper()
+	ts := time.Now().Add(-time.Hour).UnixMilli()
+	for i := int64(1); i <= int64(n); i++ {
+		cmt := &Comment{ID: streamID*100 + i, Username: "alice", Message: "m", Timestamp: ts + i}
+		payload, _ := json.Marshal(cmt)
+		if err := store.SaveComment(ctx, streamID, "v1", cmt, payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rdb.ZAdd(ctx, streamActivityKey(), &redis.Z{Score: float64(active), Member: streamID})
+}
+
+// withEviction sets the global cap policy for the rest of the test
+func withEviction(t *testing.T, cap int64, target float64, policy string, keep int64) {
+	t.Helper()
+	setVar(t, &commentGlobalCap, cap)
+	setVar(t, &commentEvictTarget, target)
+	setVar(t, &commentEvictPolicy, policy)
+	setVar(t, &commentEvictKeep, keep)
+}
+
+// remaining lists the IDs a stream still holds, oldest first
+func remaining(t *testing.T, streamID int64) []string {
+	t.Helper()
+	ids, err := rdb.ZRange(ctx, commentIndexKey(streamID), 0, -1).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+func TestEvictionLeastRecentlyActiveFirst(t *testing.T) {
+	resetRedis(t)
+	withEviction(t, 25, 0.8, evictionLRU, 2)
+	fillStream(t, 1, 10, 1000)
+	fillStream(t, 2, 10, 2000)
+	fillStream(t, 3, 10, 3000)
+
+	evicted, err := evictComments(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 30 over a cap of 25 goes down to 20: stream 1 keeps its newest 2 and
+	// stream 2 gives up the rest
+	if evicted != 10 {
+		t.Fatalf("evicted %d, want 10", evicted)
+	}
+	if got := remaining(t, 1); len(got) != 2 || got[0] != "109" || got[1] != "110" {
+		t.Fatalf("stream 1 holds %v, want its newest 2", got)
+	}
+	if got := remaining(t, 2); len(got) != 8 || got[0] != "203" {
+		t.Fatalf("stream 2 holds %v, want all but its oldest 2", got)
+	}
+	if got := remaining(t, 3); len(got) != 10 {
+		t.Fatalf("stream 3 holds %d, want the most active stream untouched", len(got))
+	}
+	if n, _ := rdb.HLen(ctx, commentDataKey(1)).Result(); n != 2 {
+		t.Fatalf("stream 1 data holds %d comments, want evicted ones deleted", n)
+	}
+}
+
+func TestEvictionLargestFirst(t *testing.T) {
+	resetRedis(t)
+	withEviction(t, 25, 0.8, evictionLargest, 0)
+	fillStream(t, 1, 5, 1000)
+	fillStream(t, 2, 15, 3000)
+	fillStream(t, 3, 10, 2000)
+
+	if _, err := evictComments(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if a, b, c := len(remaining(t, 1)), len(remaining(t, 2)), len(remaining(t, 3)); a != 5 || b != 5 || c != 10 {
+		t.Fatalf("streams hold %d/%d/%d, want 5/5/10", a, b, c)
+	}
+}
+
+func TestEvictionSparesPinnedAndUnderCap(t *testing.T) {
+	resetRedis(t)
+	withEviction(t, 10, 0.5, evictionLRU, 0)
+	fillStream(t, 1, 8, 1000)
+
+	if evicted, _ := evictComments(ctx); evicted != 0 {
+		t.Fatalf("evicted %d under the cap, want 0", evicted)
+	}
+
+	fillStream(t, 2, 4, 2000)
+	rdb.HSet(ctx, featuredEntriesKey(1), strconv.Itoa(101), "{}")
+	// 12 over a cap of 10 goes down to 5: the oldest 7 except the queued
+	// paid question
+	if evicted, _ := evictComments(ctx); evicted != 7 {
+		t.Fatalf("evicted %d, want 7", evicted)
+	}
+	if got := remaining(t, 1); len(got) != 1 || got[0] != "101" {
+		t.Fatalf("stream 1 holds %v, want only the paid question", got)
+	}
+}
+
+func TestEvictionSparesHighlightedPriorityComments(t *testing.T) {
+	resetRedis(t)
+	withEviction(t, 10, 0.5, evictionLRU, 0)
+	fillStream(t, 1, 8, 1000)
+	fillStream(t, 2, 4, 2000)
+	rdb.HSet(ctx, featuredEntriesKey(1), strconv.Itoa(101), "{}")
+	for _, id := range []int64{102, 104} {
+		rdb.ZAdd(ctx, priorityKey(1), &redis.Z{Score: float64(id), Member: strconv.FormatInt(id, 10)})
+	}
+
+	// The oldest 7 go except the paid question and both priority comments,
+	// which the highlights and the lane show above the feed
+	if evicted, _ := evictComments(ctx); evicted != 7 {
+		t.Fatalf("evicted %d, want 7", evicted)
+	}
+	if got := remaining(t, 1); strings.Join(got, " ") != "101 102 104" {
+		t.Fatalf("stream 1 holds %v, want [101 102 104]", got)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// A poll of a stream nobody created looks just like a poll of a quiet one.
+// stream:exists:<stream_id> marks a stream as known once it is registered
+// (POST /stream/:id/register), started or first written to, and expires with
+// the rest of an ephemeral stream. STREAM_NOT_FOUND decides what an empty
+// check-update of an unknown stream answers:
+//
+//   - off (default): the usual empty response, as before
+//   - flag: the empty response with stream_not_found set
+//   - 404: a 404 with reason stream_not_found
+const (
+	streamNotFoundOff  = "off"
+	streamNotFoundFlag = "flag"
+	streamNotFound404  = "404"
+)
+
+var streamNotFoundMode string
+
+func loadExistenceConfig() {
+	switch mode := strings.ToLower(strings.TrimSpace(envString("STREAM_NOT_FOUND", streamNotFoundOff))); mode {
+	case streamNotFoundOff, streamNotFoundFlag, streamNotFound404:
+		streamNotFoundMode = mode
+	default:
+		log.Printf("[GO] Warning: unknown STREAM_NOT_FOUND %q, using %q", mode, streamNotFoundOff)
+		streamNotFoundMode = streamNotFoundOff
+	}
+}
+
+// markStreamExists queues the stream's existence marker on pipe
+func markStreamExists(ctx context.Context, pipe redis.Pipeliner, streamID int64) {
+	pipe.Set(ctx, streamExistsKey(streamID), 1, 0)
+}
+
+// streamMissing reads the result of checking a stream's existence marker:
+// an empty read of a missing stream is answered as not found (see
+// loadPollState). Errors count as known, so a Redis hiccup never hides a
+// stream.
+func streamMissing(streamID int64, exists int64, err error) bool {
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error checking stream existence: %v", streamID, err)
+		return false
+	}
+	return exists == 0
+}
+
+// registerStream marks a stream as known before anything is posted to it, so
+// its viewers see an empty chat rather than a missing stream
+func registerStream(c *gin.Context) {
+	streamID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid stream id"})
+		return
+	}
+	reqCtx := c.Request.Context()
+	if _, err := rdb.Pipelined(reqCtx, func(pipe redis.Pipeliner) error {
+		markStreamExists(reqCtx, pipe, streamID)
+		return nil
+	}); err != nil {
+		log.Printf("[GO] Stream %d: Error registering stream: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to register stream"})
+		return
+	}
+	touchStream(reqCtx, streamID)
+	log.Printf("[GO] Stream %d: Registered", streamID)
+	c.JSON(200, gin.H{"success": true})
+}
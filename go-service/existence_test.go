@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+// checkStream polls a stream from the start and returns the status and body
+func checkStream(t *testing.T, streamID int64) (int, map[string]interface{}) {
+	t.Helper()
+	w := request(t, http.MethodPost, "/check-update", map[string]interface{}{"stream_id": streamID, "viewer_id": "v1", "last_id": 1})
+	return w.Code, decode(t, w)
+}
+
+func TestStreamNotFoundOff(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &streamNotFoundMode, streamNotFoundOff)
+	if status, resp := checkStream(t, 9); status != 200 || resp["stream_not_found"] != nil {
+		t.Fatalf("unknown stream: %d %v, want the usual empty response", status, resp)
+	}
+}
+
+func TestStreamNotFoundFlag(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &streamNotFoundMode, streamNotFoundFlag)
+
+	if status, resp := checkStream(t, 9); status != 200 || resp["stream_not_found"] != true {
+		t.Fatalf("unknown stream: %d %v, want stream_not_found set", status, resp)
+	}
+	// Registering marks an existing but quiet stream
+	expectStatus(t, request(t, http.MethodPost, "/stream/9/register", nil, trusted...), 200)
+	if status, resp := checkStream(t, 9); status != 200 || resp["stream_not_found"] != nil {
+		t.Fatalf("registered stream: %d %v, want it known", status, resp)
+	}
+}
+
+func TestStreamNotFound404(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &streamNotFoundMode, streamNotFound404)
+
+	status, resp := checkStream(t, 9)
+	if status != 404 || resp["reason"] != "stream_not_found" {
+		t.Fatalf("unknown stream: %d %v, want 404 stream_not_found", status, resp)
+	}
+	// The first comment or a start makes a stream known
+	expectStatus(t, post(t, 9, "v1", "alice", "first"), 200)
+	nextSecond()
+	if status, resp := checkStream(t, 9); status != 200 || len(messages(resp)) != 1 {
+		t.Fatalf("written stream: %d %v", status, resp)
+	}
+	expectStatus(t, request(t, http.MethodPost, "/stream/10/start", nil, trusted...), 200)
+	if status, _ := checkStream(t, 10); status != 200 {
+		t.Fatalf("started stream: %d, want 200", status)
+	}
+
+	// Comments outlive a lost marker, so a stream with a feed is never missing
+	rdb.Del(ctx, streamExistsKey(9))
+	if status, _ := checkStream(t, 9); status != 200 {
+		t.Fatalf("stream with comments but no marker: %d, want 200", status)
+	}
+	expectStatus(t, request(t, http.MethodPost, "/stream/abc/register", nil, trusted...), 400)
+	expectStatus(t, request(t, http.MethodPost, "/stream/11/register", nil), 401)
+}
+
+func TestStreamMissingErrorsCountAsKnown(t *testing.T) {
+	if streamMissing(1, 0, errors.New("connection refused")) {
+		t.Fatal("a failed existence check hid the stream")
+	}
+	if !streamMissing(1, 0, nil) || streamMissing(1, 1, nil) {
+		t.Fatal("existence marker misread")
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// maxCommentLifetime bounds how long an ephemeral comment may be requested to live
+const maxCommentLifetime = 24 * time.Hour
+
+// trackExpiry queues an ephemeral comment for the sweeper
+func trackExpiry(ctx context.Context, pipe redis.Pipeliner, streamID, commentID, expiresAt int64) {
+	pipe.ZAdd(ctx, expiryKey(), &redis.Z{
+		Score:  float64(expiresAt),
+		Member: fmt.Sprintf("%d:%d", streamID, commentID),
+	})
+}
+
+// isExpired reports whether a comment's visibility window has closed
+func isExpired(cmt Comment, now int64) bool {
+	return cmt.ExpiresAt > 0 && cmt.ExpiresAt <= now
+}
+
+// runExpirySweeper periodically removes expired comments. checkUpdate already
+// hides them, so the sweeper only reclaims storage and can lag safely.
+func runExpirySweeper(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		log.Printf("[GO] Expiry sweeper disabled")
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	jobs.setRunning("expiry_sweeper", true)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			removed, err := sweepExpiredComments(ctx)
+			jobs.ran("expiry_sweeper", err)
+			if err != nil {
+				log.Printf("[GO] Expiry sweep failed: %v", err)
+			} else if removed > 0 {
+				log.Printf("[GO] Expiry sweep removed %d comments", removed)
+			}
+		}
+	}
+}
+
+func sweepExpiredComments(ctx context.Context) (int, error) {
+	now := time.Now().UnixMilli()
+	members, err := rdb.ZRangeByScore(ctx, expiryKey(), &redis.ZRangeBy{
+		Min:   "0",
+		Max:   strconv.FormatInt(now, 10),
+		Count: 500,
+	}).Result()
+	if err != nil || len(members) == 0 {
+		return 0, err
+	}
+
+	_, err = rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, member := range members {
+			sid, id, ok := strings.Cut(member, ":")
+			if streamID, err := strconv.ParseInt(sid, 10, 64); ok && err == nil {
+				pipe.ZRem(ctx, commentIndexKey(streamID), id)
+				pipe.ZRem(ctx, priorityKey(streamID), id)
+				pipe.HDel(ctx, commentDataKey(streamID), id)
+				invalidateReplayTimelines(ctx, pipe, streamID)
+			}
+			pipe.ZRem(ctx, expiryKey(), member)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(members), nil
+}
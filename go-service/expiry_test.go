@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// postExpiring posts a comment that expires after seconds
+func postExpiring(t *testing.T, message string, seconds int64) map[string]interface{} {
+	t.Helper()
+	w := request(t, http.MethodPost, "/post-comment", map[string]interface{}{
+		"stream_id": 1, "viewer_id": "v1", "username": "alice", "message": message, "expires_in": seconds,
+	})
+	return map[string]interface{}{"status": w.Code, "body": decode(t, w)}
+}
+
+func TestExpiringCommentVisibility(t *testing.T) {
+	resetRedis(t)
+	resp := postExpiring(t, "self-destructs", 2)
+	if resp["status"] != 200 {
+		t.Fatalf("post: %v", resp)
+	}
+	cmt := resp["body"].(map[string]interface{})["comment"].(map[string]interface{})
+	if cmt["expires_at"] == nil {
+		t.Fatal("the response doesn't carry expires_at")
+	}
+	expectStatus(t, post(t, 1, "v2", "bob", "stays"), 200)
+
+	nextSecond()
+	if got := messages(poll(t, 1, "v3", 0)); len(got) != 2 {
+		t.Fatalf("comments before expiry = %v, want both", got)
+	}
+	expiresAt := int64(cmt["expires_at"].(float64))
+	time.Sleep(time.Until(time.UnixMilli(expiresAt)))
+	nextSecond()
+	if got := messages(poll(t, 1, "v3", 0)); len(got) != 1 || got[0] != "stays" {
+		t.Fatalf("comments after expiry = %v, want [stays]", got)
+	}
+
+	if removed, err := sweepExpiredComments(ctx); err != nil || removed != 1 {
+		t.Fatalf("sweep = %d, %v; want 1", removed, err)
+	}
+	if n := rdb.HLen(ctx, commentDataKey(1)).Val(); n != 1 {
+		t.Fatalf("comments stored after the sweep = %d, want 1", n)
+	}
+}
+
+func TestExpiryOutOfRangeIsRejected(t *testing.T) {
+	resetRedis(t)
+	for _, seconds := range []int64{-1, int64(maxCommentLifetime/time.Second) + 1, 10_000_000_000} {
+		resp := postExpiring(t, "too long", seconds)
+		body := resp["body"].(map[string]interface{})
+		if resp["status"] != 400 || body["reason"] != "invalid_expiry" {
+			t.Fatalf("expires_in %d: %v, want 400 invalid_expiry", seconds, resp)
+		}
+	}
+}
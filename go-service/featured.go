@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// Paid questions are pinned in a featured slot above the feed. The backend
+// takes the payment, then promotes the viewer's comment with the amount paid;
+// the question stays featured for FEATURED_SECONDS_PER_UNIT seconds per unit
+// of currency, clamped to FEATURED_MIN_DURATION..FEATURED_MAX_DURATION.
+// While one question is featured the rest wait in a queue, highest amount
+// first and oldest first among equal amounts, and the next one takes the slot
+// when the current one's time runs out. Refunds cancel a promotion.
+var (
+	featuredSecondsPerUnit float64
+	featuredMinDuration    time.Duration
+	featuredMaxDuration    time.Duration
+)
+
+func loadFeaturedConfig() {
+	featuredSecondsPerUnit = envFloat("FEATURED_SECONDS_PER_UNIT", 60)
+	featuredMinDuration = time.Duration(envInt("FEATURED_MIN_DURATION", 30)) * time.Second
+	featuredMaxDuration = time.Duration(envInt("FEATURED_MAX_DURATION", 600)) * time.Second
+	if featuredMaxDuration < featuredMinDuration {
+		featuredMaxDuration = featuredMinDuration
+	}
+}
+
+// FeaturedQuestion is a paid question, queued or in the featured slot
+type FeaturedQuestion struct {
+	Comment  Comment `json:"comment"`
+	Amount   float64 `json:"amount"`
+	Duration int64   `json:"duration"`        // ms it stays featured
+	QueuedAt int64   `json:"queued_at"`       // ms
+	Until    int64   `json:"until,omitempty"` // ms, set once it is featured
+}
+
+// featuredDuration returns how long a question paid with amount is featured
+func featuredDuration(amount float64) time.Duration {
+	d := time.Duration(amount * featuredSecondsPerUnit * float64(time.Second))
+	if d < featuredMinDuration {
+		return featuredMinDuration
+	}
+	if d > featuredMaxDuration {
+		return featuredMaxDuration
+	}
+	return d
+}
+
+// featuredMember is the question's queue member. Queue scores are the negated
+// amount so ZPOPMIN takes the highest; the zero-padded queue time makes equal
+// amounts pop oldest first.
+func featuredMember(q FeaturedQuestion) string {
+	return fmt.Sprintf("%013d:%d", q.QueuedAt, q.Comment.ID)
+}
+
+// featuredScript returns the featured question, promoting the head of the
+// queue when the slot is free. KEYS: active, queue, entries. Returns the
+// question, its remaining ms and 1 if it was just promoted, or nil when
+// nothing is featured.
+var featuredScript = redis.NewScript(`
+local active = redis.call('GET', KEYS[1])
+if active then
+	return {active, redis.call('PTTL', KEYS[1]), 0}
+end
+while true do
+	local head = redis.call('ZPOPMIN', KEYS[2])
+	if #head == 0 then
+		return false
+	end
+	local id = string.match(head[1], ':(%d+)$')
+	local data = redis.call('HGET', KEYS[3], id)
+	redis.call('HDEL', KEYS[3], id)
+	if data then
+		local duration = tonumber(string.match(data, '"duration":(%d+)'))
+		redis.call('SET', KEYS[1], data, 'PX', duration)
+		return {data, duration, 1}
+	end
+end
+`)
+
+// currentFeaturedQuestion returns the stream's featured question, or nil
+func currentFeaturedQuestion(ctx context.Context, streamID int64) *FeaturedQuestion {
+	keys := []string{featuredActiveKey(streamID), featuredQueueKey(streamID), featuredEntriesKey(streamID)}
+	res, err := featuredScript.Run(ctx, rdb, keys).Slice()
+	if err != nil {
+		if err != redis.Nil {
+			log.Printf("[GO] Stream %d: Error loading featured question: %v", streamID, err)
+		}
+		return nil
+	}
+	data, _ := res[0].(string)
+	remaining, _ := res[1].(int64)
+	var q FeaturedQuestion
+	if err := json.Unmarshal([]byte(data), &q); err != nil {
+		log.Printf("[GO] Stream %d: Error decoding featured question: %v", streamID, err)
+		return nil
+	}
+	q.Until = time.Now().UnixMilli() + remaining
+	if promoted, _ := res[2].(int64); promoted == 1 {
+		log.Printf("[GO] Stream %d: Featuring question %d (amount %g) for %ds", streamID, q.Comment.ID, q.Amount, q.Duration/1000)
+		publishStreamEvent(ctx, streamID, map[string]interface{}{"type": "question_featured", "comment_id": q.Comment.ID, "amount": q.Amount, "until": q.Until})
+	}
+	return &q
+}
+
+// activeFeaturedQuestion reads the featured question without promoting
+func activeFeaturedQuestion(ctx context.Context, streamID int64) (*FeaturedQuestion, error) {
+	data, err := rdb.Get(ctx, featuredActiveKey(streamID)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var q FeaturedQuestion
+	if err := json.Unmarshal([]byte(data), &q); err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+type FeatureQuestionRequest struct {
+	CommentID flexID  `json:"comment_id" binding:"required"`
+	Amount    float64 `json:"amount" binding:"required,gt=0"`
+}
+
+// featureQuestion queues a paid question for the featured slot
+func featureQuestion(c *gin.Context) {
+	streamID, ok := parseStreamID(c)
+	if !ok {
+		return
+	}
+	var req FeatureQuestionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	reqCtx := c.Request.Context()
+	commentID := strconv.FormatInt(int64(req.CommentID), 10)
+	data, err := rdb.HGet(reqCtx, commentDataKey(streamID), commentID).Result()
+	if err == redis.Nil {
+		c.JSON(404, gin.H{"error": "comment not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error loading comment %s: %v", streamID, commentID, err)
+		c.JSON(500, gin.H{"error": "failed to feature question"})
+		return
+	}
+	var cmt Comment
+	if err := json.Unmarshal([]byte(data), &cmt); err != nil || cmt.Type == commentTypeSystem {
+		c.JSON(400, gin.H{"error": "comment cannot be featured"})
+		return
+	}
+
+	if active, err := activeFeaturedQuestion(reqCtx, streamID); err == nil && active != nil && active.Comment.ID == cmt.ID {
+		c.JSON(409, gin.H{"error": "question is already featured"})
+		return
+	}
+
+	q := FeaturedQuestion{
+		Comment:  cmt,
+		Amount:   req.Amount,
+		Duration: featuredDuration(req.Amount).Milliseconds(),
+		QueuedAt: time.Now().UnixMilli(),
+	}
+	payload, err := json.Marshal(q)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to feature question"})
+		return
+	}
+	added, err := rdb.HSetNX(reqCtx, featuredEntriesKey(streamID), commentID, payload).Result()
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error queueing featured question: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to feature question"})
+		return
+	}
+	if !added {
+		c.JSON(409, gin.H{"error": "question is already queued"})
+		return
+	}
+	if err := rdb.ZAdd(reqCtx, featuredQueueKey(streamID), &redis.Z{Score: -q.Amount, Member: featuredMember(q)}).Err(); err != nil {
+		log.Printf("[GO] Stream %d: Error queueing featured question: %v", streamID, err)
+		rdb.HDel(reqCtx, featuredEntriesKey(streamID), commentID)
+		c.JSON(500, gin.H{"error": "failed to feature question"})
+		return
+	}
+
+	log.Printf("[GO] Stream %d: Queued question %s for featuring (amount %g)", streamID, commentID, q.Amount)
+	auditRequest(c, streamID, "feature_question", &ModerationTarget{Username: cmt.Username}, "", map[string]interface{}{"comment_id": cmt.ID, "amount": q.Amount})
+	// Promotes it right away if the slot is free
+	current := currentFeaturedQuestion(reqCtx, streamID)
+	resp := gin.H{"success": true, "duration": q.Duration / 1000}
+	if current != nil && current.Comment.ID == cmt.ID {
+		resp["featured"] = true
+		resp["until"] = current.Until
+	} else if rank, err := rdb.ZRank(reqCtx, featuredQueueKey(streamID), featuredMember(q)).Result(); err == nil {
+		resp["featured"] = false
+		resp["position"] = rank + 1
+	}
+	c.JSON(200, resp)
+}
+
+type CancelFeaturedRequest struct {
+	CommentID flexID `json:"comment_id" binding:"required"`
+}
+
+// cancelFeaturedQuestion withdraws a promotion, e.g. after a refund. A
+// featured question leaves the slot immediately and the next one takes it.
+func cancelFeaturedQuestion(c *gin.Context) {
+	streamID, ok := parseStreamID(c)
+	if !ok {
+		return
+	}
+	var req CancelFeaturedRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	reqCtx := c.Request.Context()
+	commentID := strconv.FormatInt(int64(req.CommentID), 10)
+	if data, err := rdb.HGet(reqCtx, featuredEntriesKey(streamID), commentID).Result(); err == nil {
+		var q FeaturedQuestion
+		if json.Unmarshal([]byte(data), &q) == nil {
+			rdb.ZRem(reqCtx, featuredQueueKey(streamID), featuredMember(q))
+		}
+		rdb.HDel(reqCtx, featuredEntriesKey(streamID), commentID)
+		log.Printf("[GO] Stream %d: Cancelled queued question %s", streamID, commentID)
+		auditRequest(c, streamID, "cancel_featured_question", nil, "", map[string]interface{}{"comment_id": int64(req.CommentID), "was": "queued"})
+		c.JSON(200, gin.H{"success": true, "was": "queued"})
+		return
+	}
+
+	active, err := activeFeaturedQuestion(reqCtx, streamID)
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error loading featured question: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to cancel question"})
+		return
+	}
+	if active == nil || active.Comment.ID != int64(req.CommentID) {
+		c.JSON(404, gin.H{"error": "question is not featured or queued"})
+		return
+	}
+	if err := rdb.Del(reqCtx, featuredActiveKey(streamID)).Err(); err != nil {
+		log.Printf("[GO] Stream %d: Error clearing featured question: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to cancel question"})
+		return
+	}
+	log.Printf("[GO] Stream %d: Cancelled featured question %s", streamID, commentID)
+	auditRequest(c, streamID, "cancel_featured_question", nil, "", map[string]interface{}{"comment_id": active.Comment.ID, "was": "featured"})
+	publishStreamEvent(reqCtx, streamID, map[string]interface{}{"type": "question_unfeatured", "comment_id": active.Comment.ID})
+	c.JSON(200, gin.H{"success": true, "was": "featured"})
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// feature promotes a stream 1 comment as the backend and returns the response
+func feature(t *testing.T, commentID int64, amount float64) map[string]interface{} {
+	t.Helper()
+	w := request(t, http.MethodPost, "/stream/1/featured-questions", map[string]interface{}{"comment_id": commentID, "amount": amount}, trusted...)
+	expectStatus(t, w, 200)
+	return decode(t, w)
+}
+
+// featuredID returns the ID of the question check-update features, or 0
+func featuredID(t *testing.T) int64 {
+	t.Helper()
+	q, _ := poll(t, 1, "v9", 0)["featured_question"].(map[string]interface{})
+	if q == nil {
+		return 0
+	}
+	return int64(q["comment"].(map[string]interface{})["id"].(float64))
+}
+
+func TestFeaturedQuestionsRotateByAmount(t *testing.T) {
+	resetRedis(t)
+	first := postedID(t, 1, "v1", "alice", "first question")
+	small := postedID(t, 1, "v2", "bob", "small tip")
+	big := postedID(t, 1, "v3", "carol", "big tip")
+	later := postedID(t, 1, "v4", "dave", "same as bob, later")
+
+	if resp := feature(t, first, 1); resp["featured"] != true {
+		t.Fatalf("first question: %v, want featured right away", resp)
+	}
+	feature(t, small, 5)
+	time.Sleep(2 * time.Millisecond)
+	if resp := feature(t, later, 5); resp["position"] != float64(2) {
+		t.Fatalf("equal amount queued later: %v, want position 2", resp)
+	}
+	if resp := feature(t, big, 10); resp["position"] != float64(1) {
+		t.Fatalf("largest amount: %v, want position 1", resp)
+	}
+	if id := featuredID(t); id != first {
+		t.Fatalf("featured = %d, want %d", id, first)
+	}
+
+	// Highest amount next, then equal amounts oldest first
+	for _, want := range []int64{big, small, later} {
+		testRedis.FastForward(featuredMaxDuration)
+		if id := featuredID(t); id != want {
+			t.Fatalf("featured = %d, want %d", id, want)
+		}
+	}
+	testRedis.FastForward(featuredMaxDuration)
+	if id := featuredID(t); id != 0 {
+		t.Fatalf("featured = %d after the queue ran out, want none", id)
+	}
+}
+
+func TestFeaturedQuestionRefunds(t *testing.T) {
+	resetRedis(t)
+	first := postedID(t, 1, "v1", "alice", "first question")
+	second := postedID(t, 1, "v2", "bob", "second question")
+	third := postedID(t, 1, "v3", "carol", "third question")
+	feature(t, first, 1)
+	feature(t, second, 3)
+	feature(t, third, 2)
+
+	cancel := func(id int64) map[string]interface{} {
+		w := request(t, http.MethodPost, "/stream/1/featured-questions/cancel", map[string]interface{}{"comment_id": id}, trusted...)
+		expectStatus(t, w, 200)
+		return decode(t, w)
+	}
+	if resp := cancel(second); resp["was"] != "queued" {
+		t.Fatalf("refunding a queued question: %v", resp)
+	}
+	if resp := cancel(first); resp["was"] != "featured" {
+		t.Fatalf("refunding the featured question: %v", resp)
+	}
+	if id := featuredID(t); id != third {
+		t.Fatalf("featured = %d after refunds, want %d", id, third)
+	}
+	expectStatus(t, request(t, http.MethodPost, "/stream/1/featured-questions/cancel", map[string]interface{}{"comment_id": second}, trusted...), 404)
+}
@@ -0,0 +1,493 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// initialLoadLimit caps how many comments an initial load (last_id == 0) returns
+const initialLoadLimit = 100
+
+// pollMaxComments caps how many comments an update poll returns
+// (POLL_MAX_COMMENTS, 0 = no cap), so a client coming back with an old
+// last_id doesn't pull the whole backlog at once. A capped poll returns the
+// newest comments with truncated and a before to page the older ones with:
+// the client repeats the poll with the same last_id and that before until a
+// page is no longer truncated, keeping the cursor of the first response for
+// its next regular poll. When a single millisecond holds more comments than
+// a page, the page also carries a before_id and the next one continues
+// inside that millisecond with the comments below it.
+var pollMaxComments int
+
+// feedQuery selects the comments a poll should see: index scores in
+// [Min, Max], keeping only the newest Limit when Limit > 0, or the oldest
+// with Oldest. With ApplyDelay the stream's chat delay is subtracted from Max.
+// With BeforeID, comments scored exactly Max are kept only if their ID is
+// below it.
+type feedQuery struct {
+	StreamID   int64
+	Min        int64
+	Max        int64
+	Limit      int
+	Oldest     bool
+	ApplyDelay bool
+	BeforeID   int64
+}
+
+// feedSnapshot is everything check-update reads from the store for one poll.
+// Data is parallel to IDs and only fetched when comments are allowed.
+type feedSnapshot struct {
+	IDs           []string
+	Data          []interface{}
+	AllowComments bool
+	Online        int64
+	Delay         int // seconds the feed was held back by
+}
+
+// decodeComments unmarshals the snapshot's comment data, skipping missing
+// and expired entries. Undecodable entries are reported and, if configured,
+// replaced with a placeholder; missing ones are reported and, if configured,
+// dropped from the index (see corrupt.go).
+func (s feedSnapshot) decodeComments(ctx context.Context, streamID int64, now int64) []Comment {
+	comments := []Comment{}
+	var missing []string
+	for i, d := range s.Data {
+		if d == nil {
+			if i < len(s.IDs) {
+				missing = append(missing, s.IDs[i])
+			}
+			continue
+		}
+
+		var cmt Comment
+		if jsonErr := json.Unmarshal([]byte(d.(string)), &cmt); jsonErr != nil {
+			if i < len(s.IDs) {
+				if placeholder := handleCorruptComment(ctx, streamID, s.IDs[i], d.(string), jsonErr); placeholder != nil {
+					comments = append(comments, *placeholder)
+				}
+			}
+			continue
+		}
+		if !isExpired(cmt, now) {
+			comments = append(comments, cmt)
+		}
+	}
+	if len(missing) > 0 {
+		handleOrphanedEntries(ctx, streamID, missing)
+	}
+	sortByTime(comments)
+	return comments
+}
+
+// useFeedScript runs the read path as a single Lua script (CHECK_UPDATE_LUA).
+// The Go path needs four round-trips (range, allow flag, data, online count)
+// where the script needs one, and the script also reads a consistent view
+// with respect to concurrent writes.
+var useFeedScript bool
+
+// feedNow is the clock every feed read is bounded by (ms). The current
+// millisecond is left out: comments stamped with it may still be in flight,
+// and a cursor that covered it would skip them.
+func feedNow() int64 {
+	return time.Now().UnixMilli() - 1
+}
+
+func loadFeedConfig() {
+	useFeedScript = envBool("CHECK_UPDATE_LUA", true)
+	pollMaxComments = envInt("POLL_MAX_COMMENTS", 500)
+}
+
+// pageFeedQuery limits a feed query to comments older than before, or with
+// a beforeID to comments older than (before, beforeID). The chat delay was
+// already applied to the poll that handed out before, so it only caps the
+// page instead of shifting it back again.
+func pageFeedQuery(ctx context.Context, q *feedQuery, before, beforeID int64) {
+	if q.ApplyDelay {
+		if delay, err := rdb.Get(ctx, delayKey(q.StreamID)).Int(); err == nil && delay > 0 {
+			q.Max -= int64(delay) * 1000
+		}
+		q.ApplyDelay = false
+	}
+	if beforeID <= 0 {
+		if before-1 < q.Max {
+			q.Max = before - 1
+		}
+		return
+	}
+	if before <= q.Max {
+		q.Max, q.BeforeID = before, beforeID
+	}
+}
+
+// truncatePoll cuts a poll that read more than limit comments down to the
+// newest and returns the before (and beforeID) that pages the rest, 0 when
+// nothing was cut. When the cut falls inside a millisecond, that
+// millisecond's comments move to the next page, so no page boundary splits
+// one. When a single millisecond fills the page, the page is cut by ID
+// instead and beforeID continues the millisecond on the next page.
+func truncatePoll(comments []Comment, limit int) ([]Comment, int64, int64) {
+	if limit <= 0 || len(comments) <= limit {
+		return comments, 0, 0
+	}
+	cut := comments[len(comments)-limit-1].Timestamp
+	comments = comments[len(comments)-limit:]
+	oldest := comments[0].Timestamp
+	if cut < oldest {
+		return comments, oldest, 0
+	}
+	split := 0
+	for split < len(comments) && comments[split].Timestamp == oldest {
+		split++
+	}
+	if split == len(comments) {
+		return comments, oldest, comments[0].ID
+	}
+	return comments[split:], oldest + 1, 0
+}
+
+// feedScript mirrors readFeedGo. KEYS: index, data, allow flag, online set,
+// delay. ARGV: min score, max score, limit (0 = no cap), apply delay (1/0),
+// keep the oldest (1/0), before ID (0 = none).
+var feedScript = redis.NewScript(`
+local limit = tonumber(ARGV[3])
+local max = tonumber(ARGV[2])
+local delay = 0
+if ARGV[4] == '1' then
+	delay = math.max(tonumber(redis.call('GET', KEYS[5]) or '0') or 0, 0)
+	max = max - delay * 1000
+end
+
+-- The index breaks score ties by member as a string ("10" before "9"), so
+-- a limit never cuts through a millisecond by member: the millisecond it
+-- falls in is read whole and cut by ID as a number
+local function older(a, b)
+	if #a ~= #b then
+		return #a < #b
+	end
+	return a < b
+end
+local function tied(score)
+	local members = redis.call('ZRANGEBYSCORE', KEYS[1], score, score)
+	table.sort(members, older)
+	return members
+end
+
+local function oldestPage(upper, n)
+	local page = {}
+	if n <= 0 then
+		return page
+	end
+	local oldest = redis.call('ZRANGEBYSCORE', KEYS[1], ARGV[1], upper, 'WITHSCORES', 'LIMIT', 0, n)
+	local boundary = #oldest == 2 * n and oldest[#oldest] or nil
+	for i = 1, #oldest, 2 do
+		if oldest[i + 1] ~= boundary then
+			page[#page + 1] = oldest[i]
+		end
+	end
+	if boundary then
+		local members = tied(boundary)
+		for i = 1, n - #page do
+			page[#page + 1] = members[i]
+		end
+	end
+	return page
+end
+local function newestPage(upper, n)
+	local page = {}
+	if n <= 0 then
+		return page
+	end
+	local newest = redis.call('ZREVRANGEBYSCORE', KEYS[1], upper, ARGV[1], 'WITHSCORES', 'LIMIT', 0, n)
+	local boundary = #newest == 2 * n and newest[#newest] or nil
+	if boundary then
+		local members = tied(boundary)
+		local keep = n
+		for i = 2, #newest, 2 do
+			if newest[i] ~= boundary then
+				keep = keep - 1
+			end
+		end
+		for i = #members - keep + 1, #members do
+			page[#page + 1] = members[i]
+		end
+	end
+	for i = #newest - 1, 1, -2 do
+		if newest[i + 1] ~= boundary then
+			page[#page + 1] = newest[i]
+		end
+	end
+	return page
+end
+
+-- With a before ID, the newest millisecond only counts the IDs below it
+-- and the rest of the range stops short of it
+local upper = max
+local top = {}
+if ARGV[6] ~= '0' and max >= tonumber(ARGV[1]) then
+	upper = '(' .. max
+	for _, id in ipairs(tied(max)) do
+		if older(id, ARGV[6]) then
+			top[#top + 1] = id
+		end
+	end
+end
+
+local ids
+if limit > 0 and ARGV[5] == '1' then
+	ids = oldestPage(upper, limit)
+	for i = 1, math.min(limit - #ids, #top) do
+		ids[#ids + 1] = top[i]
+	end
+elseif limit > 0 then
+	ids = newestPage(upper, limit - #top)
+	for i = math.max(#top - limit + 1, 1), #top do
+		ids[#ids + 1] = top[i]
+	end
+else
+	ids = redis.call('ZRANGEBYSCORE', KEYS[1], ARGV[1], upper)
+	for _, id in ipairs(top) do
+		ids[#ids + 1] = id
+	end
+end
+
+local flag = redis.call('GET', KEYS[3])
+local allowed = (not flag) or flag == '1' or flag == 'true'
+
+local data = {}
+if allowed and #ids > 0 then
+	data = redis.call('HMGET', KEYS[2], unpack(ids))
+end
+
+return {allowed and 1 or 0, redis.call('SCARD', KEYS[4]), ids, data, delay}
+`)
+
+// readRedisFeed loads a poll's snapshot from Redis, preferring the Lua script
+// and falling back to individual commands if the script fails. With a read
+// replica it reads there first and falls back to the primary (see replica.go).
+func readRedisFeed(ctx context.Context, q feedQuery) feedSnapshot {
+	if replicaEnabled {
+		snap, err := readFeedFrom(ctx, feedRdb, q)
+		if err == nil {
+			return snap
+		}
+		log.Printf("[GO] Stream %d: Replica feed read failed, using the primary: %v", q.StreamID, err)
+	}
+	snap, _ := readFeedFrom(ctx, rdb, q)
+	return snap
+}
+
+// readFeedFrom reads a snapshot from one Redis client. The error is only set
+// when the client couldn't be read at all.
+func readFeedFrom(ctx context.Context, client *redis.Client, q feedQuery) (feedSnapshot, error) {
+	if useFeedScript {
+		snap, err := readFeedScript(ctx, client, q)
+		if err == nil {
+			return snap, nil
+		}
+		log.Printf("[GO] Stream %d: Feed script failed, falling back: %v", q.StreamID, err)
+	}
+	return readFeedGo(ctx, client, q)
+}
+
+func readFeedScript(ctx context.Context, client *redis.Client, q feedQuery) (feedSnapshot, error) {
+	keys := []string{
+		commentIndexKey(q.StreamID),
+		commentDataKey(q.StreamID),
+		allowCommentsKey(q.StreamID),
+		onlineSetKey(q.StreamID),
+		delayKey(q.StreamID),
+	}
+	applyDelay, oldest := 0, 0
+	if q.ApplyDelay {
+		applyDelay = 1
+	}
+	if q.Oldest {
+		oldest = 1
+	}
+	res, err := feedScript.Run(ctx, client, keys, q.Min, q.Max, q.Limit, applyDelay, oldest, q.BeforeID).Slice()
+	if err != nil {
+		return feedSnapshot{}, err
+	}
+
+	snap := feedSnapshot{}
+	if len(res) != 5 {
+		return snap, redis.Nil
+	}
+	allowed, _ := res[0].(int64)
+	snap.AllowComments = allowed == 1
+	snap.Online, _ = res[1].(int64)
+	if ids, ok := res[2].([]interface{}); ok {
+		snap.IDs = make([]string, 0, len(ids))
+		for _, id := range ids {
+			if s, ok := id.(string); ok {
+				snap.IDs = append(snap.IDs, s)
+			}
+		}
+	}
+	if data, ok := res[3].([]interface{}); ok && len(data) > 0 {
+		snap.Data = data
+	}
+	delay, _ := res[4].(int64)
+	snap.Delay = int(delay)
+	return snap, nil
+}
+
+// readFeedIDs reads the IDs a query selects, in publication order
+func readFeedIDs(ctx context.Context, client *redis.Client, q feedQuery) ([]string, error) {
+	min, max := strconv.FormatInt(q.Min, 10), strconv.FormatInt(q.Max, 10)
+	var top []string
+	if q.BeforeID > 0 && q.Max >= q.Min {
+		tied, err := readTied(ctx, client, q.StreamID, max)
+		if err != nil {
+			return nil, err
+		}
+		beforeID := strconv.FormatInt(q.BeforeID, 10)
+		for _, id := range tied {
+			if olderID(id, beforeID) {
+				top = append(top, id)
+			}
+		}
+		max = "(" + max
+	}
+
+	if q.Limit <= 0 {
+		// Update: get only the ones inside the range
+		ids, err := client.ZRangeByScore(ctx, commentIndexKey(q.StreamID), &redis.ZRangeBy{Min: min, Max: max}).Result()
+		return append(ids, top...), err
+	}
+	if q.Oldest {
+		ids, err := readIndexPage(ctx, client, q.StreamID, min, max, q.Limit, true)
+		if n := q.Limit - len(ids); n > 0 {
+			if n > len(top) {
+				n = len(top)
+			}
+			ids = append(ids, top[:n]...)
+		}
+		return ids, err
+	}
+	if len(top) >= q.Limit {
+		return top[len(top)-q.Limit:], nil
+	}
+	ids, err := readIndexPage(ctx, client, q.StreamID, min, max, q.Limit-len(top), false)
+	return append(ids, top...), err
+}
+
+// readTied reads the IDs scored exactly score, oldest first
+func readTied(ctx context.Context, client *redis.Client, streamID int64, score string) ([]string, error) {
+	tied, err := client.ZRangeByScore(ctx, commentIndexKey(streamID), &redis.ZRangeBy{Min: score, Max: score}).Result()
+	sort.Slice(tied, func(i, j int) bool { return olderID(tied[i], tied[j]) })
+	return tied, err
+}
+
+// readIndexPage reads the limit oldest or newest IDs scored in [min, max],
+// in publication order. Like the feed script, it reads the millisecond the
+// limit falls in whole and cuts it by ID as a number.
+func readIndexPage(ctx context.Context, client *redis.Client, streamID int64, min, max string, limit int, oldest bool) ([]string, error) {
+	rng := &redis.ZRangeBy{Min: min, Max: max, Count: int64(limit)}
+	var page []redis.Z
+	var err error
+	if oldest {
+		page, err = client.ZRangeByScoreWithScores(ctx, commentIndexKey(streamID), rng).Result()
+	} else {
+		page, err = client.ZRevRangeByScoreWithScores(ctx, commentIndexKey(streamID), rng).Result()
+	}
+	if err != nil {
+		return nil, err
+	}
+	var boundary float64
+	full := len(page) == limit
+	if full {
+		boundary = page[len(page)-1].Score
+	}
+	ids := make([]string, 0, limit)
+	var tied []string
+	if full {
+		if tied, err = readTied(ctx, client, streamID, strconv.FormatFloat(boundary, 'f', -1, 64)); err != nil {
+			return nil, err
+		}
+	}
+	above := make([]redis.Z, 0, len(page))
+	for _, z := range page {
+		if !full || z.Score != boundary {
+			above = append(above, z)
+		}
+	}
+	sort.Slice(above, func(i, j int) bool {
+		if above[i].Score != above[j].Score {
+			return above[i].Score < above[j].Score
+		}
+		return olderID(above[i].Member.(string), above[j].Member.(string))
+	})
+	keep := limit - len(above)
+	if keep > len(tied) {
+		keep = len(tied)
+	}
+	if oldest {
+		for _, z := range above {
+			ids = append(ids, z.Member.(string))
+		}
+		return append(ids, tied[:keep]...), nil
+	}
+	ids = append(ids, tied[len(tied)-keep:]...)
+	for _, z := range above {
+		ids = append(ids, z.Member.(string))
+	}
+	return ids, nil
+}
+
+func readFeedGo(ctx context.Context, client *redis.Client, q feedQuery) (feedSnapshot, error) {
+	delay := 0
+	if q.ApplyDelay {
+		if v, delayErr := client.Get(ctx, delayKey(q.StreamID)).Int(); delayErr == nil && v > 0 {
+			delay = v
+			q.Max -= int64(delay) * 1000
+		}
+	}
+
+	ids, err := readFeedIDs(ctx, client, q)
+	if err != nil {
+		log.Printf("[GO] Error getting comments from Redis: %v", err)
+		ids = []string{}
+	}
+
+	snap := feedSnapshot{IDs: ids, AllowComments: true, Delay: delay} // Default to true if not set
+
+	// Get allow_comments status from Redis (set by backend when toggled)
+	allowCommentsStr, allowErr := client.Get(ctx, allowCommentsKey(q.StreamID)).Result()
+	if allowErr == nil {
+		// Check for "1" (true) or "true" (string), anything else is false
+		snap.AllowComments = flagEnabled(allowCommentsStr)
+		log.Printf("[GO] Stream %d: allow_comments from Redis = '%s' -> %v", q.StreamID, allowCommentsStr, snap.AllowComments)
+	} else {
+		log.Printf("[GO] Stream %d: allow_comments not found in Redis, defaulting to true (error: %v)", q.StreamID, allowErr)
+	}
+
+	// Only get comments if allow_comments is true
+	if snap.AllowComments {
+		if len(ids) > 0 {
+			data, dataErr := client.HMGet(ctx, commentDataKey(q.StreamID), ids...).Result()
+			if dataErr == nil {
+				snap.Data = data
+			} else {
+				log.Printf("[GO] Error getting comment data from Redis: %v", dataErr)
+			}
+		} else {
+			log.Printf("[GO] Stream %d: No comment IDs found (err: %v, ids count: %d)", q.StreamID, err, len(ids))
+		}
+	}
+
+	online, onlineErr := client.SCard(ctx, onlineSetKey(q.StreamID)).Result()
+	if onlineErr == nil {
+		snap.Online = online
+	}
+	if err != nil && allowErr != nil && allowErr != redis.Nil && onlineErr != nil {
+		return snap, err
+	}
+	return snap, nil
+}
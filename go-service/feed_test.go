@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// saveAt stores comments with the given IDs under one timestamp
+func saveAt(t testing.TB, ts int64, ids ...int64) {
+	t.Helper()
+	for _, id := range ids {
+		cmt := &Comment{ID: id, Username: "alice", Message: fmt.Sprintf("m%d", id), Timestamp: ts}
+		payload, _ := json.Marshal(cmt)
+		if err := store.SaveComment(ctx, 1, "v1", cmt, payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// checkTiedPages reads capped pages across a millisecond shared by IDs 8 to
+// 11, whose members sort "10" < "11" < "8" < "9" as strings
+func checkTiedPages(t *testing.T) {
+	t.Helper()
+	ts := time.Now().Add(-time.Minute).UnixMilli()
+	saveAt(t, ts-1, 20)
+	saveAt(t, ts, 11, 9, 10, 8)
+	saveAt(t, ts+1, 3)
+
+	for _, tc := range []struct {
+		limit  int
+		oldest bool
+		want   string
+	}{
+		{2, false, "11 3"},
+		{3, false, "10 11 3"},
+		{5, false, "8 9 10 11 3"},
+		{2, true, "20 8"},
+		{4, true, "20 8 9 10"},
+		{6, true, "20 8 9 10 11 3"},
+	} {
+		// Only which comments make the page matters; check-update orders
+		// them afterwards
+		snap := store.ReadFeed(ctx, feedQuery{StreamID: 1, Max: ts + 1, Limit: tc.limit, Oldest: tc.oldest})
+		sort.Slice(snap.IDs, func(i, j int) bool { return olderID(snap.IDs[i], snap.IDs[j]) })
+		want := strings.Fields(tc.want)
+		sort.Slice(want, func(i, j int) bool { return olderID(want[i], want[j]) })
+		if got := strings.Join(snap.IDs, " "); got != strings.Join(want, " ") {
+			t.Errorf("limit %d oldest %v: IDs = %s, want %s", tc.limit, tc.oldest, got, tc.want)
+		}
+	}
+}
+
+func TestCappedFeedOrdersTiesByID(t *testing.T) {
+	for _, script := range []bool{true, false} {
+		resetRedis(t)
+		setVar(t, &useFeedScript, script)
+		checkTiedPages(t)
+	}
+}
+
+func TestMemoryStoreCappedFeedOrdersTiesByID(t *testing.T) {
+	withMemoryStore(t)
+	checkTiedPages(t)
+}
+
+// pageAll polls stream 1 from last_id 1, following before and before_id
+// until a page isn't truncated, and returns every message delivered
+func pageAll(t *testing.T) []string {
+	t.Helper()
+	var all []string
+	body := map[string]interface{}{"stream_id": 1, "viewer_id": "v2", "last_id": 1}
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatalf("still truncated after %d pages: %v", pages, all)
+		}
+		w := request(t, http.MethodPost, "/check-update", body)
+		expectStatus(t, w, 200)
+		resp := decode(t, w)
+		all = append(messages(resp), all...)
+		if resp["truncated"] != true {
+			return all
+		}
+		body["before"], body["before_id"] = resp["before"], resp["before_id"]
+	}
+}
+
+func checkMillisecondPaging(t *testing.T) {
+	t.Helper()
+	setVar(t, &pollMaxComments, 3)
+	ts := time.Now().Add(-time.Minute).UnixMilli()
+	saveAt(t, ts-1, 1)
+	saveAt(t, ts, 12, 9, 10, 11, 8, 13, 7)
+	saveAt(t, ts+1, 2)
+
+	want := "m1 m7 m8 m9 m10 m11 m12 m13 m2"
+	if got := strings.Join(pageAll(t), " "); got != want {
+		t.Fatalf("paged messages = %s, want %s", got, want)
+	}
+}
+
+func TestTruncatedPollPagesInsideAMillisecond(t *testing.T) {
+	for _, script := range []bool{true, false} {
+		resetRedis(t)
+		setVar(t, &useFeedScript, script)
+		checkMillisecondPaging(t)
+	}
+}
+
+func TestMemoryStoreTruncatedPollPagesInsideAMillisecond(t *testing.T) {
+	withMemoryStore(t)
+	checkMillisecondPaging(t)
+}
+
+func TestChatDelayHoldsBackViewersNotModerators(t *testing.T) {
+	for _, script := range []bool{true, false} {
+		resetRedis(t)
+		setVar(t, &useFeedScript, script)
+		now := time.Now()
+		saveAt(t, now.Add(-10*time.Second).UnixMilli(), 1)
+		saveAt(t, now.Add(-2*time.Second).UnixMilli(), 2)
+		rdb.Set(ctx, delayKey(1), 5, 0)
+
+		resp := poll(t, 1, "v2", 0)
+		if got := strings.Join(messages(resp), " "); got != "m1" || resp["delay"] != float64(5) {
+			t.Fatalf("delayed feed (script %v) = %s delay %v, want m1 held back 5s", script, got, resp["delay"])
+		}
+		resp = poll(t, 1, "v2", 0, asRole(roleModerator)...)
+		if got := strings.Join(messages(resp), " "); got != "m1 m2" || resp["delay"] != nil {
+			t.Fatalf("moderator feed (script %v) = %s delay %v, want both undelayed", script, got, resp["delay"])
+		}
+
+		rdb.Del(ctx, delayKey(1))
+		if got := strings.Join(messages(poll(t, 1, "v2", 0)), " "); got != "m1 m2" {
+			t.Fatalf("feed without a delay (script %v) = %s, want both immediately", script, got)
+		}
+	}
+}
+
+func TestTruncatePollCursor(t *testing.T) {
+	at := func(ts, id int64) Comment { return Comment{ID: id, Timestamp: ts} }
+
+	// The cut falls inside a millisecond: it moves to the next page
+	page, before, beforeID := truncatePoll([]Comment{at(1, 1), at(2, 2), at(2, 3), at(3, 4)}, 2)
+	if len(page) != 1 || page[0].ID != 4 || before != 3 || beforeID != 0 {
+		t.Fatalf("partial millisecond: page %v before %d/%d, want [4] before 3/0", page, before, beforeID)
+	}
+	// A single millisecond fills the page: it is cut by ID
+	page, before, beforeID = truncatePoll([]Comment{at(2, 1), at(2, 2), at(2, 3)}, 2)
+	if len(page) != 2 || page[0].ID != 2 || before != 2 || beforeID != 2 {
+		t.Fatalf("full millisecond: page %v before %d/%d, want [2 3] before 2/2", page, before, beforeID)
+	}
+}
+
+// roundTrips counts a client's calls to Redis: one per command or pipeline
+type roundTrips struct{ n int64 }
+
+func (r *roundTrips) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	atomic.AddInt64(&r.n, 1)
+	return ctx, nil
+}
+
+func (r *roundTrips) AfterProcess(ctx context.Context, cmd redis.Cmder) error { return nil }
+
+func (r *roundTrips) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	atomic.AddInt64(&r.n, 1)
+	return ctx, nil
+}
+
+func (r *roundTrips) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error { return nil }
+
+// BenchmarkCheckUpdate polls a stream holding 300 comments with the feed
+// script and with the Go fallback, reporting Redis round trips per poll
+func BenchmarkCheckUpdate(b *testing.B) {
+	for _, bc := range []struct {
+		name   string
+		script bool
+	}{{"script", true}, {"go", false}} {
+		b.Run(bc.name, func(b *testing.B) {
+			resetRedis(b)
+			setVar(b, &useFeedScript, bc.script)
+			start := time.Now().Add(-time.Hour).UnixMilli()
+			for i := int64(0); i < 300; i++ {
+				saveAt(b, start+i, goCommentIDBase+i+1)
+			}
+			trips := &roundTrips{}
+			client := redis.NewClient(&redis.Options{Addr: testRedis.Addr()})
+			client.AddHook(trips)
+			b.Cleanup(func() { client.Close() })
+			setVar(b, &rdb, client)
+
+			for _, poll := range []struct {
+				name   string
+				lastID int64
+			}{{"initial", 0}, {"update", start + 250}} {
+				body, _ := json.Marshal(map[string]interface{}{"stream_id": 1, "viewer_id": "v1", "last_id": poll.lastID})
+				b.Run(poll.name, func(b *testing.B) {
+					atomic.StoreInt64(&trips.n, 0)
+					for i := 0; i < b.N; i++ {
+						req := httptest.NewRequest(http.MethodPost, "/check-update", bytes.NewReader(body))
+						req.Header.Set("Content-Type", "application/json")
+						w := httptest.NewRecorder()
+						testRouter.ServeHTTP(w, req)
+						if w.Code != 200 {
+							b.Fatalf("status %d: %s", w.Code, w.Body.String())
+						}
+					}
+					b.ReportMetric(float64(atomic.LoadInt64(&trips.n))/float64(b.N), "roundtrips/op")
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// Every submitted comment's outcome is counted against the filter that
+// decided it, so streamers can see which rules do the work and whether any
+// is too aggressive: per stream in the stats hash, which starting a broadcast
+// resets, shown on GET /stream/:id/stats, and for the whole service in the
+// comment_filter_actions_total counter. Comments that pass every filter count
+// as accepted, copies folded into an earlier comment as collapsed, and
+// comments swallowed by a shadow ban as hidden. Rescans count the published
+// comments they redact.
+const (
+	filterRejected  = "rejected"
+	filterMasked    = "masked"
+	filterAccepted  = "accepted"
+	filterRedacted  = "redacted"
+	filterCollapsed = "collapsed"
+	filterHidden    = "hidden"
+)
+
+const filterActionsHelp = "Comment submissions by the filter that decided them and its action"
+
+// rejectionFilters maps rejection reasons to the filter behind them.
+// Reasons missing here are malformed requests, not filtering.
+var rejectionFilters = map[string]string{
+	"banned":             "bans",
+	"geo_blocked":        "geo",
+	"reserved_name":      "reserved_names",
+	"invalid_characters": "sanitizer",
+	"too_new":            "new_viewers",
+	"script_not_allowed": "scripts",
+	"profanity":          "profanity",
+	"emote_only":         "emote_only",
+	"too_many_emoji":     "emoji_density",
+	"chat_disabled":      "chat_disabled",
+	"link_cooldown":      "links",
+	"similar_message":    "similarity",
+	"rate_limited":       "rate_limit",
+	"flood":              "flood",
+	"slow_mode":          "slow_mode",
+	"quota_exhausted":    "quota",
+}
+
+// filterOutcome returns the filter and action a submission ended with, ""
+// when it isn't counted
+func filterOutcome(cmt *Comment, rejection *commentRejection) (string, string) {
+	switch {
+	case rejection != nil:
+		if filter, ok := rejectionFilters[rejection.Reason]; ok {
+			return filter, filterRejected
+		}
+		return "", ""
+	case cmt != nil && cmt.shadowed:
+		return "shadow_bans", filterHidden
+	case cmt != nil && cmt.collapsed:
+		return "dedup", filterCollapsed
+	case cmt != nil && cmt.filtered != "":
+		return "profanity", filterMasked
+	case cmt != nil:
+		return "all", filterAccepted
+	}
+	return "", ""
+}
+
+// recordFilterOutcome counts a submission's outcome
+func recordFilterOutcome(ctx context.Context, streamID int64, cmt *Comment, rejection *commentRejection) {
+	filter, action := filterOutcome(cmt, rejection)
+	if filter == "" {
+		return
+	}
+	newCounterSeries("comment_filter_actions_total", fmt.Sprintf("filter=%q,action=%q", filter, action), filterActionsHelp).Inc()
+	fields := []string{filter + ":" + action}
+	if action == filterMasked {
+		// Masked comments are still published
+		fields = append(fields, "all:"+filterAccepted)
+	}
+	_, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, field := range fields {
+			pipe.HIncrBy(ctx, filterStatsKey(streamID), field, 1)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error recording filter stats: %v", streamID, err)
+	}
+	if action == filterAccepted || action == filterMasked {
+		recordPlatform(ctx, streamID, cmt)
+	}
+}
+
+// recordRedactions counts comments a rescan redacted
+func recordRedactions(ctx context.Context, streamID int64, n int) {
+	newCounterSeries("comment_filter_actions_total", fmt.Sprintf("filter=%q,action=%q", "profanity", filterRedacted), filterActionsHelp).Add(int64(n))
+	if err := rdb.HIncrBy(ctx, filterStatsKey(streamID), "profanity:"+filterRedacted, int64(n)).Err(); err != nil {
+		log.Printf("[GO] Stream %d: Error recording filter stats: %v", streamID, err)
+	}
+}
+
+// getFilterStats shows moderators the current broadcast's filter outcomes
+func getFilterStats(c *gin.Context) {
+	streamID, ok := parseStreamID(c)
+	if !ok {
+		return
+	}
+	reqCtx := c.Request.Context()
+	fields, err := rdb.HGetAll(reqCtx, filterStatsKey(streamID)).Result()
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error loading filter stats: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to load stats"})
+		return
+	}
+
+	var accepted int64
+	filters := map[string]map[string]int64{}
+	for field, v := range fields {
+		filter, action, ok := strings.Cut(field, ":")
+		n, convErr := strconv.ParseInt(v, 10, 64)
+		if !ok || convErr != nil {
+			continue
+		}
+		if filter == "all" {
+			accepted = n
+			continue
+		}
+		if filters[filter] == nil {
+			filters[filter] = map[string]int64{}
+		}
+		filters[filter][action] = n
+	}
+
+	resp := gin.H{"stream_id": streamID, "accepted": accepted, "filters": filters}
+	if platforms, sources, err := loadPlatformStats(reqCtx, streamID); err != nil {
+		log.Printf("[GO] Stream %d: Error loading platform stats: %v", streamID, err)
+	} else {
+		resp["platforms"] = platforms
+		resp["sources"] = sources
+	}
+	if top, err := topEmotes(reqCtx, streamID); err != nil {
+		log.Printf("[GO] Stream %d: Error loading emote usage: %v", streamID, err)
+	} else {
+		resp["top_emotes"] = top
+	}
+	if status, statusErr := loadStreamStatus(reqCtx, streamID); statusErr == nil && status.StartedAt > 0 {
+		resp["since"] = status.StartedAt
+	}
+	c.JSON(200, resp)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// filterStats reads stream 1's filter stats as a moderator
+func filterStats(t *testing.T) map[string]interface{} {
+	t.Helper()
+	w := request(t, http.MethodGet, "/stream/1/stats", nil, asRole(roleModerator)...)
+	expectStatus(t, w, 200)
+	return decode(t, w)
+}
+
+// filterCount reads one filter's action count from a stats response
+func filterCount(stats map[string]interface{}, filter, action string) float64 {
+	actions, _ := stats["filters"].(map[string]interface{})[filter].(map[string]interface{})
+	n, _ := actions[action].(float64)
+	return n
+}
+
+// filterMetric is the service-wide counter for a filter's action
+func filterMetric(filter, action string) *counter {
+	return newCounterSeries("comment_filter_actions_total", fmt.Sprintf("filter=%q,action=%q", filter, action), filterActionsHelp)
+}
+
+func TestFilterStatsCountEachAction(t *testing.T) {
+	resetRedis(t)
+	withProfanityWords(t, tierMild, "gosh")
+	withProfanityWords(t, tierSevere, "heck")
+	maskedBefore := filterMetric("profanity", filterMasked).Value()
+	rejectedBefore := filterMetric("profanity", filterRejected).Value()
+
+	expectStatus(t, post(t, 1, "v1", "alice", "hello"), 200)
+	expectStatus(t, post(t, 1, "v2", "bob", "oh gosh"), 200)
+	expectStatus(t, post(t, 1, "v3", "carol", "what the heck"), 403)
+	if err := storeBan(ctx, 1, ModerationTarget{ViewerID: "v4"}, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, post(t, 1, "v4", "dave", "let me in"), 403)
+	rdb.HSet(ctx, modesKey(1), "slow_mode", "30")
+	expectStatus(t, post(t, 1, "v5", "erin", "first"), 200)
+	expectStatus(t, post(t, 1, "v5", "erin", "second"), 429)
+	// Malformed requests aren't filtering
+	expectStatus(t, request(t, http.MethodPost, "/post-comment", map[string]interface{}{
+		"stream_id": 1, "viewer_id": "v6", "username": "frank", "message": "hi", "min_tier": "nope",
+	}), 400)
+
+	stats := filterStats(t)
+	for _, tc := range []struct {
+		filter, action string
+		want           float64
+	}{
+		{"profanity", filterMasked, 1},
+		{"profanity", filterRejected, 1},
+		{"bans", filterRejected, 1},
+		{"slow_mode", filterRejected, 1},
+	} {
+		if got := filterCount(stats, tc.filter, tc.action); got != tc.want {
+			t.Errorf("%s %s = %v, want %v", tc.filter, tc.action, got, tc.want)
+		}
+	}
+	// Masked comments are published, so they count as accepted too
+	if stats["accepted"] != float64(3) || len(stats["filters"].(map[string]interface{})) != 3 {
+		t.Fatalf("stats = %v, want 3 accepted across 3 filters", stats)
+	}
+	if got := filterMetric("profanity", filterMasked).Value() - maskedBefore; got != 1 {
+		t.Errorf("masked counter grew by %d, want 1", got)
+	}
+	if got := filterMetric("profanity", filterRejected).Value() - rejectedBefore; got != 1 {
+		t.Errorf("rejected counter grew by %d, want 1", got)
+	}
+}
+
+func TestFilterStatsResetWhenABroadcastStarts(t *testing.T) {
+	resetRedis(t)
+	rdb.HSet(ctx, modesKey(1), "emote_only", "1")
+	expectStatus(t, post(t, 1, "v1", "alice", "words"), 403)
+	if got := filterCount(filterStats(t), "emote_only", filterRejected); got != 1 {
+		t.Fatalf("emote_only rejected = %v, want 1", got)
+	}
+
+	if resp := lifecycle(t, "start", nil); resp["status"] != float64(200) {
+		t.Fatalf("start: %v", resp)
+	}
+	stats := filterStats(t)
+	if len(stats["filters"].(map[string]interface{})) != 0 || stats["accepted"] != float64(0) || stats["since"] == nil {
+		t.Fatalf("stats after starting = %v, want them reset", stats)
+	}
+}
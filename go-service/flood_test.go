@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestFloodCapCountsOnlyAcceptedComments(t *testing.T) {
+	resetRedis(t)
+	rdb.HSet(ctx, modesKey(1), "flood_cap", 2, "slow_mode", 60)
+
+	expectStatus(t, post(t, 1, "v1", "alice", "first"), 200)
+	// Refused by slow-mode after the flood check: not counted
+	w := post(t, 1, "v1", "alice", "too soon")
+	if resp := decode(t, w); w.Code != 429 || resp["reason"] != "slow_mode" {
+		t.Fatalf("second post from v1: %d %v, want 429 slow_mode", w.Code, resp)
+	}
+	expectStatus(t, post(t, 1, "v2", "bob", "second"), 200)
+
+	for i := 0; i < 3; i++ {
+		w = post(t, 1, "v3", "carol", "flooding")
+		resp := decode(t, w)
+		if w.Code != 429 || resp["reason"] != "flood" || resp["retry_after"] == nil {
+			t.Fatalf("post over the cap: %d %v, want 429 flood with retry_after", w.Code, resp)
+		}
+	}
+	if n, _ := rdb.Get(ctx, floodKey(1)).Int(); n != 2 {
+		t.Fatalf("flood count = %d, want the 2 accepted comments", n)
+	}
+	if resp := poll(t, 1, "v4", 0); resp["throttled"] != true {
+		t.Fatalf("throttled = %v, want true", resp["throttled"])
+	}
+
+	// The window rolling over lets posts through again
+	testRedis.FastForward(floodWindow)
+	expectStatus(t, post(t, 1, "v3", "carol", "later"), 200)
+}
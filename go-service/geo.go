@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// Streamers can restrict who posts by country. The policy lives in the
+// stream:geo_policy:<stream_id> hash: mode is "allow" (only the listed
+// countries may post) or "deny" (they may not), countries a comma-separated
+// list of ISO 3166-1 alpha-2 codes, and unknown ("allow" or "deny") what
+// happens to posters whose country couldn't be resolved, defaulting to
+// GEO_UNKNOWN_POLICY. The country is resolved by the trusted caller and sent
+// in X-Viewer-Country, like the viewer's role. This gates posting only;
+// reading is unaffected. Moderators and bridged integrations are never
+// blocked.
+const (
+	geoAllow = "allow"
+	geoDeny  = "deny"
+)
+
+var geoUnknownPolicy string
+
+// countryPattern matches an ISO 3166-1 alpha-2 code
+var countryPattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// geoMaxCountries bounds a policy's country list
+const geoMaxCountries = 250
+
+func loadGeoConfig() {
+	geoUnknownPolicy = geoAllow
+	if strings.ToLower(envString("GEO_UNKNOWN_POLICY", geoAllow)) == geoDeny {
+		geoUnknownPolicy = geoDeny
+	}
+}
+
+// GeoPolicy is a stream's country restriction on posting
+type GeoPolicy struct {
+	Mode      string   `json:"mode,omitempty"` // "" when the stream has none
+	Countries []string `json:"countries"`
+	Unknown   string   `json:"unknown"`
+}
+
+// normalizeCountry returns an upper-case country code, or "" when value
+// isn't one. XX and ZZ are what GeoIP databases report for unknown.
+func normalizeCountry(value string) string {
+	code := strings.ToUpper(strings.TrimSpace(value))
+	if !countryPattern.MatchString(code) || code == "XX" || code == "ZZ" {
+		return ""
+	}
+	return code
+}
+
+// requestCountry returns the resolved country of the viewer behind the
+// request, "" when unknown
+func requestCountry(c *gin.Context) string {
+	if !isTrustedRequest(c) {
+		return ""
+	}
+	return normalizeCountry(c.GetHeader("X-Viewer-Country"))
+}
+
+// parseCountries reads a comma-separated country list, dropping invalid and
+// repeated codes
+func parseCountries(list []string) []string {
+	seen := map[string]bool{}
+	countries := []string{}
+	for _, value := range list {
+		if code := normalizeCountry(value); code != "" && !seen[code] {
+			seen[code] = true
+			countries = append(countries, code)
+		}
+	}
+	sort.Strings(countries)
+	return countries
+}
+
+// loadGeoPolicy reads a stream's country policy; Mode is "" when it has none
+func loadGeoPolicy(ctx context.Context, streamID int64) (GeoPolicy, error) {
+	policy := GeoPolicy{Countries: []string{}, Unknown: geoUnknownPolicy}
+	fields, err := rdb.HGetAll(ctx, geoPolicyKey(streamID)).Result()
+	if err != nil {
+		return policy, err
+	}
+	if mode := fields["mode"]; mode == geoAllow || mode == geoDeny {
+		policy.Mode = mode
+	}
+	policy.Countries = parseCountries(strings.Split(fields["countries"], ","))
+	if unknown := fields["unknown"]; unknown == geoAllow || unknown == geoDeny {
+		policy.Unknown = unknown
+	}
+	return policy, nil
+}
+
+// allows reports whether a poster from country ("" = unknown) may post
+func (p GeoPolicy) allows(country string) bool {
+	if p.Mode == "" {
+		return true
+	}
+	if country == "" {
+		return p.Unknown == geoAllow
+	}
+	listed := false
+	for _, code := range p.Countries {
+		if code == country {
+			listed = true
+			break
+		}
+	}
+	return listed == (p.Mode == geoAllow)
+}
+
+// geoBlocked reports whether the stream's policy keeps a poster from
+// country out. A policy that can't be read blocks nobody.
+func geoBlocked(ctx context.Context, streamID int64, country string) bool {
+	policy, err := loadGeoPolicy(ctx, streamID)
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error loading geo policy: %v", streamID, err)
+		return false
+	}
+	return !policy.allows(country)
+}
+
+func getGeoPolicy(c *gin.Context) {
+	streamID, ok := parseStreamID(c)
+	if !ok {
+		return
+	}
+	policy, err := loadGeoPolicy(c.Request.Context(), streamID)
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error loading geo policy: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to load geo policy"})
+		return
+	}
+	c.JSON(200, gin.H{"stream_id": streamID, "policy": policy})
+}
+
+// GeoPolicyRequest replaces a stream's country policy. An empty mode
+// removes it.
+type GeoPolicyRequest struct {
+	Mode      string   `json:"mode"`
+	Countries []string `json:"countries"`
+	Unknown   string   `json:"unknown"`
+}
+
+func setGeoPolicy(c *gin.Context) {
+	streamID, ok := parseStreamID(c)
+	if !ok {
+		return
+	}
+	var req GeoPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	mode := strings.ToLower(strings.TrimSpace(req.Mode))
+	unknown := strings.ToLower(strings.TrimSpace(req.Unknown))
+	if mode != "" && mode != geoAllow && mode != geoDeny {
+		c.JSON(400, gin.H{"error": "mode must be allow or deny"})
+		return
+	}
+	if unknown != "" && unknown != geoAllow && unknown != geoDeny {
+		c.JSON(400, gin.H{"error": "unknown must be allow or deny"})
+		return
+	}
+	for _, value := range req.Countries {
+		if normalizeCountry(value) == "" {
+			c.JSON(400, gin.H{"error": "invalid country code: " + value})
+			return
+		}
+	}
+	countries := parseCountries(req.Countries)
+	if len(countries) > geoMaxCountries {
+		c.JSON(400, gin.H{"error": "too many countries"})
+		return
+	}
+
+	reqCtx := c.Request.Context()
+	_, err := rdb.TxPipelined(reqCtx, func(pipe redis.Pipeliner) error {
+		pipe.Del(reqCtx, geoPolicyKey(streamID))
+		if mode != "" {
+			fields := []interface{}{"mode", mode, "countries", strings.Join(countries, ",")}
+			if unknown != "" {
+				fields = append(fields, "unknown", unknown)
+			}
+			pipe.HSet(reqCtx, geoPolicyKey(streamID), fields...)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error storing geo policy: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to update geo policy"})
+		return
+	}
+
+	log.Printf("[GO] Stream %d: Geo policy set to %q %v", streamID, mode, countries)
+	auditRequest(c, streamID, "geo_policy", nil, "", map[string]interface{}{"mode": mode, "countries": countries, "unknown": unknown})
+	policy, _ := loadGeoPolicy(reqCtx, streamID)
+	c.JSON(200, gin.H{"success": true, "policy": policy})
+}
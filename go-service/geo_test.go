@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+// setGeo replaces stream 1's country policy as a moderator
+func setGeo(t *testing.T, policy map[string]interface{}) int {
+	t.Helper()
+	return request(t, http.MethodPost, "/stream/1/geo-policy", policy, asRole(roleModerator)...).Code
+}
+
+// postFromCountry posts to stream 1 for a viewer in country ("" =
+// unresolved) and returns the status and reason
+func postFromCountry(t *testing.T, country string, headers ...string) (int, interface{}) {
+	t.Helper()
+	if country != "" {
+		headers = append(append([]string{}, headers...), "X-Viewer-Country", country)
+	}
+	w := post(t, 1, "v1", "alice", "hello from "+country, headers...)
+	return w.Code, decode(t, w)["reason"]
+}
+
+func TestGeoPolicyAllowlist(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &geoUnknownPolicy, geoAllow)
+	if code := setGeo(t, map[string]interface{}{"mode": "allow", "countries": []string{"XYZ"}}); code != 400 {
+		t.Fatalf("invalid country: %d, want 400", code)
+	}
+	if code := setGeo(t, map[string]interface{}{"mode": "allow", "countries": []string{"ir", "DE"}}); code != 200 {
+		t.Fatalf("setting the policy: %d", code)
+	}
+
+	for _, tc := range []struct {
+		country string
+		status  int
+	}{
+		{"IR", 200},
+		{"de", 200},
+		{"US", 403},
+		{"", 200},   // unresolved follows GEO_UNKNOWN_POLICY
+		{"ZZ", 200}, // and so does GeoIP's unknown code
+	} {
+		code, reason := postFromCountry(t, tc.country, trusted...)
+		if code != tc.status || (code == 403 && reason != "geo_blocked") {
+			t.Fatalf("poster from %q: %d %v, want %d", tc.country, code, reason, tc.status)
+		}
+	}
+	// A country claimed without the API key counts as unresolved
+	setGeo(t, map[string]interface{}{"mode": "allow", "countries": []string{"IR"}, "unknown": "deny"})
+	if code, reason := postFromCountry(t, "IR"); code != 403 || reason != "geo_blocked" {
+		t.Fatalf("untrusted country claim: %d %v, want 403 geo_blocked", code, reason)
+	}
+	if code, _ := postFromCountry(t, "", trusted...); code != 403 {
+		t.Fatalf("unresolved poster with unknown deny: %d, want 403", code)
+	}
+}
+
+func TestGeoPolicyDenylist(t *testing.T) {
+	resetRedis(t)
+	setGeo(t, map[string]interface{}{"mode": "deny", "countries": []string{"DE"}})
+
+	if code, reason := postFromCountry(t, "DE", trusted...); code != 403 || reason != "geo_blocked" {
+		t.Fatalf("denied country: %d %v, want 403 geo_blocked", code, reason)
+	}
+	if code, _ := postFromCountry(t, "US", trusted...); code != 200 {
+		t.Fatalf("other country: %d, want 200", code)
+	}
+	// Moderators are never blocked, and reading is unaffected
+	if code, _ := postFromCountry(t, "DE", asRole(roleModerator)...); code != 200 {
+		t.Fatalf("moderator from a denied country: %d, want 200", code)
+	}
+	nextSecond()
+	if got := messages(poll(t, 1, "v2", 0, "X-Api-Key", testAPIKey, "X-Viewer-Country", "DE")); len(got) != 2 {
+		t.Fatalf("feed read from a denied country = %v, want both comments", got)
+	}
+}
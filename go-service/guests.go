@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Comments posted without a username (guest mode) get a generated name such
+// as "Guest-4821" so conversations stay followable. The number is derived
+// from the viewer ID, so a viewer keeps their name for as long as they keep
+// their ID (session resumption carries it across reconnects). Names are
+// claimed per stream: a viewer whose number another guest already holds gets
+// the next candidate in their own sequence, with more digits once the short
+// ones run out. GUEST_NAME_PREFIX and GUEST_NAME_DIGITS shape the names;
+// GUEST_NAMES=false requires a username instead. Real accounts can't post
+// under a name in the guest format.
+var (
+	guestNames       bool
+	guestNamePrefix  string
+	guestNameDigits  int
+	guestNameClaimed = authorHistoryTTL
+)
+
+// guestNameAttempts is how many candidates are tried at each length
+const guestNameAttempts = 8
+
+func loadGuestConfig() {
+	guestNames = envBool("GUEST_NAMES", true)
+	guestNamePrefix = envString("GUEST_NAME_PREFIX", "Guest-")
+	guestNameDigits = envInt("GUEST_NAME_DIGITS", 4)
+	if guestNameDigits < 1 || guestNameDigits > 9 {
+		guestNameDigits = 4
+	}
+}
+
+// guestNameCandidate is a viewer's attempt-th possible guest name
+func guestNameCandidate(viewerID string, attempt int) string {
+	digits := guestNameDigits + 2*(attempt/guestNameAttempts)
+	if digits > 18 {
+		digits = 18
+	}
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s:%d", viewerID, attempt)
+	mod := uint64(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%s%0*d", guestNamePrefix, digits, h.Sum64()%mod)
+}
+
+// isGuestName reports whether a username has the generated guest format
+func isGuestName(username string) bool {
+	username = strings.TrimSpace(username)
+	if guestNamePrefix == "" || len(username) <= len(guestNamePrefix) || !strings.EqualFold(username[:len(guestNamePrefix)], guestNamePrefix) {
+		return false
+	}
+	for _, r := range username[len(guestNamePrefix):] {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ownsGuestName reports whether a viewer holds a guest name, so clients can
+// send back the name they were given
+func ownsGuestName(ctx context.Context, streamID int64, viewerID, name string) bool {
+	if viewerID == "" {
+		return false
+	}
+	owner, err := rdb.HGet(ctx, guestNamesKey(streamID), "name:"+strings.ToLower(strings.TrimSpace(name))).Result()
+	return err == nil && owner == viewerID
+}
+
+// assignGuestName returns the viewer's guest name in a stream, claiming the
+// first of their candidates no other guest holds
+func assignGuestName(ctx context.Context, streamID int64, viewerID string) (string, error) {
+	key := guestNamesKey(streamID)
+	if name, err := rdb.HGet(ctx, key, "viewer:"+viewerID).Result(); err == nil {
+		return name, nil
+	} else if err != redis.Nil {
+		return "", err
+	}
+
+	for attempt := 0; attempt < 3*guestNameAttempts; attempt++ {
+		name := guestNameCandidate(viewerID, attempt)
+		claimed, err := rdb.HSetNX(ctx, key, "name:"+strings.ToLower(name), viewerID).Result()
+		if err != nil {
+			return "", err
+		}
+		if !claimed {
+			owner, err := rdb.HGet(ctx, key, "name:"+strings.ToLower(name)).Result()
+			if err != nil && err != redis.Nil {
+				return "", err
+			}
+			if owner != viewerID {
+				continue
+			}
+		}
+		_, err = rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, key, "viewer:"+viewerID, name)
+			pipe.Expire(ctx, key, guestNameClaimed)
+			return nil
+		})
+		return name, err
+	}
+	return "", fmt.Errorf("no guest name available for viewer %s", viewerID)
+}
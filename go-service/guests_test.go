@@ -0,0 +1,76 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// guestPost posts a comment without a username and returns the name it got
+func guestPost(t *testing.T, streamID int64, viewerID string) string {
+	t.Helper()
+	w := post(t, streamID, viewerID, "", "hi")
+	expectStatus(t, w, 200)
+	return decode(t, w)["comment"].(map[string]interface{})["username"].(string)
+}
+
+func TestGuestNamesAreDeterministic(t *testing.T) {
+	resetRedis(t)
+	if a, b := guestNameCandidate("v1", 0), guestNameCandidate("v1", 0); a != b {
+		t.Fatalf("candidates differ: %q %q", a, b)
+	}
+	if !regexp.MustCompile(`^Guest-\d{4}$`).MatchString(guestNameCandidate("v1", 0)) {
+		t.Fatalf("candidate = %q, want Guest- and 4 digits", guestNameCandidate("v1", 0))
+	}
+
+	name := guestPost(t, 1, "v1")
+	if name != guestNameCandidate("v1", 0) {
+		t.Fatalf("guest name = %q, want the viewer's first candidate %q", name, guestNameCandidate("v1", 0))
+	}
+	if again := guestPost(t, 1, "v1"); again != name {
+		t.Fatalf("second post named %q, want %q", again, name)
+	}
+	if other := guestPost(t, 1, "v2"); other == name {
+		t.Fatalf("another viewer got %q too", other)
+	}
+	// Derived from the viewer ID alone, so it's the same in another stream
+	if elsewhere := guestPost(t, 2, "v1"); elsewhere != name {
+		t.Fatalf("stream 2 named %q, want %q", elsewhere, name)
+	}
+}
+
+func TestGuestNameCollisions(t *testing.T) {
+	resetRedis(t)
+	taken := guestNameCandidate("v1", 0)
+	rdb.HSet(ctx, guestNamesKey(1), "name:"+strings.ToLower(taken), "someone-else")
+	if name := guestPost(t, 1, "v1"); name != guestNameCandidate("v1", 1) {
+		t.Fatalf("name = %q, want the next candidate %q", name, guestNameCandidate("v1", 1))
+	}
+
+	// Once the short names run out the viewer gets a longer one
+	for attempt := 0; attempt < guestNameAttempts; attempt++ {
+		rdb.HSet(ctx, guestNamesKey(1), "name:"+strings.ToLower(guestNameCandidate("v2", attempt)), "someone-else")
+	}
+	if name := guestPost(t, 1, "v2"); !regexp.MustCompile(`^Guest-\d{6}$`).MatchString(name) {
+		t.Fatalf("name = %q, want 6 digits", name)
+	}
+}
+
+func TestGuestNameFormatIsReserved(t *testing.T) {
+	resetRedis(t)
+	name := guestPost(t, 1, "v1")
+
+	for _, username := range []string{"Guest-1234", strings.ToLower(name), name} {
+		if w := post(t, 1, "v2", username, "impostor"); w.Code != 403 || decode(t, w)["reason"] != "reserved_name" {
+			t.Fatalf("%q by another viewer: %d %s, want 403 reserved_name", username, w.Code, w.Body.String())
+		}
+	}
+	// The guest may send back the name they were given
+	expectStatus(t, post(t, 1, "v1", name, "still me"), 200)
+	expectStatus(t, post(t, 1, "v2", "Guesthouse", "not the format"), 200)
+
+	setVar(t, &guestNames, false)
+	if w := post(t, 1, "v3", "", "hi"); w.Code != 400 || decode(t, w)["reason"] != "username_required" {
+		t.Fatalf("with guest names off: %d %s, want 400 username_required", w.Code, w.Body.String())
+	}
+}
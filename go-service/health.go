@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// Build information, injected at build time:
+//
+//	go build -ldflags "-X main.buildVersion=1.4.0 -X main.buildCommit=$(git rev-parse --short HEAD)"
+var (
+	buildVersion = "dev"
+	buildCommit  = "unknown"
+)
+
+var startedAt = time.Now()
+
+// Health statuses, worst last
+const (
+	healthHealthy   = "healthy"
+	healthDegraded  = "degraded"
+	healthUnhealthy = "unhealthy"
+)
+
+// healthRedisSlow is the Redis round-trip above which the service reports
+// itself degraded (HEALTH_REDIS_SLOW_MS)
+var healthRedisSlow time.Duration
+
+const healthRedisTimeout = 2 * time.Second
+
+func loadHealthConfig() {
+	healthRedisSlow = time.Duration(envInt("HEALTH_REDIS_SLOW_MS", 100)) * time.Millisecond
+}
+
+// Background jobs report their state here for /health. Every replica runs
+// every job (there is no leader election), so each replica reports its own.
+type jobState struct {
+	Running   bool   `json:"running"`
+	LastRun   int64  `json:"last_run,omitempty"` // ms
+	LastError string `json:"last_error,omitempty"`
+}
+
+type jobTracker struct {
+	mu     sync.Mutex
+	states map[string]*jobState
+}
+
+var jobs = &jobTracker{states: map[string]*jobState{}}
+
+func (t *jobTracker) state(name string) *jobState {
+	s, ok := t.states[name]
+	if !ok {
+		s = &jobState{}
+		t.states[name] = s
+	}
+	return s
+}
+
+// setRunning records that a job started or stopped
+func (t *jobTracker) setRunning(name string, running bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.state(name).Running = running
+}
+
+// ran records the outcome of one run of a periodic job
+func (t *jobTracker) ran(name string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.state(name)
+	s.LastRun = time.Now().UnixMilli()
+	s.LastError = ""
+	if err != nil {
+		s.LastError = err.Error()
+	}
+}
+
+func (t *jobTracker) snapshot() map[string]jobState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make(map[string]jobState, len(t.states))
+	for name, s := range t.states {
+		out[name] = *s
+	}
+	return out
+}
+
+// connections counts the streaming connections (SSE and WebSocket) open on
+// this replica
+func (h *liveHub) connections() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := 0
+	for _, listeners := range h.listeners {
+		n += len(listeners)
+	}
+	return n
+}
+
+type DependencyHealth struct {
+	Status  string  `json:"status"`
+	Latency float64 `json:"latency_ms"`
+	Error   string  `json:"error,omitempty"`
+}
+
+type HealthReport struct {
+	Status       string                      `json:"status"`
+	Service      string                      `json:"service"`
+	Version      string                      `json:"version"`
+	Commit       string                      `json:"commit"`
+	Uptime       int64                       `json:"uptime"` // seconds
+	Dependencies map[string]DependencyHealth `json:"dependencies"`
+	Jobs         map[string]jobState         `json:"jobs"`
+	Connections  int                         `json:"connections"`
+	Degraded     []string                    `json:"degraded,omitempty"` // what pulled the status down
+}
+
+// checkRedis pings a Redis client and rates the round-trip
+func checkRedis(ctx context.Context, client *redis.Client) DependencyHealth {
+	ctx, cancel := context.WithTimeout(ctx, healthRedisTimeout)
+	defer cancel()
+	start := time.Now()
+	err := client.Ping(ctx).Err()
+	dep := DependencyHealth{Status: healthHealthy, Latency: float64(time.Since(start).Microseconds()) / 1000}
+	switch {
+	case err != nil:
+		dep.Status, dep.Error = healthUnhealthy, err.Error()
+	case time.Since(start) > healthRedisSlow:
+		dep.Status = healthDegraded
+	}
+	return dep
+}
+
+// health reports the service's readiness: 200 while it can serve, even if
+// degraded, and 503 when a dependency is down
+func health(c *gin.Context) {
+	report := HealthReport{
+		Status:       healthHealthy,
+		Service:      "comment-polling",
+		Version:      buildVersion,
+		Commit:       buildCommit,
+		Uptime:       int64(time.Since(startedAt).Seconds()),
+		Dependencies: map[string]DependencyHealth{"redis": checkRedis(c.Request.Context(), rdb)},
+		Jobs:         jobs.snapshot(),
+		Connections:  hub.connections(),
+	}
+	if replicaEnabled {
+		// Feed reads fall back to the primary, so a down replica only degrades
+		replica := checkRedis(c.Request.Context(), feedRdb)
+		if replica.Status == healthUnhealthy {
+			replica.Status = healthDegraded
+		}
+		report.Dependencies["redis_replica"] = replica
+	}
+
+	worsen := func(status, what string) {
+		report.Degraded = append(report.Degraded, what)
+		if status == healthUnhealthy || report.Status == healthHealthy {
+			report.Status = status
+		}
+	}
+	for name, dep := range report.Dependencies {
+		if dep.Status != healthHealthy {
+			worsen(dep.Status, name)
+		}
+	}
+	for name, job := range report.Jobs {
+		if !job.Running || job.LastError != "" {
+			worsen(healthDegraded, name)
+		}
+	}
+	sort.Strings(report.Degraded)
+
+	status := 200
+	if report.Status == healthUnhealthy {
+		status = 503
+	}
+	c.JSON(status, report)
+}
+
+// livez only reports that the process is up and serving requests
+func livez(c *gin.Context) {
+	c.JSON(200, gin.H{"status": "alive"})
+}
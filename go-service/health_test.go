@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// checkHealth reads /health and returns its status code and report
+func checkHealth(t *testing.T) (int, map[string]interface{}) {
+	t.Helper()
+	w := request(t, http.MethodGet, "/health", nil)
+	return w.Code, decode(t, w)
+}
+
+// degradedBy lists what a health report says pulled it down
+func degradedBy(report map[string]interface{}) []string {
+	list, _ := report["degraded"].([]interface{})
+	out := make([]string, 0, len(list))
+	for _, what := range list {
+		out = append(out, what.(string))
+	}
+	return out
+}
+
+func TestHealthHealthy(t *testing.T) {
+	setVar(t, &jobs, &jobTracker{states: map[string]*jobState{}})
+	setVar(t, &buildVersion, "1.4.0")
+	setVar(t, &buildCommit, "abc123")
+	jobs.setRunning("compaction", true)
+	jobs.ran("compaction", nil)
+
+	status, report := checkHealth(t)
+	if status != 200 || report["status"] != healthHealthy || report["degraded"] != nil {
+		t.Fatalf("health = %d %v, want 200 healthy", status, report)
+	}
+	if report["version"] != "1.4.0" || report["commit"] != "abc123" {
+		t.Fatalf("build = %v %v, want the injected version and commit", report["version"], report["commit"])
+	}
+	redisHealth := report["dependencies"].(map[string]interface{})["redis"].(map[string]interface{})
+	if redisHealth["status"] != healthHealthy {
+		t.Fatalf("redis = %v, want healthy", redisHealth)
+	}
+	if job := report["jobs"].(map[string]interface{})["compaction"].(map[string]interface{}); job["running"] != true || job["last_run"] == nil {
+		t.Fatalf("compaction job = %v, want running with a last run", job)
+	}
+}
+
+func TestHealthDegraded(t *testing.T) {
+	setVar(t, &jobs, &jobTracker{states: map[string]*jobState{}})
+	jobs.setRunning("compaction", true)
+	jobs.ran("compaction", errors.New("boom"))
+	jobs.setRunning("expiry", false)
+
+	status, report := checkHealth(t)
+	if got := degradedBy(report); status != 200 || report["status"] != healthDegraded || len(got) != 2 || got[0] != "compaction" || got[1] != "expiry" {
+		t.Fatalf("health = %d %v, want 200 degraded by [compaction expiry]", status, report)
+	}
+
+	// A slow Redis degrades too
+	setVar(t, &jobs, &jobTracker{states: map[string]*jobState{}})
+	setVar(t, &healthRedisSlow, -1)
+	status, report = checkHealth(t)
+	if got := degradedBy(report); status != 200 || len(got) != 1 || got[0] != "redis" {
+		t.Fatalf("slow redis = %d %v, want 200 degraded by redis", status, report)
+	}
+}
+
+func TestHealthReplicaDownOnlyDegrades(t *testing.T) {
+	setVar(t, &jobs, &jobTracker{states: map[string]*jobState{}})
+	down := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	t.Cleanup(func() { down.Close() })
+	setVar(t, &replicaEnabled, true)
+	setVar(t, &feedRdb, down)
+
+	status, report := checkHealth(t)
+	if got := degradedBy(report); status != 200 || report["status"] != healthDegraded || len(got) != 1 || got[0] != "redis_replica" {
+		t.Fatalf("health = %d %v, want 200 degraded by redis_replica", status, report)
+	}
+}
+
+func TestHealthUnhealthyWithoutRedis(t *testing.T) {
+	setVar(t, &jobs, &jobTracker{states: map[string]*jobState{}})
+	redisDown(t)
+
+	status, report := checkHealth(t)
+	if status != 503 || report["status"] != healthUnhealthy {
+		t.Fatalf("health = %d %v, want 503 unhealthy", status, report)
+	}
+	if redisHealth := report["dependencies"].(map[string]interface{})["redis"].(map[string]interface{}); redisHealth["error"] == nil {
+		t.Fatalf("redis = %v, want the error", redisHealth)
+	}
+	// Liveness doesn't depend on Redis
+	expectStatus(t, request(t, http.MethodGet, "/livez", nil), 200)
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// Several features compete for the top of chat: the featured paid question,
+// the latest streamer and moderator comments from the priority lane, and the
+// welcome message. check-update returns them together as highlights, in
+// precedence order and tagged with their type, so clients stack them the
+// same way. A stream's highlight policy (stream:highlights:<stream_id>) sets
+// the precedence (order, a comma-separated list of types; types left out
+// aren't shown), the total number of slots (max) and how many of them
+// priority comments may take (priority). Streams without one get
+// HIGHLIGHT_ORDER, HIGHLIGHT_MAX and HIGHLIGHT_PRIORITY_MAX. A max of 0
+// turns highlights off.
+const (
+	highlightFeatured = "featured"
+	highlightPriority = "priority"
+	highlightWelcome  = "welcome"
+)
+
+// highlightTypes are the known types, in their default precedence
+var highlightTypes = []string{highlightFeatured, highlightPriority, highlightWelcome}
+
+// highlightMaxSlots bounds a policy's total
+const highlightMaxSlots = 10
+
+// HighlightPolicy is how a stream lays out the top of its chat
+type HighlightPolicy struct {
+	Order    []string `json:"order"`
+	Max      int      `json:"max"`
+	Priority int      `json:"priority"`
+}
+
+var defaultHighlightPolicy HighlightPolicy
+
+func loadHighlightConfig() {
+	order, invalid := parseHighlightOrder(strings.Split(envString("HIGHLIGHT_ORDER", strings.Join(highlightTypes, ",")), ","))
+	if invalid != "" {
+		log.Printf("[GO] Warning: unknown highlight type %q in HIGHLIGHT_ORDER, using %s", invalid, strings.Join(highlightTypes, ","))
+		order = highlightTypes
+	}
+	defaultHighlightPolicy = HighlightPolicy{
+		Order:    order,
+		Max:      clampInt(envInt("HIGHLIGHT_MAX", 0), 0, highlightMaxSlots),
+		Priority: clampInt(envInt("HIGHLIGHT_PRIORITY_MAX", 1), 0, highlightMaxSlots),
+	}
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// parseHighlightOrder lower-cases and deduplicates highlight types, keeping
+// their order; invalid is the first value that isn't a known type
+func parseHighlightOrder(values []string) (order []string, invalid string) {
+	order = []string{}
+	seen := map[string]bool{}
+	for _, value := range values {
+		t := strings.ToLower(strings.TrimSpace(value))
+		if t == "" || seen[t] {
+			continue
+		}
+		known := false
+		for _, h := range highlightTypes {
+			known = known || h == t
+		}
+		if !known {
+			return nil, value
+		}
+		seen[t] = true
+		order = append(order, t)
+	}
+	return order, ""
+}
+
+// loadHighlightPolicy returns a stream's policy, the default for fields it
+// doesn't set
+func loadHighlightPolicy(ctx context.Context, streamID int64) (HighlightPolicy, error) {
+	fields, err := rdb.HGetAll(ctx, highlightPolicyKey(streamID)).Result()
+	if err != nil {
+		return defaultHighlightPolicy, err
+	}
+	return parseHighlightPolicy(fields), nil
+}
+
+// parseHighlightPolicy reads a stream's highlight policy hash over the
+// defaults
+func parseHighlightPolicy(fields map[string]string) HighlightPolicy {
+	policy := defaultHighlightPolicy
+	if s, ok := fields["order"]; ok {
+		if order, invalid := parseHighlightOrder(strings.Split(s, ",")); invalid == "" {
+			policy.Order = order
+		}
+	}
+	if n, err := strconv.Atoi(fields["max"]); err == nil {
+		policy.Max = clampInt(n, 0, highlightMaxSlots)
+	}
+	if n, err := strconv.Atoi(fields["priority"]); err == nil {
+		policy.Priority = clampInt(n, 0, highlightMaxSlots)
+	}
+	return policy
+}
+
+// Highlight is one item at the top of chat; which field is set depends on
+// its type
+type Highlight struct {
+	Type     string            `json:"type"`
+	Featured *FeaturedQuestion `json:"featured,omitempty"`
+	Comment  *Comment          `json:"comment,omitempty"`
+	Welcome  *WelcomeMessage   `json:"welcome,omitempty"`
+}
+
+// streamHighlights lays out the top of a stream's chat under its policy.
+// featured is the question already loaded for the response; priority
+// comments are read up to readMax, so they respect the chat delay, and go
+// through the viewer's filter. The welcome message only shows on an initial
+// load, like the response's own welcome. nil when the stream has highlights
+// off.
+func streamHighlights(ctx context.Context, streamID int64, policy HighlightPolicy, featured *FeaturedQuestion, filter deliveryFilter, initial bool, readMax, now int64) []Highlight {
+	if policy.Max <= 0 {
+		return nil
+	}
+	highlights := []Highlight{}
+	for _, t := range policy.Order {
+		if len(highlights) >= policy.Max {
+			break
+		}
+		switch t {
+		case highlightFeatured:
+			if featured != nil {
+				highlights = append(highlights, Highlight{Type: t, Featured: featured})
+			}
+		case highlightPriority:
+			n := policy.Priority
+			if left := policy.Max - len(highlights); n > left {
+				n = left
+			}
+			if n <= 0 || !priorityLane {
+				continue
+			}
+			lane := filter.apply(readPriorityComments(ctx, streamID, 0, readMax, now, n))
+			// Newest first, the one most worth the top slot
+			for i := len(lane) - 1; i >= 0; i-- {
+				highlights = append(highlights, Highlight{Type: t, Comment: &lane[i]})
+			}
+		case highlightWelcome:
+			if !initial {
+				continue
+			}
+			if welcome := initialWelcome(ctx, streamID); welcome != nil {
+				highlights = append(highlights, Highlight{Type: t, Welcome: welcome})
+			}
+		}
+	}
+	return highlights
+}
+
+func getHighlightPolicy(c *gin.Context) {
+	streamID, ok := parseStreamID(c)
+	if !ok {
+		return
+	}
+	policy, err := loadHighlightPolicy(c.Request.Context(), streamID)
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error loading highlight policy: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to load highlight policy"})
+		return
+	}
+	c.JSON(200, gin.H{"stream_id": streamID, "policy": policy})
+}
+
+// HighlightPolicyRequest replaces a stream's highlight policy; fields left
+// out fall back to the defaults
+type HighlightPolicyRequest struct {
+	Order    []string `json:"order"`
+	Max      *int     `json:"max" binding:"omitempty,min=0,max=10"`
+	Priority *int     `json:"priority" binding:"omitempty,min=0,max=10"`
+}
+
+func setHighlightPolicy(c *gin.Context) {
+	streamID, ok := parseStreamID(c)
+	if !ok {
+		return
+	}
+	var req HighlightPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	order, invalid := parseHighlightOrder(req.Order)
+	if invalid != "" {
+		c.JSON(400, gin.H{"error": "unknown highlight type: " + invalid})
+		return
+	}
+
+	var fields []interface{}
+	if req.Order != nil {
+		fields = append(fields, "order", strings.Join(order, ","))
+	}
+	if req.Max != nil {
+		fields = append(fields, "max", *req.Max)
+	}
+	if req.Priority != nil {
+		fields = append(fields, "priority", *req.Priority)
+	}
+	reqCtx := c.Request.Context()
+	_, err := rdb.TxPipelined(reqCtx, func(pipe redis.Pipeliner) error {
+		pipe.Del(reqCtx, highlightPolicyKey(streamID))
+		if len(fields) > 0 {
+			pipe.HSet(reqCtx, highlightPolicyKey(streamID), fields...)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error storing highlight policy: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to update highlight policy"})
+		return
+	}
+	policy, _ := loadHighlightPolicy(reqCtx, streamID)
+	log.Printf("[GO] Stream %d: Highlight policy set to %s, max %d, priority %d", streamID, strings.Join(policy.Order, ","), policy.Max, policy.Priority)
+	auditRequest(c, streamID, "highlights_updated", nil, "", map[string]interface{}{"order": policy.Order, "max": policy.Max, "priority": policy.Priority})
+	c.JSON(200, gin.H{"success": true, "policy": policy})
+}
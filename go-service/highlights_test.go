@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// highlightLayout lists a poll response's highlights as their type, with the
+// message for priority comments
+func highlightLayout(resp map[string]interface{}) []string {
+	list, _ := resp["highlights"].([]interface{})
+	out := make([]string, 0, len(list))
+	for _, e := range list {
+		h := e.(map[string]interface{})
+		if cmt, ok := h["comment"].(map[string]interface{}); ok {
+			out = append(out, h["type"].(string)+":"+cmt["message"].(string))
+			continue
+		}
+		out = append(out, h["type"].(string))
+	}
+	return out
+}
+
+// setHighlights replaces stream 1's highlight policy as a moderator
+func setHighlights(t *testing.T, policy map[string]interface{}) int {
+	t.Helper()
+	return request(t, http.MethodPost, "/stream/1/highlights", policy, asRole(roleModerator)...).Code
+}
+
+// fillHighlights gives stream 1 one of every highlight type: a featured
+// question, three priority comments and a welcome message
+func fillHighlights(t *testing.T) {
+	t.Helper()
+	feature(t, postedID(t, 1, "v1", "alice", "paid question"), 5)
+	for _, msg := range []string{"mod one", "mod two", "mod three"} {
+		expectStatus(t, post(t, 1, "m1", "mod", msg, asRole(roleModerator)...), 200)
+	}
+	expectStatus(t, request(t, http.MethodPost, "/stream/1/welcome", map[string]interface{}{"text": "be kind"}, asRole(roleModerator)...), 200)
+	nextSecond()
+}
+
+func TestHighlightPrecedenceAndCap(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &priorityLane, true)
+	setVar(t, &defaultHighlightPolicy, HighlightPolicy{Order: highlightTypes, Max: 3, Priority: 1})
+	fillHighlights(t)
+
+	cases := []struct {
+		name   string
+		policy map[string]interface{}
+		want   []string
+	}{
+		{"default", map[string]interface{}{}, []string{"featured", "priority:mod three", "welcome"}},
+		{"reordered", map[string]interface{}{"order": []string{"welcome", "priority", "featured"}, "max": 4, "priority": 2},
+			[]string{"welcome", "priority:mod three", "priority:mod two", "featured"}},
+		// Priority comments only get the slots the cap leaves them
+		{"capped", map[string]interface{}{"max": 2, "priority": 5}, []string{"featured", "priority:mod three"}},
+		{"priority crowding out", map[string]interface{}{"order": []string{"priority", "welcome", "featured"}, "max": 3, "priority": 3},
+			[]string{"priority:mod three", "priority:mod two", "priority:mod one"}},
+		{"types left out", map[string]interface{}{"order": []string{"welcome", "featured"}}, []string{"welcome", "featured"}},
+	}
+	for _, tc := range cases {
+		if status := setHighlights(t, tc.policy); status != 200 {
+			t.Fatalf("%s: setting the policy: %d", tc.name, status)
+		}
+		resp := poll(t, 1, "v9", 0)
+		if got := highlightLayout(resp); !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%s: highlights = %v, want %v", tc.name, got, tc.want)
+		}
+		// Later polls keep the other highlights but not the welcome
+		later := []string{}
+		for _, h := range tc.want {
+			if h != "welcome" {
+				later = append(later, h)
+			}
+		}
+		if got := highlightLayout(poll(t, 1, "v9", int64(resp["cursor"].(float64)))); !reflect.DeepEqual(got, later) {
+			t.Fatalf("%s: highlights past the initial load = %v, want %v", tc.name, got, later)
+		}
+	}
+
+	if status := setHighlights(t, map[string]interface{}{"max": 0}); status != 200 {
+		t.Fatalf("turning highlights off: %d", status)
+	}
+	if resp := poll(t, 1, "v9", 0); resp["highlights"] != nil {
+		t.Fatalf("highlights = %v with max 0, want none", resp["highlights"])
+	}
+}
+
+func TestHighlightPolicyValidation(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &defaultHighlightPolicy, HighlightPolicy{Order: highlightTypes, Max: 3, Priority: 1})
+
+	if status := setHighlights(t, map[string]interface{}{"order": []string{"featured", "superchat"}}); status != 400 {
+		t.Fatalf("unknown type: %d, want 400", status)
+	}
+	if status := setHighlights(t, map[string]interface{}{"max": highlightMaxSlots + 1}); status != 400 {
+		t.Fatalf("max over %d: %d, want 400", highlightMaxSlots, status)
+	}
+	expectStatus(t, request(t, http.MethodPost, "/stream/1/highlights", map[string]interface{}{"max": 1}, asRole(roleViewer)...), 403)
+
+	if status := setHighlights(t, map[string]interface{}{"order": []string{" Welcome", "featured", "welcome"}, "max": 2}); status != 200 {
+		t.Fatalf("valid policy: %d", status)
+	}
+	w := request(t, http.MethodGet, "/stream/1/highlights", nil, asRole(roleModerator)...)
+	expectStatus(t, w, 200)
+	policy := decode(t, w)["policy"].(map[string]interface{})
+	if !reflect.DeepEqual(policy["order"], []interface{}{"welcome", "featured"}) || policy["max"] != float64(2) || policy["priority"] != float64(1) {
+		t.Fatalf("policy = %v, want the order normalized and priority from the default", policy)
+	}
+
+	// A stored policy that no longer parses falls back field by field
+	rdb.HSet(ctx, highlightPolicyKey(1), "order", "featured,bogus", "max", "99")
+	if got := parseHighlightPolicy(rdb.HGetAll(ctx, highlightPolicyKey(1)).Val()); !reflect.DeepEqual(got.Order, highlightTypes) || got.Max != highlightMaxSlots {
+		t.Fatalf("policy = %+v, want the default order and max clamped to %d", got, highlightMaxSlots)
+	}
+}
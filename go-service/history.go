@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// Per-author history is kept for a while after the author's last comment
+const authorHistoryTTL = 7 * 24 * time.Hour
+
+// recordAuthorship indexes a published comment under its author, for
+// moderation, and its viewer, for their history, and binds the viewer ID to
+// the username it was posted with. Names are shared by whoever claims them,
+// so only the viewer index is anyone's own history.
+func recordAuthorship(ctx context.Context, pipe redis.Pipeliner, streamID int64, viewerID string, cmt *Comment) {
+	z := &redis.Z{Score: float64(cmt.Timestamp), Member: strconv.FormatInt(cmt.ID, 10)}
+	key := authorIndexKey(streamID, cmt.Username)
+	pipe.ZAdd(ctx, key, z)
+	pipe.Expire(ctx, key, authorHistoryTTL)
+	if viewerID != "" {
+		pipe.ZAdd(ctx, viewerHistoryKey(streamID, viewerID), z)
+		pipe.Expire(ctx, viewerHistoryKey(streamID, viewerID), authorHistoryTTL)
+		pipe.HSet(ctx, viewerNamesKey(streamID), viewerID, cmt.Username)
+		pipe.Expire(ctx, viewerNamesKey(streamID), authorHistoryTTL)
+	}
+}
+
+// recordRemovals notes that moderation removed ids, for their authors'
+// history
+func recordRemovals(ctx context.Context, pipe redis.Pipeliner, streamID int64, ids []string) {
+	now := time.Now().UnixMilli()
+	fields := make([]interface{}, 0, 2*len(ids))
+	for _, id := range ids {
+		fields = append(fields, id, now)
+	}
+	pipe.HSet(ctx, removalsKey(streamID), fields...)
+	pipe.Expire(ctx, removalsKey(streamID), authorHistoryTTL)
+}
+
+// Statuses reported for a viewer's own comments
+const (
+	historyVisible = "visible"
+	historyPending = "pending" // scheduled, not yet published
+	// historyExpired covers every comment that is gone without moderation:
+	// its lifetime ran out, or retention, eviction or cleanup removed it
+	historyExpired = "expired"
+	historyDeleted = "deleted" // removed by moderation
+)
+
+type HistoryEntry struct {
+	ID        int64  `json:"id"`
+	Message   string `json:"message,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+	Status    string `json:"status"`
+}
+
+// getMyComments lists the comments a viewer posted in a stream, including
+// ones that have since been removed. Only the viewer, or a trusted caller,
+// may read them.
+func getMyComments(c *gin.Context) {
+	streamID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid stream id"})
+		return
+	}
+	viewerID := c.Query("viewer_id")
+	if viewerID == "" {
+		c.JSON(400, gin.H{"error": "viewer_id is required"})
+		return
+	}
+	if !authenticateViewer(c, viewerID) {
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 200 {
+		c.JSON(400, gin.H{"error": "limit must be between 1 and 200"})
+		return
+	}
+	order := c.DefaultQuery("order", orderChronological)
+	if order != orderChronological && order != orderNewestFirst {
+		c.JSON(400, gin.H{"error": "order must be chronological or desc"})
+		return
+	}
+
+	reqCtx := c.Request.Context()
+	username, err := rdb.HGet(reqCtx, viewerNamesKey(streamID), viewerID).Result()
+	if err != nil && err != redis.Nil {
+		log.Printf("[GO] Stream %d: Error resolving viewer %s: %v", streamID, viewerID, err)
+		c.JSON(500, gin.H{"error": "failed to load history"})
+		return
+	}
+
+	entries, err := rdb.ZRangeWithScores(reqCtx, viewerHistoryKey(streamID, viewerID), int64(-limit), -1).Result()
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error loading history for %s: %v", streamID, viewerID, err)
+		c.JSON(500, gin.H{"error": "failed to load history"})
+		return
+	}
+
+	history := make([]HistoryEntry, 0, len(entries))
+	if len(entries) > 0 {
+		ids := make([]string, len(entries))
+		for i, z := range entries {
+			ids[i] = z.Member.(string)
+		}
+		var data, removed *redis.SliceCmd
+		_, dataErr := rdb.Pipelined(reqCtx, func(pipe redis.Pipeliner) error {
+			data = pipe.HMGet(reqCtx, commentDataKey(streamID), ids...)
+			removed = pipe.HMGet(reqCtx, removalsKey(streamID), ids...)
+			return nil
+		})
+		if dataErr != nil {
+			log.Printf("[GO] Stream %d: Error loading history data: %v", streamID, dataErr)
+			c.JSON(500, gin.H{"error": "failed to load history"})
+			return
+		}
+
+		now := time.Now().UnixMilli()
+		for i, z := range entries {
+			id, _ := strconv.ParseInt(ids[i], 10, 64)
+			entry := HistoryEntry{ID: id, Timestamp: int64(z.Score), Status: historyExpired}
+			if removed.Val()[i] != nil {
+				entry.Status = historyDeleted
+			}
+			if raw, ok := data.Val()[i].(string); ok {
+				var cmt Comment
+				if json.Unmarshal([]byte(raw), &cmt) == nil {
+					entry.Message = cmt.Message
+					switch {
+					case isExpired(cmt, now):
+						entry.Status = historyExpired
+					case cmt.Timestamp > now:
+						entry.Status = historyPending
+					default:
+						entry.Status = historyVisible
+					}
+				}
+			}
+			history = append(history, entry)
+		}
+	}
+	if order == orderNewestFirst {
+		for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 {
+			history[i], history[j] = history[j], history[i]
+		}
+	}
+
+	resp := gin.H{"comments": history}
+	if username != "" {
+		resp["username"] = username
+	}
+	c.JSON(200, resp)
+}
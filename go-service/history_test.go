@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+// myComments reads a viewer's history with the given headers
+func myComments(t *testing.T, viewerID, query string, headers ...string) (int, map[string]interface{}) {
+	t.Helper()
+	w := request(t, http.MethodGet, "/stream/1/mine?viewer_id="+viewerID+query, nil, headers...)
+	return w.Code, decode(t, w)
+}
+
+// historyStatuses maps a history response's messages to their status
+func historyStatuses(resp map[string]interface{}) map[float64]string {
+	out := map[float64]string{}
+	list, _ := resp["comments"].([]interface{})
+	for _, e := range list {
+		m := e.(map[string]interface{})
+		out[m["id"].(float64)] = m["status"].(string)
+	}
+	return out
+}
+
+func TestMyCommentsRequiresTheViewer(t *testing.T) {
+	resetRedis(t)
+	expectStatus(t, post(t, 1, "v1", "alice", "mine"), 200)
+	token := viewerSession(t, "v1")
+	other := viewerSession(t, "v2")
+
+	if status, _ := myComments(t, "v1", ""); status != 401 {
+		t.Fatalf("without a session: %d, want 401", status)
+	}
+	if status, _ := myComments(t, "v1", "", "X-Session-Token", other); status != 401 {
+		t.Fatalf("with another viewer's session: %d, want 401", status)
+	}
+	if status, resp := myComments(t, "v1", "", "X-Session-Token", token); status != 200 || resp["username"] != "alice" {
+		t.Fatalf("with the viewer's session: %d %v", status, resp)
+	}
+	if status, _ := myComments(t, "v1", "", trusted...); status != 200 {
+		t.Fatalf("as a trusted caller: %d, want 200", status)
+	}
+}
+
+func TestMyCommentsStatuses(t *testing.T) {
+	resetRedis(t)
+	kept := postedID(t, 1, "v1", "alice", "kept")
+	removed := postedID(t, 1, "v1", "alice", "removed")
+	evicted := postedID(t, 1, "v1", "alice", "evicted")
+	if err := moderateComments(ctx, 1, []string{strconv.FormatInt(removed, 10)}); err != nil {
+		t.Fatal(err)
+	}
+	// Housekeeping isn't moderation
+	if err := deleteComments(ctx, 1, []string{strconv.FormatInt(evicted, 10)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := storeBan(ctx, 1, ModerationTarget{ViewerID: "v1"}, 0, true); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, post(t, 1, "v1", "alice", "shadowed"), 200)
+
+	_, resp := myComments(t, "v1", "", trusted...)
+	got := historyStatuses(resp)
+	want := map[float64]string{float64(kept): historyVisible, float64(removed): historyDeleted, float64(evicted): historyExpired}
+	if len(got) != len(want) {
+		t.Fatalf("history = %v, want %v (shadow-banned posts are never stored)", got, want)
+	}
+	for id, status := range want {
+		if got[id] != status {
+			t.Fatalf("comment %v status = %q, want %q", id, got[id], status)
+		}
+	}
+}
+
+func TestMyCommentsAreTheViewersOwn(t *testing.T) {
+	resetRedis(t)
+	mine := postedID(t, 1, "v1", "alice", "mine")
+	postedID(t, 1, "v2", "alice", "someone else using the name")
+
+	_, resp := myComments(t, "v1", "", trusted...)
+	if got := historyStatuses(resp); len(got) != 1 || got[float64(mine)] != historyVisible {
+		t.Fatalf("history = %v, want only v1's own comment", got)
+	}
+	if _, resp := myComments(t, "v3", "", trusted...); len(historyStatuses(resp)) != 0 || resp["username"] != nil {
+		t.Fatalf("viewer who never posted: %v", resp)
+	}
+}
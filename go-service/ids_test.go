@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// stringIDs asks for IDs as JSON strings
+var stringIDs = []string{"Accept", "application/json; ids=string"}
+
+func TestFlexIDAcceptsNumbersStringsAndNull(t *testing.T) {
+	var req struct {
+		A flexID `json:"a"`
+		B flexID `json:"b"`
+		C flexID `json:"c"`
+	}
+	if err := json.Unmarshal([]byte(`{"a": 9007199254740993, "b": "9007199254740995", "c": null}`), &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.A != 9007199254740993 || req.B != 9007199254740995 || req.C != 0 {
+		t.Fatalf("decoded %d, %d, %d", req.A, req.B, req.C)
+	}
+	if err := json.Unmarshal([]byte(`{"a": "twelve"}`), &req); err == nil {
+		t.Fatal("accepted a non-numeric ID")
+	}
+}
+
+func TestStringIDsRoundTrip(t *testing.T) {
+	resetRedis(t)
+	w := request(t, http.MethodPost, "/post-comment", map[string]interface{}{
+		"stream_id": "1", "viewer_id": "v1", "username": "alice", "message": "hello", "quote": nil,
+	}, stringIDs...)
+	expectStatus(t, w, 200)
+	cmt, _ := decode(t, w)["comment"].(map[string]interface{})
+	id, ok := cmt["id"].(string)
+	if !ok {
+		t.Fatalf("comment id = %#v, want a string", cmt["id"])
+	}
+
+	nextSecond()
+	w = request(t, http.MethodPost, "/check-update", map[string]interface{}{"stream_id": "1", "viewer_id": "v2"}, stringIDs...)
+	expectStatus(t, w, 200)
+	comments, _ := decode(t, w)["comments"].([]interface{})
+	if len(comments) != 1 || comments[0].(map[string]interface{})["id"] != id {
+		t.Fatalf("check-update comments = %v, want %s", comments, id)
+	}
+
+	// Send back exactly what the responses carried
+	for path, body := range map[string]map[string]interface{}{
+		"/react":     {"stream_id": "1", "comment_id": id, "viewer_id": "v2", "reaction": "like"},
+		"/report":    {"stream_id": "1", "comment_id": id, "viewer_id": "v2"},
+		"/heartbeat": {"stream_id": "1", "viewer_id": "v2"},
+	} {
+		expectStatus(t, request(t, http.MethodPost, path, body, stringIDs...), 200)
+	}
+	if n := rdb.HLen(ctx, reactionCountsKey(1, goCommentIDBase+1)).Val(); n != 1 {
+		t.Fatalf("reactions on the comment = %d, want 1", n)
+	}
+}
+
+func TestRequiredIDsRejectNull(t *testing.T) {
+	resetRedis(t)
+	w := request(t, http.MethodPost, "/react", map[string]interface{}{
+		"stream_id": 1, "comment_id": nil, "viewer_id": "v1", "reaction": "like",
+	})
+	expectStatus(t, w, 400)
+	w = request(t, http.MethodPost, "/check-update", map[string]interface{}{"stream_id": nil})
+	expectStatus(t, w, 400)
+}
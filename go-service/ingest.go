@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// IngestComment is one externally sourced comment, e.g. bridged from another
+// platform's chat
+type IngestComment struct {
+	PostCommentRequest
+	Source string `json:"source" binding:"required"`
+}
+
+// IngestRequest keeps items raw so each one is decoded and validated on its
+// own and a bad item only fails itself
+type IngestRequest struct {
+	Comments []json.RawMessage `json:"comments" binding:"required,min=1,max=100"`
+}
+
+type IngestResult struct {
+	Index   int      `json:"index"`
+	Success bool     `json:"success"`
+	Comment *Comment `json:"comment,omitempty"`
+	Error   string   `json:"error,omitempty"`
+	Reason  string   `json:"reason,omitempty"`
+}
+
+// ingestComments accepts a batch of comments from a trusted integration and
+// publishes each through the regular submit path, so stream modes and
+// filters still apply. One item failing doesn't affect the others.
+func ingestComments(c *gin.Context) {
+	var req IngestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	reqCtx := c.Request.Context()
+	results := make([]IngestResult, 0, len(req.Comments))
+	published := 0
+	for i, raw := range req.Comments {
+		result := IngestResult{Index: i}
+		var item IngestComment
+		if err := binding.JSON.BindBody(raw, &item); err != nil {
+			result.Error = err.Error()
+			result.Reason = "invalid"
+			results = append(results, result)
+			continue
+		}
+		cmt, rejection, err := submitComment(reqCtx, item.PostCommentRequest, commentOrigin{Source: item.Source})
+		switch {
+		case err != nil:
+			log.Printf("[GO] Ingest: Stream %d: Error storing comment: %v", int64(item.StreamID), err)
+			result.Error = "failed to store comment"
+		case rejection != nil:
+			result.Error = rejection.Message
+			result.Reason = rejection.Reason
+		default:
+			result.Success = true
+			result.Comment = cmt
+			published++
+		}
+		results = append(results, result)
+	}
+
+	log.Printf("[GO] Ingest: published %d of %d comments", published, len(req.Comments))
+	c.JSON(200, gin.H{"published": published, "failed": len(req.Comments) - published, "results": results})
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestIngestBatchPartialFailure(t *testing.T) {
+	resetRedis(t)
+	rdb.HSet(ctx, modesKey(2), "emote_only", "1")
+	batch := map[string]interface{}{"comments": []interface{}{
+		map[string]interface{}{"stream_id": 1, "viewer_id": "yt:1", "username": "alice", "message": "bridged", "source": "youtube"},
+		map[string]interface{}{"stream_id": 1, "viewer_id": "yt:2", "username": "bob", "message": "no source"},
+		map[string]interface{}{"stream_id": 2, "viewer_id": "yt:3", "username": "carol", "message": "words in emote-only", "source": "youtube"},
+		"not an object",
+		map[string]interface{}{"stream_id": 1, "viewer_id": "tw:1", "username": "dave", "message": "also bridged", "source": "twitch"},
+	}}
+
+	expectStatus(t, request(t, http.MethodPost, "/ingest", batch), 401)
+	w := request(t, http.MethodPost, "/ingest", batch, trusted...)
+	expectStatus(t, w, 200)
+	resp := decode(t, w)
+	if resp["published"] != float64(2) || resp["failed"] != float64(3) {
+		t.Fatalf("published/failed = %v/%v, want 2/3", resp["published"], resp["failed"])
+	}
+	results := resp["results"].([]interface{})
+	wantReasons := []string{"", "invalid", "emote_only", "invalid", ""}
+	for i, want := range wantReasons {
+		r := results[i].(map[string]interface{})
+		if r["index"] != float64(i) || r["success"] != (want == "") {
+			t.Fatalf("result %d = %v", i, r)
+		}
+		if reason, _ := r["reason"].(string); reason != want {
+			t.Fatalf("result %d reason = %q, want %q", i, reason, want)
+		}
+	}
+
+	nextSecond()
+	if got := messages(poll(t, 1, "v1", 0)); len(got) != 2 || got[0] != "bridged" || got[1] != "also bridged" {
+		t.Fatalf("comments = %v, want the two published items", got)
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// The integrity check cross-checks a stream's index ZSET against its data
+// hash: index entries with no data (which read as missing comments) and data
+// with no index entry (which is never served and never expires). Comments
+// written before publishing became atomic, half-failed deletes and manual
+// edits can leave either kind. Both keys are walked with ZSCAN/HSCAN in
+// batches so a large stream doesn't block Redis.
+//
+// The backend writes a comment's data and its index entry in two commands,
+// so a comment being published reads as orphaned until the second lands.
+// Repairs only remove orphans dated (by index score or comment timestamp)
+// more than INTEGRITY_REPAIR_GRACE seconds ago; younger ones are reported as
+// pending and left for a later pass.
+const (
+	integrityScanBatch  = 500
+	integritySampleSize = 20
+)
+
+var integrityRepairGrace time.Duration
+
+func loadIntegrityConfig() {
+	integrityRepairGrace = time.Duration(envInt("INTEGRITY_REPAIR_GRACE", 60)) * time.Second
+}
+
+// OrphanReport counts one kind of orphan with a sample of their IDs
+type OrphanReport struct {
+	Count  int      `json:"count"`
+	Sample []string `json:"sample"`
+}
+
+func (r *OrphanReport) add(ids []string) {
+	r.Count += len(ids)
+	for _, id := range ids {
+		if len(r.Sample) == integritySampleSize {
+			break
+		}
+		r.Sample = append(r.Sample, id)
+	}
+}
+
+type IntegrityReport struct {
+	IndexEntries  int          `json:"index_entries"`
+	DataEntries   int          `json:"data_entries"`
+	OrphanedIndex OrphanReport `json:"orphaned_index"` // indexed, no data
+	OrphanedData  OrphanReport `json:"orphaned_data"`  // data, not indexed
+	Repaired      int          `json:"repaired,omitempty"`
+	// Pending counts the orphans a repair left alone as too recent
+	Pending int `json:"pending,omitempty"`
+}
+
+// checkIntegrity scans a stream for orphans, removing them when repair is set
+func checkIntegrity(ctx context.Context, streamID int64, repair bool) (IntegrityReport, error) {
+	report := IntegrityReport{
+		OrphanedIndex: OrphanReport{Sample: []string{}},
+		OrphanedData:  OrphanReport{Sample: []string{}},
+	}
+
+	var cursor uint64
+	for {
+		// ZSCAN returns member, score pairs
+		pairs, next, err := rdb.ZScan(ctx, commentIndexKey(streamID), cursor, "*", integrityScanBatch).Result()
+		if err != nil {
+			return report, err
+		}
+		ids := make([]string, 0, len(pairs)/2)
+		written := make(map[string]int64, len(pairs)/2)
+		for i := 0; i < len(pairs); i += 2 {
+			ids = append(ids, pairs[i])
+			score, _ := strconv.ParseFloat(pairs[i+1], 64)
+			written[pairs[i]] = int64(score)
+		}
+		report.IndexEntries += len(ids)
+		orphans, err := missingData(ctx, streamID, ids)
+		if err != nil {
+			return report, err
+		}
+		report.OrphanedIndex.add(orphans)
+		if err := repairOrphans(ctx, streamID, orphans, written, repair, &report); err != nil {
+			return report, err
+		}
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+
+	for {
+		// HSCAN returns field, value pairs
+		pairs, next, err := rdb.HScan(ctx, commentDataKey(streamID), cursor, "*", integrityScanBatch).Result()
+		if err != nil {
+			return report, err
+		}
+		ids := make([]string, 0, len(pairs)/2)
+		written := make(map[string]int64, len(pairs)/2)
+		for i := 0; i < len(pairs); i += 2 {
+			ids = append(ids, pairs[i])
+			written[pairs[i]] = commentTimestamp(pairs[i+1])
+		}
+		report.DataEntries += len(ids)
+		orphans, err := missingIndex(ctx, streamID, ids)
+		if err != nil {
+			return report, err
+		}
+		report.OrphanedData.add(orphans)
+		if err := repairOrphans(ctx, streamID, orphans, written, repair, &report); err != nil {
+			return report, err
+		}
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+	return report, nil
+}
+
+// missingData returns the indexed IDs that have no data
+func missingData(ctx context.Context, streamID int64, ids []string) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	data, err := rdb.HMGet(ctx, commentDataKey(streamID), ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	for i, id := range ids {
+		if data[i] == nil {
+			missing = append(missing, id)
+		}
+	}
+	return missing, nil
+}
+
+// missingIndex returns the IDs with data that aren't in the index
+func missingIndex(ctx context.Context, streamID int64, ids []string) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	cmds, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, id := range ids {
+			pipe.ZScore(ctx, commentIndexKey(streamID), id)
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	var missing []string
+	for i, cmd := range cmds {
+		if cmd.Err() == redis.Nil {
+			missing = append(missing, ids[i])
+		}
+	}
+	return missing, nil
+}
+
+// commentTimestamp returns a stored comment's timestamp (ms), 0 if it can't
+// be decoded
+func commentTimestamp(data string) int64 {
+	var cmt struct {
+		Timestamp int64 `json:"timestamp"`
+	}
+	json.Unmarshal([]byte(data), &cmt)
+	return cmt.Timestamp
+}
+
+// repairOrphans removes orphans written before the grace window (see
+// written, in ms) from both keys and every ranking
+func repairOrphans(ctx context.Context, streamID int64, ids []string, written map[string]int64, repair bool, report *IntegrityReport) error {
+	if !repair || len(ids) == 0 {
+		return nil
+	}
+	cutoff := time.Now().Add(-integrityRepairGrace).UnixMilli()
+	var settled []string
+	for _, id := range ids {
+		if written[id] < cutoff {
+			settled = append(settled, id)
+		}
+	}
+	report.Pending += len(ids) - len(settled)
+	if len(settled) == 0 {
+		return nil
+	}
+	if err := deleteComments(ctx, streamID, settled); err != nil {
+		return err
+	}
+	report.Repaired += len(settled)
+	return nil
+}
+
+// getIntegrity reports a stream's orphaned comment entries
+func getIntegrity(c *gin.Context) {
+	runIntegrityCheck(c, false)
+}
+
+// repairIntegrity removes a stream's orphaned comment entries
+func repairIntegrity(c *gin.Context) {
+	runIntegrityCheck(c, true)
+}
+
+func runIntegrityCheck(c *gin.Context, repair bool) {
+	streamID, ok := parseStreamID(c)
+	if !ok {
+		return
+	}
+	report, err := checkIntegrity(c.Request.Context(), streamID, repair)
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error checking comment integrity: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to check integrity"})
+		return
+	}
+	if report.OrphanedIndex.Count > 0 || report.OrphanedData.Count > 0 {
+		log.Printf("[GO] Stream %d: Integrity check found %d orphaned index and %d orphaned data entries (%d repaired)",
+			streamID, report.OrphanedIndex.Count, report.OrphanedData.Count, report.Repaired)
+	}
+	if report.Repaired > 0 {
+		auditRequest(c, streamID, "integrity_repair", nil, "", map[string]interface{}{"repaired": report.Repaired})
+	}
+	c.JSON(200, report)
+}
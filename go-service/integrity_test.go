@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// integrity runs the integrity check on stream 1 as a moderator
+func integrity(t *testing.T, method, path string) map[string]interface{} {
+	t.Helper()
+	w := request(t, method, "/stream/1/integrity"+path, nil, asRole(roleModerator)...)
+	expectStatus(t, w, 200)
+	return decode(t, w)
+}
+
+// orphanSample returns an orphan report's sorted sample
+func orphanSample(resp map[string]interface{}, kind string) (float64, string) {
+	report := resp[kind].(map[string]interface{})
+	var ids []string
+	for _, id := range report["sample"].([]interface{}) {
+		ids = append(ids, id.(string))
+	}
+	sort.Strings(ids)
+	return report["count"].(float64), strings.Join(ids, " ")
+}
+
+func TestIntegrityFindsAndRepairsBothOrphanTypes(t *testing.T) {
+	resetRedis(t)
+	saveAt(t, time.Now().UnixMilli(), 1, 2)
+	// Indexed with no data, and data that isn't indexed
+	testRedis.ZAdd(commentIndexKey(1), float64(time.Now().Add(-time.Hour).UnixMilli()), "7")
+	testRedis.HSet(commentDataKey(1), "8", `{"id":8,"message":"stray"}`, "9", `{"id":9,"message":"stray"}`)
+
+	before := testRedis.Dump()
+	resp := integrity(t, http.MethodGet, "")
+	if resp["index_entries"] != float64(3) || resp["data_entries"] != float64(4) {
+		t.Fatalf("entries = %v/%v, want 3 indexed and 4 with data", resp["index_entries"], resp["data_entries"])
+	}
+	if count, sample := orphanSample(resp, "orphaned_index"); count != 1 || sample != "7" {
+		t.Fatalf("orphaned index = %v %q, want 1 [7]", count, sample)
+	}
+	if count, sample := orphanSample(resp, "orphaned_data"); count != 2 || sample != "8 9" {
+		t.Fatalf("orphaned data = %v %q, want 2 [8 9]", count, sample)
+	}
+	if _, ok := resp["repaired"]; ok || testRedis.Dump() != before {
+		t.Fatal("the check modified the stream")
+	}
+
+	if resp := integrity(t, http.MethodPost, "/repair"); resp["repaired"] != float64(3) {
+		t.Fatalf("repaired = %v, want 3", resp["repaired"])
+	}
+	resp = integrity(t, http.MethodGet, "")
+	if count, _ := orphanSample(resp, "orphaned_index"); count != 0 {
+		t.Fatalf("orphaned index after repair = %v", count)
+	}
+	if count, _ := orphanSample(resp, "orphaned_data"); count != 0 {
+		t.Fatalf("orphaned data after repair = %v", count)
+	}
+	if resp["index_entries"] != float64(2) || resp["data_entries"] != float64(2) {
+		t.Fatalf("entries after repair = %v/%v, want the 2 live comments", resp["index_entries"], resp["data_entries"])
+	}
+}
+
+func TestIntegritySampleIsCapped(t *testing.T) {
+	resetRedis(t)
+	for i := 0; i < integritySampleSize+5; i++ {
+		testRedis.ZAdd(commentIndexKey(1), float64(i), strconv.Itoa(100+i))
+	}
+	count, sample := orphanSample(integrity(t, http.MethodGet, ""), "orphaned_index")
+	if count != float64(integritySampleSize+5) || len(strings.Fields(sample)) != integritySampleSize {
+		t.Fatalf("count = %v with %d sampled, want %d with %d", count, len(strings.Fields(sample)), integritySampleSize+5, integritySampleSize)
+	}
+}
+
+func TestRepairSparesCommentsBeingPublished(t *testing.T) {
+	resetRedis(t)
+	now := time.Now()
+	// The backend has written the data hash but not yet the index entry
+	fresh := fmt.Sprintf(`{"id":5,"username":"alice","message":"on its way","timestamp":%d}`, now.UnixMilli())
+	testRedis.HSet(commentDataKey(1), "5", fresh)
+	// and an orphan from long ago really is one
+	stale := fmt.Sprintf(`{"id":6,"username":"bob","message":"stray","timestamp":%d}`, now.Add(-time.Hour).UnixMilli())
+	testRedis.HSet(commentDataKey(1), "6", stale)
+
+	var stats CompactionStats
+	if err := compactStream(ctx, 1, &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Orphans != 1 || rdb.HExists(ctx, commentDataKey(1), "6").Val() {
+		t.Fatalf("orphans = %d, want the stale one repaired", stats.Orphans)
+	}
+	if !rdb.HExists(ctx, commentDataKey(1), "5").Val() {
+		t.Fatal("compaction deleted a comment between its HSET and ZADD")
+	}
+	if resp := integrity(t, http.MethodPost, "/repair"); resp["pending"] != float64(1) || resp["repaired"] != nil {
+		t.Fatalf("repair = %v, want the fresh comment pending", resp)
+	}
+
+	// The ZADD lands and the comment is whole
+	testRedis.ZAdd(commentIndexKey(1), float64(now.UnixMilli()), "5")
+	if count, _ := orphanSample(integrity(t, http.MethodGet, ""), "orphaned_data"); count != 0 {
+		t.Fatalf("orphaned data = %v after the index entry landed", count)
+	}
+
+	// Left orphaned past the grace window, it goes
+	testRedis.ZRem(commentIndexKey(1), "5")
+	setVar(t, &integrityRepairGrace, -time.Second)
+	if resp := integrity(t, http.MethodPost, "/repair"); resp["repaired"] != float64(1) {
+		t.Fatalf("repair = %v, want the lapsed orphan removed", resp)
+	}
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// keyPrefix namespaces every Redis key and channel the service touches
+// (REDIS_KEY_PREFIX), so several deployments can share one Redis. The
+// backend builds the keys it shares with this service with the same
+// variable (app/utils/redis_keys.py), so both must be set alike.
+//
+// All keys are built by the helpers below; never format a key inline.
+var keyPrefix string
+
+func loadKeyPrefix() {
+	keyPrefix = os.Getenv("REDIS_KEY_PREFIX")
+}
+
+func key(format string, args ...interface{}) string {
+	return keyPrefix + fmt.Sprintf(format, args...)
+}
+
+// Comments
+
+func commentIndexKey(streamID int64) string { return key("comments:index:%d", streamID) }
+func commentDataKey(streamID int64) string  { return key("comments:data:%d", streamID) }
+func commentSeqKey(streamID int64) string   { return key("comments:seq:%d", streamID) }
+
+// corruptCountsKey counts decode failures per comment ID
+func corruptCountsKey(streamID int64) string { return key("comments:corrupt:%d", streamID) }
+
+// quarantineKey keeps the raw data of comments pulled from the feed as corrupt
+func quarantineKey(streamID int64) string { return key("comments:quarantine:%d", streamID) }
+
+// expiryKey indexes ephemeral comments across all streams by expiry time (ms).
+// Members are "<stream_id>:<comment_id>".
+func expiryKey() string { return key("comments:expiry") }
+
+// bufferedStreamsKey lists the streams holding comments posted while their
+// chat was disabled; chatBufferKey holds one stream's, oldest first
+func bufferedStreamsKey() string { return key("chat:buffered") }
+
+func chatBufferKey(streamID int64) string { return key("stream:chat_buffer:%d", streamID) }
+
+// authorIndexKey lists an author's comment IDs in a stream, scored by timestamp
+func authorIndexKey(streamID int64, username string) string {
+	return key("comments:byuser:%d:%s", streamID, username)
+}
+
+// viewerHistoryKey lists the comment IDs a viewer posted in a stream, scored
+// by timestamp
+func viewerHistoryKey(streamID int64, viewerID string) string {
+	return key("comments:byviewer:%d:%s", streamID, viewerID)
+}
+
+// removalsKey maps the IDs of comments moderation removed to when (ms), so
+// history can tell them from comments that expired or were cleaned up
+func removalsKey(streamID int64) string { return key("comments:removed:%d", streamID) }
+
+// reactionCountsKey holds a comment's reaction counts (type -> count)
+func reactionCountsKey(streamID, commentID int64) string {
+	return key("reactions:counts:%d:%d", streamID, commentID)
+}
+
+// reactionVotersKey holds "<viewer_id>|<type>" members for a comment's reactions
+func reactionVotersKey(streamID, commentID int64) string {
+	return key("reactions:voters:%d:%d", streamID, commentID)
+}
+
+// commentKeysKey lists the IDs of a stream's comments that have reaction
+// or report keys of their own, so compaction finds them without a SCAN
+func commentKeysKey(streamID int64) string { return key("comments:keyed:%d", streamID) }
+
+// reactionLeaderboardKey scores a stream's comments by total reactions
+func reactionLeaderboardKey(streamID int64) string { return key("reactions:top:%d", streamID) }
+
+// reactionTypesKey lists the reactions a stream allows, see reactions.go
+func reactionTypesKey(streamID int64) string { return key("stream:reactions:%d", streamID) }
+
+// liveReactionsKey counts a stream's floating reactions (type -> count) in
+// one second; liveReactionRateKey counts a viewer's within the current second
+func liveReactionsKey(streamID, second int64) string {
+	return key("reactions:live:%d:%d", streamID, second)
+}
+func liveReactionRateKey(streamID int64, viewerID string) string {
+	return key("reactions:live:rate:%d:%s", streamID, viewerID)
+}
+
+// engagementKey scores a stream's comments by their weighted signals
+func engagementKey(streamID int64) string { return key("comments:engagement:%d", streamID) }
+
+// reportCountsKey counts reports per comment ID; reportersKey holds who reported
+func reportCountsKey(streamID int64) string { return key("reports:counts:%d", streamID) }
+func reportersKey(streamID, commentID int64) string {
+	return key("reports:voters:%d:%d", streamID, commentID)
+}
+
+// reputationKey holds a stream's per-author reputation counts, as
+// "<count>:<username>" fields
+func reputationKey(streamID int64) string { return key("reputation:%d", streamID) }
+
+// featuredQueueKey orders paid questions waiting for the featured slot,
+// featuredEntriesKey holds them by comment ID and featuredActiveKey the one
+// currently featured, expiring when its time is up
+func featuredQueueKey(streamID int64) string   { return key("featured:queue:%d", streamID) }
+func featuredEntriesKey(streamID int64) string { return key("featured:entries:%d", streamID) }
+func featuredActiveKey(streamID int64) string  { return key("featured:active:%d", streamID) }
+
+// highlightPolicyKey holds how a stream lays out the top of its chat, see
+// highlights.go
+func highlightPolicyKey(streamID int64) string { return key("stream:highlights:%d", streamID) }
+
+// replayTimelineKey caches a stream's VOD replay timelines, by broadcast
+// start and bucket size
+func replayTimelineKey(streamID int64) string { return key("replay:timeline:%d", streamID) }
+
+// translationsKey caches a comment's translations, as "<version>:<target>"
+// fields holding JSON
+func translationsKey(streamID, commentID int64) string {
+	return key("comments:translations:%d:%d", streamID, commentID)
+}
+
+// editsKey logs recently edited comments, scored by edit time (ms)
+func editsKey(streamID int64) string { return key("comments:edits:%d", streamID) }
+
+// filterStatsKey counts the current broadcast's filter outcomes, as
+// "<filter>:<action>" fields
+func filterStatsKey(streamID int64) string { return key("stats:filters:%d", streamID) }
+
+// platformStatsKey counts the current broadcast's published comments, as
+// "platform:<platform>" and "source:<source>" fields
+func platformStatsKey(streamID int64) string { return key("stats:platforms:%d", streamID) }
+
+// Streams
+
+// bansKey maps "user:<username>" and "viewer:<viewer_id>" to ban expiry (ms, 0 = permanent)
+func bansKey(streamID int64) string { return key("stream:bans:%d", streamID) }
+
+// shadowBansKey is bansKey for shadow bans, whose comments nobody else sees
+func shadowBansKey(streamID int64) string { return key("stream:shadow_bans:%d", streamID) }
+
+func allowCommentsKey(streamID int64) string { return key("stream:allow_comments:%d", streamID) }
+func modesKey(streamID int64) string         { return key("stream:modes:%d", streamID) }
+
+// geoPolicyKey holds a stream's country restriction on posting (see geo.go)
+func geoPolicyKey(streamID int64) string { return key("stream:geo_policy:%d", streamID) }
+
+// streamTTLKey marks a stream as ephemeral, holding its idle TTL in seconds
+func streamTTLKey(streamID int64) string { return key("stream:ttl:%d", streamID) }
+
+// delayKey holds a stream's chat delay in seconds
+func delayKey(streamID int64) string { return key("stream:delay:%d", streamID) }
+
+// streamStartKey and streamEndKey hold the current or last broadcast's start
+// and end times (ms); streamPeakKey its peak viewer count
+func streamStartKey(streamID int64) string { return key("stream:start:%d", streamID) }
+func streamEndKey(streamID int64) string   { return key("stream:end:%d", streamID) }
+func streamPeakKey(streamID int64) string  { return key("stream:peak:%d", streamID) }
+
+// streamExistsKey marks a stream as known, see existence.go
+func streamExistsKey(streamID int64) string { return key("stream:exists:%d", streamID) }
+
+// floodKey counts a stream's accepted comments in the current flood window
+func floodKey(streamID int64) string { return key("stream:flood:%d", streamID) }
+
+// linkPostersKey holds who posted a link within the repeat window,
+// linkBlockKey blocks it while the cooldown runs; both by a hash of the
+// normalized URL. streamLinksKey holds the stream's own links, which are
+// never blocked.
+func linkPostersKey(streamID int64, link string) string {
+	return key("stream:links:posters:%d:%s", streamID, link)
+}
+func linkBlockKey(streamID int64, link string) string {
+	return key("stream:links:blocked:%d:%s", streamID, link)
+}
+func streamLinksKey(streamID int64) string { return key("stream:links:%d", streamID) }
+
+// auditKey logs a stream's moderation actions, newest first
+func auditKey(streamID int64) string { return key("audit:%d", streamID) }
+
+// welcomeKey holds a stream's welcome message: text, format, updated_at
+func welcomeKey(streamID int64) string { return key("stream:welcome:%d", streamID) }
+
+// emotesKey holds a stream's custom emotes (shortcode -> image url)
+func emotesKey(streamID int64) string { return key("stream:emotes:%d", streamID) }
+
+// emotePacksKey maps a stream's emote pack names to "1" (enabled) or "0";
+// emotePackKey holds one pack's emotes (shortcode -> image url)
+func emotePacksKey(streamID int64) string { return key("stream:emote_packs:%d", streamID) }
+func emotePackKey(streamID int64, pack string) string {
+	return key("stream:emote_pack:%d:%s", streamID, pack)
+}
+
+// emoteUsageKey scores the custom emotes of the current broadcast by the
+// comments that used them
+func emoteUsageKey(streamID int64) string { return key("stats:emotes:%d", streamID) }
+
+func velocityKey(streamID int64, bucket int64) string {
+	return key("velocity:%d:%d", streamID, bucket)
+}
+
+func raidKey(streamID int64) string      { return key("raid:active:%d", streamID) }
+func raidCheckKey(streamID int64) string { return key("raid:check:%d", streamID) }
+
+// raidStreamsKey holds the streams running an auto-raised slow-mode
+func raidStreamsKey() string { return key("raid:streams") }
+
+// liveChannel announces new comments on a stream to streaming connections
+func liveChannel(streamID int64) string { return key("comments:live:%d", streamID) }
+func liveChannelPrefix() string         { return key("comments:live:") }
+
+func modEventsChannel(streamID int64) string { return key("mod:events:%d", streamID) }
+
+// presenceChannel announces viewers joining and leaving a stream
+func presenceChannel(streamID int64) string { return key("online:events:%d", streamID) }
+func presenceChannelPrefix() string         { return key("online:events:") }
+
+// streamEventsChannel announces lifecycle changes (started, ended)
+func streamEventsChannel(streamID int64) string { return key("stream:events:%d", streamID) }
+
+// activeStreamsKey scores the streams being watched by when they became
+// active; leavingStreamsKey holds when each dropped to the exit threshold
+func activeStreamsKey() string  { return key("streams:active") }
+func leavingStreamsKey() string { return key("streams:active:leaving") }
+
+// streamActivityKey scores streams holding comments by their last activity
+// (ms), for eviction under COMMENT_GLOBAL_CAP and compaction;
+// evictionLockKey lets one replica evict per interval
+func streamActivityKey() string { return key("comments:streams") }
+func evictionLockKey() string   { return key("comments:eviction:lock") }
+
+// compactionLockKey lets one replica compact per interval;
+// compactionStatsKey holds the last run's stats (JSON)
+func compactionLockKey() string  { return key("comments:compaction:lock") }
+func compactionStatsKey() string { return key("comments:compaction:last") }
+
+// ingestStreamKey is the Redis Stream comments are ingested from;
+// ingestLockKey names the replica consuming it, see streamingest.go
+func ingestStreamKey() string { return key("ingest:comments") }
+func ingestLockKey() string   { return key("ingest:comments:lock") }
+
+// Viewers
+
+func onlineSetKey(streamID int64) string { return key("online:%d", streamID) }
+
+// onlineSmoothedKey holds a stream's moving-average viewer count
+func onlineSmoothedKey(streamID int64) string { return key("online:smoothed:%d", streamID) }
+
+// consumerKey holds the acknowledged watermark of a stream's consumer
+func consumerKey(streamID int64, consumerID string) string {
+	return key("consumer:%d:%s", streamID, consumerID)
+}
+
+// priorityKey indexes a stream's priority comments by timestamp
+func priorityKey(streamID int64) string { return key("comments:priority:%d", streamID) }
+
+// timezoneKey holds the IANA zone a stream's display times are in
+func timezoneKey(streamID int64) string { return key("stream:tz:%d", streamID) }
+
+// dripKey holds a stream's drip mode reveal horizon
+func dripKey(streamID int64) string { return key("stream:drip:%d", streamID) }
+
+// viewerSeenKey scores a stream's viewers by their last heartbeat (ms)
+func viewerSeenKey(streamID int64) string { return key("online:seen:%d", streamID) }
+
+// heartbeatSeenKey scores a stream's viewers by their last /heartbeat call
+// (ms); unlike viewerSeenKey, WebSocket refreshes don't count
+func heartbeatSeenKey(streamID int64) string { return key("online:heartbeats:%d", streamID) }
+
+// readCursorsKey scores a stream's viewers by the furthest last_id they polled
+func readCursorsKey(streamID int64) string { return key("online:cursors:%d", streamID) }
+
+// moderatorsOnlineKey scores a stream's moderators by their last heartbeat
+// (ms); moderatorActiveKey is set while they are moderating, see modpresence.go
+func moderatorsOnlineKey(streamID int64) string { return key("online:moderators:%d", streamID) }
+func moderatorActiveKey(streamID int64) string  { return key("online:moderating:%d", streamID) }
+
+// firstSeenKey holds when a viewer's current visit to a stream began (ms)
+func firstSeenKey(streamID int64, viewerID string) string {
+	return key("online:first_seen:%d:%s", streamID, viewerID)
+}
+
+// sessionKey holds a viewer session's metadata, keyed by its token
+func sessionKey(token string) string { return key("viewers:session:%s", token) }
+
+// botTokenKey holds a bot token's metadata by token ID, botTokensKey the
+// set of issued token IDs
+func botTokenKey(id string) string { return key("bots:token:%s", id) }
+func botTokensKey() string         { return key("bots:tokens") }
+
+// guestNamesKey holds a stream's generated guest names, as "name:<name>" ->
+// viewer ID and "viewer:<viewer_id>" -> name
+func guestNamesKey(streamID int64) string { return key("viewers:guests:%d", streamID) }
+
+// privateViewersKey holds viewers who hide themselves from viewer lists
+func privateViewersKey() string { return key("viewers:private") }
+
+// dupesKey points a message hash at the comment its copies collapse into,
+// with the viewers who posted it
+func dupesKey(streamID int64, hash string) string {
+	return key("stream:dupes:%d:%s", streamID, hash)
+}
+
+// quotaKey counts a viewer's comments in one broadcast, by its start time
+func quotaKey(streamID, session int64, viewer string) string {
+	return key("quota:%d:%d:%s", streamID, session, viewer)
+}
+
+// recentMessagesKey holds a viewer's last few messages, for similarity checks
+func recentMessagesKey(streamID int64, viewer string) string {
+	return key("stream:recent:%d:%s", streamID, viewer)
+}
+
+// rateLimitKey counts a stream's posts along one rate limit axis
+func rateLimitKey(streamID int64, axis, value string) string {
+	return key("ratelimit:%d:%s:%s", streamID, axis, value)
+}
+
+func slowModeKey(streamID int64, viewer string) string {
+	return key("slowmode:%d:%s", streamID, viewer)
+}
+
+// viewerNamesKey maps a stream's viewer IDs to the username they post as
+func viewerNamesKey(streamID int64) string { return key("viewers:names:%d", streamID) }
+
+// socketCountsKey counts each viewer's open WebSocket connections to a stream
+func socketCountsKey(streamID int64) string { return key("online:sockets:%d", streamID) }
+
+// deliveryPrefsKey maps viewer IDs to their delivery preferences (JSON)
+func deliveryPrefsKey() string { return key("viewers:prefs") }
+
+// nameColorsKey maps viewer IDs to their chosen name color
+func nameColorsKey() string { return key("viewers:name_color") }
+
+// Service
+
+// profanityWordsKey extends a profanity tier's word list; profanityVersionKey
+// is bumped whenever any list changes
+func profanityWordsKey(tier string) string { return key("profanity:words:%s", tier) }
+func profanityVersionKey() string          { return key("profanity:version") }
+
+// maintenanceKey lets operators flip read-only mode at runtime without a restart
+func maintenanceKey() string { return key("service:maintenance") }
+
+// campaignStreamsKey collects the streams a message fingerprint was posted
+// in; campaignBlockKey holds a sample of a blocked fingerprint's message
+func campaignStreamsKey(fingerprint string) string { return key("campaign:streams:%s", fingerprint) }
+func campaignBlockKey(fingerprint string) string   { return key("campaign:blocked:%s", fingerprint) }
+
+// trustedDomainsKey extends TRUSTED_DOMAINS; trustedDomainsVersionKey is
+// bumped whenever it changes
+func trustedDomainsKey() string        { return key("service:trusted_domains") }
+func trustedDomainsVersionKey() string { return key("service:trusted_domains:version") }
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAllKeysCarryThePrefix(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &keyPrefix, "tenant-a:")
+
+	lifecycle(t, "start", nil)
+	token := viewerSession(t, "v1")
+	id := postedID(t, 1, "v1", "alice", "hello there")
+	expectStatus(t, post(t, 1, "v2", "bob", "hi alice"), 200)
+	expectStatus(t, request(t, http.MethodPost, "/react", map[string]interface{}{
+		"stream_id": 1, "comment_id": id, "viewer_id": "v2", "reaction": "like",
+	}), 200)
+	expectStatus(t, request(t, http.MethodPost, "/report", map[string]interface{}{
+		"stream_id": 1, "comment_id": id, "viewer_id": "v3", "reason": "spam",
+	}), 200)
+	expectStatus(t, request(t, http.MethodPost, "/name-color", map[string]interface{}{"viewer_id": "v1", "color": "#e91"}, "X-Session-Token", token), 200)
+	expectStatus(t, request(t, http.MethodPost, "/stream/1/ban", map[string]interface{}{"viewer_id": "v2"}, asRole(roleModerator)...), 200)
+	nextSecond()
+	poll(t, 1, "v1", 0)
+	myComments(t, "v1", "", trusted...)
+	lifecycle(t, "end", nil)
+
+	keys := testRedis.Keys()
+	if len(keys) < 10 {
+		t.Fatalf("only %d keys written: %v", len(keys), keys)
+	}
+	for _, k := range keys {
+		if !strings.HasPrefix(k, "tenant-a:") {
+			t.Errorf("key %q lacks the prefix", k)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// leaderboardScanLimit bounds how many recent comments a windowed query ranks
+const leaderboardScanLimit = 5000
+
+// Top comment rankings
+const (
+	rankByReactions  = "reactions"
+	rankByEngagement = "engagement"
+)
+
+type TopComment struct {
+	Comment
+	Reactions  map[string]int64 `json:"reactions"`
+	Total      int64            `json:"total_reactions"`
+	Engagement *float64         `json:"engagement,omitempty"` // moderators only
+}
+
+// getTopComments returns a stream's top comments, by reactions or by
+// engagement score. window is a duration ("1h", "30m") limiting the ranking
+// to comments posted within it, or "all" (default) for all time; engagement
+// rankings consider at most the newest leaderboardScanLimit comments.
+func getTopComments(c *gin.Context) {
+	streamID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid stream id"})
+		return
+	}
+	by := c.DefaultQuery("by", rankByReactions)
+	if by != rankByReactions && by != rankByEngagement {
+		c.JSON(400, gin.H{"error": "by must be reactions or engagement"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit <= 0 || limit > 100 {
+		c.JSON(400, gin.H{"error": "limit must be between 1 and 100"})
+		return
+	}
+	var window time.Duration
+	if w := c.DefaultQuery("window", "all"); w != "all" {
+		window, err = time.ParseDuration(w)
+		if err != nil || window <= 0 {
+			c.JSON(400, gin.H{"error": "window must be \"all\" or a duration like 1h"})
+			return
+		}
+	}
+
+	reqCtx := c.Request.Context()
+	boardKey := reactionLeaderboardKey(streamID)
+	var ranked []redis.Z
+	if by == rankByEngagement {
+		ranked, err = rankEngagement(reqCtx, streamID, window)
+	} else if window == 0 {
+		// Over-fetch a little so deleted comments don't shrink the result
+		ranked, err = rdb.ZRevRangeWithScores(reqCtx, boardKey, 0, int64(limit*2-1)).Result()
+	} else {
+		ranked, err = rankRecentComments(reqCtx, streamID, window)
+	}
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error ranking comments: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to load top comments"})
+		return
+	}
+
+	showScore := isPrivileged(requestRole(c))
+	access := viewerAccess(c)
+	top := make([]TopComment, 0, limit)
+	if len(ranked) > 0 {
+		ids := make([]string, len(ranked))
+		for i, z := range ranked {
+			ids[i] = z.Member.(string)
+		}
+		data, dataErr := rdb.HMGet(reqCtx, commentDataKey(streamID), ids...).Result()
+		if dataErr != nil {
+			log.Printf("[GO] Stream %d: Error loading top comments: %v", streamID, dataErr)
+			c.JSON(500, gin.H{"error": "failed to load top comments"})
+			return
+		}
+
+		var deleted []interface{}
+		for i, z := range ranked {
+			raw, ok := data[i].(string)
+			if !ok {
+				deleted = append(deleted, ids[i])
+				continue
+			}
+			if len(top) == limit {
+				continue
+			}
+			var entry TopComment
+			if json.Unmarshal([]byte(raw), &entry.Comment) != nil || !canSee(entry.Comment, access) {
+				continue
+			}
+			if showScore && by == rankByEngagement {
+				score := z.Score
+				entry.Engagement = &score
+			}
+			counts, countsErr := rdb.HGetAll(reqCtx, reactionCountsKey(streamID, entry.ID)).Result()
+			if countsErr == nil {
+				entry.Reactions = make(map[string]int64, len(counts))
+				for t, v := range counts {
+					entry.Reactions[t], _ = strconv.ParseInt(v, 10, 64)
+					entry.Total += entry.Reactions[t]
+				}
+			}
+			top = append(top, entry)
+		}
+		// Deleted comments drop off the leaderboard for good
+		if len(deleted) > 0 {
+			rdb.ZRem(reqCtx, boardKey, deleted...)
+			rdb.ZRem(reqCtx, engagementKey(streamID), deleted...)
+		}
+	}
+
+	c.JSON(200, gin.H{"comments": top})
+}
+
+// rankRecentComments ranks the comments posted within window by their
+// reaction totals
+func rankRecentComments(ctx context.Context, streamID int64, window time.Duration) ([]redis.Z, error) {
+	now := time.Now()
+	ids, err := rdb.ZRevRangeByScore(ctx, commentIndexKey(streamID), &redis.ZRangeBy{
+		Min:   strconv.FormatInt(now.Add(-window).UnixMilli(), 10),
+		Max:   strconv.FormatInt(now.UnixMilli(), 10),
+		Count: leaderboardScanLimit,
+	}).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	scores, err := rdb.ZMScore(ctx, reactionLeaderboardKey(streamID), ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+	ranked := make([]redis.Z, 0, len(ids))
+	for i, id := range ids {
+		if scores[i] > 0 {
+			ranked = append(ranked, redis.Z{Member: id, Score: scores[i]})
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].Score > ranked[j].Score })
+	return ranked, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// react toggles a viewer's reaction on a stream 1 comment
+func react(t *testing.T, commentID int64, viewerID, reaction string, headers ...string) {
+	t.Helper()
+	expectStatus(t, request(t, http.MethodPost, "/react", map[string]interface{}{
+		"stream_id": 1, "comment_id": commentID, "viewer_id": viewerID, "reaction": reaction,
+	}, headers...), 200)
+}
+
+// topComments lists the IDs and reaction totals of stream 1's top comments
+func topComments(t *testing.T, query string) []string {
+	t.Helper()
+	w := request(t, http.MethodGet, "/stream/1/top-comments"+query, nil)
+	expectStatus(t, w, 200)
+	list, _ := decode(t, w)["comments"].([]interface{})
+	out := make([]string, 0, len(list))
+	for _, e := range list {
+		m := e.(map[string]interface{})
+		out = append(out, fmt.Sprintf("%s=%v", m["message"], m["total_reactions"]))
+	}
+	return out
+}
+
+func TestReactionLeaderboardRanking(t *testing.T) {
+	resetRedis(t)
+	a := postedID(t, 1, "v1", "alice", "first")
+	b := postedID(t, 1, "v2", "bob", "second")
+	c := postedID(t, 1, "v3", "carol", "third")
+	react(t, a, "x1", "like")
+	for i := 1; i <= 3; i++ {
+		react(t, b, fmt.Sprintf("x%d", i), "fire")
+	}
+	react(t, c, "x1", "like")
+	react(t, c, "x2", "love")
+	// Toggling a reaction off takes it back off the score
+	react(t, c, "x3", "like")
+	react(t, c, "x3", "like")
+	// An old comment ranks all-time but not within the last hour
+	saveAt(t, time.Now().Add(-2*time.Hour).UnixMilli(), 5)
+	for i := 1; i <= 4; i++ {
+		react(t, 5, fmt.Sprintf("x%d", i), "like")
+	}
+
+	if got := fmt.Sprint(topComments(t, "")); got != "[m5=4 second=3 third=2 first=1]" {
+		t.Fatalf("top comments = %s", got)
+	}
+	if got := fmt.Sprint(topComments(t, "?window=1h")); got != "[second=3 third=2 first=1]" {
+		t.Fatalf("top comments in the last hour = %s", got)
+	}
+	if err := deleteComments(ctx, 1, []string{"5"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(topComments(t, "?limit=1")); got != "[second=3]" {
+		t.Fatalf("limit 1 = %s, want [second=3]", got)
+	}
+
+	// Reactions keep the ranking up to date incrementally
+	react(t, a, "x2", "like")
+	react(t, a, "x3", "like")
+	react(t, a, "x4", "like")
+	if got := fmt.Sprint(topComments(t, "")); got != "[first=4 second=3 third=2]" {
+		t.Fatalf("after more reactions = %s", got)
+	}
+
+	if err := deleteComments(ctx, 1, []string{strconv.FormatInt(a, 10)}); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(topComments(t, "")); got != "[second=3 third=2]" {
+		t.Fatalf("after deleting first = %s, want it excluded", got)
+	}
+}
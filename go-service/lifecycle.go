@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// A stream is live between POST /stream/:id/start and POST /stream/:id/end.
+// stream:start holds the start time (ms) and stream:end the end time of the
+// last broadcast; streams that were never started report as not live.
+
+// StreamStatus is a stream's lifecycle state as of a moment
+type StreamStatus struct {
+	Live      bool
+	StartedAt int64 // ms, 0 if never started
+	EndedAt   int64 // ms, 0 while live
+}
+
+// Elapsed returns how long the current broadcast has been running
+func (s StreamStatus) Elapsed(now time.Time) time.Duration {
+	if !s.Live {
+		return 0
+	}
+	return now.Sub(time.UnixMilli(s.StartedAt))
+}
+
+// loadStreamStatus reads a stream's start and end markers
+func loadStreamStatus(ctx context.Context, streamID int64) (StreamStatus, error) {
+	vals, err := rdb.MGet(ctx, streamStartKey(streamID), streamEndKey(streamID)).Result()
+	if err != nil {
+		return StreamStatus{}, err
+	}
+	return parseStreamStatus(vals), nil
+}
+
+// parseStreamStatus reads the values of a stream's start and end markers
+func parseStreamStatus(vals []interface{}) StreamStatus {
+	var status StreamStatus
+	if s, ok := vals[0].(string); ok {
+		status.StartedAt, _ = strconv.ParseInt(s, 10, 64)
+	}
+	if s, ok := vals[1].(string); ok {
+		status.EndedAt, _ = strconv.ParseInt(s, 10, 64)
+	}
+	status.Live = status.StartedAt > 0 && status.EndedAt == 0
+	return status
+}
+
+// publishStreamEvent announces a lifecycle change to subscribers (e.g. the backend)
+func publishStreamEvent(ctx context.Context, streamID int64, event map[string]interface{}) {
+	event["stream_id"] = streamID
+	event["timestamp"] = time.Now().UnixMilli()
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	if err := rdb.Publish(ctx, streamEventsChannel(streamID), payload).Err(); err != nil {
+		log.Printf("[GO] Stream %d: Error publishing stream event: %v", streamID, err)
+	}
+}
+
+// recordPeakScript keeps the larger of the stored peak and ARGV[1]
+var recordPeakScript = redis.NewScript(`
+local peak = tonumber(redis.call('GET', KEYS[1]) or '0')
+if tonumber(ARGV[1]) > peak then
+	redis.call('SET', KEYS[1], ARGV[1])
+end
+return 0
+`)
+
+// recordPeak raises the stream's peak viewer count to online if it is higher
+func recordPeak(ctx context.Context, streamID int64, online int64) {
+	if err := recordPeakScript.Run(ctx, rdb, []string{streamPeakKey(streamID)}, online).Err(); err != nil && err != redis.Nil {
+		log.Printf("[GO] Stream %d: Error recording peak viewers: %v", streamID, err)
+	}
+}
+
+func startStream(c *gin.Context) {
+	streamID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid stream id"})
+		return
+	}
+
+	reqCtx := c.Request.Context()
+	status, err := loadStreamStatus(reqCtx, streamID)
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error loading stream status: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to start stream"})
+		return
+	}
+	if status.Live {
+		c.JSON(409, gin.H{"error": "stream is already live", "started_at": status.StartedAt})
+		return
+	}
+
+	now := time.Now().UnixMilli()
+	_, err = rdb.TxPipelined(reqCtx, func(pipe redis.Pipeliner) error {
+		pipe.Set(reqCtx, streamStartKey(streamID), now, 0)
+		markStreamExists(reqCtx, pipe, streamID)
+		pipe.Del(reqCtx, streamEndKey(streamID), streamPeakKey(streamID), filterStatsKey(streamID), platformStatsKey(streamID), emoteUsageKey(streamID))
+		// Cancel the expiry an earlier end's expire_in set, so the new
+		// broadcast's chat doesn't vanish mid-stream
+		for _, k := range ephemeralStreamKeys(streamID) {
+			pipe.Persist(reqCtx, k)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error starting stream: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to start stream"})
+		return
+	}
+	// An ephemeral stream gets its idle TTL back
+	touchStream(reqCtx, streamID)
+
+	log.Printf("[GO] Stream %d: Started", streamID)
+	publishStreamEvent(reqCtx, streamID, map[string]interface{}{"type": "stream_started"})
+	c.JSON(200, gin.H{"success": true, "started_at": now})
+}
+
+// EndStreamRequest optionally schedules the stream's comment data for
+// removal once the broadcast is over
+type EndStreamRequest struct {
+	ExpireIn int64 `json:"expire_in" binding:"omitempty,min=0"` // seconds, 0 = keep
+}
+
+func endStream(c *gin.Context) {
+	streamID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid stream id"})
+		return
+	}
+	var req EndStreamRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	reqCtx := c.Request.Context()
+	status, err := loadStreamStatus(reqCtx, streamID)
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error loading stream status: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to end stream"})
+		return
+	}
+	if !status.Live {
+		c.JSON(409, gin.H{"error": "stream is not live"})
+		return
+	}
+
+	now := time.Now().UnixMilli()
+	_, err = rdb.TxPipelined(reqCtx, func(pipe redis.Pipeliner) error {
+		pipe.Set(reqCtx, streamEndKey(streamID), now, 0)
+		if req.ExpireIn > 0 {
+			for _, k := range ephemeralStreamKeys(streamID) {
+				pipe.Expire(reqCtx, k, time.Duration(req.ExpireIn)*time.Second)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error ending stream: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to end stream"})
+		return
+	}
+
+	duration := now - status.StartedAt
+	log.Printf("[GO] Stream %d: Ended after %ds", streamID, duration/1000)
+	publishStreamEvent(reqCtx, streamID, map[string]interface{}{"type": "stream_ended", "duration": duration})
+	c.JSON(200, gin.H{"success": true, "started_at": status.StartedAt, "ended_at": now})
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// lifecycle sends a start or end to stream 1 as the backend
+func lifecycle(t *testing.T, action string, body interface{}) map[string]interface{} {
+	t.Helper()
+	w := request(t, http.MethodPost, "/stream/1/"+action, body, trusted...)
+	out := decode(t, w)
+	out["status"] = float64(w.Code)
+	return out
+}
+
+func TestStreamLifecycleTransitions(t *testing.T) {
+	resetRedis(t)
+
+	if resp := lifecycle(t, "end", nil); resp["status"] != float64(409) {
+		t.Fatalf("ending a stream that never started: %v, want 409", resp)
+	}
+	if resp := poll(t, 1, "v1", 0); resp["live"] == true {
+		t.Fatal("a stream that never started reports live")
+	}
+
+	started := lifecycle(t, "start", nil)
+	if started["status"] != float64(200) || started["started_at"] == nil {
+		t.Fatalf("start: %v", started)
+	}
+	if resp := lifecycle(t, "start", nil); resp["status"] != float64(409) || resp["started_at"] != started["started_at"] {
+		t.Fatalf("starting a live stream: %v, want 409 with the original start", resp)
+	}
+	if resp := poll(t, 1, "v1", 0); resp["live"] != true {
+		t.Fatalf("live = %v after start, want true", resp["live"])
+	}
+
+	ended := lifecycle(t, "end", nil)
+	if ended["status"] != float64(200) || ended["started_at"] != started["started_at"] {
+		t.Fatalf("end: %v", ended)
+	}
+	if resp := poll(t, 1, "v1", 0); resp["live"] == true {
+		t.Fatal("an ended stream reports live")
+	}
+	if resp := lifecycle(t, "end", nil); resp["status"] != float64(409) {
+		t.Fatalf("ending an ended stream: %v, want 409", resp)
+	}
+
+	if resp := lifecycle(t, "start", nil); resp["status"] != float64(200) {
+		t.Fatalf("restart: %v", resp)
+	}
+	if resp := poll(t, 1, "v1", 0); resp["live"] != true {
+		t.Fatal("a restarted stream isn't live")
+	}
+}
+
+func TestStreamStartResetsPeak(t *testing.T) {
+	resetRedis(t)
+	lifecycle(t, "start", nil)
+	recordPeak(ctx, 1, 40)
+	lifecycle(t, "end", nil)
+	lifecycle(t, "start", nil)
+	if testRedis.Exists(streamPeakKey(1)) {
+		t.Fatal("peak survived the restart")
+	}
+}
+
+func TestStreamRestartCancelsEndExpiry(t *testing.T) {
+	resetRedis(t)
+	lifecycle(t, "start", nil)
+	expectStatus(t, post(t, 1, "v1", "alice", "first broadcast"), 200)
+	lifecycle(t, "end", map[string]interface{}{"expire_in": 60})
+	if ttl := testRedis.TTL(commentDataKey(1)); ttl != time.Minute {
+		t.Fatalf("comment data TTL after end = %v, want 1m", ttl)
+	}
+
+	lifecycle(t, "start", nil)
+	for _, k := range []string{commentDataKey(1), commentIndexKey(1)} {
+		if ttl := testRedis.TTL(k); ttl != 0 {
+			t.Fatalf("%s TTL after restart = %v, want none", k, ttl)
+		}
+	}
+	testRedis.FastForward(2 * time.Minute)
+	nextSecond()
+	if got := messages(poll(t, 1, "v2", 0)); len(got) != 1 {
+		t.Fatalf("comments after restart = %v, want the first broadcast's", got)
+	}
+}
+
+func TestStreamRestartRestoresIdleTTL(t *testing.T) {
+	resetRedis(t)
+	rdb.Set(ctx, streamTTLKey(1), 600, 0)
+	lifecycle(t, "start", nil)
+	expectStatus(t, post(t, 1, "v1", "alice", "hello"), 200)
+	lifecycle(t, "end", map[string]interface{}{"expire_in": 60})
+
+	lifecycle(t, "start", nil)
+	if ttl := testRedis.TTL(commentDataKey(1)); ttl != 10*time.Minute {
+		t.Fatalf("comment data TTL after restart = %v, want the stream's 10m", ttl)
+	}
+}
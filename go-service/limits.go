@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBodyBytes caps request bodies (MAX_BODY_BYTES). The largest legitimate
+// body is a full ingest batch, so the default leaves room for that.
+var maxBodyBytes int64
+
+// rawBodyKey holds the buffered request body in the gin context
+const rawBodyKey = "raw_body"
+
+func loadBodyLimitConfig() {
+	maxBodyBytes = int64(envInt("MAX_BODY_BYTES", 256*1024))
+}
+
+// bodyLimitMiddleware rejects oversized bodies with 413 before any handler
+// binds them. Bodies are read up front through http.MaxBytesReader, so
+// chunked requests without a Content-Length are cut off at the cap too.
+func bodyLimitMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if maxBodyBytes <= 0 || c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > maxBodyBytes {
+			c.AbortWithStatusJSON(413, gin.H{"error": "request body too large", "reason": "body_too_large"})
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBodyBytes))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.AbortWithStatusJSON(413, gin.H{"error": "request body too large", "reason": "body_too_large"})
+			} else {
+				c.AbortWithStatusJSON(400, gin.H{"error": "failed to read request body"})
+			}
+			return
+		}
+		c.Set(rawBodyKey, body)
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Next()
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// sendRaw posts a raw body to path; chunked leaves the length unknown
+func sendRaw(path, body string, chunked bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if chunked {
+		req.ContentLength = -1
+		req.Body = io.NopCloser(strings.NewReader(body))
+	}
+	w := httptest.NewRecorder()
+	testRouter.ServeHTTP(w, req)
+	return w
+}
+
+func TestOversizedBodiesAreRejected(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &maxBodyBytes, 512)
+	huge := `{"stream_id": 1, "viewer_id": "v1", "username": "alice", "message": "` + strings.Repeat("a", 1024) + `"}`
+
+	for _, chunked := range []bool{false, true} {
+		for _, path := range []string{"/post-comment", "/check-update"} {
+			w := sendRaw(path, huge, chunked)
+			if resp := decode(t, w); w.Code != 413 || resp["reason"] != "body_too_large" {
+				t.Fatalf("%s (chunked %v): %d %v, want 413 body_too_large", path, chunked, w.Code, resp)
+			}
+		}
+	}
+	if n := rdb.HLen(ctx, commentDataKey(1)).Val(); n != 0 {
+		t.Fatalf("comments stored = %d, want none", n)
+	}
+
+	w := sendRaw("/post-comment", `{"stream_id": 1, "viewer_id": "v1", "username": "alice", "message": "small"}`, true)
+	expectStatus(t, w, 200)
+}
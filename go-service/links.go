@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Link spam is often coordinated: many accounts each post the same URL once,
+// staying under every per-viewer limit. Each stream tracks who posted a link
+// recently, and once LINK_REPEAT_THRESHOLD different posters have shared it
+// within LINK_REPEAT_WINDOW of each other the link is blocked for
+// LINK_COOLDOWN, whoever posts it. 0 turns the check off; streams override
+// the threshold with link_repeat_threshold in their modes.
+//
+// The streamer's links are never blocked: links they post are added to the
+// stream's own links set (which the backend may also fill), so viewers can
+// share them freely. Moderators are exempt too.
+var (
+	linkRepeatThreshold int
+	linkRepeatWindow    time.Duration
+	linkCooldown        time.Duration
+)
+
+func loadLinkConfig() {
+	linkRepeatThreshold = envInt("LINK_REPEAT_THRESHOLD", 0)
+	linkRepeatWindow = time.Duration(envInt("LINK_REPEAT_WINDOW", 60)) * time.Second
+	linkCooldown = time.Duration(envInt("LINK_COOLDOWN", 300)) * time.Second
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"']+`)
+
+// normalizeLink reduces a URL to what makes it the same link: host without
+// "www." and lowercased, path and query, no scheme, fragment or trailing
+// punctuation. It returns "" for text that isn't a usable URL.
+func normalizeLink(raw string) string {
+	raw = strings.TrimRight(raw, ".,;:!?)]}")
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	host := strings.TrimPrefix(normalizeHost(u.Host), "www.")
+	link := host + strings.TrimSuffix(u.EscapedPath(), "/")
+	if u.RawQuery != "" {
+		link += "?" + u.RawQuery
+	}
+	return link
+}
+
+// extractLinks returns the distinct normalized links in a message
+func extractLinks(message string) []string {
+	var links []string
+	seen := map[string]bool{}
+	for _, match := range linkPattern.FindAllString(message, -1) {
+		if link := normalizeLink(match); link != "" && !seen[link] {
+			seen[link] = true
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// linkID keeps keys short and free of URL characters
+func linkID(link string) string {
+	sum := sha256.Sum256([]byte(link))
+	return hex.EncodeToString(sum[:8])
+}
+
+// ownLinks reports which links are the stream's own
+func ownLinks(ctx context.Context, streamID int64, links []string) map[string]bool {
+	own := map[string]bool{}
+	cmds, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, link := range links {
+			pipe.SIsMember(ctx, streamLinksKey(streamID), link)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error loading stream links: %v", streamID, err)
+	}
+	for i, cmd := range cmds {
+		if b, ok := cmd.(*redis.BoolCmd); ok && b.Val() {
+			own[links[i]] = true
+		}
+	}
+	return own
+}
+
+// blockedLink returns the first of links under a cooldown and the seconds
+// left on it, "" when none is
+func blockedLink(ctx context.Context, streamID int64, links []string) (string, int) {
+	if len(links) == 0 {
+		return "", 0
+	}
+	cmds, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, link := range links {
+			pipe.PTTL(ctx, linkBlockKey(streamID, linkID(link)))
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error checking link cooldowns: %v", streamID, err)
+		return "", 0
+	}
+	own := ownLinks(ctx, streamID, links)
+	for i, cmd := range cmds {
+		ttl, ok := cmd.(*redis.DurationCmd)
+		if !ok || ttl.Val() <= 0 || own[links[i]] {
+			continue
+		}
+		return links[i], int((ttl.Val() + time.Second - 1) / time.Second)
+	}
+	return "", 0
+}
+
+// recordLinks counts a published comment's links towards their posters and
+// starts the cooldown on any that reached the threshold
+func recordLinks(ctx context.Context, streamID int64, poster string, links []string, threshold int) {
+	if threshold <= 0 || poster == "" || len(links) == 0 {
+		return
+	}
+	own := ownLinks(ctx, streamID, links)
+	for _, link := range links {
+		if own[link] {
+			continue
+		}
+		id := linkID(link)
+		var posters *redis.IntCmd
+		_, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.SAdd(ctx, linkPostersKey(streamID, id), poster)
+			pipe.Expire(ctx, linkPostersKey(streamID, id), linkRepeatWindow)
+			posters = pipe.SCard(ctx, linkPostersKey(streamID, id))
+			return nil
+		})
+		if err != nil {
+			log.Printf("[GO] Stream %d: Error tracking link: %v", streamID, err)
+			continue
+		}
+		if posters.Val() < int64(threshold) {
+			continue
+		}
+		blocked, err := rdb.SetNX(ctx, linkBlockKey(streamID, id), link, linkCooldown).Result()
+		if err != nil || !blocked {
+			continue
+		}
+		rdb.Del(ctx, linkPostersKey(streamID, id))
+		log.Printf("[GO] Stream %d: Link %s posted by %d viewers, blocked for %s", streamID, link, posters.Val(), linkCooldown)
+		publishModEvent(ctx, streamID, map[string]interface{}{"type": "link_blocked", "link": link, "posters": posters.Val(), "until": time.Now().Add(linkCooldown).UnixMilli()})
+	}
+}
+
+// addStreamLinks whitelists links the streamer posted
+func addStreamLinks(ctx context.Context, streamID int64, links []string) {
+	if len(links) == 0 {
+		return
+	}
+	members := make([]interface{}, len(links))
+	for i, link := range links {
+		members[i] = link
+	}
+	if err := rdb.SAdd(ctx, streamLinksKey(streamID), members...).Err(); err != nil {
+		log.Printf("[GO] Stream %d: Error saving stream links: %v", streamID, err)
+	}
+}
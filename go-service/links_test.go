@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestNormalizeLink(t *testing.T) {
+	for in, want := range map[string]string{
+		"https://www.Example.com/promo/":   "example.com/promo",
+		"example.com/promo":                "example.com/promo",
+		"http://example.com/promo?ref=1).": "example.com/promo?ref=1",
+		"https://example.com/promo#top":    "example.com/promo",
+		"https://":                         "",
+	} {
+		if got := normalizeLink(in); got != want {
+			t.Errorf("normalizeLink(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestLinkCrossingTheRepeatThreshold(t *testing.T) {
+	resetRedis(t)
+	rdb.HSet(ctx, modesKey(1), "link_repeat_threshold", "3")
+
+	// The same poster twice counts once; spellings of the link count together
+	expectStatus(t, post(t, 1, "v1", "user1", "free stuff https://spam.example/win"), 200)
+	expectStatus(t, post(t, 1, "v1", "user1", "really www.spam.example/win/"), 200)
+	expectStatus(t, post(t, 1, "v2", "user2", "look HTTP://SPAM.example/win"), 200)
+	expectStatus(t, post(t, 1, "v3", "user3", "https://spam.example/win!"), 200)
+
+	w := post(t, 1, "v4", "user4", "www.spam.example/win")
+	expectStatus(t, w, 429)
+	if resp := decode(t, w); resp["reason"] != "link_cooldown" || resp["retry_after"] != float64(linkCooldown/time.Second) {
+		t.Fatalf("rejection = %v, want link_cooldown for %v", resp, linkCooldown)
+	}
+	expectStatus(t, post(t, 1, "v4", "user4", "other link https://example.org"), 200)
+	expectStatus(t, post(t, 1, "mod", "mod", "https://spam.example/win", asRole(roleModerator)...), 200)
+
+	testRedis.FastForward(linkCooldown)
+	expectStatus(t, post(t, 1, "v5", "user5", "https://spam.example/win"), 200)
+}
+
+func TestStreamerLinksAreNeverBlocked(t *testing.T) {
+	resetRedis(t)
+	rdb.HSet(ctx, modesKey(1), "link_repeat_threshold", "2")
+	expectStatus(t, post(t, 1, "streamer", "streamer", "merch at https://shop.example/merch", asRole(roleStreamer)...), 200)
+
+	for i := 1; i <= 4; i++ {
+		expectStatus(t, post(t, 1, fmt.Sprintf("v%d", i), fmt.Sprintf("user%d", i), "https://shop.example/merch"), 200)
+	}
+}
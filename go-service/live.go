@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The live hub wakes streaming connections when a stream gets new comments.
+// Notifications carry no data: woken connections read through readFeed like
+// a poll, so delay, gating and cursors behave exactly as in check-update.
+// One pattern subscription per process fans out to every local listener.
+type liveHub struct {
+	mu        sync.Mutex
+	listeners map[int64]map[chan struct{}]struct{}
+}
+
+var hub = &liveHub{listeners: map[int64]map[chan struct{}]struct{}{}}
+
+// subscribe registers a listener for a stream; call the returned func to
+// unregister
+func (h *liveHub) subscribe(streamID int64) (chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	h.mu.Lock()
+	if h.listeners[streamID] == nil {
+		h.listeners[streamID] = map[chan struct{}]struct{}{}
+	}
+	h.listeners[streamID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.listeners[streamID], ch)
+		if len(h.listeners[streamID]) == 0 {
+			delete(h.listeners, streamID)
+		}
+		h.mu.Unlock()
+	}
+}
+
+// wake signals every listener of a stream without blocking; a listener that
+// already has a pending wake-up doesn't need another
+func (h *liveHub) wake(streamID int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.listeners[streamID] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// notifyLive tells every replica's listeners that a stream has new comments
+func notifyLive(ctx context.Context, streamID int64) {
+	if err := rdb.Publish(ctx, liveChannel(streamID), "1").Err(); err != nil {
+		log.Printf("[GO] Stream %d: Error publishing live notification: %v", streamID, err)
+	}
+}
+
+// runLiveHub relays live notifications from Redis to local listeners,
+// resubscribing if the subscription drops
+func runLiveHub(ctx context.Context) {
+	prefix := liveChannelPrefix()
+	for ctx.Err() == nil {
+		sub := rdb.PSubscribe(ctx, prefix+"*")
+		jobs.setRunning("live_hub", true)
+		for msg := range sub.Channel() {
+			if streamID, err := strconv.ParseInt(strings.TrimPrefix(msg.Channel, prefix), 10, 64); err == nil {
+				hub.wake(streamID)
+			}
+		}
+		sub.Close()
+		jobs.setRunning("live_hub", false)
+		time.Sleep(time.Second)
+	}
+}
+
+// feedReader follows a stream's feed for one streaming connection
+type feedReader struct {
+	streamID   int64
+	cursor     int64
+	applyDelay bool
+	filter     deliveryFilter
+	editsSince int64          // ms, edits after this haven't been sent
+	zone       *time.Location // adds display_time when set
+
+	// before and beforeID page the comments the last read left out, as in a
+	// truncated check-update; before is 0 when nothing was left out
+	before   int64
+	beforeID int64
+}
+
+// FeedGap tells a streaming client that a read left older comments out. It
+// pages them through check-update with the cursor the client had before the
+// read, like a truncated poll.
+type FeedGap struct {
+	Before   int64 `json:"before"`
+	BeforeID int64 `json:"before_id,omitempty"`
+}
+
+// gap returns the comments the last read left out, nil when there are none
+func (r *feedReader) gap() *FeedGap {
+	if r.before == 0 {
+		return nil
+	}
+	return &FeedGap{Before: r.before, BeforeID: r.beforeID}
+}
+
+// next returns the comments past the cursor that the viewer receives and
+// advances the cursor, including past comments the viewer filtered out.
+// Like check-update, a read past POLL_MAX_COMMENTS keeps only the newest.
+func (r *feedReader) next(ctx context.Context) []Comment {
+	now := feedNow()
+	q := feedQuery{StreamID: r.streamID, Min: r.cursor + 1, Max: now, ApplyDelay: r.applyDelay}
+	if r.cursor == 0 {
+		q.Min, q.Limit = 0, initialLoadLimit
+	} else if pollMaxComments > 0 {
+		// One past the cap tells a truncated read from one that just fits
+		q.Limit = pollMaxComments + 1
+	}
+	if r.applyDelay {
+		if modes, err := loadStreamModes(ctx, r.streamID); err == nil && modes.Drip.Count > 0 {
+			dripFeedQuery(ctx, &q, modes.Drip)
+		}
+	}
+	snap := store.ReadFeed(ctx, q)
+	if !snap.AllowComments {
+		return nil
+	}
+	comments := snap.decodeComments(ctx, r.streamID, now)
+	r.before, r.beforeID = 0, 0
+	if r.cursor != 0 {
+		comments, r.before, r.beforeID = truncatePoll(comments, pollMaxComments)
+	}
+	if newest := newestTimestamp(comments); newest > r.cursor {
+		r.cursor = newest
+	}
+	return withDisplayTime(r.filter.apply(comments), r.zone)
+}
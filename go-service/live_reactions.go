@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// Live reactions are stream-wide floating reactions (the hearts drifting up
+// the screen), separate from reactions on comments. POST /stream/:id/reaction
+// counts one in the current second; check-update returns live_reactions,
+// each type's count over the last LIVE_REACTION_WINDOW seconds with older
+// seconds weighing less, so a burst fades out instead of stopping dead. Each
+// viewer may send LIVE_REACTION_RATE per second.
+var (
+	liveReactionWindow int64 // seconds
+	liveReactionRate   int
+)
+
+func loadLiveReactionConfig() {
+	liveReactionWindow = int64(envInt("LIVE_REACTION_WINDOW", 10))
+	if liveReactionWindow < 1 {
+		liveReactionWindow = 1
+	}
+	liveReactionRate = envInt("LIVE_REACTION_RATE", 5)
+}
+
+type LiveReactionRequest struct {
+	ViewerID string `json:"viewer_id" binding:"required"`
+	Reaction string `json:"reaction" binding:"required"`
+}
+
+// takeLiveReactionSlot counts a reaction against the viewer's per-second rate
+func takeLiveReactionSlot(ctx context.Context, streamID int64, viewerID string) bool {
+	if liveReactionRate <= 0 {
+		return true
+	}
+	key := liveReactionRateKey(streamID, viewerID)
+	var count *redis.IntCmd
+	_, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		// SETNX starts a fresh window with its TTL; INCR keeps it
+		pipe.SetNX(ctx, key, 0, time.Second)
+		count = pipe.Incr(ctx, key)
+		return nil
+	})
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error checking live reaction rate: %v", streamID, err)
+		return true
+	}
+	return count.Val() <= int64(liveReactionRate)
+}
+
+// postLiveReaction adds a floating reaction to a stream
+func postLiveReaction(c *gin.Context) {
+	streamID, ok := parseStreamID(c)
+	if !ok {
+		return
+	}
+	var req LiveReactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	reqCtx := c.Request.Context()
+	if types := streamReactionTypes(reqCtx, streamID); !isReactionType(types, req.Reaction) {
+		c.JSON(400, gin.H{"error": "unknown reaction type", "reason": "invalid_reaction", "allowed": types})
+		return
+	}
+	if isBanned(reqCtx, streamID, req.ViewerID, "") {
+		c.JSON(403, gin.H{"error": "you are banned from this chat", "reason": "banned"})
+		return
+	}
+	if !takeLiveReactionSlot(reqCtx, streamID, req.ViewerID) {
+		c.Header("Retry-After", "1")
+		c.JSON(429, gin.H{"error": "you are reacting too fast", "reason": "rate_limited", "retry_after": 1})
+		return
+	}
+
+	key := liveReactionsKey(streamID, time.Now().Unix())
+	_, err := rdb.Pipelined(reqCtx, func(pipe redis.Pipeliner) error {
+		pipe.HIncrBy(reqCtx, key, req.Reaction, 1)
+		pipe.Expire(reqCtx, key, time.Duration(liveReactionWindow+1)*time.Second)
+		return nil
+	})
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error recording live reaction: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to react"})
+		return
+	}
+	c.JSON(200, gin.H{"success": true, "reaction": req.Reaction})
+}
+
+// decayLiveReactions sums per-second counts, newest first, weighting each
+// second linearly by how far it is from falling out of the window: the
+// current second counts fully, the oldest 1/window
+func decayLiveReactions(seconds []map[string]string, window int64) map[string]int {
+	totals := map[string]float64{}
+	for age, counts := range seconds {
+		weight := float64(window-int64(age)) / float64(window)
+		if weight <= 0 {
+			break
+		}
+		for reaction, v := range counts {
+			n, _ := strconv.ParseInt(v, 10, 64)
+			totals[reaction] += float64(n) * weight
+		}
+	}
+	out := map[string]int{}
+	for reaction, total := range totals {
+		if n := int(math.Round(total)); n > 0 {
+			out[reaction] = n
+		}
+	}
+	return out
+}
+
+// queueLiveReactions queues reads of the window's per-second counts on pipe,
+// newest first
+func queueLiveReactions(ctx context.Context, pipe redis.Pipeliner, streamID int64) []*redis.StringStringMapCmd {
+	now := time.Now().Unix()
+	cmds := make([]*redis.StringStringMapCmd, 0, liveReactionWindow)
+	for age := int64(0); age < liveReactionWindow; age++ {
+		cmds = append(cmds, pipe.HGetAll(ctx, liveReactionsKey(streamID, now-age)))
+	}
+	return cmds
+}
+
+// liveReactionsFrom returns a stream's decayed reaction counts from the
+// reads queueLiveReactions queued, nil when there are none
+func liveReactionsFrom(cmds []*redis.StringStringMapCmd) map[string]int {
+	seconds := make([]map[string]string, len(cmds))
+	for i, cmd := range cmds {
+		seconds[i] = cmd.Val()
+	}
+	if counts := decayLiveReactions(seconds, liveReactionWindow); len(counts) > 0 {
+		return counts
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// liveReact sends a floating reaction to stream 1
+func liveReact(t *testing.T, viewerID, reaction string) int {
+	t.Helper()
+	return request(t, http.MethodPost, "/stream/1/reaction", map[string]interface{}{"viewer_id": viewerID, "reaction": reaction}).Code
+}
+
+func TestDecayLiveReactions(t *testing.T) {
+	seconds := []map[string]string{
+		{"love": "10"},              // now: full weight
+		{"love": "10", "fire": "4"}, // 1s ago: 9/10
+		{}, {}, {}, {}, {}, {}, {},
+		{"fire": "10"},  // oldest second: 1/10
+		{"fire": "100"}, // out of the window
+	}
+	got := decayLiveReactions(seconds, 10)
+	if len(got) != 2 || got["love"] != 19 || got["fire"] != 5 {
+		t.Fatalf("decayed = %v, want love 19 fire 5", got)
+	}
+	if got := decayLiveReactions([]map[string]string{{}, {"wow": "1"}}, 10); got["wow"] != 1 {
+		t.Fatalf("one reaction 1s ago = %v, want it still shown", got)
+	}
+	if got := decayLiveReactions(nil, 10); len(got) != 0 {
+		t.Fatalf("no reactions = %v", got)
+	}
+}
+
+func TestLiveReactionsAggregateInCheckUpdate(t *testing.T) {
+	resetRedis(t)
+	for i := 0; i < 3; i++ {
+		if status := liveReact(t, fmt.Sprintf("v%d", i), "love"); status != 200 {
+			t.Fatalf("reaction: %d, want 200", status)
+		}
+	}
+	if status := liveReact(t, "v9", "fire"); status != 200 {
+		t.Fatalf("reaction: %d, want 200", status)
+	}
+	got := poll(t, 1, "v1", 0)["live_reactions"].(map[string]interface{})
+	if len(got) != 2 || got["love"] != float64(3) || got["fire"] != float64(1) {
+		t.Fatalf("live_reactions = %v, want love 3 fire 1", got)
+	}
+
+	// Older seconds fade out of the window
+	now := time.Now().Unix()
+	rdb.Del(ctx, liveReactionsKey(1, now), liveReactionsKey(1, now-1), liveReactionsKey(1, now-2))
+	rdb.HSet(ctx, liveReactionsKey(1, now-liveReactionWindow), "love", 50)
+	if _, ok := poll(t, 1, "v1", 0)["live_reactions"]; ok {
+		t.Fatal("reactions from outside the window are still counted")
+	}
+}
+
+func TestLiveReactionLimits(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &liveReactionRate, 2)
+	if status := liveReact(t, "v1", "nope"); status != 400 {
+		t.Fatalf("unknown type: %d, want 400", status)
+	}
+	liveReact(t, "v1", "love")
+	liveReact(t, "v1", "love")
+	if status := liveReact(t, "v1", "love"); status != 429 {
+		t.Fatalf("over the rate: %d, want 429", status)
+	}
+	if status := liveReact(t, "v2", "love"); status != 200 {
+		t.Fatalf("another viewer: %d, want 200", status)
+	}
+}
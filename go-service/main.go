@@ -2,8 +2,6 @@ package main
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -20,26 +18,130 @@ var ctx = context.Background()
 var persianSwear *PersianSwear
 var allowedOrigins []string
 
-func init() {
-	// Initialize Redis client
-	rdb = redis.NewClient(&redis.Options{
+// connectRedis creates the Redis client. Without Redis the service can't
+// run, except on the memory store: there the features outside its core
+// paths fail open, and each of their calls fails fast instead of retrying.
+func connectRedis() {
+	opts := &redis.Options{
 		Addr:     "redis:6379",
 		Password: "",
 		DB:       0,
-	})
+	}
+	_, memory := store.(*memoryStore)
+	if memory {
+		opts.MaxRetries = -1
+	}
+	rdb = redis.NewClient(opts)
 
 	// Test connection
 	_, err := rdb.Ping(ctx).Result()
 	if err != nil {
+		if memory {
+			log.Printf("[GO] Warning: Redis unreachable, running on the memory store alone: %v", err)
+			return
+		}
 		log.Fatal("Failed to connect to Redis:", err)
 	}
+}
 
+// loadConfig reads the service's configuration; rdb must be set
+func loadConfig() {
 	// Initialize PersianSwear filter
 	persianSwear = NewPersianSwear()
 	log.Printf("[GO] PersianSwear filter initialized with %d words", len(persianSwear.swearWords))
 
+	// Namespace for every Redis key, see keys.go
+	loadKeyPrefix()
+	loadReplicaConfig()
+	if keyPrefix != "" {
+		log.Printf("[GO] Using Redis key prefix %q", keyPrefix)
+	}
+
 	// Load allowed origins from environment
 	loadAllowedOrigins()
+
+	loadInternalAPIKey()
+	if internalAPIKey == "" {
+		log.Printf("[GO] Warning: INTERNAL_API_KEY not set, trusted endpoints are disabled")
+	}
+
+	loadMaintenanceMode()
+	loadFeedConfig()
+	loadGroupWindow()
+	loadRaidConfig()
+	loadNameColorPolicy()
+	loadSanitizeConfig()
+	loadRetentionConfig()
+	loadSSEConfig()
+	loadRateLimitConfig()
+	loadCorruptConfig()
+	loadSmoothingConfig()
+	loadFloodConfig()
+	loadPrefsConfig()
+	loadSystemConfig()
+	loadIDConfig()
+	loadResponseConfig()
+	loadBodyLimitConfig()
+	loadIDStrategyConfig()
+	loadEngagementConfig()
+	loadProfanityConfig()
+	loadNewViewerConfig()
+	loadScriptConfig()
+	loadFeaturedConfig()
+	loadTrustedDomainsConfig()
+	loadConcurrencyConfig()
+	loadDigestConfig()
+	loadSessionConfig()
+	loadSamplingConfig()
+	loadReputationConfig()
+	loadHealthConfig()
+	loadQuoteConfig()
+	loadScrollConfig()
+	loadLinkConfig()
+	loadReplayConfig()
+	loadLiveReactionConfig()
+	loadTierConfig()
+	loadGuestConfig()
+	loadPollRateConfig()
+	loadRedactConfig()
+	loadDedupConfig()
+	loadCacheConfig()
+	loadWelcomeConfig()
+	loadClosedChatConfig()
+	loadQuotaConfig()
+	loadAuditConfig()
+	loadEmojiDensityConfig()
+	loadDripConfig()
+	loadNormalizeConfig()
+	loadTimezoneConfig()
+	loadConsumerConfig()
+	loadSimilarityConfig()
+	loadPriorityConfig()
+	loadStaleConfig()
+	loadCatchUpConfig()
+	loadReactionRateConfig()
+	loadActivityConfig()
+	loadPlatformConfig()
+	loadDebugConfig()
+	loadUsernameConfig()
+	loadEvictionConfig()
+	loadSeenConfig()
+	loadWriteBufferConfig()
+	loadGeoConfig()
+	loadVersionConfig()
+	loadCompactionConfig()
+	loadConnConfig()
+	loadTranslateConfig()
+	loadModPresenceConfig()
+	loadCampaignConfig()
+	loadExistenceConfig()
+	loadReactionConfig()
+	loadStreamIngestConfig()
+	loadHighlightConfig()
+	loadIntegrityConfig()
+	if maintenanceMode {
+		log.Printf("[GO] MAINTENANCE_MODE enabled, write endpoints are read-only")
+	}
 }
 
 func loadAllowedOrigins() {
@@ -71,15 +173,92 @@ func loadAllowedOrigins() {
 }
 
 type CheckUpdateRequest struct {
-	StreamID int64 `json:"stream_id" binding:"required"`
-	LastID   int64 `json:"last_id"`
+	StreamID flexID `json:"stream_id" binding:"required"`
+	LastID   int64  `json:"last_id"`
+	ViewerID string `json:"viewer_id"`
+	Order    string `json:"order" binding:"omitempty,oneof=chronological grouped desc"`
+	// Preferences overrides the viewer's stored delivery preferences
+	Preferences *DeliveryPrefs `json:"preferences"`
+	// Digest returns a count and the newest few comments instead of all
+	Digest bool `json:"digest"`
+	// Reading tells the server the viewer is reading (scrolled away from
+	// the newest comments), for the scroll hint
+	Reading bool `json:"reading"`
+	// Before pages a truncated poll: only comments older than it are
+	// returned (see pollMaxComments)
+	Before int64 `json:"before"`
+	// BeforeID continues a page inside Before's millisecond: comments at
+	// Before are returned if their ID is below it
+	BeforeID flexID `json:"before_id"`
+	// EditsSince is the server_time of the previous response, to receive
+	// comments edited since (see edits.go)
+	EditsSince int64 `json:"edits_since"`
+	// DisplayTime adds display_time in the stream's timezone to comments
+	DisplayTime bool `json:"display_time"`
+	// ConsumerID polls as an acknowledging consumer, resuming from its
+	// watermark; Ack is the cursor of the last response it processed (see
+	// consumer.go)
+	ConsumerID string `json:"consumer_id"`
+	Ack        int64  `json:"ack"`
+	// CatchUp summarizes a long gap instead of returning all of it (see
+	// catchup.go)
+	CatchUp bool `json:"catch_up"`
+	// Conn is the client's connection type, to adapt the response to (see
+	// conn.go)
+	Conn string `json:"conn"`
 }
 
 type Comment struct {
-	ID        int64  `json:"id"`
-	Username  string `json:"username"`
-	Message   string `json:"message"`
-	Timestamp int64  `json:"timestamp"`
+	ID        int64             `json:"id"`
+	Username  string            `json:"username"`
+	Message   string            `json:"message"`
+	Timestamp int64             `json:"timestamp"`
+	Emotes    map[string]string `json:"emotes,omitempty"`
+	ExpiresAt int64             `json:"expires_at,omitempty"`
+	Source    string            `json:"source,omitempty"`
+	Type      string            `json:"type,omitempty"` // "system" for system messages
+
+	filtered  string         // profanity tier that was masked, reported to the poster only
+	NameColor string         `json:"name_color,omitempty"`
+	Messages  []string       `json:"messages,omitempty"` // set on grouped entries
+	Quote     *QuotedComment `json:"quote,omitempty"`    // snapshot taken at post time
+	MinTier   string         `json:"min_tier,omitempty"` // subscriber tier needed to read it
+	EditedAt  int64          `json:"edited_at,omitempty"`
+	Version   int64          `json:"version,omitempty"` // bumped by every edit (see versions.go)
+	// Multiplier is how many viewers posted this message, once copies were
+	// collapsed into it (see dedup.go)
+	Multiplier int `json:"multiplier,omitempty"`
+	// DisplayTime is Timestamp in the stream's timezone, set on responses
+	// that asked for it (see timezone.go)
+	DisplayTime string `json:"display_time,omitempty"`
+	// Priority marks comments from the streamer and moderators (see
+	// priority.go)
+	Priority bool `json:"priority,omitempty"`
+	// Verified marks comments whose author a trusted caller vouched for;
+	// only these count towards the author's reputation
+	Verified bool `json:"verified,omitempty"`
+	// Platform is where the comment was posted from, e.g. "web" or "ios"
+	Platform  string `json:"platform,omitempty"`
+	collapsed bool   // the submission was folded into this existing comment
+	shadowed  bool   // posted under a shadow ban, never stored
+	buffered  bool   // held until chat reopens (see closedchat.go)
+	deferred  bool   // waiting in the write buffer for Redis (see writebuffer.go)
+	quotaLeft int    // comments the poster has left, -1 without a quota
+}
+
+type PostCommentRequest struct {
+	StreamID flexID `json:"stream_id" binding:"required"`
+	ViewerID string `json:"viewer_id"`
+	Username string `json:"username"` // "" posts as a guest, see guests.go
+	Message  string `json:"message" binding:"required"`
+	// ExpiresIn makes the comment ephemeral, hidden after this many seconds
+	ExpiresIn int64 `json:"expires_in"`
+	// Quote is the ID of a comment in the stream to quote
+	Quote flexID `json:"quote"`
+	// MinTier limits the comment to subscribers of this tier and above
+	MinTier string `json:"min_tier"`
+	// Platform is the client the comment was posted from (see platform.go)
+	Platform string `json:"platform"`
 }
 
 type UpdateCheckResponse struct {
@@ -87,11 +266,52 @@ type UpdateCheckResponse struct {
 	Comments      []Comment `json:"comments,omitempty"`
 	Online        int       `json:"online,omitempty"`
 	AllowComments bool      `json:"allow_comments"`
+	SlowMode      int       `json:"slow_mode,omitempty"`
+	Cooldown      int       `json:"cooldown,omitempty"`
+	ReadOnly      bool      `json:"read_only,omitempty"`
+	Delay         int       `json:"delay,omitempty"`
+	// OnlineSmoothed is a moving average of Online for display (ONLINE_SMOOTHING)
+	OnlineSmoothed int   `json:"online_smoothed,omitempty"`
+	Live           bool  `json:"live,omitempty"`
+	Elapsed        int64 `json:"elapsed,omitempty"`   // seconds since the stream started
+	Throttled      bool  `json:"throttled,omitempty"` // flood guard is refusing posts
+	Cursor         int64 `json:"cursor,omitempty"`    // last_id to send next poll
+	// ServerTime is the "now" (ms) comments were published up to, so clients
+	// can time scheduled and delayed reveals against the server's clock
+	ServerTime int64 `json:"server_time"`
+	// FeaturedQuestion is the paid question currently pinned above the feed
+	FeaturedQuestion *FeaturedQuestion `json:"featured_question,omitempty"`
+	Highlights       []Highlight       `json:"highlights,omitempty"` // top of chat in precedence order, see highlights.go
+	Digest           *CommentDigest    `json:"digest,omitempty"`
+	Sampling         *SamplingInfo     `json:"sampling,omitempty"`
+	Drip             *DripInfo         `json:"drip,omitempty"`
+	ScrollHint       *ScrollHint       `json:"scroll_hint,omitempty"`
+	LiveReactions    map[string]int    `json:"live_reactions,omitempty"` // floating reactions, type -> count
+	Truncated        bool              `json:"truncated,omitempty"`      // older comments were left out, see Before
+	Before           int64             `json:"before,omitempty"`         // before to page the older comments with
+	BeforeID         int64             `json:"before_id,omitempty"`      // with before, when the page ends inside a millisecond
+	Welcome          *WelcomeMessage   `json:"welcome,omitempty"`        // initial loads only
+	Edits            []Comment         `json:"edits,omitempty"`          // comments edited since edits_since
+	Timezone         string            `json:"timezone,omitempty"`       // zone of display_time
+	Watermark        int64             `json:"watermark,omitempty"`      // consumer's acknowledged cursor
+	Priority         []Comment         `json:"priority,omitempty"`       // streamer and moderator comments in the polled range
+	CatchUp          *CatchUpSummary   `json:"catch_up,omitempty"`       // set when a long gap was summarized
+	SeenCounts       map[string]int    `json:"seen_counts,omitempty"`    // comment ID -> viewers past it, see seen.go
+	Delivery         string            `json:"delivery,omitempty"`       // how the response was adapted to conn
+	ModProfile       string            `json:"mod_profile,omitempty"`    // limits in force for moderator presence, see modpresence.go
+	NextPollAfterMs  int64             `json:"next_poll_after_ms,omitempty"`
+	StreamNotFound   bool              `json:"stream_not_found,omitempty"` // nothing ever created the stream, see existence.go
+	APIVersion       string            `json:"api_version"`
 }
 
 type HeartbeatRequest struct {
-	StreamID int64  `json:"stream_id" binding:"required"`
+	StreamID flexID `json:"stream_id" binding:"required"`
 	ViewerID string `json:"viewer_id"`
+	// SessionToken is the token a previous heartbeat returned
+	SessionToken string `json:"session_token"`
+	// Conn is the client's connection type; the response says which
+	// delivery check-update will use for it (see conn.go)
+	Conn string `json:"conn"`
 }
 
 type CheckSwearRequest struct {
@@ -99,7 +319,8 @@ type CheckSwearRequest struct {
 }
 
 type CheckSwearResponse struct {
-	HasSwear bool `json:"has_swear"`
+	HasSwear bool   `json:"has_swear"`
+	Tier     string `json:"tier,omitempty"`
 }
 
 func checkUpdate(c *gin.Context) {
@@ -109,111 +330,299 @@ func checkUpdate(c *gin.Context) {
 		return
 	}
 
-	// Get comments that should be published now (timestamp <= now) and are newer than last_id
-	now := time.Now().Unix() * 1000
+	// Aggressive pollers are turned away before they cost a feed read
+	reqCtx := c.Request.Context()
+	interval := pollInterval(reqCtx, int64(req.StreamID))
+	if interval > 0 && !isPrivileged(requestRole(c)) {
+		who := req.ViewerID
+		if who == "" {
+			who = "ip:" + c.ClientIP()
+		}
+		if ok, wait := takePollSlot(reqCtx, int64(req.StreamID), who, interval); !ok {
+			c.Header("Retry-After", strconv.Itoa(int((wait+time.Second-1)/time.Second)))
+			c.JSON(429, gin.H{"error": "polling too frequently", "reason": "poll_too_soon", "next_poll_after_ms": wait.Milliseconds()})
+			return
+		}
+	}
 
-	// Redis keys
-	sid := fmt.Sprintf("%d", req.StreamID)
-	idxKey := fmt.Sprintf("comments:index:%s", sid)
-	dataKey := fmt.Sprintf("comments:data:%s", sid)
-	onlineKey := fmt.Sprintf("online:%d", req.StreamID)
+	// Get comments that should be published now (timestamp <= now) and are newer than last_id
+	now := feedNow()
 
-	// Get comment IDs ready to publish (timestamp <= now)
-	reqCtx := c.Request.Context()
-	var ids []string
-	var err error
-	
+	// Chat delay gives moderators a buffer, so they see the undelayed feed
+	q := feedQuery{StreamID: int64(req.StreamID), Min: req.LastID + 1, Max: now, ApplyDelay: !isPrivileged(requestRole(c))}
+	delivery := deliveryFor(req.Conn)
+	maxComments := deliveryMaxComments(delivery)
 	if req.LastID == 0 {
-		// Initial load: get all comments (last 100 to avoid loading too many)
-		zMembers, zErr := rdb.ZRangeByScoreWithScores(reqCtx, idxKey, &redis.ZRangeBy{
-			Min: "0",                    // From beginning
-			Max: strconv.FormatInt(now, 10), // Only those ready (timestamp <= now)
-		}).Result()
-		
-		// Get only the IDs (not scores) and take last 100
-		idStrings := make([]string, 0)
-		if zErr == nil {
-			for _, z := range zMembers {
-				if memberStr, ok := z.Member.(string); ok {
-					idStrings = append(idStrings, memberStr)
-				}
-			}
-			// Take last 100 comments
-			if len(idStrings) > 100 {
-				idStrings = idStrings[len(idStrings)-100:]
-			}
-		} else {
-			log.Printf("[GO] Error getting initial comments from Redis: %v", zErr)
+		// Initial load: the newest comments only, to avoid loading too many
+		q.Min, q.Limit = 0, deliveryInitialLimit(delivery)
+	} else if maxComments > 0 {
+		// One past the cap tells a truncated poll from one that just fits
+		q.Limit = maxComments + 1
+	}
+	var watermark int64
+	if req.ConsumerID != "" {
+		if !isTrustedRequest(c) {
+			c.JSON(403, gin.H{"error": "consumers require a trusted caller"})
+			return
 		}
-		ids = idStrings
-		err = zErr // Set err for later use
-	} else {
-		// Update: get only new ones after last_id
-		ids, err = rdb.ZRangeByScore(reqCtx, idxKey, &redis.ZRangeBy{
-			Min: strconv.FormatInt(req.LastID+1, 10), // Only new ones after last_id
-			Max: strconv.FormatInt(now, 10),           // Only those ready (timestamp <= now)
-		}).Result()
-		if err != nil {
-			log.Printf("[GO] Error getting updated comments from Redis: %v", err)
-			ids = []string{} // Set empty slice on error
+		if !consumerIDPattern.MatchString(req.ConsumerID) {
+			c.JSON(400, gin.H{"error": "invalid consumer_id"})
+			return
+		}
+		var err error
+		if watermark, err = ackConsumer(reqCtx, int64(req.StreamID), req.ConsumerID, req.Ack); err != nil {
+			log.Printf("[GO] Stream %d: Error loading consumer %s: %v", int64(req.StreamID), req.ConsumerID, err)
+			c.JSON(500, gin.H{"error": "failed to load consumer"})
+			return
+		}
+		start := req.LastID
+		if watermark > 0 {
+			start = watermark
 		}
+		q.Min, q.Limit, q.Oldest = start+1, 0, pollMaxComments > 0
+		if q.Oldest {
+			q.Limit = pollMaxComments + 1
+		}
+		req.Before, req.BeforeID = 0, 0
 	}
 
-	// Get allow_comments status from Redis FIRST (set by backend when toggled)
-	allowCommentsKey := fmt.Sprintf("stream:allow_comments:%d", req.StreamID)
-	allowCommentsStr, err := rdb.Get(reqCtx, allowCommentsKey).Result()
-	allowComments := true // Default to true if not set
-	if err == nil {
-		// Check for "1" (true) or "true" (string), anything else is false
-		allowComments = allowCommentsStr == "1" || allowCommentsStr == "true"
-		log.Printf("[GO] Stream %d: allow_comments from Redis = '%s' -> %v", req.StreamID, allowCommentsStr, allowComments)
-	} else {
-		log.Printf("[GO] Stream %d: allow_comments not found in Redis, defaulting to true (error: %v)", req.StreamID, err)
-	}
-
-	comments := []Comment{}
-
-	// Only get comments if allow_comments is true
-	if allowComments {
-		if len(ids) > 0 {
-			// Get full comment data
-			data, dataErr := rdb.HMGet(reqCtx, dataKey, ids...).Result()
-			if dataErr == nil {
-				for _, d := range data {
-					if d == nil {
-						continue
-					}
-					
-					var cmt Comment
-					if jsonErr := json.Unmarshal([]byte(d.(string)), &cmt); jsonErr == nil {
-						comments = append(comments, cmt)
-					}
-				}
-			} else {
-				log.Printf("[GO] Error getting comment data from Redis: %v", dataErr)
+	state := loadPollState(reqCtx, int64(req.StreamID), req.ViewerID)
+	modes, modesErr := state.Modes, state.ModesErr
+	var drip *DripInfo
+	dripDelay := 0
+	if modesErr == nil && modes.Drip.Count > 0 && !isPrivileged(requestRole(c)) {
+		drip, dripDelay = dripFeedQuery(reqCtx, &q, modes.Drip)
+	}
+	if req.Before > 0 {
+		pageFeedQuery(reqCtx, &q, req.Before, int64(req.BeforeID))
+	}
+	snap := store.ReadFeed(reqCtx, q)
+	notFound := state.Missing && len(snap.IDs) == 0
+	if notFound && streamNotFoundMode == streamNotFound404 {
+		c.JSON(404, gin.H{"error": "stream not found", "reason": "stream_not_found"})
+		return
+	}
+	allowComments := snap.AllowComments
+	if dripDelay > 0 {
+		snap.Delay = dripDelay
+	}
+
+	// The newest score the read covered, once the delay was taken off
+	readMax := q.Max
+	if q.ApplyDelay {
+		readMax -= int64(snap.Delay) * 1000
+	}
+
+	comments := snap.decodeComments(reqCtx, int64(req.StreamID), now)
+	var before, beforeID int64
+	truncated := false
+	var catchUp *CatchUpSummary
+	if req.ConsumerID != "" {
+		comments, truncated = consumerPage(reqCtx, q, comments, pollMaxComments, now)
+	} else if req.LastID != 0 {
+		comments, before, beforeID = truncatePoll(comments, maxComments)
+		truncated = before > 0
+		if req.CatchUp && req.Before == 0 {
+			var catchUpBefore, catchUpBeforeID int64
+			if comments, catchUpBefore, catchUpBeforeID, catchUp = catchUpPoll(reqCtx, int64(req.StreamID), comments, truncated, req.LastID, q.Min, readMax); catchUp != nil {
+				before, beforeID, truncated = catchUpBefore, catchUpBeforeID, true
 			}
-		} else {
-			log.Printf("[GO] Stream %d: No comment IDs found (err: %v, ids count: %d)", req.StreamID, err, len(ids))
 		}
 	}
 
-	// Get online count
-	online, err := rdb.SCard(reqCtx, onlineKey).Result()
-	if err != nil {
-		online = 0
+	// Computed before filtering and reordering: the cursor is always the
+	// newest comment read, even if the viewer doesn't receive it
+	cursor := newestTimestamp(comments)
+
+	// Preferences sent with the request win over the viewer's stored ones
+	prefs := state.Prefs
+	if req.Preferences != nil {
+		prefs = *req.Preferences
+	}
+	filter := newDeliveryFilter(reqCtx, int64(req.StreamID), req.ViewerID, prefs, viewerAccess(c))
+	comments = filter.apply(comments)
+
+	// The lane covers the same range as the read, before truncation. Pages
+	// of a truncated poll and consumers already get every comment.
+	var priority []Comment
+	if priorityLane && req.Before == 0 && req.ConsumerID == "" {
+		priority = filter.apply(readPriorityLane(reqCtx, int64(req.StreamID), q.Min, readMax, now))
+	}
+
+	var sampling *SamplingInfo
+	if modesErr == nil && !isPrivileged(requestRole(c)) && req.ConsumerID == "" {
+		comments, sampling = sampleComments(reqCtx, int64(req.StreamID), req.ViewerID, modes, comments)
 	}
 
+	// Counted before digests and grouping shrink the list: it's how many
+	// new comments the viewer will have to take in
+	hint := scrollHint(state.ScrollRate, req.LastID == 0, len(comments), req.Reading)
+
+	var digest *CommentDigest
+	if req.Digest || c.Query("digest") == "true" || delivery == deliveryLean {
+		digest = &CommentDigest{Count: len(comments), Since: req.LastID}
+		comments = digestComments(comments)
+	}
+
+	if req.Order == orderGrouped {
+		comments = groupComments(comments)
+	}
+	if req.Order == orderNewestFirst {
+		reverseComments(comments)
+	}
+
+	online := snap.Online
+
 	resp := UpdateCheckResponse{
 		HasUpdates:    len(comments) > 0 && allowComments,
 		Comments:      comments,
 		Online:        int(online),
 		AllowComments: allowComments,
+		ReadOnly:      state.Maintenance,
+		Delay:         snap.Delay,
+		Cursor:        cursor,
+		ServerTime:    now,
+		APIVersion:    apiVersion,
+		Digest:        digest,
+		Sampling:      sampling,
+		Drip:          drip,
+		ScrollHint:    hint,
+		Truncated:     truncated,
+		Before:        before,
+		BeforeID:      beforeID,
+		Watermark:     watermark,
+		Priority:      priority,
+		CatchUp:       catchUp,
+	}
+	resp.NextPollAfterMs = interval.Milliseconds()
+	resp.StreamNotFound = notFound
+	resp.OnlineSmoothed = smoothedOnline(reqCtx, int64(req.StreamID), online)
+	resp.FeaturedQuestion = currentFeaturedQuestion(reqCtx, int64(req.StreamID))
+	resp.Highlights = streamHighlights(reqCtx, int64(req.StreamID), state.Highlights, resp.FeaturedQuestion, filter, req.LastID == 0, readMax, now)
+	if delivery != deliveryLean {
+		resp.LiveReactions = state.LiveReactions
+	}
+	if req.Conn != "" {
+		resp.Delivery = delivery
+	}
+	if req.LastID == 0 {
+		resp.Welcome = initialWelcome(reqCtx, int64(req.StreamID))
+	}
+	if req.EditsSince > 0 {
+		if edits, _, err := loadEdits(reqCtx, int64(req.StreamID), req.EditsSince); err == nil {
+			resp.Edits = filter.apply(edits)
+		} else {
+			log.Printf("[GO] Stream %d: Error loading edits: %v", int64(req.StreamID), err)
+		}
+	}
+	if req.DisplayTime {
+		zone := state.Zone
+		resp.Comments = withDisplayTime(resp.Comments, zone)
+		resp.Edits = withDisplayTime(resp.Edits, zone)
+		resp.Priority = withDisplayTime(resp.Priority, zone)
+		resp.Timezone = zone.String()
+	}
+	if state.StatusErr == nil && state.Status.Live {
+		resp.Live = true
+		resp.Elapsed = int64(state.Status.Elapsed(time.Now()).Seconds())
 	}
 
-	log.Printf("[GO] Stream %d: Returning %d comments, has_updates=%v, allow_comments=%v", req.StreamID, len(comments), resp.HasUpdates, allowComments)
+	// Surface slow-mode so the input can show a countdown proactively
+	// and the flood guard so it can explain refused posts
+	if modesErr == nil {
+		if resp.ModProfile = state.ModProfile; resp.ModProfile != "" {
+			modes = applyModProfile(modes, resp.ModProfile)
+		}
+		if modes.SlowMode > 0 {
+			resp.SlowMode = modes.SlowMode
+			if req.ViewerID != "" {
+				resp.Cooldown, _ = slowModeCooldown(reqCtx, int64(req.StreamID), req.ViewerID)
+			}
+		}
+		resp.Throttled = floodFull(state.FloodCount, modes)
+		if req.ConsumerID == "" && delivery != deliveryLean && seenEnabled(modes, online) {
+			recordReadCursor(reqCtx, int64(req.StreamID), req.ViewerID, req.LastID)
+			if counts, err := loadSeenCounts(reqCtx, int64(req.StreamID), readMax); err == nil {
+				resp.SeenCounts = counts
+			} else {
+				log.Printf("[GO] Stream %d: Error loading seen counts: %v", int64(req.StreamID), err)
+			}
+		}
+	}
+
+	log.Printf("[GO] Stream %d: Returning %d comments, has_updates=%v, allow_comments=%v", int64(req.StreamID), len(comments), resp.HasUpdates, allowComments)
 	c.JSON(200, resp)
 }
 
+func postComment(c *gin.Context) {
+	var req PostCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	cmt, rejection, err := submitComment(c.Request.Context(), req, commentOrigin{IP: c.ClientIP(), Role: requestRole(c), Tier: requestTier(c), Country: requestCountry(c), Verified: isTrustedRequest(c)})
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error storing comment: %v", int64(req.StreamID), err)
+		c.JSON(500, gin.H{"error": "failed to store comment"})
+		return
+	}
+	if rejection != nil {
+		respondRejection(c, rejection)
+		return
+	}
+
+	status, reason := postStatus(cmt)
+	resp := gin.H{"success": true, "status": status, "comment": cmt}
+	if reason != "" {
+		resp["reason"] = reason
+	}
+	if cmt.filtered != "" {
+		resp["filtered"] = cmt.filtered
+	}
+	if cmt.collapsed {
+		resp["collapsed"] = true
+	}
+	if cmt.buffered {
+		resp["buffered"] = true
+	}
+	if cmt.quotaLeft >= 0 {
+		resp["quota_remaining"] = cmt.quotaLeft
+	}
+	c.JSON(200, resp)
+}
+
+func getEmotes(c *gin.Context) {
+	streamID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid stream id"})
+		return
+	}
+
+	custom, err := loadStreamEmotes(c.Request.Context(), streamID)
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error loading custom emotes: %v", streamID, err)
+		custom = map[string]string{}
+	}
+
+	packs, err := loadEmotePacks(c.Request.Context(), streamID, true)
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error loading emote packs: %v", streamID, err)
+	}
+	// Packs group the emotes for autocomplete; emotes already has them all
+	for i := range packs {
+		for name := range packs[i].Emotes {
+			if _, ok := custom[name]; !ok {
+				delete(packs[i].Emotes, name)
+			}
+		}
+	}
+	if packs == nil {
+		packs = []EmotePack{}
+	}
+	c.JSON(200, gin.H{"emoji": builtinEmoji, "emotes": custom, "packs": packs})
+}
+
 func heartbeat(c *gin.Context) {
 	var req HeartbeatRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -221,22 +630,47 @@ func heartbeat(c *gin.Context) {
 		return
 	}
 
-	// Add viewer to online set with 2 minute expiration
-	onlineKey := fmt.Sprintf("online:%d", req.StreamID)
 	reqCtx := c.Request.Context()
+	// Only a trusted caller can bind a new session to the viewer_id it names
+	var sessionViewer string
+	if isTrustedRequest(c) {
+		sessionViewer = req.ViewerID
+	}
+	session := resumeSession(reqCtx, int64(req.StreamID), req.SessionToken, sessionViewer)
+	if session != nil && session.resumed {
+		req.ViewerID = session.ViewerID
+	}
 	
-	// Add viewer with 2 minute TTL
-	err := rdb.SAdd(reqCtx, onlineKey, req.ViewerID).Err()
+	// Counts the viewer as online for the next 2 minutes
+	added, online, err := store.MarkOnline(reqCtx, int64(req.StreamID), req.ViewerID)
 	if err == nil {
-		// Set expiration to 2 minutes (120 seconds)
-		rdb.Expire(reqCtx, onlineKey, 120*time.Second)
+		if added && req.ViewerID != "" {
+			notifyPresence(reqCtx, int64(req.StreamID), presenceJoin, req.ViewerID)
+		}
+		recordPeak(reqCtx, int64(req.StreamID), online)
+		observeActivity(reqCtx, int64(req.StreamID), online)
+		if req.ViewerID != "" {
+			recordPresence(reqCtx, int64(req.StreamID), req.ViewerID)
+			recordHeartbeat(reqCtx, int64(req.StreamID), req.ViewerID)
+			if isPrivileged(requestRole(c)) {
+				recordModeratorPresence(reqCtx, int64(req.StreamID), req.ViewerID)
+			}
+		}
 	}
 
-	c.JSON(200, gin.H{"success": true})
-}
+	// Someone is watching, keep an ephemeral stream's chat alive
+	touchStream(reqCtx, int64(req.StreamID))
 
-func health(c *gin.Context) {
-	c.JSON(200, gin.H{"status": "healthy", "service": "comment-polling"})
+	resp := gin.H{"success": true}
+	if session != nil {
+		resp["session_token"] = session.Token
+		resp["viewer_id"] = session.ViewerID
+		resp["watch_time"] = session.WatchTime / 1000
+	}
+	if req.Conn != "" {
+		resp["delivery"] = deliveryFor(req.Conn)
+	}
+	c.JSON(200, resp)
 }
 
 func checkSwear(c *gin.Context) {
@@ -247,10 +681,12 @@ func checkSwear(c *gin.Context) {
 	}
 
 	// Check if text contains swear words
-	hasSwear := persianSwear.HasSwear(req.Text)
+	tier := profanity.highestTier(req.Text)
+	hasSwear := tier != ""
 
 	resp := CheckSwearResponse{
 		HasSwear: hasSwear,
+		Tier:     tier,
 	}
 
 	log.Printf("[GO] Swear check: text length=%d, has_swear=%v", len(req.Text), hasSwear)
@@ -287,24 +723,130 @@ func corsMiddleware() gin.HandlerFunc {
 }
 
 func main() {
+	loadStoreConfig()
+	connectRedis()
+	loadConfig()
+
 	// Set release mode for production (must be before gin.New())
 	gin.SetMode(gin.ReleaseMode)
-	
+	r := newRouter()
+
+	// Background jobs
+	go runLiveHub(ctx)
+	go runPresenceHub(ctx)
+	go runProfanityRefresher(ctx, time.Duration(envInt("PROFANITY_REFRESH_INTERVAL", 30))*time.Second)
+	go runTrustedDomainsRefresher(ctx, time.Duration(envInt("TRUSTED_DOMAINS_REFRESH_INTERVAL", 30))*time.Second)
+	go runExpirySweeper(ctx, time.Duration(envInt("EXPIRY_SWEEP_INTERVAL", 10))*time.Second)
+	go runCommentEviction(ctx, time.Duration(envInt("COMMENT_EVICT_INTERVAL", 30))*time.Second)
+	go runActivitySweeper(ctx, time.Duration(envInt("ACTIVE_SWEEP_INTERVAL", 10))*time.Second)
+	go runRaidSweeper(ctx, time.Duration(envInt("RAID_SWEEP_INTERVAL", 5))*time.Second)
+	go runWriteBufferFlush(ctx)
+	go runCompaction(ctx)
+	go runStreamIngest(ctx)
+	go runBufferReveal(ctx, time.Duration(envInt("CHAT_BUFFER_REVEAL_INTERVAL", 1))*time.Second)
+
+	// Start server
+	port := ":9000"
+	log.Printf("Starting Go service on %s", port)
+	if err := http.ListenAndServe(port, r); err != nil {
+		log.Fatal("Failed to start server:", err)
+	}
+}
+
+// newRouter sets up the middleware and routes
+func newRouter() *gin.Engine {
 	r := gin.New()
-	r.Use(gin.Recovery()) // Add recovery middleware
+	r.Use(recoveryMiddleware())
 	r.Use(corsMiddleware())
+	r.Use(responseMiddleware())
+	r.Use(cacheMiddleware())
+	r.Use(concurrencyMiddleware())
+	r.Use(bodyLimitMiddleware())
 
 	// Routes
 	r.POST("/check-update", checkUpdate)
 	r.POST("/heartbeat", heartbeat)
 	r.POST("/check-swear", checkSwear)
+	r.POST("/comment/translate", translateComment)
+	r.GET("/stream/:id/emotes", cacheFor(emotesCacheTTL), getEmotes)
+	r.GET("/stream/:id/reactions", cacheFor(emotesCacheTTL), getReactionTypes)
+	r.GET("/stream/:id/mine", getMyComments)
+	r.GET("/stream/:id/top-comments", cacheFor(topCommentsCacheTTL), getTopComments)
+	r.GET("/stream/:id/cursor", cacheFor(cursorCacheTTL), getStreamCursor)
+	r.GET("/stream/:id/replay/timeline", cacheFor(replayHTTPCacheTTL), getReplayTimeline)
+	r.GET("/stream/:id/events", streamEvents)
+	r.GET("/stream/:id/ws", streamSocket)
 	r.GET("/health", health)
-
-	// Start server
-	port := ":9000"
-	log.Printf("Starting Go service on %s", port)
-	if err := http.ListenAndServe(port, r); err != nil {
-		log.Fatal("Failed to start server:", err)
+	r.GET("/livez", livez)
+	r.GET("/metrics", metricsHandler)
+
+	// Moderator endpoints
+	mods := r.Group("/")
+	mods.Use(requireModerator())
+	mods.GET("/stream/:id/viewers", getOnlineViewers)
+	mods.GET("/stream/:id/comments", getStoredComments)
+	mods.GET("/stream/:id/integrity", getIntegrity)
+	mods.GET("/stream/:id/reputation", getReputation)
+	mods.GET("/stream/:id/stats", getFilterStats)
+	mods.GET("/stream/:id/audit", getAuditLog)
+	mods.POST("/stream/:id/integrity/repair", repairIntegrity)
+	mods.POST("/stream/:id/purge", purgeComments)
+	mods.POST("/stream/:id/comments/:comment_id/edit", editCommentMessage)
+	mods.POST("/stream/:id/comments/:comment_id/delete", deleteComment)
+	mods.POST("/stream/:id/profanity/rescan", rescanProfanity)
+	mods.POST("/stream/:id/ban", banViewer)
+	mods.POST("/stream/:id/unban", unbanViewer)
+	mods.POST("/stream/:id/clear", clearChat)
+	mods.POST("/stream/:id/welcome", setWelcome)
+	mods.POST("/stream/:id/timezone", setTimezone)
+	mods.GET("/stream/:id/emote-packs", getEmotePacks)
+	mods.POST("/stream/:id/emote-packs/:pack", updateEmotePack)
+	mods.POST("/stream/:id/emote-packs/:pack/delete", deleteEmotePack)
+	mods.POST("/stream/:id/reactions", setReactionTypes)
+	mods.GET("/stream/:id/highlights", getHighlightPolicy)
+	mods.POST("/stream/:id/highlights", setHighlightPolicy)
+	mods.GET("/stream/:id/geo-policy", getGeoPolicy)
+	mods.POST("/stream/:id/geo-policy", setGeoPolicy)
+
+	// Admin debugging, only routed when DEBUG_ENDPOINTS is set
+	if debugEndpoints {
+		admin := r.Group("/")
+		admin.Use(requireAdmin())
+		admin.GET("/stream/:id/debug", getStreamDebug)
+		admin.POST("/stream/:id/reset", resetStream)
 	}
-}
 
+	// Write endpoints, disabled while in maintenance
+	writes := r.Group("/")
+	writes.Use(maintenanceMiddleware())
+	writes.POST("/post-comment", postComment)
+	writes.POST("/react", reactToComment)
+	writes.POST("/stream/:id/reaction", postLiveReaction)
+	writes.POST("/report", reportComment)
+	writes.POST("/name-color", setNameColor)
+	writes.POST("/viewer/privacy", setViewerPrivacy)
+	writes.POST("/viewer/preferences", setDeliveryPrefs)
+
+	// Trusted integrations
+	trusted := writes.Group("/")
+	trusted.Use(requireInternalKey())
+	trusted.POST("/ingest", ingestComments)
+
+	// Backend-driven stream control, available even during maintenance
+	control := r.Group("/")
+	control.Use(requireInternalKey())
+	control.POST("/stream/:id/register", registerStream)
+	control.POST("/stream/:id/start", startStream)
+	control.POST("/stream/:id/end", endStream)
+	control.POST("/stream/:id/system", postSystemMessage)
+	control.POST("/stream/:id/featured-questions", featureQuestion)
+	control.POST("/stream/:id/featured-questions/cancel", cancelFeaturedQuestion)
+	control.POST("/bot-tokens", createBotToken)
+	control.GET("/bot-tokens", listBotTokens)
+	control.GET("/active-streams", getActiveStreams)
+	control.GET("/compaction", getCompaction)
+	control.GET("/spam-campaigns", getSpamCampaigns)
+	control.POST("/spam-campaigns/:fingerprint/clear", clearSpamCampaign)
+	control.POST("/bot-tokens/:token_id/revoke", revokeBotToken)
+	return r
+}
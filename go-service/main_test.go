@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// Tests run against an in-process Redis (miniredis), flushed before each
+// test, and the service's real router.
+var (
+	testRedis  *miniredis.Miniredis
+	testRouter *gin.Engine
+)
+
+const testAPIKey = "test-key"
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	gin.SetMode(gin.TestMode)
+	if !testing.Verbose() {
+		log.SetOutput(io.Discard)
+	}
+	testRedis = miniredis.NewMiniRedis()
+	if err := testRedis.Start(); err != nil {
+		log.Fatal(err)
+	}
+	os.Setenv("INTERNAL_API_KEY", testAPIKey)
+	rdb = redis.NewClient(&redis.Options{Addr: testRedis.Addr()})
+	loadConfig()
+	testRouter = newRouter()
+	code := m.Run()
+	testRedis.Close()
+	os.Exit(code)
+}
+
+// resetRedis empties Redis for a test
+func resetRedis(t testing.TB) {
+	t.Helper()
+	testRedis.FlushAll()
+}
+
+// setVar overrides a package variable, typically configuration, for the
+// rest of a test
+func setVar[T any](t testing.TB, p *T, v T) {
+	t.Helper()
+	old := *p
+	*p = v
+	t.Cleanup(func() { *p = old })
+}
+
+// request sends body as JSON through the router; headers alternate names
+// and values
+func request(t *testing.T, method, path string, body interface{}, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	var r io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = bytes.NewReader(payload)
+	}
+	req := httptest.NewRequest(method, path, r)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	testRouter.ServeHTTP(w, req)
+	return w
+}
+
+// asRole returns the headers of a trusted caller acting for role
+func asRole(role string) []string {
+	return []string{"X-Api-Key", testAPIKey, "X-Viewer-Role", role}
+}
+
+// trusted are the headers of a trusted caller
+var trusted = []string{"X-Api-Key", testAPIKey}
+
+// decode unmarshals a response body
+func decode(t *testing.T, w *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	var out map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode %q: %v", w.Body.String(), err)
+	}
+	return out
+}
+
+// expectStatus fails the test unless the response has the status
+func expectStatus(t *testing.T, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("status = %d, want %d: %s", w.Code, status, w.Body.String())
+	}
+}
+
+// post posts a comment as a viewer and returns the response
+func post(t *testing.T, streamID int64, viewerID, username, message string, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	return request(t, http.MethodPost, "/post-comment", map[string]interface{}{
+		"stream_id": streamID, "viewer_id": viewerID, "username": username, "message": message,
+	}, headers...)
+}
+
+// poll runs check-update from lastID and returns the decoded response
+func poll(t *testing.T, streamID int64, viewerID string, lastID int64, headers ...string) map[string]interface{} {
+	t.Helper()
+	w := request(t, http.MethodPost, "/check-update", map[string]interface{}{
+		"stream_id": streamID, "viewer_id": viewerID, "last_id": lastID,
+	}, headers...)
+	expectStatus(t, w, 200)
+	return decode(t, w)
+}
+
+// nextSecond waits until check-update can see comments posted so far: it
+// publishes up to the current whole second
+func nextSecond() {
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+}
+
+// messages lists the messages of a decoded response's comments
+func messages(resp map[string]interface{}) []string {
+	list, _ := resp["comments"].([]interface{})
+	out := make([]string, 0, len(list))
+	for _, c := range list {
+		m, _ := c.(map[string]interface{})
+		s, _ := m["message"].(string)
+		out = append(out, s)
+	}
+	return out
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maintenanceMessage = "chat is temporarily read-only for maintenance"
+
+// maintenanceMode is the MAINTENANCE_MODE env switch, always read-only when set
+var maintenanceMode bool
+
+func loadMaintenanceMode() {
+	maintenanceMode = envBool("MAINTENANCE_MODE", false)
+}
+
+// inMaintenance reports whether writes are currently disabled, either by env
+// or by the Redis toggle
+func inMaintenance(ctx context.Context) bool {
+	if maintenanceMode {
+		return true
+	}
+	value, err := rdb.Get(ctx, maintenanceKey()).Result()
+	return err == nil && flagEnabled(value)
+}
+
+// maintenanceMiddleware rejects write requests while in maintenance so
+// viewers can keep watching and reading chat
+func maintenanceMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if inMaintenance(c.Request.Context()) {
+			c.AbortWithStatusJSON(503, gin.H{"error": maintenanceMessage, "reason": "maintenance"})
+			return
+		}
+		c.Next()
+	}
+}
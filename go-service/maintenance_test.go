@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestMaintenanceBlocksWritesNotReads(t *testing.T) {
+	resetRedis(t)
+	id := postedID(t, 1, "v1", "alice", "before maintenance")
+	rdb.Set(ctx, maintenanceKey(), "1", 0)
+
+	w := post(t, 1, "v2", "bob", "during maintenance")
+	expectStatus(t, w, 503)
+	if resp := decode(t, w); resp["reason"] != "maintenance" {
+		t.Fatalf("rejection = %v, want maintenance", resp)
+	}
+	expectStatus(t, request(t, http.MethodPost, "/react", map[string]interface{}{
+		"stream_id": 1, "comment_id": id, "viewer_id": "v2", "reaction": "like",
+	}), 503)
+
+	nextSecond()
+	resp := poll(t, 1, "v2", 0)
+	if got := messages(resp); len(got) != 1 || got[0] != "before maintenance" {
+		t.Fatalf("comments = %v, want the one posted before", got)
+	}
+	if resp["read_only"] != true {
+		t.Fatalf("read_only = %v, want true", resp["read_only"])
+	}
+	heartbeatSession(t, "v2", "")
+	expectStatus(t, request(t, http.MethodPost, "/stream/1/start", nil, trusted...), 200)
+
+	rdb.Del(ctx, maintenanceKey())
+	if resp := poll(t, 1, "v2", 0); resp["read_only"] != nil {
+		t.Fatalf("read_only = %v after maintenance, want it omitted", resp["read_only"])
+	}
+	expectStatus(t, post(t, 1, "v2", "bob", "after maintenance"), 200)
+}
+
+func TestMaintenanceModeFromEnv(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &maintenanceMode, true)
+	expectStatus(t, post(t, 1, "v1", "alice", "hi"), 503)
+	if resp := poll(t, 1, "v1", 0); resp["read_only"] != true {
+		t.Fatalf("read_only = %v, want true", resp["read_only"])
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// memoryStore is a CommentStore held in process memory. It behaves like the
+// Redis store for the core paths, which makes it a stand-in for tests and
+// local development without Redis; it is not shared between replicas.
+type memoryStore struct {
+	mu      sync.Mutex
+	streams map[int64]*memoryStream
+}
+
+type memoryStream struct {
+	scores map[string]int64  // comment ID -> index score (timestamp)
+	data   map[string]string // comment ID -> encoded comment
+	seq    int64
+	online map[string]time.Time // viewer ID -> when they stop counting
+	allow  *bool                // nil = allowed, like an unset flag
+	delay  int                  // chat delay, seconds
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{streams: map[int64]*memoryStream{}}
+}
+
+// stream returns a stream's state for reading: an empty one, which isn't
+// kept, when the stream has none. Callers hold s.mu.
+func (s *memoryStore) stream(streamID int64) *memoryStream {
+	if st, ok := s.streams[streamID]; ok {
+		return st
+	}
+	return &memoryStream{}
+}
+
+// writableStream returns a stream's state, creating it; callers hold s.mu
+func (s *memoryStore) writableStream(streamID int64) *memoryStream {
+	st, ok := s.streams[streamID]
+	if !ok {
+		st = &memoryStream{scores: map[string]int64{}, data: map[string]string{}, online: map[string]time.Time{}}
+		s.streams[streamID] = st
+	}
+	return st
+}
+
+// SetAllowComments sets a stream's allow flag, as the backend does in Redis
+func (s *memoryStore) SetAllowComments(streamID int64, allowed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writableStream(streamID).allow = &allowed
+}
+
+// SetDelay sets a stream's chat delay in seconds
+func (s *memoryStore) SetDelay(streamID int64, seconds int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writableStream(streamID).delay = seconds
+}
+
+func (s *memoryStore) ReadFeed(ctx context.Context, q feedQuery) feedSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.stream(q.StreamID)
+
+	snap := feedSnapshot{AllowComments: st.allow == nil || *st.allow, Online: st.onlineCount(time.Now())}
+	if q.ApplyDelay && st.delay > 0 {
+		snap.Delay = st.delay
+		q.Max -= int64(st.delay) * 1000
+	}
+
+	ids := []string{}
+	beforeID := strconv.FormatInt(q.BeforeID, 10)
+	for id, score := range st.scores {
+		if score < q.Min || score > q.Max {
+			continue
+		}
+		if q.BeforeID > 0 && score == q.Max && !olderID(id, beforeID) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	// Publication order, like the Redis feed read: by score, then ID
+	sort.Slice(ids, func(i, j int) bool {
+		if st.scores[ids[i]] != st.scores[ids[j]] {
+			return st.scores[ids[i]] < st.scores[ids[j]]
+		}
+		return olderID(ids[i], ids[j])
+	})
+	if q.Limit > 0 && len(ids) > q.Limit {
+		if q.Oldest {
+			ids = ids[:q.Limit]
+		} else {
+			ids = ids[len(ids)-q.Limit:]
+		}
+	}
+	snap.IDs = ids
+
+	if snap.AllowComments && len(ids) > 0 {
+		snap.Data = make([]interface{}, len(ids))
+		for i, id := range ids {
+			if d, ok := st.data[id]; ok {
+				snap.Data[i] = d
+			}
+		}
+	}
+	return snap
+}
+
+func (s *memoryStore) AllowComments(ctx context.Context, streamID int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	allow := s.stream(streamID).allow
+	return allow == nil || *allow, nil
+}
+
+func (s *memoryStore) NextCommentSeq(ctx context.Context, streamID int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.writableStream(streamID)
+	st.seq++
+	return st.seq, nil
+}
+
+func (s *memoryStore) SaveComment(ctx context.Context, streamID int64, viewerID string, cmt *Comment, payload []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.writableStream(streamID)
+	id := strconv.FormatInt(cmt.ID, 10)
+	st.scores[id] = cmt.Timestamp
+	st.data[id] = string(payload)
+	return nil
+}
+
+func (s *memoryStore) MarkOnline(ctx context.Context, streamID int64, viewerID string) (bool, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.writableStream(streamID)
+	now := time.Now()
+	until, ok := st.online[viewerID]
+	added := !ok || !until.After(now)
+	st.online[viewerID] = now.Add(presenceTTL)
+	return added, st.onlineCount(now), nil
+}
+
+func (s *memoryStore) MarkOffline(ctx context.Context, streamID int64, viewerID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.stream(streamID)
+	until, ok := st.online[viewerID]
+	delete(st.online, viewerID)
+	return ok && until.After(time.Now()), nil
+}
+
+func (s *memoryStore) OnlineCount(ctx context.Context, streamID int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stream(streamID).onlineCount(time.Now()), nil
+}
+
+// onlineCount drops viewers whose presence ran out and counts the rest
+func (st *memoryStream) onlineCount(now time.Time) int64 {
+	for viewer, until := range st.online {
+		if !until.After(now) {
+			delete(st.online, viewer)
+		}
+	}
+	return int64(len(st.online))
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// withMemoryStore runs the rest of a test on a fresh memory store, with
+// Redis unreachable like in a standalone development setup
+func withMemoryStore(t *testing.T) *memoryStore {
+	t.Helper()
+	s := newMemoryStore()
+	setVar[CommentStore](t, &store, s)
+	down := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	t.Cleanup(func() { down.Close() })
+	setVar(t, &rdb, down)
+	setVar(t, &feedRdb, down)
+	return s
+}
+
+func TestMemoryStorePostAndCheckUpdate(t *testing.T) {
+	withMemoryStore(t)
+
+	expectStatus(t, post(t, 1, "v1", "alice", "hello"), 200)
+	expectStatus(t, post(t, 1, "v2", "bob", "hi there"), 200)
+	expectStatus(t, post(t, 2, "v1", "alice", "other stream"), 200)
+	nextSecond()
+
+	resp := poll(t, 1, "v3", 0)
+	got := messages(resp)
+	if len(got) != 2 || got[0] != "hello" || got[1] != "hi there" {
+		t.Fatalf("comments = %v, want [hello hi there]", got)
+	}
+	if resp["has_updates"] != true {
+		t.Fatalf("has_updates = %v, want true", resp["has_updates"])
+	}
+
+	cursor := int64(resp["cursor"].(float64))
+	if again := poll(t, 1, "v3", cursor); len(messages(again)) != 0 {
+		t.Fatalf("poll from cursor returned %v, want nothing", messages(again))
+	}
+}
+
+func TestMemoryStoreClosedChat(t *testing.T) {
+	s := withMemoryStore(t)
+	expectStatus(t, post(t, 1, "v1", "alice", "before"), 200)
+	nextSecond()
+	s.SetAllowComments(1, false)
+
+	resp := poll(t, 1, "v1", 0)
+	if resp["allow_comments"] == true || resp["has_updates"] == true {
+		t.Fatalf("closed chat reported allow_comments=%v has_updates=%v", resp["allow_comments"], resp["has_updates"])
+	}
+}
+
+func TestMemoryStoreHeartbeat(t *testing.T) {
+	withMemoryStore(t)
+
+	for _, viewer := range []string{"v1", "v2", "v1"} {
+		w := request(t, http.MethodPost, "/heartbeat", map[string]interface{}{"stream_id": 1, "viewer_id": viewer})
+		expectStatus(t, w, 200)
+	}
+	if online, _ := store.OnlineCount(ctx, 1); online != 2 {
+		t.Fatalf("online = %d, want 2", online)
+	}
+	if resp := poll(t, 1, "v1", 0); resp["online"] != float64(2) {
+		t.Fatalf("check-update online = %v, want 2", resp["online"])
+	}
+}
+
+func TestMemoryStoreRejectsPostsToClosedChat(t *testing.T) {
+	s := withMemoryStore(t)
+	s.SetAllowComments(1, false)
+
+	w := post(t, 1, "v1", "alice", "anyone there?")
+	expectStatus(t, w, 403)
+	if reason := decode(t, w)["reason"]; reason != "chat_disabled" {
+		t.Fatalf("reason = %v, want chat_disabled", reason)
+	}
+}
+
+func TestMemoryStoreReadsDontCreateStreams(t *testing.T) {
+	s := withMemoryStore(t)
+
+	poll(t, 7, "v1", 0)
+	store.OnlineCount(ctx, 8)
+	store.AllowComments(ctx, 9)
+	store.MarkOffline(ctx, 10, "v1")
+	if len(s.streams) != 0 {
+		t.Fatalf("reads created %d streams, want none", len(s.streams))
+	}
+
+	expectStatus(t, post(t, 7, "v1", "alice", "hello"), 200)
+	if _, ok := s.streams[7]; !ok || len(s.streams) != 1 {
+		t.Fatalf("streams after a post = %d, want only stream 7", len(s.streams))
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// counter is a monotonically increasing metric exposed on /metrics, one
+// series per label set
+type counter struct {
+	name   string
+	labels string // rendered label set, e.g. `filter="profanity"`
+	help   string
+	value  int64
+}
+
+func (m *counter) Inc()         { atomic.AddInt64(&m.value, 1) }
+func (m *counter) Add(n int64)  { atomic.AddInt64(&m.value, n) }
+func (m *counter) Value() int64 { return atomic.LoadInt64(&m.value) }
+
+func (m *counter) series() string {
+	if m.labels == "" {
+		return m.name
+	}
+	return m.name + "{" + m.labels + "}"
+}
+
+// gauge is a metric that goes up and down, one series per label set
+type gauge struct {
+	name   string
+	labels string // rendered label set, e.g. `endpoint="/check-update"`
+	help   string
+	value  int64
+}
+
+func (m *gauge) Inc()         { atomic.AddInt64(&m.value, 1) }
+func (m *gauge) Dec()         { atomic.AddInt64(&m.value, -1) }
+func (m *gauge) Value() int64 { return atomic.LoadInt64(&m.value) }
+
+func (m *gauge) series() string {
+	if m.labels == "" {
+		return m.name
+	}
+	return m.name + "{" + m.labels + "}"
+}
+
+var (
+	metricsMu sync.Mutex
+	counters  = map[string]*counter{}
+	gauges    = map[string]*gauge{}
+)
+
+// newCounter registers a counter; names follow Prometheus conventions
+func newCounter(name, help string) *counter {
+	return newCounterSeries(name, "", help)
+}
+
+// newCounterSeries registers one series of a labeled counter
+func newCounterSeries(name, labels, help string) *counter {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	m := &counter{name: name, labels: labels, help: help}
+	if existing, ok := counters[m.series()]; ok {
+		return existing
+	}
+	counters[m.series()] = m
+	return m
+}
+
+// newGauge registers one series of a gauge
+func newGauge(name, labels, help string) *gauge {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	m := &gauge{name: name, labels: labels, help: help}
+	if existing, ok := gauges[m.series()]; ok {
+		return existing
+	}
+	gauges[m.series()] = m
+	return m
+}
+
+// metricsHandler writes all metrics in the Prometheus text format
+func metricsHandler(c *gin.Context) {
+	metricsMu.Lock()
+	all := make([]*counter, 0, len(counters))
+	for _, m := range counters {
+		all = append(all, m)
+	}
+	allGauges := make([]*gauge, 0, len(gauges))
+	for _, m := range gauges {
+		allGauges = append(allGauges, m)
+	}
+	metricsMu.Unlock()
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].name != all[j].name {
+			return all[i].name < all[j].name
+		}
+		return all[i].labels < all[j].labels
+	})
+	sort.Slice(allGauges, func(i, j int) bool {
+		if allGauges[i].name != allGauges[j].name {
+			return allGauges[i].name < allGauges[j].name
+		}
+		return allGauges[i].labels < allGauges[j].labels
+	})
+
+	c.Header("Content-Type", "text/plain; version=0.0.4")
+	c.Status(200)
+	for i, m := range all {
+		if i == 0 || all[i-1].name != m.name {
+			fmt.Fprintf(c.Writer, "# HELP %s %s\n# TYPE %s counter\n", m.name, m.help, m.name)
+		}
+		fmt.Fprintf(c.Writer, "%s %d\n", m.series(), m.Value())
+	}
+	for i, m := range allGauges {
+		if i == 0 || allGauges[i-1].name != m.name {
+			fmt.Fprintf(c.Writer, "# HELP %s %s\n# TYPE %s gauge\n", m.name, m.help, m.name)
+		}
+		fmt.Fprintf(c.Writer, "%s %d\n", m.series(), m.Value())
+	}
+}
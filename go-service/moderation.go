@@ -0,0 +1,392 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// Moderation actions (purge, ban, clear) accept dry_run to preview what they
+// would affect. A dry run goes through the same selection as the real action
+// and stops before the first write.
+
+// ModerationTarget names whose comments an action applies to. A viewer_id is
+// resolved to the username it posts under in the stream.
+type ModerationTarget struct {
+	Username string `json:"username"`
+	ViewerID string `json:"viewer_id"`
+}
+
+// resolveTarget fills in the target's username from its viewer ID
+func resolveTarget(ctx context.Context, streamID int64, t *ModerationTarget) error {
+	if t.Username == "" && t.ViewerID == "" {
+		return fmt.Errorf("username or viewer_id is required")
+	}
+	if t.Username == "" {
+		name, err := rdb.HGet(ctx, viewerNamesKey(streamID), t.ViewerID).Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		t.Username = name
+	}
+	return nil
+}
+
+// selectAuthorComments returns the IDs of a user's comments that still exist,
+// optionally only those posted within window
+func selectAuthorComments(ctx context.Context, streamID int64, username string, window time.Duration) ([]string, error) {
+	if username == "" {
+		return nil, nil
+	}
+	min := "-inf"
+	if window > 0 {
+		min = strconv.FormatInt(time.Now().Add(-window).UnixMilli(), 10)
+	}
+	ids, err := rdb.ZRangeByScore(ctx, authorIndexKey(streamID, username), &redis.ZRangeBy{Min: min, Max: "+inf"}).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	data, err := rdb.HMGet(ctx, commentDataKey(streamID), ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+	present := ids[:0]
+	for i, id := range ids {
+		if data[i] != nil {
+			present = append(present, id)
+		}
+	}
+	return present, nil
+}
+
+// deleteComments removes comments from the feed and every ranking, for
+// housekeeping (expiry, eviction, repairs). History indexes keep them, so
+// their authors see them as expired.
+func deleteComments(ctx context.Context, streamID int64, ids []string) error {
+	return removeComments(ctx, streamID, ids, false)
+}
+
+// moderateComments is deleteComments on a moderator's behalf: the removal is
+// recorded so the authors' history says moderation removed them
+func moderateComments(ctx context.Context, streamID int64, ids []string) error {
+	return removeComments(ctx, streamID, ids, true)
+}
+
+func removeComments(ctx context.Context, streamID int64, ids []string, moderated bool) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	members := make([]interface{}, len(ids))
+	expiryMembers := make([]interface{}, len(ids))
+	for i, id := range ids {
+		members[i] = id
+		expiryMembers[i] = fmt.Sprintf("%d:%s", streamID, id)
+	}
+	_, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, commentIndexKey(streamID), members...)
+		pipe.ZRem(ctx, priorityKey(streamID), members...)
+		pipe.HDel(ctx, commentDataKey(streamID), ids...)
+		pipe.ZRem(ctx, expiryKey(), expiryMembers...)
+		pipe.ZRem(ctx, reactionLeaderboardKey(streamID), members...)
+		pipe.ZRem(ctx, engagementKey(streamID), members...)
+		invalidateReplayTimelines(ctx, pipe, streamID)
+		if moderated {
+			recordRemovals(ctx, pipe, streamID, ids)
+		}
+		return nil
+	})
+	return err
+}
+
+// parseStreamID reads the :id path parameter
+func parseStreamID(c *gin.Context) (int64, bool) {
+	streamID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid stream id"})
+		return 0, false
+	}
+	return streamID, true
+}
+
+type PurgeRequest struct {
+	ModerationTarget
+	Window int64 `json:"window" binding:"min=0"` // seconds back from now, 0 = all
+	DryRun bool  `json:"dry_run"`
+}
+
+// purgeComments removes a user's comments from a stream
+func purgeComments(c *gin.Context) {
+	streamID, ok := parseStreamID(c)
+	if !ok {
+		return
+	}
+	var req PurgeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	reqCtx := c.Request.Context()
+	if err := resolveTarget(reqCtx, streamID, &req.ModerationTarget); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	ids, err := selectAuthorComments(reqCtx, streamID, req.Username, time.Duration(req.Window)*time.Second)
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error selecting comments to purge: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to purge comments"})
+		return
+	}
+	if !req.DryRun {
+		if err := moderateComments(reqCtx, streamID, ids); err != nil {
+			log.Printf("[GO] Stream %d: Error purging comments: %v", streamID, err)
+			c.JSON(500, gin.H{"error": "failed to purge comments"})
+			return
+		}
+		log.Printf("[GO] Stream %d: Purged %d comments by %s", streamID, len(ids), req.Username)
+		auditRequest(c, streamID, "purge", &req.ModerationTarget, "", map[string]interface{}{"window": req.Window, "comment_ids": ids})
+	}
+	c.JSON(200, gin.H{"success": true, "dry_run": req.DryRun, "username": req.Username, "affected": len(ids), "comment_ids": ids})
+}
+
+type BanRequest struct {
+	ModerationTarget
+	Duration int64  `json:"duration" binding:"min=0"` // seconds, 0 = permanent
+	Purge    bool   `json:"purge"`                    // also remove their comments
+	Reason   string `json:"reason" binding:"max=200"`
+	DryRun   bool   `json:"dry_run"`
+	// Shadow lets them keep posting, but nobody else sees their comments
+	Shadow bool `json:"shadow"`
+}
+
+// banMembers are the ban hash fields covering a target
+func banMembers(t ModerationTarget) []string {
+	var members []string
+	if t.Username != "" {
+		members = append(members, "user:"+strings.ToLower(t.Username))
+	}
+	if t.ViewerID != "" {
+		members = append(members, "viewer:"+t.ViewerID)
+	}
+	return members
+}
+
+// storeBan bans a target until the given time (ms, 0 = permanent) and
+// counts the ban against their reputation
+func storeBan(ctx context.Context, streamID int64, t ModerationTarget, until int64, shadow bool) error {
+	fields := map[string]interface{}{}
+	for _, member := range banMembers(t) {
+		fields[member] = until
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	banKey := bansKey(streamID)
+	if shadow {
+		banKey = shadowBansKey(streamID)
+	}
+	if err := rdb.HSet(ctx, banKey, fields).Err(); err != nil {
+		return err
+	}
+	bumpReputation(ctx, streamID, t.Username, repBans, 1)
+	return nil
+}
+
+// isBanned reports whether a viewer or username is banned from a stream,
+// clearing ban entries that have run out
+func isBanned(ctx context.Context, streamID int64, viewerID, username string) bool {
+	return checkBan(ctx, streamID, bansKey(streamID), viewerID, username)
+}
+
+// isShadowBanned is isBanned for shadow bans
+func isShadowBanned(ctx context.Context, streamID int64, viewerID, username string) bool {
+	return checkBan(ctx, streamID, shadowBansKey(streamID), viewerID, username)
+}
+
+// checkBan lets posts through when the bans can't be read, so an outage
+// doesn't silence the chat
+func checkBan(ctx context.Context, streamID int64, banKey, viewerID, username string) bool {
+	banned, err := lookupBan(ctx, banKey, viewerID, username)
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error checking bans: %v", streamID, err)
+		return false
+	}
+	return banned
+}
+
+// lookupBan reports whether banKey holds a live ban on the viewer or
+// username, clearing entries that have run out
+func lookupBan(ctx context.Context, banKey, viewerID, username string) (bool, error) {
+	members := banMembers(ModerationTarget{Username: username, ViewerID: viewerID})
+	if len(members) == 0 {
+		return false, nil
+	}
+	vals, err := rdb.HMGet(ctx, banKey, members...).Result()
+	if err != nil {
+		return false, err
+	}
+	now := time.Now().UnixMilli()
+	for i, v := range vals {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		until, _ := strconv.ParseInt(s, 10, 64)
+		if until == 0 || until > now {
+			return true, nil
+		}
+		rdb.HDel(ctx, banKey, members[i])
+	}
+	return false, nil
+}
+
+// banViewer bans a user from posting in a stream, optionally purging their
+// comments
+func banViewer(c *gin.Context) {
+	streamID, ok := parseStreamID(c)
+	if !ok {
+		return
+	}
+	var req BanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	reqCtx := c.Request.Context()
+	if err := resolveTarget(reqCtx, streamID, &req.ModerationTarget); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	var ids []string
+	if req.Purge {
+		var err error
+		ids, err = selectAuthorComments(reqCtx, streamID, req.Username, 0)
+		if err != nil {
+			log.Printf("[GO] Stream %d: Error selecting comments to purge: %v", streamID, err)
+			c.JSON(500, gin.H{"error": "failed to ban"})
+			return
+		}
+	}
+	var until int64
+	if req.Duration > 0 {
+		until = time.Now().Add(time.Duration(req.Duration) * time.Second).UnixMilli()
+	}
+	resp := gin.H{"success": true, "dry_run": req.DryRun, "username": req.Username, "until": until, "shadow": req.Shadow, "affected": len(ids), "comment_ids": ids}
+	if req.DryRun {
+		c.JSON(200, resp)
+		return
+	}
+
+	if err := storeBan(reqCtx, streamID, req.ModerationTarget, until, req.Shadow); err != nil {
+		log.Printf("[GO] Stream %d: Error storing ban: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to ban"})
+		return
+	}
+	if err := moderateComments(reqCtx, streamID, ids); err != nil {
+		log.Printf("[GO] Stream %d: Error purging comments: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to purge comments"})
+		return
+	}
+
+	action := "ban"
+	if req.Shadow {
+		action = "shadow_ban"
+	}
+	log.Printf("[GO] Stream %d: Banned %s (shadow: %t, %d comments purged)", streamID, req.Username, req.Shadow, len(ids))
+	auditRequest(c, streamID, action, &req.ModerationTarget, req.Reason, map[string]interface{}{"until": until, "comment_ids": ids})
+	// Announcing a shadow ban would give it away
+	if req.Username != "" && !req.Shadow {
+		announce(reqCtx, streamID, "%s was banned", req.Username)
+	}
+	c.JSON(200, resp)
+}
+
+// unbanViewer lifts a ban, shadow or not
+func unbanViewer(c *gin.Context) {
+	streamID, ok := parseStreamID(c)
+	if !ok {
+		return
+	}
+	var req ModerationTarget
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	members := banMembers(req)
+	if len(members) == 0 {
+		c.JSON(400, gin.H{"error": "username or viewer_id is required"})
+		return
+	}
+	reqCtx := c.Request.Context()
+	var bans, shadowBans *redis.IntCmd
+	_, err := rdb.Pipelined(reqCtx, func(pipe redis.Pipeliner) error {
+		bans = pipe.HDel(reqCtx, bansKey(streamID), members...)
+		shadowBans = pipe.HDel(reqCtx, shadowBansKey(streamID), members...)
+		return nil
+	})
+	removed := bans.Val() + shadowBans.Val()
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error removing ban: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to unban"})
+		return
+	}
+	if removed > 0 {
+		auditRequest(c, streamID, "unban", &req, "", nil)
+	}
+	c.JSON(200, gin.H{"success": true, "unbanned": removed > 0})
+}
+
+type ClearRequest struct {
+	DryRun bool `json:"dry_run"`
+}
+
+// clearChat removes every comment in a stream
+func clearChat(c *gin.Context) {
+	streamID, ok := parseStreamID(c)
+	if !ok {
+		return
+	}
+	var req ClearRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// The selection is the whole index
+	reqCtx := c.Request.Context()
+	affected, err := rdb.ZCard(reqCtx, commentIndexKey(streamID)).Result()
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error counting comments: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to clear chat"})
+		return
+	}
+	if !req.DryRun {
+		// Expiry entries for the removed comments are dropped by the sweeper
+		err = rdb.Del(reqCtx,
+			commentIndexKey(streamID),
+			priorityKey(streamID),
+			commentDataKey(streamID),
+			reactionLeaderboardKey(streamID),
+			engagementKey(streamID),
+			replayTimelineKey(streamID),
+		).Err()
+		if err != nil {
+			log.Printf("[GO] Stream %d: Error clearing chat: %v", streamID, err)
+			c.JSON(500, gin.H{"error": "failed to clear chat"})
+			return
+		}
+		log.Printf("[GO] Stream %d: Chat cleared (%d comments)", streamID, affected)
+		auditRequest(c, streamID, "clear", nil, "", map[string]interface{}{"affected": affected})
+		announce(reqCtx, streamID, "Chat was cleared by a moderator")
+	}
+	c.JSON(200, gin.H{"success": true, "dry_run": req.DryRun, "affected": affected})
+}
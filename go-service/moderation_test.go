@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// moderate runs a moderation action on stream 1 and returns its response
+func moderate(t *testing.T, action string, body map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	w := request(t, http.MethodPost, "/stream/1/"+action, body, asRole(roleModerator)...)
+	expectStatus(t, w, 200)
+	return decode(t, w)
+}
+
+func TestModerationDryRunDoesNotMutate(t *testing.T) {
+	resetRedis(t)
+	first := postedID(t, 1, "v1", "alice", "one")
+	second := postedID(t, 1, "v1", "alice", "two")
+	postedID(t, 1, "v2", "bob", "three")
+	want := fmt.Sprint([]int64{first, second})
+
+	before := testRedis.Dump()
+	for _, tc := range []struct {
+		action string
+		body   map[string]interface{}
+	}{
+		{"purge", map[string]interface{}{"username": "alice", "dry_run": true}},
+		{"ban", map[string]interface{}{"username": "alice", "purge": true, "dry_run": true}},
+	} {
+		resp := moderate(t, tc.action, tc.body)
+		if resp["dry_run"] != true || resp["affected"] != float64(2) || fmt.Sprint(resp["comment_ids"]) != want {
+			t.Fatalf("%s dry run = %v, want alice's two comments", tc.action, resp)
+		}
+	}
+	if resp := moderate(t, "clear", map[string]interface{}{"dry_run": true}); resp["affected"] != float64(3) {
+		t.Fatalf("clear dry run = %v, want 3 affected", resp)
+	}
+	if after := testRedis.Dump(); after != before {
+		t.Fatalf("dry runs changed Redis:\nbefore %s\nafter %s", before, after)
+	}
+	if isBanned(ctx, 1, "v1", "alice") {
+		t.Fatal("a dry-run ban banned alice")
+	}
+
+	// The real actions select the same comments
+	if resp := moderate(t, "purge", map[string]interface{}{"username": "alice"}); resp["dry_run"] != false || fmt.Sprint(resp["comment_ids"]) != want {
+		t.Fatalf("purge = %v, want alice's two comments", resp)
+	}
+	if resp := moderate(t, "clear", nil); resp["affected"] != float64(1) {
+		t.Fatalf("clear = %v, want the remaining comment", resp)
+	}
+}
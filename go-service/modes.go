@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StreamModes are the chat mode flags a stream's moderators can toggle.
+// They live in the stream:modes:<stream_id> hash, written by the backend.
+type StreamModes struct {
+	EmoteOnly bool `json:"emote_only"`
+	SlowMode  int  `json:"slow_mode"` // seconds between comments per viewer, 0 = off
+
+	// Flood guard settings, defaulting to FLOOD_CAP and FLOOD_WINDOW
+	FloodCap    int           `json:"flood_cap"`
+	FloodWindow time.Duration `json:"flood_window"`
+
+	// NewViewerWait is how long viewers must watch before posting
+	NewViewerWait time.Duration `json:"new_viewer_wait"`
+
+	// ProfanityActions maps each profanity tier to its action
+	ProfanityActions map[string]string `json:"profanity_actions"`
+
+	// Sampling thins the feed above this many comments/s, 0 = off
+	SamplingThreshold float64 `json:"sampling_threshold"`
+	SamplingStrategy  string  `json:"sampling_strategy"`
+
+	// LinkRepeatThreshold blocks a link once this many viewers posted it
+	// within the repeat window, 0 = off
+	LinkRepeatThreshold int `json:"link_repeat_threshold"`
+
+	// PollMinInterval is the shortest time allowed between one viewer's
+	// polls before load scaling, defaulting to POLL_MIN_INTERVAL_MS
+	PollMinInterval time.Duration `json:"poll_min_interval"`
+
+	// DedupWindow collapses identical messages posted within it into one
+	// comment, defaulting to DEDUP_WINDOW; 0 = off
+	DedupWindow time.Duration `json:"dedup_window"`
+
+	// CommentQuota caps comments per viewer per broadcast, defaulting to
+	// COMMENT_QUOTA; 0 = no quota
+	CommentQuota int `json:"comment_quota"`
+
+	// SimilarityThreshold refuses messages this similar to a viewer's recent
+	// ones, defaulting to SIMILARITY_THRESHOLD; 0 = off
+	SimilarityThreshold float64 `json:"similarity_threshold"`
+
+	// VisibleTier gates comments posted while it is set to subscribers of
+	// that tier and above
+	VisibleTier string `json:"visible_tier"`
+
+	// Scripts restricts which writing systems may appear in comments
+	Scripts scriptPolicy `json:"-"`
+
+	// Emoji limits the emoji density of comments
+	Emoji emojiPolicy `json:"-"`
+
+	// Drip paces how fast new comments are revealed
+	Drip dripPolicy `json:"-"`
+
+	// SeenCounts reports how many viewers have seen recent comments
+	SeenCounts bool `json:"seen_counts"`
+}
+
+// loadStreamModes reads a stream's mode flags; missing fields are off
+func loadStreamModes(ctx context.Context, streamID int64) (StreamModes, error) {
+	fields, err := rdb.HGetAll(ctx, modesKey(streamID)).Result()
+	if err != nil {
+		return defaultStreamModes(), err
+	}
+	return parseStreamModes(ctx, streamID, fields), nil
+}
+
+// defaultStreamModes are the modes of a stream that set none
+func defaultStreamModes() StreamModes {
+	return StreamModes{FloodCap: floodCap, FloodWindow: floodWindow, NewViewerWait: newViewerWait, Scripts: defaultScriptPolicy,
+		SamplingThreshold: samplingThreshold, SamplingStrategy: samplingStrategy, LinkRepeatThreshold: linkRepeatThreshold,
+		PollMinInterval: pollMinInterval, DedupWindow: dedupWindow, CommentQuota: commentQuota,
+		SimilarityThreshold: similarityThreshold,
+		Emoji:               defaultEmojiPolicy, Drip: dripPolicy{Interval: dripInterval}}
+}
+
+// parseStreamModes reads the modes hash of a stream over the defaults
+func parseStreamModes(ctx context.Context, streamID int64, fields map[string]string) StreamModes {
+	modes := defaultStreamModes()
+	modes.EmoteOnly = flagEnabled(fields["emote_only"])
+	modes.SeenCounts = flagEnabled(fields["seen_counts"])
+	if v, convErr := strconv.Atoi(fields["slow_mode"]); convErr == nil && v > 0 {
+		modes.SlowMode = v
+	}
+	if v, convErr := strconv.Atoi(fields["flood_cap"]); convErr == nil && v >= 0 {
+		modes.FloodCap = v
+	}
+	if v, convErr := strconv.Atoi(fields["flood_window"]); convErr == nil && v > 0 {
+		modes.FloodWindow = time.Duration(v) * time.Second
+	}
+	if v, convErr := strconv.Atoi(fields["new_viewer_wait"]); convErr == nil && v >= 0 {
+		modes.NewViewerWait = time.Duration(v) * time.Second
+	}
+	if v, convErr := strconv.ParseFloat(fields["sampling_threshold"], 64); convErr == nil && v >= 0 {
+		modes.SamplingThreshold = v
+	}
+	if v := fields["sampling_strategy"]; v != "" {
+		modes.SamplingStrategy = parseSamplingStrategy(v)
+	}
+	if v, convErr := strconv.Atoi(fields["link_repeat_threshold"]); convErr == nil && v >= 0 {
+		modes.LinkRepeatThreshold = v
+	}
+	if v, convErr := strconv.Atoi(fields["poll_min_interval_ms"]); convErr == nil && v >= 0 {
+		modes.PollMinInterval = time.Duration(v) * time.Millisecond
+	}
+	if v, convErr := strconv.Atoi(fields["dedup_window"]); convErr == nil && v >= 0 {
+		modes.DedupWindow = time.Duration(v) * time.Second
+	}
+	if v, convErr := strconv.Atoi(fields["comment_quota"]); convErr == nil && v >= 0 {
+		modes.CommentQuota = v
+	}
+	if v, convErr := strconv.ParseFloat(fields["similarity_threshold"], 64); convErr == nil && v >= 0 && v <= 1 {
+		modes.SimilarityThreshold = v
+	}
+	if v := strings.ToLower(strings.TrimSpace(fields["visible_tier"])); v != "" {
+		modes.VisibleTier = v
+	}
+	modes.ProfanityActions = parseProfanityActions(fields)
+	modes.Scripts = parseScriptPolicy(fields)
+	modes.Emoji = parseEmojiPolicy(fields)
+	modes.Drip = parseDripPolicy(fields)
+	return modes
+}
+
+// flagEnabled matches the "1"/"true" convention used for Redis flags
+func flagEnabled(value string) bool {
+	return value == "1" || value == "true"
+}
+
+// takeSlowModeSlot claims the viewer's next posting slot. When the viewer is
+// still cooling down it returns false and the seconds left until they can post.
+func takeSlowModeSlot(ctx context.Context, streamID int64, viewer string, seconds int) (bool, int, error) {
+	key := slowModeKey(streamID, viewer)
+	ok, err := rdb.SetNX(ctx, key, 1, time.Duration(seconds)*time.Second).Result()
+	if err != nil || ok {
+		return true, 0, err
+	}
+	remaining, err := slowModeCooldown(ctx, streamID, viewer)
+	return false, remaining, err
+}
+
+// slowModeCooldown returns the whole seconds (rounded up) until the viewer
+// may post again, derived from the TTL of their slow-mode key.
+func slowModeCooldown(ctx context.Context, streamID int64, viewer string) (int, error) {
+	ttl, err := rdb.PTTL(ctx, slowModeKey(streamID, viewer)).Result()
+	if err != nil || ttl <= 0 {
+		return 0, err
+	}
+	return int((ttl + time.Second - 1) / time.Second), nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlowModeCountdownMatchesTTL(t *testing.T) {
+	resetRedis(t)
+	rdb.HSet(ctx, modesKey(1), "slow_mode", "30")
+
+	if resp := poll(t, 1, "v1", 0); resp["slow_mode"] != float64(30) || resp["cooldown"] != nil {
+		t.Fatalf("before posting: slow_mode %v cooldown %v, want 30 and none", resp["slow_mode"], resp["cooldown"])
+	}
+	expectStatus(t, post(t, 1, "v1", "alice", "first"), 200)
+	if resp := poll(t, 1, "v1", 0); resp["cooldown"] != float64(30) {
+		t.Fatalf("cooldown after posting = %v, want 30", resp["cooldown"])
+	}
+
+	testRedis.FastForward(12 * time.Second)
+	ttl := testRedis.TTL(slowModeKey(1, "v1"))
+	if resp := poll(t, 1, "v1", 0); resp["cooldown"] != float64(ttl/time.Second) || ttl != 18*time.Second {
+		t.Fatalf("cooldown = %v with %v left, want 18", resp["cooldown"], ttl)
+	}
+	w := post(t, 1, "v1", "alice", "second")
+	expectStatus(t, w, 429)
+	if resp := decode(t, w); resp["reason"] != "slow_mode" || resp["retry_after"] != float64(18) {
+		t.Fatalf("rejection = %v, want slow_mode retry_after 18", resp)
+	}
+	if resp := poll(t, 1, "v2", 0); resp["cooldown"] != nil {
+		t.Fatalf("another viewer's cooldown = %v, want none", resp["cooldown"])
+	}
+
+	testRedis.FastForward(18 * time.Second)
+	if resp := poll(t, 1, "v1", 0); resp["cooldown"] != nil {
+		t.Fatalf("cooldown after the TTL = %v, want none", resp["cooldown"])
+	}
+	expectStatus(t, post(t, 1, "v1", "alice", "third"), 200)
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Chat can run stricter while nobody is there to moderate it and looser
+// while moderators are at work. Moderators count as present while their
+// heartbeats (sent with a privileged role) keep coming, and as active for
+// MOD_ACTIVE_WINDOW seconds after their last moderation action. A stream is
+// then in one of three profiles:
+//
+//   - unmoderated: no moderator online; MOD_ABSENT_PROFILE applies
+//   - moderated:   a moderator online; the stream's own modes apply
+//   - active:      a moderator online and acting; MOD_ACTIVE_PROFILE applies
+//
+// Profiles are "key=value" lists over slow_mode (seconds), flood_cap,
+// new_viewer_wait (seconds) and similarity (threshold). The absent profile
+// only ever tightens a stream's modes and the active one only relaxes them,
+// so a value on the wrong side of the stream's own setting is ignored.
+// check-update reports the profile while either is set.
+const (
+	modProfileUnmoderated = "unmoderated"
+	modProfileModerated   = "moderated"
+	modProfileActive      = "active"
+)
+
+// modProfile overrides some of a stream's modes; -1 leaves a mode alone
+type modProfile struct {
+	SlowMode      int
+	FloodCap      int
+	NewViewerWait time.Duration
+	Similarity    float64
+}
+
+var (
+	modAbsentProfile modProfile
+	modActiveProfile modProfile
+	modActiveWindow  time.Duration
+)
+
+func loadModPresenceConfig() {
+	modAbsentProfile = parseModProfile(envString("MOD_ABSENT_PROFILE", ""))
+	modActiveProfile = parseModProfile(envString("MOD_ACTIVE_PROFILE", ""))
+	modActiveWindow = time.Duration(envInt("MOD_ACTIVE_WINDOW", 300)) * time.Second
+	if modActiveWindow <= 0 {
+		modActiveWindow = 5 * time.Minute
+	}
+}
+
+// parseModProfile reads a profile such as "slow_mode=10,flood_cap=20"
+func parseModProfile(spec string) modProfile {
+	p := modProfile{SlowMode: -1, FloodCap: -1, NewViewerWait: -1, Similarity: -1}
+	for _, part := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		switch name {
+		case "slow_mode":
+			if v, err := strconv.Atoi(value); err == nil && v >= 0 {
+				p.SlowMode = v
+			}
+		case "flood_cap":
+			if v, err := strconv.Atoi(value); err == nil && v >= 0 {
+				p.FloodCap = v
+			}
+		case "new_viewer_wait":
+			if v, err := strconv.Atoi(value); err == nil && v >= 0 {
+				p.NewViewerWait = time.Duration(v) * time.Second
+			}
+		case "similarity":
+			if v, err := strconv.ParseFloat(value, 64); err == nil && v >= 0 && v <= 1 {
+				p.Similarity = v
+			}
+		default:
+			log.Printf("[GO] Warning: unknown moderator profile setting %q", name)
+		}
+	}
+	return p
+}
+
+// isSet reports whether the profile changes anything
+func (p modProfile) isSet() bool {
+	return p.SlowMode >= 0 || p.FloodCap >= 0 || p.NewViewerWait >= 0 || p.Similarity >= 0
+}
+
+// modProfilesEnabled reports whether moderator presence changes anything
+func modProfilesEnabled() bool {
+	return modAbsentProfile.isSet() || modActiveProfile.isSet()
+}
+
+// stricterCap compares limits where 0 means off: the lower non-zero one wins
+func stricterCap(a, b float64) bool {
+	return b > 0 && (a == 0 || b < a)
+}
+
+// tighten applies the profile's settings that are stricter than modes'
+func (p modProfile) tighten(modes StreamModes) StreamModes {
+	if p.SlowMode > modes.SlowMode {
+		modes.SlowMode = p.SlowMode
+	}
+	if p.FloodCap >= 0 && stricterCap(float64(modes.FloodCap), float64(p.FloodCap)) {
+		modes.FloodCap = p.FloodCap
+	}
+	if p.NewViewerWait > modes.NewViewerWait {
+		modes.NewViewerWait = p.NewViewerWait
+	}
+	if p.Similarity >= 0 && stricterCap(modes.SimilarityThreshold, p.Similarity) {
+		modes.SimilarityThreshold = p.Similarity
+	}
+	return modes
+}
+
+// relax applies the profile's settings that are looser than modes'
+func (p modProfile) relax(modes StreamModes) StreamModes {
+	if p.SlowMode >= 0 && p.SlowMode < modes.SlowMode {
+		modes.SlowMode = p.SlowMode
+	}
+	if p.FloodCap >= 0 && stricterCap(float64(p.FloodCap), float64(modes.FloodCap)) {
+		modes.FloodCap = p.FloodCap
+	}
+	if p.NewViewerWait >= 0 && p.NewViewerWait < modes.NewViewerWait {
+		modes.NewViewerWait = p.NewViewerWait
+	}
+	if p.Similarity >= 0 && stricterCap(p.Similarity, modes.SimilarityThreshold) {
+		modes.SimilarityThreshold = p.Similarity
+	}
+	return modes
+}
+
+// applyModProfile adjusts a stream's modes for its moderator profile
+func applyModProfile(modes StreamModes, profile string) StreamModes {
+	switch profile {
+	case modProfileUnmoderated:
+		return modAbsentProfile.tighten(modes)
+	case modProfileActive:
+		return modActiveProfile.relax(modes)
+	}
+	return modes
+}
+
+// recordModeratorPresence counts a moderator's heartbeat
+func recordModeratorPresence(ctx context.Context, streamID int64, viewerID string) {
+	now := time.Now()
+	k := moderatorsOnlineKey(streamID)
+	_, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, k, &redis.Z{Score: float64(now.UnixMilli()), Member: viewerID})
+		pipe.ZRemRangeByScore(ctx, k, "-inf", strconv.FormatInt(now.Add(-presenceTTL).UnixMilli(), 10))
+		pipe.Expire(ctx, k, presenceTTL)
+		return nil
+	})
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error recording moderator presence: %v", streamID, err)
+	}
+}
+
+// recordModeratorAction marks a stream's moderators as active
+func recordModeratorAction(ctx context.Context, streamID int64) {
+	if !modProfilesEnabled() {
+		return
+	}
+	if err := rdb.Set(ctx, moderatorActiveKey(streamID), time.Now().UnixMilli(), modActiveWindow).Err(); err != nil {
+		log.Printf("[GO] Stream %d: Error recording moderator activity: %v", streamID, err)
+	}
+}
+
+// currentModProfile returns a stream's moderator profile, "" when profiles
+// are off. A lookup that fails reads as moderated, leaving modes alone.
+func currentModProfile(ctx context.Context, streamID int64) string {
+	if !modProfilesEnabled() {
+		return ""
+	}
+	since := modPresenceSince()
+	var online *redis.IntCmd
+	var active *redis.IntCmd
+	_, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		online = pipe.ZCount(ctx, moderatorsOnlineKey(streamID), since, "+inf")
+		active = pipe.Exists(ctx, moderatorActiveKey(streamID))
+		return nil
+	})
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error checking moderator presence: %v", streamID, err)
+		return modProfileModerated
+	}
+	return modProfileFor(online.Val(), active.Val())
+}
+
+// modProfileFor picks the profile for the moderators online and whether one
+// was recently active
+func modProfileFor(online, active int64) string {
+	switch {
+	case online == 0:
+		return modProfileUnmoderated
+	case active > 0:
+		return modProfileActive
+	}
+	return modProfileModerated
+}
+
+// modPresenceSince is the oldest moderator heartbeat that still counts
+func modPresenceSince() string {
+	return strconv.FormatInt(time.Now().Add(-presenceTTL).UnixMilli(), 10)
+}
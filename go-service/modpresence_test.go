@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseModProfile(t *testing.T) {
+	p := parseModProfile("slow_mode=10, flood_cap=20,new_viewer_wait=30,similarity=0.8,bogus=1")
+	if p.SlowMode != 10 || p.FloodCap != 20 || p.NewViewerWait != 30*time.Second || p.Similarity != 0.8 {
+		t.Fatalf("profile = %+v", p)
+	}
+	if p := parseModProfile(""); p.isSet() {
+		t.Fatalf("empty profile = %+v, want nothing set", p)
+	}
+}
+
+func TestModProfilesOnlyTightenOrRelax(t *testing.T) {
+	modes := StreamModes{SlowMode: 5, FloodCap: 20}
+	strict := parseModProfile("slow_mode=10,flood_cap=50")
+	if got := strict.tighten(modes); got.SlowMode != 10 || got.FloodCap != 20 {
+		t.Fatalf("tightened = slow %d flood %d, want 10 and the stream's stricter 20", got.SlowMode, got.FloodCap)
+	}
+	loose := parseModProfile("slow_mode=10,flood_cap=0")
+	if got := loose.relax(modes); got.SlowMode != 5 || got.FloodCap != 0 {
+		t.Fatalf("relaxed = slow %d flood %d, want the stream's looser 5 and no flood cap", got.SlowMode, got.FloodCap)
+	}
+}
+
+// modStatus polls stream 1 and returns its moderator profile and slow mode
+func modStatus(t *testing.T) (interface{}, interface{}) {
+	t.Helper()
+	resp := poll(t, 1, "v1", 1)
+	return resp["mod_profile"], resp["slow_mode"]
+}
+
+func TestModPresenceTogglesProfile(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &modAbsentProfile, parseModProfile("slow_mode=30"))
+	setVar(t, &modActiveProfile, parseModProfile("slow_mode=0"))
+	rdb.HSet(ctx, modesKey(1), "slow_mode", "5")
+
+	if profile, slow := modStatus(t); profile != modProfileUnmoderated || slow != float64(30) {
+		t.Fatalf("without moderators: %v slow %v, want unmoderated at 30", profile, slow)
+	}
+	expectStatus(t, post(t, 1, "v2", "bob", "first"), 200)
+	if w := post(t, 1, "v2", "bob", "second"); w.Code != 429 {
+		t.Fatalf("second post while unmoderated: %d, want the tighter slow mode", w.Code)
+	}
+
+	// A moderator's heartbeat brings the stream's own modes back
+	expectStatus(t, request(t, http.MethodPost, "/heartbeat", map[string]interface{}{"stream_id": 1, "viewer_id": "mod-7"}, asModerator...), 200)
+	if profile, slow := modStatus(t); profile != modProfileModerated || slow != float64(5) {
+		t.Fatalf("with a moderator online: %v slow %v, want moderated at 5", profile, slow)
+	}
+
+	// and acting relaxes them
+	expectStatus(t, request(t, http.MethodPost, "/stream/1/timezone", map[string]interface{}{"timezone": "UTC"}, asModerator...), 200)
+	if profile, slow := modStatus(t); profile != modProfileActive || slow != nil {
+		t.Fatalf("with a moderator acting: %v slow %v, want active without slow mode", profile, slow)
+	}
+	for _, msg := range []string{"one", "two"} {
+		expectStatus(t, post(t, 1, "v3", "carol", msg), 200)
+	}
+
+	if ttl := testRedis.TTL(moderatorActiveKey(1)); ttl != modActiveWindow {
+		t.Fatalf("active marker TTL = %v, want %v", ttl, modActiveWindow)
+	}
+	// Fast-forwarding past the window would expire the presence set too, so
+	// the window is ended directly
+	rdb.Del(ctx, moderatorActiveKey(1))
+	if profile, _ := modStatus(t); profile != modProfileModerated {
+		t.Fatalf("after the action window: %v, want moderated", profile)
+	}
+	rdb.Del(ctx, moderatorsOnlineKey(1))
+	if profile, _ := modStatus(t); profile != modProfileUnmoderated {
+		t.Fatalf("after the moderator left: %v, want unmoderated", profile)
+	}
+}
+
+func TestModProfilesOff(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &modAbsentProfile, parseModProfile(""))
+	setVar(t, &modActiveProfile, parseModProfile(""))
+	if profile, _ := modStatus(t); profile != nil {
+		t.Fatalf("mod_profile = %v with no profiles set, want none", profile)
+	}
+}
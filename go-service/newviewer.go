@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// New viewers must have been watching for a while before they can post,
+// which stops drive-by spam from fresh viewer IDs. The wait is measured from
+// the viewer's first heartbeat of their current visit; if they stop
+// heartbeating for presenceTTL the visit ends and the wait starts over.
+// NEW_VIEWER_WAIT sets the default (seconds, 0 = off) and streams override it
+// with new_viewer_wait in their modes.
+var newViewerWait time.Duration
+
+func loadNewViewerConfig() {
+	newViewerWait = time.Duration(envInt("NEW_VIEWER_WAIT", 0)) * time.Second
+}
+
+// newViewerRemaining returns how long a viewer has to keep watching before
+// they can post, 0 if they can post now. Viewers without a viewer_id have no
+// presence, so they always wait the full time.
+func newViewerRemaining(ctx context.Context, streamID int64, viewerID string, wait time.Duration) time.Duration {
+	if wait <= 0 {
+		return 0
+	}
+	if viewerID == "" {
+		return wait
+	}
+	firstSeen, err := rdb.Get(ctx, firstSeenKey(streamID, viewerID)).Int64()
+	if err != nil {
+		return wait
+	}
+	remaining := wait - time.Since(time.UnixMilli(firstSeen))
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNewViewerWait(t *testing.T) {
+	resetRedis(t)
+	rdb.HSet(ctx, modesKey(1), "new_viewer_wait", "60")
+	heartbeatSession(t, "fresh", "")
+	heartbeatSession(t, "eligible", "")
+	rdb.Set(ctx, firstSeenKey(1, "eligible"), strconv.FormatInt(time.Now().Add(-61*time.Second).UnixMilli(), 10), 0)
+
+	w := post(t, 1, "fresh", "alice", "first!")
+	expectStatus(t, w, 403)
+	resp := decode(t, w)
+	if retry, _ := resp["retry_after"].(float64); resp["reason"] != "too_new" || retry < 59 || retry > 60 {
+		t.Fatalf("too-new viewer: %v, want too_new with about 60s left", resp)
+	}
+	expectStatus(t, post(t, 1, "eligible", "bob", "been watching"), 200)
+
+	// A viewer who never heartbeated waits the full time
+	if w := post(t, 1, "unseen", "carol", "hi"); decode(t, w)["retry_after"] != float64(60) {
+		t.Fatalf("unseen viewer: %s, want the full 60s", w.Body.String())
+	}
+	// Trusted posters are exempt
+	expectStatus(t, post(t, 1, "unseen-mod", "dave", "welcome all", asRole(roleModerator)...), 200)
+	// Streams without the mode don't wait
+	expectStatus(t, post(t, 2, "fresh", "alice", "first!"), 200)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Messages are normalized before the filters see them, so padding and
+// stretched words can't take up the screen: NORMALIZE_TRIM trims leading and
+// trailing whitespace, NORMALIZE_MAX_SPACES caps a run of spaces or tabs,
+// NORMALIZE_MAX_NEWLINES caps consecutive line breaks (ignoring whitespace on
+// the blank lines between them) and NORMALIZE_MAX_REPEAT caps a character
+// repeated back to back ("aaaaaaaa" becomes "aaa" at 3). Digits are never
+// capped, so numbers survive. 0 turns a cap off.
+var (
+	normalizeTrim        bool
+	normalizeMaxSpaces   int
+	normalizeMaxNewlines int
+	normalizeMaxRepeat   int
+)
+
+func loadNormalizeConfig() {
+	normalizeTrim = envBool("NORMALIZE_TRIM", true)
+	normalizeMaxSpaces = envInt("NORMALIZE_MAX_SPACES", 4)
+	normalizeMaxNewlines = envInt("NORMALIZE_MAX_NEWLINES", 2)
+	normalizeMaxRepeat = envInt("NORMALIZE_MAX_REPEAT", 0)
+}
+
+// normalizeMessage applies the normalization policy to a sanitized message
+func normalizeMessage(message string) string {
+	if normalizeTrim {
+		message = strings.TrimSpace(message)
+	}
+	var b strings.Builder
+	b.Grow(len(message))
+	var spaces []rune // whitespace not written yet
+	flushSpaces := func() {
+		if normalizeMaxSpaces > 0 && len(spaces) > normalizeMaxSpaces {
+			spaces = spaces[:normalizeMaxSpaces]
+		}
+		for _, r := range spaces {
+			b.WriteRune(r)
+		}
+		spaces = spaces[:0]
+	}
+
+	newlines, repeat := 0, 0
+	var last rune
+	for _, r := range message {
+		switch {
+		case r == '\n':
+			if normalizeMaxNewlines > 0 {
+				spaces = spaces[:0] // trailing whitespace, or a blank line's
+			} else {
+				flushSpaces()
+			}
+			newlines++
+			if normalizeMaxNewlines == 0 || newlines <= normalizeMaxNewlines {
+				b.WriteRune(r)
+			}
+			last, repeat = 0, 0
+		case unicode.IsSpace(r):
+			spaces = append(spaces, r)
+			last, repeat = 0, 0
+		default:
+			flushSpaces()
+			newlines = 0
+			if r == last {
+				repeat++
+			} else {
+				last, repeat = r, 1
+			}
+			if normalizeMaxRepeat > 0 && repeat > normalizeMaxRepeat && !unicode.IsDigit(r) {
+				continue
+			}
+			b.WriteRune(r)
+		}
+	}
+	flushSpaces()
+	return b.String()
+}
@@ -0,0 +1,58 @@
+package main
+
+import "testing"
+
+// withNormalizePolicy sets the normalization policy for the rest of the test
+func withNormalizePolicy(t *testing.T, trim bool, maxSpaces, maxNewlines, maxRepeat int) {
+	t.Helper()
+	setVar(t, &normalizeTrim, trim)
+	setVar(t, &normalizeMaxSpaces, maxSpaces)
+	setVar(t, &normalizeMaxNewlines, maxNewlines)
+	setVar(t, &normalizeMaxRepeat, maxRepeat)
+}
+
+func TestNormalizeMessage(t *testing.T) {
+	withNormalizePolicy(t, true, 2, 2, 3)
+	for _, tc := range []struct{ name, in, want string }{
+		{"trim", "  hi\t\n", "hi"},
+		{"spaces", "a     b", "a  b"},
+		{"tabs", "a\t\t\t b", "a\t\tb"},
+		{"ideographic spaces", "a　　　b", "a　　b"},
+		{"newlines", "a\n\n\n\nb", "a\n\nb"},
+		{"blank lines", "a  \n \n  \n b", "a\n\n b"},
+		{"repeat", "looooool lol", "loool lol"},
+		{"digits", "1000000 aaaa", "1000000 aaa"},
+		{"accents", "héééééé", "hééé"},
+		{"kana", "ねねねねね", "ねねね"},
+		{"emoji", "🔥🔥🔥🔥🔥", "🔥🔥🔥"},
+		{"spaces break runs", "aaa aaa", "aaa aaa"},
+	} {
+		if got := normalizeMessage(tc.in); got != tc.want {
+			t.Errorf("%s: normalizeMessage(%q) = %q, want %q", tc.name, tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestNormalizeMessageToggles(t *testing.T) {
+	withNormalizePolicy(t, false, 0, 0, 0)
+	in := "  aaaaaa     b\n\n\n\n  "
+	if got := normalizeMessage(in); got != in {
+		t.Fatalf("with everything off = %q, want it unchanged", got)
+	}
+
+	withNormalizePolicy(t, true, 0, 0, 2)
+	if got := normalizeMessage(in); got != "aa     b" {
+		t.Fatalf("with only trim and repeat = %q, want %q", got, "aa     b")
+	}
+}
+
+func TestPostedCommentIsNormalized(t *testing.T) {
+	resetRedis(t)
+	withNormalizePolicy(t, true, 2, 2, 3)
+
+	w := post(t, 1, "v1", "alice", "  soooooo     cool\n\n\n\nwow  ")
+	expectStatus(t, w, 200)
+	if got := decode(t, w)["comment"].(map[string]interface{})["message"]; got != "sooo  cool\n\nwow" {
+		t.Fatalf("message = %q, want it normalized", got)
+	}
+}
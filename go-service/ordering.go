@@ -0,0 +1,84 @@
+package main
+
+import "sort"
+
+// Comment orderings accepted by check-update. History takes chronological
+// and desc.
+const (
+	orderChronological = "chronological"
+	orderGrouped       = "grouped"
+	orderNewestFirst   = "desc"
+)
+
+// groupWindowMs is the longest gap between two messages from the same author
+// that still lets them share a group
+var groupWindowMs int64
+
+func loadGroupWindow() {
+	groupWindowMs = int64(envInt("GROUP_WINDOW_MS", 30000))
+}
+
+// groupComments coalesces consecutive comments by the same author that arrive
+// within groupWindowMs into one entry listing every message. A group takes
+// the ID and timestamp of its newest message so the client's cursor advances
+// past all of them. Comments that quote another stay on their own, as the
+// quote belongs to that one message.
+func groupComments(comments []Comment) []Comment {
+	grouped := make([]Comment, 0, len(comments))
+	for _, cmt := range comments {
+		if n := len(grouped); n > 0 {
+			last := &grouped[n-1]
+			if last.Username == cmt.Username && cmt.Timestamp-last.Timestamp <= groupWindowMs && last.Quote == nil && cmt.Quote == nil && last.MinTier == cmt.MinTier {
+				if len(last.Messages) == 0 {
+					last.Messages = []string{last.Message}
+				}
+				last.Messages = append(last.Messages, cmt.Message)
+				last.ID = cmt.ID
+				last.Timestamp = cmt.Timestamp
+				continue
+			}
+		}
+		grouped = append(grouped, cmt)
+	}
+	return grouped
+}
+
+// sortByTime puts comments in publication order. The index orders comments
+// sharing a millisecond by member, i.e. by ID as a string, which puts "10"
+// before "9"; IDs grow with publication, so ties are broken by ID as a number.
+func sortByTime(comments []Comment) {
+	sort.SliceStable(comments, func(i, j int) bool {
+		if comments[i].Timestamp != comments[j].Timestamp {
+			return comments[i].Timestamp < comments[j].Timestamp
+		}
+		return comments[i].ID < comments[j].ID
+	})
+}
+
+// olderID reports whether comment ID a was allocated before b. IDs compare
+// as numbers, where the index compares members as strings.
+func olderID(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+// reverseComments flips comments in place, for newest-first feeds
+func reverseComments(comments []Comment) {
+	for i, j := 0, len(comments)-1; i < j; i, j = i+1, j-1 {
+		comments[i], comments[j] = comments[j], comments[i]
+	}
+}
+
+// newestTimestamp returns the cursor a client should send next: the largest
+// timestamp among comments, whatever order they are returned in
+func newestTimestamp(comments []Comment) int64 {
+	var newest int64
+	for _, cmt := range comments {
+		if cmt.Timestamp > newest {
+			newest = cmt.Timestamp
+		}
+	}
+	return newest
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// pollOrdered polls stream 1 from lastID in the given order
+func pollOrdered(t *testing.T, lastID int64, order string) map[string]interface{} {
+	t.Helper()
+	w := request(t, http.MethodPost, "/check-update", map[string]interface{}{
+		"stream_id": 1, "viewer_id": "v9", "last_id": lastID, "order": order,
+	})
+	expectStatus(t, w, 200)
+	return decode(t, w)
+}
+
+func TestCheckUpdateOrderAndCursor(t *testing.T) {
+	resetRedis(t)
+	for _, msg := range []string{"one", "two", "three"} {
+		expectStatus(t, post(t, 1, "v1", "alice", msg), 200)
+	}
+	nextSecond()
+
+	want := map[string]string{orderChronological: "one two three", orderNewestFirst: "three two one"}
+	cursors := map[string]int64{}
+	for order, msgs := range want {
+		resp := pollOrdered(t, 0, order)
+		if got := strings.Join(messages(resp), " "); got != msgs {
+			t.Fatalf("initial load (%s) = %s, want %s", order, got, msgs)
+		}
+		cursors[order] = int64(resp["cursor"].(float64))
+	}
+	// The cursor is the newest comment either way
+	if cursors[orderChronological] != cursors[orderNewestFirst] {
+		t.Fatalf("cursors differ: %v", cursors)
+	}
+
+	expectStatus(t, post(t, 1, "v1", "alice", "four"), 200)
+	expectStatus(t, post(t, 1, "v1", "alice", "five"), 200)
+	nextSecond()
+	want = map[string]string{orderChronological: "four five", orderNewestFirst: "five four"}
+	for order, msgs := range want {
+		resp := pollOrdered(t, cursors[order], order)
+		if got := strings.Join(messages(resp), " "); got != msgs {
+			t.Fatalf("update (%s) = %s, want %s", order, got, msgs)
+		}
+		next := int64(resp["cursor"].(float64))
+		if next <= cursors[order] {
+			t.Fatalf("cursor (%s) went from %d to %d, want it to advance", order, cursors[order], next)
+		}
+		if got := messages(pollOrdered(t, next, order)); len(got) != 0 {
+			t.Fatalf("poll after the update (%s) = %v, want nothing new", order, got)
+		}
+	}
+}
+
+func TestMyCommentsOrder(t *testing.T) {
+	resetRedis(t)
+	first := postedID(t, 1, "v1", "alice", "first")
+	second := postedID(t, 1, "v1", "alice", "second")
+
+	for query, want := range map[string][]int64{
+		"":                     {first, second},
+		"&order=chronological": {first, second},
+		"&order=desc":          {second, first},
+	} {
+		status, resp := myComments(t, "v1", query, trusted...)
+		list, _ := resp["comments"].([]interface{})
+		if status != 200 || len(list) != 2 {
+			t.Fatalf("history%s: %d %v", query, status, resp)
+		}
+		for i, id := range want {
+			if got := int64(list[i].(map[string]interface{})["id"].(float64)); got != id {
+				t.Fatalf("history%s[%d] = %d, want %d", query, i, got, id)
+			}
+		}
+	}
+	if status, _ := myComments(t, "v1", "&order=asc", trusted...); status != 400 {
+		t.Fatalf("order=asc: %d, want 400", status)
+	}
+}
+
+// groupedEntries lists the messages of a grouped response, one string per
+// entry
+func groupedEntries(resp map[string]interface{}) []string {
+	list, _ := resp["comments"].([]interface{})
+	out := make([]string, 0, len(list))
+	for _, c := range list {
+		m := c.(map[string]interface{})
+		if msgs, ok := m["messages"].([]interface{}); ok {
+			parts := make([]string, len(msgs))
+			for i, msg := range msgs {
+				parts[i] = msg.(string)
+			}
+			out = append(out, strings.Join(parts, "+"))
+			continue
+		}
+		out = append(out, m["message"].(string))
+	}
+	return out
+}
+
+func TestGroupedOrderInterleavedAndConsecutive(t *testing.T) {
+	resetRedis(t)
+	for _, c := range [][2]string{{"alice", "hello"}, {"alice", "anyone here"}, {"bob", "yes"}, {"alice", "great"}, {"bob", "welcome"}, {"bob", "to the stream"}} {
+		expectStatus(t, post(t, 1, "v-"+c[0], c[0], c[1]), 200)
+	}
+	nextSecond()
+
+	resp := pollOrdered(t, 0, orderGrouped)
+	if got := strings.Join(groupedEntries(resp), " | "); got != "hello+anyone here | yes | great | welcome+to the stream" {
+		t.Fatalf("grouped = %s", got)
+	}
+	cursor := int64(resp["cursor"].(float64))
+
+	// A later message from the last author starts a new group rather than
+	// being lost behind the cursor
+	expectStatus(t, post(t, 1, "v-bob", "bob", "glad you came"), 200)
+	nextSecond()
+	resp = pollOrdered(t, cursor, orderGrouped)
+	if got := groupedEntries(resp); len(got) != 1 || got[0] != "glad you came" {
+		t.Fatalf("update = %v, want [glad you came]", got)
+	}
+	if got := messages(pollOrdered(t, int64(resp["cursor"].(float64)), orderGrouped)); len(got) != 0 {
+		t.Fatalf("poll after the update = %v, want nothing new", got)
+	}
+}
+
+func TestGroupCommentsWindow(t *testing.T) {
+	setVar(t, &groupWindowMs, 1000)
+	grouped := groupComments([]Comment{
+		{ID: 1, Username: "alice", Message: "a", Timestamp: 1000},
+		{ID: 2, Username: "alice", Message: "b", Timestamp: 2000},
+		{ID: 3, Username: "alice", Message: "c", Timestamp: 3500},
+	})
+	if len(grouped) != 2 || len(grouped[0].Messages) != 2 || grouped[1].Message != "c" {
+		t.Fatalf("grouped = %+v, want [a b] then c", grouped)
+	}
+	if grouped[0].ID != 2 || grouped[0].Timestamp != 2000 {
+		t.Fatalf("group takes ID %d at %d, want its newest message's 2 at 2000", grouped[0].ID, grouped[0].Timestamp)
+	}
+}
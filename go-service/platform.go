@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Comments carry the platform they were posted from so clients can show a
+// badge and streamers can see where their chat comes from. Clients send
+// platform on post-comment; it must be one of COMMENT_PLATFORMS and is
+// "unknown" when absent. Bridged comments keep their integration in source
+// as before (a direct post can't set it, since bridged comments skip the
+// checks meant for viewers). Published comments are counted by platform and
+// by source in the stats hash, which GET /stream/:id/stats reports next to
+// the filter outcomes.
+const platformUnknown = "unknown"
+
+var commentPlatforms []string
+
+func loadPlatformConfig() {
+	commentPlatforms = nil
+	for _, p := range strings.Split(envString("COMMENT_PLATFORMS", "web,ios,android,tv"), ",") {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+			commentPlatforms = append(commentPlatforms, p)
+		}
+	}
+}
+
+// normalizePlatform returns the platform to record for a requested one, and
+// false when it isn't allowed
+func normalizePlatform(platform string) (string, bool) {
+	platform = strings.ToLower(strings.TrimSpace(platform))
+	if platform == "" {
+		return platformUnknown, true
+	}
+	for _, p := range commentPlatforms {
+		if p == platform {
+			return platform, true
+		}
+	}
+	return platform, false
+}
+
+// recordPlatform counts a published comment's platform and source
+func recordPlatform(ctx context.Context, streamID int64, cmt *Comment) {
+	_, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		platform := cmt.Platform
+		if platform == "" {
+			platform = platformUnknown
+		}
+		pipe.HIncrBy(ctx, platformStatsKey(streamID), "platform:"+platform, 1)
+		if cmt.Source != "" {
+			pipe.HIncrBy(ctx, platformStatsKey(streamID), "source:"+cmt.Source, 1)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error recording platform stats: %v", streamID, err)
+	}
+}
+
+// loadPlatformStats returns the current broadcast's comment counts by platform
+// and by source
+func loadPlatformStats(ctx context.Context, streamID int64) (map[string]int64, map[string]int64, error) {
+	fields, err := rdb.HGetAll(ctx, platformStatsKey(streamID)).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	platforms := map[string]int64{}
+	sources := map[string]int64{}
+	for field, v := range fields {
+		kind, name, ok := strings.Cut(field, ":")
+		n, convErr := strconv.ParseInt(v, 10, 64)
+		if !ok || convErr != nil {
+			continue
+		}
+		switch kind {
+		case "platform":
+			platforms[name] = n
+		case "source":
+			sources[name] = n
+		}
+	}
+	return platforms, sources, nil
+}
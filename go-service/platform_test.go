@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// postFrom posts a comment to stream 1 from a platform
+func postFrom(t *testing.T, viewerID, platform string) *httptest.ResponseRecorder {
+	t.Helper()
+	body := map[string]interface{}{"stream_id": 1, "viewer_id": viewerID, "username": viewerID, "message": "hi"}
+	if platform != "" {
+		body["platform"] = platform
+	}
+	return request(t, http.MethodPost, "/post-comment", body)
+}
+
+func TestNormalizePlatform(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+		ok       bool
+	}{
+		{"", platformUnknown, true},
+		{"web", "web", true},
+		{"iOS", "ios", true},
+		{" tv ", "tv", true},
+		{"fridge", "fridge", false},
+	} {
+		if got, ok := normalizePlatform(tc.in); got != tc.want || ok != tc.ok {
+			t.Errorf("normalizePlatform(%q) = %q %v, want %q %v", tc.in, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestCommentPlatforms(t *testing.T) {
+	resetRedis(t)
+	for _, tc := range []struct{ viewer, platform, want string }{
+		{"v1", "ios", "ios"},
+		{"v2", "Android", "android"},
+		{"v3", "", platformUnknown},
+	} {
+		w := postFrom(t, tc.viewer, tc.platform)
+		expectStatus(t, w, 200)
+		if got := decode(t, w)["comment"].(map[string]interface{})["platform"]; got != tc.want {
+			t.Fatalf("platform %q recorded as %v, want %q", tc.platform, got, tc.want)
+		}
+	}
+	w := postFrom(t, "v4", "fridge")
+	if resp := decode(t, w); w.Code != 400 || resp["reason"] != "invalid_platform" {
+		t.Fatalf("unknown platform: %d %v, want 400 invalid_platform", w.Code, resp)
+	}
+
+	ingest := map[string]interface{}{"comments": []interface{}{
+		map[string]interface{}{"stream_id": 1, "viewer_id": "yt:1", "username": "alice", "message": "bridged", "source": "youtube"},
+	}}
+	expectStatus(t, request(t, http.MethodPost, "/ingest", ingest, trusted...), 200)
+
+	stats := filterStats(t)
+	platforms, _ := stats["platforms"].(map[string]interface{})
+	if platforms["ios"] != float64(1) || platforms["android"] != float64(1) || platforms["unknown"] != float64(2) || platforms["fridge"] != nil {
+		t.Fatalf("platforms = %v, want ios 1, android 1, unknown 2 (the bridged comment has none)", platforms)
+	}
+	if sources, _ := stats["sources"].(map[string]interface{}); len(sources) != 1 || sources["youtube"] != float64(1) {
+		t.Fatalf("sources = %v, want youtube 1", stats["sources"])
+	}
+}
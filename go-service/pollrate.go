@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// check-update enforces a minimum interval between one viewer's polls, so
+// clients that ignore the recommended interval can't hammer Redis. Every
+// response carries next_poll_after_ms, and polls that come sooner get a 429
+// saying how long to wait. The interval is POLL_MIN_INTERVAL_MS (streams
+// override it with poll_min_interval_ms in their modes) plus
+// POLL_LOAD_INTERVAL_MS for every POLL_LOAD_STEP viewers online, up to
+// POLL_MAX_INTERVAL_MS, so busy streams are asked to poll less often. 0 turns
+// enforcement off. Moderators are never throttled. Viewers are told apart by
+// viewer_id, or by address when they send none.
+var (
+	pollMinInterval  time.Duration
+	pollLoadInterval time.Duration
+	pollLoadStep     int64
+	pollMaxInterval  time.Duration
+)
+
+// pollIntervalRefresh is how long a replica reuses a stream's interval
+// before re-reading its modes and viewer count
+const pollIntervalRefresh = 5 * time.Second
+
+func loadPollRateConfig() {
+	pollMinInterval = time.Duration(envInt("POLL_MIN_INTERVAL_MS", 0)) * time.Millisecond
+	pollLoadInterval = time.Duration(envInt("POLL_LOAD_INTERVAL_MS", 0)) * time.Millisecond
+	pollLoadStep = int64(envInt("POLL_LOAD_STEP", 1000))
+	if pollLoadStep < 1 {
+		pollLoadStep = 1000
+	}
+	pollMaxInterval = time.Duration(envInt("POLL_MAX_INTERVAL_MS", 10000)) * time.Millisecond
+}
+
+// pollIntervalFor scales a stream's base interval by its viewer count
+func pollIntervalFor(base time.Duration, online int64) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	interval := base + time.Duration(online/pollLoadStep)*pollLoadInterval
+	if pollMaxInterval > 0 && interval > pollMaxInterval {
+		interval = pollMaxInterval
+	}
+	return interval
+}
+
+type cachedPollInterval struct {
+	interval time.Duration
+	expires  time.Time
+}
+
+var pollIntervals = struct {
+	sync.Mutex
+	streams map[int64]cachedPollInterval
+}{streams: map[int64]cachedPollInterval{}}
+
+// pollInterval returns a stream's current minimum poll interval
+func pollInterval(ctx context.Context, streamID int64) time.Duration {
+	now := time.Now()
+	pollIntervals.Lock()
+	cached, ok := pollIntervals.streams[streamID]
+	pollIntervals.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.interval
+	}
+
+	base := pollMinInterval
+	if modes, err := loadStreamModes(ctx, streamID); err == nil {
+		base = modes.PollMinInterval
+	}
+	var online int64
+	if base > 0 && pollLoadInterval > 0 {
+		online, _ = store.OnlineCount(ctx, streamID)
+	}
+	interval := pollIntervalFor(base, online)
+
+	pollIntervals.Lock()
+	pollIntervals.streams[streamID] = cachedPollInterval{interval: interval, expires: now.Add(pollIntervalRefresh)}
+	// Streams nobody polls anymore drop out as the cache is refreshed
+	for id, entry := range pollIntervals.streams {
+		if now.After(entry.expires.Add(time.Minute)) {
+			delete(pollIntervals.streams, id)
+		}
+	}
+	pollIntervals.Unlock()
+	return interval
+}
+
+// takePollSlot claims a viewer's next poll. When they polled too recently it
+// returns false and how long until they may poll again.
+func takePollSlot(ctx context.Context, streamID int64, who string, interval time.Duration) (bool, time.Duration) {
+	key := rateLimitKey(streamID, "poll", who)
+	ok, err := rdb.SetNX(ctx, key, 1, interval).Result()
+	if err != nil {
+		// Fail open, like the comment rate limit
+		log.Printf("[GO] Stream %d: Error checking poll interval: %v", streamID, err)
+		return true, 0
+	}
+	if ok {
+		return true, 0
+	}
+	wait, err := rdb.PTTL(ctx, key).Result()
+	if err != nil && err != redis.Nil {
+		return true, 0
+	}
+	if wait <= 0 {
+		wait = interval
+	}
+	return false, wait
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// resetPollIntervals drops the cached poll intervals now and after the test
+func resetPollIntervals(t *testing.T) {
+	t.Helper()
+	reset := func() {
+		pollIntervals.Lock()
+		pollIntervals.streams = map[int64]cachedPollInterval{}
+		pollIntervals.Unlock()
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+// pollStatus runs check-update on stream 1 and returns the status and body
+func pollStatus(t *testing.T, viewerID string, headers ...string) (int, map[string]interface{}) {
+	t.Helper()
+	w := request(t, http.MethodPost, "/check-update", map[string]interface{}{
+		"stream_id": 1, "viewer_id": viewerID, "last_id": 0,
+	}, headers...)
+	return w.Code, decode(t, w)
+}
+
+func TestPollIntervalFor(t *testing.T) {
+	setVar(t, &pollLoadInterval, 500*time.Millisecond)
+	setVar(t, &pollLoadStep, 1000)
+	setVar(t, &pollMaxInterval, 3*time.Second)
+	for _, tc := range []struct {
+		base   time.Duration
+		online int64
+		want   time.Duration
+	}{
+		{0, 5000, 0},
+		{time.Second, 999, time.Second},
+		{time.Second, 1000, 1500 * time.Millisecond},
+		{time.Second, 2500, 2 * time.Second},
+		{time.Second, 100000, 3 * time.Second},
+	} {
+		if got := pollIntervalFor(tc.base, tc.online); got != tc.want {
+			t.Errorf("pollIntervalFor(%v, %d) = %v, want %v", tc.base, tc.online, got, tc.want)
+		}
+	}
+}
+
+func TestTooFrequentPolling(t *testing.T) {
+	resetRedis(t)
+	resetPollIntervals(t)
+	rdb.HSet(ctx, modesKey(1), "poll_min_interval_ms", "2000")
+
+	status, resp := pollStatus(t, "v1")
+	if status != 200 || resp["next_poll_after_ms"] != float64(2000) {
+		t.Fatalf("first poll = %d %v, want 200 with next_poll_after_ms 2000", status, resp["next_poll_after_ms"])
+	}
+	w := request(t, http.MethodPost, "/check-update", map[string]interface{}{"stream_id": 1, "viewer_id": "v1", "last_id": 0})
+	expectStatus(t, w, 429)
+	if resp := decode(t, w); resp["reason"] != "poll_too_soon" || resp["next_poll_after_ms"].(float64) <= 0 || w.Header().Get("Retry-After") != "2" {
+		t.Fatalf("too soon = %v Retry-After %q, want poll_too_soon with a wait", resp, w.Header().Get("Retry-After"))
+	}
+
+	// Other viewers and moderators aren't held back
+	if status, _ := pollStatus(t, "v2"); status != 200 {
+		t.Fatalf("another viewer: %d, want 200", status)
+	}
+	for i := 0; i < 2; i++ {
+		if status, _ := pollStatus(t, "mod", asRole(roleModerator)...); status != 200 {
+			t.Fatalf("moderator: %d, want 200", status)
+		}
+	}
+
+	testRedis.FastForward(2 * time.Second)
+	if status, _ := pollStatus(t, "v1"); status != 200 {
+		t.Fatalf("after the interval: %d, want 200", status)
+	}
+}
+
+func TestPollingUnthrottledByDefault(t *testing.T) {
+	resetRedis(t)
+	resetPollIntervals(t)
+	for i := 0; i < 3; i++ {
+		if status, resp := pollStatus(t, "v1"); status != 200 || resp["next_poll_after_ms"] != nil {
+			t.Fatalf("poll %d = %d %v, want 200 without a hint", i, status, resp["next_poll_after_ms"])
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// pollState is the stream state check-update needs besides the feed: modes,
+// lifecycle, maintenance, existence, timezone, flood window, moderator
+// presence, highlight policy, comment rate, live reactions and the viewer's
+// delivery preferences. It's read in one pipeline rather than a round trip
+// per helper, so a poll costs that pipeline plus the feed script. Scripts
+// that write as they go (featured question promotion, smoothing, the poll
+// slot) and the priority lane, whose range depends on the feed read, keep
+// their own calls.
+type pollState struct {
+	Modes       StreamModes
+	ModesErr    error
+	Status      StreamStatus
+	StatusErr   error
+	Maintenance bool
+	// Missing is set when the stream's existence marker is absent and
+	// STREAM_NOT_FOUND is on
+	Missing    bool
+	Zone       *time.Location
+	FloodCount int
+	ModProfile string
+	Prefs      DeliveryPrefs
+	Highlights HighlightPolicy
+	// ScrollRate is the comment rate over scrollHintWindow
+	ScrollRate    float64
+	LiveReactions map[string]int
+}
+
+// loadPollState reads a stream's poll state for viewerID. Each value falls
+// back on its own like the helper it replaces when its read fails.
+func loadPollState(ctx context.Context, streamID int64, viewerID string) pollState {
+	var (
+		modes       *redis.StringStringMapCmd
+		status      *redis.SliceCmd
+		maintenance *redis.StringCmd
+		exists      *redis.IntCmd
+		zone        *redis.StringCmd
+		flood       *redis.StringCmd
+		modsOnline  *redis.IntCmd
+		modActive   *redis.IntCmd
+		prefs       *redis.StringCmd
+		highlights  *redis.StringStringMapCmd
+		velocity    *redis.SliceCmd
+		reactions   []*redis.StringStringMapCmd
+	)
+	rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		modes = pipe.HGetAll(ctx, modesKey(streamID))
+		status = pipe.MGet(ctx, streamStartKey(streamID), streamEndKey(streamID))
+		maintenance = pipe.Get(ctx, maintenanceKey())
+		exists = pipe.Exists(ctx, streamExistsKey(streamID))
+		zone = pipe.Get(ctx, timezoneKey(streamID))
+		flood = pipe.Get(ctx, floodKey(streamID))
+		modsOnline = pipe.ZCount(ctx, moderatorsOnlineKey(streamID), modPresenceSince(), "+inf")
+		modActive = pipe.Exists(ctx, moderatorActiveKey(streamID))
+		if viewerID != "" {
+			prefs = pipe.HGet(ctx, deliveryPrefsKey(), viewerID)
+		}
+		highlights = pipe.HGetAll(ctx, highlightPolicyKey(streamID))
+		if scrollHints && scrollPauseRate > 0 {
+			velocity = pipe.MGet(ctx, velocityKeys(streamID, scrollHintWindow)...)
+		}
+		reactions = queueLiveReactions(ctx, pipe, streamID)
+		return nil
+	})
+
+	var state pollState
+	if state.ModesErr = modes.Err(); state.ModesErr == nil {
+		state.Modes = parseStreamModes(ctx, streamID, modes.Val())
+	} else {
+		state.Modes = defaultStreamModes()
+	}
+	if state.StatusErr = status.Err(); state.StatusErr == nil {
+		state.Status = parseStreamStatus(status.Val())
+	}
+	state.Maintenance = maintenanceMode || (maintenance.Err() == nil && flagEnabled(maintenance.Val()))
+	if streamNotFoundMode != streamNotFoundOff {
+		state.Missing = streamMissing(streamID, exists.Val(), exists.Err())
+	}
+	state.Zone = defaultDisplayZone
+	if zone.Err() == nil {
+		state.Zone = zoneNamed(zone.Val())
+	}
+	state.FloodCount, _ = flood.Int()
+	if modProfilesEnabled() {
+		state.ModProfile = modProfileModerated
+		if modsOnline.Err() == nil && modActive.Err() == nil {
+			state.ModProfile = modProfileFor(modsOnline.Val(), modActive.Val())
+		}
+	}
+	if prefs != nil {
+		state.Prefs = parseDeliveryPrefs(viewerID, prefs.Val(), prefs.Err())
+	}
+	state.Highlights = defaultHighlightPolicy
+	if err := highlights.Err(); err == nil {
+		state.Highlights = parseHighlightPolicy(highlights.Val())
+	} else {
+		log.Printf("[GO] Stream %d: Error loading highlight policy: %v", streamID, err)
+	}
+	if velocity != nil {
+		if err := velocity.Err(); err == nil {
+			state.ScrollRate = velocityRate(velocity.Val())
+		} else {
+			log.Printf("[GO] Stream %d: Error reading comment rate: %v", streamID, err)
+		}
+	}
+	state.LiveReactions = liveReactionsFrom(reactions)
+	return state
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func TestPollStateReadsInOneRoundTrip(t *testing.T) {
+	resetRedis(t)
+	rdb.HSet(ctx, modesKey(1), "flood_cap", 3, "slow_mode", 10)
+	rdb.Set(ctx, floodKey(1), 3, time.Minute)
+	rdb.Set(ctx, maintenanceKey(), "1", 0)
+	rdb.Set(ctx, timezoneKey(1), "Asia/Tehran", 0)
+	rdb.Set(ctx, streamStartKey(1), time.Now().UnixMilli(), 0)
+	rdb.HSet(ctx, deliveryPrefsKey(), "v1", `{"hide_bots":true}`)
+
+	trips := &roundTrips{}
+	client := redis.NewClient(&redis.Options{Addr: testRedis.Addr()})
+	client.AddHook(trips)
+	t.Cleanup(func() { client.Close() })
+	setVar(t, &rdb, client)
+
+	state := loadPollState(ctx, 1, "v1")
+	if trips.n != 1 {
+		t.Fatalf("round trips = %d, want 1", trips.n)
+	}
+	if state.ModesErr != nil || state.Modes.SlowMode != 10 || !floodFull(state.FloodCount, state.Modes) {
+		t.Fatalf("modes = %+v (%v), flood count %d", state.Modes, state.ModesErr, state.FloodCount)
+	}
+	if !state.Maintenance || !state.Status.Live || state.Zone.String() != "Asia/Tehran" || !state.Prefs.HideBots {
+		t.Fatalf("state = %+v", state)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// postOutcome posts to stream 1 and returns the response code and body
+func postOutcome(t *testing.T, viewerID, username, message string) (int, map[string]interface{}) {
+	t.Helper()
+	w := post(t, 1, viewerID, username, message)
+	return w.Code, decode(t, w)
+}
+
+func TestPostStatusStates(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &chatDisabledPolicy, chatDisabledBuffer)
+	if err := storeBan(ctx, 1, ModerationTarget{ViewerID: "v9"}, 0, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if code, resp := postOutcome(t, "v1", "alice", "hello"); code != 200 || resp["status"] != postPublished || resp["reason"] != nil {
+		t.Fatalf("plain post: %d %v, want published", code, resp)
+	}
+	// The shadow-banned poster is told it went out like any other
+	if code, resp := postOutcome(t, "v9", "troll", "hidden"); code != 200 || resp["status"] != postPublished || resp["reason"] != nil {
+		t.Fatalf("shadow-banned post: %d %v, want published", code, resp)
+	}
+
+	rdb.HSet(ctx, modesKey(1), "emote_only", "1")
+	if code, resp := postOutcome(t, "v1", "alice", "words"); code != 403 || resp["status"] != postRejected || resp["reason"] != "emote_only" {
+		t.Fatalf("refused post: %d %v, want rejected emote_only", code, resp)
+	}
+	rdb.HDel(ctx, modesKey(1), "emote_only")
+
+	// Others only ever see the published post
+	nextSecond()
+	if got := strings.Join(messages(poll(t, 1, "v2", 0)), " "); got != "hello" {
+		t.Fatalf("feed = %q, want only the published post", got)
+	}
+
+	rdb.Set(ctx, allowCommentsKey(1), "0", 0)
+	for _, poster := range []string{"v1", "v9"} {
+		if code, resp := postOutcome(t, poster, poster, "while closed"); code != 200 || resp["status"] != postPending || resp["reason"] != "chat_disabled" {
+			t.Fatalf("post by %s while closed: %d %v, want pending chat_disabled", poster, code, resp)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// DeliveryPrefs choose which kinds of comments a viewer receives. They filter
+// by comment type (who or what produced it, whether it mentions the viewer),
+// not by content. The zero value delivers everything.
+type DeliveryPrefs struct {
+	MentionsOnly bool `json:"mentions_only"` // only comments that @mention the viewer
+	HideBots     bool `json:"hide_bots"`     // drop comments from bot sources (BOT_SOURCES)
+	HideBridged  bool `json:"hide_bridged"`  // drop comments bridged from other platforms
+}
+
+func (p DeliveryPrefs) isZero() bool { return p == DeliveryPrefs{} }
+
+// botSources are ingest sources whose comments count as bot messages
+var botSources map[string]bool
+
+func loadPrefsConfig() {
+	botSources = map[string]bool{}
+	for _, source := range strings.Split(envString("BOT_SOURCES", "bot"), ",") {
+		if source = strings.TrimSpace(source); source != "" {
+			botSources[source] = true
+		}
+	}
+}
+
+// loadDeliveryPrefs returns a viewer's stored preferences, or the zero value
+func loadDeliveryPrefs(ctx context.Context, viewerID string) DeliveryPrefs {
+	var prefs DeliveryPrefs
+	if viewerID == "" {
+		return prefs
+	}
+	raw, err := rdb.HGet(ctx, deliveryPrefsKey(), viewerID).Result()
+	return parseDeliveryPrefs(viewerID, raw, err)
+}
+
+// parseDeliveryPrefs decodes a viewer's stored preferences as read with err
+func parseDeliveryPrefs(viewerID, raw string, err error) DeliveryPrefs {
+	var prefs DeliveryPrefs
+	if err != nil {
+		if err != redis.Nil {
+			log.Printf("[GO] Error loading delivery preferences for %s: %v", viewerID, err)
+		}
+		return prefs
+	}
+	json.Unmarshal([]byte(raw), &prefs)
+	return prefs
+}
+
+// deliveryFilter decides which comments a viewer receives
+type deliveryFilter struct {
+	prefs   DeliveryPrefs
+	access  int            // subscriber tier rank the viewer may read, see tiers.go
+	mention *regexp.Regexp // nil when the viewer's name is unknown
+}
+
+// newDeliveryFilter resolves what the preferences need, i.e. the name the
+// viewer posts under in this stream for mention matching
+func newDeliveryFilter(ctx context.Context, streamID int64, viewerID string, prefs DeliveryPrefs, access int) deliveryFilter {
+	f := deliveryFilter{prefs: prefs, access: access}
+	if prefs.MentionsOnly && viewerID != "" {
+		if name, err := rdb.HGet(ctx, viewerNamesKey(streamID), viewerID).Result(); err == nil && name != "" {
+			f.mention = regexp.MustCompile(`(?i)@` + regexp.QuoteMeta(name) + `\b`)
+		}
+	}
+	return f
+}
+
+func (f deliveryFilter) allows(cmt Comment) bool {
+	if !canSee(cmt, f.access) {
+		return false
+	}
+	// Everyone needs to see notices like mode changes
+	if cmt.Type == commentTypeSystem {
+		return true
+	}
+	if f.prefs.HideBots && botSources[cmt.Source] {
+		return false
+	}
+	if f.prefs.HideBridged && cmt.Source != "" && !botSources[cmt.Source] {
+		return false
+	}
+	if f.prefs.MentionsOnly {
+		if f.mention == nil {
+			return false
+		}
+		for _, msg := range append([]string{cmt.Message}, cmt.Messages...) {
+			if f.mention.MatchString(msg) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// apply drops comments the viewer opted out of or may not read. Callers take
+// the cursor before filtering so hidden comments are still skipped over.
+func (f deliveryFilter) apply(comments []Comment) []Comment {
+	if f.prefs.isZero() && f.access == tierAccessAll {
+		return comments
+	}
+	kept := comments[:0]
+	for _, cmt := range comments {
+		if f.allows(cmt) {
+			kept = append(kept, cmt)
+		}
+	}
+	return kept
+}
+
+type PreferencesRequest struct {
+	ViewerID string `json:"viewer_id" binding:"required"`
+	DeliveryPrefs
+}
+
+// setDeliveryPrefs stores a viewer's delivery preferences; sending all
+// options off clears them
+func setDeliveryPrefs(c *gin.Context) {
+	var req PreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	reqCtx := c.Request.Context()
+	var err error
+	if req.DeliveryPrefs.isZero() {
+		err = rdb.HDel(reqCtx, deliveryPrefsKey(), req.ViewerID).Err()
+	} else {
+		payload, _ := json.Marshal(req.DeliveryPrefs)
+		err = rdb.HSet(reqCtx, deliveryPrefsKey(), req.ViewerID, payload).Err()
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to save preferences"})
+		return
+	}
+	c.JSON(200, gin.H{"success": true, "preferences": req.DeliveryPrefs})
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// ingest publishes a bridged comment to stream 1 from source
+func ingest(t *testing.T, source, username, message string) {
+	t.Helper()
+	w := request(t, http.MethodPost, "/ingest", map[string]interface{}{"comments": []interface{}{
+		map[string]interface{}{"stream_id": 1, "viewer_id": source + ":" + username, "username": username, "message": message, "source": source},
+	}}, trusted...)
+	expectStatus(t, w, 200)
+	if decode(t, w)["published"] != float64(1) {
+		t.Fatalf("ingest from %s: %s", source, w.Body.String())
+	}
+}
+
+func TestDeliveryPreferenceCombinations(t *testing.T) {
+	resetRedis(t)
+	expectStatus(t, post(t, 1, "v3", "carol", "hi all"), 200)
+	expectStatus(t, post(t, 1, "v1", "alice", "hey @carol"), 200)
+	ingest(t, "bot", "helper", "bot says @carol")
+	ingest(t, "youtube", "yuki", "from youtube")
+	ingest(t, "youtube", "yuki", "yt @carol")
+	expectStatus(t, post(t, 1, "v4", "dave", "plain"), 200)
+	nextSecond()
+	full := poll(t, 1, "v3", 0)
+
+	type kind struct{ bot, bridged, mention bool }
+	kinds := map[string]kind{
+		"hi all":          {},
+		"hey @carol":      {mention: true},
+		"bot says @carol": {bot: true, mention: true},
+		"from youtube":    {bridged: true},
+		"yt @carol":       {bridged: true, mention: true},
+		"plain":           {},
+	}
+	for mask := 0; mask < 8; mask++ {
+		prefs := DeliveryPrefs{MentionsOnly: mask&1 != 0, HideBots: mask&2 != 0, HideBridged: mask&4 != 0}
+		var want []string
+		for _, msg := range messages(full) {
+			k := kinds[msg]
+			if (prefs.MentionsOnly && !k.mention) || (prefs.HideBots && k.bot) || (prefs.HideBridged && k.bridged) {
+				continue
+			}
+			want = append(want, msg)
+		}
+
+		w := request(t, http.MethodPost, "/check-update", map[string]interface{}{
+			"stream_id": 1, "viewer_id": "v3", "last_id": 0, "preferences": prefs,
+		})
+		expectStatus(t, w, 200)
+		resp := decode(t, w)
+		if got := strings.Join(messages(resp), "|"); got != strings.Join(want, "|") {
+			t.Fatalf("prefs %+v: %s, want %s", prefs, got, strings.Join(want, "|"))
+		}
+		// Filtered comments are still skipped over
+		if resp["cursor"] != full["cursor"] {
+			t.Fatalf("prefs %+v: cursor %v, want %v", prefs, resp["cursor"], full["cursor"])
+		}
+	}
+}
+
+func TestStoredDeliveryPreferences(t *testing.T) {
+	resetRedis(t)
+	expectStatus(t, post(t, 1, "v1", "alice", "hello"), 200)
+	ingest(t, "bot", "helper", "beep")
+	expectStatus(t, request(t, http.MethodPost, "/viewer/preferences", map[string]interface{}{"viewer_id": "v2", "hide_bots": true}), 200)
+	nextSecond()
+
+	if got := fmt.Sprint(messages(poll(t, 1, "v2", 0))); got != "[hello]" {
+		t.Fatalf("with stored prefs = %s, want [hello]", got)
+	}
+	if got := fmt.Sprint(messages(poll(t, 1, "v1", 0))); got != "[hello beep]" {
+		t.Fatalf("another viewer = %s, want everything", got)
+	}
+	expectStatus(t, request(t, http.MethodPost, "/viewer/preferences", map[string]interface{}{"viewer_id": "v2"}), 200)
+	if got := fmt.Sprint(messages(poll(t, 1, "v2", 0))); got != "[hello beep]" {
+		t.Fatalf("after clearing = %s, want everything", got)
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Presence changes (viewers joining and leaving) are announced on a per-stream
+// channel so every replica can push them to its WebSocket clients. Updates
+// are debounced per stream, and each carries the online count read from the
+// online set at flush time, so clients always converge on the Redis count
+// however many events were coalesced or missed.
+const (
+	presenceJoin  = "join"
+	presenceLeave = "leave"
+)
+
+const (
+	// presenceDebounce coalesces churn into one update per stream
+	presenceDebounce = time.Second
+	// presenceReconcile re-reads counts that change without an event, such
+	// as polling viewers whose online set entry lapses
+	presenceReconcile = 30 * time.Second
+)
+
+type presenceEvent struct {
+	Event    string `json:"event"`
+	ViewerID string `json:"viewer_id"`
+}
+
+// PresenceUpdate is what WebSocket clients receive. Joined and Left omit
+// viewers who hide themselves from viewer lists.
+type PresenceUpdate struct {
+	Type   string   `json:"type"`
+	Online int64    `json:"online"`
+	Joined []string `json:"joined,omitempty"`
+	Left   []string `json:"left,omitempty"`
+}
+
+// notifyPresence announces a join or leave to every replica
+func notifyPresence(ctx context.Context, streamID int64, event, viewerID string) {
+	payload, _ := json.Marshal(presenceEvent{Event: event, ViewerID: viewerID})
+	if err := rdb.Publish(ctx, presenceChannel(streamID), payload).Err(); err != nil {
+		log.Printf("[GO] Stream %d: Error publishing presence: %v", streamID, err)
+	}
+}
+
+// presenceHub fans debounced presence updates out to local listeners
+type presenceHub struct {
+	mu        sync.Mutex
+	listeners map[int64]map[chan PresenceUpdate]struct{}
+	pending   map[int64]*PresenceUpdate // coalescing until the debounce fires
+	last      map[int64]int64           // last count sent per stream
+}
+
+var presence = &presenceHub{
+	listeners: map[int64]map[chan PresenceUpdate]struct{}{},
+	pending:   map[int64]*PresenceUpdate{},
+	last:      map[int64]int64{},
+}
+
+func (h *presenceHub) subscribe(streamID int64) (chan PresenceUpdate, func()) {
+	ch := make(chan PresenceUpdate, 4)
+	h.mu.Lock()
+	if h.listeners[streamID] == nil {
+		h.listeners[streamID] = map[chan PresenceUpdate]struct{}{}
+	}
+	h.listeners[streamID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.listeners[streamID], ch)
+		if len(h.listeners[streamID]) == 0 {
+			delete(h.listeners, streamID)
+			delete(h.last, streamID)
+		}
+		h.mu.Unlock()
+	}
+}
+
+// record queues an event and schedules the stream's next flush
+func (h *presenceHub) record(ctx context.Context, streamID int64, ev presenceEvent) {
+	h.mu.Lock()
+	listening := h.listeners[streamID] != nil
+	h.mu.Unlock()
+	if !listening {
+		return // nobody here to tell
+	}
+	named := ev.ViewerID != "" && !isPrivateViewer(ctx, ev.ViewerID)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	upd := h.pending[streamID]
+	if upd == nil {
+		upd = &PresenceUpdate{Type: "presence"}
+		h.pending[streamID] = upd
+		time.AfterFunc(presenceDebounce, func() { h.flush(ctx, streamID) })
+	}
+	if !named {
+		return
+	}
+	switch ev.Event {
+	case presenceJoin:
+		upd.Joined = append(upd.Joined, ev.ViewerID)
+	case presenceLeave:
+		upd.Left = append(upd.Left, ev.ViewerID)
+	}
+}
+
+// flush sends the stream's pending update with the authoritative count
+func (h *presenceHub) flush(ctx context.Context, streamID int64) {
+	online, err := store.OnlineCount(ctx, streamID)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	upd := h.pending[streamID]
+	delete(h.pending, streamID)
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error counting viewers: %v", streamID, err)
+		return
+	}
+	if upd == nil {
+		// Reconciliation pass: only worth sending if the count drifted
+		if last, ok := h.last[streamID]; ok && last == online {
+			return
+		}
+		upd = &PresenceUpdate{Type: "presence"}
+	}
+	upd.Online = online
+	h.last[streamID] = online
+	for ch := range h.listeners[streamID] {
+		select {
+		case ch <- *upd:
+		default: // a slow client catches up on the next update
+		}
+	}
+}
+
+// reconcile re-checks the count of every stream with local listeners
+func (h *presenceHub) reconcile(ctx context.Context) {
+	h.mu.Lock()
+	streams := make([]int64, 0, len(h.listeners))
+	for streamID := range h.listeners {
+		if h.pending[streamID] == nil {
+			streams = append(streams, streamID)
+		}
+	}
+	h.mu.Unlock()
+	for _, streamID := range streams {
+		h.flush(ctx, streamID)
+	}
+}
+
+// runPresenceHub relays presence events from Redis to the local hub,
+// resubscribing if the subscription drops
+func runPresenceHub(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(presenceReconcile)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				presence.reconcile(ctx)
+			}
+		}
+	}()
+
+	prefix := presenceChannelPrefix()
+	for ctx.Err() == nil {
+		sub := rdb.PSubscribe(ctx, prefix+"*")
+		jobs.setRunning("presence_hub", true)
+		for msg := range sub.Channel() {
+			streamID, err := strconv.ParseInt(strings.TrimPrefix(msg.Channel, prefix), 10, 64)
+			if err != nil {
+				continue
+			}
+			var ev presenceEvent
+			if json.Unmarshal([]byte(msg.Payload), &ev) == nil {
+				presence.record(ctx, streamID, ev)
+			}
+		}
+		sub.Close()
+		jobs.setRunning("presence_hub", false)
+		time.Sleep(time.Second)
+	}
+}
+
+// isPrivateViewer reports whether a viewer hides themselves from viewer lists
+func isPrivateViewer(ctx context.Context, viewerID string) bool {
+	hidden, err := rdb.SIsMember(ctx, privateViewersKey(), viewerID).Result()
+	return err == nil && hidden
+}
+
+// connectViewer marks a viewer online for a persistent connection. Viewers
+// can hold several connections (tabs, replicas), so they only leave the
+// online set when their last one closes.
+func connectViewer(ctx context.Context, streamID int64, viewerID string) {
+	if err := rdb.HIncrBy(ctx, socketCountsKey(streamID), viewerID, 1).Err(); err != nil {
+		log.Printf("[GO] Stream %d: Error registering connection: %v", streamID, err)
+	}
+	rdb.Expire(ctx, socketCountsKey(streamID), presenceTTL)
+	refreshViewer(ctx, streamID, viewerID)
+}
+
+// refreshViewer keeps a connected viewer in the online set, like a heartbeat
+func refreshViewer(ctx context.Context, streamID int64, viewerID string) {
+	added, online, err := store.MarkOnline(ctx, streamID, viewerID)
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error marking viewer online: %v", streamID, err)
+		return
+	}
+	rdb.Expire(ctx, socketCountsKey(streamID), presenceTTL)
+	recordPeak(ctx, streamID, online)
+	observeActivity(ctx, streamID, online)
+	recordPresence(ctx, streamID, viewerID)
+	touchStream(ctx, streamID)
+	if added {
+		notifyPresence(ctx, streamID, presenceJoin, viewerID)
+	}
+}
+
+// disconnectViewer undoes connectViewer when a connection closes. A viewer
+// who also heartbeats stays online until their heartbeats lapse.
+func disconnectViewer(ctx context.Context, streamID int64, viewerID string) {
+	remaining, err := rdb.HIncrBy(ctx, socketCountsKey(streamID), viewerID, -1).Result()
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error deregistering connection: %v", streamID, err)
+		return
+	}
+	if remaining > 0 {
+		return
+	}
+	rdb.HDel(ctx, socketCountsKey(streamID), viewerID)
+	if heartbeatFresh(ctx, streamID, viewerID) {
+		return
+	}
+	rdb.ZRem(ctx, viewerSeenKey(streamID), viewerID)
+	if removed, err := store.MarkOffline(ctx, streamID, viewerID); err == nil && removed {
+		notifyPresence(ctx, streamID, presenceLeave, viewerID)
+	}
+}
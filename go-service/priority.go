@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Comments from the streamer and moderators are easy to lose in fast chat,
+// so they are flagged priority and also indexed on their own. check-update
+// returns the priority comments of the polled range as priority, besides the
+// normal feed, so clients can show them in a highlighted lane and get them
+// even when the feed is sampled or truncated. The lane carries at most
+// PRIORITY_LANE_MAX comments, the newest, and the index keeps the stream's
+// last PRIORITY_LANE_RETAIN. PRIORITY_LANE turns it off. The role comes from
+// the trusted caller, like every privileged role.
+var (
+	priorityLane       bool
+	priorityLaneMax    int
+	priorityLaneRetain int64
+)
+
+func loadPriorityConfig() {
+	priorityLane = envBool("PRIORITY_LANE", true)
+	priorityLaneMax = envInt("PRIORITY_LANE_MAX", 50)
+	if priorityLaneMax < 1 {
+		priorityLaneMax = 50
+	}
+	priorityLaneRetain = int64(envInt("PRIORITY_LANE_RETAIN", 1000))
+	if priorityLaneRetain < int64(priorityLaneMax) {
+		priorityLaneRetain = int64(priorityLaneMax)
+	}
+}
+
+// trackPriority adds a priority comment to the lane index as part of the
+// transaction that stores it, so no poll sees one without the other
+func trackPriority(ctx context.Context, pipe redis.Pipeliner, streamID int64, cmt *Comment) {
+	key := priorityKey(streamID)
+	pipe.ZAdd(ctx, key, &redis.Z{Score: float64(cmt.Timestamp), Member: strconv.FormatInt(cmt.ID, 10)})
+	pipe.ZRemRangeByRank(ctx, key, 0, -priorityLaneRetain-1)
+}
+
+// readPriorityLane returns the newest priority comments with timestamps in
+// [min, max]. Comments deleted since are skipped.
+func readPriorityLane(ctx context.Context, streamID, min, max, now int64) []Comment {
+	return readPriorityComments(ctx, streamID, min, max, now, priorityLaneMax)
+}
+
+// readPriorityComments returns up to limit of the newest priority comments
+// in min..max, oldest first
+func readPriorityComments(ctx context.Context, streamID, min, max, now int64, limit int) []Comment {
+	ids, err := rdb.ZRevRangeByScore(ctx, priorityKey(streamID), &redis.ZRangeBy{
+		Min:   strconv.FormatInt(min, 10),
+		Max:   strconv.FormatInt(max, 10),
+		Count: int64(limit),
+	}).Result()
+	if err != nil || len(ids) == 0 {
+		if err != nil {
+			log.Printf("[GO] Stream %d: Error loading priority comments: %v", streamID, err)
+		}
+		return nil
+	}
+	data, err := rdb.HMGet(ctx, commentDataKey(streamID), ids...).Result()
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error loading priority comments: %v", streamID, err)
+		return nil
+	}
+	var lane []Comment
+	for i := len(data) - 1; i >= 0; i-- {
+		s, ok := data[i].(string)
+		if !ok {
+			continue
+		}
+		var cmt Comment
+		if json.Unmarshal([]byte(s), &cmt) == nil && !isExpired(cmt, now) {
+			lane = append(lane, cmt)
+		}
+	}
+	sortByTime(lane)
+	return lane
+}
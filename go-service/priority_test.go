@@ -0,0 +1,68 @@
+package main
+
+import "testing"
+
+// priorityMessages lists the messages in a poll response's priority lane
+func priorityMessages(resp map[string]interface{}) []string {
+	return messages(map[string]interface{}{"comments": resp["priority"]})
+}
+
+func TestPriorityLane(t *testing.T) {
+	resetRedis(t)
+	expectStatus(t, post(t, 1, "v1", "alice", "hello"), 200)
+	expectStatus(t, post(t, 1, "s1", "streamer", "welcome all", asRole(roleStreamer)...), 200)
+	expectStatus(t, post(t, 1, "m1", "mod", "be nice", asRole(roleModerator)...), 200)
+	// A role claimed without the API key is ignored
+	expectStatus(t, post(t, 1, "v2", "bob", "i am a mod", "X-Viewer-Role", roleModerator), 200)
+	nextSecond()
+
+	resp := poll(t, 1, "v3", 0)
+	if got := priorityMessages(resp); len(got) != 2 || got[0] != "welcome all" || got[1] != "be nice" {
+		t.Fatalf("priority lane = %v, want the streamer's and moderator's comments", got)
+	}
+	for _, c := range resp["comments"].([]interface{}) {
+		cmt := c.(map[string]interface{})
+		want := cmt["username"] == "streamer" || cmt["username"] == "mod"
+		if (cmt["priority"] == true) != want {
+			t.Fatalf("comment %q priority = %v, want %v", cmt["message"], cmt["priority"], want)
+		}
+	}
+	if got := messages(resp); len(got) != 4 {
+		t.Fatalf("feed = %v, want all four comments in the normal feed too", got)
+	}
+}
+
+func TestPriorityLaneSurvivesTruncation(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &pollMaxComments, 2)
+	w := post(t, 1, "m1", "mod", "be nice", asRole(roleModerator)...)
+	expectStatus(t, w, 200)
+	ts := int64(decode(t, w)["comment"].(map[string]interface{})["timestamp"].(float64))
+	for i := int64(1); i <= 3; i++ {
+		saveAt(t, ts+i, 100+i)
+	}
+	nextSecond()
+
+	resp := poll(t, 1, "v3", ts-1)
+	if resp["truncated"] != true {
+		t.Fatalf("feed = %v, want it truncated", messages(resp))
+	}
+	for _, msg := range messages(resp) {
+		if msg == "be nice" {
+			t.Fatalf("truncated feed = %v, want the moderator's comment cut", messages(resp))
+		}
+	}
+	if got := priorityMessages(resp); len(got) != 1 || got[0] != "be nice" {
+		t.Fatalf("priority lane = %v, want the moderator's comment", got)
+	}
+}
+
+func TestPriorityLaneOff(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &priorityLane, false)
+	expectStatus(t, post(t, 1, "m1", "mod", "be nice", asRole(roleModerator)...), 200)
+	nextSecond()
+	if resp := poll(t, 1, "v3", 0); resp["priority"] != nil {
+		t.Fatalf("priority lane = %v with the lane off", resp["priority"])
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Profanity is graded in tiers, each with its own word list and an action
+// streams can tune in their modes (profanity_mild, profanity_severe,
+// profanity_extreme = allow|mask|reject|ban). data.txt is the severe list;
+// the backend can extend any tier through the profanity:words:<tier> sets and
+// bumps profanity:version so every replica reloads.
+const (
+	tierMild    = "mild"
+	tierSevere  = "severe"
+	tierExtreme = "extreme"
+)
+
+var profanityTiers = []string{tierMild, tierSevere, tierExtreme}
+
+// Profanity actions, weakest first
+const (
+	profanityAllow  = "allow"
+	profanityMask   = "mask"
+	profanityReject = "reject"
+	profanityBan    = "ban"
+)
+
+var profanityActionRank = map[string]int{profanityAllow: 0, profanityMask: 1, profanityReject: 2, profanityBan: 3}
+
+var defaultProfanityActions = map[string]string{
+	tierMild:    profanityMask,
+	tierSevere:  profanityReject,
+	tierExtreme: profanityBan,
+}
+
+// profanityBanDuration is how long the ban action bans for (PROFANITY_BAN_DURATION)
+var profanityBanDuration time.Duration
+
+// profanityFilter maps normalized words to their tier. Lookups are one map
+// access per word; the map is rebuilt only when the lists change.
+type profanityFilter struct {
+	mu      sync.RWMutex
+	words   map[string]string
+	version string
+}
+
+var profanity = &profanityFilter{words: map[string]string{}}
+
+func loadProfanityConfig() {
+	profanityBanDuration = time.Duration(envInt("PROFANITY_BAN_DURATION", 600)) * time.Second
+	profanity.reload(ctx)
+}
+
+// reload rebuilds the word map from data.txt and the Redis tier sets
+func (f *profanityFilter) reload(ctx context.Context) {
+	version, err := rdb.Get(ctx, profanityVersionKey()).Result()
+	if err != nil && err != redis.Nil {
+		log.Printf("[GO] Error checking profanity lists: %v", err)
+		return
+	}
+
+	words := map[string]string{}
+	for _, w := range persianSwear.swearWords {
+		words[persianSwear.clearWord(w)] = tierSevere
+	}
+	for _, tier := range profanityTiers {
+		extra, err := rdb.SMembers(ctx, profanityWordsKey(tier)).Result()
+		if err != nil {
+			log.Printf("[GO] Error loading %s profanity list: %v", tier, err)
+			return
+		}
+		for _, w := range extra {
+			// A word listed in several tiers counts as the harshest
+			cleared := persianSwear.clearWord(w)
+			if cleared != "" && tierIndex(tier) >= tierIndex(words[cleared]) {
+				words[cleared] = tier
+			}
+		}
+	}
+
+	f.mu.Lock()
+	f.words, f.version = words, version
+	f.mu.Unlock()
+	log.Printf("[GO] Profanity lists loaded: %d words", len(words))
+}
+
+// refreshIfChanged reloads the lists when profanity:version has moved
+func (f *profanityFilter) refreshIfChanged(ctx context.Context) {
+	version, err := rdb.Get(ctx, profanityVersionKey()).Result()
+	if err != nil && err != redis.Nil {
+		return
+	}
+	f.mu.RLock()
+	changed := version != f.version
+	f.mu.RUnlock()
+	if changed {
+		f.reload(ctx)
+	}
+}
+
+// runProfanityRefresher polls for list changes
+func runProfanityRefresher(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	jobs.setRunning("profanity_refresher", true)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			profanity.refreshIfChanged(ctx)
+		}
+	}
+}
+
+func tierIndex(tier string) int {
+	for i, t := range profanityTiers {
+		if t == tier {
+			return i
+		}
+	}
+	return -1
+}
+
+// tierOf returns the tier of a single word, or "" if it is clean
+func (f *profanityFilter) tierOf(word string) string {
+	cleared := persianSwear.clearWord(word)
+	if cleared == "" {
+		return ""
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.words[cleared]
+}
+
+// highestTier returns the harshest tier found in text, or ""
+func (f *profanityFilter) highestTier(text string) string {
+	highest := ""
+	for _, word := range strings.Split(text, " ") {
+		if tier := f.tierOf(word); tierIndex(tier) > tierIndex(highest) {
+			highest = tier
+		}
+	}
+	return highest
+}
+
+// profanityVerdict is the outcome of checking a message
+type profanityVerdict struct {
+	Action  string // strongest action triggered
+	Tier    string // tier that triggered it
+	Message string // the message, with masked words replaced
+}
+
+// check applies a stream's tier actions to message
+func (f *profanityFilter) check(message string, actions map[string]string) profanityVerdict {
+	verdict := profanityVerdict{Action: profanityAllow, Message: message}
+	words := strings.Split(message, " ")
+	masked := false
+	for i, word := range words {
+		tier := f.tierOf(word)
+		if tier == "" {
+			continue
+		}
+		action := actions[tier]
+		if action == profanityMask {
+			words[i] = strings.Repeat("*", len([]rune(word)))
+			masked = true
+		}
+		if profanityActionRank[action] > profanityActionRank[verdict.Action] {
+			verdict.Action, verdict.Tier = action, tier
+		}
+	}
+	if masked {
+		verdict.Message = strings.Join(words, " ")
+	}
+	return verdict
+}
+
+// parseProfanityActions reads a stream's per-tier overrides from its modes
+func parseProfanityActions(fields map[string]string) map[string]string {
+	actions := make(map[string]string, len(defaultProfanityActions))
+	for tier, action := range defaultProfanityActions {
+		actions[tier] = action
+		if v := fields["profanity_"+tier]; v != "" {
+			if _, ok := profanityActionRank[v]; ok {
+				actions[tier] = v
+			}
+		}
+	}
+	return actions
+}
+
+// banForProfanity applies the ban action to a poster
+func banForProfanity(ctx context.Context, streamID int64, viewerID, username string) {
+	var until int64
+	if profanityBanDuration > 0 {
+		until = time.Now().Add(profanityBanDuration).UnixMilli()
+	}
+	if err := storeBan(ctx, streamID, ModerationTarget{Username: username, ViewerID: viewerID}, until, false); err != nil {
+		log.Printf("[GO] Stream %d: Error auto-banning %s: %v", streamID, username, err)
+		return
+	}
+	log.Printf("[GO] Stream %d: Auto-banned %s for profanity (until %d)", streamID, username, until)
+	recordAudit(ctx, streamID, AuditEntry{Action: "ban", Actor: systemActor, Target: &ModerationTarget{Username: username, ViewerID: viewerID}, Reason: "profanity", Details: map[string]interface{}{"until": until}})
+	publishModEvent(ctx, streamID, map[string]interface{}{"type": "auto_ban", "username": username, "viewer_id": viewerID, "until": until})
+}
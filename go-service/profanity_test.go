@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestProfanityTierActions(t *testing.T) {
+	resetRedis(t)
+	withProfanityWords(t, tierMild, "heck")
+	withProfanityWords(t, tierSevere, "darn")
+	withProfanityWords(t, tierExtreme, "blast")
+
+	// Mild words are masked by default
+	w := post(t, 1, "v1", "alice", "oh heck no")
+	expectStatus(t, w, 200)
+	if msg := decode(t, w)["comment"].(map[string]interface{})["message"]; msg != "oh **** no" {
+		t.Fatalf("mild: message = %q, want it masked", msg)
+	}
+
+	// Severe words are rejected, naming the tier
+	w = post(t, 1, "v1", "alice", "darn it")
+	expectStatus(t, w, 403)
+	if resp := decode(t, w); resp["reason"] != "profanity" || resp["tier"] != tierSevere {
+		t.Fatalf("severe: %v, want profanity in the severe tier", resp)
+	}
+	expectStatus(t, post(t, 1, "v1", "alice", "still here"), 200)
+
+	// Extreme words ban the author
+	w = post(t, 1, "v2", "bob", "blast you")
+	expectStatus(t, w, 403)
+	if resp := decode(t, w); resp["tier"] != tierExtreme {
+		t.Fatalf("extreme: %v, want the extreme tier", resp)
+	}
+	if w := post(t, 1, "v2", "bob", "hello?"); w.Code != 403 || decode(t, w)["reason"] != "banned" {
+		t.Fatalf("after an extreme word: %d %s, want banned", w.Code, w.Body.String())
+	}
+}
+
+func TestProfanityPerStreamActions(t *testing.T) {
+	resetRedis(t)
+	withProfanityWords(t, tierMild, "heck")
+	withProfanityWords(t, tierSevere, "darn")
+	rdb.HSet(ctx, modesKey(2), "profanity_mild", profanityReject, "profanity_severe", profanityAllow)
+
+	if w := post(t, 2, "v1", "alice", "oh heck"); w.Code != 403 || decode(t, w)["tier"] != tierMild {
+		t.Fatalf("mild on a strict stream: %d %s, want rejected", w.Code, w.Body.String())
+	}
+	w := post(t, 2, "v1", "alice", "darn it")
+	expectStatus(t, w, 200)
+	if msg := decode(t, w)["comment"].(map[string]interface{})["message"]; msg != "darn it" {
+		t.Fatalf("allowed severe word: message = %q", msg)
+	}
+	// Stream 1 keeps the defaults
+	expectStatus(t, post(t, 1, "v1", "alice", "darn it"), 403)
+}
+
+func TestProfanityListsReloadOnChange(t *testing.T) {
+	resetRedis(t)
+	withProfanityWords(t, tierSevere, "darn")
+	if profanity.tierOf("gosh") != "" {
+		t.Fatal("gosh is listed before it was added")
+	}
+	rdb.SAdd(ctx, profanityWordsKey(tierSevere), "gosh")
+	profanity.refreshIfChanged(ctx)
+	if profanity.tierOf("gosh") != "" {
+		t.Fatal("the lists reloaded without a version bump")
+	}
+	rdb.Incr(ctx, profanityVersionKey())
+	profanity.refreshIfChanged(ctx)
+	if profanity.tierOf("gosh") != tierSevere {
+		t.Fatal("a new word wasn't picked up after the version bump")
+	}
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// commentRejection describes why a comment was refused, in the shape
+// returned to clients
+type commentRejection struct {
+	Status     int
+	Reason     string
+	Message    string
+	RetryAfter int
+	Tier       string                 // profanity tier, for profanity rejections
+	Characters []string               // offending characters, for script rejections
+	Limit      map[string]interface{} // limits the comment broke
+}
+
+func (r *commentRejection) body() gin.H {
+	body := gin.H{"error": r.Message, "reason": r.Reason, "status": postRejected}
+	if r.RetryAfter > 0 {
+		body["retry_after"] = r.RetryAfter
+	}
+	if r.Tier != "" {
+		body["tier"] = r.Tier
+	}
+	if len(r.Characters) > 0 {
+		body["characters"] = r.Characters
+	}
+	if len(r.Limit) > 0 {
+		body["limit"] = r.Limit
+	}
+	return body
+}
+
+// respondRejection writes a rejection, setting Retry-After for throttles
+func respondRejection(c *gin.Context, r *commentRejection) {
+	if r.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(r.RetryAfter))
+	}
+	c.JSON(r.Status, r.body())
+}
+
+// Post outcomes as told to the poster, so their UI can show the comment as
+// sent, awaiting the chat reopening or Redis (see writebuffer.go), or blocked. Comments hidden by a shadow
+// ban report postPublished; only the filter stats count them as hidden.
+const (
+	postPublished = "published"
+	postPending   = "pending"
+	postBuffered  = "buffered"
+	postRejected  = "rejected"
+)
+
+// postStatus returns the outcome of a post that wasn't rejected, and the
+// reason when it isn't simply published
+func postStatus(cmt *Comment) (string, string) {
+	if cmt.buffered {
+		return postPending, "chat_disabled"
+	}
+	if cmt.deferred {
+		return postBuffered, "storage_unavailable"
+	}
+	return postPublished, ""
+}
+
+// commentOrigin describes where a submitted comment came from
+type commentOrigin struct {
+	Source  string // integration that bridged the comment, "" for direct posts
+	IP      string // client address, "" when not known (e.g. ingested)
+	Role    string // poster's role as asserted by a trusted caller
+	Tier    string // poster's subscriber tier as asserted by a trusted caller
+	Country string // poster's resolved country, "" when not known
+	// Verified is set when a trusted caller vouched for the viewer_id and
+	// username, e.g. the backend posting for a signed-in user. Only verified
+	// authors have a reputation (see reputation.go).
+	Verified bool
+}
+
+// trusted reports whether the poster skips checks meant for anonymous
+// viewers: moderators and bridged integrations
+func (o commentOrigin) trusted() bool {
+	return o.Source != "" || isPrivileged(o.Role)
+}
+
+// submitComment runs a comment through the stream's modes and filters and
+// publishes it. Every write path (post-comment, ingest) goes through here so
+// they enforce the same rules, and records which filter had the last word
+// (see filterstats.go). A non-nil error means storage failed.
+func submitComment(ctx context.Context, req PostCommentRequest, origin commentOrigin) (*Comment, *commentRejection, error) {
+	cmt, rejection, err := processComment(ctx, req, origin)
+	if err == nil {
+		recordFilterOutcome(ctx, int64(req.StreamID), cmt, rejection)
+	}
+	return cmt, rejection, err
+}
+
+// processComment is submitComment without the outcome bookkeeping
+func processComment(ctx context.Context, req PostCommentRequest, origin commentOrigin) (*Comment, *commentRejection, error) {
+	if req.ExpiresIn < 0 || req.ExpiresIn > int64(maxCommentLifetime/time.Second) {
+		return nil, &commentRejection{Status: 400, Reason: "invalid_expiry", Message: fmt.Sprintf("expires_in must be between 0 and %d seconds", int64(maxCommentLifetime/time.Second))}, nil
+	}
+
+	minTier := strings.ToLower(strings.TrimSpace(req.MinTier))
+	if minTier != "" && !isTier(minTier) {
+		return nil, &commentRejection{Status: 400, Reason: "invalid_tier", Message: "min_tier is not a subscriber tier"}, nil
+	}
+	platform, ok := normalizePlatform(req.Platform)
+	if !ok {
+		return nil, &commentRejection{Status: 400, Reason: "invalid_platform", Message: "platform must be one of " + strings.Join(commentPlatforms, ", ")}, nil
+	}
+	access := tierRank(origin.Tier)
+	if isPrivileged(origin.Role) {
+		access = tierAccessAll
+	}
+	if access < tierRank(minTier) {
+		return nil, &commentRejection{Status: 403, Reason: "tier_required", Message: "you can only post for tiers you are subscribed to"}, nil
+	}
+
+	if req.Username != "" {
+		name, reason := normalizeUsername(req.Username)
+		if reason != "" {
+			return nil, &commentRejection{Status: 400, Reason: "invalid_username", Message: reason}, nil
+		}
+		req.Username = name
+	}
+	if req.Username == "" {
+		if !guestNames || req.ViewerID == "" {
+			return nil, &commentRejection{Status: 400, Reason: "username_required", Message: "username is required"}, nil
+		}
+		name, err := assignGuestName(ctx, int64(req.StreamID), req.ViewerID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("assign guest name: %w", err)
+		}
+		req.Username = name
+	} else if isGuestName(req.Username) && !ownsGuestName(ctx, int64(req.StreamID), req.ViewerID, req.Username) {
+		return nil, &commentRejection{Status: 403, Reason: "reserved_name", Message: "this username is reserved for guests"}, nil
+	}
+
+	if isReservedName(req.Username) {
+		return nil, &commentRejection{Status: 403, Reason: "reserved_name", Message: "this username is reserved"}, nil
+	}
+
+	if isBanned(ctx, int64(req.StreamID), req.ViewerID, req.Username) {
+		return nil, &commentRejection{Status: 403, Reason: "banned", Message: "you are banned from this chat"}, nil
+	}
+
+	if !origin.trusted() && geoBlocked(ctx, int64(req.StreamID), origin.Country) {
+		return nil, &commentRejection{Status: 403, Reason: "geo_blocked", Message: "posting isn't available in your country for this stream"}, nil
+	}
+
+	closed := !chatAllowed(ctx, int64(req.StreamID))
+	if closed && chatDisabledPolicy == chatDisabledReject {
+		return nil, &commentRejection{Status: 403, Reason: "chat_disabled", Message: "chat is disabled"}, nil
+	}
+
+	// A shadow-banned poster is answered as if the comment went out, but it
+	// is never stored, so nobody else sees it
+	if !origin.trusted() && isShadowBanned(ctx, int64(req.StreamID), req.ViewerID, req.Username) {
+		cmt := Comment{Username: req.Username, Message: req.Message, Platform: platform, shadowed: true, buffered: closed, quotaLeft: -1}
+		if err := stampComment(ctx, int64(req.StreamID), &cmt, time.Duration(req.ExpiresIn)*time.Second); err != nil {
+			return nil, nil, err
+		}
+		return &cmt, nil, nil
+	}
+
+	// Strip invisible characters first so later filters see the real text
+	message, reason := sanitizeMessage(req.Message)
+	if reason != "" {
+		return nil, &commentRejection{Status: 400, Reason: "invalid_characters", Message: reason}, nil
+	}
+	message = normalizeMessage(message)
+
+	// Expand :shortcode: emoji and resolve custom stream emotes
+	customEmotes, err := loadStreamEmotes(ctx, int64(req.StreamID))
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error loading custom emotes: %v", int64(req.StreamID), err)
+		customEmotes = nil
+	}
+	message, emotes := expandShortcodes(message, customEmotes)
+
+	checkRaid(ctx, int64(req.StreamID))
+	modes, err := loadStreamModes(ctx, int64(req.StreamID))
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error loading chat modes: %v", int64(req.StreamID), err)
+		modes.ProfanityActions = defaultProfanityActions
+	}
+	modes = applyModProfile(modes, currentModProfile(ctx, int64(req.StreamID)))
+
+	// Reputation relaxes the modes for trusted authors and tightens them for
+	// poorly received ones. Anyone can claim a name, so only authors a
+	// trusted caller vouched for have one.
+	tier := reputationNormal
+	if origin.Verified {
+		tier = authorTier(ctx, int64(req.StreamID), req.Username)
+		modes = applyReputation(modes, tier)
+	}
+
+	if !origin.trusted() {
+		if remaining := newViewerRemaining(ctx, int64(req.StreamID), req.ViewerID, modes.NewViewerWait); remaining > 0 {
+			return nil, &commentRejection{Status: 403, Reason: "too_new", Message: "you need to watch a little longer before you can chat", RetryAfter: int((remaining + time.Second - 1) / time.Second)}, nil
+		}
+		if chars := modes.Scripts.disallowedCharacters(message, customEmotes); len(chars) > 0 {
+			return nil, &commentRejection{Status: 403, Reason: "script_not_allowed", Message: scriptRejectionMessage(chars), Characters: chars}, nil
+		}
+	}
+
+	verdict := profanity.check(message, modes.ProfanityActions)
+	switch verdict.Action {
+	case profanityBan:
+		banForProfanity(ctx, int64(req.StreamID), req.ViewerID, req.Username)
+		return nil, &commentRejection{Status: 403, Reason: "profanity", Tier: verdict.Tier, Message: "message contains inappropriate language"}, nil
+	case profanityReject:
+		return nil, &commentRejection{Status: 403, Reason: "profanity", Tier: verdict.Tier, Message: "message contains inappropriate language"}, nil
+	}
+	message = verdict.Message
+	if modes.EmoteOnly && !isEmoteOnly(message, customEmotes) {
+		return nil, &commentRejection{Status: 403, Reason: "emote_only", Message: "chat is in emote-only mode: message may only contain emotes"}, nil
+	}
+	if !modes.EmoteOnly && !origin.trusted() {
+		var ok bool
+		if message, ok = modes.Emoji.apply(message, customEmotes); !ok {
+			return nil, &commentRejection{Status: 403, Reason: "too_many_emoji", Message: "message contains too many emoji", Limit: modes.Emoji.limits()}, nil
+		}
+	}
+
+	// Links under a repeat cooldown are refused whoever posts them
+	var links []string
+	if modes.LinkRepeatThreshold > 0 || origin.Role == roleStreamer {
+		links = extractLinks(message)
+	}
+	if modes.LinkRepeatThreshold > 0 && !isPrivileged(origin.Role) {
+		if link, retryAfter := blockedLink(ctx, int64(req.StreamID), links); link != "" {
+			return nil, &commentRejection{Status: 429, Reason: "link_cooldown", Message: "this link is being posted too often, please try again later", RetryAfter: retryAfter}, nil
+		}
+	}
+
+	poster := req.ViewerID
+	if poster == "" {
+		poster = req.Username
+	}
+	similarity := modes.SimilarityThreshold > 0 && !isPrivileged(origin.Role) && !origin.trusted()
+	if similarity {
+		if ok, retryAfter := similarMessage(ctx, int64(req.StreamID), poster, message, modes.SimilarityThreshold); !ok {
+			return nil, &commentRejection{Status: 429, Reason: "similar_message", Message: "this message is too similar to one you just posted", RetryAfter: retryAfter}, nil
+		}
+	}
+
+	// Payloads posted across many streams are refused in all of them
+	var fingerprint string
+	if !origin.trusted() {
+		fingerprint = messageFingerprint(message)
+		if retryAfter := campaignRetryAfter(ctx, int64(req.StreamID), fingerprint); retryAfter > 0 {
+			return nil, &commentRejection{Status: 429, Reason: "spam_campaign", Message: "this message is being posted across many streams, please try again later", RetryAfter: retryAfter}, nil
+		}
+	}
+
+	var quote *QuotedComment
+	if req.Quote != 0 {
+		var rejection *commentRejection
+		quote, rejection, err = quoteComment(ctx, int64(req.StreamID), int64(req.Quote), access)
+		if err != nil || rejection != nil {
+			return nil, rejection, err
+		}
+		// A quote can't carry gated text to viewers who couldn't read it
+		minTier = stricterTier(minTier, quote.minTier)
+	}
+
+	if tier != reputationTrusted {
+		if allowed, retryAfter := checkCommentRate(ctx, int64(req.StreamID), req, origin); !allowed {
+			return nil, &commentRejection{Status: 429, Reason: "rate_limited", Message: "you are posting too fast, please slow down", RetryAfter: retryAfter}, nil
+		}
+	}
+
+	if allowed, retryAfter := checkFloodSlot(ctx, int64(req.StreamID), modes); !allowed {
+		return nil, &commentRejection{Status: 429, Reason: "flood", Message: "chat is receiving too many comments, please try again shortly", RetryAfter: retryAfter}, nil
+	}
+
+	// Slow-mode is checked last so rejected messages don't start a cooldown
+	if modes.SlowMode > 0 {
+		viewer := req.ViewerID
+		if viewer == "" {
+			viewer = req.Username
+		}
+		allowed, retryAfter, slowErr := takeSlowModeSlot(ctx, int64(req.StreamID), viewer, modes.SlowMode)
+		if slowErr != nil {
+			log.Printf("[GO] Stream %d: Error checking slow-mode: %v", int64(req.StreamID), slowErr)
+		}
+		if !allowed {
+			return nil, &commentRejection{Status: 429, Reason: "slow_mode", Message: "slow mode is on, please wait before posting again", RetryAfter: retryAfter}, nil
+		}
+	}
+
+	quotaLeft := -1
+	if modes.CommentQuota > 0 && !origin.trusted() {
+		left, ok := takeQuota(ctx, int64(req.StreamID), poster, modes.CommentQuota)
+		if !ok {
+			return nil, &commentRejection{Status: 403, Reason: "quota_exhausted", Message: "you've posted as many comments as this stream allows"}, nil
+		}
+		quotaLeft = left
+	}
+	dedup := modes.DedupWindow > 0 && !isPrivileged(origin.Role) && quote == nil && req.ExpiresIn == 0 && !closed
+	finalTier := stricterTier(minTier, modes.VisibleTier)
+	if dedup {
+		collapsed, err := collapseDuplicate(ctx, int64(req.StreamID), poster, message, finalTier, modes.DedupWindow)
+		if err != nil {
+			log.Printf("[GO] Stream %d: Error checking duplicates: %v", int64(req.StreamID), err)
+		}
+		if collapsed != nil {
+			collapsed.quotaLeft = quotaLeft
+			return collapsed, nil, nil
+		}
+	}
+
+	cmt := Comment{
+		Username:  req.Username,
+		Message:   message,
+		Emotes:    emotes,
+		Source:    origin.Source,
+		Platform:  platform,
+		Priority:  priorityLane && isPrivileged(origin.Role),
+		NameColor: loadNameColor(ctx, req.ViewerID),
+		Quote:     quote,
+		MinTier:   finalTier,
+		Verified:  origin.Verified,
+		quotaLeft: quotaLeft,
+	}
+	if verdict.Action == profanityMask {
+		cmt.filtered = verdict.Tier
+	}
+	if closed {
+		held, err := bufferComment(ctx, int64(req.StreamID), req.ViewerID, &cmt, time.Duration(req.ExpiresIn)*time.Second)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !held {
+			return nil, &commentRejection{Status: 403, Reason: "chat_disabled", Message: "chat is disabled"}, nil
+		}
+	} else {
+		rejection, err := publishOrQueue(ctx, int64(req.StreamID), req.ViewerID, origin.trusted(), &cmt, time.Duration(req.ExpiresIn)*time.Second)
+		if err != nil || rejection != nil {
+			return nil, rejection, err
+		}
+	}
+	countFloodComment(ctx, int64(req.StreamID), modes)
+	if cmt.deferred {
+		// The rest needs the comment's timestamp and Redis
+		return &cmt, nil, nil
+	}
+	if similarity {
+		rememberMessage(ctx, int64(req.StreamID), poster, message, cmt.Timestamp)
+	}
+	recordEmoteUsage(ctx, int64(req.StreamID), cmt.Emotes)
+	recordCampaign(ctx, int64(req.StreamID), fingerprint, message)
+	if dedup {
+		if err := registerDuplicate(ctx, int64(req.StreamID), poster, &cmt, modes.DedupWindow); err != nil {
+			log.Printf("[GO] Stream %d: Error registering comment for deduplication: %v", int64(req.StreamID), err)
+		}
+	}
+	if origin.Verified {
+		recordPostReputation(ctx, int64(req.StreamID), req.Username, cmt.Timestamp)
+	}
+	switch {
+	case origin.Role == roleStreamer:
+		addStreamLinks(ctx, int64(req.StreamID), links)
+	case !isPrivileged(origin.Role):
+		recordLinks(ctx, int64(req.StreamID), poster, links, modes.LinkRepeatThreshold)
+	}
+	return &cmt, nil, nil
+}
+
+// publishComment allocates an ID and timestamp for cmt and stores it
+func publishComment(ctx context.Context, streamID int64, viewerID string, cmt *Comment, lifetime time.Duration) error {
+	if err := stampComment(ctx, streamID, cmt, lifetime); err != nil {
+		return err
+	}
+	return storeComment(ctx, streamID, viewerID, cmt)
+}
+
+// stampComment gives cmt its ID, timestamp and expiry
+func stampComment(ctx context.Context, streamID int64, cmt *Comment, lifetime time.Duration) error {
+	id, err := allocateCommentID(ctx, streamID)
+	if err != nil {
+		return err
+	}
+	cmt.ID = id
+	cmt.Timestamp = time.Now().UnixMilli()
+	if lifetime > 0 {
+		cmt.ExpiresAt = cmt.Timestamp + lifetime.Milliseconds()
+	}
+	return nil
+}
+
+// storeComment writes a stamped comment to the feed and wakes live clients
+func storeComment(ctx context.Context, streamID int64, viewerID string, cmt *Comment) error {
+	payload, err := json.Marshal(cmt)
+	if err != nil {
+		return fmt.Errorf("encode comment: %w", err)
+	}
+
+	if err := store.SaveComment(ctx, streamID, viewerID, cmt, payload); err != nil {
+		return err
+	}
+	touchStream(ctx, streamID)
+	notifyLive(ctx, streamID)
+
+	log.Printf("[GO] Stream %d: Published comment %d", streamID, cmt.ID)
+	return nil
+}
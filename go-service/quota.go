@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// A comment quota caps how many comments one viewer may post in a stream's
+// broadcast, however slowly they post them. COMMENT_QUOTA sets it for every
+// stream (0 = no quota) and streams override it with comment_quota in their
+// modes. Each broadcast starts everyone's quota afresh; counts outlive a
+// broadcast by at most COMMENT_QUOTA_TTL. Moderators and bridged
+// integrations have no quota.
+var (
+	commentQuota    int
+	commentQuotaTTL time.Duration
+)
+
+func loadQuotaConfig() {
+	commentQuota = envInt("COMMENT_QUOTA", 0)
+	commentQuotaTTL = time.Duration(envInt("COMMENT_QUOTA_TTL", 12*3600)) * time.Second
+}
+
+// quotaScript counts a comment against a quota unless it is used up.
+// KEYS: counter. ARGV: quota, ttl (ms). Returns the comments left after this
+// one, or -1 when there were none left.
+var quotaScript = redis.NewScript(`
+local used = tonumber(redis.call('GET', KEYS[1]) or '0')
+if used >= tonumber(ARGV[1]) then
+	return -1
+end
+used = redis.call('INCR', KEYS[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return tonumber(ARGV[1]) - used
+`)
+
+// takeQuota spends one of a viewer's comments, returning how many are left
+// and false when the quota is used up
+func takeQuota(ctx context.Context, streamID int64, viewer string, quota int) (int, bool) {
+	var session int64
+	if status, err := loadStreamStatus(ctx, streamID); err == nil {
+		session = status.StartedAt
+	}
+	left, err := quotaScript.Run(ctx, rdb, []string{quotaKey(streamID, session, viewer)}, quota, commentQuotaTTL.Milliseconds()).Int()
+	if err != nil {
+		// Fail open, like the rate limits
+		log.Printf("[GO] Stream %d: Error checking comment quota: %v", streamID, err)
+		return quota, true
+	}
+	if left < 0 {
+		return 0, false
+	}
+	return left, true
+}
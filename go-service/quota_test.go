@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCommentQuotaConsumptionAndExhaustion(t *testing.T) {
+	resetRedis(t)
+	rdb.HSet(ctx, modesKey(1), "comment_quota", "3")
+
+	for want := 2; want >= 0; want-- {
+		w := post(t, 1, "v1", "alice", fmt.Sprintf("comment %d", want))
+		expectStatus(t, w, 200)
+		if left := decode(t, w)["quota_remaining"]; left != float64(want) {
+			t.Fatalf("quota_remaining = %v, want %d", left, want)
+		}
+	}
+	w := post(t, 1, "v1", "alice", "one too many")
+	if w.Code != 403 || decode(t, w)["reason"] != "quota_exhausted" {
+		t.Fatalf("over the quota: %d %s, want 403 quota_exhausted", w.Code, w.Body.String())
+	}
+
+	// Other viewers and moderators have their own or none
+	expectStatus(t, post(t, 1, "v2", "bob", "mine"), 200)
+	for i := 0; i < 4; i++ {
+		w := post(t, 1, "mod", "mod", fmt.Sprintf("mod %d", i), asRole(roleModerator)...)
+		expectStatus(t, w, 200)
+		if _, ok := decode(t, w)["quota_remaining"]; ok {
+			t.Fatal("moderator post reported a quota")
+		}
+	}
+}
+
+func TestCommentQuotaResetsPerBroadcast(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &commentQuota, 1)
+	lifecycle(t, "start", nil)
+	expectStatus(t, post(t, 1, "v1", "alice", "first"), 200)
+	expectStatus(t, post(t, 1, "v1", "alice", "second"), 403)
+
+	lifecycle(t, "end", nil)
+	time.Sleep(2 * time.Millisecond) // a new broadcast is told apart by its start time
+	if resp := lifecycle(t, "start", nil); resp["status"] != float64(200) {
+		t.Fatalf("restart: %v", resp)
+	}
+	w := post(t, 1, "v1", "alice", "new broadcast")
+	expectStatus(t, w, 200)
+	if left := decode(t, w)["quota_remaining"]; left != float64(0) {
+		t.Fatalf("quota_remaining = %v, want 0", left)
+	}
+
+	// Streams can turn the global quota off
+	rdb.HSet(ctx, modesKey(1), "comment_quota", "0")
+	expectStatus(t, post(t, 1, "v1", "alice", "unlimited"), 200)
+}
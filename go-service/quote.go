@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// A comment can quote another one. The quote is a snapshot of the original
+// (its author and up to QUOTE_MAX_LENGTH characters of its text) copied into
+// the new comment when it is posted, so it keeps showing what was said even
+// after the original expires or is deleted. It is a copy rather than a
+// reference: a threaded reply would point at the original and follow
+// whatever happens to it.
+var quoteMaxLength int
+
+func loadQuoteConfig() {
+	quoteMaxLength = envInt("QUOTE_MAX_LENGTH", 100)
+}
+
+// QuotedComment is the snapshot of a quoted comment
+type QuotedComment struct {
+	ID        int64             `json:"id"`
+	Username  string            `json:"username"`
+	Message   string            `json:"message"`
+	Timestamp int64             `json:"timestamp"`
+	Emotes    map[string]string `json:"emotes,omitempty"`
+	Truncated bool              `json:"truncated,omitempty"`
+
+	minTier string // the quoted comment's, carried over to the quoting one
+}
+
+// quoteComment snapshots a comment in the stream for quoting, returning a
+// rejection when there is nothing under that ID the poster (with the given
+// tier access) can quote
+func quoteComment(ctx context.Context, streamID, commentID int64, access int) (*QuotedComment, *commentRejection, error) {
+	notFound := &commentRejection{Status: 400, Reason: "quote_not_found", Message: "the quoted comment does not exist"}
+	data, err := rdb.HGet(ctx, commentDataKey(streamID), strconv.FormatInt(commentID, 10)).Result()
+	if err == redis.Nil {
+		return nil, notFound, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("load quoted comment %d: %w", commentID, err)
+	}
+	var cmt Comment
+	if err := json.Unmarshal([]byte(data), &cmt); err != nil || cmt.Type == commentTypeSystem || !canSee(cmt, access) {
+		return nil, notFound, nil
+	}
+	if cmt.ExpiresAt > 0 && cmt.ExpiresAt <= time.Now().UnixMilli() {
+		return nil, notFound, nil
+	}
+
+	// The snapshot quotes the comment itself, not whatever it quoted
+	q := &QuotedComment{ID: cmt.ID, Username: cmt.Username, Message: cmt.Message, Timestamp: cmt.Timestamp, minTier: cmt.MinTier}
+	if runes := []rune(q.Message); quoteMaxLength > 0 && len(runes) > quoteMaxLength {
+		q.Message = string(runes[:quoteMaxLength]) + "…"
+		q.Truncated = true
+	}
+	// Keep only the emotes the quoted text still uses
+	for code, url := range cmt.Emotes {
+		if strings.Contains(q.Message, ":"+code+":") {
+			if q.Emotes == nil {
+				q.Emotes = map[string]string{}
+			}
+			q.Emotes[code] = url
+		}
+	}
+	return q, nil, nil
+}
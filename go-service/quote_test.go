@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// postQuote posts a comment on stream 1 quoting another
+func postQuote(t *testing.T, viewerID, username, message string, quote int64) *httptest.ResponseRecorder {
+	t.Helper()
+	return request(t, http.MethodPost, "/post-comment", map[string]interface{}{
+		"stream_id": 1, "viewer_id": viewerID, "username": username, "message": message, "quote": quote,
+	})
+}
+
+func TestQuoteSurvivesDeletingTheOriginal(t *testing.T) {
+	resetRedis(t)
+	original := postedID(t, 1, "v1", "alice", "the original")
+	w := postQuote(t, "v2", "bob", "agreed", original)
+	expectStatus(t, w, 200)
+	quote := decode(t, w)["comment"].(map[string]interface{})["quote"].(map[string]interface{})
+	if quote["id"] != float64(original) || quote["username"] != "alice" || quote["message"] != "the original" {
+		t.Fatalf("quote = %v, want a snapshot of the original", quote)
+	}
+
+	if err := deleteComments(ctx, 1, []string{strconv.FormatInt(original, 10)}); err != nil {
+		t.Fatal(err)
+	}
+	nextSecond()
+	resp := poll(t, 1, "v3", 0)
+	list := resp["comments"].([]interface{})
+	if len(list) != 1 {
+		t.Fatalf("comments = %v, want only the quoting one", messages(resp))
+	}
+	quote = list[0].(map[string]interface{})["quote"].(map[string]interface{})
+	if quote["username"] != "alice" || quote["message"] != "the original" {
+		t.Fatalf("quote after deleting the original = %v", quote)
+	}
+
+	// The original is gone, so it can't be quoted again
+	w = postQuote(t, "v3", "carol", "me too", original)
+	if w.Code != 400 || decode(t, w)["reason"] != "quote_not_found" {
+		t.Fatalf("quoting a deleted comment: %d %s, want 400 quote_not_found", w.Code, w.Body.String())
+	}
+}
+
+func TestQuoteSnapshotIsTruncated(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &quoteMaxLength, 10)
+	original := postedID(t, 1, "v1", "alice", strings.Repeat("é", 15))
+
+	w := postQuote(t, "v2", "bob", "long", original)
+	expectStatus(t, w, 200)
+	quote := decode(t, w)["comment"].(map[string]interface{})["quote"].(map[string]interface{})
+	if quote["message"] != strings.Repeat("é", 10)+"…" || quote["truncated"] != true {
+		t.Fatalf("quote = %v, want the first 10 characters", quote)
+	}
+
+	// Quoting a quote snapshots only the quoting comment's own text
+	quoting := int64(decode(t, w)["comment"].(map[string]interface{})["id"].(float64))
+	w = postQuote(t, "v3", "carol", "nested", quoting)
+	expectStatus(t, w, 200)
+	if quote := decode(t, w)["comment"].(map[string]interface{})["quote"].(map[string]interface{}); quote["message"] != "long" {
+		t.Fatalf("nested quote = %v, want bob's text only", quote)
+	}
+}
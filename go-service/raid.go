@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Raid detection compares the short-window comment rate against the stream's
+// baseline and, on a spike, raises slow-mode until the spike has been gone for
+// the cooldown period, when runRaidSweeper restores the stream's own setting. Streams opt out with auto_slow_mode=0 in their modes.
+var (
+	raidShortWindow   time.Duration
+	raidBaseWindow    time.Duration
+	raidMinRate       float64 // comments/second the short window must reach
+	raidSpikeFactor   float64 // how many times the baseline counts as a spike
+	raidSlowMode      int     // slow-mode seconds applied during a raid
+	raidCooldown      time.Duration
+	raidCheckInterval = time.Second
+)
+
+func loadRaidConfig() {
+	raidShortWindow = time.Duration(envInt("RAID_SHORT_WINDOW", 10)) * time.Second
+	raidBaseWindow = time.Duration(envInt("RAID_BASELINE_WINDOW", 300)) * time.Second
+	raidMinRate = envFloat("RAID_MIN_RATE", 5)
+	raidSpikeFactor = envFloat("RAID_SPIKE_FACTOR", 4)
+	raidSlowMode = envInt("RAID_SLOW_MODE", 10)
+	raidCooldown = time.Duration(envInt("RAID_COOLDOWN", 120)) * time.Second
+}
+
+// publishModEvent notifies moderators (via the backend's subscriber) of an
+// automatic action taken on their stream
+func publishModEvent(ctx context.Context, streamID int64, event map[string]interface{}) {
+	event["stream_id"] = streamID
+	event["timestamp"] = time.Now().UnixMilli()
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	if err := rdb.Publish(ctx, modEventsChannel(streamID), payload).Err(); err != nil {
+		log.Printf("[GO] Stream %d: Error publishing moderator event: %v", streamID, err)
+	}
+}
+
+// checkRaid evaluates the stream's comment velocity, at most once per
+// raidCheckInterval, and enables slow-mode when it looks like a raid
+func checkRaid(ctx context.Context, streamID int64) {
+	if raidSlowMode <= 0 {
+		return
+	}
+	claimed, err := rdb.SetNX(ctx, raidCheckKey(streamID), 1, raidCheckInterval).Result()
+	if err != nil || !claimed {
+		return
+	}
+
+	fields, err := rdb.HGetAll(ctx, modesKey(streamID)).Result()
+	if err != nil {
+		return
+	}
+	if value, ok := fields["auto_slow_mode"]; ok && !flagEnabled(value) {
+		return
+	}
+
+	short, err := commentRate(ctx, streamID, raidShortWindow)
+	if err != nil {
+		return
+	}
+	baseline, err := commentRate(ctx, streamID, raidBaseWindow)
+	if err != nil {
+		return
+	}
+	if short < raidMinRate || short < baseline*raidSpikeFactor {
+		return
+	}
+
+	// Each detection during an ongoing raid pushes the revert further out
+	rdb.Set(ctx, raidKey(streamID), 1, raidCooldown)
+	if fields["slow_mode_auto"] != "" {
+		return // Already raised by an earlier detection
+	}
+	prior, _ := strconv.Atoi(fields["slow_mode"])
+	if prior >= raidSlowMode {
+		return // A moderator's slow-mode is already at least as strict
+	}
+	// slow_mode_pre_raid keeps the moderator's setting for the revert
+	rdb.HSet(ctx, modesKey(streamID), "slow_mode", raidSlowMode, "slow_mode_auto", raidSlowMode, "slow_mode_pre_raid", prior)
+	rdb.SAdd(ctx, raidStreamsKey(), streamID)
+
+	log.Printf("[GO] Stream %d: Raid detected (%.1f/s vs baseline %.1f/s), auto-enabled %ds slow-mode", streamID, short, baseline, raidSlowMode)
+	publishModEvent(ctx, streamID, map[string]interface{}{
+		"type":      "raid_detected",
+		"rate":      short,
+		"baseline":  baseline,
+		"slow_mode": raidSlowMode,
+	})
+	recordAudit(ctx, streamID, AuditEntry{Action: "mode_change", Actor: systemActor, Reason: "raid_detected", Details: map[string]interface{}{"slow_mode": raidSlowMode}})
+	announce(ctx, streamID, "Slow mode enabled: one message every %d seconds", raidSlowMode)
+}
+
+// raidRevertScript undoes an auto-enabled slow-mode. KEYS: modes hash. If a
+// moderator changed slow_mode during the raid their value stays; otherwise the
+// pre-raid value is restored. Returns the resulting slow_mode, or nil when
+// there was nothing to revert.
+var raidRevertScript = redis.NewScript(`
+local auto = redis.call('HGET', KEYS[1], 'slow_mode_auto')
+if not auto then
+	return false
+end
+local prior = redis.call('HGET', KEYS[1], 'slow_mode_pre_raid')
+local current = redis.call('HGET', KEYS[1], 'slow_mode')
+redis.call('HDEL', KEYS[1], 'slow_mode_auto', 'slow_mode_pre_raid')
+if current ~= auto then
+	return current or '0'
+end
+if prior and tonumber(prior) and tonumber(prior) > 0 then
+	redis.call('HSET', KEYS[1], 'slow_mode', prior)
+	return prior
+end
+redis.call('HDEL', KEYS[1], 'slow_mode')
+return '0'
+`)
+
+// runRaidSweeper periodically reverts the auto slow-mode of streams whose raid
+// marker has expired
+func runRaidSweeper(ctx context.Context, interval time.Duration) {
+	if interval <= 0 || raidSlowMode <= 0 {
+		log.Printf("[GO] Raid sweeper disabled")
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	jobs.setRunning("raid_sweeper", true)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := sweepRaids(ctx)
+			jobs.ran("raid_sweeper", err)
+			if err != nil {
+				log.Printf("[GO] Raid sweep failed: %v", err)
+			}
+		}
+	}
+}
+
+func sweepRaids(ctx context.Context) error {
+	members, err := rdb.SMembers(ctx, raidStreamsKey()).Result()
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		streamID, err := strconv.ParseInt(member, 10, 64)
+		if err != nil {
+			rdb.SRem(ctx, raidStreamsKey(), member)
+			continue
+		}
+		active, err := rdb.Exists(ctx, raidKey(streamID)).Result()
+		if err != nil {
+			return err
+		}
+		if active > 0 {
+			continue
+		}
+		if err := revertRaidSlowMode(ctx, streamID); err != nil {
+			return err
+		}
+		rdb.SRem(ctx, raidStreamsKey(), member)
+	}
+	return nil
+}
+
+// revertRaidSlowMode restores the stream's pre-raid slow-mode
+func revertRaidSlowMode(ctx context.Context, streamID int64) error {
+	restored, err := raidRevertScript.Run(ctx, rdb, []string{modesKey(streamID)}).Text()
+	if err == redis.Nil {
+		return nil // Already reverted
+	}
+	if err != nil {
+		return err
+	}
+	slowMode, _ := strconv.Atoi(restored)
+	log.Printf("[GO] Stream %d: Raid subsided, slow-mode back to %ds", streamID, slowMode)
+	publishModEvent(ctx, streamID, map[string]interface{}{"type": "raid_ended", "slow_mode": slowMode})
+	recordAudit(ctx, streamID, AuditEntry{Action: "mode_change", Actor: systemActor, Reason: "raid_ended", Details: map[string]interface{}{"slow_mode": slowMode}})
+	if slowMode > 0 {
+		announce(ctx, streamID, "Slow mode restored: one message every %d seconds", slowMode)
+	} else {
+		announce(ctx, streamID, "Slow mode disabled")
+	}
+	return nil
+}
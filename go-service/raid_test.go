@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// raidBurst posts n comments to stream 1 from distinct viewers, then lets the
+// next post run the raid check
+func raidBurst(t *testing.T, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		expectStatus(t, post(t, 1, fmt.Sprintf("raider%d", i), fmt.Sprintf("raider%d", i), fmt.Sprintf("raid message %d", i)), 200)
+	}
+	rdb.Del(ctx, raidCheckKey(1))
+}
+
+func TestRaidBurstEnablesSlowMode(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &raidMinRate, 1)
+	raidBurst(t, 25)
+
+	expectStatus(t, post(t, 1, "v1", "alice", "first"), 200)
+	if resp := poll(t, 1, "v1", 0); resp["slow_mode"] != float64(raidSlowMode) {
+		t.Fatalf("slow_mode = %v after the burst, want %d", resp["slow_mode"], raidSlowMode)
+	}
+	if w := post(t, 1, "v1", "alice", "second"); w.Code != 429 || decode(t, w)["reason"] != "slow_mode" {
+		t.Fatalf("second post: %d %s, want 429 slow_mode", w.Code, w.Body.String())
+	}
+	if entries := rdb.LRange(ctx, auditKey(1), 0, -1).Val(); len(entries) == 0 {
+		t.Fatal("the auto-action wasn't audited")
+	}
+
+	// Reads never revert; the sweeper does once the raid marker expires
+	rdb.Del(ctx, raidKey(1))
+	if resp := poll(t, 1, "v1", 0); resp["slow_mode"] != float64(raidSlowMode) {
+		t.Fatalf("slow_mode = %v after a poll, want the poll to leave it alone", resp["slow_mode"])
+	}
+	if err := sweepRaids(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if resp := poll(t, 1, "v1", 0); resp["slow_mode"] != nil {
+		t.Fatalf("slow_mode = %v after the raid, want it off", resp["slow_mode"])
+	}
+	if rdb.SCard(ctx, raidStreamsKey()).Val() != 0 {
+		t.Fatal("the reverted stream is still tracked")
+	}
+}
+
+func TestRaidRevertRestoresPriorSlowMode(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &raidMinRate, 1)
+	rdb.HSet(ctx, modesKey(1), "slow_mode", 2)
+	raidBurst(t, 25)
+	expectStatus(t, post(t, 1, "v1", "alice", "first"), 200)
+	if got := rdb.HGet(ctx, modesKey(1), "slow_mode").Val(); got != fmt.Sprint(raidSlowMode) {
+		t.Fatalf("slow_mode = %s during the raid, want %d", got, raidSlowMode)
+	}
+
+	rdb.Del(ctx, raidKey(1))
+	if err := sweepRaids(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := rdb.HGet(ctx, modesKey(1), "slow_mode").Val(); got != "2" {
+		t.Fatalf("slow_mode = %s after the raid, want the pre-raid 2", got)
+	}
+}
+
+func TestRaidRevertKeepsModeratorChange(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &raidMinRate, 1)
+	raidBurst(t, 25)
+	expectStatus(t, post(t, 1, "v1", "alice", "first"), 200)
+
+	// A moderator tightens slow-mode mid-raid
+	rdb.HSet(ctx, modesKey(1), "slow_mode", 30)
+	rdb.Del(ctx, raidKey(1))
+	if err := sweepRaids(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := rdb.HGet(ctx, modesKey(1), "slow_mode").Val(); got != "30" {
+		t.Fatalf("slow_mode = %s after the raid, want the moderator's 30", got)
+	}
+}
+
+func TestRaidDetectionOptOut(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &raidMinRate, 1)
+	rdb.HSet(ctx, modesKey(1), "auto_slow_mode", "0")
+	raidBurst(t, 25)
+
+	expectStatus(t, post(t, 1, "v1", "alice", "first"), 200)
+	expectStatus(t, post(t, 1, "v1", "alice", "second"), 200)
+	if resp := poll(t, 1, "v1", 0); resp["slow_mode"] != nil {
+		t.Fatalf("slow_mode = %v on an opted-out stream", resp["slow_mode"])
+	}
+}
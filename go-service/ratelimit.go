@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Rate limit axes (COMMENT_RATE_LIMIT_KEYS). Each configured axis gets its
+// own counter and a post must stay under the limit on all of them:
+//
+//   - viewer:   the client-chosen viewer_id. Cheap and precise, but a
+//     spammer can rotate it freely.
+//   - username: the display name. Catches viewer_id rotation under one
+//     name, but a shared name (e.g. the anonymous default) limits everyone
+//     using it together.
+//   - ip:       the client address. Hardest to rotate, but viewers behind
+//     one NAT or mobile carrier share a budget.
+//
+// Combining axes ("viewer,username,ip") means evading one axis still trips
+// another.
+const (
+	rateAxisViewer   = "viewer"
+	rateAxisUsername = "username"
+	rateAxisIP       = "ip"
+)
+
+var (
+	commentRateLimit  int // comments per window, 0 = no limit
+	commentRateWindow time.Duration
+	commentRateAxes   []string
+)
+
+func loadRateLimitConfig() {
+	commentRateLimit = envInt("COMMENT_RATE_LIMIT", 0)
+	commentRateWindow = time.Duration(envInt("COMMENT_RATE_WINDOW", 60)) * time.Second
+	if commentRateWindow <= 0 {
+		commentRateWindow = time.Minute
+	}
+	commentRateAxes = nil
+	for _, axis := range strings.Split(envString("COMMENT_RATE_LIMIT_KEYS", rateAxisViewer), ",") {
+		axis = strings.TrimSpace(axis)
+		switch axis {
+		case rateAxisViewer, rateAxisUsername, rateAxisIP:
+			commentRateAxes = append(commentRateAxes, axis)
+		case "":
+		default:
+			log.Printf("[GO] Warning: ignoring unknown rate limit key %q", axis)
+		}
+	}
+}
+
+// checkCommentRate counts a post against every configured axis. When any
+// axis is over the limit it returns false and the seconds until it resets.
+func checkCommentRate(ctx context.Context, streamID int64, req PostCommentRequest, origin commentOrigin) (bool, int) {
+	if commentRateLimit <= 0 {
+		return true, 0
+	}
+
+	values := map[string]string{
+		rateAxisViewer:   req.ViewerID,
+		rateAxisUsername: req.Username,
+		rateAxisIP:       origin.IP,
+	}
+	var keys []string
+	for _, axis := range commentRateAxes {
+		if values[axis] != "" {
+			keys = append(keys, rateLimitKey(streamID, axis, values[axis]))
+		}
+	}
+	if len(keys) == 0 {
+		return true, 0
+	}
+
+	counts := make([]*redis.IntCmd, len(keys))
+	ttls := make([]*redis.DurationCmd, len(keys))
+	_, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, k := range keys {
+			// SETNX starts a fresh window with its TTL; INCR keeps it
+			pipe.SetNX(ctx, k, 0, commentRateWindow)
+			counts[i] = pipe.Incr(ctx, k)
+			ttls[i] = pipe.PTTL(ctx, k)
+		}
+		return nil
+	})
+	if err != nil {
+		// Fail open, a Redis hiccup shouldn't silence chat
+		log.Printf("[GO] Stream %d: Error checking rate limit: %v", streamID, err)
+		return true, 0
+	}
+
+	retryAfter := 0
+	for i := range keys {
+		if counts[i].Val() > int64(commentRateLimit) {
+			secs := int((ttls[i].Val() + time.Second - 1) / time.Second)
+			if secs < 1 {
+				secs = 1
+			}
+			if secs > retryAfter {
+				retryAfter = secs
+			}
+		}
+	}
+	if retryAfter > 0 {
+		return false, retryAfter
+	}
+	return true, 0
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// rotatingPosts posts three comments to stream 1, rotating viewer_id and,
+// with names, the username, and returns the statuses
+func rotatingPosts(t *testing.T, names bool) []int {
+	t.Helper()
+	var statuses []int
+	for i := 1; i <= 3; i++ {
+		username := "alice"
+		if names {
+			username = fmt.Sprintf("alias%d", i)
+		}
+		statuses = append(statuses, post(t, 1, fmt.Sprintf("v%d", i), username, fmt.Sprintf("message %d", i)).Code)
+	}
+	return statuses
+}
+
+func TestCombinedRateLimitKeysCatchEvasion(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &commentRateLimit, 2)
+
+	for _, tc := range []struct {
+		axes  []string
+		names bool
+		want  string
+	}{
+		// Rotating viewer_id evades a viewer-only limit
+		{[]string{rateAxisViewer}, false, "[200 200 200]"},
+		// but not one that also keys on the username
+		{[]string{rateAxisViewer, rateAxisUsername}, false, "[200 200 429]"},
+		// Rotating both evades those two
+		{[]string{rateAxisViewer, rateAxisUsername}, true, "[200 200 200]"},
+		// but everyone still posts from one address
+		{[]string{rateAxisViewer, rateAxisUsername, rateAxisIP}, true, "[200 200 429]"},
+	} {
+		resetRedis(t)
+		setVar(t, &commentRateAxes, tc.axes)
+		if got := fmt.Sprint(rotatingPosts(t, tc.names)); got != tc.want {
+			t.Fatalf("axes %v (rotating names %v): %s, want %s", tc.axes, tc.names, got, tc.want)
+		}
+	}
+}
+
+func TestRateLimitRejection(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &commentRateLimit, 1)
+	setVar(t, &commentRateAxes, []string{rateAxisUsername})
+	expectStatus(t, post(t, 1, "v1", "alice", "first"), 200)
+	w := post(t, 1, "v2", "alice", "second")
+	expectStatus(t, w, 429)
+	if resp := decode(t, w); resp["reason"] != "rate_limited" || resp["retry_after"] != float64(commentRateWindow.Seconds()) {
+		t.Fatalf("rejection = %v, want rate_limited retry_after %v", resp, commentRateWindow.Seconds())
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// Streamers can replace the reactions their community uses, on comments and
+// as live reactions, with their own list (stream:reactions:<stream_id>, in
+// display order). Streams without one get REACTION_TYPES, a comma-separated
+// list defaulting to the set below. GET /stream/:id/reactions returns a
+// stream's types for rendering. Counts of types that are later removed stay
+// on their comments but can't be added to.
+var defaultReactionTypes = []string{"like", "love", "laugh", "wow", "sad", "fire"}
+
+// reactionTypePattern matches a reaction identifier
+var reactionTypePattern = regexp.MustCompile(`^[a-z0-9_\-]{1,32}$`)
+
+// reactionMaxTypes bounds a stream's reaction list
+const reactionMaxTypes = 20
+
+func loadReactionConfig() {
+	spec := envString("REACTION_TYPES", "")
+	if spec == "" {
+		return
+	}
+	types, invalid := parseReactionTypes(strings.Split(spec, ","))
+	if invalid != "" || len(types) == 0 || len(types) > reactionMaxTypes {
+		log.Printf("[GO] Warning: invalid REACTION_TYPES %q, using %s", spec, strings.Join(defaultReactionTypes, ","))
+		return
+	}
+	defaultReactionTypes = types
+}
+
+// parseReactionTypes lower-cases and deduplicates values, keeping their
+// order; invalid is the first value that isn't an identifier
+func parseReactionTypes(values []string) (types []string, invalid string) {
+	seen := map[string]bool{}
+	for _, value := range values {
+		t := strings.ToLower(strings.TrimSpace(value))
+		if !reactionTypePattern.MatchString(t) {
+			return nil, value
+		}
+		if !seen[t] {
+			seen[t] = true
+			types = append(types, t)
+		}
+	}
+	return types, ""
+}
+
+// streamReactionTypes returns the reactions a stream allows
+func streamReactionTypes(ctx context.Context, streamID int64) []string {
+	types, err := rdb.LRange(ctx, reactionTypesKey(streamID), 0, -1).Result()
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error loading reaction types: %v", streamID, err)
+		return defaultReactionTypes
+	}
+	if len(types) == 0 {
+		return defaultReactionTypes
+	}
+	return types
+}
+
+type ReactRequest struct {
+	StreamID  flexID `json:"stream_id" binding:"required"`
+	CommentID flexID `json:"comment_id" binding:"required"`
+	ViewerID  string `json:"viewer_id" binding:"required"`
+	Reaction  string `json:"reaction" binding:"required"`
+}
+
+// reactScript toggles one viewer's reaction and keeps the per-comment counts
+// and the stream's leaderboard in step, recording that the comment has keys
+// for compaction.
+// KEYS: voters set, counts hash, leaderboard, keyed comments. ARGV: voter member, type, comment id.
+var reactScript = redis.NewScript(`
+local delta = 1
+if redis.call('SADD', KEYS[1], ARGV[1]) == 0 then
+	redis.call('SREM', KEYS[1], ARGV[1])
+	delta = -1
+end
+local count = redis.call('HINCRBY', KEYS[2], ARGV[2], delta)
+if count <= 0 then
+	redis.call('HDEL', KEYS[2], ARGV[2])
+end
+redis.call('SADD', KEYS[4], ARGV[3])
+local total = tonumber(redis.call('ZINCRBY', KEYS[3], delta, ARGV[3]))
+if total <= 0 then
+	redis.call('ZREM', KEYS[3], ARGV[3])
+end
+return {delta, count}
+`)
+
+func isReactionType(types []string, reaction string) bool {
+	for _, t := range types {
+		if t == reaction {
+			return true
+		}
+	}
+	return false
+}
+
+// reactToComment toggles a viewer's reaction on a comment: reacting again
+// with the same type removes it
+func reactToComment(c *gin.Context) {
+	var req ReactRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	reqCtx := c.Request.Context()
+	if types := streamReactionTypes(reqCtx, int64(req.StreamID)); !isReactionType(types, req.Reaction) {
+		c.JSON(400, gin.H{"error": "unknown reaction type", "reason": "invalid_reaction", "allowed": types})
+		return
+	}
+	if ok, retryAfter := checkReactionRate(reqCtx, int64(req.StreamID), int64(req.CommentID), req.ViewerID); !ok {
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		c.JSON(429, gin.H{"error": "you are reacting too fast, please slow down", "reason": "reaction_rate_limited", "retry_after": retryAfter})
+		return
+	}
+	commentID := strconv.FormatInt(int64(req.CommentID), 10)
+	data, err := rdb.HGet(reqCtx, commentDataKey(int64(req.StreamID)), commentID).Result()
+	if err == redis.Nil {
+		c.JSON(404, gin.H{"error": "comment not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error checking comment %s: %v", int64(req.StreamID), commentID, err)
+		c.JSON(500, gin.H{"error": "failed to react"})
+		return
+	}
+
+	keys := []string{
+		reactionVotersKey(int64(req.StreamID), int64(req.CommentID)),
+		reactionCountsKey(int64(req.StreamID), int64(req.CommentID)),
+		reactionLeaderboardKey(int64(req.StreamID)),
+		commentKeysKey(int64(req.StreamID)),
+	}
+	res, err := reactScript.Run(reqCtx, rdb, keys, req.ViewerID+"|"+req.Reaction, req.Reaction, commentID).Int64Slice()
+	if err != nil || len(res) != 2 {
+		log.Printf("[GO] Stream %d: Error reacting to comment %s: %v", int64(req.StreamID), commentID, err)
+		c.JSON(500, gin.H{"error": "failed to react"})
+		return
+	}
+
+	adjustEngagement(reqCtx, int64(req.StreamID), commentID, float64(res[0])*engagementReactionWeight)
+	// viewer_id is only an identity when a trusted caller sent it
+	if isTrustedRequest(c) {
+		bumpReputation(reqCtx, int64(req.StreamID), commentAuthor(data), repReactions, res[0])
+	}
+
+	count := res[1]
+	if count < 0 {
+		count = 0
+	}
+	c.JSON(200, gin.H{"success": true, "reacted": res[0] > 0, "reaction": req.Reaction, "count": count})
+}
+
+// getReactionTypes returns the reactions a stream allows, in display order
+func getReactionTypes(c *gin.Context) {
+	streamID, ok := parseStreamID(c)
+	if !ok {
+		return
+	}
+	types := streamReactionTypes(c.Request.Context(), streamID)
+	c.JSON(200, gin.H{"reactions": types})
+}
+
+type ReactionTypesRequest struct {
+	Reactions []string `json:"reactions"` // empty reverts to the default set
+}
+
+// setReactionTypes replaces the reactions a stream allows
+func setReactionTypes(c *gin.Context) {
+	streamID, ok := parseStreamID(c)
+	if !ok {
+		return
+	}
+	var req ReactionTypesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	types, invalid := parseReactionTypes(req.Reactions)
+	if invalid != "" {
+		c.JSON(400, gin.H{"error": "invalid reaction type: " + invalid})
+		return
+	}
+	if len(types) > reactionMaxTypes {
+		c.JSON(400, gin.H{"error": "too many reaction types"})
+		return
+	}
+
+	reqCtx := c.Request.Context()
+	_, err := rdb.TxPipelined(reqCtx, func(pipe redis.Pipeliner) error {
+		pipe.Del(reqCtx, reactionTypesKey(streamID))
+		for _, t := range types {
+			pipe.RPush(reqCtx, reactionTypesKey(streamID), t)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error storing reaction types: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to update reaction types"})
+		return
+	}
+	if len(types) == 0 {
+		types = defaultReactionTypes
+	}
+	log.Printf("[GO] Stream %d: Reaction types set to %s", streamID, strings.Join(types, ","))
+	auditRequest(c, streamID, "reactions_updated", nil, "", map[string]interface{}{"reactions": types})
+	c.JSON(200, gin.H{"success": true, "reactions": types})
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// reactWith reacts to a comment on stream 1 with the given type
+func reactWith(t *testing.T, commentID int64, reaction string) (int, map[string]interface{}) {
+	t.Helper()
+	w := request(t, http.MethodPost, "/react", map[string]interface{}{
+		"stream_id": 1, "comment_id": commentID, "viewer_id": "v2", "reaction": reaction,
+	})
+	return w.Code, decode(t, w)
+}
+
+// reactionTypes lists stream 1's allowed reactions
+func reactionTypes(t *testing.T) []interface{} {
+	t.Helper()
+	w := request(t, http.MethodGet, "/stream/1/reactions", nil)
+	expectStatus(t, w, 200)
+	list, _ := decode(t, w)["reactions"].([]interface{})
+	return list
+}
+
+func TestParseReactionTypes(t *testing.T) {
+	types, invalid := parseReactionTypes([]string{" PogChamp ", "fire", "pogchamp", "kek_w", "a-b"})
+	if invalid != "" || !reflect.DeepEqual(types, []string{"pogchamp", "fire", "kek_w", "a-b"}) {
+		t.Fatalf("types = %v invalid = %q", types, invalid)
+	}
+	for _, bad := range []string{"", "two words", "🔥", "semi;colon", "abcdefghijklmnopqrstuvwxyz0123456"} {
+		if _, invalid := parseReactionTypes([]string{"fire", bad}); invalid != bad {
+			t.Fatalf("%q: invalid = %q, want it rejected", bad, invalid)
+		}
+	}
+}
+
+func TestDefaultReactionTypes(t *testing.T) {
+	resetRedis(t)
+	id := postedID(t, 1, "v1", "alice", "react to me")
+
+	if got := reactionTypes(t); len(got) != len(defaultReactionTypes) || got[0] != defaultReactionTypes[0] {
+		t.Fatalf("reactions = %v, want the default set", got)
+	}
+	if status, resp := reactWith(t, id, "fire"); status != 200 || resp["reacted"] != true {
+		t.Fatalf("default type: %d %v", status, resp)
+	}
+	if status, resp := reactWith(t, id, "pogchamp"); status != 400 || resp["reason"] != "invalid_reaction" {
+		t.Fatalf("unknown type: %d %v, want 400 invalid_reaction", status, resp)
+	}
+}
+
+func TestCustomReactionTypes(t *testing.T) {
+	resetRedis(t)
+	id := postedID(t, 1, "v1", "alice", "react to me")
+	set := func(reactions ...string) (int, map[string]interface{}) {
+		w := request(t, http.MethodPost, "/stream/1/reactions", map[string]interface{}{"reactions": reactions}, asModerator...)
+		return w.Code, decode(t, w)
+	}
+
+	if status, _ := set("PogChamp", "kek"); status != 200 {
+		t.Fatalf("setting custom types: %d", status)
+	}
+	if got := reactionTypes(t); !reflect.DeepEqual(got, []interface{}{"pogchamp", "kek"}) {
+		t.Fatalf("reactions = %v, want [pogchamp kek]", got)
+	}
+	if status, _ := reactWith(t, id, "pogchamp"); status != 200 {
+		t.Fatalf("custom type: %d, want 200", status)
+	}
+	status, resp := reactWith(t, id, "like")
+	if status != 400 || resp["reason"] != "invalid_reaction" || len(resp["allowed"].([]interface{})) != 2 {
+		t.Fatalf("default type on a custom stream: %d %v, want 400 listing the allowed set", status, resp)
+	}
+	// Live reactions follow the same list
+	w := request(t, http.MethodPost, "/stream/1/reaction", map[string]interface{}{"viewer_id": "v2", "reaction": "like"})
+	if w.Code != 400 || decode(t, w)["reason"] != "invalid_reaction" {
+		t.Fatalf("live reaction of an unknown type: %d %s", w.Code, w.Body.String())
+	}
+
+	if status, _ := set("ok", "not valid!"); status != 400 {
+		t.Fatalf("invalid identifier: %d, want 400", status)
+	}
+	many := make([]string, reactionMaxTypes+1)
+	for i := range many {
+		many[i] = fmt.Sprintf("r%d", i)
+	}
+	if status, _ := set(many...); status != 400 {
+		t.Fatalf("%d types: %d, want 400", len(many), status)
+	}
+	expectStatus(t, request(t, http.MethodPost, "/stream/1/reactions", map[string]interface{}{"reactions": []string{"kek"}}, asRole(roleViewer)...), 403)
+
+	// An empty list reverts to the defaults
+	if status, _ := set(); status != 200 || len(reactionTypes(t)) != len(defaultReactionTypes) {
+		t.Fatalf("reverting: %d %v", status, reactionTypes(t))
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Reaction rate limits. The toggle already stops one viewer inflating a
+// comment's count, but flipping a reaction on and off still writes to Redis
+// every time, so reacts are counted per viewer per comment
+// (REACTION_RATE_LIMIT) and per viewer across the stream
+// (REACTION_STREAM_RATE_LIMIT) over REACTION_RATE_WINDOW seconds. A viewer
+// can change their mind a few times; past the limit they get a 429 until the
+// window resets. 0 turns a limit off.
+var (
+	reactionRateLimit       int
+	reactionStreamRateLimit int
+	reactionRateWindow      time.Duration
+)
+
+func loadReactionRateConfig() {
+	reactionRateLimit = envInt("REACTION_RATE_LIMIT", 5)
+	reactionStreamRateLimit = envInt("REACTION_STREAM_RATE_LIMIT", 30)
+	reactionRateWindow = time.Duration(envInt("REACTION_RATE_WINDOW", 10)) * time.Second
+	if reactionRateWindow <= 0 {
+		reactionRateWindow = 10 * time.Second
+	}
+}
+
+// checkReactionRate counts a react against the viewer's per-comment and
+// per-stream limits. When either is exceeded it returns false and the
+// seconds until it resets.
+func checkReactionRate(ctx context.Context, streamID, commentID int64, viewerID string) (bool, int) {
+	type window struct {
+		key   string
+		limit int
+	}
+	var windows []window
+	if reactionRateLimit > 0 {
+		windows = append(windows, window{rateLimitKey(streamID, "react", viewerID+":"+strconv.FormatInt(commentID, 10)), reactionRateLimit})
+	}
+	if reactionStreamRateLimit > 0 {
+		windows = append(windows, window{rateLimitKey(streamID, "react", viewerID), reactionStreamRateLimit})
+	}
+	if len(windows) == 0 {
+		return true, 0
+	}
+
+	counts := make([]*redis.IntCmd, len(windows))
+	ttls := make([]*redis.DurationCmd, len(windows))
+	_, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, w := range windows {
+			pipe.SetNX(ctx, w.key, 0, reactionRateWindow)
+			counts[i] = pipe.Incr(ctx, w.key)
+			ttls[i] = pipe.PTTL(ctx, w.key)
+		}
+		return nil
+	})
+	if err != nil {
+		// Fail open, like the comment rate limit
+		log.Printf("[GO] Stream %d: Error checking reaction rate limit: %v", streamID, err)
+		return true, 0
+	}
+
+	retryAfter := 0
+	for i, w := range windows {
+		if counts[i].Val() <= int64(w.limit) {
+			continue
+		}
+		secs := int((ttls[i].Val() + time.Second - 1) / time.Second)
+		if secs < 1 {
+			secs = 1
+		}
+		if secs > retryAfter {
+			retryAfter = secs
+		}
+	}
+	if retryAfter > 0 {
+		return false, retryAfter
+	}
+	return true, 0
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// reactOnce sends a react to stream 1 and returns the raw response
+func reactOnce(t *testing.T, commentID int64, viewerID string) *httptest.ResponseRecorder {
+	t.Helper()
+	return request(t, http.MethodPost, "/react", map[string]interface{}{
+		"stream_id": 1, "comment_id": commentID, "viewer_id": viewerID, "reaction": "like",
+	})
+}
+
+// expectReactionLimited checks a react was refused by the rate limit
+func expectReactionLimited(t *testing.T, w *httptest.ResponseRecorder) {
+	t.Helper()
+	resp := decode(t, w)
+	if w.Code != 429 || resp["reason"] != "reaction_rate_limited" || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("react = %d %v, want 429 reaction_rate_limited with Retry-After", w.Code, resp)
+	}
+}
+
+func TestReactionRateLimitPerComment(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &reactionRateLimit, 3)
+	setVar(t, &reactionStreamRateLimit, 0)
+	first := postedID(t, 1, "v1", "alice", "one")
+	second := postedID(t, 1, "v1", "alice", "two")
+
+	// Toggling within the limit still works, and ends where it should
+	for i, want := range []bool{true, false, true} {
+		w := reactOnce(t, first, "v2")
+		expectStatus(t, w, 200)
+		if got := decode(t, w)["reacted"]; got != want {
+			t.Fatalf("react %d: reacted = %v, want %v", i+1, got, want)
+		}
+	}
+	expectReactionLimited(t, reactOnce(t, first, "v2"))
+
+	// Other comments and viewers have their own counters
+	expectStatus(t, reactOnce(t, second, "v2"), 200)
+	expectStatus(t, reactOnce(t, first, "v3"), 200)
+
+	testRedis.FastForward(reactionRateWindow)
+	expectStatus(t, reactOnce(t, first, "v2"), 200)
+}
+
+func TestReactionRateLimitPerStream(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &reactionRateLimit, 0)
+	setVar(t, &reactionStreamRateLimit, 3)
+	ids := make([]int64, 4)
+	for i := range ids {
+		ids[i] = postedID(t, 1, "v1", "alice", "comment")
+	}
+
+	for _, id := range ids[:3] {
+		expectStatus(t, reactOnce(t, id, "v2"), 200)
+	}
+	expectReactionLimited(t, reactOnce(t, ids[3], "v2"))
+	expectStatus(t, reactOnce(t, ids[3], "v3"), 200)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"syscall"
+
+	"github.com/gin-gonic/gin"
+)
+
+var handlerPanics = newCounter("http_panics_total", "Handler panics recovered")
+
+// panicLog is the structured record written for a recovered panic
+type panicLog struct {
+	Event    string `json:"event"`
+	Method   string `json:"method"`
+	Endpoint string `json:"endpoint"`
+	StreamID string `json:"stream_id,omitempty"`
+	Error    string `json:"error"`
+	Stack    string `json:"stack"`
+}
+
+// recoveryMiddleware turns a handler panic into a JSON 500 and logs it with
+// its request context. The stack only goes to the log, never to clients.
+func recoveryMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			err, ok := rec.(error)
+			if !ok {
+				err = fmt.Errorf("%v", rec)
+			}
+			// A client that went away isn't a bug; there's nobody to answer
+			if errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET) {
+				c.Abort()
+				return
+			}
+
+			handlerPanics.Inc()
+			entry, _ := json.Marshal(panicLog{
+				Event:    "panic",
+				Method:   c.Request.Method,
+				Endpoint: c.FullPath(),
+				StreamID: requestStreamID(c),
+				Error:    err.Error(),
+				Stack:    string(debug.Stack()),
+			})
+			log.Printf("[GO] %s", entry)
+
+			if c.Writer.Written() {
+				c.Abort() // too late for a clean error, e.g. mid-stream
+				return
+			}
+			c.AbortWithStatusJSON(500, gin.H{"error": "internal server error", "reason": "internal"})
+		}()
+		c.Next()
+	}
+}
+
+// requestStreamID finds the stream a request was for, from the path or the
+// buffered JSON body, for logging
+func requestStreamID(c *gin.Context) string {
+	if id := c.Param("id"); id != "" {
+		return id
+	}
+	body, ok := c.Get(rawBodyKey)
+	if !ok {
+		return ""
+	}
+	var probe struct {
+		StreamID json.Number `json:"stream_id"`
+	}
+	if json.Unmarshal(body.([]byte), &probe) != nil {
+		return ""
+	}
+	return probe.StreamID.String()
+}
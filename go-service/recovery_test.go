@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// panicRouter has newRouter's recovery, response and body handling in front
+// of a handler that panics
+func panicRouter() *gin.Engine {
+	r := gin.New()
+	r.Use(recoveryMiddleware(), responseMiddleware(), bodyLimitMiddleware())
+	r.POST("/boom", func(c *gin.Context) {
+		var m map[string]int
+		m["boom"]++ // nil map write
+	})
+	return r
+}
+
+func TestHandlerPanicIsRecovered(t *testing.T) {
+	var logged bytes.Buffer
+	out := log.Writer()
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(out) })
+	before := handlerPanics.Value()
+
+	req := httptest.NewRequest(http.MethodPost, "/boom", strings.NewReader(`{"stream_id": 42}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	panicRouter().ServeHTTP(w, req)
+
+	resp := decode(t, w)
+	if w.Code != 500 || resp["error"] != "internal server error" || resp["reason"] != "internal" {
+		t.Fatalf("response = %d %v, want the JSON 500", w.Code, resp)
+	}
+	if strings.Contains(w.Body.String(), "goroutine") || strings.Contains(w.Body.String(), "nil map") {
+		t.Fatalf("the response leaks panic details: %s", w.Body.String())
+	}
+	if got := handlerPanics.Value() - before; got != 1 {
+		t.Fatalf("panics counted = %d, want 1", got)
+	}
+
+	line := logged.String()
+	start := strings.Index(line, "{")
+	if start < 0 {
+		t.Fatalf("no structured log line: %q", line)
+	}
+	var entry panicLog
+	if err := json.Unmarshal([]byte(strings.TrimSpace(line[start:])), &entry); err != nil {
+		t.Fatalf("log line %q: %v", line, err)
+	}
+	if entry.Event != "panic" || entry.Endpoint != "/boom" || entry.StreamID != "42" || !strings.Contains(entry.Error, "nil map") || entry.Stack == "" {
+		t.Fatalf("logged %+v", entry)
+	}
+}
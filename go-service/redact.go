@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Words added to the blocklist only apply to comments posted afterwards.
+// POST /stream/:id/profanity/rescan lets moderators catch up: it checks the
+// newest comments (limit, default PROFANITY_RESCAN_LIMIT, at most
+// PROFANITY_RESCAN_MAX) against the current lists and masks every listed
+// word the stream doesn't allow, whatever its tier's action, since
+// published comments can only be edited. Redactions go out to live clients
+// as edits.
+var (
+	profanityRescanLimit int
+	profanityRescanMax   int
+)
+
+func loadRedactConfig() {
+	profanityRescanLimit = envInt("PROFANITY_RESCAN_LIMIT", 500)
+	profanityRescanMax = envInt("PROFANITY_RESCAN_MAX", 5000)
+	if profanityRescanMax < 1 {
+		profanityRescanMax = 5000
+	}
+	if profanityRescanLimit < 1 || profanityRescanLimit > profanityRescanMax {
+		profanityRescanLimit = profanityRescanMax
+	}
+}
+
+// redactActions turns a stream's tier actions into what a rescan applies:
+// anything stronger than allow masks
+func redactActions(actions map[string]string) map[string]string {
+	redact := make(map[string]string, len(actions))
+	for tier, action := range actions {
+		redact[tier] = profanityAllow
+		if action != profanityAllow {
+			redact[tier] = profanityMask
+		}
+	}
+	return redact
+}
+
+// redactComment masks a comment's listed words, including in its quote, and
+// reports whether anything changed
+func redactComment(cmt *Comment, actions map[string]string) bool {
+	changed := false
+	if v := profanity.check(cmt.Message, actions); v.Message != cmt.Message {
+		cmt.Message, changed = v.Message, true
+	}
+	if cmt.Quote != nil {
+		if v := profanity.check(cmt.Quote.Message, actions); v.Message != cmt.Quote.Message {
+			quote := *cmt.Quote
+			quote.Message = v.Message
+			cmt.Quote, changed = &quote, true
+		}
+	}
+	return changed
+}
+
+// findRedactions returns redacted versions of the newest comments that need it
+func findRedactions(ctx context.Context, streamID int64, limit int, actions map[string]string) ([]Comment, int, error) {
+	ids, err := rdb.ZRevRange(ctx, commentIndexKey(streamID), 0, int64(limit-1)).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, 0, err
+	}
+	data, err := rdb.HMGet(ctx, commentDataKey(streamID), ids...).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	var redacted []Comment
+	scanned := 0
+	for _, raw := range data {
+		s, ok := raw.(string)
+		if !ok {
+			continue
+		}
+		var cmt Comment
+		if json.Unmarshal([]byte(s), &cmt) != nil || cmt.Type == "system" {
+			continue
+		}
+		scanned++
+		if redactComment(&cmt, actions) {
+			redacted = append(redacted, cmt)
+		}
+	}
+	return redacted, scanned, nil
+}
+
+type RescanRequest struct {
+	Limit  int  `json:"limit" binding:"min=0"` // newest comments to check, 0 = PROFANITY_RESCAN_LIMIT
+	DryRun bool `json:"dry_run"`
+}
+
+// rescanProfanity redacts recent comments that the current blocklist catches
+func rescanProfanity(c *gin.Context) {
+	streamID, ok := parseStreamID(c)
+	if !ok {
+		return
+	}
+	var req RescanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	limit := req.Limit
+	if limit == 0 {
+		limit = profanityRescanLimit
+	}
+	if limit > profanityRescanMax {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("limit must be at most %d", profanityRescanMax)})
+		return
+	}
+
+	reqCtx := c.Request.Context()
+	modes, err := loadStreamModes(reqCtx, streamID)
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error loading modes: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to rescan comments"})
+		return
+	}
+	profanity.refreshIfChanged(reqCtx)
+	redacted, scanned, err := findRedactions(reqCtx, streamID, limit, redactActions(modes.ProfanityActions))
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error scanning comments: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to rescan comments"})
+		return
+	}
+	ids := make([]string, len(redacted))
+	for i, cmt := range redacted {
+		ids[i] = strconv.FormatInt(cmt.ID, 10)
+	}
+
+	if !req.DryRun && len(redacted) > 0 {
+		applied, err := editComments(reqCtx, streamID, redacted)
+		if err != nil {
+			log.Printf("[GO] Stream %d: Error redacting comments: %v", streamID, err)
+			c.JSON(500, gin.H{"error": "failed to rescan comments"})
+			return
+		}
+		recordRedactions(reqCtx, streamID, applied)
+		log.Printf("[GO] Stream %d: Redacted %d of %d scanned comments", streamID, applied, scanned)
+		auditRequest(c, streamID, "redact", nil, "", map[string]interface{}{"scanned": scanned, "comment_ids": ids})
+		publishModEvent(reqCtx, streamID, map[string]interface{}{"type": "comments_redacted", "comment_ids": ids})
+	}
+	c.JSON(200, gin.H{"success": true, "dry_run": req.DryRun, "scanned": scanned, "redacted": len(redacted), "comment_ids": ids})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// rescan rescans stream 1's comments as a moderator
+func rescan(t *testing.T, body map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	w := request(t, http.MethodPost, "/stream/1/profanity/rescan", body, asRole(roleModerator)...)
+	expectStatus(t, w, 200)
+	return decode(t, w)
+}
+
+func TestRescanRedactsAfterABlocklistUpdate(t *testing.T) {
+	resetRedis(t)
+	expectStatus(t, post(t, 1, "v1", "alice", "oh heck no"), 200)
+	expectStatus(t, post(t, 1, "v2", "bob", "hello"), 200)
+	nextSecond()
+	before := poll(t, 1, "v9", 0)
+
+	// Severe words are normally rejected, but published comments can only
+	// be masked
+	withProfanityWords(t, tierSevere, "heck")
+	if resp := rescan(t, map[string]interface{}{"dry_run": true}); resp["redacted"] != float64(1) || resp["scanned"] != float64(2) {
+		t.Fatalf("dry run = %v, want 1 of 2 redacted", resp)
+	}
+	if got := messages(poll(t, 1, "v9", 0)); got[0] != "oh heck no" {
+		t.Fatalf("after a dry run = %v, want nothing changed", got)
+	}
+
+	resp := rescan(t, map[string]interface{}{})
+	if resp["redacted"] != float64(1) || len(resp["comment_ids"].([]interface{})) != 1 {
+		t.Fatalf("rescan = %v, want 1 redacted", resp)
+	}
+	got := messages(poll(t, 1, "v9", 0))
+	if strings.Contains(got[0], "heck") || !strings.HasPrefix(got[0], "oh ") || got[1] != "hello" {
+		t.Fatalf("after the rescan = %v, want heck masked", got)
+	}
+
+	// Live clients get the redaction as an edit
+	w := request(t, http.MethodPost, "/check-update", map[string]interface{}{
+		"stream_id": 1, "viewer_id": "v9", "last_id": before["cursor"], "edits_since": before["server_time"],
+	})
+	expectStatus(t, w, 200)
+	if edits, _ := decode(t, w)["edits"].([]interface{}); len(edits) != 1 || strings.Contains(edits[0].(map[string]interface{})["message"].(string), "heck") {
+		t.Fatalf("edits = %v, want the redacted comment", edits)
+	}
+
+	if resp := rescan(t, map[string]interface{}{}); resp["redacted"] != float64(0) {
+		t.Fatalf("second rescan = %v, want nothing left to redact", resp)
+	}
+}
+
+func TestRescanIsBounded(t *testing.T) {
+	resetRedis(t)
+	expectStatus(t, post(t, 1, "v1", "alice", "heck one"), 200)
+	expectStatus(t, post(t, 1, "v2", "bob", "heck two"), 200)
+	withProfanityWords(t, tierMild, "heck")
+
+	if resp := rescan(t, map[string]interface{}{"limit": 1}); resp["scanned"] != float64(1) || resp["redacted"] != float64(1) {
+		t.Fatalf("limit 1 = %v, want only the newest scanned", resp)
+	}
+	w := request(t, http.MethodPost, "/stream/1/profanity/rescan", map[string]interface{}{"limit": profanityRescanMax + 1}, asRole(roleModerator)...)
+	expectStatus(t, w, 400)
+}
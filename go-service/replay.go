@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// A VOD player replays the chat alongside the recording. Rather than range
+// querying as playback advances, it fetches the whole broadcast's timeline
+// once from GET /stream/:id/replay/timeline: the comments grouped by the
+// playback second (or bucket of REPLAY_BUCKET_SECONDS, ?bucket= overrides it
+// up to REPLAY_MAX_BUCKET_SECONDS) they arrived at, counted from the start of
+// the broadcast, to schedule locally.
+//
+// Only ended broadcasts have a timeline. Their comments no longer change other
+// than through moderation, so timelines are cached for REPLAY_CACHE_TTL, per
+// broadcast and bucket size, and dropped whenever comments are removed.
+var (
+	replayBucket    time.Duration
+	replayMaxBucket time.Duration
+	replayCacheTTL  time.Duration
+)
+
+func loadReplayConfig() {
+	replayBucket = time.Duration(envInt("REPLAY_BUCKET_SECONDS", 1)) * time.Second
+	replayMaxBucket = time.Duration(envInt("REPLAY_MAX_BUCKET_SECONDS", 60)) * time.Second
+	if replayBucket <= 0 {
+		replayBucket = time.Second
+	}
+	if replayMaxBucket < replayBucket {
+		replayMaxBucket = replayBucket
+	}
+	replayCacheTTL = time.Duration(envInt("REPLAY_CACHE_TTL", 3600)) * time.Second
+}
+
+// ReplayBucket holds the comments posted in one bucket of playback
+type ReplayBucket struct {
+	Offset   int64     `json:"offset"` // seconds into the broadcast the bucket starts
+	Comments []Comment `json:"comments"`
+}
+
+type ReplayTimeline struct {
+	StreamID  int64          `json:"stream_id"`
+	StartedAt int64          `json:"started_at"` // ms
+	EndedAt   int64          `json:"ended_at"`   // ms
+	Duration  int64          `json:"duration"`   // seconds
+	Bucket    int64          `json:"bucket"`     // seconds per bucket
+	Count     int            `json:"count"`
+	Buckets   []ReplayBucket `json:"buckets"` // non-empty buckets, in order
+}
+
+// bucketComments groups chronologically ordered comments by the bucket of
+// playback they fall in. A comment exactly on a boundary starts the next
+// bucket.
+func bucketComments(comments []Comment, startedAt int64, bucket time.Duration) []ReplayBucket {
+	buckets := []ReplayBucket{}
+	size := bucket.Milliseconds()
+	for _, cmt := range comments {
+		offset := (cmt.Timestamp - startedAt) / size * (size / 1000)
+		if n := len(buckets); n > 0 && buckets[n-1].Offset == offset {
+			buckets[n-1].Comments = append(buckets[n-1].Comments, cmt)
+			continue
+		}
+		buckets = append(buckets, ReplayBucket{Offset: offset, Comments: []Comment{cmt}})
+	}
+	return buckets
+}
+
+// buildReplayTimeline reads a finished broadcast's comments into a timeline
+func buildReplayTimeline(ctx context.Context, streamID int64, status StreamStatus, bucket time.Duration) (*ReplayTimeline, error) {
+	ids, err := rdb.ZRangeByScore(ctx, commentIndexKey(streamID), &redis.ZRangeBy{
+		Min: strconv.FormatInt(status.StartedAt, 10),
+		Max: strconv.FormatInt(status.EndedAt, 10),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	var comments []Comment
+	if len(ids) > 0 {
+		data, err := rdb.HMGet(ctx, commentDataKey(streamID), ids...).Result()
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now().UnixMilli()
+		for _, raw := range data {
+			s, ok := raw.(string)
+			if !ok {
+				continue // deleted between the two reads
+			}
+			var cmt Comment
+			// Timelines are cached for everyone, so they leave out comments
+			// gated to subscribers
+			if json.Unmarshal([]byte(s), &cmt) != nil || isExpired(cmt, now) || cmt.MinTier != "" {
+				continue
+			}
+			comments = append(comments, cmt)
+		}
+	}
+	return &ReplayTimeline{
+		StreamID:  streamID,
+		StartedAt: status.StartedAt,
+		EndedAt:   status.EndedAt,
+		Duration:  (status.EndedAt - status.StartedAt) / 1000,
+		Bucket:    int64(bucket / time.Second),
+		Count:     len(comments),
+		Buckets:   bucketComments(comments, status.StartedAt, bucket),
+	}, nil
+}
+
+// replayCacheField identifies a cached timeline: restarting the stream starts
+// a new broadcast, which never reuses the last one's timelines
+func replayCacheField(status StreamStatus, bucket time.Duration) string {
+	return fmt.Sprintf("%d:%d", status.StartedAt, int64(bucket/time.Second))
+}
+
+// invalidateReplayTimelines drops a stream's cached timelines, for anything
+// that removes comments
+func invalidateReplayTimelines(ctx context.Context, pipe redis.Pipeliner, streamID int64) {
+	pipe.Del(ctx, replayTimelineKey(streamID))
+}
+
+// getReplayTimeline returns a finished broadcast's comments bucketed by
+// playback time
+func getReplayTimeline(c *gin.Context) {
+	streamID, ok := parseStreamID(c)
+	if !ok {
+		return
+	}
+	bucket := replayBucket
+	if v := c.Query("bucket"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds <= 0 || time.Duration(seconds)*time.Second > replayMaxBucket {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("bucket must be between 1 and %d seconds", int64(replayMaxBucket/time.Second))})
+			return
+		}
+		bucket = time.Duration(seconds) * time.Second
+	}
+
+	reqCtx := c.Request.Context()
+	status, err := loadStreamStatus(reqCtx, streamID)
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error loading stream status: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to load replay"})
+		return
+	}
+	if status.StartedAt == 0 {
+		c.JSON(404, gin.H{"error": "stream has no broadcast to replay"})
+		return
+	}
+	if status.Live {
+		c.JSON(409, gin.H{"error": "stream is still live"})
+		return
+	}
+
+	field := replayCacheField(status, bucket)
+	if cached, err := rdb.HGet(reqCtx, replayTimelineKey(streamID), field).Result(); err == nil {
+		c.Data(200, "application/json; charset=utf-8", []byte(cached))
+		return
+	} else if err != redis.Nil {
+		log.Printf("[GO] Stream %d: Error reading cached replay timeline: %v", streamID, err)
+	}
+
+	timeline, err := buildReplayTimeline(reqCtx, streamID, status, bucket)
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error building replay timeline: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to load replay"})
+		return
+	}
+	payload, err := json.Marshal(timeline)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to load replay"})
+		return
+	}
+	if replayCacheTTL > 0 {
+		_, err = rdb.Pipelined(reqCtx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(reqCtx, replayTimelineKey(streamID), field, payload)
+			pipe.Expire(reqCtx, replayTimelineKey(streamID), replayCacheTTL)
+			return nil
+		})
+		if err != nil {
+			log.Printf("[GO] Stream %d: Error caching replay timeline: %v", streamID, err)
+		}
+	}
+	c.Data(200, "application/json; charset=utf-8", payload)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// replayTimeline fetches stream 1's replay timeline
+func replayTimeline(t *testing.T, query string) (int, map[string]interface{}) {
+	t.Helper()
+	w := request(t, http.MethodGet, "/stream/1/replay/timeline"+query, nil)
+	return w.Code, decode(t, w)
+}
+
+// bucketIDs maps a timeline's bucket offsets to their comment IDs
+func bucketIDs(timeline map[string]interface{}) map[float64][]int64 {
+	out := map[float64][]int64{}
+	for _, b := range timeline["buckets"].([]interface{}) {
+		bucket := b.(map[string]interface{})
+		out[bucket["offset"].(float64)] = commentIDs(bucket)
+	}
+	return out
+}
+
+// endedBroadcast records a broadcast on stream 1 that started at start and
+// ran for a minute
+func endedBroadcast(start int64) {
+	rdb.Set(ctx, streamStartKey(1), start, 0)
+	rdb.Set(ctx, streamEndKey(1), start+time.Minute.Milliseconds(), 0)
+}
+
+func TestReplayTimelineBuckets(t *testing.T) {
+	resetRedis(t)
+	start := time.Now().Add(-10 * time.Minute).UnixMilli()
+	endedBroadcast(start)
+	saveAt(t, start-1, 9) // before the broadcast
+	saveAt(t, start, 1)
+	saveAt(t, start+999, 2)
+	saveAt(t, start+1000, 3) // on a boundary: starts the next bucket
+	saveAt(t, start+5500, 4)
+	saveAt(t, start+time.Minute.Milliseconds()+1, 8) // after it ended
+
+	status, timeline := replayTimeline(t, "")
+	if status != 200 || timeline["count"] != float64(4) || timeline["duration"] != float64(60) || timeline["bucket"] != float64(1) {
+		t.Fatalf("timeline = %d %v", status, timeline)
+	}
+	got := bucketIDs(timeline)
+	if len(got) != 3 || len(got[0]) != 2 || got[0][0] != 1 || got[0][1] != 2 || len(got[1]) != 1 || got[1][0] != 3 || len(got[5]) != 1 || got[5][0] != 4 {
+		t.Fatalf("1s buckets = %v, want 0:[1 2] 1:[3] 5:[4]", got)
+	}
+
+	_, timeline = replayTimeline(t, "?bucket=5")
+	got = bucketIDs(timeline)
+	if len(got) != 2 || len(got[0]) != 3 || len(got[5]) != 1 || got[5][0] != 4 {
+		t.Fatalf("5s buckets = %v, want 0:[1 2 3] 5:[4]", got)
+	}
+
+	for _, bucket := range []string{"0", "x", strconv.Itoa(int(replayMaxBucket/time.Second) + 1)} {
+		if status, _ := replayTimeline(t, "?bucket="+bucket); status != 400 {
+			t.Fatalf("bucket %s: %d, want 400", bucket, status)
+		}
+	}
+}
+
+func TestReplayTimelineCaching(t *testing.T) {
+	resetRedis(t)
+	start := time.Now().Add(-10 * time.Minute).UnixMilli()
+	endedBroadcast(start)
+	saveAt(t, start+100, 1, 2)
+
+	if _, timeline := replayTimeline(t, ""); timeline["count"] != float64(2) {
+		t.Fatalf("count = %v, want 2", timeline["count"])
+	}
+	// Served from the cache, which doesn't see comments written behind it
+	saveAt(t, start+2000, 3)
+	if _, timeline := replayTimeline(t, ""); timeline["count"] != float64(2) {
+		t.Fatalf("cached count = %v, want 2", timeline["count"])
+	}
+
+	// Removing a comment drops the cached timelines
+	if err := deleteComments(ctx, 1, []string{"1"}); err != nil {
+		t.Fatal(err)
+	}
+	_, timeline := replayTimeline(t, "")
+	if got := bucketIDs(timeline); timeline["count"] != float64(2) || len(got[0]) != 1 || got[0][0] != 2 || len(got[2]) != 1 {
+		t.Fatalf("after a delete = %v, want 0:[2] 2:[3]", got)
+	}
+}
+
+func TestReplayTimelineNeedsAnEndedBroadcast(t *testing.T) {
+	resetRedis(t)
+	if status, _ := replayTimeline(t, ""); status != 404 {
+		t.Fatalf("never started: %d, want 404", status)
+	}
+	rdb.Set(ctx, streamStartKey(1), time.Now().Add(-time.Minute).UnixMilli(), 0)
+	if status, _ := replayTimeline(t, ""); status != 409 {
+		t.Fatalf("still live: %d, want 409", status)
+	}
+}
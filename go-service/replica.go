@@ -0,0 +1,50 @@
+package main
+
+import (
+	"log"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// The feed reads behind check-update and the live feeds are most of the
+// service's Redis traffic, so they can go to a read replica
+// (REDIS_REPLICA_ADDR) while everything else, writes included, stays on the
+// primary. Without it feedRdb is rdb. A replica that errors falls back to the
+// primary for that read.
+//
+// Replica reads are eventually consistent: a comment shows up a
+// replication lag later than on the primary, so a viewer may not see their
+// own comment in the poll right after posting it, and a live feed woken by a
+// new comment may only pick it up on its next poll. last_id cursoring stays
+// gap-free under lag: the cursor is the newest timestamp a poll returned, and
+// the replica applies writes in the primary's order, so a poll never sees a
+// comment without everything the primary stored before it. It can however
+// miss a comment with an older timestamp that was stored after a newer one
+// (a scheduled comment, or a slow publish racing a fast one), exactly as the
+// primary can, only over a window widened by the lag. Reads that must see the
+// latest state (moderation, integrity checks, the orphan re-check in
+// corrupt.go) use the primary.
+var (
+	feedRdb        *redis.Client
+	replicaEnabled bool
+)
+
+func loadReplicaConfig() {
+	feedRdb = rdb
+	addr := envString("REDIS_REPLICA_ADDR", "")
+	if addr == "" {
+		return
+	}
+	feedRdb = redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: envString("REDIS_REPLICA_PASSWORD", ""),
+		DB:       0,
+	})
+	replicaEnabled = true
+	if err := feedRdb.Ping(ctx).Err(); err != nil {
+		// Not fatal: reads fall back to the primary until the replica is up
+		log.Printf("[GO] Warning: Redis replica %s unreachable, feed reads fall back to the primary: %v", addr, err)
+		return
+	}
+	log.Printf("[GO] Reading feeds from Redis replica %s", addr)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// withReplica points feed reads at a separate Redis for the rest of the test
+func withReplica(t *testing.T) *redis.Client {
+	t.Helper()
+	replica := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: replica.Addr()})
+	t.Cleanup(func() { client.Close() })
+	setVar(t, &feedRdb, client)
+	setVar(t, &replicaEnabled, true)
+	return client
+}
+
+// replicate copies stream 1's feed from the primary to the replica
+func replicate(t *testing.T, replica *redis.Client) {
+	t.Helper()
+	index, err := rdb.ZRangeWithScores(ctx, commentIndexKey(1), 0, -1).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := rdb.HGetAll(ctx, commentDataKey(1)).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, z := range index {
+		replica.ZAdd(ctx, commentIndexKey(1), &redis.Z{Score: z.Score, Member: z.Member})
+	}
+	for id, payload := range data {
+		replica.HSet(ctx, commentDataKey(1), id, payload)
+	}
+}
+
+func TestFeedReadsGoToTheReplica(t *testing.T) {
+	for _, script := range []bool{true, false} {
+		resetRedis(t)
+		setVar(t, &useFeedScript, script)
+		replica := withReplica(t)
+
+		expectStatus(t, post(t, 1, "v1", "alice", "one"), 200)
+		nextSecond()
+		// Writes only reach the primary; the replica hasn't caught up yet
+		if n, _ := replica.ZCard(ctx, commentIndexKey(1)).Result(); n != 0 {
+			t.Fatalf("replica (script %v) has %d comments, want writes on the primary only", script, n)
+		}
+		if got := messages(poll(t, 1, "v2", 0)); len(got) != 0 {
+			t.Fatalf("feed before replication (script %v) = %v, want the replica's empty view", script, got)
+		}
+
+		replicate(t, replica)
+		if got := strings.Join(messages(poll(t, 1, "v2", 0)), " "); got != "one" {
+			t.Fatalf("feed after replication (script %v) = %q, want one", script, got)
+		}
+	}
+}
+
+func TestFeedFallsBackToThePrimary(t *testing.T) {
+	resetRedis(t)
+	down := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	t.Cleanup(func() { down.Close() })
+	setVar(t, &feedRdb, down)
+	setVar(t, &replicaEnabled, true)
+
+	expectStatus(t, post(t, 1, "v1", "alice", "one"), 200)
+	nextSecond()
+	if got := strings.Join(messages(poll(t, 1, "v2", 0)), " "); got != "one" {
+		t.Fatalf("feed with the replica down = %q, want the primary's", got)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// An author's reputation in a stream summarizes how the community has
+// received them:
+//
+//	score = posts*REPUTATION_POST_WEIGHT
+//	      + reactions*REPUTATION_REACTION_WEIGHT
+//	      - reports*REPUTATION_REPORT_WEIGHT
+//	      - bans*REPUTATION_BAN_WEIGHT
+//	      + days since first post*REPUTATION_TENURE_WEIGHT (up to REPUTATION_TENURE_CAP days)
+//
+// The counts are kept per author (by username) in the stream's reputation
+// hash and bumped as comments, reactions, reports and bans happen; the score
+// is derived from them on read, so weight changes apply to past activity too.
+// Names are only an identity when a trusted caller vouched for them, so only
+// verified comments (see commentOrigin) build or consult a reputation, and
+// only reactions and reports sent by a trusted caller count towards it.
+// Verified authors at or above REPUTATION_TRUSTED skip slow mode, the new
+// viewer wait and the per-viewer rate limit. Authors at or below REPUTATION_LOW get at
+// least REPUTATION_LOW_SLOW_MODE seconds of slow mode and have masked
+// profanity rejected instead.
+const (
+	repPosts     = "posts"
+	repReactions = "reactions"
+	repReports   = "reports"
+	repBans      = "bans"
+	repFirstPost = "first_post"
+)
+
+// Reputation tiers
+const (
+	reputationTrusted = "trusted"
+	reputationNormal  = "normal"
+	reputationLow     = "low"
+)
+
+var (
+	reputationPostWeight     float64
+	reputationReactionWeight float64
+	reputationReportWeight   float64
+	reputationBanWeight      float64
+	reputationTenureWeight   float64
+	reputationTenureCap      float64 // days
+	reputationTrustedAt      float64
+	reputationLowAt          float64
+	reputationLowSlowMode    int
+)
+
+func loadReputationConfig() {
+	reputationPostWeight = envFloat("REPUTATION_POST_WEIGHT", 0.2)
+	reputationReactionWeight = envFloat("REPUTATION_REACTION_WEIGHT", 1)
+	reputationReportWeight = envFloat("REPUTATION_REPORT_WEIGHT", 5)
+	reputationBanWeight = envFloat("REPUTATION_BAN_WEIGHT", 25)
+	reputationTenureWeight = envFloat("REPUTATION_TENURE_WEIGHT", 1)
+	reputationTenureCap = envFloat("REPUTATION_TENURE_CAP", 30)
+	reputationTrustedAt = envFloat("REPUTATION_TRUSTED", 50)
+	reputationLowAt = envFloat("REPUTATION_LOW", -10)
+	reputationLowSlowMode = envInt("REPUTATION_LOW_SLOW_MODE", 30)
+}
+
+// reputationField is the author's entry for one count in the stream hash
+func reputationField(count, username string) string {
+	return count + ":" + username
+}
+
+// bumpReputation adds delta to one of an author's counts
+func bumpReputation(ctx context.Context, streamID int64, username, count string, delta int64) {
+	if username == "" || delta == 0 {
+		return
+	}
+	if err := rdb.HIncrBy(ctx, reputationKey(streamID), reputationField(count, username), delta).Err(); err != nil {
+		log.Printf("[GO] Stream %d: Error updating reputation for %s: %v", streamID, username, err)
+	}
+}
+
+// recordPostReputation counts a published comment towards its author
+func recordPostReputation(ctx context.Context, streamID int64, username string, postedAt int64) {
+	_, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HIncrBy(ctx, reputationKey(streamID), reputationField(repPosts, username), 1)
+		pipe.HSetNX(ctx, reputationKey(streamID), reputationField(repFirstPost, username), postedAt)
+		return nil
+	})
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error updating reputation for %s: %v", streamID, username, err)
+	}
+}
+
+// commentAuthor returns the username in a stored comment whose author was
+// verified, "" for anyone else or if undecodable
+func commentAuthor(data string) string {
+	var cmt Comment
+	if json.Unmarshal([]byte(data), &cmt) != nil || cmt.Type == commentTypeSystem || !cmt.Verified {
+		return ""
+	}
+	return cmt.Username
+}
+
+// Reputation is an author's standing in a stream
+type Reputation struct {
+	Username  string  `json:"username"`
+	Score     float64 `json:"score"`
+	Tier      string  `json:"tier"`
+	Posts     int64   `json:"posts"`
+	Reactions int64   `json:"reactions"`
+	Reports   int64   `json:"reports"`
+	Bans      int64   `json:"bans"`
+	FirstPost int64   `json:"first_post,omitempty"` // ms
+}
+
+// loadReputation reads an author's counts and scores them
+func loadReputation(ctx context.Context, streamID int64, username string) (Reputation, error) {
+	rep := Reputation{Username: username, Tier: reputationNormal}
+	fields := []string{repPosts, repReactions, repReports, repBans, repFirstPost}
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = reputationField(f, username)
+	}
+	vals, err := rdb.HMGet(ctx, reputationKey(streamID), names...).Result()
+	if err != nil {
+		return rep, err
+	}
+	counts := make([]int64, len(vals))
+	for i, v := range vals {
+		if s, ok := v.(string); ok {
+			counts[i], _ = strconv.ParseInt(s, 10, 64)
+		}
+	}
+	rep.Posts, rep.Reactions, rep.Reports, rep.Bans, rep.FirstPost = counts[0], counts[1], counts[2], counts[3], counts[4]
+
+	score := float64(rep.Posts)*reputationPostWeight +
+		float64(rep.Reactions)*reputationReactionWeight -
+		float64(rep.Reports)*reputationReportWeight -
+		float64(rep.Bans)*reputationBanWeight
+	if rep.FirstPost > 0 {
+		days := time.Since(time.UnixMilli(rep.FirstPost)).Hours() / 24
+		score += math.Min(math.Max(days, 0), reputationTenureCap) * reputationTenureWeight
+	}
+	rep.Score = math.Round(score*100) / 100
+	switch {
+	case rep.Score >= reputationTrustedAt:
+		rep.Tier = reputationTrusted
+	case rep.Score <= reputationLowAt:
+		rep.Tier = reputationLow
+	}
+	return rep, nil
+}
+
+// authorTier returns an author's reputation tier, normal when unknown
+func authorTier(ctx context.Context, streamID int64, username string) string {
+	rep, err := loadReputation(ctx, streamID, username)
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error loading reputation for %s: %v", streamID, username, err)
+		return reputationNormal
+	}
+	return rep.Tier
+}
+
+// applyReputation adjusts a stream's modes for an author's tier
+func applyReputation(modes StreamModes, tier string) StreamModes {
+	switch tier {
+	case reputationTrusted:
+		modes.SlowMode = 0
+		modes.NewViewerWait = 0
+	case reputationLow:
+		if modes.SlowMode < reputationLowSlowMode {
+			modes.SlowMode = reputationLowSlowMode
+		}
+		actions := make(map[string]string, len(modes.ProfanityActions))
+		for t, action := range modes.ProfanityActions {
+			if action == profanityMask {
+				action = profanityReject
+			}
+			actions[t] = action
+		}
+		modes.ProfanityActions = actions
+	}
+	return modes
+}
+
+// getReputation shows moderators an author's reputation
+func getReputation(c *gin.Context) {
+	streamID, ok := parseStreamID(c)
+	if !ok {
+		return
+	}
+	username := c.Query("username")
+	if username == "" {
+		c.JSON(400, gin.H{"error": "username is required"})
+		return
+	}
+	rep, err := loadReputation(c.Request.Context(), streamID, username)
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error loading reputation for %s: %v", streamID, username, err)
+		c.JSON(500, gin.H{"error": "failed to load reputation"})
+		return
+	}
+	c.JSON(200, rep)
+}
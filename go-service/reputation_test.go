@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// reputation reads an author's reputation in stream 1 as a moderator
+func reputation(t *testing.T, username string) map[string]interface{} {
+	t.Helper()
+	w := request(t, http.MethodGet, "/stream/1/reputation?username="+username, nil, asRole(roleModerator)...)
+	expectStatus(t, w, 200)
+	return decode(t, w)
+}
+
+// vouchedID posts to stream 1 as an author a trusted caller vouched for and
+// returns the comment's ID
+func vouchedID(t *testing.T, viewerID, username, message string) int64 {
+	t.Helper()
+	w := post(t, 1, viewerID, username, message, trusted...)
+	expectStatus(t, w, 200)
+	return int64(decode(t, w)["comment"].(map[string]interface{})["id"].(float64))
+}
+
+func TestReputationScoreChanges(t *testing.T) {
+	resetRedis(t)
+	if rep := reputation(t, "alice"); rep["score"] != float64(0) || rep["tier"] != reputationNormal {
+		t.Fatalf("unknown author = %v, want 0 normal", rep)
+	}
+
+	id := vouchedID(t, "v1", "alice", "hello")
+	vouchedID(t, "v1", "alice", "again")
+	for i := 0; i < 3; i++ {
+		react(t, id, fmt.Sprintf("r%d", i), "like", trusted...)
+	}
+	rep := reputation(t, "alice")
+	if rep["posts"] != float64(2) || rep["reactions"] != float64(3) || rep["first_post"] == nil {
+		t.Fatalf("counts = %v, want 2 posts and 3 reactions", rep)
+	}
+	if rep["score"] != float64(3.4) {
+		t.Fatalf("score = %v, want 2*0.2 + 3*1", rep["score"])
+	}
+
+	report(t, id, "r9", trusted...)
+	if rep := reputation(t, "alice"); rep["reports"] != float64(1) || rep["score"] != float64(-1.6) {
+		t.Fatalf("after a report = %v, want score -1.6", rep)
+	}
+	if err := storeBan(ctx, 1, ModerationTarget{ViewerID: "v1", Username: "alice"}, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	if rep := reputation(t, "alice"); rep["bans"] != float64(1) || rep["score"] != float64(-26.6) || rep["tier"] != reputationLow {
+		t.Fatalf("after a ban = %v, want score -26.6 low", rep)
+	}
+
+	expectStatus(t, request(t, http.MethodGet, "/stream/1/reputation", nil, asRole(roleModerator)...), 400)
+	expectStatus(t, request(t, http.MethodGet, "/stream/1/reputation?username=alice", nil, trusted...), 403)
+}
+
+func TestReputationGatesSlowMode(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &reputationTrustedAt, 3)
+	rdb.HSet(ctx, modesKey(1), "slow_mode", "30")
+
+	// Reactions put alice over the trusted threshold
+	id := vouchedID(t, "v1", "alice", "first")
+	for i := 0; i < 3; i++ {
+		react(t, id, fmt.Sprintf("r%d", i), "like", trusted...)
+	}
+	expectStatus(t, post(t, 1, "v1", "alice", "trusted skips slow mode", trusted...), 200)
+
+	// Claiming alice's name without a trusted caller earns nothing
+	expectStatus(t, post(t, 1, "attacker", "alice", "first"), 200)
+	if w := post(t, 1, "attacker", "alice", "second"); w.Code != 429 || decode(t, w)["reason"] != "slow_mode" {
+		t.Fatalf("unverified poster using a trusted name: %d %s, want 429 slow_mode", w.Code, w.Body.String())
+	}
+
+	// bob is a normal author and waits
+	expectStatus(t, post(t, 1, "v2", "bob", "first"), 200)
+	if w := post(t, 1, "v2", "bob", "second"); w.Code != 429 || decode(t, w)["reason"] != "slow_mode" {
+		t.Fatalf("normal author: %d %s, want 429 slow_mode", w.Code, w.Body.String())
+	}
+}
+
+func TestLowReputationGetsSlowMode(t *testing.T) {
+	resetRedis(t)
+	id := vouchedID(t, "v1", "carol", "first")
+	for i := 0; i < 3; i++ {
+		report(t, id, fmt.Sprintf("r%d", i), trusted...)
+	}
+	if rep := reputation(t, "carol"); rep["tier"] != reputationLow {
+		t.Fatalf("carol = %v, want low", rep)
+	}
+
+	expectStatus(t, post(t, 1, "v1", "carol", "second", trusted...), 200)
+	w := post(t, 1, "v1", "carol", "third", trusted...)
+	if w.Code != 429 || decode(t, w)["retry_after"] != float64(reputationLowSlowMode) {
+		t.Fatalf("low author: %d %s, want %ds of slow mode", w.Code, w.Body.String(), reputationLowSlowMode)
+	}
+	expectStatus(t, post(t, 1, "v2", "dave", "one"), 200)
+	expectStatus(t, post(t, 1, "v2", "dave", "two"), 200)
+}
+
+func TestReputationIgnoresUnverifiedInput(t *testing.T) {
+	resetRedis(t)
+	spoofed := postedID(t, 1, "attacker", "alice", "posing as alice")
+	vouched := vouchedID(t, "v1", "alice", "the real alice")
+
+	// Reactions and reports from arbitrary viewer_ids don't move anyone's
+	// reputation, and comments under a claimed name don't earn any
+	for i := 0; i < 3; i++ {
+		react(t, vouched, fmt.Sprintf("sock%d", i), "like")
+		report(t, vouched, fmt.Sprintf("sock%d", i))
+	}
+	react(t, spoofed, "r1", "like", trusted...)
+	report(t, spoofed, "r2", trusted...)
+	if rep := reputation(t, "alice"); rep["posts"] != float64(1) || rep["reactions"] != float64(0) || rep["reports"] != float64(0) {
+		t.Fatalf("alice = %v, want only the vouched post counted", rep)
+	}
+}
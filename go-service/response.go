@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiVersion lets clients detect response format changes
+const apiVersion = "1"
+
+// responseEnvelope adds server_time (ms) and api_version to every JSON object
+// response, so clients can correct for clock skew on any call. Both fields
+// are additions old clients ignore; RESPONSE_ENVELOPE=false leaves them out
+// of everything but check-update, which always carries them.
+var responseEnvelope bool
+
+func loadResponseConfig() {
+	responseEnvelope = envBool("RESPONSE_ENVELOPE", true)
+}
+
+// decodeDocument parses a JSON payload keeping numbers exact
+func decodeDocument(payload []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	var doc interface{}
+	err := dec.Decode(&doc)
+	return doc, err
+}
+
+// jsonWriter buffers JSON responses so they can be rewritten once the
+// handler is done; anything else (e.g. event streams) passes straight through
+type jsonWriter struct {
+	gin.ResponseWriter
+	buf bytes.Buffer
+}
+
+func (w *jsonWriter) buffering() bool {
+	return strings.HasPrefix(w.Header().Get("Content-Type"), "application/json")
+}
+
+func (w *jsonWriter) Write(b []byte) (int, error) {
+	if w.buffering() {
+		return w.buf.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *jsonWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Unwrap lets http.ResponseController reach the connection, e.g. for the
+// write deadlines of event streams
+func (w *jsonWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// responseMiddleware applies the per-response JSON rewrites: string IDs for
+// clients that ask for them and the server time envelope
+func responseMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		stringIDs := wantsStringIDs(c)
+		if !stringIDs && !responseEnvelope {
+			c.Next()
+			return
+		}
+		w := &jsonWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		// If the handler panics, the recovery response bypasses the buffer
+		defer func() { c.Writer = w.ResponseWriter }()
+		c.Next()
+		if w.buf.Len() == 0 {
+			return
+		}
+
+		out := w.buf.Bytes()
+		if doc, err := decodeDocument(out); err == nil {
+			if stringIDs {
+				doc = quoteIDValues(doc)
+			}
+			if obj, ok := doc.(map[string]interface{}); ok && responseEnvelope {
+				if _, ok := obj["server_time"]; !ok {
+					obj["server_time"] = time.Now().UnixMilli()
+				}
+				obj["api_version"] = apiVersion
+			}
+			if rewritten, err := json.Marshal(doc); err == nil {
+				out = rewritten
+			}
+		}
+		w.ResponseWriter.Write(out)
+	}
+}
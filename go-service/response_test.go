@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestResponsesCarryServerTime(t *testing.T) {
+	resetRedis(t)
+	before := time.Now().UnixMilli()
+	w := request(t, http.MethodGet, "/stream/1/reactions", nil)
+	expectStatus(t, w, 200)
+	resp := decode(t, w)
+	if st, _ := resp["server_time"].(float64); int64(st) < before || int64(st) > time.Now().UnixMilli() {
+		t.Fatalf("server_time = %v, want the time of the request", resp["server_time"])
+	}
+	if resp["api_version"] != apiVersion {
+		t.Fatalf("api_version = %v, want %s", resp["api_version"], apiVersion)
+	}
+}
+
+func TestServerTimeIsMonotonic(t *testing.T) {
+	resetRedis(t)
+	last := 0.0
+	for i := 0; i < 3; i++ {
+		st, _ := poll(t, 1, "v1", 0)["server_time"].(float64)
+		if st < last {
+			t.Fatalf("server_time went from %v to %v", last, st)
+		}
+		last = st
+		time.Sleep(2 * time.Millisecond)
+	}
+}
+
+func TestResponseEnvelopeCanBeTurnedOff(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &responseEnvelope, false)
+	if resp := decode(t, request(t, http.MethodGet, "/stream/1/reactions", nil)); resp["server_time"] != nil {
+		t.Fatalf("server_time = %v with the envelope off", resp["server_time"])
+	}
+	if resp := poll(t, 1, "v1", 0); resp["server_time"] == nil {
+		t.Fatal("check-update lost server_time with the envelope off")
+	}
+}
+
+// deadlineRecorder records write deadlines, like a connection would
+type deadlineRecorder struct {
+	*httptest.ResponseRecorder
+	deadline time.Time
+}
+
+func (r *deadlineRecorder) SetWriteDeadline(d time.Time) error {
+	r.deadline = d
+	return nil
+}
+
+func TestJSONWriterReachesConnection(t *testing.T) {
+	rec := &deadlineRecorder{ResponseRecorder: httptest.NewRecorder()}
+	c, _ := gin.CreateTestContext(rec)
+	w := &jsonWriter{ResponseWriter: c.Writer}
+
+	deadline := time.Now().Add(time.Minute)
+	if err := http.NewResponseController(w).SetWriteDeadline(deadline); err != nil {
+		t.Fatalf("SetWriteDeadline through the JSON writer: %v", err)
+	}
+	if !rec.deadline.Equal(deadline) {
+		t.Fatal("the deadline didn't reach the connection")
+	}
+}
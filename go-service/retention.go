@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Ephemeral streams (tests, short-lived events) let their comment data expire
+// after a period of inactivity. A stream opts in via stream:ttl:<stream_id>
+// (seconds); EPHEMERAL_STREAM_TTL applies a default to every stream.
+// Each comment and heartbeat pushes the expiry out again, so a stream only
+// expires once nobody is posting or watching. The comment ID sequence never
+// expires: reactions, reports and history are keyed by comment ID and outlive
+// the stream's data, so a reset sequence would hand their entries to new
+// comments.
+var defaultStreamTTL time.Duration
+
+// minStreamTTL keeps an idle TTL from being shorter than a viewer's presence,
+// so a watched stream can't expire between heartbeats
+const minStreamTTL = 5 * time.Minute
+
+func loadRetentionConfig() {
+	defaultStreamTTL = time.Duration(envInt("EPHEMERAL_STREAM_TTL", 0)) * time.Second
+}
+
+// streamIdleTTL returns the stream's inactivity TTL, or 0 if it never expires
+func streamIdleTTL(ctx context.Context, streamID int64) time.Duration {
+	ttl := defaultStreamTTL
+	if seconds, err := rdb.Get(ctx, streamTTLKey(streamID)).Int(); err == nil {
+		ttl = time.Duration(seconds) * time.Second
+	} else if err != redis.Nil {
+		log.Printf("[GO] Stream %d: Error loading stream TTL: %v", streamID, err)
+	}
+	if ttl <= 0 {
+		return 0
+	}
+	if ttl < minStreamTTL {
+		ttl = minStreamTTL
+	}
+	return ttl
+}
+
+// touchStream refreshes the expiry of an ephemeral stream's comment keys,
+// and the stream's activity for eviction (see eviction.go)
+func touchStream(ctx context.Context, streamID int64) {
+	touchStreamActivity(ctx, streamID)
+	ttl := streamIdleTTL(ctx, streamID)
+	if ttl == 0 {
+		return
+	}
+	_, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, k := range ephemeralStreamKeys(streamID) {
+			pipe.Expire(ctx, k, ttl)
+		}
+		// Earlier versions expired the sequence with the stream
+		pipe.Persist(ctx, commentSeqKey(streamID))
+		return nil
+	})
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error refreshing stream TTL: %v", streamID, err)
+	}
+}
+
+// ephemeralStreamKeys are the per-stream keys that expire with an idle stream
+func ephemeralStreamKeys(streamID int64) []string {
+	return []string{
+		commentIndexKey(streamID),
+		priorityKey(streamID),
+		commentDataKey(streamID),
+		viewerNamesKey(streamID),
+		removalsKey(streamID),
+		guestNamesKey(streamID),
+		engagementKey(streamID),
+		reportCountsKey(streamID),
+		reputationKey(streamID),
+		featuredQueueKey(streamID),
+		featuredEntriesKey(streamID),
+		streamLinksKey(streamID),
+		replayTimelineKey(streamID),
+		editsKey(streamID),
+		chatBufferKey(streamID),
+		filterStatsKey(streamID),
+		platformStatsKey(streamID),
+		emoteUsageKey(streamID),
+		streamStartKey(streamID),
+		streamEndKey(streamID),
+		streamPeakKey(streamID),
+		streamExistsKey(streamID),
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIdleEphemeralStreamExpires(t *testing.T) {
+	resetRedis(t)
+	rdb.Set(ctx, streamTTLKey(1), 600, 0)
+	expectStatus(t, post(t, 1, "v1", "alice", "short-lived"), 200)
+	if ttl := testRedis.TTL(commentDataKey(1)); ttl != 10*time.Minute {
+		t.Fatalf("data TTL = %v, want 10m", ttl)
+	}
+
+	// Watching keeps the stream alive past its original TTL
+	testRedis.FastForward(8 * time.Minute)
+	heartbeatSession(t, "v2", "")
+	testRedis.FastForward(8 * time.Minute)
+	if !testRedis.Exists(commentDataKey(1)) || !testRedis.Exists(commentIndexKey(1)) {
+		t.Fatal("an active stream's comments expired")
+	}
+
+	testRedis.FastForward(10*time.Minute + time.Second)
+	for _, k := range []string{commentIndexKey(1), commentDataKey(1), viewerNamesKey(1)} {
+		if testRedis.Exists(k) {
+			t.Errorf("%s survived the idle TTL", k)
+		}
+	}
+	if got := messages(poll(t, 1, "v2", 0)); len(got) != 0 {
+		t.Fatalf("comments after expiry = %v, want none", got)
+	}
+}
+
+func TestExpiredStreamNeverReusesCommentIDs(t *testing.T) {
+	resetRedis(t)
+	rdb.Set(ctx, streamTTLKey(1), 600, 0)
+	first := postedID(t, 1, "v1", "alice", "before")
+	react(t, first, "v2", "like")
+	testRedis.FastForward(10*time.Minute + time.Second)
+
+	next := postedID(t, 1, "v1", "alice", "after")
+	if next <= first {
+		t.Fatalf("comment ID after expiry = %d, want past %d", next, first)
+	}
+	if testRedis.Exists(reactionCountsKey(1, next)) {
+		t.Fatal("new comment inherited an expired comment's reactions")
+	}
+}
+
+func TestStreamTTLFloorAndDefault(t *testing.T) {
+	resetRedis(t)
+	expectStatus(t, post(t, 1, "v1", "alice", "kept"), 200)
+	if ttl := testRedis.TTL(commentDataKey(1)); ttl != 0 {
+		t.Fatalf("TTL without a setting = %v, want none", ttl)
+	}
+
+	rdb.Set(ctx, streamTTLKey(2), 10, 0)
+	expectStatus(t, post(t, 2, "v1", "alice", "floored"), 200)
+	if ttl := testRedis.TTL(commentDataKey(2)); ttl != minStreamTTL {
+		t.Fatalf("TTL = %v, want it raised to %v", ttl, minStreamTTL)
+	}
+
+	setVar(t, &defaultStreamTTL, time.Hour)
+	rdb.Set(ctx, streamTTLKey(3), 0, 0)
+	expectStatus(t, post(t, 3, "v1", "alice", "opted out"), 200)
+	if ttl := testRedis.TTL(commentDataKey(3)); ttl != 0 {
+		t.Fatalf("TTL of a stream opted out of the default = %v, want none", ttl)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// On mega-streams comments arrive faster than anyone can read them, so above
+// a velocity threshold viewers get a sample of the feed instead of all of it.
+// While the stream's rate over SAMPLING_WINDOW exceeds SAMPLING_THRESHOLD
+// (comments/s, 0 = off), each poll keeps about 1 in N comments where N brings
+// the rate down to SAMPLING_TARGET. SAMPLING_STRATEGY picks which ones:
+//
+//   - "nth" keeps comments by a hash of their ID, so every viewer sees the
+//     same sample and a comment's fate doesn't depend on how polls line up
+//   - "engagement" keeps each poll's most engaged comments (reactions,
+//     reports; see engagement.go), ties falling back to the hash
+//
+// Either way the kept comments stay in feed order, system messages and the
+// viewer's own comments are always kept, and the response reports the total
+// so clients can show the real volume. Moderators always get the full feed.
+// Streams override the threshold and strategy with sampling_threshold and
+// sampling_strategy in their modes.
+const (
+	samplingNth        = "nth"
+	samplingEngagement = "engagement"
+)
+
+var (
+	samplingThreshold float64
+	samplingTarget    float64
+	samplingWindow    time.Duration
+	samplingStrategy  string
+)
+
+func loadSamplingConfig() {
+	samplingThreshold = envFloat("SAMPLING_THRESHOLD", 0)
+	samplingTarget = envFloat("SAMPLING_TARGET", 5)
+	if samplingTarget <= 0 {
+		samplingTarget = 5
+	}
+	samplingWindow = time.Duration(envInt("SAMPLING_WINDOW", 10)) * time.Second
+	samplingStrategy = parseSamplingStrategy(envString("SAMPLING_STRATEGY", samplingNth))
+}
+
+func parseSamplingStrategy(value string) string {
+	switch v := strings.ToLower(strings.TrimSpace(value)); v {
+	case samplingNth, samplingEngagement:
+		return v
+	case "":
+	default:
+		log.Printf("[GO] Warning: unknown sampling strategy %q, using %q", value, samplingNth)
+	}
+	return samplingNth
+}
+
+// SamplingInfo tells the client its comments were sampled
+type SamplingInfo struct {
+	Total    int     `json:"total"` // comments before sampling
+	Shown    int     `json:"shown"`
+	Rate     float64 `json:"rate"` // stream's comments per second
+	Strategy string  `json:"strategy"`
+}
+
+// commentHash spreads comment IDs evenly (the splitmix64 finalizer), so
+// sequential and snowflake IDs alike sample uniformly
+func commentHash(id int64) uint64 {
+	z := uint64(id) + 0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// sampleComments thins chronologically ordered comments for a stream over its
+// sampling threshold. It returns the comments unchanged and nil when the
+// stream is below it.
+func sampleComments(ctx context.Context, streamID int64, viewerID string, modes StreamModes, comments []Comment) ([]Comment, *SamplingInfo) {
+	if modes.SamplingThreshold <= 0 || len(comments) == 0 {
+		return comments, nil
+	}
+	rate, err := commentRate(ctx, streamID, samplingWindow)
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error reading comment rate: %v", streamID, err)
+		return comments, nil
+	}
+	if rate < modes.SamplingThreshold {
+		return comments, nil
+	}
+	every := int(math.Ceil(rate / samplingTarget))
+	if every <= 1 {
+		return comments, nil
+	}
+
+	var own string
+	if viewerID != "" {
+		own, _ = rdb.HGet(ctx, viewerNamesKey(streamID), viewerID).Result()
+	}
+	keep := make([]bool, len(comments))
+	var candidates []int
+	for i, cmt := range comments {
+		if cmt.Type == commentTypeSystem || (own != "" && cmt.Username == own) {
+			keep[i] = true
+		} else {
+			candidates = append(candidates, i)
+		}
+	}
+
+	switch modes.SamplingStrategy {
+	case samplingEngagement:
+		keepMostEngaged(ctx, streamID, comments, candidates, every, keep)
+	default:
+		for _, i := range candidates {
+			if commentHash(comments[i].ID)%uint64(every) == 0 {
+				keep[i] = true
+			}
+		}
+	}
+
+	sampled := make([]Comment, 0, len(comments)/every+1)
+	for i, cmt := range comments {
+		if keep[i] {
+			sampled = append(sampled, cmt)
+		}
+	}
+	return sampled, &SamplingInfo{Total: len(comments), Shown: len(sampled), Rate: math.Round(rate*10) / 10, Strategy: modes.SamplingStrategy}
+}
+
+// keepMostEngaged marks the top 1 in every candidates by engagement score
+func keepMostEngaged(ctx context.Context, streamID int64, comments []Comment, candidates []int, every int, keep []bool) {
+	if len(candidates) == 0 {
+		return
+	}
+	scores := make([]float64, len(candidates))
+	cmds, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, i := range candidates {
+			pipe.ZScore(ctx, engagementKey(streamID), strconv.FormatInt(comments[i].ID, 10))
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		log.Printf("[GO] Stream %d: Error loading engagement for sampling: %v", streamID, err)
+	}
+	for j, cmd := range cmds {
+		if z, ok := cmd.(*redis.FloatCmd); ok {
+			scores[j], _ = z.Result()
+		}
+	}
+
+	order := make([]int, len(candidates))
+	for j := range order {
+		order[j] = j
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		if scores[order[a]] != scores[order[b]] {
+			return scores[order[a]] > scores[order[b]]
+		}
+		return commentHash(comments[candidates[order[a]]].ID) < commentHash(comments[candidates[order[b]]].ID)
+	})
+	n := int(math.Ceil(float64(len(candidates)) / float64(every)))
+	for _, j := range order[:n] {
+		keep[candidates[j]] = true
+	}
+}
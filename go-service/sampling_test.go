@@ -0,0 +1,104 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// commentIDs lists the IDs of a decoded response's comments
+func commentIDs(resp map[string]interface{}) []int64 {
+	list, _ := resp["comments"].([]interface{})
+	out := make([]int64, 0, len(list))
+	for _, c := range list {
+		out = append(out, int64(c.(map[string]interface{})["id"].(float64)))
+	}
+	return out
+}
+
+// busyStream stores 40 comments on stream 1 and records a rate of 20/s
+// against a sampling threshold of 10/s and the default target of 5/s
+func busyStream(t *testing.T, strategy string) {
+	t.Helper()
+	resetRedis(t)
+	ids := make([]int64, 40)
+	for i := range ids {
+		ids[i] = int64(i + 1)
+	}
+	saveAt(t, time.Now().Add(-time.Minute).UnixMilli(), ids...)
+	rdb.HSet(ctx, modesKey(1), "sampling_threshold", "10", "sampling_strategy", strategy)
+	rdb.Set(ctx, velocityKey(1, velocityBucketAt(time.Now())), 200, 0)
+	setVar(t, &samplingTarget, 5)
+	setVar(t, &samplingWindow, 10*time.Second)
+}
+
+func TestSamplingReducesVolumeInOrder(t *testing.T) {
+	busyStream(t, samplingNth)
+
+	resp := poll(t, 1, "v9", 0)
+	ids := commentIDs(resp)
+	if len(ids) == 0 || len(ids) >= 40 {
+		t.Fatalf("sampled %d of 40 comments", len(ids))
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] <= ids[i-1] {
+			t.Fatalf("sampled comments out of order: %v", ids)
+		}
+	}
+	for _, id := range ids {
+		if commentHash(id)%4 != 0 {
+			t.Fatalf("comment %d kept, want only 1 in 4 by hash", id)
+		}
+	}
+	info := resp["sampling"].(map[string]interface{})
+	if info["total"] != float64(40) || info["shown"] != float64(len(ids)) || info["strategy"] != samplingNth {
+		t.Fatalf("sampling = %v, want total 40 shown %d", info, len(ids))
+	}
+
+	// Every viewer sees the same sample
+	if other := commentIDs(poll(t, 1, "v8", 0)); len(other) != len(ids) {
+		t.Fatalf("another viewer sampled %v, want %v", other, ids)
+	}
+	// The cursor still moves past the whole feed
+	if got := commentIDs(poll(t, 1, "v9", int64(resp["cursor"].(float64)))); len(got) != 0 {
+		t.Fatalf("after the sampled cursor = %v, want nothing", got)
+	}
+}
+
+func TestSamplingSkipsModeratorsAndQuietStreams(t *testing.T) {
+	busyStream(t, samplingNth)
+
+	resp := poll(t, 1, "mod", 0, asRole(roleModerator)...)
+	if got := commentIDs(resp); len(got) != 40 {
+		t.Fatalf("moderator got %d comments, want all 40", len(got))
+	}
+	if _, ok := resp["sampling"]; ok {
+		t.Fatal("moderator response was sampled")
+	}
+
+	rdb.Set(ctx, velocityKey(1, velocityBucketAt(time.Now())), 50, 0)
+	rdb.Del(ctx, velocityKey(1, velocityBucketAt(time.Now())-1))
+	if got := commentIDs(poll(t, 1, "v9", 0)); len(got) != 40 {
+		t.Fatalf("below the threshold got %d comments, want all 40", len(got))
+	}
+}
+
+func TestSamplingByEngagement(t *testing.T) {
+	busyStream(t, samplingEngagement)
+	for _, id := range []int64{3, 17, 22, 31, 38, 5, 9, 12, 26, 40} {
+		rdb.ZAdd(ctx, engagementKey(1), &redis.Z{Score: float64(id), Member: strconv.FormatInt(id, 10)})
+	}
+
+	ids := commentIDs(poll(t, 1, "v9", 0))
+	want := []int64{3, 5, 9, 12, 17, 22, 26, 31, 38, 40}
+	if len(ids) != len(want) {
+		t.Fatalf("kept %v, want the most engaged %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("kept %v, want the most engaged %v in feed order", ids, want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+)
+
+// Invisible character policies (INVISIBLE_CHAR_POLICY)
+const (
+	invisibleStrip  = "strip"
+	invisibleReject = "reject"
+)
+
+// Invisible characters are used to slip past filters and to mangle chat
+// layout. ZWNJ (U+200C) is deliberately allowed since Persian text needs it,
+// as are ZWJ and variation selectors needed by emoji sequences.
+var (
+	invisiblePolicy   string
+	maxCombiningMarks int // per base character, guards against Zalgo text
+)
+
+func loadSanitizeConfig() {
+	invisiblePolicy = strings.ToLower(strings.TrimSpace(envString("INVISIBLE_CHAR_POLICY", invisibleStrip)))
+	if invisiblePolicy != invisibleStrip && invisiblePolicy != invisibleReject {
+		log.Printf("[GO] Warning: unknown INVISIBLE_CHAR_POLICY %q, using %q", invisiblePolicy, invisibleStrip)
+		invisiblePolicy = invisibleStrip
+	}
+	maxCombiningMarks = envInt("MAX_COMBINING_MARKS", 4)
+}
+
+// isDisallowedInvisible reports whether r is an invisible or control
+// character that has no place in a chat message
+func isDisallowedInvisible(r rune) bool {
+	switch {
+	case r == '\n' || r == '\t':
+		return false
+	case r == 0x200B, r == 0x2060, r == 0xFEFF, r == 0x00AD, r == 0x180E: // zero-width space/joiners, BOM, soft hyphen
+		return true
+	case r >= 0x202A && r <= 0x202E: // bidi embeddings and overrides
+		return true
+	case r >= 0x2066 && r <= 0x2069: // bidi isolates
+		return true
+	case r >= 0x2061 && r <= 0x2064: // invisible math operators
+		return true
+	case unicode.Is(unicode.Cc, r):
+		return true
+	}
+	return false
+}
+
+// sanitizeMessage applies the invisible-character policy and the combining
+// mark limit. It returns the cleaned message, or a reason it was refused.
+func sanitizeMessage(message string) (string, string) {
+	var b strings.Builder
+	b.Grow(len(message))
+	marks := 0
+	for _, r := range message {
+		if isDisallowedInvisible(r) {
+			if invisiblePolicy == invisibleReject {
+				return "", fmt.Sprintf("message contains a disallowed invisible character (U+%04X)", r)
+			}
+			continue
+		}
+		if unicode.Is(unicode.Mn, r) && r != 0xFE0F && r != 0xFE0E {
+			marks++
+			if maxCombiningMarks > 0 && marks > maxCombiningMarks {
+				if invisiblePolicy == invisibleReject {
+					return "", fmt.Sprintf("message stacks more than %d combining marks on a character", maxCombiningMarks)
+				}
+				continue
+			}
+		} else {
+			marks = 0
+		}
+		b.WriteRune(r)
+	}
+
+	cleaned := b.String()
+	if strings.TrimSpace(cleaned) == "" {
+		return "", "message is empty"
+	}
+	return cleaned, ""
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeMessage(t *testing.T) {
+	zalgo := "h" + strings.Repeat("\u0301", 12) + "i"
+	for _, tc := range []struct {
+		name, policy, in, want string
+		rejected               bool
+	}{
+		{"zero-width space", invisibleStrip, "bad\u200bword", "badword", false},
+		{"zero-width space", invisibleReject, "bad\u200bword", "", true},
+		{"RTL override", invisibleStrip, "abc\u202etxt.exe", "abctxt.exe", false},
+		{"RTL override", invisibleReject, "abc\u202etxt.exe", "", true},
+		{"Zalgo", invisibleStrip, zalgo, "h" + strings.Repeat("\u0301", 4) + "i", false},
+		{"Zalgo", invisibleReject, zalgo, "", true},
+		{"only invisible", invisibleStrip, "\u200b\u2060", "", true},
+		// ZWNJ in Persian, an emoji ZWJ sequence and a few accents survive
+		{"Persian ZWNJ", invisibleReject, "می\u200cخواهم", "می\u200cخواهم", false},
+		{"emoji sequence", invisibleReject, "👨\u200d👩\u200d👧 ❤️", "👨\u200d👩\u200d👧 ❤️", false},
+		{"accents", invisibleReject, "cafe\u0301 naïve", "cafe\u0301 naïve", false},
+	} {
+		setVar(t, &invisiblePolicy, tc.policy)
+		got, reason := sanitizeMessage(tc.in)
+		if (reason != "") != tc.rejected || got != tc.want {
+			t.Errorf("%s (%s): %q, %q; want %q rejected=%v", tc.name, tc.policy, got, reason, tc.want, tc.rejected)
+		}
+	}
+}
+
+func TestInvisibleCharactersRejectedWithReason(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &invisiblePolicy, invisibleReject)
+	w := post(t, 1, "v1", "alice", "hidden\u202etext")
+	expectStatus(t, w, 400)
+	if resp := decode(t, w); resp["reason"] != "invalid_characters" || !strings.Contains(resp["error"].(string), "U+202E") {
+		t.Fatalf("rejection = %v, want invalid_characters naming U+202E", resp)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Streams can restrict chat to the scripts their moderators can read.
+// ALLOWED_SCRIPTS and BLOCKED_SCRIPTS set the defaults and streams override
+// them with allowed_scripts and blocked_scripts in their modes. Both take a
+// comma-separated list of Unicode script names (latin, arabic, cyrillic, han,
+// ...) or code point ranges (U+0600-U+06FF). When an allow list is set, only
+// those scripts may appear; blocked scripts are refused either way. Digits,
+// punctuation, emoji and combining marks (the Common and Inherited scripts)
+// are always accepted.
+var defaultScriptPolicy scriptPolicy
+
+// maxReportedCharacters caps how many offending characters a rejection lists
+const maxReportedCharacters = 10
+
+// scriptNames indexes unicode.Scripts by lowercase name
+var scriptNames = func() map[string]*unicode.RangeTable {
+	names := make(map[string]*unicode.RangeTable, len(unicode.Scripts))
+	for name, table := range unicode.Scripts {
+		names[strings.ToLower(name)] = table
+	}
+	return names
+}()
+
+func loadScriptConfig() {
+	defaultScriptPolicy = scriptPolicy{
+		Allowed: parseScriptSet(envString("ALLOWED_SCRIPTS", "")),
+		Blocked: parseScriptSet(envString("BLOCKED_SCRIPTS", "")),
+	}
+}
+
+// scriptSet is a set of scripts and code point ranges
+type scriptSet struct {
+	tables []*unicode.RangeTable
+	ranges [][2]rune
+}
+
+func (s scriptSet) empty() bool {
+	return len(s.tables) == 0 && len(s.ranges) == 0
+}
+
+func (s scriptSet) contains(r rune) bool {
+	for _, rng := range s.ranges {
+		if r >= rng[0] && r <= rng[1] {
+			return true
+		}
+	}
+	return unicode.In(r, s.tables...)
+}
+
+// parseScriptSet parses a comma-separated list of script names and ranges,
+// skipping entries it doesn't recognise
+func parseScriptSet(value string) scriptSet {
+	var set scriptSet
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if table, ok := scriptNames[strings.ToLower(entry)]; ok {
+			set.tables = append(set.tables, table)
+			continue
+		}
+		if rng, ok := parseRuneRange(entry); ok {
+			set.ranges = append(set.ranges, rng)
+			continue
+		}
+		log.Printf("[GO] Warning: ignoring unknown script %q", entry)
+	}
+	return set
+}
+
+// parseRuneRange parses U+XXXX or U+XXXX-U+YYYY
+func parseRuneRange(entry string) ([2]rune, bool) {
+	lo, hi, found := strings.Cut(strings.ToUpper(entry), "-")
+	if !found {
+		hi = lo
+	}
+	start, ok := parseCodePoint(lo)
+	if !ok {
+		return [2]rune{}, false
+	}
+	end, ok := parseCodePoint(hi)
+	if !ok || end < start {
+		return [2]rune{}, false
+	}
+	return [2]rune{start, end}, true
+}
+
+func parseCodePoint(s string) (rune, bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "U+")
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil || v > unicode.MaxRune {
+		return 0, false
+	}
+	return rune(v), true
+}
+
+// scriptPolicy is a stream's allowed and blocked scripts
+type scriptPolicy struct {
+	Allowed scriptSet
+	Blocked scriptSet
+}
+
+// parseScriptPolicy reads a stream's script policy from its modes fields. A
+// field that is present, even empty, replaces the default.
+func parseScriptPolicy(fields map[string]string) scriptPolicy {
+	policy := defaultScriptPolicy
+	if v, ok := fields["allowed_scripts"]; ok {
+		policy.Allowed = parseScriptSet(v)
+	}
+	if v, ok := fields["blocked_scripts"]; ok {
+		policy.Blocked = parseScriptSet(v)
+	}
+	return policy
+}
+
+// permits reports whether r may appear in a message under the policy
+func (p scriptPolicy) permits(r rune) bool {
+	if p.Blocked.contains(r) {
+		return false
+	}
+	if unicode.IsSpace(r) || unicode.In(r, unicode.Common, unicode.Inherited) {
+		return true
+	}
+	return p.Allowed.empty() || p.Allowed.contains(r)
+}
+
+// disallowedCharacters returns the distinct characters of message the policy
+// refuses, in order of appearance. Custom emote shortcodes are skipped since
+// they're replaced by images on the client.
+func (p scriptPolicy) disallowedCharacters(message string, custom map[string]string) []string {
+	if p.Allowed.empty() && p.Blocked.empty() {
+		return nil
+	}
+	message = shortcodePattern.ReplaceAllStringFunc(message, func(match string) string {
+		if _, ok := custom[match[1:len(match)-1]]; ok {
+			return " "
+		}
+		return match
+	})
+	var found []string
+	seen := map[rune]bool{}
+	for _, r := range message {
+		if seen[r] || p.permits(r) {
+			continue
+		}
+		seen[r] = true
+		found = append(found, string(r))
+		if len(found) == maxReportedCharacters {
+			break
+		}
+	}
+	return found
+}
+
+// scriptRejectionMessage describes a script rejection for the poster
+func scriptRejectionMessage(characters []string) string {
+	codes := make([]string, len(characters))
+	for i, ch := range characters {
+		codes[i] = fmt.Sprintf("U+%04X", []rune(ch)[0])
+	}
+	return "message contains characters not allowed in this chat (" + strings.Join(codes, ", ") + ")"
+}
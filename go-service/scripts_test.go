@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestScriptPolicyMixedScripts(t *testing.T) {
+	latinArabic := parseScriptPolicy(map[string]string{"allowed_scripts": "latin, arabic"})
+	noCyrillic := parseScriptPolicy(map[string]string{"blocked_scripts": "cyrillic,U+4E00-U+9FFF"})
+	arabicOnly := parseScriptPolicy(map[string]string{"allowed_scripts": "arabic"})
+	custom := map[string]string{"pog": "https://cdn.example.com/pog.png"}
+
+	for _, tc := range []struct {
+		policy scriptPolicy
+		msg    string
+		want   string
+	}{
+		{latinArabic, "hello سلام 123!", "[]"},
+		{latinArabic, "gg 🔥🔥 :)", "[]"},
+		{latinArabic, "hello привет", "[п р и в е т]"},
+		{latinArabic, "hi 你好 привет 你", "[你 好 п р и в е т]"},
+		// Custom emote shortcodes don't count as text
+		{arabicOnly, "سلام :pog:", "[]"},
+		{arabicOnly, "سلام :nope:", "[n o p e]"},
+		{noCyrillic, "hello سلام こんにちは", "[]"},
+		{noCyrillic, "hello мир 中文", "[м и р 中 文]"},
+	} {
+		if got := fmt.Sprint(tc.policy.disallowedCharacters(tc.msg, custom)); got != tc.want {
+			t.Errorf("%q: %s, want %s", tc.msg, got, tc.want)
+		}
+	}
+}
+
+func TestScriptRejectionNamesCharacters(t *testing.T) {
+	resetRedis(t)
+	rdb.HSet(ctx, modesKey(1), "allowed_scripts", "latin")
+
+	w := post(t, 1, "v1", "alice", "hi мир")
+	expectStatus(t, w, 403)
+	resp := decode(t, w)
+	if resp["reason"] != "script_not_allowed" || fmt.Sprint(resp["characters"]) != "[м и р]" {
+		t.Fatalf("rejection = %v, want script_not_allowed naming м и р", resp)
+	}
+	expectStatus(t, post(t, 1, "v1", "alice", "hi there, 100% 👍"), 200)
+	// Moderators aren't held to the policy
+	expectStatus(t, post(t, 1, "v2", "bob", "привет всем", asRole(roleModerator)...), 200)
+}
@@ -0,0 +1,81 @@
+package main
+
+import "time"
+
+// check-update suggests whether clients should keep auto-scrolling the chat
+// so every client applies the same heuristic. It's advisory: clients may
+// ignore it. The hint is to pause when
+//
+//   - the viewer is reading (the client sent reading, e.g. because they
+//     scrolled up), so new comments don't yank the view away
+//   - a poll brings SCROLL_PAUSE_BATCH or more new comments, more than can be
+//     read while they scroll past
+//   - the stream's rate over SCROLL_HINT_WINDOW reaches SCROLL_PAUSE_RATE
+//     comments/s
+//
+// and to auto-scroll otherwise. The initial load always auto-scrolls, as the
+// client is showing the newest comments from scratch. SCROLL_HINTS=false
+// leaves the hint out.
+const (
+	scrollAuto  = "auto"
+	scrollPause = "pause"
+)
+
+// Why a hint was given
+const (
+	scrollCalm    = "calm"
+	scrollReading = "reading"
+	scrollBurst   = "burst"
+	scrollFast    = "fast"
+)
+
+var (
+	scrollHints      bool
+	scrollPauseRate  float64
+	scrollPauseBatch int
+	scrollHintWindow time.Duration
+)
+
+func loadScrollConfig() {
+	scrollHints = envBool("SCROLL_HINTS", true)
+	scrollPauseRate = envFloat("SCROLL_PAUSE_RATE", 3)
+	scrollPauseBatch = envInt("SCROLL_PAUSE_BATCH", 25)
+	scrollHintWindow = time.Duration(envInt("SCROLL_HINT_WINDOW", 10)) * time.Second
+}
+
+// ScrollHint is check-update's auto-scroll suggestion
+type ScrollHint struct {
+	Action string `json:"action"` // "auto" or "pause"
+	Reason string `json:"reason"`
+}
+
+// scrollHintFor maps the stream's rate (comments/s), the number of comments
+// the poll returned and whether the viewer is reading to a hint. A threshold
+// of 0 disables that check.
+func scrollHintFor(rate float64, newComments int, reading bool) ScrollHint {
+	switch {
+	case reading:
+		return ScrollHint{Action: scrollPause, Reason: scrollReading}
+	case scrollPauseBatch > 0 && newComments >= scrollPauseBatch:
+		return ScrollHint{Action: scrollPause, Reason: scrollBurst}
+	case scrollPauseRate > 0 && rate >= scrollPauseRate:
+		return ScrollHint{Action: scrollPause, Reason: scrollFast}
+	}
+	return ScrollHint{Action: scrollAuto, Reason: scrollCalm}
+}
+
+// scrollHint computes the hint for one poll, nil when hints are off. rate is
+// the stream's comment rate over scrollHintWindow (see loadPollState).
+func scrollHint(rate float64, initial bool, newComments int, reading bool) *ScrollHint {
+	if !scrollHints {
+		return nil
+	}
+	if initial {
+		return &ScrollHint{Action: scrollAuto, Reason: scrollCalm}
+	}
+	if scrollPauseRate <= 0 || reading {
+		rate = 0
+	}
+	hint := scrollHintFor(rate, newComments, reading)
+	return &hint
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestScrollHintFor(t *testing.T) {
+	setVar(t, &scrollPauseRate, 3)
+	setVar(t, &scrollPauseBatch, 25)
+	for _, tc := range []struct {
+		rate           float64
+		newComments    int
+		reading        bool
+		action, reason string
+	}{
+		{0, 0, false, scrollAuto, scrollCalm},
+		{2.9, 24, false, scrollAuto, scrollCalm},
+		{3, 0, false, scrollPause, scrollFast},
+		{0.5, 25, false, scrollPause, scrollBurst},
+		{10, 40, false, scrollPause, scrollBurst},
+		{0, 0, true, scrollPause, scrollReading},
+		{10, 40, true, scrollPause, scrollReading},
+	} {
+		if got := scrollHintFor(tc.rate, tc.newComments, tc.reading); got.Action != tc.action || got.Reason != tc.reason {
+			t.Errorf("rate %v, %d new, reading %v: %v, want %s %s", tc.rate, tc.newComments, tc.reading, got, tc.action, tc.reason)
+		}
+	}
+
+	// A threshold of 0 turns its check off
+	setVar(t, &scrollPauseRate, 0)
+	setVar(t, &scrollPauseBatch, 0)
+	if got := scrollHintFor(100, 1000, false); got.Action != scrollAuto {
+		t.Fatalf("with both thresholds off: %v, want auto", got)
+	}
+}
+
+func TestScrollHintInCheckUpdate(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &scrollHintWindow, 10*time.Second)
+	rdb.Set(ctx, velocityKey(1, velocityBucketAt(time.Now())), 50, 0)
+
+	hint := func(lastID int64, reading bool) map[string]interface{} {
+		t.Helper()
+		w := request(t, http.MethodPost, "/check-update", map[string]interface{}{
+			"stream_id": 1, "viewer_id": "v1", "last_id": lastID, "reading": reading,
+		})
+		expectStatus(t, w, 200)
+		return decode(t, w)["scroll_hint"].(map[string]interface{})
+	}
+	// The initial load always auto-scrolls
+	if got := hint(0, true); got["action"] != scrollAuto {
+		t.Fatalf("initial load = %v, want auto", got)
+	}
+	if got := hint(1, false); got["action"] != scrollPause || got["reason"] != scrollFast {
+		t.Fatalf("fast stream = %v, want pause fast", got)
+	}
+	if got := hint(1, true); got["reason"] != scrollReading {
+		t.Fatalf("reading = %v, want pause reading", got)
+	}
+
+	setVar(t, &scrollHints, false)
+	w := request(t, http.MethodPost, "/check-update", map[string]interface{}{"stream_id": 1, "viewer_id": "v1", "last_id": 1})
+	if _, ok := decode(t, w)["scroll_hint"]; ok {
+		t.Fatal("scroll_hint returned with hints off")
+	}
+}
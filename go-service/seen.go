@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Streams with the seen_counts mode on report how many online viewers have
+// likely seen each recent comment. Every check-update from a viewer records
+// the furthest last_id they polled past; a comment counts as seen by the
+// online viewers whose cursor reached its timestamp. It's opt-in because it
+// tells everyone how far others have read, and it only runs while at most
+// SEEN_MAX_VIEWERS are online, where a single count still means something
+// and tracking stays cheap. Responses carry seen_counts for the newest
+// SEEN_RECENT comments, by comment ID.
+var (
+	seenMaxViewers int64
+	seenRecent     int64
+)
+
+// readCursorsTTL drops a stream's cursors once nobody has polled for a while
+const readCursorsTTL = 10 * time.Minute
+
+func loadSeenConfig() {
+	seenMaxViewers = int64(envInt("SEEN_MAX_VIEWERS", 50))
+	seenRecent = int64(envInt("SEEN_RECENT", 20))
+	if seenRecent < 1 {
+		seenRecent = 20
+	}
+}
+
+// seenEnabled reports whether a stream with online viewers tracks seen counts
+func seenEnabled(modes StreamModes, online int64) bool {
+	return modes.SeenCounts && seenMaxViewers > 0 && online <= seenMaxViewers
+}
+
+// recordReadCursor raises a viewer's cursor to lastID. The set never holds
+// more than twice the audience cap; the furthest-behind cursors go first,
+// as they belong to viewers who most likely left.
+func recordReadCursor(ctx context.Context, streamID int64, viewerID string, lastID int64) {
+	if viewerID == "" || lastID <= 0 {
+		return
+	}
+	cursorsKey := readCursorsKey(streamID)
+	_, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAddArgs(ctx, cursorsKey, redis.ZAddArgs{GT: true, Members: []redis.Z{{Score: float64(lastID), Member: viewerID}}})
+		pipe.ZRemRangeByRank(ctx, cursorsKey, 0, -(2*seenMaxViewers + 1))
+		pipe.Expire(ctx, cursorsKey, readCursorsTTL)
+		return nil
+	})
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error recording read cursor: %v", streamID, err)
+	}
+}
+
+// loadSeenCounts counts, for the stream's newest comments up to readMax, the
+// online viewers whose cursor reached them
+func loadSeenCounts(ctx context.Context, streamID, readMax int64) (map[string]int, error) {
+	var cursorsCmd *redis.ZSliceCmd
+	var onlineCmd *redis.StringSliceCmd
+	var recentCmd *redis.ZSliceCmd
+	_, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		cursorsCmd = pipe.ZRangeWithScores(ctx, readCursorsKey(streamID), 0, -1)
+		onlineCmd = pipe.SMembers(ctx, onlineSetKey(streamID))
+		recentCmd = pipe.ZRevRangeByScoreWithScores(ctx, commentIndexKey(streamID), &redis.ZRangeBy{
+			Min:   "-inf",
+			Max:   strconv.FormatInt(readMax, 10),
+			Count: seenRecent,
+		})
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	online := make(map[string]bool, len(onlineCmd.Val()))
+	for _, viewerID := range onlineCmd.Val() {
+		online[viewerID] = true
+	}
+	var cursors []int64
+	for _, z := range cursorsCmd.Val() {
+		if viewerID, ok := z.Member.(string); ok && online[viewerID] {
+			cursors = append(cursors, int64(z.Score))
+		}
+	}
+	return seenCounts(cursors, recentCmd.Val()), nil
+}
+
+// seenCounts maps each comment ID to how many cursors are at or past its
+// timestamp (the score)
+func seenCounts(cursors []int64, comments []redis.Z) map[string]int {
+	if len(comments) == 0 {
+		return nil
+	}
+	sorted := append([]int64(nil), cursors...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	counts := make(map[string]int, len(comments))
+	for _, z := range comments {
+		id, ok := z.Member.(string)
+		if !ok {
+			continue
+		}
+		ts := int64(z.Score)
+		behind := sort.Search(len(sorted), func(i int) bool { return sorted[i] >= ts })
+		counts[id] = len(sorted) - behind
+	}
+	return counts
+}
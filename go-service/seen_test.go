@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func TestSeenCountsDerivation(t *testing.T) {
+	comments := []redis.Z{{Score: 100, Member: "a"}, {Score: 150, Member: "b"}, {Score: 300, Member: "c"}, {Score: 400, Member: "d"}}
+	got := seenCounts([]int64{300, 100, 200}, comments)
+	for id, want := range map[string]int{"a": 3, "b": 2, "c": 1, "d": 0} {
+		if got[id] != want {
+			t.Errorf("comment %s seen by %d, want %d", id, got[id], want)
+		}
+	}
+	if got := seenCounts([]int64{100}, nil); got != nil {
+		t.Fatalf("seen counts without comments = %v, want nil", got)
+	}
+}
+
+// seenBy reads a poll response's seen counts
+func seenBy(resp map[string]interface{}) map[string]interface{} {
+	counts, _ := resp["seen_counts"].(map[string]interface{})
+	return counts
+}
+
+func TestSeenCountsFromPolls(t *testing.T) {
+	resetRedis(t)
+	ts := time.Now().Add(-time.Minute).UnixMilli()
+	saveAt(t, ts, 1)
+	saveAt(t, ts+10, 2)
+	saveAt(t, ts+20, 3)
+	for _, viewer := range []string{"v1", "v2", "v3"} {
+		heartbeatSession(t, viewer, "")
+	}
+	if counts := seenBy(poll(t, 1, "v1", ts+20)); counts != nil {
+		t.Fatalf("seen counts = %v with the mode off, want none", counts)
+	}
+
+	rdb.HSet(ctx, modesKey(1), "seen_counts", "1")
+	poll(t, 1, "v1", ts+20)
+	poll(t, 1, "v2", ts+10)
+	// Cursors of viewers no longer online don't count
+	recordReadCursor(ctx, 1, "gone", ts+20)
+	counts := seenBy(poll(t, 1, "v3", ts))
+	for id, want := range map[string]float64{"1": 3, "2": 2, "3": 1} {
+		if counts[id] != want {
+			t.Fatalf("seen counts = %v, want comment %s seen by %v", counts, id, want)
+		}
+	}
+
+	// A cursor never moves back
+	poll(t, 1, "v1", ts)
+	if counts := seenBy(poll(t, 1, "v3", ts)); counts["3"] != float64(1) {
+		t.Fatalf("seen counts = %v after v1 polled an older cursor, want comment 3 still seen by 1", counts)
+	}
+}
+
+func TestSeenCountsOnlyForSmallAudiences(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &seenMaxViewers, 2)
+	saveAt(t, time.Now().Add(-time.Minute).UnixMilli(), 1)
+	rdb.HSet(ctx, modesKey(1), "seen_counts", "1")
+	for _, viewer := range []string{"v1", "v2", "v3"} {
+		heartbeatSession(t, viewer, "")
+	}
+	if counts := seenBy(poll(t, 1, "v1", 1)); counts != nil {
+		t.Fatalf("seen counts = %v with 3 online over a cap of 2, want none", counts)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// Viewer sessions give the server its own handle on a viewer. The first
+// heartbeat is issued a session token which the client sends back on every
+// later heartbeat, including after reconnecting, so the viewer keeps the same
+// identity even if the client loses or changes its viewer_id. Anyone can
+// claim a viewer_id, so a session is only bound to the one in the heartbeat
+// when a trusted caller sent it; everyone else gets a generated ID, which
+// only takes effect once the client sends the token back so clients unaware
+// of sessions keep counting as before. Sessions expire
+// SESSION_TTL (seconds) after their last heartbeat once the client has sent
+// the token back; until then they only live as long as presence, so clients
+// unaware of sessions, which get a new one on every heartbeat, don't pile
+// them up. Requests about the viewer themselves (their history and settings)
+// carry the token in X-Session-Token.
+var sessionTTL time.Duration
+
+const (
+	sessionTokenBytes = 16
+	anonViewerPrefix  = "anon:"
+)
+
+func loadSessionConfig() {
+	sessionTTL = time.Duration(envInt("SESSION_TTL", 24*60*60)) * time.Second
+}
+
+// ViewerSession is a session's stored metadata
+type ViewerSession struct {
+	Token     string
+	ViewerID  string
+	StreamID  int64
+	CreatedAt int64 // ms
+	LastSeen  int64 // ms
+	WatchTime int64 // ms of continuous watching across heartbeats
+
+	resumed bool // the client sent this session's token
+}
+
+func newSessionToken() (string, error) {
+	b := make([]byte, sessionTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// validSessionToken reports whether token has the shape newSessionToken makes
+func validSessionToken(token string) bool {
+	if len(token) != sessionTokenBytes*2 {
+		return false
+	}
+	_, err := hex.DecodeString(token)
+	return err == nil
+}
+
+// loadSession returns the session for token, or nil if it is unknown or expired
+func loadSession(ctx context.Context, token string) (*ViewerSession, error) {
+	if !validSessionToken(token) {
+		return nil, nil
+	}
+	fields, err := rdb.HGetAll(ctx, sessionKey(token)).Result()
+	if err != nil || len(fields) == 0 {
+		return nil, err
+	}
+	s := &ViewerSession{Token: token, ViewerID: fields["viewer_id"]}
+	s.StreamID, _ = strconv.ParseInt(fields["stream_id"], 10, 64)
+	s.CreatedAt, _ = strconv.ParseInt(fields["created_at"], 10, 64)
+	s.LastSeen, _ = strconv.ParseInt(fields["last_seen"], 10, 64)
+	s.WatchTime, _ = strconv.ParseInt(fields["watch_time"], 10, 64)
+	return s, nil
+}
+
+// resumeSession continues the session for token, or starts one when there is
+// none, for viewerID if a trusted caller vouched for it (see heartbeat) or a
+// generated ID if it is "". The session's viewer ID wins over the one the
+// client sent. On storage errors the viewer carries on without a session.
+func resumeSession(ctx context.Context, streamID int64, token, viewerID string) *ViewerSession {
+	now := time.Now().UnixMilli()
+	s, err := loadSession(ctx, token)
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error loading viewer session: %v", streamID, err)
+		return nil
+	}
+	if s == nil {
+		if token, err = newSessionToken(); err != nil {
+			log.Printf("[GO] Stream %d: Error issuing viewer session: %v", streamID, err)
+			return nil
+		}
+		if viewerID == "" {
+			viewerID = anonViewerPrefix + token[:12]
+		}
+		s = &ViewerSession{Token: token, ViewerID: viewerID, CreatedAt: now, LastSeen: now}
+	} else {
+		s.resumed = true
+		// Gaps longer than presence count as having stopped watching
+		if since := now - s.LastSeen; since > 0 && since <= presenceTTL.Milliseconds() {
+			s.WatchTime += since
+		}
+	}
+	s.StreamID, s.LastSeen = streamID, now
+	ttl := sessionTTL
+	if !s.resumed {
+		ttl = presenceTTL
+	}
+
+	_, err = rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		k := sessionKey(s.Token)
+		pipe.HSet(ctx, k, "viewer_id", s.ViewerID, "stream_id", s.StreamID, "created_at", s.CreatedAt, "last_seen", s.LastSeen, "watch_time", s.WatchTime)
+		pipe.Expire(ctx, k, ttl)
+		return nil
+	})
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error storing viewer session: %v", streamID, err)
+		return nil
+	}
+	return s
+}
+
+// authenticateViewer checks that the request may act as viewerID: trusted
+// callers act for whoever they name, viewers need a session token
+// (X-Session-Token) issued for that viewer ID. It responds 401 and returns
+// false otherwise.
+func authenticateViewer(c *gin.Context, viewerID string) bool {
+	if isTrustedRequest(c) {
+		return true
+	}
+	s, err := loadSession(c.Request.Context(), c.GetHeader("X-Session-Token"))
+	if err != nil {
+		log.Printf("[GO] Error loading viewer session: %v", err)
+		c.JSON(500, gin.H{"error": "failed to check session"})
+		return false
+	}
+	if s == nil || s.ViewerID != viewerID {
+		c.JSON(401, gin.H{"error": "a session for this viewer is required", "reason": "session_required"})
+		return false
+	}
+	return true
+}
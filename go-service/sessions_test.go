@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func heartbeatSession(t *testing.T, viewerID, token string, headers ...string) map[string]interface{} {
+	t.Helper()
+	w := request(t, http.MethodPost, "/heartbeat", map[string]interface{}{"stream_id": 1, "viewer_id": viewerID, "session_token": token}, headers...)
+	expectStatus(t, w, 200)
+	return decode(t, w)
+}
+
+// viewerSession returns a session token bound to viewerID, issued through a
+// trusted caller
+func viewerSession(t *testing.T, viewerID string) string {
+	t.Helper()
+	return heartbeatSession(t, viewerID, "", trusted...)["session_token"].(string)
+}
+
+func TestSessionIssuedAndResumed(t *testing.T) {
+	resetRedis(t)
+
+	first := heartbeatSession(t, "v1", "", trusted...)
+	token, _ := first["session_token"].(string)
+	if !validSessionToken(token) || first["viewer_id"] != "v1" {
+		t.Fatalf("session = %v, want a token for v1", first)
+	}
+
+	// The session's viewer ID wins over a changed one
+	again := heartbeatSession(t, "changed", token)
+	if again["session_token"] != token || again["viewer_id"] != "v1" {
+		t.Fatalf("resumed session = %v/%v, want %s/v1", again["session_token"], again["viewer_id"], token)
+	}
+}
+
+func TestSessionTTL(t *testing.T) {
+	resetRedis(t)
+
+	for _, viewerID := range []string{"v1", ""} {
+		token := heartbeatSession(t, viewerID, "")["session_token"].(string)
+		if ttl := testRedis.TTL(sessionKey(token)); ttl != presenceTTL {
+			t.Fatalf("viewer %q: new session TTL = %s, want %s", viewerID, ttl, presenceTTL)
+		}
+		heartbeatSession(t, viewerID, token)
+		if ttl := testRedis.TTL(sessionKey(token)); ttl != sessionTTL {
+			t.Fatalf("viewer %q: resumed session TTL = %s, want %s", viewerID, ttl, sessionTTL)
+		}
+	}
+}
+
+func TestSessionsOfUnawareClientsExpireWithPresence(t *testing.T) {
+	resetRedis(t)
+
+	for i := 0; i < 5; i++ {
+		heartbeatSession(t, "v1", "")
+	}
+	testRedis.FastForward(presenceTTL)
+	for _, k := range testRedis.Keys() {
+		if strings.HasPrefix(k, sessionKey("")) {
+			t.Fatalf("session %s outlived presence", k)
+		}
+	}
+}
+
+func TestAnonymousSessionIDOnlyAppliesOnceResumed(t *testing.T) {
+	resetRedis(t)
+
+	first := heartbeatSession(t, "", "")
+	anon, _ := first["viewer_id"].(string)
+	if !strings.HasPrefix(anon, anonViewerPrefix) {
+		t.Fatalf("viewer_id = %q, want an anonymous ID", anon)
+	}
+	if online, _ := store.OnlineCount(ctx, 1); online != 1 {
+		t.Fatalf("online = %d, want 1", online)
+	}
+	heartbeatSession(t, "", first["session_token"].(string))
+	if ok, _ := testRedis.SIsMember(onlineSetKey(1), anon); !ok {
+		t.Fatalf("resumed anonymous viewer %s isn't online", anon)
+	}
+}
+
+func TestSessionNotBoundToAClaimedViewerID(t *testing.T) {
+	resetRedis(t)
+
+	// A viewer can't get a session for someone else's viewer_id
+	spoofed := heartbeatSession(t, "victim", "")
+	if bound, _ := spoofed["viewer_id"].(string); !strings.HasPrefix(bound, anonViewerPrefix) {
+		t.Fatalf("untrusted heartbeat bound its session to %q, want a generated ID", bound)
+	}
+	token := spoofed["session_token"].(string)
+	if status, _ := myComments(t, "victim", "", "X-Session-Token", token); status != 401 {
+		t.Fatalf("reading the victim's history with the spoofed session: %d, want 401", status)
+	}
+	// Presence still counts the viewer_id the client sent until it resumes
+	if ok, _ := testRedis.SIsMember(onlineSetKey(1), "victim"); !ok {
+		t.Fatal("unaware client isn't online under its viewer_id")
+	}
+}
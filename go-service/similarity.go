@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Spammers dodge exact-duplicate checks by varying a message a little
+// ("buy now!!", "buy now!!!", "BUY now"). With SIMILARITY_THRESHOLD set
+// (similarity_threshold in a stream's modes, between 0 and 1, 0 = off),
+// a viewer's comment is compared with the last SIMILARITY_HISTORY comments
+// they posted within SIMILARITY_WINDOW seconds and refused while it is at
+// least that similar to one of them. Similarity is the better of the
+// normalized edit distance and the word overlap of the two messages, after
+// lowercasing and dropping punctuation, so both small edits and reordered
+// words count. Messages shorter than SIMILARITY_MIN_LENGTH characters
+// ("gg", "lol") are never compared, and only their first
+// similarityMaxRunes characters are, to keep it cheap.
+var (
+	similarityThreshold float64
+	similarityHistory   int64
+	similarityWindow    time.Duration
+	similarityMinLength int
+)
+
+const similarityMaxRunes = 200
+
+func loadSimilarityConfig() {
+	similarityThreshold = envFloat("SIMILARITY_THRESHOLD", 0)
+	if similarityThreshold < 0 || similarityThreshold > 1 {
+		log.Printf("[GO] SIMILARITY_THRESHOLD must be in [0, 1], disabling similarity throttling")
+		similarityThreshold = 0
+	}
+	similarityHistory = int64(envInt("SIMILARITY_HISTORY", 5))
+	if similarityHistory < 1 {
+		similarityHistory = 1
+	}
+	similarityWindow = time.Duration(envInt("SIMILARITY_WINDOW", 60)) * time.Second
+	similarityMinLength = envInt("SIMILARITY_MIN_LENGTH", 8)
+}
+
+// similarityText reduces a message to what similarity compares
+func similarityText(message string) []rune {
+	var b strings.Builder
+	for _, r := range strings.ToLower(message) {
+		switch {
+		case unicode.IsLetter(r), unicode.IsNumber(r), unicode.IsMark(r):
+			b.WriteRune(r)
+		case unicode.IsSpace(r):
+			b.WriteRune(' ')
+		}
+	}
+	text := []rune(strings.Join(strings.Fields(b.String()), " "))
+	if len(text) > similarityMaxRunes {
+		text = text[:similarityMaxRunes]
+	}
+	return text
+}
+
+// editSimilarity is 1 minus the Levenshtein distance over the longer length
+func editSimilarity(a, b []rune) float64 {
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+	if len(a) == 0 {
+		return 1
+	}
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return 1 - float64(prev[len(b)])/float64(len(a))
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// wordSimilarity is the share of distinct words the messages have in common
+func wordSimilarity(a, b []rune) float64 {
+	wordsA, wordsB := map[string]bool{}, map[string]bool{}
+	for _, w := range strings.Fields(string(a)) {
+		wordsA[w] = true
+	}
+	for _, w := range strings.Fields(string(b)) {
+		wordsB[w] = true
+	}
+	if len(wordsA) == 0 || len(wordsB) == 0 {
+		return 0
+	}
+	common := 0
+	for w := range wordsA {
+		if wordsB[w] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(wordsA)+len(wordsB)-common)
+}
+
+func messageSimilarity(a, b []rune) float64 {
+	edit := editSimilarity(a, b)
+	if words := wordSimilarity(a, b); words > edit {
+		return words
+	}
+	return edit
+}
+
+// similarMessage checks a message against the viewer's recent ones. When it
+// is too similar to one it returns false and the seconds until that one no
+// longer counts. Errors fail open, like the rate limits.
+func similarMessage(ctx context.Context, streamID int64, viewer, message string, threshold float64) (bool, int) {
+	text := similarityText(message)
+	if threshold <= 0 || len(text) < similarityMinLength {
+		return true, 0
+	}
+	recent, err := rdb.LRange(ctx, recentMessagesKey(streamID, viewer), 0, similarityHistory-1).Result()
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error loading recent messages: %v", streamID, err)
+		return true, 0
+	}
+	now := time.Now().UnixMilli()
+	for _, entry := range recent {
+		at, previous, ok := strings.Cut(entry, "|")
+		if !ok {
+			continue
+		}
+		postedAt, _ := strconv.ParseInt(at, 10, 64)
+		age := time.Duration(now-postedAt) * time.Millisecond
+		if age >= similarityWindow {
+			continue
+		}
+		if messageSimilarity(text, similarityText(previous)) >= threshold {
+			return false, int((similarityWindow - age + time.Second - 1) / time.Second)
+		}
+	}
+	return true, 0
+}
+
+// rememberMessage adds a published message to the viewer's recent ones
+func rememberMessage(ctx context.Context, streamID int64, viewer, message string, postedAt int64) {
+	key := recentMessagesKey(streamID, viewer)
+	_, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, key, strconv.FormatInt(postedAt, 10)+"|"+message)
+		pipe.LTrim(ctx, key, 0, similarityHistory-1)
+		pipe.PExpire(ctx, key, similarityWindow)
+		return nil
+	})
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error recording recent message: %v", streamID, err)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestMessageSimilarity(t *testing.T) {
+	for _, tc := range []struct {
+		a, b     string
+		min, max float64
+	}{
+		{"buy cheap coins now", "buy cheap coins now", 1, 1},
+		{"buy cheap coins now!!", "BUY cheap coins now!!!", 1, 1},
+		{"buy cheap coins now", "buy cheap coinz now", 0.9, 0.99},
+		{"buy cheap coins now", "now buy coins cheap", 1, 1},
+		{"buy cheap coins now", "what a great play that was", 0, 0.4},
+		{"ありがとうございます", "ありがとうございました", 0.8, 0.95},
+	} {
+		got := messageSimilarity(similarityText(tc.a), similarityText(tc.b))
+		if got < tc.min || got > tc.max {
+			t.Errorf("similarity(%q, %q) = %.2f, want between %.2f and %.2f", tc.a, tc.b, got, tc.min, tc.max)
+		}
+	}
+}
+
+func TestSimilarMessagesThrottled(t *testing.T) {
+	resetRedis(t)
+	rdb.HSet(ctx, modesKey(1), "similarity_threshold", "0.8")
+
+	expectStatus(t, post(t, 1, "v1", "alice", "check out my channel"), 200)
+	for _, msg := range []string{"check out my channel", "CHECK out my channel!!!", "check out my channels", "my channel check out"} {
+		w := post(t, 1, "v1", "alice", msg)
+		if resp := decode(t, w); w.Code != 429 || resp["reason"] != "similar_message" || resp["retry_after"] == nil {
+			t.Fatalf("%q: %d %v, want 429 similar_message with retry_after", msg, w.Code, resp)
+		}
+	}
+	expectStatus(t, post(t, 1, "v1", "alice", "what a great play that was"), 200)
+
+	// Short messages and other viewers are never compared
+	expectStatus(t, post(t, 1, "v1", "alice", "gg"), 200)
+	expectStatus(t, post(t, 1, "v1", "alice", "gg"), 200)
+	expectStatus(t, post(t, 1, "v2", "bob", "check out my channel"), 200)
+}
+
+func TestSimilarityOff(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &similarityThreshold, 0)
+	for i := 0; i < 2; i++ {
+		expectStatus(t, post(t, 1, "v1", "alice", "check out my channel"), 200)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// smoothedStateTTL lets the average of an abandoned stream expire
+const smoothedStateTTL = 10 * time.Minute
+
+var (
+	// onlineSmoothing is the EMA weight of each new sample (ONLINE_SMOOTHING,
+	// 0 = off). Lower values give a steadier but slower-moving number.
+	onlineSmoothing float64
+	// onlineSampleInterval is how often a new sample enters the average, so
+	// the result doesn't depend on how many clients are polling
+	onlineSampleInterval time.Duration
+)
+
+func loadSmoothingConfig() {
+	onlineSmoothing = envFloat("ONLINE_SMOOTHING", 0)
+	if onlineSmoothing < 0 || onlineSmoothing > 1 {
+		log.Printf("[GO] ONLINE_SMOOTHING must be in [0, 1], disabling smoothing")
+		onlineSmoothing = 0
+	}
+	onlineSampleInterval = time.Duration(envInt("ONLINE_SAMPLE_INTERVAL", 5)) * time.Second
+}
+
+// smoothingScript folds a raw count into the stream's moving average at most
+// once per sample interval. KEYS: state hash. ARGV: raw count, alpha, now
+// (ms), interval (ms), ttl (s). Returns the average as a string.
+var smoothingScript = redis.NewScript(`
+local state = redis.call('HMGET', KEYS[1], 'value', 'at')
+local raw, now = tonumber(ARGV[1]), tonumber(ARGV[3])
+local value, at = tonumber(state[1]), tonumber(state[2])
+if not value then
+	value = raw
+elseif now - at >= tonumber(ARGV[4]) then
+	local alpha = tonumber(ARGV[2])
+	value = alpha * raw + (1 - alpha) * value
+else
+	return tostring(value)
+end
+redis.call('HSET', KEYS[1], 'value', tostring(value), 'at', now)
+redis.call('EXPIRE', KEYS[1], ARGV[5])
+return tostring(value)
+`)
+
+// smoothedOnline returns the stream's smoothed viewer count, or 0 when
+// smoothing is off or fails
+func smoothedOnline(ctx context.Context, streamID int64, raw int64) int {
+	if onlineSmoothing == 0 {
+		return 0
+	}
+	res, err := smoothingScript.Run(ctx, rdb, []string{onlineSmoothedKey(streamID)},
+		raw, onlineSmoothing, time.Now().UnixMilli(), onlineSampleInterval.Milliseconds(),
+		int(smoothedStateTTL.Seconds())).Text()
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error smoothing viewer count: %v", streamID, err)
+		return 0
+	}
+	value, err := strconv.ParseFloat(res, 64)
+	if err != nil {
+		return 0
+	}
+	return int(math.Round(value))
+}
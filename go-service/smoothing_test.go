@@ -0,0 +1,70 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// spread is the difference between the largest and smallest of values
+func spread(values []int) int {
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo, hi = min(lo, v), max(hi, v)
+	}
+	return hi - lo
+}
+
+func TestSmoothedOnlineDampensNoise(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &onlineSmoothing, 0.3)
+	setVar(t, &onlineSampleInterval, 0)
+
+	raw := []int{100, 60, 140, 70, 130, 90, 120, 80}
+	var smoothed []int
+	ema := float64(raw[0])
+	for i, r := range raw {
+		if i > 0 {
+			ema = 0.3*float64(r) + 0.7*ema
+		}
+		got := smoothedOnline(ctx, 1, int64(r))
+		if got != int(math.Round(ema)) {
+			t.Fatalf("sample %d: smoothed %d, want %d", i, got, int(math.Round(ema)))
+		}
+		smoothed = append(smoothed, got)
+	}
+	if spread(smoothed)*3 > spread(raw) {
+		t.Fatalf("smoothed %v isn't much steadier than raw %v", smoothed, raw)
+	}
+}
+
+func TestSmoothedOnlineSamplesPerInterval(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &onlineSmoothing, 0.5)
+	setVar(t, &onlineSampleInterval, time.Hour)
+
+	if got := smoothedOnline(ctx, 1, 100); got != 100 {
+		t.Fatalf("first sample = %d, want 100", got)
+	}
+	// Polls within the interval don't move the average
+	for _, r := range []int64{0, 0, 0} {
+		if got := smoothedOnline(ctx, 1, r); got != 100 {
+			t.Fatalf("smoothed = %d within the interval, want 100", got)
+		}
+	}
+
+	setVar(t, &onlineSmoothing, 0)
+	if got := smoothedOnline(ctx, 1, 40); got != 0 {
+		t.Fatalf("smoothed = %d with smoothing off, want 0", got)
+	}
+}
+
+func TestCheckUpdateReportsSmoothedOnline(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &onlineSmoothing, 0.5)
+	heartbeatSession(t, "v1", "")
+	heartbeatSession(t, "v2", "")
+	if resp := poll(t, 1, "v1", 0); resp["online_smoothed"] != float64(2) || resp["online"] != float64(2) {
+		t.Fatalf("online/smoothed = %v/%v, want 2/2", resp["online"], resp["online_smoothed"])
+	}
+}
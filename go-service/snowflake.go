@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Comment ID strategies (COMMENT_ID_STRATEGY)
+const (
+	idStrategySequence  = "sequence"  // per-stream INCR counter in Redis, above goCommentIDBase
+	idStrategySnowflake = "snowflake" // time-ordered, allocated locally
+)
+
+// Snowflake IDs pack milliseconds since snowflakeEpoch, the node ID and a
+// per-millisecond sequence into 63 bits. They are unique across nodes without
+// a shared counter and sort by allocation time, but don't reveal how many
+// comments a stream has. Every replica must have its own SNOWFLAKE_NODE_ID,
+// and responses carry IDs as strings (see ids.go).
+const (
+	snowflakeNodeBits = 10
+	snowflakeSeqBits  = 12
+	snowflakeMaxNode  = 1<<snowflakeNodeBits - 1
+	snowflakeMaxSeq   = 1<<snowflakeSeqBits - 1
+)
+
+// snowflakeEpoch is 2024-01-01T00:00:00Z in ms, leaving ~69 years of IDs
+const snowflakeEpoch = 1704067200000
+
+// Comments published here live only in Redis, next to the ones the backend
+// approves into the same comments:data:<sid> hash under their Postgres
+// comment.id. So the two can't overwrite each other, sequential IDs start
+// above goCommentIDBase, far past any Postgres ID, and snowflake IDs are
+// larger still. The backend treats IDs from goCommentIDBase up as this
+// service's (GO_COMMENT_ID_BASE in app/core/config.py): they have no
+// Postgres row, and moderating them only removes them from Redis.
+const goCommentIDBase int64 = 1 << 40
+
+var commentIDStrategy string
+
+// snowflakeGenerator allocates IDs for one node
+type snowflakeGenerator struct {
+	mu   sync.Mutex
+	node int64
+	last int64 // ms since epoch of the last ID
+	seq  int64
+}
+
+var snowflake = &snowflakeGenerator{}
+
+func loadIDStrategyConfig() {
+	commentIDStrategy = envString("COMMENT_ID_STRATEGY", idStrategySequence)
+	switch commentIDStrategy {
+	case idStrategySequence:
+	case idStrategySnowflake:
+		// Replicas sharing a default node ID would allocate the same IDs
+		if strings.TrimSpace(os.Getenv("SNOWFLAKE_NODE_ID")) == "" {
+			log.Fatalf("[GO] COMMENT_ID_STRATEGY=snowflake requires SNOWFLAKE_NODE_ID")
+		}
+		node := envInt("SNOWFLAKE_NODE_ID", -1)
+		if node < 0 || node > snowflakeMaxNode {
+			log.Fatalf("[GO] SNOWFLAKE_NODE_ID must be between 0 and %d", snowflakeMaxNode)
+		}
+		snowflake.node = int64(node)
+		log.Printf("[GO] Allocating snowflake comment IDs as node %d", node)
+		// Snowflake IDs are past 2^53 from the start, so browsers can't read
+		// them as numbers
+		if !stringIDsDefault {
+			log.Printf("[GO] Warning: snowflake comment IDs need JSON_STRING_IDS, turning it on")
+			stringIDsDefault = true
+		}
+	default:
+		log.Fatalf("[GO] Unknown COMMENT_ID_STRATEGY %q", commentIDStrategy)
+	}
+}
+
+// next returns a new ID, strictly greater than every earlier one from this
+// generator. If the clock steps back, or a millisecond's sequence runs out,
+// it keeps counting on from the last timestamp instead of waiting.
+func (g *snowflakeGenerator) next() int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := time.Now().UnixMilli() - snowflakeEpoch
+	if ms <= g.last {
+		ms = g.last
+		g.seq++
+		if g.seq > snowflakeMaxSeq {
+			ms++
+			g.seq = 0
+		}
+	} else {
+		g.seq = 0
+	}
+	g.last = ms
+	return ms<<(snowflakeNodeBits+snowflakeSeqBits) | g.node<<snowflakeSeqBits | g.seq
+}
+
+// allocateCommentID returns the ID for a stream's next comment
+func allocateCommentID(ctx context.Context, streamID int64) (int64, error) {
+	if commentIDStrategy == idStrategySnowflake {
+		return snowflake.next(), nil
+	}
+	seq, err := store.NextCommentSeq(ctx, streamID)
+	if err != nil {
+		return 0, fmt.Errorf("allocate comment id: %w", err)
+	}
+	return goCommentIDBase + seq, nil
+}
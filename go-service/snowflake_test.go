@@ -0,0 +1,105 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSequenceIDsStayClearOfBackendIDs(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &commentIDStrategy, idStrategySequence)
+	// The backend writes Postgres IDs into the same hash
+	rdb.HSet(ctx, commentDataKey(1), "1", `{"id":1,"username":"backend","message":"approved","timestamp":1}`)
+
+	for want := goCommentIDBase + 1; want <= goCommentIDBase+3; want++ {
+		id, err := allocateCommentID(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != want {
+			t.Fatalf("id = %d, want %d", id, want)
+		}
+	}
+	expectStatus(t, post(t, 1, "v1", "alice", "hello"), 200)
+	if n := rdb.HLen(ctx, commentDataKey(1)).Val(); n != 2 {
+		t.Fatalf("comments stored = %d, want the backend's and ours", n)
+	}
+}
+
+func TestSnowflakeIDsIncrease(t *testing.T) {
+	g := &snowflakeGenerator{node: 5}
+	last := int64(0)
+	for i := 0; i < 3*(snowflakeMaxSeq+1); i++ {
+		id := g.next()
+		if id <= last {
+			t.Fatalf("id %d after %d", id, last)
+		}
+		if node := id >> snowflakeSeqBits & snowflakeMaxNode; node != 5 {
+			t.Fatalf("id %d carries node %d, want 5", id, node)
+		}
+		last = id
+	}
+}
+
+func TestSnowflakeIDsIncreaseWhenClockStepsBack(t *testing.T) {
+	g := &snowflakeGenerator{}
+	first := g.next()
+	// As if the clock had been a minute ahead at the last allocation
+	g.last += time.Minute.Milliseconds()
+	ahead := g.last<<(snowflakeNodeBits+snowflakeSeqBits) | g.seq
+	if id := g.next(); id <= ahead || id <= first {
+		t.Fatalf("id %d after the clock stepped back, want above %d", id, ahead)
+	}
+}
+
+func TestSnowflakeIDsUniqueAcrossGoroutines(t *testing.T) {
+	g := &snowflakeGenerator{node: 1}
+	const workers, each = 8, 2000
+	ids := make(chan int64, workers*each)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			last := int64(0)
+			for i := 0; i < each; i++ {
+				id := g.next()
+				if id <= last {
+					t.Errorf("id %d after %d in the same goroutine", id, last)
+					return
+				}
+				last = id
+				ids <- id
+			}
+		}()
+	}
+	wg.Wait()
+	close(ids)
+	seen := make(map[int64]bool, workers*each)
+	for id := range ids {
+		if seen[id] {
+			t.Fatalf("id %d allocated twice", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestSnowflakeStrategyTurnsOnStringIDs(t *testing.T) {
+	setVar(t, &commentIDStrategy, commentIDStrategy)
+	setVar(t, &stringIDsDefault, false)
+	setVar(t, &snowflake, &snowflakeGenerator{})
+	t.Setenv("COMMENT_ID_STRATEGY", idStrategySnowflake)
+	t.Setenv("SNOWFLAKE_NODE_ID", "7")
+	loadIDStrategyConfig()
+
+	if snowflake.node != 7 {
+		t.Fatalf("node = %d, want 7", snowflake.node)
+	}
+	if !stringIDsDefault {
+		t.Fatal("snowflake IDs left JSON_STRING_IDS off")
+	}
+	if id, _ := allocateCommentID(ctx, 1); id <= 1<<53 {
+		t.Fatalf("snowflake id %d, expected past 2^53", id)
+	}
+}
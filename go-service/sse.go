@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SSE delivery settings. Comments arriving within sseBatchWindow of each
+// other are sent as one frame; sseBatchMax wake-ups flush early, and frames
+// never carry more than sseBatchMax comments. ssePollInterval catches
+// comments that arrive without a live notification (scheduled, delayed or
+// written by the backend).
+var (
+	sseBatchWindow  time.Duration
+	sseBatchMax     int
+	ssePollInterval time.Duration
+)
+
+func loadSSEConfig() {
+	sseBatchWindow = time.Duration(envInt("SSE_BATCH_WINDOW_MS", 100)) * time.Millisecond
+	sseBatchMax = envInt("SSE_BATCH_MAX", 50)
+	if sseBatchMax < 1 {
+		sseBatchMax = 1
+	}
+	ssePollInterval = time.Duration(envInt("SSE_POLL_INTERVAL_MS", 2000)) * time.Millisecond
+	if ssePollInterval <= 0 {
+		ssePollInterval = 2 * time.Second
+	}
+}
+
+// streamEvents serves a stream's comments as Server-Sent Events. Each
+// "comments" event carries a JSON array and its id is the cursor (the last
+// comment's timestamp), so a reconnecting EventSource resumes via
+// Last-Event-ID exactly like last_id in check-update.
+func streamEvents(c *gin.Context) {
+	streamID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid stream id"})
+		return
+	}
+	cursorParam := c.GetHeader("Last-Event-ID")
+	if cursorParam == "" {
+		cursorParam = c.DefaultQuery("last_id", "0")
+	}
+	cursor, err := strconv.ParseInt(cursorParam, 10, 64)
+	if err != nil || cursor < 0 {
+		c.JSON(400, gin.H{"error": "invalid cursor"})
+		return
+	}
+	applyDelay := !isPrivileged(requestRole(c))
+	stringIDs := wantsStringIDs(c)
+
+	reqCtx := c.Request.Context()
+	viewerID := c.Query("viewer_id")
+	feed := &feedReader{
+		streamID:   streamID,
+		cursor:     cursor,
+		applyDelay: applyDelay,
+		filter:     newDeliveryFilter(reqCtx, streamID, viewerID, loadDeliveryPrefs(reqCtx, viewerID), viewerAccess(c)),
+		editsSince: time.Now().UnixMilli(),
+	}
+	if c.Query("display_time") == "true" {
+		feed.zone = streamZone(reqCtx, streamID)
+	}
+	wake, unsubscribe := hub.subscribe(streamID)
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(200)
+
+	// A client that stops reading fails the write once its buffers fill
+	rc := http.NewResponseController(c.Writer)
+	extendDeadline := func() {
+		rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	}
+
+	// send reads past the cursor and writes it in capped frames, after a
+	// "truncated" event when the read left older comments out
+	send := func() bool {
+		extendDeadline()
+		comments := feed.next(reqCtx)
+		if gap := feed.gap(); gap != nil {
+			payload, err := marshalResponse(gap, stringIDs)
+			if err != nil {
+				return false
+			}
+			if _, err := fmt.Fprintf(c.Writer, "event: truncated\ndata: %s\n\n", payload); err != nil {
+				return false
+			}
+		}
+		for start := 0; start < len(comments); start += sseBatchMax {
+			end := start + sseBatchMax
+			if end > len(comments) {
+				end = len(comments)
+			}
+			frame := comments[start:end]
+			payload, err := marshalResponse(frame, stringIDs)
+			if err != nil {
+				return false
+			}
+			if _, err := fmt.Fprintf(c.Writer, "id: %d\nevent: comments\ndata: %s\n\n", frame[len(frame)-1].Timestamp, payload); err != nil {
+				return false
+			}
+		}
+		if edits := feed.nextEdits(reqCtx); len(edits) > 0 {
+			payload, err := marshalResponse(edits, stringIDs)
+			if err != nil {
+				return false
+			}
+			if _, err := fmt.Fprintf(c.Writer, "event: edits\ndata: %s\n\n", payload); err != nil {
+				return false
+			}
+		}
+		c.Writer.Flush()
+		return true
+	}
+
+	if cursor == 0 {
+		extendDeadline()
+		if welcome := initialWelcome(reqCtx, streamID); welcome != nil {
+			payload, err := marshalResponse(welcome, stringIDs)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(c.Writer, "event: welcome\ndata: %s\n\n", payload); err != nil {
+				return
+			}
+		}
+	}
+	if !send() {
+		return
+	}
+
+	poll := time.NewTicker(ssePollInterval)
+	defer poll.Stop()
+	keepAlive := time.NewTicker(streamPingInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-reqCtx.Done():
+			return
+		case <-keepAlive.C:
+			extendDeadline()
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				if isTimeout(err) {
+					newCounter("stream_connections_reaped_total", connectionsReapedHelp).Inc()
+				}
+				return
+			}
+			c.Writer.Flush()
+		case <-poll.C:
+			if !send() {
+				return
+			}
+		case <-wake:
+			// Coalesce a burst: wait out the window unless it fills up first
+			timer := time.NewTimer(sseBatchWindow)
+			for pending := 1; pending < sseBatchMax; pending++ {
+				select {
+				case <-wake:
+					continue
+				case <-timer.C:
+				case <-reqCtx.Done():
+				}
+				break
+			}
+			timer.Stop()
+			if !send() {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sseFrame is one event read from an SSE response
+type sseFrame struct {
+	id, event, data string
+	messages        []string
+}
+
+// openEvents connects to stream 1's events and returns its frames as they
+// arrive; the connection closes with the test
+func openEvents(t *testing.T, lastEventID string) <-chan sseFrame {
+	t.Helper()
+	srv := httptest.NewServer(testRouter)
+	reqCtx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		srv.Close()
+	})
+	req, _ := http.NewRequestWithContext(reqCtx, http.MethodGet, srv.URL+"/stream/1/events", nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames := make(chan sseFrame, 100)
+	go func() {
+		defer resp.Body.Close()
+		defer close(frames)
+		scanner := bufio.NewScanner(resp.Body)
+		var f sseFrame
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				if f.event != "" {
+					frames <- f
+				}
+				f = sseFrame{}
+			case strings.HasPrefix(line, "id: "):
+				f.id = line[4:]
+			case strings.HasPrefix(line, "event: "):
+				f.event = line[7:]
+			case strings.HasPrefix(line, "data: ") && f.event != "comments":
+				f.data = line[6:]
+			case strings.HasPrefix(line, "data: ") && f.event == "comments":
+				var list []Comment
+				json.Unmarshal([]byte(line[6:]), &list)
+				for _, cmt := range list {
+					f.messages = append(f.messages, cmt.Message)
+				}
+			}
+		}
+	}()
+	return frames
+}
+
+// nextComments waits for the next comments frame
+func nextComments(t *testing.T, frames <-chan sseFrame) sseFrame {
+	t.Helper()
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case f, ok := <-frames:
+			if !ok {
+				t.Fatal("event stream closed")
+			}
+			if f.event == "comments" {
+				return f
+			}
+		case <-timeout:
+			t.Fatal("no comments frame within 3s")
+		}
+	}
+}
+
+func TestSSESingleDelivery(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &ssePollInterval, 50*time.Millisecond)
+	frames := openEvents(t, "")
+
+	w := post(t, 1, "v1", "alice", "just one")
+	expectStatus(t, w, 200)
+	ts := decode(t, w)["comment"].(map[string]interface{})["timestamp"].(float64)
+	f := nextComments(t, frames)
+	if len(f.messages) != 1 || f.messages[0] != "just one" {
+		t.Fatalf("frame = %v, want [just one]", f.messages)
+	}
+	if f.id != strconv.FormatInt(int64(ts), 10) {
+		t.Fatalf("event id = %s, want the comment's timestamp %v", f.id, ts)
+	}
+}
+
+func TestSSEBurstIsBatchedInOrder(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &ssePollInterval, 50*time.Millisecond)
+	setVar(t, &sseBatchMax, 2)
+	frames := openEvents(t, "")
+
+	var lastTS float64
+	for _, msg := range []string{"one", "two", "three", "four", "five"} {
+		w := post(t, 1, "v1", "alice", msg)
+		expectStatus(t, w, 200)
+		lastTS = decode(t, w)["comment"].(map[string]interface{})["timestamp"].(float64)
+	}
+	var got []string
+	var last sseFrame
+	for len(got) < 5 {
+		last = nextComments(t, frames)
+		if len(last.messages) > 2 {
+			t.Fatalf("frame of %d comments, want at most 2", len(last.messages))
+		}
+		got = append(got, last.messages...)
+	}
+	if strings.Join(got, " ") != "one two three four five" {
+		t.Fatalf("delivered %v, want every comment in order", got)
+	}
+	if last.id != strconv.FormatInt(int64(lastTS), 10) {
+		t.Fatalf("last event id = %s, want %v", last.id, int64(lastTS))
+	}
+
+	// Resuming from the last event ID delivers only what came after
+	resumed := openEvents(t, last.id)
+	expectStatus(t, post(t, 1, "v1", "alice", "six"), 200)
+	if f := nextComments(t, resumed); len(f.messages) != 1 || f.messages[0] != "six" {
+		t.Fatalf("after resuming = %v, want [six]", f.messages)
+	}
+}
+
+func TestSSEResumeIsCappedLikeAPoll(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &pollMaxComments, 3)
+	ts := time.Now().Add(-time.Minute).UnixMilli()
+	saveAt(t, ts, 1)
+	saveAt(t, ts+1, 2, 3)
+	saveAt(t, ts+2, 4, 5)
+
+	// The cap falls inside ts+1, which moves whole to the older page
+	frames := openEvents(t, strconv.FormatInt(ts-1, 10))
+	var gap FeedGap
+	select {
+	case f := <-frames:
+		if f.event != "truncated" {
+			t.Fatalf("first event = %q, want truncated", f.event)
+		}
+		json.Unmarshal([]byte(f.data), &gap)
+	case <-time.After(3 * time.Second):
+		t.Fatal("no truncated event within 3s")
+	}
+	if gap.Before != ts+2 || gap.BeforeID != 0 {
+		t.Fatalf("gap = %+v, want before %d", gap, ts+2)
+	}
+	if f := nextComments(t, frames); strings.Join(f.messages, " ") != "m4 m5" {
+		t.Fatalf("resumed with %v, want only the newest millisecond [m4 m5]", f.messages)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// Streaming clients can vanish without closing (a dropped network, a
+// suspended laptop), which would keep their connection open and them online
+// until presence expired. Both endpoints send a keepalive every
+// STREAM_PING_INTERVAL seconds (an SSE comment; a WebSocket ping control
+// frame next to the JSON ping message) and give every write
+// STREAM_WRITE_TIMEOUT seconds, so a client that stopped reading is dropped
+// once its buffers fill. WebSocket clients must also be heard from: the
+// connection is closed when nothing, pongs included, has been read from it
+// for STREAM_PING_TIMEOUT seconds (0 = never). Closing a socket takes its
+// viewer offline at once.
+var (
+	streamPingInterval time.Duration
+	streamPingTimeout  time.Duration
+	streamWriteTimeout time.Duration
+)
+
+const connectionsReapedHelp = "Streaming connections closed because the client stopped responding."
+
+func loadStaleConfig() {
+	streamPingInterval = time.Duration(envInt("STREAM_PING_INTERVAL", 15)) * time.Second
+	if streamPingInterval <= 0 {
+		streamPingInterval = 15 * time.Second
+	}
+	streamPingTimeout = time.Duration(envInt("STREAM_PING_TIMEOUT", 45)) * time.Second
+	if streamPingTimeout > 0 && streamPingTimeout <= streamPingInterval {
+		// A client needs at least one ping to answer
+		streamPingTimeout = 2 * streamPingInterval
+	}
+	streamWriteTimeout = time.Duration(envInt("STREAM_WRITE_TIMEOUT", 10)) * time.Second
+	if streamWriteTimeout <= 0 {
+		streamWriteTimeout = 10 * time.Second
+	}
+}
+
+// pingFrame sends an empty WebSocket ping control frame; browsers answer it
+// with a pong on their own
+var pingFrame = websocket.Codec{Marshal: func(interface{}) ([]byte, byte, error) {
+	return nil, websocket.PingFrame, nil
+}}
+
+// readDeadlineWriter hands the WebSocket server a connection whose reads
+// time out after streamPingTimeout of silence. Every read through it, frames
+// of any kind, pushes the deadline out again.
+type readDeadlineWriter struct {
+	http.ResponseWriter
+	timeout time.Duration
+}
+
+func (w readDeadlineWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, buf, err := w.ResponseWriter.(http.Hijacker).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	reader := bufio.NewReader(&deadlineReader{conn: conn, r: buf.Reader, timeout: w.timeout})
+	return conn, bufio.NewReadWriter(reader, buf.Writer), nil
+}
+
+type deadlineReader struct {
+	conn    net.Conn
+	r       io.Reader
+	timeout time.Duration
+}
+
+func (r *deadlineReader) Read(p []byte) (int, error) {
+	r.conn.SetReadDeadline(time.Now().Add(r.timeout))
+	return r.r.Read(p)
+}
+
+// isTimeout reports whether err is a deadline expiring
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
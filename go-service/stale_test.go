@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// withFastPings shortens the keepalive timings for the rest of the test
+func withFastPings(t *testing.T) {
+	t.Helper()
+	setVar(t, &streamPingInterval, 50*time.Millisecond)
+	setVar(t, &streamPingTimeout, 200*time.Millisecond)
+}
+
+// waitOnline waits for stream 1's online count to reach want
+func waitOnline(t *testing.T, want int64, within time.Duration) {
+	t.Helper()
+	deadline := time.Now().Add(within)
+	for {
+		online, _ := store.OnlineCount(ctx, 1)
+		if online == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("online = %d, want %d", online, want)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestSilentSocketIsReaped(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &allowedOrigins, []string{"http://localhost"})
+	withFastPings(t)
+	srv := httptest.NewServer(testRouter)
+	t.Cleanup(srv.Close)
+	reaped := newCounter("stream_connections_reaped_total", connectionsReapedHelp)
+	before := reaped.Value()
+
+	// The client never reads, so it never answers a ping either
+	socketClient(t, srv, "v1")
+	waitOnline(t, 1, time.Second)
+	waitOnline(t, 0, 2*time.Second)
+	if reaped.Value() != before+1 {
+		t.Fatalf("reaped = %d, want %d", reaped.Value(), before+1)
+	}
+}
+
+func TestTalkingSocketIsKept(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &allowedOrigins, []string{"http://localhost"})
+	withFastPings(t)
+	srv := httptest.NewServer(testRouter)
+	t.Cleanup(srv.Close)
+
+	ws := socketClient(t, srv, "v1")
+	waitOnline(t, 1, time.Second)
+	for end := time.Now().Add(3 * streamPingTimeout); time.Now().Before(end); {
+		if err := websocket.JSON.Send(ws, socketMessage{Type: "heartbeat"}); err != nil {
+			t.Fatalf("heartbeat: %v", err)
+		}
+		time.Sleep(streamPingInterval)
+	}
+	if online, _ := store.OnlineCount(ctx, 1); online != 1 {
+		t.Fatalf("online = %d with the client heartbeating, want 1", online)
+	}
+}
+
+func TestEventsSendKeepalives(t *testing.T) {
+	resetRedis(t)
+	withFastPings(t)
+	srv := httptest.NewServer(testRouter)
+	reqCtx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		srv.Close()
+	})
+	req, _ := http.NewRequestWithContext(reqCtx, http.MethodGet, srv.URL+"/stream/1/events", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	pings := make(chan struct{}, 10)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if scanner.Text() == ": ping" {
+				pings <- struct{}{}
+			}
+		}
+	}()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-pings:
+		case <-time.After(time.Second):
+			t.Fatalf("got %d keepalives, want 2", i)
+		}
+	}
+}
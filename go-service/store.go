@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// CommentStore holds the data the core chat paths work on: the comment feed,
+// who is online and whether a stream accepts comments. check-update, the
+// live feeds, publishing and heartbeats go through it rather than Redis, so
+// they can run against another backend. STORAGE_BACKEND picks it: "redis"
+// (the default) or "memory", a single-process store for development and
+// tests that runs without Redis. Features outside the core paths
+// (moderation, rankings, rate limits and the like) still talk to Redis
+// directly: on the memory store they fail open while Redis is unreachable,
+// and even with Redis up they don't see its comments, so purges, deletes,
+// edits and integrity checks have nothing to act on.
+type CommentStore interface {
+	// ReadFeed returns what one poll sees of a stream
+	ReadFeed(ctx context.Context, q feedQuery) feedSnapshot
+	// NextCommentSeq allocates a stream's next sequential comment ID
+	NextCommentSeq(ctx context.Context, streamID int64) (int64, error)
+	// SaveComment stores an encoded comment that already has its ID and
+	// timestamp; viewerID is its author, "" when unknown
+	SaveComment(ctx context.Context, streamID int64, viewerID string, cmt *Comment, payload []byte) error
+	// MarkOnline counts a viewer as online for presenceTTL, reporting whether
+	// they were new and the stream's viewer count
+	MarkOnline(ctx context.Context, streamID int64, viewerID string) (bool, int64, error)
+	// MarkOffline stops counting a viewer, reporting whether they were online
+	MarkOffline(ctx context.Context, streamID int64, viewerID string) (bool, error)
+	// OnlineCount returns a stream's viewer count
+	OnlineCount(ctx context.Context, streamID int64) (int64, error)
+	// AllowComments reads a stream's allow_comments flag, which defaults
+	// to on
+	AllowComments(ctx context.Context, streamID int64) (bool, error)
+}
+
+// Storage backends (STORAGE_BACKEND)
+const (
+	storageRedis  = "redis"
+	storageMemory = "memory"
+)
+
+var store CommentStore = redisStore{}
+
+func loadStoreConfig() {
+	switch backend := strings.ToLower(strings.TrimSpace(envString("STORAGE_BACKEND", storageRedis))); backend {
+	case storageMemory:
+		log.Printf("[GO] Using in-memory comment store; data is lost on restart and not shared between replicas")
+		store = newMemoryStore()
+	case storageRedis:
+		store = redisStore{}
+	default:
+		log.Printf("[GO] Warning: unknown STORAGE_BACKEND %q, using %q", backend, storageRedis)
+		store = redisStore{}
+	}
+}
+
+// redisStore is the CommentStore backed by rdb
+type redisStore struct{}
+
+func (redisStore) ReadFeed(ctx context.Context, q feedQuery) feedSnapshot {
+	return readRedisFeed(ctx, q)
+}
+
+func (redisStore) AllowComments(ctx context.Context, streamID int64) (bool, error) {
+	v, err := rdb.Get(ctx, allowCommentsKey(streamID)).Result()
+	if err == redis.Nil {
+		return true, nil
+	}
+	if err != nil {
+		return true, err
+	}
+	return flagEnabled(v), nil
+}
+
+func (redisStore) NextCommentSeq(ctx context.Context, streamID int64) (int64, error) {
+	return rdb.Incr(ctx, commentSeqKey(streamID)).Result()
+}
+
+// SaveComment writes data and index in one transaction so pollers never see
+// half a comment, together with the comment's expiry, velocity and history
+// entries
+func (redisStore) SaveComment(ctx context.Context, streamID int64, viewerID string, cmt *Comment, payload []byte) error {
+	member := strconv.FormatInt(cmt.ID, 10)
+	_, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, commentDataKey(streamID), member, payload)
+		pipe.ZAdd(ctx, commentIndexKey(streamID), &redis.Z{Score: float64(cmt.Timestamp), Member: member})
+		markStreamExists(ctx, pipe, streamID)
+		if cmt.ExpiresAt > 0 {
+			trackExpiry(ctx, pipe, streamID, cmt.ID, cmt.ExpiresAt)
+		}
+		if cmt.Priority {
+			trackPriority(ctx, pipe, streamID, cmt)
+		}
+		// System messages aren't chat activity or anyone's history
+		if cmt.Type != commentTypeSystem {
+			recordVelocity(ctx, pipe, streamID, time.UnixMilli(cmt.Timestamp))
+			recordAuthorship(ctx, pipe, streamID, viewerID, cmt)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("publish comment %d: %w", cmt.ID, err)
+	}
+	return nil
+}
+
+func (redisStore) MarkOnline(ctx context.Context, streamID int64, viewerID string) (bool, int64, error) {
+	onlineKey := onlineSetKey(streamID)
+	var added *redis.IntCmd
+	var online *redis.IntCmd
+	_, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		added = pipe.SAdd(ctx, onlineKey, viewerID)
+		pipe.Expire(ctx, onlineKey, presenceTTL)
+		online = pipe.SCard(ctx, onlineKey)
+		return nil
+	})
+	if err != nil {
+		return false, 0, err
+	}
+	return added.Val() > 0, online.Val(), nil
+}
+
+func (redisStore) MarkOffline(ctx context.Context, streamID int64, viewerID string) (bool, error) {
+	removed, err := rdb.SRem(ctx, onlineSetKey(streamID), viewerID).Result()
+	return removed > 0, err
+}
+
+func (redisStore) OnlineCount(ctx context.Context, streamID int64) (int64, error) {
+	return rdb.SCard(ctx, onlineSetKey(streamID)).Result()
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-redis/redis/v8"
+)
+
+// Producers that shouldn't depend on this service being up can append
+// comments to a Redis Stream (ingest:comments) instead of calling /ingest.
+// Each entry carries one /ingest item as JSON in its "comment" field. With
+// INGEST_STREAM set, the replica holding the ingest lock reads the stream as
+// a member of the INGEST_STREAM_GROUP consumer group (named after its host,
+// or INGEST_STREAM_CONSUMER) and submits every entry like /ingest does.
+// Entries are acknowledged once published, rejected or found invalid; an
+// entry whose store failed stays pending and, like the entries of a
+// consumer that died mid-batch, is claimed again once it has been idle for
+// INGEST_STREAM_RECLAIM_IDLE seconds. After INGEST_STREAM_MAX_DELIVERIES
+// attempts an entry is dropped. Producers are expected to cap the stream
+// (XADD MAXLEN).
+var (
+	ingestStreamEnabled       bool
+	ingestStreamGroup         string
+	ingestStreamConsumer      string
+	ingestStreamBatch         int64
+	ingestStreamReclaimIdle   time.Duration
+	ingestStreamMaxDeliveries int64
+)
+
+const (
+	// ingestLockTTL is how long the ingest lock outlives a consumer that
+	// stopped renewing it
+	ingestLockTTL = 10 * time.Second
+	// ingestBlock bounds one XREADGROUP wait, well inside the lock's TTL
+	ingestBlock = 2 * time.Second
+)
+
+func loadStreamIngestConfig() {
+	ingestStreamEnabled = envBool("INGEST_STREAM", false)
+	ingestStreamGroup = envString("INGEST_STREAM_GROUP", "metastream")
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "metastream"
+	}
+	ingestStreamConsumer = envString("INGEST_STREAM_CONSUMER", host)
+	ingestStreamBatch = int64(envInt("INGEST_STREAM_BATCH", 100))
+	if ingestStreamBatch < 1 {
+		ingestStreamBatch = 100
+	}
+	ingestStreamReclaimIdle = time.Duration(envInt("INGEST_STREAM_RECLAIM_IDLE", 60)) * time.Second
+	if ingestStreamReclaimIdle <= 0 {
+		ingestStreamReclaimIdle = time.Minute
+	}
+	ingestStreamMaxDeliveries = int64(envInt("INGEST_STREAM_MAX_DELIVERIES", 5))
+	if ingestStreamMaxDeliveries < 1 {
+		ingestStreamMaxDeliveries = 5
+	}
+}
+
+// renewLockScript extends a lock's TTL if ARGV[1] still holds it.
+// KEYS: lock. ARGV: holder, TTL (ms).
+var renewLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// runStreamIngest consumes the ingest stream while this replica holds the
+// ingest lock, and waits for the lock otherwise
+func runStreamIngest(ctx context.Context) {
+	if !ingestStreamEnabled {
+		return
+	}
+	jobs.setRunning("stream_ingest", true)
+	defer jobs.setRunning("stream_ingest", false)
+	holding := false
+	for ctx.Err() == nil {
+		var err error
+		if holding, err = holdIngestLock(ctx, holding); err != nil || !holding {
+			jobs.ran("stream_ingest", err)
+			sleepCtx(ctx, ingestLockTTL/2)
+			continue
+		}
+		if err := consumeIngestStream(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("[GO] Stream ingest failed: %v", err)
+			jobs.ran("stream_ingest", err)
+			sleepCtx(ctx, time.Second)
+			continue
+		}
+		jobs.ran("stream_ingest", nil)
+	}
+	if holding {
+		// Hand over right away instead of waiting out the TTL
+		rdb.Del(context.Background(), ingestLockKey())
+	}
+}
+
+// sleepCtx waits for d or until ctx is done
+func sleepCtx(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
+
+// holdIngestLock renews the ingest lock if this replica holds it and tries
+// to take it otherwise, reporting whether it holds it now
+func holdIngestLock(ctx context.Context, holding bool) (bool, error) {
+	if holding {
+		renewed, err := renewLockScript.Run(ctx, rdb, []string{ingestLockKey()}, ingestStreamConsumer, ingestLockTTL.Milliseconds()).Int()
+		if err != nil {
+			return false, err
+		}
+		if renewed == 0 {
+			log.Printf("[GO] Stream ingest: lost the ingest lock")
+		}
+		return renewed == 1, nil
+	}
+	claimed, err := rdb.SetNX(ctx, ingestLockKey(), ingestStreamConsumer, ingestLockTTL).Result()
+	if err != nil || !claimed {
+		return false, err
+	}
+	if err := createIngestGroup(ctx); err != nil {
+		rdb.Del(ctx, ingestLockKey())
+		return false, err
+	}
+	log.Printf("[GO] Stream ingest: consuming %s as %s/%s", ingestStreamKey(), ingestStreamGroup, ingestStreamConsumer)
+	return true, nil
+}
+
+// createIngestGroup creates the consumer group, and the stream with it, if
+// either is missing. A new group starts at the beginning of the stream.
+func createIngestGroup(ctx context.Context) error {
+	err := rdb.XGroupCreateMkStream(ctx, ingestStreamKey(), ingestStreamGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	return nil
+}
+
+// consumeIngestStream reclaims stalled entries, then processes one batch of
+// new ones
+func consumeIngestStream(ctx context.Context) error {
+	err := reclaimIngestEntries(ctx)
+	if err != nil && strings.HasPrefix(err.Error(), "NOGROUP") {
+		// The stream was deleted since the group was created
+		return createIngestGroup(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	streams, err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    ingestStreamGroup,
+		Consumer: ingestStreamConsumer,
+		Streams:  []string{ingestStreamKey(), ">"},
+		Count:    ingestStreamBatch,
+		Block:    ingestBlock,
+	}).Result()
+	if err == redis.Nil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, s := range streams {
+		processIngestEntries(ctx, s.Messages)
+	}
+	return nil
+}
+
+// reclaimIngestEntries takes over the entries left pending for longer than
+// INGEST_STREAM_RECLAIM_IDLE, whoever they were delivered to, and processes
+// them again; entries out of deliveries are dropped
+func reclaimIngestEntries(ctx context.Context) error {
+	pending, err := rdb.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: ingestStreamKey(),
+		Group:  ingestStreamGroup,
+		Idle:   ingestStreamReclaimIdle,
+		Start:  "-",
+		End:    "+",
+		Count:  ingestStreamBatch,
+	}).Result()
+	if err != nil || len(pending) == 0 {
+		return err
+	}
+	var retry, drop []string
+	for _, p := range pending {
+		if p.RetryCount >= ingestStreamMaxDeliveries {
+			drop = append(drop, p.ID)
+		} else {
+			retry = append(retry, p.ID)
+		}
+	}
+	if len(drop) > 0 {
+		log.Printf("[GO] Stream ingest: dropping %d entries after %d deliveries", len(drop), ingestStreamMaxDeliveries)
+		if err := rdb.XAck(ctx, ingestStreamKey(), ingestStreamGroup, drop...).Err(); err != nil {
+			return err
+		}
+	}
+	if len(retry) == 0 {
+		return nil
+	}
+	messages, err := rdb.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   ingestStreamKey(),
+		Group:    ingestStreamGroup,
+		Consumer: ingestStreamConsumer,
+		MinIdle:  ingestStreamReclaimIdle,
+		Messages: retry,
+	}).Result()
+	if err != nil {
+		return err
+	}
+	log.Printf("[GO] Stream ingest: reclaimed %d pending entries", len(messages))
+	processIngestEntries(ctx, messages)
+	return nil
+}
+
+// processIngestEntries submits each entry and acknowledges the ones that
+// are done with: published, rejected or undecodable
+func processIngestEntries(ctx context.Context, messages []redis.XMessage) {
+	done := make([]string, 0, len(messages))
+	published := 0
+	for _, msg := range messages {
+		err := ingestEntry(ctx, msg)
+		if errors.Is(err, errIngestStore) {
+			continue
+		}
+		if err == nil {
+			published++
+		}
+		done = append(done, msg.ID)
+	}
+	if len(done) > 0 {
+		if err := rdb.XAck(ctx, ingestStreamKey(), ingestStreamGroup, done...).Err(); err != nil {
+			log.Printf("[GO] Stream ingest: Error acknowledging %d entries: %v", len(done), err)
+		}
+	}
+	if len(messages) > 0 {
+		log.Printf("[GO] Stream ingest: published %d of %d comments", published, len(messages))
+	}
+}
+
+// errIngestStore marks an entry whose comment couldn't be stored, which is
+// worth retrying
+var errIngestStore = errors.New("failed to store comment")
+
+// ingestEntry submits one stream entry, returning nil once it is published
+func ingestEntry(ctx context.Context, msg redis.XMessage) error {
+	raw, _ := msg.Values["comment"].(string)
+	var item IngestComment
+	if err := binding.JSON.BindBody([]byte(raw), &item); err != nil {
+		log.Printf("[GO] Stream ingest: Entry %s is invalid: %v", msg.ID, err)
+		return err
+	}
+	_, rejection, err := submitComment(ctx, item.PostCommentRequest, commentOrigin{Source: item.Source})
+	if err != nil {
+		log.Printf("[GO] Stream ingest: Stream %d: Error storing comment from entry %s: %v", int64(item.StreamID), msg.ID, err)
+		return errIngestStore
+	}
+	if rejection != nil {
+		return errors.New(rejection.Reason)
+	}
+	return nil
+}
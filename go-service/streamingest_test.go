@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// addIngestEntry appends a raw entry to the ingest stream
+func addIngestEntry(t *testing.T, comment string) string {
+	t.Helper()
+	id, err := rdb.XAdd(ctx, &redis.XAddArgs{Stream: ingestStreamKey(), Values: map[string]interface{}{"comment": comment}}).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// ingestItem is an entry for a bridged comment on stream 1
+func ingestItem(message string) string {
+	return fmt.Sprintf(`{"stream_id": 1, "username": "bridged", "message": %q, "source": "twitch"}`, message)
+}
+
+// pendingIngest counts the group's unacknowledged entries
+func pendingIngest(t *testing.T) int64 {
+	t.Helper()
+	p, err := rdb.XPending(ctx, ingestStreamKey(), ingestStreamGroup).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.Count
+}
+
+// abandonIngestEntries delivers the new entries to a consumer that dies
+// before acknowledging them
+func abandonIngestEntries(t *testing.T) {
+	t.Helper()
+	if err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group: ingestStreamGroup, Consumer: "dead", Streams: []string{ingestStreamKey(), ">"}, Count: 10,
+	}).Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStreamIngestConsumesAndAcks(t *testing.T) {
+	resetRedis(t)
+	if err := createIngestGroup(ctx); err != nil {
+		t.Fatal(err)
+	}
+	addIngestEntry(t, ingestItem("from the stream"))
+	addIngestEntry(t, "not json")
+	addIngestEntry(t, ingestItem("another one"))
+
+	if err := consumeIngestStream(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := rdb.HLen(ctx, commentDataKey(1)).Val(); n != 2 {
+		t.Fatalf("comments published = %d, want 2", n)
+	}
+	// The invalid entry is acknowledged too: retrying it can't help
+	if n := pendingIngest(t); n != 0 {
+		t.Fatalf("pending entries = %d, want 0", n)
+	}
+}
+
+func TestStreamIngestReclaimsPendingEntries(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &ingestStreamReclaimIdle, 10*time.Millisecond)
+	setVar(t, &ingestStreamMaxDeliveries, int64(2))
+	if err := createIngestGroup(ctx); err != nil {
+		t.Fatal(err)
+	}
+	addIngestEntry(t, ingestItem("orphaned"))
+	abandonIngestEntries(t)
+	if n := pendingIngest(t); n != 1 {
+		t.Fatalf("pending entries = %d, want 1", n)
+	}
+
+	// Not idle long enough yet: left alone
+	addIngestEntry(t, ingestItem("fresh"))
+	if err := consumeIngestStream(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := pendingIngest(t); n != 1 {
+		t.Fatalf("pending entries before the idle time = %d, want 1", n)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	addIngestEntry(t, ingestItem("fresh again"))
+	if err := consumeIngestStream(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := pendingIngest(t); n != 0 {
+		t.Fatalf("pending entries after reclaiming = %d, want 0", n)
+	}
+	if n := rdb.HLen(ctx, commentDataKey(1)).Val(); n != 3 {
+		t.Fatalf("comments published = %d, want 3 including the reclaimed one", n)
+	}
+}
+
+func TestStreamIngestDropsEntriesOutOfDeliveries(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &ingestStreamReclaimIdle, 10*time.Millisecond)
+	setVar(t, &ingestStreamMaxDeliveries, int64(1))
+	if err := createIngestGroup(ctx); err != nil {
+		t.Fatal(err)
+	}
+	addIngestEntry(t, ingestItem("poison"))
+	abandonIngestEntries(t)
+
+	time.Sleep(20 * time.Millisecond)
+	addIngestEntry(t, ingestItem("fresh"))
+	if err := consumeIngestStream(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := pendingIngest(t); n != 0 {
+		t.Fatalf("pending entries = %d, want 0", n)
+	}
+	if n := rdb.HLen(ctx, commentDataKey(1)).Val(); n != 1 {
+		t.Fatalf("comments published = %d, want only the fresh one", n)
+	}
+}
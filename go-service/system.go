@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// System messages are notices in the comment feed from the service or the
+// backend ("Slow mode enabled", "User X was banned"). They carry
+// type "system" and the reserved systemAuthor name so clients can render
+// them apart, and skip the checks user comments go through.
+const (
+	commentTypeSystem = "system"
+	systemAuthor      = "System"
+)
+
+// systemMessages makes the service announce its own automatic actions, such
+// as raid slow-mode, in the feed (SYSTEM_MESSAGES)
+var systemMessages bool
+
+func loadSystemConfig() {
+	systemMessages = envBool("SYSTEM_MESSAGES", false)
+}
+
+// isReservedName reports whether a username is reserved for system messages
+func isReservedName(username string) bool {
+	return strings.EqualFold(strings.TrimSpace(username), systemAuthor)
+}
+
+// emitSystemMessage publishes a system message into a stream's feed
+func emitSystemMessage(ctx context.Context, streamID int64, message string) (*Comment, error) {
+	cmt := Comment{Type: commentTypeSystem, Username: systemAuthor, Message: message}
+	if err := publishComment(ctx, streamID, "", &cmt, 0); err != nil {
+		return nil, err
+	}
+	return &cmt, nil
+}
+
+// announce emits a system message for an automatic action when enabled
+func announce(ctx context.Context, streamID int64, format string, args ...interface{}) {
+	if !systemMessages {
+		return
+	}
+	if _, err := emitSystemMessage(ctx, streamID, fmt.Sprintf(format, args...)); err != nil {
+		log.Printf("[GO] Stream %d: Error emitting system message: %v", streamID, err)
+	}
+}
+
+type SystemMessageRequest struct {
+	Message string `json:"message" binding:"required,max=500"`
+}
+
+// postSystemMessage lets the backend put a notice in a stream's feed, e.g.
+// after a ban or a chat clear
+func postSystemMessage(c *gin.Context) {
+	streamID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid stream id"})
+		return
+	}
+	var req SystemMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	cmt, err := emitSystemMessage(c.Request.Context(), streamID, req.Message)
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error storing system message: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to store system message"})
+		return
+	}
+	c.JSON(200, gin.H{"success": true, "comment": cmt})
+}
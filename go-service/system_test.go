@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+// withProfanityWords adds words to a profanity tier for the rest of a test
+func withProfanityWords(t *testing.T, tier string, words ...string) {
+	t.Helper()
+	rdb.SAdd(ctx, profanityWordsKey(tier), words)
+	profanity.reload(ctx)
+	t.Cleanup(func() {
+		rdb.Del(ctx, profanityWordsKey(tier))
+		profanity.reload(ctx)
+	})
+}
+
+// systemComments lists the messages of a response's system comments,
+// checking their author
+func systemComments(t *testing.T, resp map[string]interface{}) []string {
+	t.Helper()
+	var out []string
+	list, _ := resp["comments"].([]interface{})
+	for _, c := range list {
+		m := c.(map[string]interface{})
+		if m["type"] != commentTypeSystem {
+			continue
+		}
+		if m["username"] != systemAuthor {
+			t.Fatalf("system comment by %v, want %s", m["username"], systemAuthor)
+		}
+		out = append(out, m["message"].(string))
+	}
+	return out
+}
+
+func TestSystemMessageFromBackend(t *testing.T) {
+	resetRedis(t)
+	withProfanityWords(t, tierSevere, "darn")
+	expectStatus(t, request(t, http.MethodPost, "/stream/1/system", map[string]interface{}{"message": "Be nice"}), 401)
+	expectStatus(t, request(t, http.MethodPost, "/stream/1/system", map[string]interface{}{"message": "Be nice"}, trusted...), 200)
+	// Filters for viewers don't apply to notices
+	expectStatus(t, post(t, 1, "v1", "alice", "darn it"), 403)
+	expectStatus(t, request(t, http.MethodPost, "/stream/1/system", map[string]interface{}{"message": "No darn spoilers"}, trusted...), 200)
+	expectStatus(t, post(t, 1, "v1", "alice", "hello"), 200)
+	nextSecond()
+
+	resp := poll(t, 1, "v2", 0)
+	if got := systemComments(t, resp); len(got) != 2 || got[0] != "Be nice" || got[1] != "No darn spoilers" {
+		t.Fatalf("system comments = %v", got)
+	}
+	if got := messages(resp); len(got) != 3 || got[2] != "hello" {
+		t.Fatalf("comments = %v, want the notices then hello", got)
+	}
+}
+
+func TestModerationActionsAnnounce(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &systemMessages, true)
+	expectStatus(t, post(t, 1, "v1", "alice", "hello"), 200)
+	expectStatus(t, request(t, http.MethodPost, "/stream/1/ban", map[string]interface{}{"viewer_id": "v1", "username": "alice"}, asRole(roleModerator)...), 200)
+	expectStatus(t, request(t, http.MethodPost, "/stream/1/ban", map[string]interface{}{"viewer_id": "v2", "username": "bob", "shadow": true}, asRole(roleModerator)...), 200)
+	nextSecond()
+
+	if got := systemComments(t, poll(t, 1, "v3", 0)); len(got) != 1 || got[0] != "alice was banned" {
+		t.Fatalf("system comments = %v, want only the visible ban announced", got)
+	}
+}
+
+func TestSystemAuthorIsReserved(t *testing.T) {
+	resetRedis(t)
+	for _, name := range []string{"System", "system", " SYSTEM "} {
+		w := post(t, 1, "v1", name, "I am the system")
+		if w.Code != 403 || decode(t, w)["reason"] != "reserved_name" {
+			t.Fatalf("posting as %q: %d %s, want 403 reserved_name", name, w.Code, w.Body.String())
+		}
+	}
+}
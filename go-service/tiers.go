@@ -0,0 +1,89 @@
+package main
+
+import (
+	"math"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Comments can be gated to subscribers: a comment with min_tier is only
+// delivered to viewers subscribed at that tier or above, plus moderators.
+// This gates reading, not posting. SUBSCRIBER_TIERS lists the tiers from
+// lowest to highest; the viewer's tier comes from the trusted caller in
+// X-Viewer-Tier, like their role. A post sets min_tier for itself (the poster
+// must hold the tier), and a stream's visible_tier mode gates everything
+// posted while it is on, for subscriber-only segments of the chat. Posters
+// below a segment's tier can still post but won't see their comments in the
+// feed. Gated comments are left out rather than redacted, and the cursor
+// still moves past them.
+var subscriberTiers []string
+
+func loadTierConfig() {
+	subscriberTiers = nil
+	for _, tier := range strings.Split(envString("SUBSCRIBER_TIERS", "subscriber"), ",") {
+		if tier = strings.ToLower(strings.TrimSpace(tier)); tier != "" {
+			subscriberTiers = append(subscriberTiers, tier)
+		}
+	}
+}
+
+// tierAccessAll is the access of viewers who see every comment
+const tierAccessAll = math.MaxInt
+
+// isTier reports whether name is a configured tier
+func isTier(name string) bool {
+	for _, t := range subscriberTiers {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
+
+// tierRank orders tiers: 0 for none, 1 for the lowest. Tiers that are no
+// longer configured rank above all others, so their comments stay hidden.
+func tierRank(name string) int {
+	if name == "" {
+		return 0
+	}
+	for i, t := range subscriberTiers {
+		if t == name {
+			return i + 1
+		}
+	}
+	return len(subscriberTiers) + 1
+}
+
+// stricterTier returns whichever of two tiers ranks higher
+func stricterTier(a, b string) string {
+	if tierRank(b) > tierRank(a) {
+		return b
+	}
+	return a
+}
+
+// requestTier returns the subscriber tier of the viewer behind the request.
+// Only trusted callers can assert one; everyone else has none.
+func requestTier(c *gin.Context) string {
+	if !isTrustedRequest(c) {
+		return ""
+	}
+	if tier := strings.ToLower(strings.TrimSpace(c.GetHeader("X-Viewer-Tier"))); isTier(tier) {
+		return tier
+	}
+	return ""
+}
+
+// viewerAccess is the highest tier rank the request may read
+func viewerAccess(c *gin.Context) int {
+	if isPrivileged(requestRole(c)) {
+		return tierAccessAll
+	}
+	return tierRank(requestTier(c))
+}
+
+// canSee reports whether a viewer with the given access may read a comment
+func canSee(cmt Comment, access int) bool {
+	return cmt.MinTier == "" || access >= tierRank(cmt.MinTier)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // validate zones even on images without a zoneinfo database
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// Comment timestamps are UTC milliseconds. Clients that would rather not
+// ship a timezone library can ask for display_time as well (display_time in
+// check-update, ?display_time=true on the live feeds): the timestamp as local
+// time in the stream's timezone, which moderators set to an IANA zone name
+// (stream:tz:<stream_id>) and which otherwise is DISPLAY_TIMEZONE. The zone
+// abbreviation makes daylight saving time visible.
+const displayTimeLayout = "2006-01-02 15:04:05 MST"
+
+var defaultDisplayZone *time.Location
+
+func loadTimezoneConfig() {
+	name := envString("DISPLAY_TIMEZONE", "UTC")
+	loc, err := loadZone(name)
+	if err != nil {
+		log.Printf("[GO] Warning: invalid DISPLAY_TIMEZONE %q, using UTC: %v", name, err)
+		loc = time.UTC
+	}
+	defaultDisplayZone = loc
+}
+
+// zones caches loaded locations, which are parsed from the database each time
+var zones sync.Map
+
+// loadZone resolves an IANA zone name. "Local" is refused since it names the
+// server's zone, not a place.
+func loadZone(name string) (*time.Location, error) {
+	if cached, ok := zones.Load(name); ok {
+		return cached.(*time.Location), nil
+	}
+	if name == "" || strings.EqualFold(name, "local") {
+		return nil, errInvalidZone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	zones.Store(name, loc)
+	return loc, nil
+}
+
+var errInvalidZone = errors.New("not an IANA timezone")
+
+// streamZone returns the zone a stream's display times are in
+func streamZone(ctx context.Context, streamID int64) *time.Location {
+	name, err := rdb.Get(ctx, timezoneKey(streamID)).Result()
+	if err != nil {
+		if err != redis.Nil {
+			log.Printf("[GO] Stream %d: Error loading timezone: %v", streamID, err)
+		}
+		return defaultDisplayZone
+	}
+	return zoneNamed(name)
+}
+
+// zoneNamed returns a stream's stored zone, the default when it is invalid
+func zoneNamed(name string) *time.Location {
+	loc, err := loadZone(name)
+	if err != nil {
+		return defaultDisplayZone
+	}
+	return loc
+}
+
+// withDisplayTime sets display_time on comments for a zone; nil leaves them
+func withDisplayTime(comments []Comment, loc *time.Location) []Comment {
+	if loc == nil {
+		return comments
+	}
+	for i := range comments {
+		comments[i].DisplayTime = formatDisplayTime(comments[i].Timestamp, loc)
+	}
+	return comments
+}
+
+func formatDisplayTime(ms int64, loc *time.Location) string {
+	return time.UnixMilli(ms).In(loc).Format(displayTimeLayout)
+}
+
+type TimezoneRequest struct {
+	Timezone string `json:"timezone"` // "" reverts to DISPLAY_TIMEZONE
+}
+
+// setTimezone sets the zone a stream's display times are formatted in
+func setTimezone(c *gin.Context) {
+	streamID, ok := parseStreamID(c)
+	if !ok {
+		return
+	}
+	var req TimezoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	name := strings.TrimSpace(req.Timezone)
+	reqCtx := c.Request.Context()
+	if name == "" {
+		if err := rdb.Del(reqCtx, timezoneKey(streamID)).Err(); err != nil {
+			log.Printf("[GO] Stream %d: Error removing timezone: %v", streamID, err)
+			c.JSON(500, gin.H{"error": "failed to update timezone"})
+			return
+		}
+		auditRequest(c, streamID, "timezone_updated", nil, "", map[string]interface{}{"timezone": defaultDisplayZone.String()})
+		c.JSON(200, gin.H{"success": true, "timezone": defaultDisplayZone.String()})
+		return
+	}
+	loc, err := loadZone(name)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "unknown timezone: " + name})
+		return
+	}
+	if err := rdb.Set(reqCtx, timezoneKey(streamID), loc.String(), 0).Err(); err != nil {
+		log.Printf("[GO] Stream %d: Error storing timezone: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to update timezone"})
+		return
+	}
+	log.Printf("[GO] Stream %d: Timezone set to %s", streamID, loc)
+	auditRequest(c, streamID, "timezone_updated", nil, "", map[string]interface{}{"timezone": loc.String()})
+	c.JSON(200, gin.H{"success": true, "timezone": loc.String()})
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFormatDisplayTimeAcrossZones(t *testing.T) {
+	for _, tc := range []struct {
+		zone string
+		utc  string
+		want string
+	}{
+		{"UTC", "2024-07-01T12:00:00Z", "2024-07-01 12:00:00 UTC"},
+		{"Asia/Kolkata", "2024-07-01T00:00:00Z", "2024-07-01 05:30:00 IST"},
+		// Spring forward skips 02:00 to 03:00
+		{"America/New_York", "2024-03-10T06:59:59Z", "2024-03-10 01:59:59 EST"},
+		{"America/New_York", "2024-03-10T07:00:00Z", "2024-03-10 03:00:00 EDT"},
+		// Fall back repeats 01:00 to 02:00, told apart by the abbreviation
+		{"America/New_York", "2024-11-03T05:30:00Z", "2024-11-03 01:30:00 EDT"},
+		{"America/New_York", "2024-11-03T06:30:00Z", "2024-11-03 01:30:00 EST"},
+		{"Europe/London", "2024-03-31T00:59:59Z", "2024-03-31 00:59:59 GMT"},
+		{"Europe/London", "2024-03-31T01:00:00Z", "2024-03-31 02:00:00 BST"},
+		// Southern hemisphere clocks go back in April, across the date line
+		{"Australia/Sydney", "2024-04-06T15:59:59Z", "2024-04-07 02:59:59 AEDT"},
+		{"Australia/Sydney", "2024-04-06T16:00:00Z", "2024-04-07 02:00:00 AEST"},
+	} {
+		loc, err := loadZone(tc.zone)
+		if err != nil {
+			t.Fatalf("loadZone(%q): %v", tc.zone, err)
+		}
+		at, _ := time.Parse(time.RFC3339, tc.utc)
+		if got := formatDisplayTime(at.UnixMilli(), loc); got != tc.want {
+			t.Errorf("%s at %s = %q, want %q", tc.zone, tc.utc, got, tc.want)
+		}
+	}
+}
+
+func TestLoadZoneRejectsNonIANANames(t *testing.T) {
+	for _, name := range []string{"", "Local", "local", "Mars/Olympus_Mons", "EST+99"} {
+		if _, err := loadZone(name); err == nil {
+			t.Errorf("loadZone(%q) succeeded, want an error", name)
+		}
+	}
+	// A bad stored zone falls back to the default
+	if got := zoneNamed("Mars/Olympus_Mons"); got != defaultDisplayZone {
+		t.Fatalf("zoneNamed(invalid) = %v, want %v", got, defaultDisplayZone)
+	}
+}
+
+// pollDisplayTime polls stream 1 from the start, asking for display_time
+func pollDisplayTime(t *testing.T, displayTime bool) map[string]interface{} {
+	t.Helper()
+	w := request(t, http.MethodPost, "/check-update", map[string]interface{}{
+		"stream_id": 1, "viewer_id": "v2", "last_id": 0, "display_time": displayTime,
+	})
+	expectStatus(t, w, 200)
+	return decode(t, w)
+}
+
+func TestStreamTimezone(t *testing.T) {
+	resetRedis(t)
+	setZone := func(name string, status int) map[string]interface{} {
+		t.Helper()
+		w := request(t, http.MethodPost, "/stream/1/timezone", map[string]interface{}{"timezone": name}, asModerator...)
+		expectStatus(t, w, status)
+		return decode(t, w)
+	}
+	ts := time.Now().Add(-time.Minute).UnixMilli()
+	saveAt(t, ts, 1)
+
+	setZone("Mars/Olympus_Mons", 400)
+	if resp := setZone("Asia/Tokyo", 200); resp["timezone"] != "Asia/Tokyo" {
+		t.Fatalf("timezone = %v, want Asia/Tokyo", resp["timezone"])
+	}
+	tokyo, _ := loadZone("Asia/Tokyo")
+	resp := pollDisplayTime(t, true)
+	cmt := resp["comments"].([]interface{})[0].(map[string]interface{})
+	if cmt["display_time"] != formatDisplayTime(ts, tokyo) || resp["timezone"] != "Asia/Tokyo" {
+		t.Fatalf("display_time = %v in %v, want %q", cmt["display_time"], resp["timezone"], formatDisplayTime(ts, tokyo))
+	}
+	if cmt["timestamp"] != float64(ts) {
+		t.Fatalf("timestamp = %v, want the raw %d kept", cmt["timestamp"], ts)
+	}
+
+	// Only clients that ask get it
+	resp = pollDisplayTime(t, false)
+	if cmt := resp["comments"].([]interface{})[0].(map[string]interface{}); cmt["display_time"] != nil || resp["timezone"] != nil {
+		t.Fatalf("unrequested display_time = %v in %v", cmt["display_time"], resp["timezone"])
+	}
+
+	// Clearing reverts to DISPLAY_TIMEZONE
+	if resp := setZone("", 200); resp["timezone"] != defaultDisplayZone.String() {
+		t.Fatalf("cleared timezone = %v, want %v", resp["timezone"], defaultDisplayZone)
+	}
+	cmt = pollDisplayTime(t, true)["comments"].([]interface{})[0].(map[string]interface{})
+	if cmt["display_time"] != formatDisplayTime(ts, defaultDisplayZone) {
+		t.Fatalf("display_time = %v after clearing, want the default zone's", cmt["display_time"])
+	}
+}
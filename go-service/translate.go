@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// POST /comment/translate translates a comment's message into a target
+// language on demand. Translations come from a Translator, picked by
+// TRANSLATOR: "noop" (the default) hands the message back unchanged and
+// "stub" tags it with the target, for development; providers plug in by
+// implementing the interface. Targets must be in TRANSLATION_LANGUAGES and
+// supported by the translator. Results are cached per comment version and
+// target for TRANSLATION_CACHE_TTL seconds, so an edited comment is
+// translated afresh. TRANSLATOR=off turns the endpoint off.
+type Translator interface {
+	// Translate returns text in target, and the language it was in when
+	// known. Targets it can't handle return errUnsupportedLanguage.
+	Translate(ctx context.Context, text, target string) (Translation, error)
+}
+
+// Translation is a translated text
+type Translation struct {
+	Text   string `json:"text"`
+	Source string `json:"source,omitempty"` // detected source language
+}
+
+var errUnsupportedLanguage = errors.New("unsupported language")
+
+// Translators (TRANSLATOR)
+const (
+	translatorOff  = "off"
+	translatorNoop = "noop"
+	translatorStub = "stub"
+)
+
+var (
+	translator          Translator
+	translationLangs    map[string]bool
+	translationCacheTTL time.Duration
+)
+
+func loadTranslateConfig() {
+	switch name := strings.ToLower(strings.TrimSpace(envString("TRANSLATOR", translatorNoop))); name {
+	case translatorOff:
+		translator = nil
+	case translatorStub:
+		translator = stubTranslator{}
+	case translatorNoop:
+		translator = noopTranslator{}
+	default:
+		log.Printf("[GO] Warning: unknown TRANSLATOR %q, using %q", name, translatorNoop)
+		translator = noopTranslator{}
+	}
+	translationLangs = map[string]bool{}
+	for _, lang := range strings.Split(envString("TRANSLATION_LANGUAGES", "en,es,fr,de,pt,it,ar,fa,tr,ru,hi,ja,ko,zh"), ",") {
+		if lang = normalizeLanguage(lang); lang != "" {
+			translationLangs[lang] = true
+		}
+	}
+	translationCacheTTL = time.Duration(envInt("TRANSLATION_CACHE_TTL", 3600)) * time.Second
+}
+
+// normalizeLanguage lower-cases a language tag's primary subtag ("pt-BR"
+// is "pt"), "" when it isn't one
+func normalizeLanguage(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	if len(tag) < 2 || len(tag) > 3 {
+		return ""
+	}
+	for _, r := range tag {
+		if r < 'a' || r > 'z' {
+			return ""
+		}
+	}
+	return tag
+}
+
+// noopTranslator returns messages as they are
+type noopTranslator struct{}
+
+func (noopTranslator) Translate(ctx context.Context, text, target string) (Translation, error) {
+	return Translation{Text: text}, nil
+}
+
+// stubTranslator marks messages with their target, to see translations flow
+// through clients without a provider
+type stubTranslator struct{}
+
+func (stubTranslator) Translate(ctx context.Context, text, target string) (Translation, error) {
+	return Translation{Text: fmt.Sprintf("[%s] %s", target, text)}, nil
+}
+
+// cachedTranslation returns a comment's cached translation, if any
+func cachedTranslation(ctx context.Context, streamID int64, cmt Comment, target string) (Translation, bool) {
+	var t Translation
+	raw, err := rdb.HGet(ctx, translationsKey(streamID, cmt.ID), translationField(cmt, target)).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			log.Printf("[GO] Stream %d: Error loading translation of comment %d: %v", streamID, cmt.ID, err)
+		}
+		return t, false
+	}
+	return t, json.Unmarshal(raw, &t) == nil
+}
+
+// cacheTranslation stores a comment's translation for translationCacheTTL
+func cacheTranslation(ctx context.Context, streamID int64, cmt Comment, target string, t Translation) {
+	payload, err := json.Marshal(t)
+	if err != nil {
+		return
+	}
+	k := translationsKey(streamID, cmt.ID)
+	_, err = rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, k, translationField(cmt, target), payload)
+		pipe.Expire(ctx, k, translationCacheTTL)
+		return nil
+	})
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error caching translation of comment %d: %v", streamID, cmt.ID, err)
+	}
+}
+
+// translationField keys a translation by the comment version it was made from
+func translationField(cmt Comment, target string) string {
+	return strconv.FormatInt(cmt.Version, 10) + ":" + target
+}
+
+type TranslateRequest struct {
+	StreamID  flexID `json:"stream_id" binding:"required"`
+	CommentID flexID `json:"comment_id" binding:"required"`
+	Target    string `json:"target" binding:"required"`
+}
+
+// translateComment returns a comment's message in the requested language
+func translateComment(c *gin.Context) {
+	if translator == nil {
+		c.JSON(404, gin.H{"error": "translation is not enabled"})
+		return
+	}
+	var req TranslateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	target := normalizeLanguage(req.Target)
+	if !translationLangs[target] {
+		c.JSON(400, gin.H{"error": "target language is not supported", "reason": "unsupported_language"})
+		return
+	}
+
+	reqCtx := c.Request.Context()
+	commentID := int64(req.CommentID)
+	raw, err := rdb.HGet(reqCtx, commentDataKey(int64(req.StreamID)), strconv.FormatInt(commentID, 10)).Result()
+	if err != nil && err != redis.Nil {
+		log.Printf("[GO] Stream %d: Error loading comment %d: %v", int64(req.StreamID), commentID, err)
+		c.JSON(500, gin.H{"error": "failed to load comment"})
+		return
+	}
+	var cmt Comment
+	// Comments the viewer couldn't read aren't theirs to translate either
+	if err == redis.Nil || json.Unmarshal([]byte(raw), &cmt) != nil || isExpired(cmt, time.Now().UnixMilli()) ||
+		tierRank(cmt.MinTier) > viewerAccess(c) {
+		c.JSON(404, gin.H{"error": "comment not found"})
+		return
+	}
+
+	resp := gin.H{"comment_id": cmt.ID, "version": cmt.Version, "original": cmt.Message, "target": target}
+	if t, ok := cachedTranslation(reqCtx, int64(req.StreamID), cmt, target); ok {
+		respondTranslation(c, resp, t, true)
+		return
+	}
+	t, err := translator.Translate(reqCtx, cmt.Message, target)
+	if errors.Is(err, errUnsupportedLanguage) {
+		c.JSON(400, gin.H{"error": "target language is not supported", "reason": "unsupported_language"})
+		return
+	}
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error translating comment %d: %v", int64(req.StreamID), cmt.ID, err)
+		c.JSON(502, gin.H{"error": "translation failed", "reason": "translator_error"})
+		return
+	}
+	cacheTranslation(reqCtx, int64(req.StreamID), cmt, target, t)
+	respondTranslation(c, resp, t, false)
+}
+
+func respondTranslation(c *gin.Context, resp gin.H, t Translation, cached bool) {
+	resp["translated"], resp["cached"] = t.Text, cached
+	if t.Source != "" {
+		resp["source"] = t.Source
+	}
+	c.JSON(200, resp)
+}
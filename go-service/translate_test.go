@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+// countingTranslator wraps a translator, counting the calls that reach it
+type countingTranslator struct {
+	Translator
+	calls *int
+}
+
+func (t countingTranslator) Translate(ctx context.Context, text, target string) (Translation, error) {
+	*t.calls++
+	return t.Translator.Translate(ctx, text, target)
+}
+
+// failingTranslator refuses every target with err
+type failingTranslator struct{ err error }
+
+func (t failingTranslator) Translate(ctx context.Context, text, target string) (Translation, error) {
+	return Translation{}, t.err
+}
+
+// translate asks for a comment of stream 1 in target
+func translate(t *testing.T, commentID int64, target string) (int, map[string]interface{}) {
+	t.Helper()
+	w := request(t, http.MethodPost, "/comment/translate", map[string]interface{}{"stream_id": 1, "comment_id": commentID, "target": target})
+	return w.Code, decode(t, w)
+}
+
+func TestTranslateWithStubAndCache(t *testing.T) {
+	resetRedis(t)
+	calls := 0
+	setVar[Translator](t, &translator, countingTranslator{stubTranslator{}, &calls})
+	id := postedID(t, 1, "v1", "alice", "hola")
+
+	if code, resp := translate(t, id, "EN"); code != 200 || resp["translated"] != "[en] hola" || resp["original"] != "hola" || resp["cached"] != false {
+		t.Fatalf("first translation: %d %v", code, resp)
+	}
+	if code, resp := translate(t, id, "en-GB"); code != 200 || resp["translated"] != "[en] hola" || resp["cached"] != true {
+		t.Fatalf("repeated translation: %d %v, want it from the cache", code, resp)
+	}
+	if calls != 1 {
+		t.Fatalf("translator called %d times, want once", calls)
+	}
+	translate(t, id, "fr")
+	if calls != 2 {
+		t.Fatalf("translator called %d times, want another target translated afresh", calls)
+	}
+
+	// An edited comment is a new version, so its old translation doesn't apply
+	raw, _ := rdb.HGet(ctx, commentDataKey(1), strconv.FormatInt(id, 10)).Result()
+	var cmt Comment
+	json.Unmarshal([]byte(raw), &cmt)
+	cmt.Message, cmt.Version = "adiós", cmt.Version+1
+	payload, _ := json.Marshal(cmt)
+	rdb.HSet(ctx, commentDataKey(1), strconv.FormatInt(id, 10), payload)
+	if _, resp := translate(t, id, "en"); resp["translated"] != "[en] adiós" || resp["cached"] != false {
+		t.Fatalf("edited comment = %v, want a fresh translation", resp)
+	}
+}
+
+func TestTranslateUnsupportedAndFailures(t *testing.T) {
+	resetRedis(t)
+	id := postedID(t, 1, "v1", "alice", "hola")
+
+	setVar[Translator](t, &translator, noopTranslator{})
+	if code, resp := translate(t, id, "en"); code != 200 || resp["translated"] != "hola" {
+		t.Fatalf("noop translation: %d %v", code, resp)
+	}
+	for _, target := range []string{"xx", "english", "1"} {
+		if code, resp := translate(t, id, target); code != 400 || resp["reason"] != "unsupported_language" {
+			t.Fatalf("target %q: %d %v, want 400 unsupported_language", target, code, resp)
+		}
+	}
+	if code, _ := translate(t, id+1000, "en"); code != 404 {
+		t.Fatalf("unknown comment: %d, want 404", code)
+	}
+
+	setVar[Translator](t, &translator, failingTranslator{errUnsupportedLanguage})
+	if code, resp := translate(t, id, "ja"); code != 400 || resp["reason"] != "unsupported_language" {
+		t.Fatalf("target the translator can't handle: %d %v, want 400", code, resp)
+	}
+	setVar[Translator](t, &translator, failingTranslator{errors.New("provider down")})
+	if code, resp := translate(t, id, "ja"); code != 502 || resp["reason"] != "translator_error" {
+		t.Fatalf("translator failure: %d %v, want 502", code, resp)
+	}
+	setVar[Translator](t, &translator, nil)
+	if code, _ := translate(t, id, "en"); code != 404 {
+		t.Fatalf("translation off: %d, want 404", code)
+	}
+}
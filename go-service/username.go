@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Usernames are normalized before anything else sees them, so padding and
+// hidden characters can't make one name pass for another ("alice " or
+// "alice" with a zero-width space inside) or stretch the chat layout:
+// invisible and control characters are stripped, runs of whitespace collapse
+// to one space and the ends are trimmed. ZWNJ stays between non-Latin
+// letters, where Persian spelling needs it, and ZWJ and variation selectors
+// stay inside emoji. The result must be USERNAME_MIN_LENGTH to
+// USERNAME_MAX_LENGTH characters long.
+var (
+	usernameMinLength int
+	usernameMaxLength int
+)
+
+func loadUsernameConfig() {
+	usernameMinLength = envInt("USERNAME_MIN_LENGTH", 1)
+	if usernameMinLength < 1 {
+		usernameMinLength = 1
+	}
+	usernameMaxLength = envInt("USERNAME_MAX_LENGTH", 32)
+}
+
+// normalizeUsername returns the name a requested username is stored under,
+// or why it was refused
+func normalizeUsername(username string) (string, string) {
+	runes := []rune(username)
+	var b strings.Builder
+	b.Grow(len(username))
+	space := false
+	marks := 0
+	var prev rune
+	for i, r := range runes {
+		switch {
+		case unicode.IsSpace(r):
+			space = true
+			continue
+		case isDisallowedInvisible(r), isBlankLetter(r):
+			continue
+		case r == 0x200C: // ZWNJ
+			if i+1 >= len(runes) || !isNonLatinLetter(prev) || !isNonLatinLetter(runes[i+1]) {
+				continue
+			}
+		case r == 0x200D, r == 0xFE0E, r == 0xFE0F: // ZWJ, variation selectors
+			if !isEmojiRune(prev) {
+				continue
+			}
+		case unicode.Is(unicode.Mn, r):
+			marks++
+			if maxCombiningMarks > 0 && marks > maxCombiningMarks {
+				continue
+			}
+		case unicode.In(r, unicode.Cf, unicode.Co, unicode.Cs):
+			continue
+		default:
+			marks = 0
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteRune(r)
+		prev = r
+	}
+
+	name := b.String()
+	n := utf8.RuneCountInString(name)
+	switch {
+	case n == 0:
+		return "", "username is empty"
+	case n < usernameMinLength:
+		return "", fmt.Sprintf("username must be at least %d characters", usernameMinLength)
+	case usernameMaxLength > 0 && n > usernameMaxLength:
+		return "", fmt.Sprintf("username must be at most %d characters", usernameMaxLength)
+	}
+	return name, ""
+}
+
+// isBlankLetter reports whether r is one of the letters and symbols that
+// render as nothing (Hangul fillers, the blank Braille pattern)
+func isBlankLetter(r rune) bool {
+	return r == 0x3164 || r == 0x115F || r == 0x1160 || r == 0xFFA0 || r == 0x2800
+}
+
+// isNonLatinLetter reports whether r is a letter outside ASCII
+func isNonLatinLetter(r rune) bool {
+	return r > unicode.MaxASCII && unicode.IsLetter(r)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeUsername(t *testing.T) {
+	setVar(t, &usernameMinLength, 2)
+	setVar(t, &usernameMaxLength, 12)
+	for _, tc := range []struct {
+		name, in, want string
+	}{
+		{"padded", "  alice\t", "alice"},
+		{"inner whitespace", "alice \u00a0\t smith", "alice smith"},
+		{"zero-width space", "ali\u200bce", "alice"},
+		{"bidi override", "\u202ealice", "alice"},
+		{"hangul filler", "alice\u3164", "alice"},
+		{"persian zwnj", "علی\u200cرضا", "علی\u200cرضا"},
+		{"stray zwnj", "alice\u200c", "alice"},
+		{"emoji zwj", "bob\U0001F468\u200d\U0001F469", "bob\U0001F468\u200d\U0001F469"},
+		{"stray zwj", "al\u200dice", "alice"},
+		{"exactly max", "abcdefghijkl", "abcdefghijkl"},
+	} {
+		got, reason := normalizeUsername(tc.in)
+		if got != tc.want || reason != "" {
+			t.Errorf("%s: normalizeUsername(%q) = %q %q, want %q", tc.name, tc.in, got, reason, tc.want)
+		}
+	}
+	for _, in := range []string{"", "   ", "\u200b\u3164", "a", " a\u200b ", "abcdefghijklm"} {
+		if got, reason := normalizeUsername(in); reason == "" {
+			t.Errorf("normalizeUsername(%q) = %q, want it refused", in, got)
+		}
+	}
+}
+
+func TestPostedUsernameNormalized(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &usernameMaxLength, 12)
+
+	for _, name := range []string{"  alice  ", "ali\u200bce", "alice\u3164"} {
+		w := post(t, 1, "v1", name, "hi")
+		expectStatus(t, w, 200)
+		if got := decode(t, w)["comment"].(map[string]interface{})["username"]; got != "alice" {
+			t.Fatalf("username %q stored as %q, want alice", name, got)
+		}
+	}
+	for _, name := range []string{strings.Repeat("a", 13), "\u200b\u3164"} {
+		w := post(t, 1, "v2", name, "hi")
+		if resp := decode(t, w); w.Code != 400 || resp["reason"] != "invalid_username" {
+			t.Fatalf("username %q: %d %v, want 400 invalid_username", name, w.Code, resp)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Comment velocity is tracked as per-stream counters in fixed-size time
+// buckets, kept long enough to compute the baseline rate.
+const (
+	velocityBucket    = 5 * time.Second
+	velocityRetention = 10 * time.Minute
+)
+
+func velocityBucketAt(ts time.Time) int64 {
+	return ts.UnixMilli() / velocityBucket.Milliseconds()
+}
+
+// recordVelocity counts one published comment in the current bucket
+func recordVelocity(ctx context.Context, pipe redis.Pipeliner, streamID int64, ts time.Time) {
+	key := velocityKey(streamID, velocityBucketAt(ts))
+	pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, velocityRetention)
+}
+
+// commentRate returns the average comments per second over the trailing
+// window, including the current (partial) bucket
+func commentRate(ctx context.Context, streamID int64, window time.Duration) (float64, error) {
+	values, err := rdb.MGet(ctx, velocityKeys(streamID, window)...).Result()
+	if err != nil {
+		return 0, err
+	}
+	return velocityRate(values), nil
+}
+
+// velocityKeys lists the buckets of the trailing window, oldest first
+func velocityKeys(streamID int64, window time.Duration) []string {
+	buckets := int64(window / velocityBucket)
+	if buckets < 1 {
+		buckets = 1
+	}
+	current := velocityBucketAt(time.Now())
+	keys := make([]string, 0, buckets)
+	for b := current - buckets + 1; b <= current; b++ {
+		keys = append(keys, velocityKey(streamID, b))
+	}
+	return keys
+}
+
+// velocityRate averages the counts read from velocityKeys per second
+func velocityRate(values []interface{}) float64 {
+	var total int64
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			n, _ := strconv.ParseInt(s, 10, 64)
+			total += n
+		}
+	}
+	return float64(total) / (float64(len(values)) * velocityBucket.Seconds())
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// Comments carry a version, 0 when posted and bumped by every edit, so two
+// moderators changing the same comment can't silently clobber each other.
+// The single-comment edit and delete endpoints take the version the
+// moderator last saw and answer 409 with the current comment when it has
+// moved on, so they can refetch and retry. Bulk edits (profanity rescans,
+// duplicate counts) are checked the same way and skip comments that changed
+// under them. With COMMENT_EDIT_REQUIRE_VERSION off, requests may leave the
+// version out and act on whatever is stored.
+var commentEditRequireVersion bool
+
+func loadVersionConfig() {
+	commentEditRequireVersion = envBool("COMMENT_EDIT_REQUIRE_VERSION", true)
+}
+
+// deleteVersionScript deletes a comment's data if it is still at the
+// expected version; the rest of its entries go with deleteComments.
+// KEYS: data hash. ARGV: comment id, expected version.
+var deleteVersionScript = redis.NewScript(`
+local current = redis.call('HGET', KEYS[1], ARGV[1])
+if not current then
+	return 0
+end
+local ok, stored = pcall(cjson.decode, current)
+local version = 0
+if ok and type(stored) == 'table' and type(stored.version) == 'number' then
+	version = stored.version
+end
+if version ~= tonumber(ARGV[2]) then
+	return -1
+end
+redis.call('HDEL', KEYS[1], ARGV[1])
+return 1
+`)
+
+// CommentMutationRequest is the base of single-comment moderation requests
+type CommentMutationRequest struct {
+	Version *int64 `json:"version"` // version the change was made from
+	Reason  string `json:"reason"`
+}
+
+type CommentEditRequest struct {
+	CommentMutationRequest
+	Message string `json:"message" binding:"required"`
+}
+
+// loadVersionedComment reads the comment a mutation targets and checks the
+// request's version against it. It writes the error response and returns
+// false when the mutation can't go ahead.
+func loadVersionedComment(c *gin.Context, streamID int64, req CommentMutationRequest) (Comment, bool) {
+	var cmt Comment
+	commentID, err := strconv.ParseInt(c.Param("comment_id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid comment id"})
+		return cmt, false
+	}
+	if req.Version == nil && commentEditRequireVersion {
+		c.JSON(400, gin.H{"error": "version is required"})
+		return cmt, false
+	}
+	raw, err := rdb.HGet(c.Request.Context(), commentDataKey(streamID), strconv.FormatInt(commentID, 10)).Result()
+	if err == redis.Nil {
+		c.JSON(404, gin.H{"error": "comment not found"})
+		return cmt, false
+	}
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error loading comment %d: %v", streamID, commentID, err)
+		c.JSON(500, gin.H{"error": "failed to load comment"})
+		return cmt, false
+	}
+	if err := json.Unmarshal([]byte(raw), &cmt); err != nil || cmt.Type == "system" {
+		c.JSON(404, gin.H{"error": "comment not found"})
+		return cmt, false
+	}
+	if req.Version != nil && *req.Version != cmt.Version {
+		respondVersionConflict(c, cmt)
+		return cmt, false
+	}
+	return cmt, true
+}
+
+// respondVersionConflict tells the caller the comment changed since they read it
+func respondVersionConflict(c *gin.Context, current Comment) {
+	c.JSON(409, gin.H{"error": "comment was changed by someone else", "reason": "version_conflict", "version": current.Version, "comment": current})
+}
+
+// editCommentMessage replaces one comment's message
+func editCommentMessage(c *gin.Context) {
+	streamID, ok := parseStreamID(c)
+	if !ok {
+		return
+	}
+	var req CommentEditRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	message, reason := sanitizeMessage(req.Message)
+	if reason != "" {
+		c.JSON(400, gin.H{"error": reason})
+		return
+	}
+	if message = normalizeMessage(message); message == "" {
+		c.JSON(400, gin.H{"error": "message is required"})
+		return
+	}
+	cmt, ok := loadVersionedComment(c, streamID, req.CommentMutationRequest)
+	if !ok {
+		return
+	}
+
+	reqCtx := c.Request.Context()
+	expected := cmt.Version
+	cmt.Message = message
+	edited := []Comment{cmt}
+	results, err := applyEdits(reqCtx, streamID, edited)
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error editing comment %d: %v", streamID, cmt.ID, err)
+		c.JSON(500, gin.H{"error": "failed to edit comment"})
+		return
+	}
+	switch results[0] {
+	case editMissing:
+		c.JSON(404, gin.H{"error": "comment not found"})
+		return
+	case editConflict:
+		// Lost the race between reading the comment and writing it; the
+		// reload answers with whatever is there now
+		if current, ok := loadVersionedComment(c, streamID, CommentMutationRequest{Version: &expected}); ok {
+			respondVersionConflict(c, current)
+		}
+		return
+	}
+
+	log.Printf("[GO] Stream %d: Edited comment %d (version %d)", streamID, cmt.ID, edited[0].Version)
+	auditRequest(c, streamID, "edit", &ModerationTarget{Username: cmt.Username}, req.Reason, map[string]interface{}{"comment_id": cmt.ID, "version": edited[0].Version})
+	c.JSON(200, gin.H{"success": true, "comment": edited[0]})
+}
+
+// deleteComment removes one comment
+func deleteComment(c *gin.Context) {
+	streamID, ok := parseStreamID(c)
+	if !ok {
+		return
+	}
+	var req CommentMutationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	cmt, ok := loadVersionedComment(c, streamID, req)
+	if !ok {
+		return
+	}
+
+	reqCtx := c.Request.Context()
+	id := strconv.FormatInt(cmt.ID, 10)
+	result, err := deleteVersionScript.Run(reqCtx, rdb, []string{commentDataKey(streamID)}, id, cmt.Version).Int64()
+	if err == nil && result == editApplied {
+		err = moderateComments(reqCtx, streamID, []string{id})
+	}
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error deleting comment %d: %v", streamID, cmt.ID, err)
+		c.JSON(500, gin.H{"error": "failed to delete comment"})
+		return
+	}
+	switch result {
+	case editMissing:
+		c.JSON(404, gin.H{"error": "comment not found"})
+		return
+	case editConflict:
+		expected := cmt.Version
+		if current, ok := loadVersionedComment(c, streamID, CommentMutationRequest{Version: &expected}); ok {
+			respondVersionConflict(c, current)
+		}
+		return
+	}
+
+	log.Printf("[GO] Stream %d: Deleted comment %d", streamID, cmt.ID)
+	auditRequest(c, streamID, "delete", &ModerationTarget{Username: cmt.Username}, req.Reason, map[string]interface{}{"comment_id": cmt.ID, "version": cmt.Version})
+	publishModEvent(reqCtx, streamID, map[string]interface{}{"type": "comments_deleted", "comment_ids": []string{id}})
+	c.JSON(200, gin.H{"success": true, "comment_id": cmt.ID})
+}
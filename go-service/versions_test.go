@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// mutate edits (with a message) or deletes comment id from version
+func mutate(t *testing.T, id int64, message string, version interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	action, body := "delete", map[string]interface{}{"version": version}
+	if message != "" {
+		action, body["message"] = "edit", message
+	}
+	w := request(t, http.MethodPost, fmt.Sprintf("/stream/1/comments/%d/%s", id, action), body, asRole(roleModerator)...)
+	return w.Code, decode(t, w)
+}
+
+func TestStaleEditsConflict(t *testing.T) {
+	resetRedis(t)
+	id := postedID(t, 1, "v1", "alice", "original")
+
+	// Both moderators read version 0
+	if status, resp := mutate(t, id, "first fix", 0); status != 200 || resp["comment"].(map[string]interface{})["version"] != float64(1) {
+		t.Fatalf("first edit: %d %v", status, resp)
+	}
+	status, resp := mutate(t, id, "second fix", 0)
+	if status != 409 || resp["reason"] != "version_conflict" || resp["version"] != float64(1) {
+		t.Fatalf("stale edit: %d %v, want 409 at version 1", status, resp)
+	}
+	if msg := resp["comment"].(map[string]interface{})["message"]; msg != "first fix" {
+		t.Fatalf("conflict carries %q, want the current comment", msg)
+	}
+	if status, resp := mutate(t, id, "", 0); status != 409 || resp["reason"] != "version_conflict" {
+		t.Fatalf("stale delete: %d %v, want 409", status, resp)
+	}
+	if status, _ := mutate(t, id, "", nil); status != 400 {
+		t.Fatalf("delete without a version: %d, want 400", status)
+	}
+	if status, resp := mutate(t, id, "", 1); status != 200 {
+		t.Fatalf("delete at the current version: %d %v", status, resp)
+	}
+}
+
+func TestConcurrentEditsOneWins(t *testing.T) {
+	resetRedis(t)
+	id := postedID(t, 1, "v1", "alice", "original")
+
+	const editors = 8
+	codes := make(chan int, editors)
+	var wg sync.WaitGroup
+	for i := 0; i < editors; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := map[string]interface{}{"version": 0, "message": fmt.Sprintf("fix %d", i)}
+			codes <- request(t, http.MethodPost, fmt.Sprintf("/stream/1/comments/%d/edit", id), body, asRole(roleModerator)...).Code
+		}(i)
+	}
+	wg.Wait()
+	close(codes)
+	won := 0
+	for status := range codes {
+		switch status {
+		case 200:
+			won++
+		case 409:
+		default:
+			t.Fatalf("concurrent edit: %d, want 200 or 409", status)
+		}
+	}
+	if won != 1 {
+		t.Fatalf("%d edits applied, want exactly 1", won)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// presenceTTL is how long a viewer counts as online after their last heartbeat
+const presenceTTL = 120 * time.Second
+
+// recordPresence stamps the viewer's last heartbeat. Unlike the online set,
+// whose TTL covers the whole set, this lets stale viewers be told apart.
+func recordPresence(ctx context.Context, streamID int64, viewerID string) {
+	now := time.Now()
+	seenKey := viewerSeenKey(streamID)
+	_, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, seenKey, &redis.Z{Score: float64(now.UnixMilli()), Member: viewerID})
+		pipe.ZRemRangeByScore(ctx, seenKey, "-inf", "("+strconv.FormatInt(now.Add(-presenceTTL).UnixMilli(), 10))
+		pipe.Expire(ctx, seenKey, presenceTTL)
+		// First heartbeat of this visit, for the new viewer wait
+		pipe.SetNX(ctx, firstSeenKey(streamID, viewerID), now.UnixMilli(), presenceTTL)
+		pipe.Expire(ctx, firstSeenKey(streamID, viewerID), presenceTTL)
+		return nil
+	})
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error recording presence: %v", streamID, err)
+	}
+}
+
+// recordHeartbeat stamps the viewer's last /heartbeat call, which keeps them
+// online past their WebSocket closing
+func recordHeartbeat(ctx context.Context, streamID int64, viewerID string) {
+	now := time.Now()
+	key := heartbeatSeenKey(streamID)
+	_, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, key, &redis.Z{Score: float64(now.UnixMilli()), Member: viewerID})
+		pipe.ZRemRangeByScore(ctx, key, "-inf", "("+strconv.FormatInt(now.Add(-presenceTTL).UnixMilli(), 10))
+		pipe.Expire(ctx, key, presenceTTL)
+		return nil
+	})
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error recording heartbeat: %v", streamID, err)
+	}
+}
+
+// heartbeatFresh reports whether the viewer called /heartbeat within presenceTTL
+func heartbeatFresh(ctx context.Context, streamID int64, viewerID string) bool {
+	last, err := rdb.ZScore(ctx, heartbeatSeenKey(streamID), viewerID).Result()
+	return err == nil && int64(last) > time.Now().Add(-presenceTTL).UnixMilli()
+}
+
+type OnlineViewer struct {
+	ViewerID string `json:"viewer_id"`
+	Username string `json:"username"`
+	LastSeen int64  `json:"last_seen"`
+}
+
+// getOnlineViewers lists a stream's currently online viewers for moderators,
+// newest heartbeat first. Viewers with no known username, or who hide their
+// presence, are only counted as anonymous.
+func getOnlineViewers(c *gin.Context) {
+	streamID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid stream id"})
+		return
+	}
+	offset, err := strconv.ParseInt(c.DefaultQuery("offset", "0"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(400, gin.H{"error": "offset must be a non-negative integer"})
+		return
+	}
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "100"), 10, 64)
+	if err != nil || limit <= 0 || limit > 500 {
+		c.JSON(400, gin.H{"error": "limit must be between 1 and 500"})
+		return
+	}
+
+	reqCtx := c.Request.Context()
+	seenKey := viewerSeenKey(streamID)
+	fresh := &redis.ZRangeBy{
+		Min:    strconv.FormatInt(time.Now().Add(-presenceTTL).UnixMilli(), 10),
+		Max:    "+inf",
+		Offset: offset,
+		Count:  limit,
+	}
+	entries, err := rdb.ZRevRangeByScoreWithScores(reqCtx, seenKey, fresh).Result()
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error listing viewers: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to list viewers"})
+		return
+	}
+	total, err := rdb.ZCount(reqCtx, seenKey, fresh.Min, fresh.Max).Result()
+	if err != nil {
+		total = 0
+	}
+
+	viewers := make([]OnlineViewer, 0, len(entries))
+	anonymous := 0
+	if len(entries) > 0 {
+		ids := make([]string, len(entries))
+		members := make([]interface{}, len(entries))
+		for i, z := range entries {
+			ids[i] = z.Member.(string)
+			members[i] = ids[i]
+		}
+		names, namesErr := rdb.HMGet(reqCtx, viewerNamesKey(streamID), ids...).Result()
+		hidden, hiddenErr := rdb.SMIsMember(reqCtx, privateViewersKey(), members...).Result()
+		if namesErr != nil || hiddenErr != nil {
+			log.Printf("[GO] Stream %d: Error resolving viewers: %v %v", streamID, namesErr, hiddenErr)
+			c.JSON(500, gin.H{"error": "failed to list viewers"})
+			return
+		}
+		for i, z := range entries {
+			name, ok := names[i].(string)
+			if !ok || hidden[i] {
+				anonymous++
+				continue
+			}
+			viewers = append(viewers, OnlineViewer{ViewerID: ids[i], Username: name, LastSeen: int64(z.Score)})
+		}
+	}
+
+	resp := gin.H{"viewers": viewers, "anonymous": anonymous, "total": total}
+	if next := offset + int64(len(entries)); next < total {
+		resp["next_offset"] = next
+	}
+	c.JSON(200, resp)
+}
+
+type PrivacyRequest struct {
+	ViewerID string `json:"viewer_id" binding:"required"`
+	Hidden   bool   `json:"hidden"`
+}
+
+// setViewerPrivacy lets a viewer, or a trusted caller for them, hide their
+// identity from viewer lists
+func setViewerPrivacy(c *gin.Context) {
+	var req PrivacyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if !authenticateViewer(c, req.ViewerID) {
+		return
+	}
+
+	reqCtx := c.Request.Context()
+	var err error
+	if req.Hidden {
+		err = rdb.SAdd(reqCtx, privateViewersKey(), req.ViewerID).Err()
+	} else {
+		err = rdb.SRem(reqCtx, privateViewersKey(), req.ViewerID).Err()
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to save privacy setting"})
+		return
+	}
+	c.JSON(200, gin.H{"success": true, "hidden": req.Hidden})
+}
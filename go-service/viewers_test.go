@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// onlineViewers lists stream 1's viewers as a moderator
+func onlineViewers(t *testing.T, query string) map[string]interface{} {
+	t.Helper()
+	w := request(t, http.MethodGet, "/stream/1/viewers"+query, nil, asRole(roleModerator)...)
+	expectStatus(t, w, 200)
+	return decode(t, w)
+}
+
+// viewerNames lists the usernames in a viewer list response
+func viewerNames(resp map[string]interface{}) []string {
+	list, _ := resp["viewers"].([]interface{})
+	names := make([]string, 0, len(list))
+	for _, v := range list {
+		names = append(names, v.(map[string]interface{})["username"].(string))
+	}
+	return names
+}
+
+func TestOnlineViewersPagination(t *testing.T) {
+	resetRedis(t)
+	for i := 1; i <= 5; i++ {
+		viewer := fmt.Sprintf("v%d", i)
+		expectStatus(t, post(t, 1, viewer, fmt.Sprintf("user%d", i), "hi"), 200)
+		heartbeatSession(t, viewer, "")
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	first := onlineViewers(t, "?limit=2")
+	if got := viewerNames(first); len(got) != 2 || got[0] != "user5" || got[1] != "user4" {
+		t.Fatalf("first page = %v, want [user5 user4]", got)
+	}
+	if first["total"] != float64(5) || first["next_offset"] != float64(2) {
+		t.Fatalf("first page total/next = %v/%v, want 5/2", first["total"], first["next_offset"])
+	}
+	last := onlineViewers(t, "?limit=2&offset=4")
+	if got := viewerNames(last); len(got) != 1 || got[0] != "user1" {
+		t.Fatalf("last page = %v, want [user1]", got)
+	}
+	if _, ok := last["next_offset"]; ok {
+		t.Fatal("last page has a next_offset")
+	}
+}
+
+func TestOnlineViewersSkipStaleAndUnnamed(t *testing.T) {
+	resetRedis(t)
+	expectStatus(t, post(t, 1, "v1", "alice", "hi"), 200)
+	heartbeatSession(t, "v1", "")
+	heartbeatSession(t, "lurker", "")
+	rdb.HSet(ctx, viewerNamesKey(1), "gone", "bob")
+	testRedis.ZAdd(viewerSeenKey(1), float64(time.Now().Add(-presenceTTL-time.Second).UnixMilli()), "gone")
+
+	resp := onlineViewers(t, "")
+	if got := viewerNames(resp); len(got) != 1 || got[0] != "alice" {
+		t.Fatalf("viewers = %v, want [alice]", got)
+	}
+	if resp["anonymous"] != float64(1) || resp["total"] != float64(2) {
+		t.Fatalf("anonymous/total = %v/%v, want 1/2", resp["anonymous"], resp["total"])
+	}
+}
+
+func TestViewerPrivacy(t *testing.T) {
+	resetRedis(t)
+	expectStatus(t, post(t, 1, "v1", "alice", "hi"), 200)
+	token := viewerSession(t, "v1")
+	other := viewerSession(t, "v2")
+	hide := map[string]interface{}{"viewer_id": "v1", "hidden": true}
+
+	expectStatus(t, request(t, http.MethodPost, "/viewer/privacy", hide), 401)
+	expectStatus(t, request(t, http.MethodPost, "/viewer/privacy", hide, "X-Session-Token", other), 401)
+	if got := viewerNames(onlineViewers(t, "")); len(got) != 1 {
+		t.Fatalf("viewers = %v, want alice still listed", got)
+	}
+
+	expectStatus(t, request(t, http.MethodPost, "/viewer/privacy", hide, "X-Session-Token", token), 200)
+	resp := onlineViewers(t, "")
+	if got := viewerNames(resp); len(got) != 0 || resp["anonymous"] != float64(2) {
+		t.Fatalf("viewers = %v anonymous = %v, want alice hidden", got, resp["anonymous"])
+	}
+}
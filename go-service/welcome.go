@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// A stream can show new joiners its chat rules: moderators set a welcome
+// message with POST /stream/:id/welcome and it comes with every initial load
+// (check-update with last_id 0, or a WebSocket or SSE connection starting
+// from the beginning), never with later polls. The text is plain or limited
+// markdown, which leaves out images and raw HTML; clients render it by
+// format. Messages are capped at WELCOME_MAX_LENGTH characters.
+var welcomeMaxLength int
+
+const (
+	welcomePlain    = "plain"
+	welcomeMarkdown = "markdown"
+)
+
+func loadWelcomeConfig() {
+	welcomeMaxLength = envInt("WELCOME_MAX_LENGTH", 1000)
+}
+
+// WelcomeMessage is a stream's welcome message as clients receive it
+type WelcomeMessage struct {
+	Text      string `json:"text"`
+	Format    string `json:"format"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// welcomeDisallowed matches the markdown a welcome message may not use
+var welcomeDisallowed = regexp.MustCompile(`!\[|<[a-zA-Z/!?]`)
+
+// loadWelcome returns a stream's welcome message, nil when it has none
+func loadWelcome(ctx context.Context, streamID int64) (*WelcomeMessage, error) {
+	fields, err := rdb.HGetAll(ctx, welcomeKey(streamID)).Result()
+	if err != nil || fields["text"] == "" {
+		return nil, err
+	}
+	welcome := &WelcomeMessage{Text: fields["text"], Format: fields["format"]}
+	if welcome.Format == "" {
+		welcome.Format = welcomePlain
+	}
+	welcome.UpdatedAt, _ = strconv.ParseInt(fields["updated_at"], 10, 64)
+	return welcome, nil
+}
+
+// initialWelcome loads the welcome message for an initial load, logging
+// failures: a missing welcome shouldn't fail the load
+func initialWelcome(ctx context.Context, streamID int64) *WelcomeMessage {
+	welcome, err := loadWelcome(ctx, streamID)
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error loading welcome message: %v", streamID, err)
+	}
+	return welcome
+}
+
+type WelcomeRequest struct {
+	Text   string `json:"text"`   // "" removes the welcome message
+	Format string `json:"format"` // plain (default) or markdown
+}
+
+// setWelcome sets or removes a stream's welcome message
+func setWelcome(c *gin.Context) {
+	streamID, ok := parseStreamID(c)
+	if !ok {
+		return
+	}
+	var req WelcomeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	text := strings.TrimSpace(strings.ToValidUTF8(req.Text, ""))
+	format := strings.ToLower(strings.TrimSpace(req.Format))
+	if format == "" {
+		format = welcomePlain
+	}
+	if format != welcomePlain && format != welcomeMarkdown {
+		c.JSON(400, gin.H{"error": "format must be plain or markdown"})
+		return
+	}
+	if utf8.RuneCountInString(text) > welcomeMaxLength {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("text must be at most %d characters", welcomeMaxLength)})
+		return
+	}
+	if format == welcomeMarkdown && welcomeDisallowed.MatchString(text) {
+		c.JSON(400, gin.H{"error": "welcome markdown can't contain images or HTML"})
+		return
+	}
+
+	reqCtx := c.Request.Context()
+	if text == "" {
+		if err := rdb.Del(reqCtx, welcomeKey(streamID)).Err(); err != nil {
+			log.Printf("[GO] Stream %d: Error removing welcome message: %v", streamID, err)
+			c.JSON(500, gin.H{"error": "failed to update welcome message"})
+			return
+		}
+		log.Printf("[GO] Stream %d: Welcome message removed", streamID)
+		auditRequest(c, streamID, "welcome_removed", nil, "", nil)
+		publishModEvent(reqCtx, streamID, map[string]interface{}{"type": "welcome_removed"})
+		c.JSON(200, gin.H{"success": true, "welcome": nil})
+		return
+	}
+
+	welcome := WelcomeMessage{Text: text, Format: format, UpdatedAt: time.Now().UnixMilli()}
+	_, err := rdb.TxPipelined(reqCtx, func(pipe redis.Pipeliner) error {
+		pipe.Del(reqCtx, welcomeKey(streamID))
+		pipe.HSet(reqCtx, welcomeKey(streamID), "text", welcome.Text, "format", welcome.Format, "updated_at", welcome.UpdatedAt)
+		return nil
+	})
+	if err != nil {
+		log.Printf("[GO] Stream %d: Error storing welcome message: %v", streamID, err)
+		c.JSON(500, gin.H{"error": "failed to update welcome message"})
+		return
+	}
+	log.Printf("[GO] Stream %d: Welcome message updated (%s)", streamID, format)
+	auditRequest(c, streamID, "welcome_updated", nil, "", map[string]interface{}{"text": text, "format": format})
+	publishModEvent(reqCtx, streamID, map[string]interface{}{"type": "welcome_updated", "format": format})
+	c.JSON(200, gin.H{"success": true, "welcome": welcome})
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// setWelcomeText sets stream 1's welcome message as a moderator
+func setWelcomeText(t *testing.T, text, format string) int {
+	t.Helper()
+	return request(t, http.MethodPost, "/stream/1/welcome", map[string]interface{}{"text": text, "format": format}, asRole(roleModerator)...).Code
+}
+
+func TestWelcomeOnlyOnInitialLoad(t *testing.T) {
+	resetRedis(t)
+	if _, ok := poll(t, 1, "v1", 0)["welcome"]; ok {
+		t.Fatal("welcome returned before one was set")
+	}
+	if status := setWelcomeText(t, "  **Be kind.** No spoilers.  ", welcomeMarkdown); status != 200 {
+		t.Fatalf("setting the welcome: %d, want 200", status)
+	}
+	expectStatus(t, post(t, 1, "v1", "alice", "hi"), 200)
+	nextSecond()
+
+	first := poll(t, 1, "v1", 0)
+	welcome, _ := first["welcome"].(map[string]interface{})
+	if welcome["text"] != "**Be kind.** No spoilers." || welcome["format"] != welcomeMarkdown || welcome["updated_at"] == nil {
+		t.Fatalf("welcome = %v, want the trimmed markdown", first["welcome"])
+	}
+	if next := poll(t, 1, "v1", int64(first["cursor"].(float64))); next["welcome"] != nil {
+		t.Fatalf("later poll welcome = %v, want none", next["welcome"])
+	}
+
+	if status := setWelcomeText(t, "", ""); status != 200 {
+		t.Fatalf("removing the welcome: %d, want 200", status)
+	}
+	if _, ok := poll(t, 1, "v2", 0)["welcome"]; ok {
+		t.Fatal("welcome returned after it was removed")
+	}
+}
+
+func TestWelcomeValidation(t *testing.T) {
+	resetRedis(t)
+	for _, tc := range []struct{ text, format string }{
+		{"rules", "html"},
+		{"![logo](https://example.com/x.png)", welcomeMarkdown},
+		{"<b>rules</b>", welcomeMarkdown},
+		{strings.Repeat("r", welcomeMaxLength+1), welcomePlain},
+	} {
+		if status := setWelcomeText(t, tc.text, tc.format); status != 400 {
+			t.Errorf("%.20q as %q: %d, want 400", tc.text, tc.format, status)
+		}
+	}
+	// Plain text is shown as is, so it may contain anything
+	if status := setWelcomeText(t, "<b>rules</b>", ""); status != 200 {
+		t.Fatalf("plain text with tags: %d, want 200", status)
+	}
+	if welcome := poll(t, 1, "v1", 0)["welcome"].(map[string]interface{}); welcome["format"] != welcomePlain {
+		t.Fatalf("format = %v, want plain by default", welcome["format"])
+	}
+	expectStatus(t, request(t, http.MethodPost, "/stream/1/welcome", map[string]interface{}{"text": "x"}, trusted...), 403)
+}
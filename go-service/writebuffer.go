@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Feed reads survive a Redis primary outage through the replica; writes
+// survive a brief one through the write buffer. With WRITE_BUFFER_SIZE set,
+// comments that can't be stored because Redis is unreachable are held in
+// memory, oldest first, and the poster is told they're "buffered". Every
+// WRITE_BUFFER_FLUSH_MS the flusher checks Redis and, once it answers,
+// publishes them in the order they were posted. They get their ID and
+// timestamp then, so they sort after everything pollers have already seen.
+// While comments are waiting, new ones queue behind them rather than
+// overtaking them. A full buffer refuses comments with a 503. The buffer is
+// per replica and lost on restart; 0 turns it off.
+//
+// The ban, shadow-ban and closed-chat checks let posts through while Redis
+// is down, so the flusher runs them again before publishing: comments from
+// posters banned or shadow-banned in the meantime are dropped, and comments
+// to a chat that has closed are held for it (or dropped, under the reject
+// policy) like any other.
+var (
+	writeBufferSize       int
+	writeBufferFlushEvery time.Duration
+)
+
+const (
+	writeBufferedHelp = "Comments held in memory because Redis was unreachable"
+	writeDroppedHelp  = "Comments refused because the write buffer was full"
+)
+
+func loadWriteBufferConfig() {
+	writeBufferSize = envInt("WRITE_BUFFER_SIZE", 0)
+	writeBufferFlushEvery = time.Duration(envInt("WRITE_BUFFER_FLUSH_MS", 500)) * time.Millisecond
+	if writeBufferFlushEvery <= 0 {
+		writeBufferFlushEvery = 500 * time.Millisecond
+	}
+}
+
+// queuedWrite is a comment waiting for Redis, with what publishing it needs
+type queuedWrite struct {
+	streamID int64
+	viewerID string
+	trusted  bool // poster skips the shadow-ban check (see commentOrigin)
+	comment  Comment
+	lifetime time.Duration
+}
+
+var writeBuffer = struct {
+	sync.Mutex
+	queue []queuedWrite
+}{}
+
+// writesPending reports whether comments are waiting to be flushed
+func writesPending() bool {
+	writeBuffer.Lock()
+	defer writeBuffer.Unlock()
+	return len(writeBuffer.queue) > 0
+}
+
+// queueWrite holds cmt for the flusher. It returns false when the buffer is full.
+func queueWrite(streamID int64, viewerID string, trusted bool, cmt *Comment, lifetime time.Duration) bool {
+	writeBuffer.Lock()
+	defer writeBuffer.Unlock()
+	if len(writeBuffer.queue) >= writeBufferSize {
+		newCounter("comments_write_buffer_full_total", writeDroppedHelp).Inc()
+		return false
+	}
+	// stampComment runs again at flush time
+	cmt.ID, cmt.Timestamp, cmt.ExpiresAt = 0, 0, 0
+	cmt.deferred = true
+	writeBuffer.queue = append(writeBuffer.queue, queuedWrite{streamID: streamID, viewerID: viewerID, trusted: trusted, comment: *cmt, lifetime: lifetime})
+	newCounter("comments_write_buffered_total", writeBufferedHelp).Inc()
+	return true
+}
+
+// storageUnavailable reports whether err means Redis couldn't be reached,
+// as opposed to a command or encoding failure that retrying won't fix
+func storageUnavailable(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, redis.ErrClosed) ||
+		strings.Contains(err.Error(), "connection pool timeout")
+}
+
+// publishOrQueue publishes cmt, or holds it in the write buffer when Redis
+// is unreachable or earlier comments are still waiting there
+func publishOrQueue(ctx context.Context, streamID int64, viewerID string, trusted bool, cmt *Comment, lifetime time.Duration) (*commentRejection, error) {
+	if writeBufferSize <= 0 {
+		return nil, publishComment(ctx, streamID, viewerID, cmt, lifetime)
+	}
+	if !writesPending() {
+		err := publishComment(ctx, streamID, viewerID, cmt, lifetime)
+		if err == nil || !storageUnavailable(err) {
+			return nil, err
+		}
+		log.Printf("[GO] Stream %d: Redis unavailable, buffering comment: %v", streamID, err)
+	}
+	if !queueWrite(streamID, viewerID, trusted, cmt, lifetime) {
+		return &commentRejection{Status: 503, Reason: "write_buffer_full", Message: "chat is temporarily unavailable, please try again shortly", RetryAfter: 5}, nil
+	}
+	return nil, nil
+}
+
+// flushWrites publishes buffered comments in order until Redis fails again,
+// leaving the comment it was on at the front for the next attempt. A comment
+// that fails for any other reason, or that the checks skipped during the
+// outage now refuse, is dropped so it can't block the rest. It returns how
+// many it stored.
+func flushWrites(ctx context.Context) (int, error) {
+	flushed := 0
+	for {
+		writeBuffer.Lock()
+		if len(writeBuffer.queue) == 0 {
+			writeBuffer.Unlock()
+			return flushed, nil
+		}
+		next := writeBuffer.queue[0]
+		writeBuffer.Unlock()
+
+		next.comment.deferred = false
+		err := flushWrite(ctx, &next)
+		if err != nil && storageUnavailable(err) {
+			return flushed, err
+		}
+		// Only the flusher removes entries, so the front is still next
+		writeBuffer.Lock()
+		writeBuffer.queue = writeBuffer.queue[1:]
+		writeBuffer.Unlock()
+		if err != nil {
+			log.Printf("[GO] Stream %d: Dropping buffered comment: %v", next.streamID, err)
+			continue
+		}
+		flushed++
+	}
+}
+
+// flushWrite re-runs the checks that failed open while w was buffered and
+// publishes it, or holds it for its closed chat
+func flushWrite(ctx context.Context, w *queuedWrite) error {
+	username := w.comment.Username
+	banned, err := lookupBan(ctx, bansKey(w.streamID), w.viewerID, username)
+	if err != nil {
+		return err
+	}
+	if banned {
+		return errors.New("poster is banned")
+	}
+	if !w.trusted {
+		if shadowed, err := lookupBan(ctx, shadowBansKey(w.streamID), w.viewerID, username); err != nil {
+			return err
+		} else if shadowed {
+			return errors.New("poster is shadow-banned")
+		}
+	}
+	allowed, err := store.AllowComments(ctx, w.streamID)
+	if err != nil {
+		return err
+	}
+	if allowed {
+		return publishComment(ctx, w.streamID, w.viewerID, &w.comment, w.lifetime)
+	}
+	if chatDisabledPolicy == chatDisabledReject {
+		return errors.New("chat is disabled")
+	}
+	held, err := bufferComment(ctx, w.streamID, w.viewerID, &w.comment, w.lifetime)
+	if err == nil && !held {
+		err = errors.New("chat is disabled and its buffer is full")
+	}
+	return err
+}
+
+// runWriteBufferFlush retries buffered comments until Redis takes them
+func runWriteBufferFlush(ctx context.Context) {
+	if writeBufferSize <= 0 {
+		return
+	}
+	ticker := time.NewTicker(writeBufferFlushEvery)
+	defer ticker.Stop()
+	jobs.setRunning("write_buffer_flush", true)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !writesPending() {
+				continue
+			}
+			if err := rdb.Ping(ctx).Err(); err != nil {
+				continue
+			}
+			flushed, err := flushWrites(ctx)
+			jobs.ran("write_buffer_flush", err)
+			if flushed > 0 {
+				log.Printf("[GO] Flushed %d buffered comments", flushed)
+			}
+			if err != nil {
+				log.Printf("[GO] Write buffer flush stopped: %v", err)
+			}
+		}
+	}
+}
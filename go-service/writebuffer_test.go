@@ -0,0 +1,112 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// withWriteBuffer turns the write buffer on for a test, empty
+func withWriteBuffer(t *testing.T) {
+	t.Helper()
+	resetRedis(t)
+	setVar(t, &writeBufferSize, 10)
+	writeBuffer.queue = nil
+	t.Cleanup(func() { writeBuffer.queue = nil })
+}
+
+// redisDown makes Redis unreachable until the returned function is called
+func redisDown(t *testing.T) func() {
+	t.Helper()
+	up, upFeed := rdb, feedRdb
+	down := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	rdb, feedRdb = down, down
+	restore := func() { rdb, feedRdb = up, upFeed }
+	t.Cleanup(func() {
+		restore()
+		down.Close()
+	})
+	return restore
+}
+
+// postDuringOutage posts a comment while Redis is down and expects it buffered
+func postDuringOutage(t *testing.T, viewerID, username, message string) {
+	t.Helper()
+	w := post(t, 1, viewerID, username, message)
+	expectStatus(t, w, 200)
+	if status := decode(t, w)["status"]; status != postBuffered {
+		t.Fatalf("status = %v, want %s", status, postBuffered)
+	}
+}
+
+func TestWriteBufferFlushesAfterOutage(t *testing.T) {
+	withWriteBuffer(t)
+	restore := redisDown(t)
+	postDuringOutage(t, "v1", "alice", "first")
+	postDuringOutage(t, "v2", "bob", "second")
+	if flushed, err := flushWrites(ctx); err == nil || flushed != 0 {
+		t.Fatalf("flush during outage = %d, %v; want 0 and an error", flushed, err)
+	}
+
+	restore()
+	flushed, err := flushWrites(ctx)
+	if err != nil || flushed != 2 {
+		t.Fatalf("flush = %d, %v; want 2", flushed, err)
+	}
+	if writesPending() {
+		t.Fatal("comments still pending after the flush")
+	}
+	nextSecond()
+	if got := messages(poll(t, 1, "v3", 0)); len(got) != 2 || got[0] != "first" || got[1] != "second" {
+		t.Fatalf("comments = %v, want [first second]", got)
+	}
+}
+
+func TestWriteBufferRechecksAtFlush(t *testing.T) {
+	withWriteBuffer(t)
+	restore := redisDown(t)
+	postDuringOutage(t, "v1", "alice", "from a banned viewer")
+	postDuringOutage(t, "v2", "bob", "from a shadow-banned viewer")
+	postDuringOutage(t, "v3", "carol", "allowed")
+	restore()
+
+	if err := storeBan(ctx, 1, ModerationTarget{ViewerID: "v1"}, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := storeBan(ctx, 1, ModerationTarget{Username: "bob"}, 0, true); err != nil {
+		t.Fatal(err)
+	}
+	if flushed, err := flushWrites(ctx); err != nil || flushed != 1 {
+		t.Fatalf("flush = %d, %v; want 1", flushed, err)
+	}
+	nextSecond()
+	if got := messages(poll(t, 1, "v4", 0)); len(got) != 1 || got[0] != "allowed" {
+		t.Fatalf("comments = %v, want [allowed]", got)
+	}
+}
+
+func TestWriteBufferRespectsChatClosedDuringOutage(t *testing.T) {
+	for _, policy := range []string{chatDisabledReject, chatDisabledBuffer} {
+		t.Run(policy, func(t *testing.T) {
+			withWriteBuffer(t)
+			setVar(t, &chatDisabledPolicy, policy)
+			restore := redisDown(t)
+			postDuringOutage(t, "v1", "alice", "while closing")
+			restore()
+
+			rdb.Set(ctx, allowCommentsKey(1), "0", 0)
+			want := map[string]int{chatDisabledReject: 0, chatDisabledBuffer: 1}[policy]
+			if flushed, err := flushWrites(ctx); err != nil || flushed != want {
+				t.Fatalf("flush = %d, %v; want %d", flushed, err, want)
+			}
+			if held := rdb.LLen(ctx, chatBufferKey(1)).Val(); held != int64(want) {
+				t.Fatalf("held for the closed chat = %d, want %d", held, want)
+			}
+			rdb.Del(ctx, allowCommentsKey(1))
+			nextSecond()
+			if got := messages(poll(t, 1, "v2", 0)); len(got) != 0 {
+				t.Fatalf("comments = %v, want none", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+// socketPresenceRefresh keeps a connected viewer in the online set without
+// client heartbeats
+const socketPresenceRefresh = presenceTTL / 2
+
+// SocketComments carries new comments to a WebSocket client, or with Type
+// "edits" new versions of comments it already has. Cursor is the last_id to
+// resume from after a reconnect.
+type SocketComments struct {
+	Type     string    `json:"type"`
+	Cursor   int64     `json:"cursor"`
+	Comments []Comment `json:"comments"`
+	Gap      *FeedGap  `json:"truncated,omitempty"` // older comments were left out
+}
+
+// SocketWelcome carries the stream's welcome message to a client starting
+// from the beginning
+type SocketWelcome struct {
+	Type    string          `json:"type"`
+	Welcome *WelcomeMessage `json:"welcome"`
+}
+
+// socketMessage is a message from the client; only "heartbeat" is understood
+type socketMessage struct {
+	Type string `json:"type"`
+}
+
+// checkSocketOrigin applies the CORS origin list to WebSocket handshakes,
+// which browsers don't subject to CORS
+func checkSocketOrigin(config *websocket.Config, req *http.Request) error {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	for _, allowed := range allowedOrigins {
+		if origin == allowed {
+			return nil
+		}
+	}
+	return errors.New("origin not allowed")
+}
+
+// streamSocket serves a stream's comments and presence over a WebSocket.
+// Comments are read exactly like the SSE endpoint; presence messages announce
+// joins, leaves and the online count as they change. A connection with a
+// viewer_id keeps that viewer online until it closes.
+func streamSocket(c *gin.Context) {
+	streamID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid stream id"})
+		return
+	}
+	cursor, err := strconv.ParseInt(c.DefaultQuery("last_id", "0"), 10, 64)
+	if err != nil || cursor < 0 {
+		c.JSON(400, gin.H{"error": "invalid cursor"})
+		return
+	}
+	viewerID := c.Query("viewer_id")
+	stringIDs := wantsStringIDs(c)
+	reqCtx := c.Request.Context()
+	feed := &feedReader{
+		streamID:   streamID,
+		cursor:     cursor,
+		applyDelay: !isPrivileged(requestRole(c)),
+		filter:     newDeliveryFilter(reqCtx, streamID, viewerID, loadDeliveryPrefs(reqCtx, viewerID), viewerAccess(c)),
+		editsSince: time.Now().UnixMilli(),
+	}
+	if c.Query("display_time") == "true" {
+		feed.zone = streamZone(reqCtx, streamID)
+	}
+
+	server := websocket.Server{
+		Handshake: checkSocketOrigin,
+		Handler: func(ws *websocket.Conn) {
+			ws.MaxPayloadBytes = 4096
+			serveSocket(ws, streamID, viewerID, feed, stringIDs)
+		},
+	}
+	var w http.ResponseWriter = c.Writer
+	if streamPingTimeout > 0 {
+		w = readDeadlineWriter{ResponseWriter: c.Writer, timeout: streamPingTimeout}
+	}
+	server.ServeHTTP(w, c.Request)
+}
+
+func serveSocket(ws *websocket.Conn, streamID int64, viewerID string, feed *feedReader, stringIDs bool) {
+	// The connection is hijacked, so the request context no longer tracks it
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	wake, unsubscribe := hub.subscribe(streamID)
+	defer unsubscribe()
+	updates, unsubscribePresence := presence.subscribe(streamID)
+	defer unsubscribePresence()
+
+	if viewerID != "" {
+		connectViewer(ctx, streamID, viewerID)
+		defer disconnectViewer(context.Background(), streamID, viewerID)
+	}
+
+	// The reader only watches for heartbeats and the connection closing,
+	// or going silent past the ping timeout
+	go func() {
+		defer cancel()
+		for {
+			var msg socketMessage
+			if err := websocket.JSON.Receive(ws, &msg); err != nil {
+				if isTimeout(err) {
+					log.Printf("[GO] Stream %d: Closing unresponsive WebSocket client", streamID)
+					newCounter("stream_connections_reaped_total", connectionsReapedHelp).Inc()
+				}
+				ws.Close()
+				return
+			}
+			if msg.Type == "heartbeat" && viewerID != "" {
+				refreshViewer(ctx, streamID, viewerID)
+			}
+		}
+	}()
+
+	send := func(v interface{}) bool {
+		payload, err := marshalResponse(v, stringIDs)
+		if err != nil {
+			return false
+		}
+		ws.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		return websocket.Message.Send(ws, string(payload)) == nil
+	}
+	sendComments := func() bool {
+		comments := feed.next(ctx)
+		gap := feed.gap()
+		if (len(comments) > 0 || gap != nil) && !send(SocketComments{Type: "comments", Cursor: feed.cursor, Comments: comments, Gap: gap}) {
+			return false
+		}
+		if edits := feed.nextEdits(ctx); len(edits) > 0 {
+			return send(SocketComments{Type: "edits", Cursor: feed.cursor, Comments: edits})
+		}
+		return true
+	}
+
+	online, _ := store.OnlineCount(ctx, streamID)
+	if !send(PresenceUpdate{Type: "presence", Online: online}) {
+		return
+	}
+	if feed.cursor == 0 {
+		if welcome := initialWelcome(ctx, streamID); welcome != nil && !send(SocketWelcome{Type: "welcome", Welcome: welcome}) {
+			return
+		}
+	}
+	if !sendComments() {
+		return
+	}
+
+	poll := time.NewTicker(ssePollInterval)
+	defer poll.Stop()
+	keepAlive := time.NewTicker(streamPingInterval)
+	defer keepAlive.Stop()
+	refresh := time.NewTicker(socketPresenceRefresh)
+	defer refresh.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepAlive.C:
+			if !send(socketMessage{Type: "ping"}) {
+				return
+			}
+			ws.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if pingFrame.Send(ws, nil) != nil {
+				return
+			}
+		case <-refresh.C:
+			if viewerID != "" {
+				refreshViewer(ctx, streamID, viewerID)
+			}
+		case upd := <-updates:
+			if !send(upd) {
+				return
+			}
+		case <-poll.C:
+			if !sendComments() {
+				return
+			}
+		case <-wake:
+			if !sendComments() {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"golang.org/x/net/websocket"
+)
+
+// presenceHubOnce runs one presence hub for every test that needs it: a hub
+// keeps its subscription until the process exits
+var presenceHubOnce sync.Once
+
+// withPresenceHub starts the presence hub and waits for its subscription
+func withPresenceHub(t *testing.T) {
+	t.Helper()
+	presenceHubOnce.Do(func() { go runPresenceHub(context.Background()) })
+	for deadline := time.Now().Add(2 * time.Second); testRedis.PubSubNumPat() == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("presence hub didn't subscribe")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// socketClient connects to stream 1's WebSocket, as viewerID when set
+func socketClient(t *testing.T, srv *httptest.Server, viewerID string) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/stream/1/ws"
+	if viewerID != "" {
+		url += "?viewer_id=" + viewerID
+	}
+	ws, err := websocket.Dial(url, "", "http://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return ws
+}
+
+// nextPresence reads messages until a presence update arrives
+func nextPresence(t *testing.T, ws *websocket.Conn) PresenceUpdate {
+	t.Helper()
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var upd PresenceUpdate
+		if err := websocket.JSON.Receive(ws, &upd); err != nil {
+			t.Fatalf("no presence update: %v", err)
+		}
+		if upd.Type == "presence" {
+			return upd
+		}
+	}
+}
+
+func TestSocketPresenceConnectAndDisconnect(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &allowedOrigins, []string{"http://localhost"})
+	withPresenceHub(t)
+	srv := httptest.NewServer(testRouter)
+	t.Cleanup(srv.Close)
+
+	watcher := socketClient(t, srv, "")
+	if upd := nextPresence(t, watcher); upd.Online != 0 {
+		t.Fatalf("initial online = %d, want 0", upd.Online)
+	}
+
+	first := socketClient(t, srv, "v1")
+	if upd := nextPresence(t, watcher); upd.Online != 1 || len(upd.Joined) != 1 || upd.Joined[0] != "v1" {
+		t.Fatalf("after v1 connected: %+v", upd)
+	}
+	// A second tab keeps the viewer online when the first closes
+	second := socketClient(t, srv, "v1")
+	nextPresence(t, second)
+	first.Close()
+	time.Sleep(100 * time.Millisecond)
+	if online, _ := store.OnlineCount(ctx, 1); online != 1 {
+		t.Fatalf("online = %d with a tab still open, want 1", online)
+	}
+
+	second.Close()
+	if upd := nextPresence(t, watcher); upd.Online != 0 || len(upd.Left) != 1 || upd.Left[0] != "v1" {
+		t.Fatalf("after v1 disconnected: %+v", upd)
+	}
+	if online, _ := store.OnlineCount(ctx, 1); online != 0 {
+		t.Fatalf("online set holds %d viewers, want 0", online)
+	}
+}
+
+func TestSocketDisconnectKeepsHeartbeatingViewerOnline(t *testing.T) {
+	resetRedis(t)
+	setVar(t, &allowedOrigins, []string{"http://localhost"})
+	withPresenceHub(t)
+	srv := httptest.NewServer(testRouter)
+	t.Cleanup(srv.Close)
+
+	// v1 polls over HTTP and also holds a socket
+	expectStatus(t, request(t, http.MethodPost, "/heartbeat", map[string]interface{}{"stream_id": 1, "viewer_id": "v1"}), 200)
+	ws := socketClient(t, srv, "v1")
+	nextPresence(t, ws)
+	ws.Close()
+	time.Sleep(100 * time.Millisecond)
+	if online, _ := store.OnlineCount(ctx, 1); online != 1 {
+		t.Fatalf("online = %d after the socket closed, want the heartbeating viewer kept", online)
+	}
+
+	// Once the heartbeats lapse, closing the socket takes them offline
+	stale := time.Now().Add(-presenceTTL - time.Second).UnixMilli()
+	rdb.ZAdd(ctx, heartbeatSeenKey(1), &redis.Z{Score: float64(stale), Member: "v1"})
+	ws = socketClient(t, srv, "v1")
+	nextPresence(t, ws)
+	ws.Close()
+	time.Sleep(100 * time.Millisecond)
+	if online, _ := store.OnlineCount(ctx, 1); online != 0 {
+		t.Fatalf("online = %d after a stale viewer's socket closed, want 0", online)
+	}
+}
@@ -0,0 +1,27 @@
+"""
+Tests for the Redis keys shared with the Go service
+"""
+from app.core.config import settings
+from app.utils.redis_keys import (
+    allow_comments_key,
+    comment_data_key,
+    comment_index_key,
+    online_set_key,
+    stream_pid_key,
+)
+
+
+def test_keys_match_go_service(monkeypatch):
+    """Test that unprefixed keys are the ones the Go service builds"""
+    monkeypatch.setattr(settings, "REDIS_KEY_PREFIX", "")
+    assert comment_index_key(7) == "comments:index:7"
+    assert comment_data_key("7") == "comments:data:7"
+    assert online_set_key(7) == "online:7"
+    assert allow_comments_key(7) == "stream:allow_comments:7"
+
+
+def test_keys_carry_the_prefix(monkeypatch):
+    """Test that every key carries REDIS_KEY_PREFIX"""
+    monkeypatch.setattr(settings, "REDIS_KEY_PREFIX", "tenant-a:")
+    for key in [comment_index_key(7), comment_data_key(7), online_set_key(7), allow_comments_key(7), stream_pid_key(7)]:
+        assert key.startswith("tenant-a:")